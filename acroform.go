@@ -0,0 +1,820 @@
+package annotation
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amoghkashyap86/form-annotation/internal/pdf"
+)
+
+// AcroForm field flags, as bit masks of the /Ff entry.
+const (
+	acroReadOnly   = 1 << 0
+	acroRequired   = 1 << 1
+	acroRadio      = 1 << 15
+	acroPushbutton = 1 << 16
+	acroComb       = 1 << 24
+)
+
+// ImportAcroForm builds an annotation from the interactive form of a PDF,
+// one field per widget, so that a form shipped with form fields need not be
+// annotated by hand. Positions are in points from the top left of each
+// page's media box. Text fields become text fields, comb fields segmented
+// ones with a cell per character, check boxes checkboxes, radio buttons
+// checkboxes in a radio group keyed by their export values, and signature
+// fields signatures. Choice fields become choice fields with the options
+// of their Opt array, or text fields when they list none, and push buttons
+// are skipped. Max lengths, the required and read-only flags, tooltips, and
+// the font size, color and alignment of the default appearance carry over.
+//
+// Field IDs are the fields' partial names in lower case, with characters
+// other than letters, digits and underscores replaced, made unique with a
+// numeric suffix; pdf_name keeps each field's full name so that values can
+// be exported to the PDF. The form ID, year and value paths are left for
+// the caller to fill in, value paths defaulting to the field IDs.
+func ImportAcroForm(r io.Reader) (*FormAnnotation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("import acroform: %w", err)
+	}
+	f, err := pdf.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("import acroform: %w", err)
+	}
+	pages, err := f.Pages()
+	if err != nil {
+		return nil, fmt.Errorf("import acroform: %w", err)
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("import acroform: PDF has no pages")
+	}
+	catalog, err := f.Catalog()
+	if err != nil {
+		return nil, fmt.Errorf("import acroform: %w", err)
+	}
+	obj, err := f.Resolve(catalog["AcroForm"])
+	if err != nil {
+		return nil, fmt.Errorf("import acroform: %w", err)
+	}
+	form, ok := obj.(pdf.Dict)
+	if !ok {
+		return nil, errors.New("import acroform: PDF has no interactive form")
+	}
+
+	first := pages[0].MediaBox
+	im := &acroImporter{
+		f:      f,
+		pages:  pages,
+		pageOf: map[int]int{},
+		ids:    map[string]bool{},
+		fonts:  map[string]string{},
+		fa: &FormAnnotation{FormMetadata: FormMetadata{
+			PageCount: len(pages),
+			PageSize:  PageSize{Width: roundUnit(first[2] - first[0]), Height: roundUnit(first[3] - first[1]), Unit: "pt"},
+		}},
+	}
+	if info, err := f.Resolve(f.Trailer()["Info"]); err == nil {
+		if d, ok := info.(pdf.Dict); ok {
+			if title, ok := d["Title"].(pdf.String); ok {
+				im.fa.FormMetadata.FormName = strings.TrimSpace(title.Text())
+			}
+		}
+	}
+	for i, page := range pages {
+		im.fa.Pages = append(im.fa.Pages, Page{PageNumber: i + 1, Fields: []Field{}})
+		im.pageOf[page.Ref.Num] = i + 1
+	}
+	// Widgets that do not name their page are found in the pages' /Annots.
+	for i, page := range pages {
+		annots, err := f.Resolve(page.Dict["Annots"])
+		if err != nil {
+			return nil, fmt.Errorf("import acroform: page %d: %w", i+1, err)
+		}
+		list, _ := annots.(pdf.Array)
+		for _, a := range list {
+			if ref, ok := a.(pdf.Ref); ok {
+				im.pageOf[ref.Num] = i + 1
+			}
+		}
+	}
+	if dr, err := f.Resolve(form["DR"]); err == nil {
+		if dr, ok := dr.(pdf.Dict); ok {
+			if fonts, err := f.Resolve(dr["Font"]); err == nil {
+				fontDict, _ := fonts.(pdf.Dict)
+				for name, v := range fontDict {
+					if font, err := f.Resolve(v); err == nil {
+						if font, ok := font.(pdf.Dict); ok {
+							if base, ok := font["BaseFont"].(pdf.Name); ok {
+								im.fonts[string(name)] = string(base)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := walkAcroFields(f, form, im.addField); err != nil {
+		return nil, fmt.Errorf("import acroform: %w", err)
+	}
+	return im.fa, nil
+}
+
+// acroAttrs are the field attributes a field inherits from its ancestors.
+type acroAttrs struct {
+	ft     pdf.Name
+	ff     int64
+	da     string
+	q      int64
+	maxLen int64
+}
+
+// acroField is a terminal field of an AcroForm: one that holds a value.
+type acroField struct {
+	// name is the full name, partial the field's own part of it.
+	name, partial string
+	// ref is the field's object, a pdf.Ref unless the field is direct.
+	ref     any
+	dict    pdf.Dict
+	attrs   acroAttrs
+	widgets []any
+}
+
+type acroImporter struct {
+	f     *pdf.File
+	pages []pdf.Page
+	// pageOf maps page and widget object numbers to page numbers.
+	pageOf map[int]int
+	ids    map[string]bool
+	// fonts maps the form's font resource names to base fonts.
+	fonts map[string]string
+	fa    *FormAnnotation
+}
+
+// walkAcroFields calls visit for each terminal field of an AcroForm, in
+// the order of the field tree.
+func walkAcroFields(f *pdf.File, form pdf.Dict, visit func(acroField) error) error {
+	inherited := acroAttrs{}
+	if da, ok := form["DA"].(pdf.String); ok {
+		inherited.da = string(da)
+	}
+	if q, ok := form["Q"].(int64); ok {
+		inherited.q = q
+	}
+	fields, err := f.Resolve(form["Fields"])
+	if err != nil {
+		return err
+	}
+	list, _ := fields.(pdf.Array)
+	w := &acroWalker{f: f, seen: map[int]bool{}, visit: visit}
+	for _, v := range list {
+		if err := w.walk(v, "", inherited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type acroWalker struct {
+	f     *pdf.File
+	seen  map[int]bool
+	visit func(acroField) error
+}
+
+// walk visits the terminal fields at or below v.
+func (w *acroWalker) walk(v any, parent string, attrs acroAttrs) error {
+	ref, _ := v.(pdf.Ref)
+	if ref != (pdf.Ref{}) {
+		if w.seen[ref.Num] {
+			return fmt.Errorf("field tree loops at object %d", ref.Num)
+		}
+		w.seen[ref.Num] = true
+	}
+	obj, err := w.f.Resolve(v)
+	if err != nil {
+		return err
+	}
+	d, ok := obj.(pdf.Dict)
+	if !ok {
+		return nil
+	}
+	partial := ""
+	if t, ok := d["T"].(pdf.String); ok {
+		partial = t.Text()
+	}
+	name := parent
+	if partial != "" {
+		name = strings.TrimPrefix(parent+"."+partial, ".")
+	}
+	if ft, ok := d["FT"].(pdf.Name); ok {
+		attrs.ft = ft
+	}
+	if ff, ok := d["Ff"].(int64); ok {
+		attrs.ff = ff
+	}
+	if da, ok := d["DA"].(pdf.String); ok {
+		attrs.da = string(da)
+	}
+	if q, ok := d["Q"].(int64); ok {
+		attrs.q = q
+	}
+	if n, err := w.f.Resolve(d["MaxLen"]); err == nil {
+		if n, ok := n.(int64); ok {
+			attrs.maxLen = n
+		}
+	}
+
+	kids, err := w.f.Resolve(d["Kids"])
+	if err != nil {
+		return err
+	}
+	kidList, _ := kids.(pdf.Array)
+	var widgets []any
+	var children []any
+	for _, kid := range kidList {
+		k, err := w.f.Resolve(kid)
+		if err != nil {
+			return err
+		}
+		kd, _ := k.(pdf.Dict)
+		if _, named := kd["T"]; named {
+			children = append(children, kid)
+		} else {
+			widgets = append(widgets, kid)
+		}
+	}
+	if len(children) > 0 {
+		for _, kid := range children {
+			if err := w.walk(kid, name, attrs); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(widgets) == 0 {
+		// The field and its only widget share a dictionary.
+		widgets = []any{v}
+	}
+	if partial == "" {
+		partial = name
+	}
+	return w.visit(acroField{name: name, partial: partial, ref: v, dict: d, attrs: attrs, widgets: widgets})
+}
+
+// choiceOptions reads a choice field's Opt array, whose entries are either
+// a value or an [export value, display text] pair.
+func (im *acroImporter) choiceOptions(v any) ([]ChoiceOption, error) {
+	v, err := im.f.Resolve(v)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := v.(pdf.Array)
+	var opts []ChoiceOption
+	for _, item := range list {
+		item, err := im.f.Resolve(item)
+		if err != nil {
+			return nil, err
+		}
+		switch item := item.(type) {
+		case pdf.String:
+			opts = append(opts, ChoiceOption{Value: item.Text()})
+		case pdf.Array:
+			if len(item) != 2 {
+				continue
+			}
+			export, _ := im.f.Resolve(item[0])
+			display, _ := im.f.Resolve(item[1])
+			e, eok := export.(pdf.String)
+			d, _ := display.(pdf.String)
+			if !eok {
+				continue
+			}
+			o := ChoiceOption{Value: e.Text()}
+			if label := d.Text(); label != o.Value {
+				o.Label = label
+			}
+			opts = append(opts, o)
+		}
+	}
+	return opts, nil
+}
+
+// addField adds a field for each of a terminal field's widgets.
+func (im *acroImporter) addField(af acroField) error {
+	name, partial, d, attrs, widgets := af.name, af.partial, af.dict, af.attrs, af.widgets
+	base := Field{PDFName: name, DataType: DataTypeString}
+	if tu, ok := d["TU"].(pdf.String); ok {
+		base.Label = strings.TrimSpace(tu.Text())
+	}
+	base.ReadOnly = attrs.ff&acroReadOnly != 0
+	if attrs.ff&acroRequired != 0 {
+		base.Validation = &Validation{Required: true}
+	}
+	radio := false
+	switch attrs.ft {
+	case "Ch":
+		base.FieldType = FieldTypeText
+		if opts, err := im.choiceOptions(d["Opt"]); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		} else if len(opts) > 0 {
+			base.FieldType, base.Options = FieldTypeChoice, opts
+		}
+		base.Style = im.textStyle(attrs)
+	case "Tx":
+		base.FieldType = FieldTypeText
+		if attrs.maxLen > 0 {
+			if base.Validation == nil {
+				base.Validation = &Validation{}
+			}
+			base.Validation.MaxLength = int(attrs.maxLen)
+			if attrs.ff&acroComb != 0 {
+				base.FieldType = FieldTypeSegmented
+			}
+		}
+		base.Style = im.textStyle(attrs)
+	case "Btn":
+		if attrs.ff&acroPushbutton != 0 {
+			return nil
+		}
+		base.FieldType, base.DataType = FieldTypeCheckbox, DataTypeBoolean
+		radio = attrs.ff&acroRadio != 0
+	case "Sig":
+		base.FieldType = FieldTypeSignature
+	default:
+		return nil
+	}
+
+	id := im.uniqueID(acroFieldID(partial))
+	var group *FieldGroup
+	if radio {
+		im.fa.FieldGroups = append(im.fa.FieldGroups, FieldGroup{GroupID: id, GroupType: GroupTypeRadio})
+		group = &im.fa.FieldGroups[len(im.fa.FieldGroups)-1]
+	}
+	for i, w := range widgets {
+		wd, err := im.f.Resolve(w)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		widget, _ := wd.(pdf.Dict)
+		page := 0
+		if p, ok := widget["P"].(pdf.Ref); ok {
+			page = im.pageOf[p.Num]
+		}
+		if ref, ok := w.(pdf.Ref); ok && page == 0 {
+			page = im.pageOf[ref.Num]
+		}
+		rect, err := im.f.Resolve(widget["Rect"])
+		if err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+		box, ok := pdf.Rect(rect)
+		if page == 0 || !ok {
+			// A widget on no page, or without a rectangle, cannot be placed.
+			continue
+		}
+		mb := im.pages[page-1].MediaBox
+		if rot := im.pages[page-1].Rotate; rot%360 != 0 {
+			return fmt.Errorf("field %q: page %d is rotated %d degrees", name, page, rot)
+		}
+
+		field := base
+		if field.Validation != nil {
+			v := *field.Validation
+			field.Validation = &v
+		}
+		field.Position = Position{
+			X:      roundUnit(box[0] - mb[0]),
+			Y:      roundUnit(mb[3] - box[3]),
+			Width:  roundUnit(box[2] - box[0]),
+			Height: roundUnit(box[3] - box[1]),
+			Unit:   "pt",
+		}
+		switch {
+		case radio:
+			state := acroOnState(im.f, widget)
+			if state == "" {
+				state = strconv.Itoa(i + 1)
+			}
+			field.FieldID = im.uniqueID(id + "_" + acroFieldID(state))
+			field.GroupID = id
+			field.OptionCode = state
+			field.FieldValue = id + "." + state
+			group.FieldIDs = append(group.FieldIDs, field.FieldID)
+			group.ExpectedOptions = append(group.ExpectedOptions, state)
+		case i == 0:
+			field.FieldID = id
+		default:
+			field.FieldID = im.uniqueID(id)
+		}
+		if field.FieldValue == "" {
+			field.FieldValue = field.FieldID
+		}
+		if field.FieldType == FieldTypeSegmented {
+			field.Segments = []Segment{{Position: field.Position, Length: int(attrs.maxLen)}}
+		}
+		im.fa.Pages[page-1].Fields = append(im.fa.Pages[page-1].Fields, field)
+	}
+	return nil
+}
+
+// acroStates returns the names of a button widget's normal appearance
+// states.
+func acroStates(f *pdf.File, widget pdf.Dict) []string {
+	ap, _ := f.Resolve(widget["AP"])
+	apDict, _ := ap.(pdf.Dict)
+	normal, _ := f.Resolve(apDict["N"])
+	states, _ := normal.(pdf.Dict)
+	names := make([]string, 0, len(states))
+	for state := range states {
+		names = append(names, string(state))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// acroOnState returns the name of a button widget's on state.
+func acroOnState(f *pdf.File, widget pdf.Dict) string {
+	for _, state := range acroStates(f, widget) {
+		if state != pdfUnchecked {
+			return state
+		}
+	}
+	return ""
+}
+
+// textStyle reads the font size, color and alignment of a default
+// appearance string such as "/Helv 10 Tf 0 g".
+func (im *acroImporter) textStyle(attrs acroAttrs) *TextStyle {
+	style := &TextStyle{}
+	tokens := strings.Fields(attrs.da)
+	number := func(i int) float64 {
+		if i < 0 || i >= len(tokens) {
+			return math.NaN()
+		}
+		v, err := strconv.ParseFloat(tokens[i], 64)
+		if err != nil {
+			return math.NaN()
+		}
+		return v
+	}
+	hex := func(vs ...float64) string {
+		var sb strings.Builder
+		sb.WriteByte('#')
+		for _, v := range vs {
+			fmt.Fprintf(&sb, "%02x", int(math.Round(min(max(v, 0), 1)*255)))
+		}
+		return sb.String()
+	}
+	for i, tok := range tokens {
+		switch tok {
+		case "Tf":
+			if size := number(i - 1); size > 0 {
+				style.FontSize = size
+			}
+			if i >= 2 && strings.HasPrefix(tokens[i-2], "/") {
+				style.FontFamily, style.FontWeight = acroFont(im.fonts, tokens[i-2][1:])
+			}
+		case "g":
+			if v := number(i - 1); !math.IsNaN(v) {
+				style.Color = hex(v, v, v)
+			}
+		case "rg":
+			if r, g, b := number(i-3), number(i-2), number(i-1); !math.IsNaN(r + g + b) {
+				style.Color = hex(r, g, b)
+			}
+		}
+	}
+	switch attrs.q {
+	case 1:
+		style.TextAlign = TextAlignCenter
+	case 2:
+		style.TextAlign = TextAlignRight
+	}
+	if *style == (TextStyle{}) {
+		return nil
+	}
+	return style
+}
+
+// acroFont maps a form font resource to a font family and weight, by its
+// base font when the form's resources name one.
+func acroFont(fonts map[string]string, resource string) (family, weight string) {
+	base := resource
+	if b, ok := fonts[resource]; ok {
+		base = b
+	}
+	switch base {
+	case "Helv":
+		return "Helvetica", ""
+	case "HeBo":
+		return "Helvetica", "bold"
+	case "TiRo":
+		return "Times", ""
+	case "TiBo":
+		return "Times", "bold"
+	case "Cour":
+		return "Courier", ""
+	case "CoBo":
+		return "Courier", "bold"
+	case "ZaDb", "ZapfDingbats":
+		return "", ""
+	}
+	// Drop a subset prefix, as in "ABCDEF+Helvetica-Bold".
+	if i := strings.IndexByte(base, '+'); i == 6 {
+		base = base[i+1:]
+	}
+	family, style, _ := strings.Cut(base, "-")
+	family, style2, _ := strings.Cut(family, ",")
+	if strings.Contains(strings.ToLower(style+style2), "bold") {
+		weight = "bold"
+	}
+	if family == "Times" && style == "Roman" {
+		return "Times", ""
+	}
+	return family, weight
+}
+
+// acroFieldID turns a PDF partial name such as "f1_01[0]" into a field ID.
+func acroFieldID(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexByte(name, '['); i > 0 {
+		name = name[:i]
+	}
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' {
+			sb.WriteRune(r)
+		} else if s := sb.String(); s != "" && !strings.HasSuffix(s, "_") {
+			sb.WriteByte('_')
+		}
+	}
+	id := strings.Trim(sb.String(), "_")
+	if id == "" {
+		return "field"
+	}
+	return id
+}
+
+// uniqueID returns id, or id with the first free numeric suffix, and
+// reserves it.
+func (im *acroImporter) uniqueID(id string) string {
+	candidate := id
+	for n := 2; im.ids[candidate]; n++ {
+		candidate = id + "_" + strconv.Itoa(n)
+	}
+	im.ids[candidate] = true
+	return candidate
+}
+
+// ExportAcroForm writes templatePDF to w with the fields' filled values
+// set in its interactive form, so that the PDF opens filled in and can be
+// handed on to tools that read form data. Fields are matched to the PDF's
+// by full name, through PDFNameFor unless opts.Names renames them. Text is
+// written as FormatValue renders it; a checkbox is set to its widget's on
+// state, and a radio group to the option code of its checked member. The
+// PDF is flagged for viewers to regenerate the field appearances, and an
+// XFA form is dropped so that they show the AcroForm values. Widgets of
+// fields with a tab index are moved to the front of their page's /Annots
+// in that order, which viewers tab through, and a /Tabs entry asking for
+// row or column order instead is dropped. The template
+// is kept as it is and the values appended as an incremental update. A
+// value for a field the PDF does not have is an error.
+func (fa *FormAnnotation) ExportAcroForm(templatePDF io.Reader, w io.Writer, opts FormDataOptions) error {
+	values, err := fa.pdfValues(nil, opts)
+	if err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+	data, err := io.ReadAll(templatePDF)
+	if err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+	f, err := pdf.Parse(data)
+	if err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+	catalog, err := f.Catalog()
+	if err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+	obj, err := f.Resolve(catalog["AcroForm"])
+	if err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+	form, ok := obj.(pdf.Dict)
+	if !ok {
+		return errors.New("export acroform: PDF has no interactive form")
+	}
+	fields := map[string]acroField{}
+	err = walkAcroFields(f, form, func(af acroField) error {
+		if _, ok := fields[af.name]; !ok {
+			fields[af.name] = af
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+
+	ex := &acroExporter{f: f, edits: map[pdf.Ref]pdf.Dict{}}
+	byName := map[string][]pdfValue{}
+	var names []string
+	for _, v := range values {
+		if _, ok := byName[v.name]; !ok {
+			names = append(names, v.name)
+		}
+		byName[v.name] = append(byName[v.name], v)
+	}
+	for _, name := range names {
+		af, ok := fields[name]
+		if !ok {
+			return fmt.Errorf("export acroform: PDF has no field named %q", name)
+		}
+		if err := ex.set(af, byName[name]); err != nil {
+			return fmt.Errorf("export acroform: field %q: %w", name, err)
+		}
+	}
+
+	if err := ex.orderWidgets(fa, fields, opts); err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+
+	form = maps.Clone(form)
+	form["NeedAppearances"] = true
+	delete(form, "XFA")
+	if ref, ok := catalog["AcroForm"].(pdf.Ref); ok {
+		ex.edits[ref] = form
+	} else {
+		root, ok := f.Trailer()["Root"].(pdf.Ref)
+		if !ok {
+			return errors.New("export acroform: document catalog is not an indirect object")
+		}
+		catalog = maps.Clone(catalog)
+		catalog["AcroForm"] = form
+		ex.edits[root] = catalog
+	}
+	u := pdf.NewUpdate(f)
+	for ref, d := range ex.edits {
+		u.Replace(ref, d)
+	}
+	if err := u.Write(w); err != nil {
+		return fmt.Errorf("export acroform: %w", err)
+	}
+	return nil
+}
+
+// orderWidgets sorts each page's annotations by the tab index of the
+// field they are a widget of, keeping the others after them in their
+// order. A radio group member ranks the widget whose on state is its
+// option code.
+func (ex *acroExporter) orderWidgets(fa *FormAnnotation, fields map[string]acroField, opts FormDataOptions) error {
+	rank := map[int]int{} // widget object number to tab index
+	for _, f := range fa.Fields() {
+		if f.TabIndex <= 0 || f.IsVirtual() {
+			continue
+		}
+		af, ok := fields[fa.pdfNameWith(f, opts)]
+		if !ok {
+			continue
+		}
+		for _, w := range af.widgets {
+			ref, ok := w.(pdf.Ref)
+			if !ok {
+				continue
+			}
+			if af.attrs.ff&acroRadio != 0 && f.OptionCode != "" {
+				wd, err := ex.f.Resolve(ref)
+				if err != nil {
+					return err
+				}
+				if d, ok := wd.(pdf.Dict); !ok || !slices.Contains(acroStates(ex.f, d), f.OptionCode) {
+					continue
+				}
+			}
+			if r, seen := rank[ref.Num]; !seen || f.TabIndex < r {
+				rank[ref.Num] = f.TabIndex
+			}
+		}
+	}
+	if len(rank) == 0 {
+		return nil
+	}
+	pages, err := ex.f.Pages()
+	if err != nil {
+		return err
+	}
+	key := func(v any) int {
+		if ref, ok := v.(pdf.Ref); ok {
+			if r, ok := rank[ref.Num]; ok {
+				return r
+			}
+		}
+		return math.MaxInt
+	}
+	for _, page := range pages {
+		obj, err := ex.f.Resolve(page.Dict["Annots"])
+		if err != nil {
+			return err
+		}
+		annots, _ := obj.(pdf.Array)
+		if !slices.ContainsFunc(annots, func(v any) bool { return key(v) != math.MaxInt }) {
+			continue
+		}
+		ordered := slices.Clone(annots)
+		slices.SortStableFunc(ordered, func(a, b any) int { return cmp.Compare(key(a), key(b)) })
+		d, err := ex.dict(page.Ref)
+		if err != nil {
+			return err
+		}
+		d["Annots"] = ordered
+		if tabs, _ := d["Tabs"].(pdf.Name); tabs == "R" || tabs == "C" {
+			delete(d, "Tabs")
+		}
+	}
+	return nil
+}
+
+// acroExporter collects edited copies of field and widget dictionaries.
+type acroExporter struct {
+	f     *pdf.File
+	edits map[pdf.Ref]pdf.Dict
+}
+
+// dict returns the editable copy of the dictionary v refers to.
+func (ex *acroExporter) dict(v any) (pdf.Dict, error) {
+	ref, ok := v.(pdf.Ref)
+	if !ok {
+		return nil, errors.New("field is not an indirect object")
+	}
+	if d, ok := ex.edits[ref]; ok {
+		return d, nil
+	}
+	obj, err := ex.f.Resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := obj.(pdf.Dict)
+	if !ok {
+		return nil, fmt.Errorf("object %d is not a dictionary", ref.Num)
+	}
+	d = maps.Clone(d)
+	ex.edits[ref] = d
+	return d, nil
+}
+
+// set writes a field's value and, for buttons, the state its widgets show.
+func (ex *acroExporter) set(af acroField, values []pdfValue) error {
+	fd, err := ex.dict(af.ref)
+	if err != nil {
+		return err
+	}
+	if af.attrs.ft != "Btn" {
+		fd["V"] = pdf.TextString(values[len(values)-1].value)
+		return nil
+	}
+	state := pdfUnchecked
+	for _, v := range values {
+		if v.check && v.value == pdfUnchecked || !v.check && !isChecked(v.value) {
+			continue
+		}
+		if af.attrs.ff&acroRadio != 0 && v.field.OptionCode != "" {
+			state = v.field.OptionCode
+			break
+		}
+		state = pdfChecked
+		for _, w := range af.widgets {
+			wd, err := ex.f.Resolve(w)
+			if err != nil {
+				return err
+			}
+			if d, ok := wd.(pdf.Dict); ok {
+				if on := acroOnState(ex.f, d); on != "" {
+					state = on
+					break
+				}
+			}
+		}
+		break
+	}
+	fd["V"] = pdf.Name(state)
+	for _, w := range af.widgets {
+		wd, err := ex.dict(w)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(acroStates(ex.f, wd), state) {
+			wd["AS"] = pdf.Name(state)
+		} else {
+			wd["AS"] = pdf.Name(pdfUnchecked)
+		}
+	}
+	return nil
+}
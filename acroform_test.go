@@ -0,0 +1,144 @@
+package annotation
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/amoghkashyap86/form-annotation/internal/pdf"
+)
+
+// acroFixture is a one-page PDF whose form has a text field, a comb field,
+// a check box, a two-button radio group, a choice field and a push button.
+const acroFixture = "testdata/acroform/form.pdf"
+
+func acroTemplate(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(acroFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// filledAcroForm is the fixture imported and filled through every kind of
+// field it has.
+func filledAcroForm(t *testing.T) *FormAnnotation {
+	t.Helper()
+	fa, err := ImportAcroForm(bytes.NewReader(acroTemplate(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := fa.SetValues(map[string]string{"name": "Ada Lovelace", "ssn": "123456789", "agree": "true",
+		"status_joint": "true", "state": "NV"}, FillOptions{})
+	if len(report.Issues) > 0 {
+		t.Fatalf("filling the fixture: %v", report.Issues)
+	}
+	return fa
+}
+
+// acroValues reads back the /V of every terminal field of a PDF's form and
+// the /AS of every widget, keyed by full name and by widget object number.
+func acroValues(t *testing.T, data []byte) (values map[string]any, states map[int]pdf.Name, form pdf.Dict) {
+	t.Helper()
+	f, err := pdf.Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog, err := f.Catalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := f.Resolve(catalog["AcroForm"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	form, _ = obj.(pdf.Dict)
+	values, states = map[string]any{}, map[int]pdf.Name{}
+	err = walkAcroFields(f, form, func(af acroField) error {
+		if v, ok := af.dict["V"]; ok {
+			values[af.name] = v
+		}
+		for _, w := range af.widgets {
+			wd, _ := f.Resolve(w)
+			if d, ok := wd.(pdf.Dict); ok {
+				if ref, ok := w.(pdf.Ref); ok {
+					states[ref.Num], _ = d["AS"].(pdf.Name)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return values, states, form
+}
+
+func TestExportAcroForm(t *testing.T) {
+	template := acroTemplate(t)
+	var out bytes.Buffer
+	if err := filledAcroForm(t).ExportAcroForm(bytes.NewReader(template), &out, FormDataOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), template) {
+		t.Error("the template was not kept as an incremental update's base")
+	}
+	values, states, form := acroValues(t, out.Bytes())
+	want := map[string]any{
+		"name":   pdf.TextString("Ada Lovelace"),
+		"ssn":    pdf.TextString("123456789"),
+		"agree":  pdf.Name("Yes"),
+		"status": pdf.Name("joint"),
+		"state":  pdf.TextString("NV"),
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("field values = %v, want %v", values, want)
+	}
+	// Widgets 9, 11 and 12 are the check box and the two radio buttons.
+	if want := map[int]pdf.Name{9: "Yes", 11: "Off", 12: "joint"}; !reflect.DeepEqual(
+		map[int]pdf.Name{9: states[9], 11: states[11], 12: states[12]}, want) {
+		t.Errorf("appearance states = %v, want %v", states, want)
+	}
+	if form["NeedAppearances"] != true {
+		t.Error("NeedAppearances is not set")
+	}
+
+	unfilled, err := ImportAcroForm(bytes.NewReader(template))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unfilled.Pages[0].Fields[0].PDFName = "missing"
+	unfilled.Pages[0].Fields[0].Value = "x"
+	if err := unfilled.ExportAcroForm(bytes.NewReader(template), &out, FormDataOptions{}); err == nil {
+		t.Error("a value for a field the PDF lacks was exported")
+	}
+}
+
+// TestImportAcroForm imports the fixture and compares the annotation with a
+// golden file: the push button is skipped, the radio buttons become a
+// group and the text field's tooltip, flags and length carry over.
+func TestImportAcroForm(t *testing.T) {
+	fa, err := ImportAcroForm(bytes.NewReader(acroTemplate(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := fa.Index().ByID("reset"); f != nil {
+		t.Error("the push button was imported as a field")
+	}
+	var radio *FieldGroup
+	for i := range fa.FieldGroups {
+		if fa.FieldGroups[i].GroupType == GroupTypeRadio {
+			radio = &fa.FieldGroups[i]
+		}
+	}
+	if radio == nil || !reflect.DeepEqual(radio.FieldIDs, []string{"status_single", "status_joint"}) {
+		t.Errorf("radio group = %+v, want status_single and status_joint", radio)
+	}
+	data, err := fa.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "acroform/import.json", []byte(data))
+}
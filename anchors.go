@@ -0,0 +1,190 @@
+package annotation
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Anchor is a named point on a page, such as the top-left corner of a
+// form section, that fields can be positioned from. When a revision moves
+// the section, moving the anchor and calling ResolvePositions moves every
+// field placed from it.
+type Anchor struct {
+	Name string  `json:"name"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	// Unit defaults to the page unit.
+	Unit string `json:"unit,omitempty"`
+}
+
+// RelativePosition places a field's top-left corner at an offset from a
+// page anchor or from another field on the same page. Exactly one of
+// Anchor and FieldID is set. The field's Position still holds the resolved
+// coordinates, so readers that ignore relative_to draw it in place.
+type RelativePosition struct {
+	Anchor  string `json:"anchor,omitempty"`
+	FieldID string `json:"field_id,omitempty"`
+	// OffsetX and OffsetY are added to the reference point, in the unit of
+	// the field's position.
+	OffsetX float64 `json:"offset_x"`
+	OffsetY float64 `json:"offset_y"`
+}
+
+// InvalidRelativePosition is the validation issue code for an anchor or
+// relative_to block ResolvePositions cannot use.
+const InvalidRelativePosition = "invalid_relative_position"
+
+// ResolvePositions sets the X and Y of every field with a relative_to
+// block from its anchor or reference field, after resolving the reference
+// field itself. Segments move with their field, keeping their place
+// within it, and width and height are left as they are. Nothing is moved
+// when a reference is unknown, on another page, or part of a cycle.
+func (fa *FormAnnotation) ResolvePositions() error {
+	if issues := fa.checkRelativePositions(); len(issues) > 0 {
+		return fmt.Errorf("resolve positions: %v", issues[0])
+	}
+	pageUnit := fa.FormMetadata.PageSize.Unit
+	// exact holds resolved corners before rounding, so a chain of fields
+	// in different units does not accumulate rounding noise.
+	exact := map[*Field][2]float64{}
+	var resolve func(page *Page, f *Field) [2]float64
+	resolve = func(page *Page, f *Field) [2]float64 {
+		if p, ok := exact[f]; ok {
+			return p
+		}
+		rel := f.RelativeTo
+		if rel == nil {
+			return [2]float64{f.Position.X, f.Position.Y}
+		}
+		var ref [2]float64
+		var unit string
+		if rel.Anchor != "" {
+			a := page.anchor(rel.Anchor)
+			ref, unit = [2]float64{a.X, a.Y}, a.Unit
+		} else {
+			other := pageField(page, rel.FieldID, fa.sameID)
+			ref, unit = resolve(page, other), other.Position.Unit
+		}
+		fieldUnit := cmp.Or(f.Position.Unit, pageUnit)
+		k := convertScale(cmp.Or(unit, pageUnit), fieldUnit)
+		p := [2]float64{ref[0]*k + rel.OffsetX, ref[1]*k + rel.OffsetY}
+		exact[f] = p
+		dx, dy := p[0]-f.Position.X, p[1]-f.Position.Y
+		f.Position.X, f.Position.Y = roundUnit(p[0]), roundUnit(p[1])
+		for i := range f.Segments {
+			sp := &f.Segments[i].Position
+			k := convertScale(fieldUnit, cmp.Or(sp.Unit, pageUnit))
+			sp.X, sp.Y = roundUnit(sp.X+dx*k), roundUnit(sp.Y+dy*k)
+		}
+		return p
+	}
+	for i := range fa.Pages {
+		page := &fa.Pages[i]
+		for j := range page.Fields {
+			resolve(page, &page.Fields[j])
+		}
+	}
+	return nil
+}
+
+// convertScale returns the factor converting from unit to target, or 1
+// when either is unknown or empty.
+func convertScale(unit, target string) float64 {
+	if unit == "" || target == "" || strings.EqualFold(unit, target) {
+		return 1
+	}
+	if k, ok := (UnitOptions{}).scale(unit, target); ok && k != 0 {
+		return k
+	}
+	return 1
+}
+
+// anchor returns the page's anchor called name, or nil.
+func (p *Page) anchor(name string) *Anchor {
+	for i := range p.Anchors {
+		if p.Anchors[i].Name == name {
+			return &p.Anchors[i]
+		}
+	}
+	return nil
+}
+
+// pageField returns the field on page whose ID matches id, or nil.
+func pageField(page *Page, id string, same func(a, b string) bool) *Field {
+	for i := range page.Fields {
+		if same(page.Fields[i].FieldID, id) {
+			return &page.Fields[i]
+		}
+	}
+	return nil
+}
+
+// checkRelativePositions reports anchors without a name or declared twice
+// on a page, and relative_to blocks naming no reference, two references,
+// an unknown one, a field on another page, or a chain that comes back to
+// the field.
+func (fa *FormAnnotation) checkRelativePositions() []ValidationIssue {
+	var issues []ValidationIssue
+	for i := range fa.Pages {
+		page := &fa.Pages[i]
+		seen := map[string]bool{}
+		for _, a := range page.Anchors {
+			at := ValidationIssue{Code: InvalidRelativePosition, Severity: SeverityError, Page: page.PageNumber}
+			switch {
+			case a.Name == "":
+				at.Message = "anchor has no name"
+			case seen[a.Name]:
+				at.Message = fmt.Sprintf("anchor %q is declared twice", a.Name)
+			default:
+				seen[a.Name] = true
+				continue
+			}
+			issues = append(issues, at)
+		}
+		for j := range page.Fields {
+			f := &page.Fields[j]
+			rel := f.RelativeTo
+			if rel == nil {
+				continue
+			}
+			at := ValidationIssue{Code: InvalidRelativePosition, Severity: SeverityError, FieldID: f.FieldID, Page: page.PageNumber}
+			switch {
+			case (rel.Anchor == "") == (rel.FieldID == ""):
+				at.Message = "relative_to must name exactly one of an anchor and a field"
+			case rel.Anchor != "" && page.anchor(rel.Anchor) == nil:
+				at.Message = fmt.Sprintf("page %d has no anchor %q", page.PageNumber, rel.Anchor)
+			case rel.FieldID != "" && pageField(page, rel.FieldID, fa.sameID) == nil:
+				if fa.GetFieldByID(rel.FieldID) != nil {
+					at.Message = fmt.Sprintf("reference field %q is on another page", rel.FieldID)
+				} else {
+					at.Message = fmt.Sprintf("reference field %q does not exist", rel.FieldID)
+				}
+			case fa.relativeCycle(page, f):
+				at.Message = "relative_to chain comes back to the field"
+			default:
+				continue
+			}
+			issues = append(issues, at)
+		}
+	}
+	return issues
+}
+
+// relativeCycle reports whether following f's reference fields on page
+// leads back to f.
+func (fa *FormAnnotation) relativeCycle(page *Page, f *Field) bool {
+	seen := map[*Field]bool{f: true}
+	for cur := f; cur.RelativeTo != nil && cur.RelativeTo.FieldID != ""; {
+		next := pageField(page, cur.RelativeTo.FieldID, fa.sameID)
+		if next == nil {
+			return false
+		}
+		if seen[next] {
+			return next == f
+		}
+		seen[next] = true
+		cur = next
+	}
+	return false
+}
@@ -1,33 +1,93 @@
 package annotation
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"iter"
 	"os"
+	"time"
 )
 
 type FormAnnotation struct {
 	FormMetadata FormMetadata `json:"form_metadata"`
 	Pages        []Page       `json:"pages"`
 	FieldGroups  []FieldGroup `json:"field_groups,omitempty"`
+	// History lists the changes TrackedUpdate made, oldest first.
+	History []ChangeRecord `json:"history,omitempty"`
+
+	// CaseInsensitiveIDs makes field ID lookups, group membership, SetValues
+	// and rename collision checks ignore case. IDs keep their original casing.
+	CaseInsensitiveIDs bool `json:"-"`
+	// ExprLimits overrides DefaultExprLimits for this annotation's rules.
+	ExprLimits *ExprLimits `json:"-"`
+
+	// index is the FieldIndex Index returns until a mutation discards it.
+	index *FieldIndex
 }
 
 type FormMetadata struct {
-	FormID    string   `json:"form_id"`
-	FormName  string   `json:"form_name"`
-	Year      int      `json:"year"`
-	PageCount int      `json:"page_count"`
-	PageSize  PageSize `json:"page_size"`
+	FormID      string       `json:"form_id"`
+	FormName    string       `json:"form_name"`
+	Year        int          `json:"year"`
+	PageCount   int          `json:"page_count"`
+	PageSize    PageSize     `json:"page_size"`
+	NameMapping *NameMapping `json:"name_mapping,omitempty"`
+	// RenderTargets declares render targets beyond screen and print.
+	RenderTargets []RenderTarget `json:"render_targets,omitempty"`
+	// SchemaVersion is the version of the schema the document is shaped
+	// for; v1 when absent. Older documents are migrated on load and every
+	// document is written at CurrentSchemaVersion.
+	SchemaVersion SchemaVersion `json:"schema_version,omitempty"`
+	// MinReaderVersion and RequiredCapabilities are written on save from the
+	// capabilities the document uses and checked on load.
+	MinReaderVersion     SchemaVersion `json:"min_reader_version,omitempty"`
+	RequiredCapabilities []Capability  `json:"required_capabilities,omitempty"`
+	// Parts records the forms a packet was merged from.
+	Parts []PacketPart `json:"packet_parts,omitempty"`
+	// CoordinateOrigin is the corner positions are measured from; the top
+	// left when empty.
+	CoordinateOrigin Origin `json:"coordinate_origin,omitempty"`
+	// Revision counts the edits Revise has recorded.
+	Revision int `json:"revision,omitempty"`
+	// SourcePDFHash is the ContentHash of the template PDF the annotation
+	// was traced from.
+	SourcePDFHash string `json:"source_pdf_hash,omitempty"`
+	// CreatedAt, UpdatedAt and Author record when the annotation was first
+	// and last revised, and by whom.
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	Author    string    `json:"author,omitempty"`
+	// Rounding, when set, rounds the amounts SumFields totals, SetAmount
+	// stores and calculations produce, such as to whole dollars.
+	Rounding *Rounding `json:"rounding,omitempty"`
+	// Extends names the base form an overlay document builds on;
+	// ResolveTemplate applies the overlay to it.
+	Extends *FormRef `json:"extends,omitempty"`
 }
 
 type PageSize struct {
 	Width  float64 `json:"width"`
 	Height float64 `json:"height"`
 	Unit   string  `json:"unit"`
+	// Frame declares what positions are measured from; the media box when nil.
+	Frame *CoordinateFrame `json:"coordinate_frame,omitempty"`
 }
 
 type Page struct {
-	PageNumber int     `json:"page_number"`
-	Fields     []Field `json:"fields"`
+	PageNumber int           `json:"page_number"`
+	Fields     []Field       `json:"fields"`
+	Includes   []PageInclude `json:"includes,omitempty"`
+	// Frame overrides the document's coordinate frame for this page.
+	Frame *CoordinateFrame `json:"coordinate_frame,omitempty"`
+	// Anchors are named points fields can be positioned from.
+	Anchors []Anchor `json:"anchors,omitempty"`
+	// Rotation is how many degrees clockwise viewers turn the page, as the
+	// PDF's /Rotate says; positions are still measured on the page unturned.
+	Rotation int `json:"rotation,omitempty"`
+	// PageSize overrides the document's page width and height for this page.
+	PageSize *PageSize `json:"page_size,omitempty"`
 }
 
 type FieldType string
@@ -40,6 +100,10 @@ const (
 	FieldTypeDate      FieldType = "date"
 	FieldTypeSegmented FieldType = "segmented"
 	FieldTypeSignature FieldType = "signature"
+	FieldTypeVirtual   FieldType = "virtual"
+	FieldTypeChoice    FieldType = "choice"
+	FieldTypeBarcode   FieldType = "barcode"
+	FieldTypeImage     FieldType = "image"
 )
 
 type DataType string
@@ -53,18 +117,75 @@ const (
 )
 
 type Field struct {
-	FieldID    string      `json:"field_id"`
-	IRSLineRef string      `json:"irs_line_reference,omitempty"`
-	FieldType  FieldType   `json:"field_type"`
-	DataType   DataType    `json:"data_type"`
-	Position   Position    `json:"position,omitempty"`
-	Segments   []Segment   `json:"segments,omitempty"`
-	Style      *TextStyle  `json:"style,omitempty"`
-	CheckStyle *CheckStyle `json:"check_style,omitempty"`
-	Formatting *Formatting `json:"formatting,omitempty"`
-	Validation *Validation `json:"validation,omitempty"`
-	GroupID    string      `json:"group_id,omitempty"`
-	FieldValue string      `json:"field_value"`
+	FieldID    string                               `json:"field_id"`
+	IRSLineRef string                               `json:"irs_line_reference,omitempty"`
+	Label      string                               `json:"label,omitempty"`
+	PDFName    string                               `json:"pdf_name,omitempty"`
+	FieldType  FieldType                            `json:"field_type"`
+	DataType   DataType                             `json:"data_type"`
+	Position   Position                             `json:"position,omitempty"`
+	Segments   []Segment                            `json:"segments,omitempty"`
+	Style      *TextStyle                           `json:"style,omitempty"`
+	CheckStyle *CheckStyle                          `json:"check_style,omitempty"`
+	Formatting *Formatting                          `json:"formatting,omitempty"`
+	Validation *Validation                          `json:"validation,omitempty"`
+	GroupID    string                               `json:"group_id,omitempty"`
+	FieldValue string                               `json:"field_value"`
+	Value      string                               `json:"value,omitempty"`
+	Sensitive  bool                                 `json:"sensitive,omitempty"`
+	ReadOnly   bool                                 `json:"read_only,omitempty"`
+	OptionCode string                               `json:"option_code,omitempty"`
+	Help       *HelpContent                         `json:"help,omitempty"`
+	Overrides  map[RenderTarget]FieldRenderOverride `json:"overrides,omitempty"`
+	// IntroducedYear and RetiredYear bound the tax years the field appears in.
+	IntroducedYear *int `json:"introduced_year,omitempty"`
+	RetiredYear    *int `json:"retired_year,omitempty"`
+	// Transforms names the registered transforms applied, in order, to
+	// upstream values on fill and inverted on extraction.
+	Transforms []string `json:"transforms,omitempty"`
+	// TabIndex is the field's place in its page's tab order, from 1; zero
+	// leaves it to the reader. ApplyTabOrder computes it.
+	TabIndex int `json:"tab_index,omitempty"`
+	// Removed marks, in the overrides passed to DeriveForYear, a base field
+	// to delete.
+	Removed bool `json:"removed,omitempty"`
+	// Provenance records the field's last tracked change.
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// Calculation computes the value from other fields; Recalculate
+	// applies it.
+	Calculation *Calculation `json:"calculation,omitempty"`
+	// Conditions show, hide or disable the field depending on other values.
+	Conditions *Conditions `json:"conditions,omitempty"`
+	// Options are the values a choice field may hold, in display order.
+	Options []ChoiceOption `json:"options,omitempty"`
+	// RelativeTo places the field from an anchor or another field;
+	// ResolvePositions recomputes Position from it.
+	RelativeTo *RelativePosition `json:"relative_to,omitempty"`
+	// Tooltip is the short hint a filing UI shows for the field.
+	Tooltip string `json:"tooltip,omitempty"`
+	// Localized holds the label and tooltip in other languages, keyed by
+	// language tag; LabelFor and TooltipFor pick from it.
+	Localized map[string]LabelSet `json:"localized,omitempty"`
+	// Barcode and Image configure barcode and image fields.
+	Barcode *BarcodeSpec `json:"barcode,omitempty"`
+	Image   *ImageSpec   `json:"image,omitempty"`
+	// Sensitivity names the kind of personal data the value is, one of the
+	// Sensitivity constants; it marks the field sensitive and selects it for
+	// RedactOptions.Sensitivities.
+	Sensitivity string `json:"sensitivity,omitempty"`
+	// Signature names a signature field's signer role and date field.
+	Signature *SignatureSpec `json:"signature,omitempty"`
+}
+
+// ChoiceOption is one entry of a choice field, such as a filing status or
+// a state code.
+type ChoiceOption struct {
+	// Value is what the field holds when the option is chosen.
+	Value string `json:"value"`
+	// Label is shown to the person filling the form; Value when empty.
+	Label string `json:"label,omitempty"`
+	// ExportValue is written to PDF form data; Value when empty.
+	ExportValue string `json:"export_value,omitempty"`
 }
 
 type Position struct {
@@ -82,7 +203,7 @@ type Segment struct {
 
 type TextStyle struct {
 	FontFamily    string  `json:"font_family,omitempty"`
-	FontSize      int     `json:"font_size,omitempty"`
+	FontSize      float64 `json:"font_size,omitempty"`
 	FontWeight    string  `json:"font_weight,omitempty"`
 	TextAlign     string  `json:"text_align,omitempty"`
 	VerticalAlign string  `json:"vertical_align,omitempty"`
@@ -91,9 +212,9 @@ type TextStyle struct {
 }
 
 type CheckStyle struct {
-	MarkType   string `json:"mark_type"`
-	MarkSize   int    `json:"mark_size"`
-	MarkWeight string `json:"mark_weight"`
+	MarkType   string  `json:"mark_type"`
+	MarkSize   float64 `json:"mark_size"`
+	MarkWeight string  `json:"mark_weight"`
 }
 
 type Formatting struct {
@@ -104,96 +225,257 @@ type Formatting struct {
 	Suffix         string `json:"suffix,omitempty"`
 	DateFormat     string `json:"date_format,omitempty"`
 	TextTransform  string `json:"text_transform,omitempty"`
+	// SegmentAlign places a value shorter than a segmented field's cells
+	// against the left or right end; the default is right for numbers and
+	// left otherwise.
+	SegmentAlign string `json:"segment_align,omitempty"`
+	// StripChars lists the characters, such as the dashes of an SSN,
+	// removed from a value before it is distributed across segments.
+	StripChars string `json:"strip_chars,omitempty"`
 }
 
 type Validation struct {
-	Pattern   string  `json:"pattern,omitempty"`
-	Min       float64 `json:"min,omitempty"`
-	Max       float64 `json:"max,omitempty"`
-	MinLength int     `json:"min_length,omitempty"`
-	MaxLength int     `json:"max_length,omitempty"`
+	Required   bool             `json:"required,omitempty"`
+	Level      RequirementLevel `json:"requirement_level,omitempty"`
+	RequiredIf string           `json:"required_if,omitempty"`
+	Pattern    string           `json:"pattern,omitempty"`
+	Min        float64          `json:"min,omitempty"`
+	Max        float64          `json:"max,omitempty"`
+	MinLength  int              `json:"min_length,omitempty"`
+	MaxLength  int              `json:"max_length,omitempty"`
+	MinDate    string           `json:"min_date,omitempty"`
+	MaxDate    string           `json:"max_date,omitempty"`
 }
 
 type FieldGroup struct {
-	GroupID   string   `json:"group_id"`
-	GroupType string   `json:"group_type"`
-	FieldIDs  []string `json:"field_ids"`
+	GroupID         string   `json:"group_id"`
+	GroupType       string   `json:"group_type"`
+	FieldIDs        []string `json:"field_ids"`
+	ExpectedOptions []string `json:"expected_options,omitempty"`
+	FieldValue      string   `json:"field_value,omitempty"`
+	// Required makes leaving a yes/no group unanswered a finding.
+	Required bool `json:"required,omitempty"`
+	// Repeat makes the group a section of rows ExpandRows materializes.
+	Repeat *RepeatingRows `json:"repeat,omitempty"`
+	// Validation constrains the members' values together.
+	Validation *GroupValidation `json:"validation,omitempty"`
+}
+
+// LoadFile reads an annotation file, stripping a leading byte order mark and
+// rejecting or transcoding input that is not UTF-8. Files named .yaml or
+// .yml are read as YAML.
+func LoadFile(ctx context.Context, filepath string, opts LoadOptions) (*FormAnnotation, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	load := Load
+	if isYAMLName(filepath) {
+		load = LoadYAML
+	}
+	annotation, err := load(f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath, err)
+	}
+	return annotation, nil
+}
+
+// parseAnnotationFile parses data as YAML when name has a .yaml or .yml
+// extension, and as JSON otherwise.
+func parseAnnotationFile(name string, data []byte, opts LoadOptions) (*FormAnnotation, error) {
+	if isYAMLName(name) {
+		return parseYAMLAnnotation(data, opts)
+	}
+	return parseAnnotation(data, opts)
+}
+
+func parseAnnotation(data []byte, opts LoadOptions) (*FormAnnotation, error) {
+	data, err := decodeInput(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	fa, err := decodeAnnotation(data, opts)
+	if err != nil {
+		return nil, locateJSONError(err, data)
+	}
+	return fa, nil
 }
 
-func LoadFromFile(filepath string) (*FormAnnotation, error) {
-	data, err := os.ReadFile(filepath)
+func decodeAnnotation(data []byte, opts LoadOptions) (*FormAnnotation, error) {
+	version, err := documentVersion(data)
 	if err != nil {
 		return nil, err
 	}
+	if version > CurrentSchemaVersion && !opts.IgnoreReaderVersion {
+		return nil, &UnsupportedDocumentError{SchemaVersion: version}
+	}
+	migrated, changed, err := migrate(data, version)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Strict {
+		if err := checkStrict(migrated); err != nil {
+			return nil, err
+		}
+	}
 	var annotation FormAnnotation
-	if err := json.Unmarshal(data, &annotation); err != nil {
+	if err := json.Unmarshal(migrated, &annotation); err != nil {
+		if changed {
+			// Offsets refer to the migrated document, not the input, but
+			// its paths still name the same values.
+			e := &JSONError{Err: fmt.Errorf("schema version %d document, migrated: %w", version, err)}
+			if offset, ok := jsonErrorOffset(err); ok {
+				loc := locateJSONPath(migrated, offset)
+				e.Path, e.Page, e.FieldID = loc.path, loc.page, loc.fieldID
+			}
+			return nil, e
+		}
+		return nil, err
+	}
+	if version < CurrentSchemaVersion {
+		annotation.FormMetadata.SchemaVersion = CurrentSchemaVersion
+	}
+	if !opts.IgnoreReaderVersion {
+		if err := annotation.FormMetadata.checkReader(); err != nil {
+			return nil, err
+		}
+	}
+	report, err := opts.Gates.apply(&annotation)
+	if err != nil {
 		return nil, err
 	}
-	return &annotation, nil
+	if len(report.Changes) > 0 && opts.OnStripped != nil {
+		opts.OnStripped(report)
+	}
+	return runLoadHooks(&annotation)
 }
 
-// SaveToFile writes the FormAnnotation to a JSON file.
+// SaveToFile writes the FormAnnotation to a JSON file as Save writes it:
+// as UTF-8 without a byte order mark, stamped with the current schema
+// version and recording the reader version and capabilities it needs. The
+// document is encoded before the file is created, so one that cannot be
+// saved leaves no file behind.
+// Documents using experimental capabilities are written only through
+// SaveToFileWithOptions with their gates open.
 func (fa *FormAnnotation) SaveToFile(filepath string) error {
-	data, err := json.MarshalIndent(fa, "", "  ")
+	var buf bytes.Buffer
+	if err := fa.Save(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath, buf.Bytes(), 0644)
+}
+
+// SaveToFileCanonical writes the annotation like SaveToFile, in the order
+// Normalize gives it, without reordering the annotation itself.
+func (fa *FormAnnotation) SaveToFileCanonical(filepath string) error {
+	data, err := fa.canonicalJSON(NormalizeOptions{})
 	if err != nil {
 		return err
 	}
 	return os.WriteFile(filepath, data, 0644)
 }
 
+func (fa *FormAnnotation) canonicalJSON(opts NormalizeOptions) ([]byte, error) {
+	c := fa.Clone()
+	c.NormalizeWithOptions(opts)
+	return c.encode()
+}
+
+func (fa *FormAnnotation) encode() ([]byte, error) {
+	fa, err := fa.forSave()
+	if err != nil {
+		return nil, err
+	}
+	if err := FeatureGates(nil).checkEmit(fa); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(fa.withReaderRequirements(), "", "  ")
+}
+
 // GetFieldByID finds a field by its ID across all pages.
 func (fa *FormAnnotation) GetFieldByID(fieldID string) *Field {
+	return fa.FindField(fieldID, LookupOptions{CaseInsensitive: fa.CaseInsensitiveIDs})
+}
+
+// Fields returns every field across all pages, in page order. The
+// pointers refer to the annotation's own fields, so changes made through
+// them stick; only the slice of pointers is allocated, where GetAllFields
+// copied every field. Callers that only loop over the fields can use
+// FieldsSeq and allocate nothing.
+func (fa *FormAnnotation) Fields() []*Field {
+	n := 0
 	for i := range fa.Pages {
-		for j := range fa.Pages[i].Fields {
-			if fa.Pages[i].Fields[j].FieldID == fieldID {
-				return &fa.Pages[i].Fields[j]
-			}
-		}
+		n += len(fa.Pages[i].Fields)
 	}
-	return nil
+	if n == 0 {
+		return nil
+	}
+	fields := make([]*Field, 0, n)
+	for f := range fa.FieldsSeq() {
+		fields = append(fields, f)
+	}
+	return fields
 }
 
-// GetFieldsByFieldValue finds all fields that match a field value path.
-func (fa *FormAnnotation) GetFieldsByFieldValue(fieldValue string) []Field {
-	var fields []Field
-	for _, page := range fa.Pages {
-		for _, field := range page.Fields {
-			if field.FieldValue == fieldValue {
-				fields = append(fields, field)
+// FieldsSeq yields every field across all pages, in page order, as Fields
+// returns them. Fields added or removed while iterating may or may not be
+// visited.
+func (fa *FormAnnotation) FieldsSeq() iter.Seq[*Field] {
+	return func(yield func(*Field) bool) {
+		for i := range fa.Pages {
+			for j := range fa.Pages[i].Fields {
+				if !yield(&fa.Pages[i].Fields[j]) {
+					return
+				}
 			}
 		}
 	}
-	return fields
 }
 
-// GetFieldsOnPage returns all fields on a specific page.
-func (fa *FormAnnotation) GetFieldsOnPage(pageNum int) []Field {
-	for _, page := range fa.Pages {
-		if page.PageNumber == pageNum {
-			return page.Fields
+// FieldsByValuePath returns the fields bound to a field value path.
+func (fa *FormAnnotation) FieldsByValuePath(path string) []*Field {
+	return fa.fieldsWhere(func(f *Field) bool { return f.FieldValue == path })
+}
+
+// FieldsOnPage returns the fields on a page, or nil when there is no such page.
+func (fa *FormAnnotation) FieldsOnPage(pageNum int) []*Field {
+	for i := range fa.Pages {
+		if fa.Pages[i].PageNumber == pageNum {
+			fields := make([]*Field, len(fa.Pages[i].Fields))
+			for j := range fa.Pages[i].Fields {
+				fields[j] = &fa.Pages[i].Fields[j]
+			}
+			return fields
 		}
 	}
 	return nil
 }
 
-// GetFieldsByGroupID returns all fields belonging to a specific group.
-func (fa *FormAnnotation) GetFieldsByGroupID(groupID string) []Field {
-	var fields []Field
-	for _, page := range fa.Pages {
-		for _, field := range page.Fields {
-			if field.GroupID == groupID {
-				fields = append(fields, field)
-			}
+// page returns the page numbered pageNum, or nil.
+func (fa *FormAnnotation) page(pageNum int) *Page {
+	for i := range fa.Pages {
+		if fa.Pages[i].PageNumber == pageNum {
+			return &fa.Pages[i]
 		}
 	}
-	return fields
+	return nil
+}
+
+// FieldsInGroup returns the fields whose group_id is groupID.
+func (fa *FormAnnotation) FieldsInGroup(groupID string) []*Field {
+	return fa.fieldsWhere(func(f *Field) bool { return f.GroupID == groupID })
 }
 
-// GetAllFields returns all fields across all pages.
-func (fa *FormAnnotation) GetAllFields() []Field {
-	var fields []Field
-	for _, page := range fa.Pages {
-		fields = append(fields, page.Fields...)
+func (fa *FormAnnotation) fieldsWhere(match func(f *Field) bool) []*Field {
+	var fields []*Field
+	for f := range fa.FieldsSeq() {
+		if match(f) {
+			fields = append(fields, f)
+		}
 	}
 	return fields
 }
@@ -209,9 +491,5 @@ func (fa *FormAnnotation) ToJSON() (string, error) {
 
 // FromJSON parses a JSON string into a FormAnnotation.
 func FromJSON(jsonStr string) (*FormAnnotation, error) {
-	var annotation FormAnnotation
-	if err := json.Unmarshal([]byte(jsonStr), &annotation); err != nil {
-		return nil, err
-	}
-	return &annotation, nil
+	return parseAnnotation([]byte(jsonStr), LoadOptions{})
 }
@@ -0,0 +1,175 @@
+package annotation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// TestFileHelpersWrapStreams checks that LoadFile and SaveToFile read and
+// write what Load, LoadYAML and Save do, so that an annotation can come
+// from any reader, here an fs.FS as embed.FS would give.
+func TestFileHelpersWrapStreams(t *testing.T) {
+	fa := loadExample(t)
+	var saved bytes.Buffer
+	if err := fa.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	yaml, err := fa.ToYAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"f1040.json": {Data: saved.Bytes()}, "f1040.yaml": {Data: []byte(yaml)}}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "f1040.json")
+	if err := fa.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(path); err != nil || !bytes.Equal(data, saved.Bytes()) {
+		t.Errorf("SaveToFile wrote other bytes than Save: %v", err)
+	}
+	for name, load := range map[string]func(io.Reader, LoadOptions) (*FormAnnotation, error){
+		"f1040.json": Load,
+		"f1040.yaml": LoadYAML,
+	} {
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromFS, err := load(f, LoadOptions{})
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, fsys[name].Data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		fromFile, err := LoadFile(context.Background(), path, LoadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(fromFS, fromFile) {
+			t.Errorf("%s: LoadFile and the reader loaders disagree", name)
+		}
+	}
+
+	gated := gatedForms()[CapYesNoGroups]
+	refused := filepath.Join(dir, "refused.json")
+	if err := gated.SaveToFile(refused); err == nil {
+		t.Fatal("an ungated document was saved")
+	}
+	if _, err := os.Stat(refused); !os.IsNotExist(err) {
+		t.Errorf("a refused save left a file behind: %v", err)
+	}
+}
+
+func TestFieldsSeq(t *testing.T) {
+	fa := denseForm(20, 50)
+	var seq []*Field
+	for f := range fa.FieldsSeq() {
+		seq = append(seq, f)
+	}
+	if !reflect.DeepEqual(seq, fa.Fields()) {
+		t.Error("FieldsSeq and Fields disagree")
+	}
+	n := 0
+	for range fa.FieldsSeq() {
+		if n++; n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("iteration continued to %d fields after break", n)
+	}
+	for f := range fa.FieldsSeq() {
+		f.Value = "set"
+	}
+	if fa.Pages[0].Fields[999].Value != "set" {
+		t.Error("FieldsSeq yielded copies")
+	}
+}
+
+// BenchmarkAllFields compares visiting every field of a 1,000-field page
+// through the iterator, the pointer slice and the deprecated copies.
+func BenchmarkAllFields(b *testing.B) {
+	fa := denseForm(20, 50)
+	visit := func(f *Field) {
+		if f.FieldID == "" {
+			b.Fatal("empty field ID")
+		}
+	}
+	b.Run("FieldsSeq", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for f := range fa.FieldsSeq() {
+				visit(f)
+			}
+		}
+	})
+	b.Run("Fields", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, f := range fa.Fields() {
+				visit(f)
+			}
+		}
+	})
+	b.Run("GetAllFields", func(b *testing.B) {
+		legacy(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, f := range fa.GetAllFields() {
+				visit(&f)
+			}
+		}
+	})
+}
+
+// BenchmarkFieldsOnPage compares FieldsOnPage with GetFieldsOnPage, which
+// returns the page's own slice rather than copies.
+func BenchmarkFieldsOnPage(b *testing.B) {
+	fa := denseForm(20, 50)
+	b.Run("FieldsOnPage", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fa.FieldsOnPage(1)
+		}
+	})
+	b.Run("GetFieldsOnPage", func(b *testing.B) {
+		legacy(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fa.GetFieldsOnPage(1)
+		}
+	})
+}
+
+// BenchmarkFieldsInGroup compares the pointer and copying group accessors.
+func BenchmarkFieldsInGroup(b *testing.B) {
+	fa := denseForm(20, 50)
+	for f := range fa.FieldsSeq() {
+		if f.Position.X < 100 {
+			f.GroupID = "left"
+		}
+	}
+	b.Run("FieldsInGroup", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fa.FieldsInGroup("left")
+		}
+	})
+	b.Run("GetFieldsByGroupID", func(b *testing.B) {
+		legacy(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fa.GetFieldsByGroupID("left")
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package annotation
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"strconv"
+	"sync"
+)
+
+// AnnotationStore serves the annotations of a Store by form ID and year to
+// concurrent callers, such as the handlers of a web server. Each form is
+// loaded once, on first use, and kept as a snapshot that is never modified:
+// Snapshot hands it out for reading, Copy hands out a deep copy to fill or
+// edit, and Put replaces it with a copy of the new version, so readers
+// holding the old snapshot keep a consistent document. It is safe for
+// concurrent use.
+type AnnotationStore struct {
+	store  Store
+	prefix string
+	opts   LoadOptions
+
+	mu    sync.RWMutex
+	keys  map[libraryKey]string
+	forms map[libraryKey]*storeSnapshot
+}
+
+// storeSnapshot is a form being loaded or loaded; done is closed once fa
+// or err is set.
+type storeSnapshot struct {
+	done chan struct{}
+	fa   *FormAnnotation
+	err  error
+}
+
+// NewAnnotationStore indexes the annotations under prefix in store by the
+// form ID and year in their metadata, as ListForms reads them. Two
+// documents for the same form and year are an error.
+func NewAnnotationStore(ctx context.Context, store Store, prefix string, opts LoadOptions) (*AnnotationStore, error) {
+	entries, err := ListForms(ctx, store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	s := &AnnotationStore{
+		store:  store,
+		prefix: prefix,
+		opts:   opts,
+		keys:   map[libraryKey]string{},
+		forms:  map[libraryKey]*storeSnapshot{},
+	}
+	for _, e := range entries {
+		k := libraryKey{e.Metadata.FormID, e.Metadata.Year}
+		if other, ok := s.keys[k]; ok {
+			return nil, fmt.Errorf("annotation store: %s and %s both hold %s/%d", other, e.Key, k.formID, k.year)
+		}
+		s.keys[k] = e.Key
+	}
+	return s, nil
+}
+
+// Snapshot returns the shared annotation for a form and year, loading it
+// on first use. Concurrent first calls wait for one load. The annotation
+// must not be modified; use Copy for a value to change. A load that fails
+// is not cached, so a later call tries again. An unknown form wraps
+// fs.ErrNotExist.
+func (s *AnnotationStore) Snapshot(ctx context.Context, formID string, year int) (*FormAnnotation, error) {
+	k := libraryKey{formID, year}
+	s.mu.RLock()
+	snap, ok := s.forms[k]
+	s.mu.RUnlock()
+	if !ok {
+		snap = s.load(ctx, k)
+	}
+	if snap == nil {
+		return nil, fmt.Errorf("annotation store: %s/%d: %w", formID, year, fs.ErrNotExist)
+	}
+	select {
+	case <-snap.done:
+		return snap.fa, snap.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load starts loading the form k unless another call has, returning its
+// snapshot, or nil when the store has no such form.
+func (s *AnnotationStore) load(ctx context.Context, k libraryKey) *storeSnapshot {
+	s.mu.Lock()
+	if snap, ok := s.forms[k]; ok {
+		s.mu.Unlock()
+		return snap
+	}
+	key, known := s.keys[k]
+	if !known {
+		s.mu.Unlock()
+		return nil
+	}
+	snap := &storeSnapshot{done: make(chan struct{})}
+	s.forms[k] = snap
+	s.mu.Unlock()
+	snap.fa, snap.err = LoadFromStore(ctx, s.store, key, s.opts)
+	if snap.err != nil {
+		s.mu.Lock()
+		if s.forms[k] == snap {
+			delete(s.forms, k)
+		}
+		s.mu.Unlock()
+	}
+	close(snap.done)
+	return snap
+}
+
+// Copy returns a deep copy of a form's snapshot, which the caller owns.
+func (s *AnnotationStore) Copy(ctx context.Context, formID string, year int) (*FormAnnotation, error) {
+	fa, err := s.Snapshot(ctx, formID, year)
+	if err != nil {
+		return nil, err
+	}
+	return fa.Clone(), nil
+}
+
+// Put saves fa to the store, under the key its form and year were loaded
+// from or, for a new form, "<prefix>/<form_id>/<year>.json", and makes a
+// copy of it the snapshot later calls see. fa itself stays the caller's.
+// Puts are serialized, and calls that need a form not yet loaded wait for
+// the write.
+func (s *AnnotationStore) Put(ctx context.Context, fa *FormAnnotation) error {
+	k := libraryKey{fa.FormMetadata.FormID, fa.FormMetadata.Year}
+	if k.formID == "" {
+		return fmt.Errorf("annotation store: the annotation has no form ID")
+	}
+	snap := &storeSnapshot{done: make(chan struct{}), fa: fa.Clone()}
+	close(snap.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[k]
+	if !ok {
+		key = path.Join(s.prefix, k.formID, strconv.Itoa(k.year)+".json")
+	}
+	if err := SaveToStore(ctx, s.store, key, snap.fa); err != nil {
+		return err
+	}
+	s.keys[k] = key
+	s.forms[k] = snap
+	return nil
+}
+
+// Invalidate drops the cached snapshot of a form, so the next call
+// reloads it from the store, such as after another process wrote it.
+func (s *AnnotationStore) Invalidate(formID string, year int) {
+	s.mu.Lock()
+	delete(s.forms, libraryKey{formID, year})
+	s.mu.Unlock()
+}
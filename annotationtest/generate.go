@@ -0,0 +1,183 @@
+// Package annotationtest helps test code that consumes annotations: it
+// generates random annotations that pass validation, for fuzzing and
+// property tests, and checks that annotations survive serialization and
+// match golden files.
+package annotationtest
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// Options controls GenerateRandom.
+type Options struct {
+	// MaxPages is the most pages generated; 3 when zero.
+	MaxPages int
+	// MaxFields is the most fields on a page, up to 60; 12 when zero.
+	MaxFields int
+	// FieldTypes are the types fields are drawn from; text, currency,
+	// numeric, checkbox, date, segmented and signature when empty.
+	FieldTypes []annotation.FieldType
+	// Groups adds radio groups of checkboxes with declared options.
+	Groups bool
+	// Values fills fields with values of their data types, checking one
+	// member of each radio group.
+	Values bool
+}
+
+var defaultFieldTypes = []annotation.FieldType{
+	annotation.FieldTypeText, annotation.FieldTypeCurrency, annotation.FieldTypeNumeric,
+	annotation.FieldTypeCheckbox, annotation.FieldTypeDate, annotation.FieldTypeSegmented,
+	annotation.FieldTypeSignature,
+}
+
+// Page layout, in points on a US Letter page: two columns of rows.
+const (
+	pageMargin  = 36.0
+	rowHeight   = 24.0
+	columnWidth = 252.0
+	maxFields   = 60
+)
+
+// GenerateRandom returns an annotation built from seed alone, so a failing
+// seed reproduces the failure. Fields of the chosen types are laid out in
+// rows, clear of each other and on the page, with unique IDs, value paths
+// and line references, so the result has no validation errors. It panics
+// if it generates one that does, which is a bug in the generator.
+func GenerateRandom(seed int64, opts Options) *annotation.FormAnnotation {
+	if opts.MaxPages <= 0 {
+		opts.MaxPages = 3
+	}
+	if opts.MaxFields <= 0 {
+		opts.MaxFields = 12
+	}
+	opts.MaxFields = min(opts.MaxFields, maxFields)
+	types := opts.FieldTypes
+	if len(types) == 0 {
+		types = defaultFieldTypes
+	}
+	rng := rand.New(rand.NewPCG(uint64(seed), 0x616e6e6f))
+	fa := &annotation.FormAnnotation{FormMetadata: annotation.FormMetadata{
+		FormID:   "gen-" + strconv.FormatUint(uint64(seed), 36),
+		FormName: fmt.Sprintf("Generated form %d", seed),
+		Year:     2000 + rng.IntN(30),
+		PageSize: annotation.PageSize{Width: 612, Height: 792, Unit: "pt"},
+	}}
+	line := 0
+	for p := 1; p <= 1+rng.IntN(opts.MaxPages); p++ {
+		page := annotation.Page{PageNumber: p, Fields: []annotation.Field{}}
+		// slot hands out the next free cell, left column then right.
+		n := 0
+		slot := func() (x, y float64) {
+			x, y = pageMargin+float64(n%2)*columnWidth, pageMargin+float64(n/2)*rowHeight
+			n++
+			return x, y
+		}
+		count := 1 + rng.IntN(opts.MaxFields)
+		for f := 0; f < count; f++ {
+			line++
+			x, y := slot()
+			field := randomField(rng, types[rng.IntN(len(types))], x, y)
+			field.FieldID = fmt.Sprintf("p%d_f%d", p, f+1)
+			field.FieldValue = fmt.Sprintf("page%d.field%d", p, f+1)
+			field.IRSLineRef = "line " + strconv.Itoa(line)
+			field.Label = fmt.Sprintf("Line %d", line)
+			if opts.Values {
+				field.Value = randomValue(rng, &field)
+			}
+			page.Fields = append(page.Fields, field)
+		}
+		if opts.Groups && n < opts.MaxFields && rng.IntN(2) == 0 {
+			groupID := fmt.Sprintf("p%d_choice", p)
+			group := annotation.FieldGroup{GroupID: groupID, GroupType: annotation.GroupTypeRadio}
+			checked := -1
+			if opts.Values {
+				checked = rng.IntN(3)
+			}
+			for i, code := range []string{"yes", "no", "unsure"}[:2+rng.IntN(2)] {
+				x, y := slot()
+				id := fmt.Sprintf("%s_%s", groupID, code)
+				page.Fields = append(page.Fields, annotation.Field{
+					FieldID:    id,
+					FieldType:  annotation.FieldTypeCheckbox,
+					DataType:   annotation.DataTypeBoolean,
+					Position:   annotation.Position{X: x, Y: y + 4, Width: 10, Height: 10, Unit: "pt"},
+					GroupID:    groupID,
+					FieldValue: fmt.Sprintf("page%d.choice", p),
+					OptionCode: code,
+				})
+				if i == checked {
+					page.Fields[len(page.Fields)-1].Value = "true"
+				}
+				group.FieldIDs = append(group.FieldIDs, id)
+				group.ExpectedOptions = append(group.ExpectedOptions, code)
+			}
+			fa.FieldGroups = append(fa.FieldGroups, group)
+		}
+		fa.Pages = append(fa.Pages, page)
+	}
+	fa.FormMetadata.PageCount = len(fa.Pages)
+	for _, issue := range fa.Validate() {
+		if issue.Severity == annotation.SeverityError {
+			panic(fmt.Sprintf("annotationtest: seed %d generated an invalid annotation: %v", seed, issue))
+		}
+	}
+	return fa
+}
+
+// randomField returns a field of type t in the row cell at x, y.
+func randomField(rng *rand.Rand, t annotation.FieldType, x, y float64) annotation.Field {
+	f := annotation.Field{FieldType: t}
+	width := float64(40 + rng.IntN(160))
+	f.Position = annotation.Position{X: x, Y: y, Width: width, Height: 18, Unit: "pt"}
+	switch t {
+	case annotation.FieldTypeCurrency:
+		f.DataType = annotation.DataTypeDecimal
+	case annotation.FieldTypeNumeric:
+		f.DataType = annotation.DataTypeInteger
+	case annotation.FieldTypeCheckbox:
+		f.DataType = annotation.DataTypeBoolean
+		f.Position = annotation.Position{X: x, Y: y + 4, Width: 10, Height: 10, Unit: "pt"}
+	case annotation.FieldTypeDate:
+		f.DataType = annotation.DataTypeDate
+	case annotation.FieldTypeSegmented:
+		f.DataType = annotation.DataTypeString
+		lengths := [][]int{{3, 2, 4}, {2, 7}, {5}}[rng.IntN(3)]
+		f.Segments = annotation.BuildSegments(annotation.Position{X: x, Y: y, Height: 18, Unit: "pt"}, 12, 6, lengths)
+		f.Position = annotation.Position{}
+	case annotation.FieldTypeVirtual:
+		f.DataType = annotation.DataTypeString
+		f.Position = annotation.Position{}
+	default:
+		f.DataType = annotation.DataTypeString
+	}
+	return f
+}
+
+// randomValue returns a value of f's data type, fitting its segments.
+func randomValue(rng *rand.Rand, f *annotation.Field) string {
+	switch f.DataType {
+	case annotation.DataTypeDecimal:
+		return fmt.Sprintf("%d.%02d", rng.IntN(100000), rng.IntN(100))
+	case annotation.DataTypeInteger:
+		return strconv.Itoa(rng.IntN(1000))
+	case annotation.DataTypeBoolean:
+		return strconv.FormatBool(rng.IntN(2) == 0)
+	case annotation.DataTypeDate:
+		return fmt.Sprintf("%04d-%02d-%02d", 1950+rng.IntN(70), 1+rng.IntN(12), 1+rng.IntN(28))
+	}
+	if len(f.Segments) > 0 {
+		digits := make([]byte, 0, 9)
+		for _, s := range f.Segments {
+			for range s.Length {
+				digits = append(digits, byte('0'+rng.IntN(10)))
+			}
+		}
+		return string(digits)
+	}
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo"}
+	return words[rng.IntN(len(words))] + " " + strconv.Itoa(rng.IntN(100))
+}
@@ -0,0 +1,136 @@
+package annotationtest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// UpdateEnv names the environment variable that, set to any value, makes
+// AssertGolden write golden files instead of comparing against them.
+const UpdateEnv = "ANNOTATIONTEST_UPDATE"
+
+// AssertRoundTrip checks that fa reads back unchanged from each of its
+// serializations, JSON, YAML and binary, comparing what the original and
+// the copy read back save as JSON, and that its canonical form is stable:
+// saving canonically what was read from a canonical save gives the same
+// bytes.
+func AssertRoundTrip(t testing.TB, fa *annotation.FormAnnotation) {
+	t.Helper()
+	want, err := saved(fa)
+	if err != nil {
+		t.Fatalf("save JSON: %v", err)
+	}
+	check := func(format string, back *annotation.FormAnnotation) {
+		t.Helper()
+		got, err := saved(back)
+		if err != nil {
+			t.Fatalf("save JSON read back from %s: %v", format, err)
+		}
+		if !bytes.Equal(want, got) {
+			t.Errorf("%s round trip changed the annotation: %s", format, firstDifference(want, got))
+		}
+	}
+
+	back, err := annotation.Load(bytes.NewReader(want), annotation.LoadOptions{})
+	if err != nil {
+		t.Fatalf("load JSON: %v", err)
+	}
+	check("JSON", back)
+
+	yaml, err := fa.ToYAML()
+	if err != nil {
+		t.Fatalf("save YAML: %v", err)
+	}
+	if back, err = annotation.FromYAML(yaml); err != nil {
+		t.Fatalf("load YAML: %v", err)
+	}
+	check("YAML", back)
+
+	bin, err := fa.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal binary: %v", err)
+	}
+	back = &annotation.FormAnnotation{}
+	if err := back.UnmarshalBinary(bin); err != nil {
+		t.Fatalf("unmarshal binary: %v", err)
+	}
+	check("binary", back)
+
+	first, err := canonical(fa)
+	if err != nil {
+		t.Fatalf("save canonical: %v", err)
+	}
+	if back, err = annotation.Load(bytes.NewReader(first), annotation.LoadOptions{}); err != nil {
+		t.Fatalf("load canonical: %v", err)
+	}
+	second, err := canonical(back)
+	if err != nil {
+		t.Fatalf("save canonical: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("canonical form is not stable: %s", firstDifference(first, second))
+	}
+}
+
+// AssertGolden compares fa's canonical JSON with the golden file at path,
+// reporting the first line that differs. With UpdateEnv set it writes the
+// file instead, creating its directory.
+func AssertGolden(t testing.TB, path string, fa *annotation.FormAnnotation) {
+	t.Helper()
+	got, err := canonical(fa)
+	if err != nil {
+		t.Fatalf("save canonical: %v", err)
+	}
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v (set %s=1 to create it)", err, UpdateEnv)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs: %s", path, firstDifference(want, got))
+	}
+}
+
+func saved(fa *annotation.FormAnnotation) ([]byte, error) {
+	var buf bytes.Buffer
+	err := fa.Save(&buf)
+	return buf.Bytes(), err
+}
+
+func canonical(fa *annotation.FormAnnotation) ([]byte, error) {
+	var buf bytes.Buffer
+	err := fa.SaveCanonical(&buf, annotation.NormalizeOptions{})
+	return buf.Bytes(), err
+}
+
+// firstDifference describes the first line where want and got differ.
+func firstDifference(want, got []byte) string {
+	wl, gl := strings.Split(string(want), "\n"), strings.Split(string(got), "\n")
+	for i := range max(len(wl), len(gl)) {
+		var w, g string
+		if i < len(wl) {
+			w = wl[i]
+		}
+		if i < len(gl) {
+			g = gl[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d: want %q, got %q", i+1, w, g)
+		}
+	}
+	return "no line differs"
+}
@@ -0,0 +1,335 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Barcode symbologies. Code 128 is encoded by the package; the others need
+// an encoder registered with RegisterBarcodeEncoder before they are drawn.
+const (
+	SymbologyCode128    = "code128"
+	SymbologyCode39     = "code39"
+	SymbologyPDF417     = "pdf417"
+	SymbologyQR         = "qr"
+	SymbologyDataMatrix = "datamatrix"
+)
+
+// Image fit modes.
+const (
+	// ImageFitContain scales the image to fit inside the field, keeping its
+	// aspect ratio, and centers it. It is the default.
+	ImageFitContain = "contain"
+	// ImageFitCover scales the image to cover the field, keeping its aspect
+	// ratio, and clips what falls outside.
+	ImageFitCover = "cover"
+	// ImageFitFill stretches the image to the field.
+	ImageFitFill = "fill"
+	// ImageFitNone draws the image at 72 pixels per inch, centered and
+	// clipped to the field.
+	ImageFitNone = "none"
+)
+
+// BarcodeSpec describes what a barcode field encodes and how.
+type BarcodeSpec struct {
+	Symbology string `json:"symbology"`
+	// Template builds the encoded data from other fields: each {field_id}
+	// is replaced by that field's value, and {{ and }} stand for braces.
+	// The field's own value is encoded when there is no template.
+	Template string `json:"template,omitempty"`
+}
+
+// ImageSpec describes how an image field's picture is placed in its box.
+type ImageSpec struct {
+	// Fit is one of the ImageFit modes; ImageFitContain when empty.
+	Fit string `json:"fit,omitempty"`
+}
+
+// Media field validation issue codes.
+const (
+	InvalidBarcode = "invalid_barcode"
+	InvalidImage   = "invalid_image"
+)
+
+// BarcodeEncoder turns data into the modules of a symbol, a row of dark
+// (true) and light modules per line, quiet zone included. A linear
+// symbology returns a single row, drawn over the field's full height.
+type BarcodeEncoder func(data string) ([][]bool, error)
+
+var (
+	barcodeMu       sync.RWMutex
+	barcodeEncoders = map[string]BarcodeEncoder{SymbologyCode128: encodeCode128}
+)
+
+// RegisterBarcodeEncoder makes enc draw barcodes of symbology. It panics if
+// symbology is empty or already has an encoder.
+func RegisterBarcodeEncoder(symbology string, enc BarcodeEncoder) {
+	barcodeMu.Lock()
+	defer barcodeMu.Unlock()
+	if symbology == "" || enc == nil {
+		panic("annotation: RegisterBarcodeEncoder needs a symbology and an encoder")
+	}
+	if _, dup := barcodeEncoders[symbology]; dup {
+		panic("annotation: barcode symbology " + symbology + " already has an encoder")
+	}
+	barcodeEncoders[symbology] = enc
+}
+
+// EncodeBarcode encodes data in symbology with its registered encoder.
+func EncodeBarcode(symbology, data string) ([][]bool, error) {
+	barcodeMu.RLock()
+	enc, ok := barcodeEncoders[symbology]
+	barcodeMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no encoder for barcode symbology %q", symbology)
+	}
+	return enc(data)
+}
+
+// BarcodeData returns the data a barcode field encodes: its template filled
+// in with the current values, or its own value when it has no template.
+func (fa *FormAnnotation) BarcodeData(fieldID string) (string, error) {
+	field := fa.GetFieldByID(fieldID)
+	if field == nil {
+		return "", fmt.Errorf("no field with ID %q", fieldID)
+	}
+	return fa.barcodeData(field)
+}
+
+func (fa *FormAnnotation) barcodeData(field *Field) (string, error) {
+	if field.Barcode == nil || field.Barcode.Template == "" {
+		return field.Value, nil
+	}
+	var sb strings.Builder
+	err := expandTemplate(field.Barcode.Template, func(id string) error {
+		ref := fa.GetFieldByID(id)
+		if ref == nil {
+			return fmt.Errorf("barcode template references unknown field %q", id)
+		}
+		sb.WriteString(ref.Value)
+		return nil
+	}, func(s string) { sb.WriteString(s) })
+	return sb.String(), err
+}
+
+// expandTemplate walks a barcode template, calling ref for each {field_id}
+// and text for the literal text between them.
+func expandTemplate(tmpl string, ref func(id string) error, text func(string)) error {
+	for tmpl != "" {
+		i := strings.IndexAny(tmpl, "{}")
+		if i < 0 {
+			text(tmpl)
+			return nil
+		}
+		text(tmpl[:i])
+		switch {
+		case strings.HasPrefix(tmpl[i:], "{{"):
+			text("{")
+			tmpl = tmpl[i+2:]
+		case strings.HasPrefix(tmpl[i:], "}}"):
+			text("}")
+			tmpl = tmpl[i+2:]
+		case tmpl[i] == '}':
+			return errors.New("barcode template has an unmatched }")
+		default:
+			end := strings.IndexByte(tmpl[i:], '}')
+			if end < 0 {
+				return errors.New("barcode template has an unclosed {")
+			}
+			id := strings.TrimSpace(tmpl[i+1 : i+end])
+			if id == "" {
+				return errors.New("barcode template has an empty {}")
+			}
+			if err := ref(id); err != nil {
+				return err
+			}
+			tmpl = tmpl[i+end+1:]
+		}
+	}
+	return nil
+}
+
+// rewriteTemplateRefs returns tmpl with each {field_id} replaced by
+// {fn(field_id)}, or dropped when fn returns "". A template that does not
+// parse, or in which nothing changes, is returned as it is.
+func rewriteTemplateRefs(tmpl string, fn func(id string) string) string {
+	var sb strings.Builder
+	changed := false
+	err := expandTemplate(tmpl, func(id string) error {
+		to := fn(id)
+		changed = changed || to != id
+		if to != "" {
+			sb.WriteString("{" + to + "}")
+		}
+		return nil
+	}, func(s string) {
+		sb.WriteString(strings.NewReplacer("{", "{{", "}", "}}").Replace(s))
+	})
+	if err != nil || !changed {
+		return tmpl
+	}
+	return sb.String()
+}
+
+// ParseImageData decodes an image field's value, a data URI of a PNG or
+// JPEG image such as "data:image/png;base64,iVBOR...", returning its media
+// type and bytes.
+func ParseImageData(value string) (mediaType string, data []byte, err error) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(value), "data:")
+	if !ok {
+		return "", nil, errors.New("image value is not a data URI")
+	}
+	meta, payload, ok := strings.Cut(rest, ",")
+	mediaType, encoding, _ := strings.Cut(meta, ";")
+	if !ok || encoding != "base64" {
+		return "", nil, errors.New("image data URI is not base64 encoded")
+	}
+	mediaType = strings.ToLower(mediaType)
+	if mediaType != "image/png" && mediaType != "image/jpeg" {
+		return "", nil, fmt.Errorf("image type %q is not image/png or image/jpeg", mediaType)
+	}
+	data, err = base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("image data: %w", err)
+	}
+	magic := map[string][]byte{"image/png": []byte("\x89PNG\r\n\x1a\n"), "image/jpeg": {0xff, 0xd8, 0xff}}
+	if !bytes.HasPrefix(data, magic[mediaType]) {
+		return "", nil, fmt.Errorf("image data is not %s", mediaType)
+	}
+	return mediaType, data, nil
+}
+
+// checkMediaFields reports barcode fields without a known symbology or with
+// a template that does not parse or names an unknown field, image fields
+// with an unknown fit, and barcode or image settings on other field types.
+func (fa *FormAnnotation) checkMediaFields() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			f := &page.Fields[i]
+			at := ValidationIssue{Code: InvalidBarcode, Severity: SeverityError, FieldID: f.FieldID, Page: page.PageNumber}
+			switch {
+			case f.FieldType != FieldTypeBarcode && f.Barcode != nil:
+				at.Message = fmt.Sprintf("barcode settings on a %s field", f.FieldType)
+			case f.FieldType != FieldTypeBarcode:
+			case f.Barcode == nil || f.Barcode.Symbology == "":
+				at.Message = "barcode field has no symbology"
+			case !knownSymbology(f.Barcode.Symbology):
+				at.Message = fmt.Sprintf("barcode symbology %q is not known", f.Barcode.Symbology)
+			default:
+				err := expandTemplate(f.Barcode.Template, func(id string) error {
+					if fa.GetFieldByID(id) == nil {
+						return fmt.Errorf("barcode template references unknown field %q", id)
+					}
+					return nil
+				}, func(string) {})
+				if err != nil {
+					at.Message = err.Error()
+				}
+			}
+			if at.Message != "" {
+				issues = append(issues, at)
+			}
+			at.Code, at.Message = InvalidImage, ""
+			switch {
+			case f.FieldType != FieldTypeImage && f.Image != nil:
+				at.Message = fmt.Sprintf("image settings on a %s field", f.FieldType)
+			case f.Image != nil && !knownFit(f.Image.Fit):
+				at.Message = fmt.Sprintf("image fit %q is not contain, cover, fill or none", f.Image.Fit)
+			}
+			if at.Message != "" {
+				issues = append(issues, at)
+			}
+		}
+	}
+	return issues
+}
+
+// knownSymbology reports whether symbology is one of the Symbology
+// constants or has a registered encoder.
+func knownSymbology(symbology string) bool {
+	switch symbology {
+	case SymbologyCode128, SymbologyCode39, SymbologyPDF417, SymbologyQR, SymbologyDataMatrix:
+		return true
+	}
+	barcodeMu.RLock()
+	defer barcodeMu.RUnlock()
+	_, ok := barcodeEncoders[symbology]
+	return ok
+}
+
+func knownFit(fit string) bool {
+	switch fit {
+	case "", ImageFitContain, ImageFitCover, ImageFitFill, ImageFitNone:
+		return true
+	}
+	return false
+}
+
+// code128Patterns are the bar and space widths of the Code 128 symbols,
+// indexed by symbol value; 106 is the stop pattern with its final bar.
+var code128Patterns = [...]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+	// code128Quiet is the quiet zone on either side, in modules.
+	code128Quiet = 10
+)
+
+// encodeCode128 encodes printable ASCII in code set B, or an even number of
+// digits, four or more, in the denser code set C.
+func encodeCode128(data string) ([][]bool, error) {
+	if data == "" {
+		return nil, errors.New("code 128: no data")
+	}
+	var values []int
+	if len(data) >= 4 && len(data)%2 == 0 && strings.Trim(data, "0123456789") == "" {
+		values = append(values, code128StartC)
+		for i := 0; i < len(data); i += 2 {
+			values = append(values, int(data[i]-'0')*10+int(data[i+1]-'0'))
+		}
+	} else {
+		values = append(values, code128StartB)
+		for i := 0; i < len(data); i++ {
+			c := data[i]
+			if c < ' ' || c > '~' {
+				return nil, fmt.Errorf("code 128: %q is not printable ASCII", data)
+			}
+			values = append(values, int(c-' '))
+		}
+	}
+	sum := values[0]
+	for i, v := range values[1:] {
+		sum += (i + 1) * v
+	}
+	values = append(values, sum%103, code128Stop)
+
+	row := make([]bool, code128Quiet)
+	for _, v := range values {
+		for i, w := range code128Patterns[v] {
+			for range w - '0' {
+				row = append(row, i%2 == 0)
+			}
+		}
+	}
+	row = append(row, make([]bool, code128Quiet)...)
+	return [][]bool{row}, nil
+}
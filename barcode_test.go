@@ -0,0 +1,68 @@
+package annotation
+
+import "testing"
+
+func barcodeForm(template string) *FormAnnotation {
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{
+			{FieldID: "ssn", FieldType: FieldTypeText, DataType: DataTypeString, Value: "123456789",
+				Position: Position{X: 36, Y: 36, Width: 120, Height: 18, Unit: "pt"}},
+			{FieldID: "sig_date", FieldType: FieldTypeDate, DataType: DataTypeDate, Value: "2024-04-15",
+				Position: Position{X: 36, Y: 72, Width: 120, Height: 18, Unit: "pt"}},
+			{FieldID: "code", FieldType: FieldTypeBarcode, DataType: DataTypeString,
+				Position: Position{X: 36, Y: 108, Width: 240, Height: 48, Unit: "pt"},
+				Barcode:  &BarcodeSpec{Symbology: SymbologyCode128, Template: template}},
+		}}},
+	}
+}
+
+func barcodeIssues(fa *FormAnnotation) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, issue := range fa.Validate() {
+		if issue.Code == InvalidBarcode {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func TestRenameFieldRewritesBarcodeTemplate(t *testing.T) {
+	fa := barcodeForm("{{{ssn}}}-{sig_date}")
+	if err := fa.RenameField("ssn", "taxpayer_ssn"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fa.GetFieldByID("code").Barcode.Template, "{{{taxpayer_ssn}}}-{sig_date}"; got != want {
+		t.Errorf("template = %q, want %q", got, want)
+	}
+	if data, err := fa.BarcodeData("code"); err != nil || data != "{123456789}-2024-04-15" {
+		t.Errorf("data = %q, %v", data, err)
+	}
+	if issues := barcodeIssues(fa); len(issues) != 0 {
+		t.Errorf("issues after rename: %v", issues)
+	}
+}
+
+func TestRemoveFieldDropsBarcodePlaceholder(t *testing.T) {
+	fa := barcodeForm("{ssn}-{sig_date}")
+	if err := fa.RemoveField("sig_date"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fa.GetFieldByID("code").Barcode.Template, "{ssn}-"; got != want {
+		t.Errorf("template = %q, want %q", got, want)
+	}
+	if issues := barcodeIssues(fa); len(issues) != 0 {
+		t.Errorf("issues after removal: %v", issues)
+	}
+}
+
+func TestRewriteTemplateRefsKeepsUnchangedTemplate(t *testing.T) {
+	tmpl := "{ ssn }|{sig_date"
+	if got := rewriteTemplateRefs(tmpl, func(id string) string { return id + "x" }); got != tmpl {
+		t.Errorf("unparsable template rewritten to %q", got)
+	}
+	tmpl = "{ ssn }-{sig_date}"
+	if got := rewriteTemplateRefs(tmpl, func(id string) string { return id }); got != tmpl {
+		t.Errorf("unchanged template rewritten to %q", got)
+	}
+}
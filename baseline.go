@@ -0,0 +1,230 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// RenderBaseline is a recorded stamp plan, kept in a repository to detect
+// rendering changes across upgrades of this package. Positions are in
+// points and items are ordered by page and field ID, so the JSON is stable.
+type RenderBaseline struct {
+	FormID string       `json:"form_id"`
+	Year   int          `json:"year"`
+	Target RenderTarget `json:"target,omitempty"`
+	Items  []StampItem  `json:"items"`
+}
+
+// CaptureBaseline fills a copy of fa from data and records the stamp plan
+// it produces. A fill or plan reporting errors is refused, since its
+// baseline would leave fields out.
+func CaptureBaseline(fa *FormAnnotation, data map[string]any, opts FillOptions) (*RenderBaseline, error) {
+	filled := fa.Clone()
+	if err := reportError(filled.FillFromData(data, opts)); err != nil {
+		return nil, err
+	}
+	plan, report := filled.BuildStampPlan(opts)
+	if err := reportError(report); err != nil {
+		return nil, err
+	}
+	b := &RenderBaseline{FormID: fa.FormMetadata.FormID, Year: fa.FormMetadata.Year, Target: opts.Target}
+	unit := fa.FormMetadata.PageSize.Unit
+	for _, item := range plan.Items {
+		var ok bool
+		if item.Position, ok = positionInPoints(item.Position, unit); !ok {
+			return nil, fmt.Errorf("field %q: unknown unit %q", item.FieldID, item.Position.Unit)
+		}
+		item.Cells = append([]StampCell(nil), item.Cells...)
+		for i := range item.Cells {
+			if item.Cells[i].Position, ok = positionInPoints(item.Cells[i].Position, unit); !ok {
+				return nil, fmt.Errorf("field %q: unknown unit %q", item.FieldID, item.Cells[i].Position.Unit)
+			}
+		}
+		b.Items = append(b.Items, item)
+	}
+	b.sort()
+	return b, nil
+}
+
+func reportError(report *FillReport) error {
+	for _, issue := range report.Issues {
+		if issue.Severity == SeverityError {
+			return fmt.Errorf("field %q: %s", issue.FieldID, issue.Message)
+		}
+	}
+	return nil
+}
+
+func (b *RenderBaseline) sort() {
+	sort.SliceStable(b.Items, func(i, j int) bool {
+		if b.Items[i].Page != b.Items[j].Page {
+			return b.Items[i].Page < b.Items[j].Page
+		}
+		return b.Items[i].FieldID < b.Items[j].FieldID
+	})
+}
+
+// ToJSON returns the baseline as indented JSON ending in a newline.
+func (b *RenderBaseline) ToJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// ParseBaseline decodes a baseline written by ToJSON.
+func ParseBaseline(data []byte) (*RenderBaseline, error) {
+	var b RenderBaseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("render baseline: %w", err)
+	}
+	return &b, nil
+}
+
+// Accept copies the current rendering of the named fields into the
+// baseline, recording an intended change without regenerating the rest.
+// With no field IDs, the whole form is accepted.
+func (b *RenderBaseline) Accept(current *RenderBaseline, fieldIDs ...string) {
+	if len(fieldIDs) == 0 {
+		*b = *current
+		b.Items = append([]StampItem(nil), current.Items...)
+		return
+	}
+	accept := map[string]bool{}
+	for _, id := range fieldIDs {
+		accept[id] = true
+	}
+	var items []StampItem
+	for _, item := range b.Items {
+		if !accept[item.FieldID] {
+			items = append(items, item)
+		}
+	}
+	for _, item := range current.Items {
+		if accept[item.FieldID] {
+			items = append(items, item)
+		}
+	}
+	b.Items = items
+	b.sort()
+}
+
+// Kinds of render drift.
+const (
+	DriftMoved   = "moved"
+	DriftText    = "text_changed"
+	DriftStyle   = "style_changed"
+	DriftMissing = "missing"
+	DriftAdded   = "added"
+)
+
+// Drift tolerances, in points.
+const (
+	// DefaultDriftTolerance is movement small enough to ignore.
+	DefaultDriftTolerance = 0.5
+	// MajorDrift is movement reported as an error rather than a warning.
+	MajorDrift = 3.0
+)
+
+// RenderDrift is one difference between a baseline and a current rendering.
+type RenderDrift struct {
+	FieldID  string   `json:"field_id"`
+	Page     int      `json:"page"`
+	Kind     string   `json:"kind"`
+	Severity Severity `json:"severity"`
+	// Distance is the largest change to an edge, width or height, in points.
+	Distance float64 `json:"distance,omitempty"`
+	Message  string  `json:"message"`
+}
+
+// CompareToBaseline reports the fields of current that render differently
+// from baseline. Movement within DefaultDriftTolerance is ignored and
+// movement below MajorDrift is a warning. Changed text and fields no longer
+// drawn are errors; changed styles and newly drawn fields are warnings.
+func CompareToBaseline(baseline, current *RenderBaseline) []RenderDrift {
+	type key struct {
+		page int
+		id   string
+	}
+	now := map[key]StampItem{}
+	for _, item := range current.Items {
+		now[key{item.Page, item.FieldID}] = item
+	}
+	var drifts []RenderDrift
+	add := func(item StampItem, kind string, severity Severity, distance float64, format string, args ...any) {
+		drifts = append(drifts, RenderDrift{FieldID: item.FieldID, Page: item.Page, Kind: kind,
+			Severity: severity, Distance: distance, Message: fmt.Sprintf(format, args...)})
+	}
+	seen := map[key]bool{}
+	for _, was := range baseline.Items {
+		k := key{was.Page, was.FieldID}
+		seen[k] = true
+		is, ok := now[k]
+		if !ok {
+			add(was, DriftMissing, SeverityError, 0, "field is no longer drawn")
+			continue
+		}
+		if len(was.Cells) != len(is.Cells) {
+			add(was, DriftMoved, SeverityError, 0, "segment count changed from %d to %d", len(was.Cells), len(is.Cells))
+		} else if d := itemDistance(was, is); d > DefaultDriftTolerance {
+			severity := SeverityWarning
+			if d >= MajorDrift {
+				severity = SeverityError
+			}
+			add(was, DriftMoved, severity, d, "placement moved by %.2fpt", d)
+		}
+		if was.Text != is.Text || !sameCellText(was.Cells, is.Cells) {
+			add(was, DriftText, SeverityError, 0, "text changed from %q to %q", stampText(was), stampText(is))
+		}
+		if !reflect.DeepEqual(was.Style, is.Style) || !reflect.DeepEqual(was.Check, is.Check) {
+			add(was, DriftStyle, SeverityWarning, 0, "style changed")
+		}
+	}
+	for _, is := range current.Items {
+		if !seen[key{is.Page, is.FieldID}] {
+			add(is, DriftAdded, SeverityWarning, 0, "field is newly drawn")
+		}
+	}
+	return drifts
+}
+
+// itemDistance is the largest change to any edge or cell of two items with
+// the same number of cells.
+func itemDistance(a, b StampItem) float64 {
+	d := positionDistance(a.Position, b.Position)
+	for i := range a.Cells {
+		d = max(d, positionDistance(a.Cells[i].Position, b.Cells[i].Position))
+	}
+	return d
+}
+
+func positionDistance(a, b Position) float64 {
+	return max(math.Abs(a.X-b.X), math.Abs(a.Y-b.Y), math.Abs(a.Width-b.Width), math.Abs(a.Height-b.Height))
+}
+
+func sameCellText(a, b []StampCell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text {
+			return false
+		}
+	}
+	return true
+}
+
+func stampText(item StampItem) string {
+	if len(item.Cells) == 0 {
+		return item.Text
+	}
+	text := ""
+	for _, cell := range item.Cells {
+		text += cell.Text
+	}
+	return text
+}
@@ -0,0 +1,37 @@
+package annotation
+
+// BatchOptions controls a BatchFiller.
+type BatchOptions struct {
+	Fill     FillOptions
+	Progress ProgressFunc
+}
+
+// BatchFiller fills one template with many data records.
+type BatchFiller struct {
+	template *FormAnnotation
+	opts     BatchOptions
+}
+
+// BatchResult is one filled copy of the template and its fill report.
+type BatchResult struct {
+	Form   *FormAnnotation
+	Report *FillReport
+}
+
+// NewBatchFiller returns a filler for template. The template itself is never modified.
+func NewBatchFiller(template *FormAnnotation, opts BatchOptions) *BatchFiller {
+	return &BatchFiller{template: template, opts: opts}
+}
+
+// Run fills a fresh copy of the template from each record with FillFromData
+// and returns the results in record order. Progress is reported per record.
+func (b *BatchFiller) Run(records []map[string]any) []BatchResult {
+	results := make([]BatchResult, len(records))
+	b.opts.Progress.report(StageFill, 0, len(records))
+	for i, record := range records {
+		form := b.template.Clone()
+		results[i] = BatchResult{Form: form, Report: form.FillFromData(record, b.opts.Fill)}
+		b.opts.Progress.report(StageFill, i+1, len(records))
+	}
+	return results
+}
@@ -0,0 +1,480 @@
+package annotation
+
+import (
+	"cmp"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The binary form starts with magic bytes, a format version and a
+// fingerprint of the Go types it was written from, followed by the values
+// of the document's exported fields in declaration order.
+const (
+	binaryMagic      = "FABN"
+	binaryVersion    = 1
+	binaryHeaderSize = len(binaryMagic) + 1 + 8
+)
+
+// ErrNotBinary is wrapped by UnmarshalBinary errors for data that is not
+// in the binary form, such as a JSON document.
+var ErrNotBinary = errors.New("not a binary form annotation")
+
+// ErrBinarySchema is wrapped by UnmarshalBinary errors for data written by
+// a build of this package whose types differ, such as one adding a field.
+// A cache meeting it should re-encode from JSON.
+var ErrBinarySchema = errors.New("binary form annotation was written by a different schema")
+
+// MarshalBinary encodes the annotation in a compact binary form for caches
+// and traffic between services, which UnmarshalBinary decodes several
+// times faster than Load parses JSON. It holds what Save writes, stamped
+// the same way, and not load settings such as CaseInsensitiveIDs.
+//
+// The form is tied to the package's Go types: data written by a build
+// whose types differ is refused with ErrBinarySchema, so services sharing
+// it must run the same version, and JSON stays the format to store.
+func (fa *FormAnnotation) MarshalBinary() ([]byte, error) {
+	if err := FeatureGates(nil).checkEmit(fa); err != nil {
+		return nil, err
+	}
+	doc := fa.withReaderRequirements()
+	e := &binaryEncoder{buf: make([]byte, 0, 4096)}
+	e.buf = append(e.buf, binaryMagic...)
+	e.buf = append(e.buf, binaryVersion)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, binarySchema())
+	v := reflect.ValueOf(doc).Elem()
+	if err := binaryCodecFor(v.Type()).enc(e, v); err != nil {
+		return nil, fmt.Errorf("marshal binary: %w", err)
+	}
+	return e.buf, nil
+}
+
+// UnmarshalBinary replaces the annotation with the one data encodes, as
+// MarshalBinary writes it, keeping the receiver's CaseInsensitiveIDs and
+// ExprLimits. Like Load, it refuses a document needing a newer reader.
+func (fa *FormAnnotation) UnmarshalBinary(data []byte) error {
+	if len(data) < binaryHeaderSize || string(data[:len(binaryMagic)]) != binaryMagic {
+		return fmt.Errorf("unmarshal binary: %w", ErrNotBinary)
+	}
+	if v := data[len(binaryMagic)]; v != binaryVersion {
+		return fmt.Errorf("unmarshal binary: format version %d is not supported", v)
+	}
+	if binary.LittleEndian.Uint64(data[len(binaryMagic)+1:]) != binarySchema() {
+		return fmt.Errorf("unmarshal binary: %w", ErrBinarySchema)
+	}
+	var doc FormAnnotation
+	d := &binaryDecoder{buf: data[binaryHeaderSize:]}
+	v := reflect.ValueOf(&doc).Elem()
+	if err := binaryCodecFor(v.Type()).dec(d, v); err != nil {
+		return fmt.Errorf("unmarshal binary: %w", err)
+	}
+	if len(d.buf) > 0 {
+		return fmt.Errorf("unmarshal binary: %d bytes after the document", len(d.buf))
+	}
+	if err := doc.FormMetadata.checkReader(); err != nil {
+		return err
+	}
+	doc.CaseInsensitiveIDs, doc.ExprLimits = fa.CaseInsensitiveIDs, fa.ExprLimits
+	*fa = doc
+	return nil
+}
+
+// binaryCodec encodes and decodes the values of one Go type.
+type binaryCodec struct {
+	enc func(e *binaryEncoder, v reflect.Value) error
+	dec func(d *binaryDecoder, v reflect.Value) error
+}
+
+type binaryEncoder struct{ buf []byte }
+
+func (e *binaryEncoder) uvarint(n uint64) { e.buf = binary.AppendUvarint(e.buf, n) }
+
+func (e *binaryEncoder) bytes(b []byte) {
+	e.uvarint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+type binaryDecoder struct{ buf []byte }
+
+var errBinaryTruncated = errors.New("data ends early")
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	n, size := binary.Uvarint(d.buf)
+	if size <= 0 {
+		return 0, errBinaryTruncated
+	}
+	d.buf = d.buf[size:]
+	return n, nil
+}
+
+func (d *binaryDecoder) varint() (int64, error) {
+	n, size := binary.Varint(d.buf)
+	if size <= 0 {
+		return 0, errBinaryTruncated
+	}
+	d.buf = d.buf[size:]
+	return n, nil
+}
+
+// bytes returns the next length-prefixed bytes, aliasing the input.
+func (d *binaryDecoder) bytes() ([]byte, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)) {
+		return nil, errBinaryTruncated
+	}
+	b := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+// length reads a slice or map header: zero for nil, else the length plus
+// one. Each element takes at least a byte, which bounds the length.
+func (d *binaryDecoder) length() (n int, isNil bool, err error) {
+	h, err := d.uvarint()
+	if err != nil {
+		return 0, false, err
+	}
+	if h == 0 {
+		return 0, true, nil
+	}
+	if h-1 > uint64(len(d.buf)) {
+		return 0, false, errBinaryTruncated
+	}
+	return int(h - 1), false, nil
+}
+
+var (
+	binaryCodecs sync.Map // reflect.Type to *binaryCodec
+	binaryMu     sync.Mutex
+
+	binaryMarshaler   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshaler = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+)
+
+// binaryCodecFor returns the codec of t, building and caching it on first
+// use.
+func binaryCodecFor(t reflect.Type) *binaryCodec {
+	if c, ok := binaryCodecs.Load(t); ok {
+		return c.(*binaryCodec)
+	}
+	binaryMu.Lock()
+	defer binaryMu.Unlock()
+	return buildBinaryCodec(t, map[reflect.Type]*binaryCodec{})
+}
+
+// buildBinaryCodec builds the codec of t. A recursive type finds its own
+// codec in building while it is being built.
+func buildBinaryCodec(t reflect.Type, building map[reflect.Type]*binaryCodec) *binaryCodec {
+	if c, ok := binaryCodecs.Load(t); ok {
+		return c.(*binaryCodec)
+	}
+	if c, ok := building[t]; ok {
+		return c
+	}
+	c := &binaryCodec{}
+	building[t] = c
+	sub := func(t reflect.Type) *binaryCodec { return buildBinaryCodec(t, building) }
+
+	switch {
+	case selfEncoding(t):
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			b, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+			e.bytes(b)
+			return err
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			b, err := d.bytes()
+			if err != nil {
+				return err
+			}
+			return v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b)
+		}
+	case t.Kind() == reflect.Bool:
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			b := byte(0)
+			if v.Bool() {
+				b = 1
+			}
+			e.buf = append(e.buf, b)
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			if len(d.buf) == 0 {
+				return errBinaryTruncated
+			}
+			v.SetBool(d.buf[0] != 0)
+			d.buf = d.buf[1:]
+			return nil
+		}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			e.buf = binary.AppendVarint(e.buf, v.Int())
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			n, err := d.varint()
+			v.SetInt(n)
+			return err
+		}
+	case t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64:
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			e.uvarint(v.Uint())
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			n, err := d.uvarint()
+			v.SetUint(n)
+			return err
+		}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v.Float()))
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			if len(d.buf) < 8 {
+				return errBinaryTruncated
+			}
+			v.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(d.buf)))
+			d.buf = d.buf[8:]
+			return nil
+		}
+	case t.Kind() == reflect.String:
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			e.uvarint(uint64(v.Len()))
+			e.buf = append(e.buf, v.String()...)
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			b, err := d.bytes()
+			v.SetString(string(b))
+			return err
+		}
+	case t.Kind() == reflect.Pointer:
+		elem := sub(t.Elem())
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.buf = append(e.buf, 0)
+				return nil
+			}
+			e.buf = append(e.buf, 1)
+			return elem.enc(e, v.Elem())
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			if len(d.buf) == 0 {
+				return errBinaryTruncated
+			}
+			present := d.buf[0] != 0
+			d.buf = d.buf[1:]
+			if !present {
+				return nil
+			}
+			p := reflect.New(t.Elem())
+			v.Set(p)
+			return elem.dec(d, p.Elem())
+		}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.uvarint(0)
+				return nil
+			}
+			e.uvarint(uint64(v.Len()) + 1)
+			e.buf = append(e.buf, v.Bytes()...)
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			n, isNil, err := d.length()
+			if err != nil || isNil {
+				return err
+			}
+			b := reflect.MakeSlice(t, n, n)
+			copy(b.Bytes(), d.buf[:n])
+			d.buf = d.buf[n:]
+			v.Set(b)
+			return nil
+		}
+	case t.Kind() == reflect.Slice:
+		elem := sub(t.Elem())
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.uvarint(0)
+				return nil
+			}
+			e.uvarint(uint64(v.Len()) + 1)
+			for i := range v.Len() {
+				if err := elem.enc(e, v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			n, isNil, err := d.length()
+			if err != nil || isNil {
+				return err
+			}
+			s := reflect.MakeSlice(t, n, n)
+			for i := range n {
+				if err := elem.dec(d, s.Index(i)); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		}
+	case t.Kind() == reflect.Map:
+		key, elem := sub(t.Key()), sub(t.Elem())
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			if v.IsNil() {
+				e.uvarint(0)
+				return nil
+			}
+			e.uvarint(uint64(v.Len()) + 1)
+			// Sorted keys give equal annotations equal bytes.
+			keys := v.MapKeys()
+			slices.SortFunc(keys, compareBinaryKeys)
+			for _, k := range keys {
+				if err := key.enc(e, k); err != nil {
+					return err
+				}
+				if err := elem.enc(e, v.MapIndex(k)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			n, isNil, err := d.length()
+			if err != nil || isNil {
+				return err
+			}
+			m := reflect.MakeMapWithSize(t, n)
+			k, x := reflect.New(t.Key()).Elem(), reflect.New(t.Elem()).Elem()
+			for range n {
+				k.SetZero()
+				x.SetZero()
+				if err := key.dec(d, k); err != nil {
+					return err
+				}
+				if err := elem.dec(d, x); err != nil {
+					return err
+				}
+				m.SetMapIndex(k, x)
+			}
+			v.Set(m)
+			return nil
+		}
+	case t.Kind() == reflect.Struct:
+		type field struct {
+			index int
+			codec *binaryCodec
+		}
+		var fields []field
+		for i := range t.NumField() {
+			if f := t.Field(i); binaryField(f) {
+				fields = append(fields, field{i, sub(f.Type)})
+			}
+		}
+		c.enc = func(e *binaryEncoder, v reflect.Value) error {
+			for _, f := range fields {
+				if err := f.codec.enc(e, v.Field(f.index)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		c.dec = func(d *binaryDecoder, v reflect.Value) error {
+			for _, f := range fields {
+				if err := f.codec.dec(d, v.Field(f.index)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		err := fmt.Errorf("cannot encode %s", t)
+		c.enc = func(*binaryEncoder, reflect.Value) error { return err }
+		c.dec = func(*binaryDecoder, reflect.Value) error { return err }
+	}
+	delete(building, t)
+	binaryCodecs.Store(t, c)
+	return c
+}
+
+// selfEncoding reports whether t, such as time.Time, encodes itself.
+func selfEncoding(t reflect.Type) bool {
+	return t != reflect.TypeFor[FormAnnotation]() && t.Implements(binaryMarshaler) && reflect.PointerTo(t).Implements(binaryUnmarshaler)
+}
+
+// binaryField reports whether a struct field is encoded: exported and not
+// left out of the JSON form.
+func binaryField(f reflect.StructField) bool {
+	return f.IsExported() && f.Tag.Get("json") != "-"
+}
+
+// compareBinaryKeys orders map keys of string, integer or float kinds.
+func compareBinaryKeys(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.String:
+		return strings.Compare(a.String(), b.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	}
+	return 0
+}
+
+// binarySchema is the fingerprint of the types the binary form is written
+// from: their names and kinds and their encoded fields, in order.
+var binarySchema = sync.OnceValue(func() uint64 {
+	var b strings.Builder
+	seen := map[reflect.Type]bool{}
+	var describe func(t reflect.Type)
+	describe = func(t reflect.Type) {
+		fmt.Fprintf(&b, "%s:%s", t, t.Kind())
+		if seen[t] || selfEncoding(t) {
+			return
+		}
+		seen[t] = true
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice:
+			b.WriteByte('(')
+			describe(t.Elem())
+			b.WriteByte(')')
+		case reflect.Map:
+			b.WriteByte('(')
+			describe(t.Key())
+			b.WriteByte(',')
+			describe(t.Elem())
+			b.WriteByte(')')
+		case reflect.Struct:
+			b.WriteByte('{')
+			for i := range t.NumField() {
+				if f := t.Field(i); binaryField(f) {
+					fmt.Fprintf(&b, "%s ", f.Name)
+					describe(f.Type)
+					b.WriteByte(';')
+				}
+			}
+			b.WriteByte('}')
+		}
+	}
+	describe(reflect.TypeFor[FormAnnotation]())
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	return h.Sum64()
+})
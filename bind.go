@@ -0,0 +1,184 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bind fills fields from the exported fields of a struct, or a pointer to
+// one, each tagged with the ID of the field it fills:
+//
+//	type Income struct {
+//		Wages    float64  `form:"wages_line1a"`
+//		Interest *big.Rat `form:"interest_line2b"`
+//		Status   string   `form:"filing_status"`
+//		Signed   Date     `form:"signature_date,omitempty"`
+//	}
+//
+// Each value is stored in the canonical form of the field's data type, as
+// SetTypedValue does, so dates may be Date, time.Time or a string in the
+// field's date format, and then runs through the field's transforms. A tag
+// naming an option group takes the option code to check, and one naming a
+// yes/no group a bool. Untagged struct fields that hold structs are bound
+// recursively; other untagged fields, fields tagged "-", nil pointers and,
+// under omitempty, zero values are skipped. Values that cannot be stored
+// are reported as fill issues.
+//
+// v may instead be a map from field and group IDs to values, such as a
+// map[string]any, bound entry by entry in key order; nil entries are
+// skipped. The error is for v being neither a struct nor such a map.
+func (fa *FormAnnotation) Bind(v any, opts FillOptions) (*FillReport, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	report := &FillReport{}
+	index := fa.BuildIndex()
+	switch {
+	case rv.Kind() == reflect.Struct:
+		fa.bindStruct(rv, index, opts, report)
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		keys := rv.MapKeys()
+		slices.SortFunc(keys, func(a, b reflect.Value) int { return strings.Compare(a.String(), b.String()) })
+		for _, k := range keys {
+			fv := rv.MapIndex(k)
+			if fv.Kind() == reflect.Interface {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			fa.bindOne(k.String(), strconv.Quote(k.String()), fv, index, opts, report)
+		}
+	default:
+		return nil, fmt.Errorf("bind: %T is not a struct or a map keyed by field ID", v)
+	}
+	return report, nil
+}
+
+func (fa *FormAnnotation) bindStruct(rv reflect.Value, index *FieldIndex, opts FillOptions, report *FillReport) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, hasTag := sf.Tag.Lookup("form")
+		id, options, _ := strings.Cut(tag, ",")
+		fv := rv.Field(i)
+		if !hasTag || id == "" {
+			for fv.Kind() == reflect.Pointer && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && !boundScalar(fv.Type()) {
+				fa.bindStruct(fv, index, opts, report)
+			}
+			continue
+		}
+		if id == "-" || (slices.Contains(strings.Split(options, ","), "omitempty") && fv.IsZero()) {
+			continue
+		}
+		fa.bindOne(id, sf.Name, fv, index, opts, report)
+	}
+}
+
+// bindOne fills the field or group with the given ID from fv; name
+// identifies the value in issues.
+func (fa *FormAnnotation) bindOne(id, name string, fv reflect.Value, index *FieldIndex, opts FillOptions, report *FillReport) {
+	mismatch := func(page int, err error) {
+		report.add(FillIssue{
+			FieldID:  id,
+			Page:     page,
+			Code:     FillTypeMismatch,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("%s: %v", name, err),
+		})
+	}
+	raw, ok, err := bindValue(fv)
+	if !ok {
+		return
+	}
+	if err != nil {
+		mismatch(0, err)
+		return
+	}
+	field, page := index.lookup(id)
+	if field == nil {
+		g := fa.GetGroup(id)
+		if g == nil {
+			report.add(FillIssue{
+				FieldID:  id,
+				Code:     FillUnknownField,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s: no field or group with ID %q", name, id),
+			})
+			return
+		}
+		data := map[string]any{}
+		if err := setPath(data, g.valuePath(), raw); err != nil {
+			mismatch(0, err)
+			return
+		}
+		fa.fillOptionGroups(data, opts, report)
+		return
+	}
+	value, err := canonicalValue(field, raw)
+	if err != nil {
+		mismatch(page, err)
+		return
+	}
+	fa.placeTransformed(field, page, value, opts, report)
+}
+
+// boundScalar reports whether a struct type is bound as one value.
+func boundScalar(t reflect.Type) bool {
+	return t == reflect.TypeFor[Date]() || t == reflect.TypeFor[time.Time]() || t == reflect.TypeFor[big.Rat]()
+}
+
+// bindValue converts a struct field to a value canonicalValue accepts. It
+// reports false for a nil pointer, which leaves the field as it is.
+func bindValue(fv reflect.Value) (any, bool, error) {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil, false, nil
+		}
+		if r, ok := fv.Interface().(*big.Rat); ok {
+			return r, true, nil
+		}
+		fv = fv.Elem()
+	}
+	switch v := fv.Interface().(type) {
+	case Date, time.Time:
+		return v, true, nil
+	case big.Rat:
+		return &v, true, nil
+	case json.Number:
+		return v.String(), true, nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), true, nil
+	case reflect.Bool:
+		return fv.Bool(), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fv.Int(), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := fv.Uint(); u <= math.MaxInt64 {
+			return int64(u), true, nil
+		}
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(fv.Uint())), true, nil
+	case reflect.Float32:
+		// Go through the shortest decimal so that float32(0.1) binds as 0.1.
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 32), true, nil
+	case reflect.Float64:
+		return fv.Float(), true, nil
+	}
+	return nil, true, fmt.Errorf("cannot bind a value of type %s", fv.Type())
+}
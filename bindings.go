@@ -0,0 +1,404 @@
+package annotation
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kinds of bound value, as ExtractValues produces them.
+const (
+	bindString  = "string"
+	bindDecimal = "decimal"
+	bindInteger = "integer"
+	bindBoolean = "boolean"
+	bindDate    = "date"
+	bindOption  = "option"
+)
+
+// bindingNode is one key of the value document ExtractValues builds and
+// FillFromData reads: an object when it has children, a value otherwise.
+type bindingNode struct {
+	children map[string]*bindingNode
+	leaf     *bindingLeaf
+}
+
+type bindingLeaf struct {
+	kind     string
+	required bool
+	doc      string      // the field's label or line reference
+	field    *Field      // the first field bound here; nil for a group
+	group    *FieldGroup // the option or yes/no group bound here
+}
+
+// bindingTree arranges the value paths of the annotation's fields and
+// option groups into a tree. Members of option and yes/no groups are bound
+// through their group, as ExtractValues binds them. A path bound with two
+// kinds of value, or both as a value and as an object, is an error.
+func (fa *FormAnnotation) bindingTree() (*bindingNode, error) {
+	root := &bindingNode{children: map[string]*bindingNode{}}
+	add := func(p string, leaf *bindingLeaf) error {
+		node := root
+		keys := strings.Split(p, ".")
+		for i, key := range keys {
+			if key == "" {
+				return fmt.Errorf("value path %q has an empty key", p)
+			}
+			if node.leaf != nil {
+				return fmt.Errorf("value path %q runs through the value at %q", p, strings.Join(keys[:i], "."))
+			}
+			next := node.children[key]
+			if next == nil {
+				next = &bindingNode{}
+				if node.children == nil {
+					node.children = map[string]*bindingNode{}
+				}
+				node.children[key] = next
+			}
+			node = next
+		}
+		switch {
+		case node.children != nil:
+			return fmt.Errorf("value path %q is also an object", p)
+		case node.leaf == nil:
+			node.leaf = leaf
+		case node.leaf.kind != leaf.kind:
+			return fmt.Errorf("value path %q is bound to both %s and %s values", p, node.leaf.kind, leaf.kind)
+		default:
+			node.leaf.required = node.leaf.required || leaf.required
+		}
+		return nil
+	}
+
+	members := map[string]bool{}
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		leaf := &bindingLeaf{group: g}
+		switch {
+		case g.GroupType == GroupTypeYesNo:
+			yes, no, err := fa.yesNoMembers(g.GroupID)
+			if err != nil {
+				return nil, err
+			}
+			members[yes.FieldID], members[no.FieldID] = true, true
+			leaf.kind, leaf.required = bindBoolean, g.Required
+		case g.optionGroup():
+			for _, id := range g.FieldIDs {
+				if f := fa.GetFieldByID(id); f != nil {
+					members[f.FieldID] = true
+				}
+			}
+			leaf.kind = bindOption
+		default:
+			continue
+		}
+		if err := add(g.valuePath(), leaf); err != nil {
+			return nil, fmt.Errorf("group %q: %w", g.GroupID, err)
+		}
+	}
+	for _, f := range fa.Fields() {
+		if f.FieldValue == "" || members[f.FieldID] {
+			continue
+		}
+		leaf := &bindingLeaf{kind: bindString, required: alwaysRequired(f.Validation), doc: cmp.Or(f.Label, f.IRSLineRef), field: f}
+		switch {
+		case f.DataType == DataTypeDate:
+			leaf.kind = bindDate
+		case f.DataType == DataTypeBoolean || f.FieldType == FieldTypeCheckbox:
+			leaf.kind = bindBoolean
+		case f.DataType == DataTypeDecimal:
+			leaf.kind = bindDecimal
+		case f.DataType == DataTypeInteger:
+			leaf.kind = bindInteger
+		}
+		if err := add(f.FieldValue, leaf); err != nil {
+			return nil, fmt.Errorf("field %q: %w", f.FieldID, err)
+		}
+	}
+	return root, nil
+}
+
+// alwaysRequired reports whether v requires a value unconditionally and at
+// the hard tier.
+func alwaysRequired(v *Validation) bool {
+	if v == nil || (!v.Required && (v.RequiredIf != "" || v.Level == "")) {
+		return false
+	}
+	return v.Level != RequirementSoft && v.Level != RequirementRecommended
+}
+
+// GoTypeOptions controls GenerateGoTypesWithOptions.
+type GoTypeOptions struct {
+	// TypeName names the top-level struct; the default is the form ID in
+	// Go case, as F1040 for "f1040".
+	TypeName string
+	// Decimal is the Go type of decimal values; the default is json.Number,
+	// which keeps their digits exactly.
+	Decimal string
+	// Date is the Go type of date values; the default is time.Time. Note
+	// that time.Time decodes only RFC 3339 timestamps; use string to bind
+	// the dates ExtractValues writes.
+	Date string
+	// Imports lists the import paths of packages the types above name,
+	// other than encoding/json and time.
+	Imports []string
+}
+
+// GenerateGoTypes is GenerateGoTypesWithOptions with the default options.
+func GenerateGoTypes(fa *FormAnnotation, pkgName string) ([]byte, error) {
+	return GenerateGoTypesWithOptions(fa, pkgName, GoTypeOptions{})
+}
+
+// GenerateGoTypesWithOptions writes a gofmt-formatted Go source file
+// declaring structs that bind the annotation's value document, as
+// ExtractValues produces it and FillFromData reads it. Each dotted
+// field_value path becomes nested structs, named after their parent and
+// key, with JSON tags matching the keys. Integers are int64, booleans bool
+// and option groups string. Values not required unconditionally are
+// tagged omitempty. The output depends only on the annotation and opts.
+func GenerateGoTypesWithOptions(fa *FormAnnotation, pkgName string, opts GoTypeOptions) ([]byte, error) {
+	if !isGoIdentifier(pkgName) {
+		return nil, fmt.Errorf("generate Go types: %q is not a package name", pkgName)
+	}
+	root, err := fa.bindingTree()
+	if err != nil {
+		return nil, fmt.Errorf("generate Go types: %w", err)
+	}
+	typeName := opts.TypeName
+	if typeName == "" {
+		typeName = goName(fa.FormMetadata.FormID)
+	}
+	if !isGoIdentifier(typeName) {
+		return nil, fmt.Errorf("generate Go types: %q is not a type name", typeName)
+	}
+	goTypes := map[string]string{
+		bindString:  "string",
+		bindDecimal: cmp.Or(opts.Decimal, "json.Number"),
+		bindInteger: "int64",
+		bindBoolean: "bool",
+		bindDate:    cmp.Or(opts.Date, "time.Time"),
+		bindOption:  "string",
+	}
+	known := map[string]string{"json": "encoding/json", "time": "time"}
+	for _, imp := range opts.Imports {
+		known[path.Base(imp)] = imp
+	}
+	imports := map[string]bool{}
+	needs := func(goType string) error {
+		qualifier, _, ok := strings.Cut(strings.TrimLeft(goType, "*[]"), ".")
+		if !ok {
+			return nil
+		}
+		imp, ok := known[qualifier]
+		if !ok {
+			return fmt.Errorf("generate Go types: no import for package %q of type %s", qualifier, goType)
+		}
+		imports[imp] = true
+		return nil
+	}
+
+	var body bytes.Buffer
+	declared := map[string]bool{}
+	var emit func(name string, node *bindingNode) error
+	emit = func(name string, node *bindingNode) error {
+		if declared[name] {
+			return fmt.Errorf("generate Go types: two objects are both named %s", name)
+		}
+		declared[name] = true
+		keys := sortedKeys(node.children)
+		names := goFieldNames(keys)
+		fmt.Fprintf(&body, "\ntype %s struct {\n", name)
+		var nested []int
+		for i, key := range keys {
+			child := node.children[key]
+			if child.leaf == nil {
+				fmt.Fprintf(&body, "\t%s %s `json:%q`\n", names[i], name+names[i], key)
+				nested = append(nested, i)
+				continue
+			}
+			goType := goTypes[child.leaf.kind]
+			if err := needs(goType); err != nil {
+				return err
+			}
+			if doc := strings.TrimSpace(child.leaf.doc); doc != "" {
+				fmt.Fprintf(&body, "\t// %s\n", strings.Join(strings.Fields(doc), " "))
+			}
+			tag := key
+			if !child.leaf.required {
+				tag += ",omitempty"
+			}
+			fmt.Fprintf(&body, "\t%s %s `json:%q`\n", names[i], goType, tag)
+		}
+		body.WriteString("}\n")
+		for _, i := range nested {
+			if err := emit(name+names[i], node.children[keys[i]]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := emit(typeName, root); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated from form annotation %s; DO NOT EDIT.\n\npackage %s\n", strconv.Quote(fa.FormMetadata.FormID), pkgName)
+	if len(imports) > 0 {
+		b.WriteString("\nimport (\n")
+		for _, imp := range sortedKeys(imports) {
+			fmt.Fprintf(&b, "\t%q\n", imp)
+		}
+		b.WriteString(")\n")
+	}
+	b.Write(body.Bytes())
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generate Go types: %w", err)
+	}
+	return out, nil
+}
+
+// goInitialisms are written in upper case in Go names.
+var goInitialisms = map[string]bool{"ID": true, "SSN": true, "EIN": true, "ITIN": true, "ZIP": true, "URL": true, "IRS": true}
+
+// goName turns a key such as "first_name" into an exported Go name such
+// as FirstName.
+func goName(key string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(key, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if upper := strings.ToUpper(word); goInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(word)
+		b.WriteRune(unicode.ToUpper(r[0]))
+		b.WriteString(string(r[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "Value"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+// goFieldNames returns the Go names of sorted keys, numbering the later of
+// keys whose names collide.
+func goFieldNames(keys []string) []string {
+	names := make([]string, len(keys))
+	used := map[string]bool{}
+	for i, key := range keys {
+		name := goName(key)
+		for n := 2; used[name]; n++ {
+			name = goName(key) + strconv.Itoa(n)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+func isGoIdentifier(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// GenerateJSONSchema writes a JSON Schema (draft-07) describing the
+// annotation's value document, as ExtractValues produces it and
+// FillFromData reads it. Values required unconditionally are listed as
+// required, along with the objects holding them, and patterns, bounds and
+// lengths are lifted from each field's validation. Decimals and integers
+// are numbers, dates strings in the date format, and option groups one of
+// their expected options. Keys are written in sorted order.
+func GenerateJSONSchema(fa *FormAnnotation) ([]byte, error) {
+	root, err := fa.bindingTree()
+	if err != nil {
+		return nil, fmt.Errorf("generate JSON schema: %w", err)
+	}
+	schema, _ := jsonSchemaOf(root)
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	if title := fa.FormMetadata.FormName; title != "" {
+		schema["title"] = title
+	} else if fa.FormMetadata.FormID != "" {
+		schema["title"] = fa.FormMetadata.FormID
+	}
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// jsonSchemaOf returns the schema of node and whether it is required.
+func jsonSchemaOf(node *bindingNode) (map[string]any, bool) {
+	if leaf := node.leaf; leaf != nil {
+		s := map[string]any{}
+		if doc := strings.TrimSpace(leaf.doc); doc != "" {
+			s["description"] = strings.Join(strings.Fields(doc), " ")
+		}
+		switch leaf.kind {
+		case bindString:
+			s["type"] = "string"
+		case bindDecimal:
+			s["type"] = "number"
+		case bindInteger:
+			s["type"] = "integer"
+		case bindBoolean:
+			s["type"] = "boolean"
+		case bindDate:
+			s["type"], s["format"] = "string", "date"
+		case bindOption:
+			s["type"], s["enum"] = "string", slices.Clone(leaf.group.ExpectedOptions)
+		}
+		if leaf.field != nil && leaf.field.Validation != nil {
+			v := leaf.field.Validation
+			switch leaf.kind {
+			case bindString:
+				if v.Pattern != "" {
+					s["pattern"] = v.Pattern
+				}
+				if v.MinLength > 0 {
+					s["minLength"] = v.MinLength
+				}
+				if v.MaxLength > 0 {
+					s["maxLength"] = v.MaxLength
+				}
+			case bindDecimal, bindInteger:
+				if v.Min != 0 {
+					s["minimum"] = v.Min
+				}
+				if v.Max != 0 {
+					s["maximum"] = v.Max
+				}
+			}
+		}
+		return s, leaf.required
+	}
+	properties := map[string]any{}
+	required := []string{}
+	for _, key := range sortedKeys(node.children) {
+		child, req := jsonSchemaOf(node.children[key])
+		properties[key] = child
+		if req {
+			required = append(required, key)
+		}
+	}
+	s := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s, len(required) > 0
+}
@@ -0,0 +1,404 @@
+package annotation
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Builder constructs an annotation in code. Methods record what they are
+// given and Build checks the result, so calls can come in any order: a
+// field may name a group that is declared later.
+type Builder struct {
+	fa   *FormAnnotation
+	page int // index into fa.Pages of the current page, or -1
+	errs []error
+}
+
+// FieldOption sets part of a field. Any func(*Field) works, so everything
+// the format can express can be built.
+type FieldOption func(*Field)
+
+// ValidationOption sets part of a field's Validation block.
+type ValidationOption func(*Validation)
+
+// FormattingOption sets part of a field's Formatting block.
+type FormattingOption func(*Formatting)
+
+// NewBuilder starts an annotation for a form. Pages are US Letter in
+// points unless PageSize says otherwise.
+func NewBuilder(formID, formName string, year int) *Builder {
+	return &Builder{
+		fa: &FormAnnotation{FormMetadata: FormMetadata{
+			FormID:   formID,
+			FormName: formName,
+			Year:     year,
+			PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"},
+		}},
+		page: -1,
+	}
+}
+
+// PageSize sets the size of every page.
+func (b *Builder) PageSize(width, height float64, unit string) *Builder {
+	b.fa.FormMetadata.PageSize = PageSize{Width: width, Height: height, Unit: unit}
+	return b
+}
+
+// Page makes page n current, adding it if needed. Fields are added to the
+// current page.
+func (b *Builder) Page(n int) *Builder {
+	for i, page := range b.fa.Pages {
+		if page.PageNumber == n {
+			b.page = i
+			return b
+		}
+	}
+	b.fa.Pages = append(b.fa.Pages, Page{PageNumber: n, Fields: []Field{}})
+	b.page = len(b.fa.Pages) - 1
+	return b
+}
+
+// Anchor adds a named anchor to the current page, for fields placed with
+// WithAnchorOffset.
+func (b *Builder) Anchor(name string, x, y float64, unit string) *Builder {
+	if b.page < 0 {
+		b.errs = append(b.errs, fmt.Errorf("anchor %q is added before any page", name))
+		return b
+	}
+	b.fa.Pages[b.page].Anchors = append(b.fa.Pages[b.page].Anchors, Anchor{Name: name, X: x, Y: y, Unit: unit})
+	return b
+}
+
+// Rotation sets how many degrees clockwise viewers turn the current page.
+func (b *Builder) Rotation(degrees int) *Builder {
+	if b.page < 0 {
+		b.errs = append(b.errs, fmt.Errorf("rotation is set before any page"))
+		return b
+	}
+	b.fa.Pages[b.page].Rotation = degrees
+	return b
+}
+
+// CurrentPageSize sets the size of the current page alone, in the unit of
+// the document's page size.
+func (b *Builder) CurrentPageSize(width, height float64) *Builder {
+	if b.page < 0 {
+		b.errs = append(b.errs, fmt.Errorf("page size is set before any page"))
+		return b
+	}
+	b.fa.Pages[b.page].PageSize = &PageSize{Width: width, Height: height}
+	return b
+}
+
+// Field adds a field of any type to the current page.
+func (b *Builder) Field(id string, fieldType FieldType, dataType DataType, opts ...FieldOption) *Builder {
+	if b.page < 0 {
+		b.errs = append(b.errs, fmt.Errorf("field %q is added before any page", id))
+		return b
+	}
+	f := Field{FieldID: id, FieldType: fieldType, DataType: dataType}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	b.fa.Pages[b.page].Fields = append(b.fa.Pages[b.page].Fields, f)
+	return b
+}
+
+// TextField adds a text field holding a string.
+func (b *Builder) TextField(id string, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeText, DataTypeString, opts...)
+}
+
+// CurrencyField adds a currency field holding a decimal.
+func (b *Builder) CurrencyField(id string, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeCurrency, DataTypeDecimal, opts...)
+}
+
+// NumericField adds a numeric field holding an integer.
+func (b *Builder) NumericField(id string, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeNumeric, DataTypeInteger, opts...)
+}
+
+// CheckboxField adds a checkbox holding a boolean.
+func (b *Builder) CheckboxField(id string, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeCheckbox, DataTypeBoolean, opts...)
+}
+
+// DateField adds a date field.
+func (b *Builder) DateField(id string, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeDate, DataTypeDate, opts...)
+}
+
+// SegmentedField adds a segmented field, such as an SSN, drawn into segs.
+func (b *Builder) SegmentedField(id string, segs []Segment, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeSegmented, DataTypeString, append([]FieldOption{WithSegments(segs...)}, opts...)...)
+}
+
+// SignatureField adds a signature field.
+func (b *Builder) SignatureField(id string, opts ...FieldOption) *Builder {
+	return b.Field(id, FieldTypeSignature, DataTypeString, opts...)
+}
+
+// BarcodeField adds a barcode field in symbology, encoding template as
+// BarcodeSpec describes, or its own value when template is empty.
+func (b *Builder) BarcodeField(id, symbology, template string, opts ...FieldOption) *Builder {
+	spec := func(f *Field) { f.Barcode = &BarcodeSpec{Symbology: symbology, Template: template} }
+	return b.Field(id, FieldTypeBarcode, DataTypeString, append([]FieldOption{spec}, opts...)...)
+}
+
+// ImageField adds an image field placing its picture by fit, one of the
+// ImageFit modes.
+func (b *Builder) ImageField(id, fit string, opts ...FieldOption) *Builder {
+	spec := func(f *Field) { f.Image = &ImageSpec{Fit: fit} }
+	return b.Field(id, FieldTypeImage, DataTypeString, append([]FieldOption{spec}, opts...)...)
+}
+
+// Group declares a field group. Fields naming the group with WithGroup are
+// added to its members on Build.
+func (b *Builder) Group(id, groupType string, fieldIDs ...string) *Builder {
+	b.fa.FieldGroups = append(b.fa.FieldGroups, FieldGroup{GroupID: id, GroupType: groupType, FieldIDs: fieldIDs})
+	return b
+}
+
+// RepeatingGroup declares a table group whose rows copy the template
+// fields, spacing apart in the page unit, up to maxRows; zero is unbounded.
+// Fields join it with WithGroup, and ExpandRows adds the rows.
+func (b *Builder) RepeatingGroup(id string, spacing float64, maxRows int, templateIDs ...string) *Builder {
+	b.fa.FieldGroups = append(b.fa.FieldGroups, FieldGroup{
+		GroupID:   id,
+		GroupType: GroupTypeTable,
+		FieldIDs:  slices.Clone(templateIDs),
+		Repeat:    &RepeatingRows{TemplateFieldIDs: templateIDs, Spacing: spacing, MaxRows: maxRows},
+	})
+	return b
+}
+
+// Build returns the annotation with its page count set. Pages are sorted by
+// number, and group members are completed from the fields' group IDs. Any
+// structural error Validate reports, such as a duplicate field ID or a
+// group naming an unknown field, fails the build. Relative positions are
+// resolved.
+func (b *Builder) Build() (*FormAnnotation, error) {
+	fa := b.fa.Clone()
+	slices.SortStableFunc(fa.Pages, func(x, y Page) int { return x.PageNumber - y.PageNumber })
+	fa.FormMetadata.PageCount = len(fa.Pages)
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		if g.FieldIDs == nil {
+			g.FieldIDs = []string{}
+		}
+		for _, f := range fa.Fields() {
+			if f.GroupID == g.GroupID && !slices.Contains(g.FieldIDs, f.FieldID) {
+				g.FieldIDs = append(g.FieldIDs, f.FieldID)
+			}
+		}
+	}
+	errs := append([]error(nil), b.errs...)
+	for _, issue := range fa.Validate() {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := fa.ResolvePositions(); err != nil {
+		return nil, err
+	}
+	return fa, nil
+}
+
+// WithPosition places the field.
+func WithPosition(x, y, width, height float64, unit string) FieldOption {
+	return func(f *Field) { f.Position = Position{X: x, Y: y, Width: width, Height: height, Unit: unit} }
+}
+
+// WithAnchorOffset places the field's top-left corner dx and dy from the
+// named anchor of its page, in the unit of its position. Build resolves
+// the position; set the size with WithPosition.
+func WithAnchorOffset(anchor string, dx, dy float64) FieldOption {
+	return func(f *Field) { f.RelativeTo = &RelativePosition{Anchor: anchor, OffsetX: dx, OffsetY: dy} }
+}
+
+// WithFieldOffset places the field's top-left corner dx and dy from that
+// of another field on its page, like WithAnchorOffset.
+func WithFieldOffset(fieldID string, dx, dy float64) FieldOption {
+	return func(f *Field) { f.RelativeTo = &RelativePosition{FieldID: fieldID, OffsetX: dx, OffsetY: dy} }
+}
+
+// WithLabel sets the field's label.
+func WithLabel(label string) FieldOption {
+	return func(f *Field) { f.Label = label }
+}
+
+// WithTooltip sets the field's tooltip.
+func WithTooltip(tooltip string) FieldOption {
+	return func(f *Field) { f.Tooltip = tooltip }
+}
+
+// WithLocalized sets the field's label and tooltip in lang.
+func WithLocalized(lang, label, tooltip string) FieldOption {
+	return func(f *Field) {
+		if f.Localized == nil {
+			f.Localized = map[string]LabelSet{}
+		}
+		f.Localized[lang] = LabelSet{Label: label, Tooltip: tooltip}
+	}
+}
+
+// WithLineRef sets the IRS line reference.
+func WithLineRef(ref string) FieldOption {
+	return func(f *Field) { f.IRSLineRef = ref }
+}
+
+// WithDataType overrides the data type the field constructor chose.
+func WithDataType(dt DataType) FieldOption {
+	return func(f *Field) { f.DataType = dt }
+}
+
+// WithValuePath binds the field to a value path, as in "taxpayer.ssn".
+func WithValuePath(path string) FieldOption {
+	return func(f *Field) { f.FieldValue = path }
+}
+
+// WithGroup makes the field a member of a group.
+func WithGroup(groupID string) FieldOption {
+	return func(f *Field) { f.GroupID = groupID }
+}
+
+// WithSigner sets a signature field's signer role and the date field
+// dated when it is signed; dateField may be empty.
+func WithSigner(role, dateField string) FieldOption {
+	return func(f *Field) { f.Signature = &SignatureSpec{Role: role, DateField: dateField} }
+}
+
+// WithOptionCode sets the option a radio group member represents.
+func WithOptionCode(code string) FieldOption {
+	return func(f *Field) { f.OptionCode = code }
+}
+
+// WithStyle sets the text style.
+func WithStyle(style TextStyle) FieldOption {
+	return func(f *Field) { f.Style = &style }
+}
+
+// WithCheckStyle sets the checkbox mark style.
+func WithCheckStyle(style CheckStyle) FieldOption {
+	return func(f *Field) { f.CheckStyle = &style }
+}
+
+// WithSegments sets the segments of a segmented field.
+func WithSegments(segs ...Segment) FieldOption {
+	return func(f *Field) { f.Segments = append([]Segment(nil), segs...) }
+}
+
+// BuildSegments lays out segments side by side from start, each holding
+// lengths[i] character boxes boxWidth wide, with gap between segments.
+// Every segment takes start's Y, height and unit.
+func BuildSegments(start Position, boxWidth, gap float64, lengths []int) []Segment {
+	segs := make([]Segment, len(lengths))
+	x := start.X
+	for i, n := range lengths {
+		pos := start
+		pos.X, pos.Width = roundUnit(x), roundUnit(boxWidth*float64(n))
+		segs[i] = Segment{Position: pos, Length: n}
+		x += boxWidth*float64(n) + gap
+	}
+	return segs
+}
+
+// WithValidation adds constraints to the field's Validation block.
+func WithValidation(opts ...ValidationOption) FieldOption {
+	return func(f *Field) {
+		if f.Validation == nil {
+			f.Validation = &Validation{}
+		}
+		for _, opt := range opts {
+			opt(f.Validation)
+		}
+	}
+}
+
+// WithCalculation computes the field's value from expr on Recalculate.
+func WithCalculation(expr string) FieldOption {
+	return func(f *Field) { f.Calculation = &Calculation{Expr: expr} }
+}
+
+// WithVisibleIf shows the field only while expr holds.
+func WithVisibleIf(expr string) FieldOption {
+	return func(f *Field) {
+		if f.Conditions == nil {
+			f.Conditions = &Conditions{}
+		}
+		f.Conditions.VisibleIf = expr
+	}
+}
+
+// WithDisabledIf closes the field to input while expr holds.
+func WithDisabledIf(expr string) FieldOption {
+	return func(f *Field) {
+		if f.Conditions == nil {
+			f.Conditions = &Conditions{}
+		}
+		f.Conditions.DisabledIf = expr
+	}
+}
+
+// WithFormatting adds settings to the field's Formatting block.
+func WithFormatting(opts ...FormattingOption) FieldOption {
+	return func(f *Field) {
+		if f.Formatting == nil {
+			f.Formatting = &Formatting{}
+		}
+		for _, opt := range opts {
+			opt(f.Formatting)
+		}
+	}
+}
+
+// Required makes a value mandatory.
+func Required() ValidationOption { return func(v *Validation) { v.Required = true } }
+
+// RequiredIf makes a value mandatory when expr holds.
+func RequiredIf(expr string) ValidationOption { return func(v *Validation) { v.RequiredIf = expr } }
+
+// Pattern requires the value to match a regular expression.
+func Pattern(re string) ValidationOption { return func(v *Validation) { v.Pattern = re } }
+
+// Range bounds a numeric value; zero leaves a bound unset.
+func Range(lo, hi float64) ValidationOption {
+	return func(v *Validation) { v.Min, v.Max = lo, hi }
+}
+
+// Length bounds the number of characters; zero leaves a bound unset.
+func Length(lo, hi int) ValidationOption {
+	return func(v *Validation) { v.MinLength, v.MaxLength = lo, hi }
+}
+
+// DateRange bounds a date, with literal dates or bounds such as "today" or
+// "tax_year_end+3m15d"; an empty string leaves a bound unset.
+func DateRange(lo, hi string) ValidationOption {
+	return func(v *Validation) { v.MinDate, v.MaxDate = lo, hi }
+}
+
+// DecimalPlaces sets the digits shown after the decimal point.
+func DecimalPlaces(n int) FormattingOption { return func(f *Formatting) { f.DecimalPlaces = n } }
+
+// ShowCommas groups thousands with commas.
+func ShowCommas() FormattingOption { return func(f *Formatting) { f.ShowCommas = true } }
+
+// NegativeFormat sets how negative amounts are written.
+func NegativeFormat(format string) FormattingOption {
+	return func(f *Formatting) { f.NegativeFormat = format }
+}
+
+// Affixes sets text written before and after the value.
+func Affixes(prefix, suffix string) FormattingOption {
+	return func(f *Formatting) { f.Prefix, f.Suffix = prefix, suffix }
+}
+
+// DateFormat sets the date format, as in "MM/DD/YYYY".
+func DateFormat(format string) FormattingOption {
+	return func(f *Formatting) { f.DateFormat = format }
+}
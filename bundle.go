@@ -0,0 +1,501 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidationBundleVersion is the format version written by ExportValidationBundle.
+const ValidationBundleVersion = 1
+
+// ValidationBundle is the portable form of an annotation's value constraints.
+// It carries no positions, styles, or values, and its Digest is the
+// StructuralHash of the annotation it was exported from so clients can tell
+// when their copy is stale. Conditional requirements and visibility use the
+// syntax documented on Expr; a field hidden or disabled by its conditions
+// is not required.
+type ValidationBundle struct {
+	Version int                    `json:"bundle_version"`
+	FormID  string                 `json:"form_id"`
+	Year    int                    `json:"year"`
+	Digest  string                 `json:"digest"`
+	Fields  map[string]BundleField `json:"fields"`
+	Groups  []BundleGroup          `json:"groups,omitempty"`
+}
+
+// BundleField holds the constraints for one field, keyed by field ID in the
+// bundle. MinDate and MaxDate are literal dates, or today with an optional
+// offset such as today-18y for the client to resolve.
+type BundleField struct {
+	Path       string           `json:"path,omitempty"`
+	FieldType  FieldType        `json:"field_type"`
+	DataType   DataType         `json:"data_type"`
+	Required   bool             `json:"required,omitempty"`
+	Level      RequirementLevel `json:"requirement_level,omitempty"`
+	RequiredIf string           `json:"required_if,omitempty"`
+	VisibleIf  string           `json:"visible_if,omitempty"`
+	DisabledIf string           `json:"disabled_if,omitempty"`
+	Options    []string         `json:"options,omitempty"`
+	Pattern    string           `json:"pattern,omitempty"`
+	Min        *float64         `json:"min,omitempty"`
+	Max        *float64         `json:"max,omitempty"`
+	MinLength  int              `json:"min_length,omitempty"`
+	MaxLength  int              `json:"max_length,omitempty"`
+	DateFormat string           `json:"date_format,omitempty"`
+	MinDate    string           `json:"min_date,omitempty"`
+	MaxDate    string           `json:"max_date,omitempty"`
+	// DateField, on a signature, is the ID of the field dated when it is
+	// signed; one of the two filled without the other is reported.
+	DateField string `json:"date_field,omitempty"`
+}
+
+// BundleGroup is a constraint spanning several fields.
+type BundleGroup struct {
+	GroupID  string   `json:"group_id"`
+	Rule     string   `json:"rule"`
+	FieldIDs []string `json:"field_ids"`
+	Required bool     `json:"required,omitempty"`
+	// SumField and Rounding are the field holding the members' total and
+	// how the total is rounded, for GroupRuleSumEquals.
+	SumField string    `json:"sum_field,omitempty"`
+	Rounding *Rounding `json:"rounding,omitempty"`
+}
+
+// GroupRuleAtMostOne allows at most one member of the group to be checked.
+const GroupRuleAtMostOne = "at_most_one"
+
+// Value validation issue codes.
+const (
+	ValueRequired        = "required"
+	ValuePattern         = "pattern"
+	ValueBelowMin        = "min"
+	ValueAboveMax        = "max"
+	ValueTooShort        = "min_length"
+	ValueTooLong         = "max_length"
+	ValueNotNumber       = "not_number"
+	ValueNotInteger      = "not_integer"
+	ValueNotBoolean      = "not_boolean"
+	ValueNotDate         = "not_date"
+	ValueBeforeMinDate   = "min_date"
+	ValueAfterMaxDate    = "max_date"
+	GroupMultipleChecked = "multiple_checked"
+	ValueNotOption       = "not_option"
+	RuleInvalid          = "invalid_rule"
+)
+
+// ExportValidationBundle emits the annotation's value constraints as a
+// compact, versioned JSON document for client-side enforcement.
+func (fa *FormAnnotation) ExportValidationBundle() ([]byte, error) {
+	bundle, err := fa.validationBundle()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(bundle)
+}
+
+// ValidateWithBundle checks values, keyed by field ID, against an exported
+// bundle. It is the reference implementation for clients of the bundle format.
+func ValidateWithBundle(bundle []byte, values map[string]string) (*ValidationReport, error) {
+	var b ValidationBundle
+	if err := json.Unmarshal(bundle, &b); err != nil {
+		return nil, err
+	}
+	if b.Version < 1 || b.Version > ValidationBundleVersion {
+		return nil, fmt.Errorf("unsupported validation bundle version %d", b.Version)
+	}
+	return b.validate(values), nil
+}
+
+// ValidateValues checks values, keyed by field ID, against each field's
+// Validation block and the annotation's group rules. It evaluates exactly the
+// rules ExportValidationBundle exports, so client and server agree.
+func (fa *FormAnnotation) ValidateValues(values map[string]string) *ValidationReport {
+	bundle, err := fa.validationBundle()
+	if err != nil {
+		return &ValidationReport{Issues: []ValidationIssue{{
+			Code:     RuleInvalid,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		}}}
+	}
+	report := bundle.validate(values)
+	for i := range report.Issues {
+		if id := report.Issues[i].FieldID; id != "" {
+			_, report.Issues[i].Page = fa.fieldAndPage(id)
+		}
+	}
+	return report
+}
+
+// ValidateFilled is ValidateValues over the values filled into the fields,
+// so that a form filled in place can be checked as it stands. Encrypted
+// values are checked as sealed; decrypt them first.
+func (fa *FormAnnotation) ValidateFilled() *ValidationReport {
+	values := map[string]string{}
+	for _, field := range fa.Fields() {
+		if field.Value != "" {
+			values[field.FieldID] = field.Value
+		}
+	}
+	return fa.ValidateValues(values)
+}
+
+func (fa *FormAnnotation) validationBundle() (*ValidationBundle, error) {
+	digest, err := fa.StructuralHash()
+	if err != nil {
+		return nil, err
+	}
+	bundle := &ValidationBundle{
+		Version: ValidationBundleVersion,
+		FormID:  fa.FormMetadata.FormID,
+		Year:    fa.FormMetadata.Year,
+		Digest:  digest,
+		Fields:  map[string]BundleField{},
+	}
+	for _, field := range fa.Fields() {
+		bf, err := bundleField(field, fa.FormMetadata.Year)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Fields[field.FieldID] = bf
+	}
+	for _, group := range fa.FieldGroups {
+		switch group.GroupType {
+		case GroupTypeRadio:
+			bundle.Groups = append(bundle.Groups, BundleGroup{
+				GroupID:  group.GroupID,
+				Rule:     GroupRuleAtMostOne,
+				FieldIDs: fa.resolveIDs(group.FieldIDs),
+			})
+		case GroupTypeYesNo:
+			bundle.Groups = append(bundle.Groups, BundleGroup{
+				GroupID:  group.GroupID,
+				Rule:     GroupRuleYesNo,
+				FieldIDs: fa.resolveIDs(group.FieldIDs),
+				Required: group.Required,
+			})
+		}
+		bundle.Groups = append(bundle.Groups, fa.bundleGroupRules(group)...)
+	}
+	return bundle, nil
+}
+
+// bundleField exports the constraints of one field. Fields without a data
+// type are checked by their field type: checkboxes as booleans, and currency
+// and numeric fields as decimals.
+func bundleField(field *Field, year int) (BundleField, error) {
+	bf := BundleField{
+		Path:      field.FieldValue,
+		FieldType: field.FieldType,
+		DataType:  field.DataType,
+	}
+	if bf.DataType == "" {
+		switch field.FieldType {
+		case FieldTypeCheckbox:
+			bf.DataType = DataTypeBoolean
+		case FieldTypeCurrency, FieldTypeNumeric:
+			bf.DataType = DataTypeDecimal
+		}
+	}
+	if field.Formatting != nil && bf.DataType == DataTypeDate {
+		bf.DateFormat = field.Formatting.DateFormat
+	}
+	if c := field.Conditions; c != nil {
+		bf.VisibleIf, bf.DisabledIf = c.VisibleIf, c.DisabledIf
+	}
+	bf.Options = field.optionValues()
+	if field.Signature != nil {
+		bf.DateField = field.Signature.DateField
+	}
+	if v := field.Validation; v != nil {
+		bf.Required = v.Required
+		bf.Level = v.Level
+		bf.RequiredIf = v.RequiredIf
+		bf.Pattern = v.Pattern
+		bf.MinLength = v.MinLength
+		bf.MaxLength = v.MaxLength
+		if v.Min != 0 {
+			bf.Min = &v.Min
+		}
+		if v.Max != 0 {
+			bf.Max = &v.Max
+		}
+		var err error
+		if bf.MinDate, err = resolveDateBound(v.MinDate, year); err != nil {
+			return bf, fmt.Errorf("field %q: %w", field.FieldID, err)
+		}
+		if bf.MaxDate, err = resolveDateBound(v.MaxDate, year); err != nil {
+			return bf, fmt.Errorf("field %q: %w", field.FieldID, err)
+		}
+	}
+	return bf, nil
+}
+
+// ValueError lists the constraints a single field value fails.
+type ValueError struct {
+	FieldID string
+	Issues  []ValidationIssue
+}
+
+func (e *ValueError) Error() string {
+	msgs := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		msgs[i] = issue.Message
+	}
+	return fmt.Sprintf("field %q: %s", e.FieldID, strings.Join(msgs, "; "))
+}
+
+// ValidateValue checks value against the field's own constraints and
+// returns a *ValueError naming each one it fails. A required_if condition
+// is evaluated as if every other field were empty; use ValidateValues to
+// check values together. Date bounds relative to the form year are not
+// resolved for a field on its own and are not checked.
+func (f *Field) ValidateValue(value string) error {
+	local := *f
+	if v := f.Validation; v != nil && (isYearBound(v.MinDate) || isYearBound(v.MaxDate)) {
+		copied := *v
+		if isYearBound(copied.MinDate) {
+			copied.MinDate = ""
+		}
+		if isYearBound(copied.MaxDate) {
+			copied.MaxDate = ""
+		}
+		local.Validation = &copied
+	}
+	bf, err := bundleField(&local, 0)
+	if err != nil {
+		return err
+	}
+	issues := bf.check(value, func(string) string { return "" })
+	if len(issues) == 0 {
+		return nil
+	}
+	for i := range issues {
+		issues[i].FieldID = f.FieldID
+	}
+	return &ValueError{FieldID: f.FieldID, Issues: issues}
+}
+
+func (b *ValidationBundle) validate(values map[string]string) *ValidationReport {
+	report := &ValidationReport{}
+	b.validateFields(sortedKeys(b.Fields), values, report)
+	b.validateGroups(values, report)
+	return report
+}
+
+// validateFields checks the listed fields against values.
+func (b *ValidationBundle) validateFields(ids []string, values map[string]string, report *ValidationReport) {
+	lookup := func(id string) string { return values[id] }
+	for _, id := range ids {
+		bf := b.Fields[id]
+		for _, issue := range bf.check(values[id], lookup) {
+			issue.FieldID = id
+			report.add(issue)
+		}
+		if bf.DateField == "" {
+			continue
+		}
+		signed := !isEmptyValue(bf.FieldType, bf.DataType, values[id])
+		dated := strings.TrimSpace(values[bf.DateField]) != ""
+		switch {
+		case signed && !dated:
+			report.add(ValidationIssue{Code: ValueUndated, Severity: SeverityError, FieldID: id,
+				Message: fmt.Sprintf("signed, but date field %q is empty", bf.DateField)})
+		case dated && !signed:
+			report.add(ValidationIssue{Code: ValueUnsigned, Severity: SeverityWarning, FieldID: id,
+				Message: fmt.Sprintf("date field %q is filled, but the field is not signed", bf.DateField)})
+		}
+	}
+}
+
+// validateGroups checks the group rules against values.
+func (b *ValidationBundle) validateGroups(values map[string]string, report *ValidationReport) {
+	for _, group := range b.Groups {
+		switch group.Rule {
+		case GroupRuleAllOrNone, GroupRuleSumEquals:
+			check := b.checkAllOrNone
+			if group.Rule == GroupRuleSumEquals {
+				check = b.checkSum
+			}
+			for _, issue := range check(group, values) {
+				report.add(issue)
+			}
+			continue
+		case GroupRuleAtMostOne, GroupRuleYesNo:
+		default:
+			continue
+		}
+		// Members that are not checkboxes count when they are filled.
+		var checked []string
+		filled := false
+		for _, id := range group.FieldIDs {
+			bf, ok := b.Fields[id]
+			if ok && bf.FieldType != FieldTypeCheckbox && bf.DataType != DataTypeBoolean {
+				if strings.TrimSpace(values[id]) != "" {
+					checked, filled = append(checked, id), true
+				}
+			} else if isChecked(values[id]) {
+				checked = append(checked, id)
+			}
+		}
+		switch {
+		case len(checked) > 1 && group.Rule == GroupRuleYesNo:
+			report.add(ValidationIssue{
+				Code:     GroupMultipleChecked,
+				Severity: SeverityError,
+				GroupID:  group.GroupID,
+				Message:  "both Yes and No are checked",
+			})
+		case len(checked) > 1 && filled:
+			report.add(ValidationIssue{
+				Code:     GroupMultipleChecked,
+				Severity: SeverityError,
+				GroupID:  group.GroupID,
+				Message:  fmt.Sprintf("only one member may be filled, got %s", strings.Join(checked, ", ")),
+			})
+		case len(checked) > 1:
+			report.add(ValidationIssue{
+				Code:     GroupMultipleChecked,
+				Severity: SeverityError,
+				GroupID:  group.GroupID,
+				Message:  fmt.Sprintf("only one option may be checked, got %s", strings.Join(checked, ", ")),
+			})
+		case len(checked) == 0 && group.Required:
+			report.add(ValidationIssue{
+				Code:     ValueRequired,
+				Severity: SeverityError,
+				GroupID:  group.GroupID,
+				Message:  "an answer is required",
+			})
+		}
+	}
+}
+
+// check evaluates the field's constraints against value. lookup resolves
+// other fields referenced by a conditional requirement.
+func (bf BundleField) check(value string, lookup func(string) string) []ValidationIssue {
+	fail := func(code, format string, args ...any) []ValidationIssue {
+		return []ValidationIssue{{Code: code, Severity: SeverityError, Message: fmt.Sprintf(format, args...)}}
+	}
+
+	if isEmptyValue(bf.FieldType, bf.DataType, value) {
+		visible, disabled, err := conditionState(bf.VisibleIf, bf.DisabledIf, DefaultExprLimits, lookup)
+		if err != nil {
+			return fail(RuleInvalid, "%v", err)
+		}
+		if !visible || disabled {
+			return nil
+		}
+		level, err := requirementOf(bf.Required, bf.Level, bf.RequiredIf, DefaultExprLimits, lookup)
+		if err != nil {
+			return fail(RuleInvalid, "%v", err)
+		}
+		issues := fail(ValueRequired, "value is required")
+		if bf.RequiredIf != "" {
+			issues[0].Message += " when " + bf.RequiredIf
+		}
+		switch level {
+		case RequirementHard:
+			return issues
+		case RequirementSoft:
+			issues[0].Severity = SeverityWarning
+			return issues
+		}
+		return nil
+	}
+
+	var issues []ValidationIssue
+	if len(bf.Options) > 0 && !slices.Contains(bf.Options, value) {
+		issues = append(issues, fail(ValueNotOption, "value is not one of %s", strings.Join(bf.Options, ", "))...)
+	}
+	if bf.Pattern != "" {
+		re, err := compilePattern(bf.Pattern)
+		if err != nil {
+			return fail(RuleInvalid, "invalid pattern %q: %v", bf.Pattern, err)
+		}
+		if !re.MatchString(value) {
+			issues = append(issues, fail(ValuePattern, "value does not match pattern %q", bf.Pattern)...)
+		}
+	}
+
+	switch bf.DataType {
+	case DataTypeDecimal, DataTypeInteger:
+		n, ok := parseDecimal(value)
+		if !ok {
+			return append(issues, fail(ValueNotNumber, "value is not a number")...)
+		}
+		if bf.DataType == DataTypeInteger && !n.IsInt() {
+			issues = append(issues, fail(ValueNotInteger, "value is not a whole number")...)
+		}
+		if bf.Min != nil && n.Cmp(new(big.Rat).SetFloat64(*bf.Min)) < 0 {
+			issues = append(issues, fail(ValueBelowMin, "value is below the minimum of %g", *bf.Min)...)
+		}
+		if bf.Max != nil && n.Cmp(new(big.Rat).SetFloat64(*bf.Max)) > 0 {
+			issues = append(issues, fail(ValueAboveMax, "value is above the maximum of %g", *bf.Max)...)
+		}
+	case DataTypeBoolean:
+		if _, ok := parseBoolValue(value); !ok {
+			issues = append(issues, fail(ValueNotBoolean, "value is not a boolean")...)
+		}
+	case DataTypeDate:
+		d, err := ParseDate(value, bf.DateFormat)
+		if err != nil {
+			return append(issues, fail(ValueNotDate, "value is not a valid date in %s form", dateFormatOrISO(bf.DateFormat))...)
+		}
+		now := time.Now()
+		if bf.MinDate != "" {
+			if min, err := boundDate(bf.MinDate, now); err == nil && d.Before(min) {
+				issues = append(issues, fail(ValueBeforeMinDate, "date is before %s", min)...)
+			}
+		}
+		if bf.MaxDate != "" {
+			if max, err := boundDate(bf.MaxDate, now); err == nil && d.After(max) {
+				issues = append(issues, fail(ValueAfterMaxDate, "date is after %s", max)...)
+			}
+		}
+	}
+
+	length := utf8.RuneCountInString(value)
+	if bf.MinLength > 0 && length < bf.MinLength {
+		issues = append(issues, fail(ValueTooShort, "value is shorter than %d characters", bf.MinLength)...)
+	}
+	if bf.MaxLength > 0 && length > bf.MaxLength {
+		issues = append(issues, fail(ValueTooLong, "value is longer than %d characters", bf.MaxLength)...)
+	}
+	return issues
+}
+
+var patternCache sync.Map
+
+// compilePattern compiles a validation pattern, caching the result.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patternCache.Store(pattern, re)
+	return re, nil
+}
+
+func dateFormatOrISO(format string) string {
+	if format == "" {
+		return ISODateFormat
+	}
+	return format
+}
+
+// isYearBound reports whether a date bound depends on the form year.
+func isYearBound(bound string) bool {
+	base, _, err := splitDateBound(bound)
+	return err == nil && (base == DateBoundTaxYearStart || base == DateBoundTaxYearEnd)
+}
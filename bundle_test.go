@@ -0,0 +1,163 @@
+package annotation
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// bundleForm has a field or group for each kind of rule a validation
+// bundle carries.
+func bundleForm() *FormAnnotation {
+	y := 36.0
+	field := func(id string, ft FieldType, dt DataType) Field {
+		y += 24
+		return Field{FieldID: id, FieldType: ft, DataType: dt, FieldValue: "taxpayer." + id,
+			Position: Position{X: 36, Y: y, Width: 200, Height: 18, Unit: "pt"}}
+	}
+	ssn := field("ssn", FieldTypeText, DataTypeString)
+	ssn.Value = "123-45-6789"
+	ssn.Validation = &Validation{Required: true, Pattern: `^\d{3}-\d{2}-\d{4}$`}
+	age := field("age", FieldTypeNumeric, DataTypeInteger)
+	age.Validation = &Validation{Min: 18, Max: 120}
+	name := field("name", FieldTypeText, DataTypeString)
+	name.Validation = &Validation{MinLength: 2, MaxLength: 10, Level: RequirementSoft}
+	status := field("status", FieldTypeChoice, DataTypeString)
+	status.Options = []ChoiceOption{{Value: "single"}, {Value: "joint"}}
+	spouse := field("spouse", FieldTypeText, DataTypeString)
+	spouse.Validation = &Validation{RequiredIf: `status == "joint"`}
+	spouseSSN := field("spouse_ssn", FieldTypeText, DataTypeString)
+	spouseSSN.Validation = &Validation{Required: true}
+	spouseSSN.Conditions = &Conditions{VisibleIf: `status == "joint"`}
+	dob := field("dob", FieldTypeDate, DataTypeDate)
+	dob.Validation = &Validation{MinDate: "1900-01-01", MaxDate: "2024-12-31"}
+	fields := []Field{ssn, age, name, status, spouse, spouseSSN, dob}
+	for _, id := range []string{"single", "joint_box", "yes", "no", "wages", "interest", "total"} {
+		ft, dt := FieldTypeCheckbox, DataTypeBoolean
+		if id == "wages" || id == "interest" || id == "total" {
+			ft, dt = FieldTypeCurrency, DataTypeDecimal
+		}
+		fields = append(fields, field(id, ft, dt))
+	}
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", Year: 2024, PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages:        []Page{{PageNumber: 1, Fields: fields}},
+		FieldGroups: []FieldGroup{
+			{GroupID: "filing", GroupType: GroupTypeRadio, FieldIDs: []string{"single", "joint_box"}},
+			{GroupID: "question", GroupType: GroupTypeYesNo, FieldIDs: []string{"yes", "no"}, Required: true},
+			{GroupID: "income", GroupType: GroupTypeTable, FieldIDs: []string{"wages", "interest"},
+				Validation: &GroupValidation{SumEquals: "total"}},
+		},
+	}
+}
+
+// bundleCases are checked through both ValidateValues and a bundle, so
+// that the server and the reference client cannot drift apart. Each issue
+// is written as field or group ID, a colon and the code.
+var bundleCases = []struct {
+	name   string
+	values map[string]string
+	want   []string
+}{
+	{"valid", map[string]string{"ssn": "123-45-6789", "age": "40", "name": "Ada", "status": "single", "yes": "true",
+		"dob": "1980-02-29", "wages": "10", "interest": "5", "total": "15"}, nil},
+	{"empty", map[string]string{}, []string{"name:required", "question:required", "ssn:required"}},
+	{"pattern", map[string]string{"ssn": "123456789", "name": "Ada", "yes": "true"}, []string{"ssn:pattern"}},
+	{"bounds", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "age": "17"}, []string{"age:min"}},
+	{"above max", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "age": "121"}, []string{"age:max"}},
+	{"not integer", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "age": "40.5"}, []string{"age:not_integer"}},
+	{"not number", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "age": "forty"}, []string{"age:not_number"}},
+	{"lengths", map[string]string{"ssn": "123-45-6789", "name": "A", "yes": "true"}, []string{"name:min_length"}},
+	{"too long", map[string]string{"ssn": "123-45-6789", "name": "Adalovelace", "yes": "true"}, []string{"name:max_length"}},
+	{"option", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "status": "widowed"}, []string{"status:not_option"}},
+	{"required if", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "status": "joint"},
+		[]string{"spouse:required", "spouse_ssn:required"}},
+	{"dates", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "dob": "1899-12-31"}, []string{"dob:min_date"}},
+	{"after max date", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "dob": "2025-01-01"}, []string{"dob:max_date"}},
+	{"bad date", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "dob": "1980-02-30"}, []string{"dob:not_date"}},
+	{"radio", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "single": "true", "joint_box": "true"},
+		[]string{"filing:multiple_checked"}},
+	{"yes and no", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "no": "true"}, []string{"question:multiple_checked"}},
+	{"sum", map[string]string{"ssn": "123-45-6789", "name": "Ada", "yes": "true", "wages": "10", "interest": "5", "total": "16"},
+		[]string{"total:sum_mismatch"}},
+}
+
+func issueKeys(issues []ValidationIssue) []string {
+	var keys []string
+	for _, issue := range issues {
+		id := issue.FieldID
+		if id == "" {
+			id = issue.GroupID
+		}
+		keys = append(keys, id+":"+issue.Code)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// withoutPages drops the page numbers ValidateValues adds, which a bundle
+// does not carry.
+func withoutPages(issues []ValidationIssue) []ValidationIssue {
+	var out []ValidationIssue
+	for _, issue := range issues {
+		issue.Page = 0
+		out = append(out, issue)
+	}
+	return out
+}
+
+func TestValidateWithBundleMatchesValidateValues(t *testing.T) {
+	fa := bundleForm()
+	bundle, err := fa.ExportValidationBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range bundleCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fa.ValidateValues(tt.values)
+			client, err := ValidateWithBundle(bundle, tt.values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := issueKeys(server.Issues); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateValues issues = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(withoutPages(server.Issues), client.Issues) {
+				t.Errorf("ValidateValues and ValidateWithBundle disagree:\n%v\n%v", server.Issues, client.Issues)
+			}
+		})
+	}
+}
+
+func TestExportValidationBundle(t *testing.T) {
+	fa := bundleForm()
+	data, err := fa.ExportValidationBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{`"position"`, "123-45-6789", `"style"`} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("bundle contains %s", leak)
+		}
+	}
+	digest, err := fa.StructuralHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := fa.validationBundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundle.Digest != digest || bundle.Version != ValidationBundleVersion {
+		t.Errorf("digest %q version %d, want %q %d", bundle.Digest, bundle.Version, digest, ValidationBundleVersion)
+	}
+
+	future := strings.Replace(string(data), `"bundle_version":1`, `"bundle_version":99`, 1)
+	if _, err := ValidateWithBundle([]byte(future), nil); err == nil {
+		t.Error("a newer bundle version was accepted")
+	}
+	if _, err := ValidateWithBundle([]byte("{"), nil); err == nil {
+		t.Error("a malformed bundle was accepted")
+	}
+}
@@ -0,0 +1,197 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Calculation computes a field's value from other fields.
+type Calculation struct {
+	// Expr is a rule expression over field IDs, such as
+	// "line_1a + line_1b - line_2" or "sum(line_1, line_2, line_3)".
+	Expr string `json:"expr"`
+}
+
+// Calculation issue codes.
+const (
+	// FillCalculationFailed is reported by Recalculate for a calculation
+	// that does not parse or evaluate, or whose result the field cannot hold.
+	FillCalculationFailed = "calculation_failed"
+	// CalculationCycle is reported by Validate for calculations that depend
+	// on their own result.
+	CalculationCycle = "calculation_cycle"
+)
+
+// calcNode is one calculated field.
+type calcNode struct {
+	field *Field
+	page  int
+	expr  *Expr
+	err   error
+}
+
+// calculations parses every calculated field and orders them so that each
+// follows the calculated fields it references. Fields on a dependency
+// cycle are returned separately, each cycle once, in document order.
+func (fa *FormAnnotation) calculations() (order []*calcNode, cycles [][]string) {
+	byID := map[string]*calcNode{}
+	var nodes []*calcNode
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			f := &fa.Pages[i].Fields[j]
+			if f.Calculation == nil {
+				continue
+			}
+			n := &calcNode{field: f, page: fa.Pages[i].PageNumber}
+			n.expr, n.err = ParseExprWithLimits(f.Calculation.Expr, fa.exprLimits())
+			n.err = forField(n.err, f.FieldID)
+			nodes = append(nodes, n)
+			byID[f.FieldID] = n
+		}
+	}
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[*calcNode]int{}
+	var stack []*calcNode
+	var visit func(n *calcNode)
+	visit = func(n *calcNode) {
+		switch state[n] {
+		case done:
+			return
+		case visiting:
+			at := slices.Index(stack, n)
+			var ids []string
+			for _, m := range stack[at:] {
+				ids = append(ids, m.field.FieldID)
+			}
+			cycles = append(cycles, append(ids, n.field.FieldID))
+			return
+		}
+		state[n] = visiting
+		stack = append(stack, n)
+		if n.expr != nil {
+			for _, ref := range n.expr.Refs() {
+				if dep := byID[ref]; dep != nil {
+					visit(dep)
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		order = append(order, n)
+	}
+	for _, n := range nodes {
+		visit(n)
+	}
+	return order, cycles
+}
+
+// Recalculate sets the value of every field with a Calculation, in
+// dependency order so that totals of totals see fresh inputs. Expressions
+// are evaluated as exact decimals under the annotation's expression
+// limits, with empty values counting as zero, rounded by
+// FormMetadata.Rounding when it is set, and stored in the canonical form of
+// the field's data type; boolean fields take the expression's truthiness.
+// Read-only fields are recalculated too.
+//
+// Calculations that fail, including those on a dependency cycle, leave
+// their field unchanged and are reported as FillCalculationFailed issues.
+func (fa *FormAnnotation) Recalculate() *FillReport {
+	report := &FillReport{}
+	values := map[string]string{}
+	for _, field := range fa.Fields() {
+		values[field.FieldID] = field.Value
+	}
+	lookup := func(id string) string { return values[id] }
+	order, cycles := fa.calculations()
+	cyclic := map[string]bool{}
+	for _, c := range cycles {
+		for _, id := range c {
+			cyclic[id] = true
+		}
+	}
+	for _, n := range order {
+		fail := func(format string, args ...any) {
+			report.add(FillIssue{
+				FieldID:  n.field.FieldID,
+				Page:     n.page,
+				Code:     FillCalculationFailed,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+		if cyclic[n.field.FieldID] {
+			fail("calculation depends on its own result")
+			continue
+		}
+		if n.err != nil {
+			fail("calculation: %v", n.err)
+			continue
+		}
+		var result any
+		if n.field.DataType == DataTypeBoolean {
+			b, err := n.expr.EvalBool(lookup)
+			if err != nil {
+				fail("calculation: %v", forField(err, n.field.FieldID))
+				continue
+			}
+			result = b
+		} else {
+			r, err := n.expr.EvalNumber(lookup)
+			if err != nil {
+				fail("calculation: %v", forField(err, n.field.FieldID))
+				continue
+			}
+			if rounding := fa.FormMetadata.Rounding; rounding != nil && (n.field.DataType == DataTypeDecimal || n.field.DataType == DataTypeInteger) {
+				r = rounding.Round(r)
+			}
+			result = r
+			if n.field.DataType != DataTypeDecimal && n.field.DataType != DataTypeInteger {
+				result = decimalString(r)
+			}
+		}
+		value, err := canonicalValue(n.field, result)
+		if err != nil {
+			fail("calculation: %v", err)
+			continue
+		}
+		n.field.Value = value
+		values[n.field.FieldID] = value
+		report.Filled = append(report.Filled, n.field.FieldID)
+	}
+	return report
+}
+
+// checkCalculations reports calculations that do not parse, reference
+// unknown fields, or depend on their own result.
+func (fa *FormAnnotation) checkCalculations() []ValidationIssue {
+	var issues []ValidationIssue
+	order, cycles := fa.calculations()
+	for _, n := range order {
+		at := ValidationIssue{FieldID: n.field.FieldID, Page: n.page, Severity: SeverityError}
+		if n.err != nil {
+			at.Code, at.Message = RuleInvalid, fmt.Sprintf("calculation: %v", n.err)
+			issues = append(issues, at)
+			continue
+		}
+		for _, ref := range n.expr.Refs() {
+			if fa.GetFieldByID(ref) == nil {
+				at.Code, at.Message = UnknownReference, fmt.Sprintf("calculation references unknown field %q", ref)
+				issues = append(issues, at)
+			}
+		}
+	}
+	for _, c := range cycles {
+		issues = append(issues, ValidationIssue{
+			FieldID:  c[0],
+			Code:     CalculationCycle,
+			Severity: SeverityError,
+			Message:  "calculation cycle: " + strings.Join(c, " -> "),
+		})
+	}
+	return issues
+}
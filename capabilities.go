@@ -0,0 +1,250 @@
+package annotation
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Capability names an optional schema feature a consumer may or may not support.
+type Capability string
+
+const (
+	CapNumericFields           Capability = "numeric_fields"
+	CapSegmentedFields         Capability = "segmented_fields"
+	CapSignatureFields         Capability = "signature_fields"
+	CapVirtualFields           Capability = "virtual_fields"
+	CapFieldGroups             Capability = "field_groups"
+	CapFormatting              Capability = "formatting"
+	CapConditionalRequirements Capability = "conditional_requirements"
+	CapFilledValues            Capability = "filled_values"
+	CapSensitiveFields         Capability = "sensitive_fields"
+	CapRequirementLevels       Capability = "requirement_levels"
+	CapReadOnlyFields          Capability = "read_only_fields"
+	CapOptionCodes             Capability = "option_codes"
+	CapFractionalSizes         Capability = "fractional_sizes"
+	CapHelpContent             Capability = "help_content"
+	CapEncryptedValues         Capability = "encrypted_values"
+	CapRenderOverrides         Capability = "render_overrides"
+	CapYearRanges              Capability = "year_ranges"
+	CapValueTransforms         Capability = "value_transforms"
+	CapPageIncludes            Capability = "page_includes"
+	CapYesNoGroups             Capability = "yes_no_groups"
+	CapCoordinateFrames        Capability = "coordinate_frames"
+	CapPacketParts             Capability = "packet_parts"
+	CapCoordinateOrigins       Capability = "coordinate_origins"
+	CapSegmentLayout           Capability = "segment_layout"
+	CapTabOrder                Capability = "tab_order"
+	CapProvenance              Capability = "provenance"
+	CapCalculations            Capability = "calculations"
+	CapConditionalVisibility   Capability = "conditional_visibility"
+	CapRepeatingRows           Capability = "repeating_rows"
+	CapChoiceFields            Capability = "choice_fields"
+	CapRelativePositions       Capability = "relative_positions"
+	CapLocalizedLabels         Capability = "localized_labels"
+	CapBarcodeFields           Capability = "barcode_fields"
+	CapImageFields             Capability = "image_fields"
+	CapRoundingPolicy          Capability = "rounding_policy"
+	CapTemplateInheritance     Capability = "template_inheritance"
+	CapSignatureWorkflow       Capability = "signature_workflow"
+	CapPageLayout              Capability = "page_layout"
+	CapGroupValidation         Capability = "group_validation"
+)
+
+// capabilityDetectors decides, by inspecting the document, which optional
+// features an annotation uses. Every new optional schema construct needs a
+// row here so that consumers can refuse documents they cannot honor.
+var capabilityDetectors = []struct {
+	capability Capability
+	detect     func(fa *FormAnnotation) bool
+}{
+	{CapNumericFields, anyField(func(f *Field) bool {
+		return f.FieldType == FieldTypeCurrency || f.FieldType == FieldTypeNumeric
+	})},
+	{CapSegmentedFields, anyField(func(f *Field) bool {
+		return f.FieldType == FieldTypeSegmented || len(f.Segments) > 0
+	})},
+	{CapSignatureFields, anyField(func(f *Field) bool { return f.FieldType == FieldTypeSignature })},
+	{CapVirtualFields, anyField(func(f *Field) bool { return f.IsVirtual() })},
+	{CapFieldGroups, func(fa *FormAnnotation) bool { return len(fa.FieldGroups) > 0 }},
+	{CapFormatting, anyField(func(f *Field) bool { return f.Formatting != nil })},
+	{CapConditionalRequirements, anyField(func(f *Field) bool {
+		return f.Validation != nil && f.Validation.RequiredIf != ""
+	})},
+	{CapFilledValues, anyField(func(f *Field) bool { return f.Value != "" })},
+	{CapSensitiveFields, anyField(func(f *Field) bool { return f.Sensitive || f.Sensitivity != "" })},
+	{CapRequirementLevels, anyField(func(f *Field) bool { return f.Validation != nil && f.Validation.Level != "" })},
+	{CapReadOnlyFields, anyField(func(f *Field) bool { return f.ReadOnly })},
+	{CapOptionCodes, func(fa *FormAnnotation) bool {
+		for _, g := range fa.FieldGroups {
+			if len(g.ExpectedOptions) > 0 {
+				return true
+			}
+		}
+		return anyField(func(f *Field) bool { return f.OptionCode != "" })(fa)
+	}},
+	{CapFractionalSizes, anyField(func(f *Field) bool {
+		return (f.Style != nil && f.Style.FontSize != math.Trunc(f.Style.FontSize)) ||
+			(f.CheckStyle != nil && f.CheckStyle.MarkSize != math.Trunc(f.CheckStyle.MarkSize))
+	})},
+	{CapHelpContent, anyField(func(f *Field) bool { return f.Help != nil })},
+	{CapEncryptedValues, anyField(func(f *Field) bool { return f.IsEncrypted() })},
+	{CapRenderOverrides, func(fa *FormAnnotation) bool {
+		return len(fa.FormMetadata.RenderTargets) > 0 || anyField(func(f *Field) bool { return len(f.Overrides) > 0 })(fa)
+	}},
+	{CapYearRanges, anyField(func(f *Field) bool { return f.IntroducedYear != nil || f.RetiredYear != nil })},
+	{CapValueTransforms, anyField(func(f *Field) bool { return len(f.Transforms) > 0 })},
+	{CapPageIncludes, func(fa *FormAnnotation) bool { return fa.HasIncludes() }},
+	{CapCoordinateFrames, func(fa *FormAnnotation) bool { return fa.HasCoordinateFrames() }},
+	{CapPacketParts, func(fa *FormAnnotation) bool { return len(fa.FormMetadata.Parts) > 0 }},
+	{CapTabOrder, anyField(func(f *Field) bool { return f.TabIndex != 0 })},
+	{CapSegmentLayout, anyField(func(f *Field) bool { return f.segmentLayout() })},
+	{CapProvenance, func(fa *FormAnnotation) bool {
+		return len(fa.History) > 0 || fa.FormMetadata.hasProvenance() || anyField(func(f *Field) bool { return f.Provenance != nil })(fa)
+	}},
+	{CapCalculations, anyField(func(f *Field) bool { return f.Calculation != nil })},
+	{CapConditionalVisibility, anyField(func(f *Field) bool { return f.Conditions != nil })},
+	{CapChoiceFields, anyField(func(f *Field) bool { return f.FieldType == FieldTypeChoice || len(f.Options) > 0 })},
+	{CapLocalizedLabels, anyField(func(f *Field) bool { return f.Tooltip != "" || len(f.Localized) > 0 })},
+	{CapBarcodeFields, anyField(func(f *Field) bool { return f.FieldType == FieldTypeBarcode || f.Barcode != nil })},
+	{CapImageFields, anyField(func(f *Field) bool { return f.FieldType == FieldTypeImage || f.Image != nil })},
+	{CapRoundingPolicy, func(fa *FormAnnotation) bool { return fa.FormMetadata.Rounding != nil }},
+	{CapTemplateInheritance, func(fa *FormAnnotation) bool { return fa.IsOverlay() }},
+	{CapSignatureWorkflow, anyField(func(f *Field) bool { return f.Signature != nil })},
+	{CapPageLayout, func(fa *FormAnnotation) bool {
+		for _, p := range fa.Pages {
+			if p.Rotation != 0 || p.PageSize != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{CapRelativePositions, func(fa *FormAnnotation) bool {
+		for _, p := range fa.Pages {
+			if len(p.Anchors) > 0 {
+				return true
+			}
+		}
+		return anyField(func(f *Field) bool { return f.RelativeTo != nil })(fa)
+	}},
+	{CapGroupValidation, func(fa *FormAnnotation) bool {
+		for _, g := range fa.FieldGroups {
+			if g.Validation != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{CapRepeatingRows, func(fa *FormAnnotation) bool {
+		for _, g := range fa.FieldGroups {
+			if g.Repeat != nil {
+				return true
+			}
+		}
+		return false
+	}},
+	{CapCoordinateOrigins, func(fa *FormAnnotation) bool { return fa.origin() != OriginTopLeft }},
+	{CapYesNoGroups, func(fa *FormAnnotation) bool {
+		for _, g := range fa.FieldGroups {
+			if g.GroupType == GroupTypeYesNo || g.Required {
+				return true
+			}
+		}
+		return false
+	}},
+}
+
+// anyField builds a detector that reports whether any field satisfies pred.
+func anyField(pred func(f *Field) bool) func(fa *FormAnnotation) bool {
+	return func(fa *FormAnnotation) bool {
+		for i := range fa.Pages {
+			for j := range fa.Pages[i].Fields {
+				if pred(&fa.Pages[i].Fields[j]) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// CapabilitySet is a set of capabilities.
+type CapabilitySet map[Capability]bool
+
+// NewCapabilitySet returns a set holding caps.
+func NewCapabilitySet(caps ...Capability) CapabilitySet {
+	set := CapabilitySet{}
+	for _, c := range caps {
+		set[c] = true
+	}
+	return set
+}
+
+// Has reports whether c is in the set.
+func (s CapabilitySet) Has(c Capability) bool {
+	return s[c]
+}
+
+// List returns the capabilities in the set in sorted order.
+func (s CapabilitySet) List() []Capability {
+	list := make([]Capability, 0, len(s))
+	for c, ok := range s {
+		if ok {
+			list = append(list, c)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
+}
+
+// KnownCapabilities returns every capability the package can detect.
+func KnownCapabilities() CapabilitySet {
+	set := CapabilitySet{}
+	for _, d := range capabilityDetectors {
+		set[d.capability] = true
+	}
+	return set
+}
+
+// Capabilities reports which optional features the annotation actually uses.
+// The set is derived by inspecting the document, not from any declaration in it.
+func (fa *FormAnnotation) Capabilities() CapabilitySet {
+	set := CapabilitySet{}
+	for _, d := range capabilityDetectors {
+		if d.detect(fa) {
+			set[d.capability] = true
+		}
+	}
+	return set
+}
+
+// UnsupportedCapabilitiesError lists the features a document uses that the
+// caller declared it cannot honor.
+type UnsupportedCapabilitiesError struct {
+	FormID      string
+	Unsupported []Capability
+}
+
+func (e *UnsupportedCapabilitiesError) Error() string {
+	names := make([]string, len(e.Unsupported))
+	for i, c := range e.Unsupported {
+		names[i] = string(c)
+	}
+	return fmt.Sprintf("form %q uses unsupported features: %s", e.FormID, strings.Join(names, ", "))
+}
+
+// RequireCapabilities returns an *UnsupportedCapabilitiesError when the
+// annotation uses any feature outside supported.
+func (fa *FormAnnotation) RequireCapabilities(supported CapabilitySet) error {
+	var missing []Capability
+	for _, c := range fa.Capabilities().List() {
+		if !supported.Has(c) {
+			missing = append(missing, c)
+		}
+	}
+	if len(missing) > 0 {
+		return &UnsupportedCapabilitiesError{FormID: fa.FormMetadata.FormID, Unsupported: missing}
+	}
+	return nil
+}
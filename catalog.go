@@ -0,0 +1,156 @@
+package annotation
+
+import "sort"
+
+// Error code categories.
+const (
+	CategoryStructure = "structure"
+	CategoryMetadata  = "metadata"
+	CategoryValue     = "value"
+	CategoryFill      = "fill"
+	CategoryLibrary   = "library"
+)
+
+// ErrorCodeInfo describes one issue code the package can emit.
+type ErrorCodeInfo struct {
+	Code     string   `json:"code"`
+	Category string   `json:"category"`
+	Severity Severity `json:"severity"`
+	// Description explains the problem and how to resolve it.
+	Description string `json:"description"`
+	// Repair names the exported function that fixes the issue, if any.
+	Repair string `json:"repair,omitempty"`
+}
+
+// Fixable reports whether a built-in repair resolves the issue.
+func (i ErrorCodeInfo) Fixable() bool { return i.Repair != "" }
+
+// ErrorDocBaseURL, when set, is the page DocURL links issue codes to, as
+// ErrorDocBaseURL + "#" + code.
+var ErrorDocBaseURL string
+
+// errorCatalog lists every issue code with its default severity. Some codes
+// are reported at a lower severity in context, such as soft requirements.
+var errorCatalog = []ErrorCodeInfo{
+	{EmptyFieldID, CategoryStructure, SeverityError, "A field has no field_id; give it a unique ID.", ""},
+	{DuplicateFieldID, CategoryStructure, SeverityError, "Two fields share a field_id; rename one of them.", ""},
+	{CaseDuplicateFieldID, CategoryStructure, SeverityWarning, "Two field IDs differ only by case; they collide under case-insensitive lookups.", ""},
+	{MissingGroupRef, CategoryStructure, SeverityError, "A field names a group_id that no field group defines; add the group or fix the reference.", ""},
+	{UnknownGroupMember, CategoryStructure, SeverityError, "A field group lists a member that is not a field; remove it or fix the ID.", ""},
+	{UnknownReference, CategoryStructure, SeverityError, "A condition or calculation references a field that does not exist.", ""},
+	{CalculationCycle, CategoryStructure, SeverityError, "Calculations depend on their own result; break the cycle.", ""},
+	{InvalidFontSize, CategoryStructure, SeverityError, "A text style has a negative font size; use zero for the default or a positive size.", ""},
+	{SmallFontSize, CategoryStructure, SeverityWarning, "A font size is too small to read once printed.", ""},
+	{InvalidMarkSize, CategoryStructure, SeverityError, "A checkbox mark size is not positive.", ""},
+	{UnknownHelpReference, CategoryStructure, SeverityWarning, "Help content links to a field that does not exist.", ""},
+	{UnknownRenderTarget, CategoryStructure, SeverityError, "A render override names a target the form does not declare; add it to render_targets.", ""},
+	{OverrideOffPage, CategoryStructure, SeverityError, "A render override moves the field off the page; reduce the offset.", ""},
+	{InvalidYearRange, CategoryStructure, SeverityError, "A field's year range is implausible or ends before it starts.", ""},
+	{FieldInactiveForYear, CategoryStructure, SeverityWarning, "A field's year range excludes the form's own year; materialize the form for its year.", "ForYear"},
+	{MalformedLineRef, CategoryStructure, SeverityWarning, "A field's irs_line_reference starts like a line reference but does not parse as one; write it as \"Schedule 1, Part II, Line 10b\".", ""},
+	{UnknownTransform, CategoryStructure, SeverityError, "A field names a value transform that is not registered.", ""},
+	{PageCountMismatch, CategoryStructure, SeverityError, "The page_count in the metadata differs from the number of pages.", ""},
+	{InvalidPosition, CategoryStructure, SeverityError, "A field's position has a negative width or height, or a unit that is not known.", ""},
+	{SegmentsMissing, CategoryStructure, SeverityError, "A segmented field lists no segments.", ""},
+	{UnknownFieldType, CategoryStructure, SeverityError, "A field's field_type is not one of the known types.", ""},
+	{UnknownDataType, CategoryStructure, SeverityError, "A field's data_type is not one of the known types.", ""},
+	{PositionOffPage, CategoryStructure, SeverityError, "A field or one of its segments extends beyond the page; move or resize it.", ""},
+	{IncompatibleDataType, CategoryStructure, SeverityError, "A field's data type makes no sense for its field type, such as a checkbox holding a decimal.", ""},
+	{YesNoMemberCount, CategoryStructure, SeverityError, "A yes/no group must have exactly a Yes and a No member.", ""},
+	{InvalidCoordinateFrame, CategoryStructure, SeverityError, "A coordinate frame has an unknown origin or negative margins.", ""},
+	{InvalidRounding, CategoryStructure, SeverityError, "The rounding policy names an unknown mode or a negative number of places.", ""},
+	{InvalidPageLayout, CategoryStructure, SeverityError, "A page rotation is not 0, 90, 180 or 270, or a page size override is not positive, names another unit than the document's, or declares a coordinate frame.", ""},
+	{InvalidCoordinateOrigin, CategoryStructure, SeverityError, "The coordinate origin is unknown, or is bottom-left on a document with a coordinate frame.", ""},
+	{MixedCoordinateFrames, CategoryStructure, SeverityError, "Pages are measured in different coordinate frames; normalize them to the media box.", "NormalizeToMediaBox"},
+	{GroupOptionsUndeclared, CategoryStructure, SeverityWarning, "A radio group declares no expected_options, so its completeness cannot be checked.", ""},
+	{GroupOptionCount, CategoryStructure, SeverityError, "A radio group's member count differs from its expected options.", ""},
+	{MissingOptionCode, CategoryStructure, SeverityError, "A radio group member has no option_code.", ""},
+	{UnexpectedOptionCode, CategoryStructure, SeverityError, "A member's option_code is not one of the group's expected options.", ""},
+	{DuplicateOptionCode, CategoryStructure, SeverityError, "Two members of a radio group share an option_code.", ""},
+	{MissingOption, CategoryStructure, SeverityError, "No member of a radio group represents one of its expected options.", ""},
+	{InvalidGroupValidation, CategoryStructure, SeverityError, "A group's sum constraint names a missing field, or sums into or over fields that do not hold decimals or integers.", ""},
+	{InvalidRepeat, CategoryStructure, SeverityError, "A repeating group has no template, a template field outside the group, or a row spacing that is not positive.", ""},
+	{ChoiceWithoutOptions, CategoryStructure, SeverityError, "A choice field declares no options; list them or make it a text field.", ""},
+	{DuplicateChoiceOption, CategoryStructure, SeverityError, "A field declares the same option value twice.", ""},
+	{InvalidRelativePosition, CategoryStructure, SeverityError, "An anchor is unnamed or declared twice, or a relative_to block names no reference, an unknown one, a field on another page, or a chain back to the field.", ""},
+	{InvalidBarcode, CategoryStructure, SeverityError, "A barcode field has no known symbology or a template that does not parse or names an unknown field, or another field type carries barcode settings.", ""},
+	{InvalidSensitivity, CategoryStructure, SeverityError, "A field's sensitivity is not one of the known kinds of personal data.", ""},
+	{InvalidSignature, CategoryStructure, SeverityError, "Signature settings are on a field that is not a signature, or name an unknown signer role or a date field that is missing or not a date.", ""},
+	{InvalidImage, CategoryStructure, SeverityError, "An image field has an unknown fit, or another field type carries image settings.", ""},
+	{InvalidLocalization, CategoryStructure, SeverityError, "Localized labels are keyed by a malformed or repeated language tag, or hold neither a label nor a tooltip.", ""},
+	{InvalidTabOrder, CategoryStructure, SeverityError, "A page's tab indexes are not 1 through its field count: one is missing, repeated, negative or out of range, or set on a virtual field.", "ApplyTabOrder"},
+	{RuleInvalid, CategoryStructure, SeverityError, "A rule expression or date bound does not parse, or an expression exceeds the expression limits.", ""},
+	{FieldsOverlap, CategoryStructure, SeverityWarning, "Two fields on a page overlap; move or resize one unless the form prints them nested.", ""},
+	{ZeroSizePosition, CategoryStructure, SeverityError, "A field or segment has no width or height, so nothing can be drawn in it.", ""},
+	{SegmentsOverlap, CategoryStructure, SeverityError, "Two segments of a segmented field overlap; respace them.", ""},
+
+	{MetadataMissingFormID, CategoryMetadata, SeverityError, "The form has no form_id.", ""},
+	{MetadataFormIDPattern, CategoryMetadata, SeverityError, "The form_id does not match the required pattern.", ""},
+	{MetadataInvalidYear, CategoryMetadata, SeverityError, "The form year is missing or outside the supported range.", ""},
+	{MetadataMissingFormName, CategoryMetadata, SeverityError, "The form has no form_name.", ""},
+	{MetadataUnknownForm, CategoryMetadata, SeverityError, "The form_id is not in the form registry.", ""},
+	{MetadataNameMismatch, CategoryMetadata, SeverityError, "The form_name differs from the registry's name for the form.", ""},
+	{MetadataPageCount, CategoryMetadata, SeverityError, "The page_count differs from the registry's page count for the form.", ""},
+	{MetadataPageSize, CategoryMetadata, SeverityError, "The page size is not positive or its unit is unknown.", ""},
+	{MetadataProvenance, CategoryMetadata, SeverityError, "The revision is negative, the source PDF hash is not a sha256:<hex> digest, or updated_at is before created_at.", ""},
+
+	{ValueRequired, CategoryValue, SeverityError, "A required field or yes/no group has no value.", ""},
+	{ValuePattern, CategoryValue, SeverityError, "A value does not match the field's pattern.", ""},
+	{ValueBelowMin, CategoryValue, SeverityError, "A number is below the field's minimum.", ""},
+	{ValueAboveMax, CategoryValue, SeverityError, "A number is above the field's maximum.", ""},
+	{ValueTooShort, CategoryValue, SeverityError, "A value is shorter than the field's minimum length.", ""},
+	{ValueTooLong, CategoryValue, SeverityError, "A value is longer than the field's maximum length.", ""},
+	{ValueNotNumber, CategoryValue, SeverityError, "A decimal field holds something that is not a number.", ""},
+	{ValueNotInteger, CategoryValue, SeverityError, "An integer field holds something that is not a whole number.", ""},
+	{ValueNotBoolean, CategoryValue, SeverityError, "A boolean field holds something that is not a yes/no value.", ""},
+	{ValueNotDate, CategoryValue, SeverityError, "A date field holds something that is not a date in its format.", ""},
+	{ValueBeforeMinDate, CategoryValue, SeverityError, "A date is before the field's earliest allowed date.", ""},
+	{ValueAfterMaxDate, CategoryValue, SeverityError, "A date is after the field's latest allowed date.", ""},
+	{ValueNotOption, CategoryValue, SeverityError, "A choice field holds a value that is not one of its options.", ""},
+	{GroupMultipleChecked, CategoryValue, SeverityError, "More than one option of an exclusive group is checked, or more than one member of an at-most-one group is filled.", ""},
+	{GroupPartiallyFilled, CategoryValue, SeverityError, "Some but not all members of an all-or-none group are filled.", ""},
+	{GroupSumMismatch, CategoryValue, SeverityError, "A group's sum field does not hold the sum of the members' values.", ""},
+	{ValueUndated, CategoryValue, SeverityError, "A signature is signed but its date field is empty.", ""},
+	{ValueUnsigned, CategoryValue, SeverityWarning, "A signature's date field is filled but the signature is not.", ""},
+
+	{FillUnknownField, CategoryFill, SeverityError, "A value was supplied for a field the form does not have.", ""},
+	{FillUnplaceableField, CategoryFill, SeverityError, "A field's geometry cannot hold the value; fix its position or size.", ""},
+	{FillUnsupportedValue, CategoryFill, SeverityError, "A supplied value has a type or option the field cannot take.", ""},
+	{FillReadOnlyField, CategoryFill, SeverityError, "A value was supplied for a read-only field.", ""},
+	{FillTransformFailed, CategoryFill, SeverityError, "A field's value transform rejected the supplied value.", ""},
+	{FillHookFailed, CategoryFill, SeverityError, "A field set hook registered with OnFieldSet refused the supplied value.", ""},
+	{FillCalculationFailed, CategoryFill, SeverityError, "A field's calculation does not evaluate, or its result does not fit the field's data type.", ""},
+	{FillUnmatchedKey, CategoryFill, SeverityError, "A value was supplied for a value path no field or group is bound to.", ""},
+	{FillTypeMismatch, CategoryFill, SeverityError, "A supplied value's type does not match the field's data type; fix the data or fill with a lenient coercion policy.", ""},
+
+	{LibraryFormIDCollision, CategoryLibrary, SeverityError, "Two forms of the same year have IDs that differ only by case or spacing.", ""},
+	{LibraryUnknownTemplate, CategoryLibrary, SeverityError, "A page includes a template the library does not hold.", ""},
+	{LibraryUnusedTemplate, CategoryLibrary, SeverityWarning, "A library template is not included by any form.", ""},
+	{LibraryDataTypeMismatch, CategoryLibrary, SeverityError, "A value path has a different data type on this form than on most others.", ""},
+}
+
+// ErrorCatalog returns every issue code the package can emit, sorted by code.
+func ErrorCatalog() []ErrorCodeInfo {
+	out := append([]ErrorCodeInfo(nil), errorCatalog...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Code < out[j].Code })
+	return out
+}
+
+// LookupErrorCode returns the catalog entry for code.
+func LookupErrorCode(code string) (ErrorCodeInfo, bool) {
+	for _, info := range errorCatalog {
+		if info.Code == code {
+			return info, true
+		}
+	}
+	return ErrorCodeInfo{}, false
+}
+
+// DocURL returns the documentation link for the issue's code, or "" when
+// ErrorDocBaseURL is not set or the code is not catalogued.
+func (i ValidationIssue) DocURL() string {
+	if _, ok := LookupErrorCode(i.Code); !ok || ErrorDocBaseURL == "" {
+		return ""
+	}
+	return ErrorDocBaseURL + "#" + i.Code
+}
@@ -0,0 +1,166 @@
+package annotation
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// emittedCodes scans the package source for string constants used as an
+// issue code: as a Code field, assigned to one, or passed as the code
+// parameter of a function. It returns each code's constant name by value.
+func emittedCodes(t *testing.T) map[string]string {
+	t.Helper()
+	paths, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, file)
+	}
+	consts := map[string]string{}
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*ast.ValueSpec)
+				for i, name := range vs.Names {
+					if i >= len(vs.Values) {
+						continue
+					}
+					if lit, ok := vs.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						consts[name.Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			}
+		}
+	}
+
+	codes := map[string]string{}
+	record := func(e ast.Expr) {
+		if id, ok := e.(*ast.Ident); ok {
+			if value, ok := consts[id.Name]; ok && (id.Obj == nil || id.Obj.Kind == ast.Con) {
+				codes[value] = id.Name
+			}
+		}
+	}
+	// codeParam returns the index of the parameter called code of the
+	// function a call invokes, or -1.
+	codeParam := func(call *ast.CallExpr) int {
+		id, ok := call.Fun.(*ast.Ident)
+		if !ok || id.Obj == nil {
+			return -1
+		}
+		var params *ast.FieldList
+		switch decl := id.Obj.Decl.(type) {
+		case *ast.FuncDecl:
+			params = decl.Type.Params
+		case *ast.AssignStmt:
+			for i, lhs := range decl.Lhs {
+				if lhs, ok := lhs.(*ast.Ident); ok && lhs.Name == id.Name && i < len(decl.Rhs) {
+					if fn, ok := decl.Rhs[i].(*ast.FuncLit); ok {
+						params = fn.Type.Params
+					}
+				}
+			}
+		}
+		if params == nil {
+			return -1
+		}
+		index := 0
+		for _, field := range params.List {
+			for _, name := range field.Names {
+				if name.Name == "code" {
+					return index
+				}
+				index++
+			}
+		}
+		return -1
+	}
+	for _, file := range files {
+		ast.Inspect(file, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.KeyValueExpr:
+				if key, ok := n.Key.(*ast.Ident); ok && key.Name == "Code" {
+					record(n.Value)
+				}
+			case *ast.AssignStmt:
+				for i, lhs := range n.Lhs {
+					if i >= len(n.Rhs) {
+						break
+					}
+					switch lhs := lhs.(type) {
+					case *ast.SelectorExpr:
+						if lhs.Sel.Name == "Code" {
+							record(n.Rhs[i])
+						}
+					case *ast.Ident:
+						if lhs.Name == "code" {
+							record(n.Rhs[i])
+						}
+					}
+				}
+			case *ast.CallExpr:
+				if i := codeParam(n); i >= 0 && i < len(n.Args) {
+					record(n.Args[i])
+				}
+			}
+			return true
+		})
+	}
+	return codes
+}
+
+// TestErrorCatalogComplete checks that the catalogue lists exactly the
+// codes the package emits, so that adding a code without documenting it, or
+// leaving a retired one behind, fails here.
+func TestErrorCatalogComplete(t *testing.T) {
+	codes := emittedCodes(t)
+	var missing []string
+	for value, name := range codes {
+		if _, ok := LookupErrorCode(value); !ok {
+			missing = append(missing, name+" ("+value+")")
+		}
+	}
+	sort.Strings(missing)
+	for _, m := range missing {
+		t.Errorf("%s is emitted but not in errorCatalog", m)
+	}
+
+	seen := map[string]bool{}
+	for _, info := range errorCatalog {
+		if seen[info.Code] {
+			t.Errorf("%s is catalogued twice", info.Code)
+		}
+		seen[info.Code] = true
+		if _, ok := codes[info.Code]; !ok {
+			t.Errorf("%s is catalogued but never emitted", info.Code)
+		}
+		if info.Description == "" {
+			t.Errorf("%s has no description", info.Code)
+		}
+		switch info.Category {
+		case CategoryStructure, CategoryMetadata, CategoryValue, CategoryFill, CategoryLibrary:
+		default:
+			t.Errorf("%s has unknown category %q", info.Code, info.Category)
+		}
+	}
+}
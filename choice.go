@@ -0,0 +1,73 @@
+package annotation
+
+import "fmt"
+
+// Choice field structural issue codes.
+const (
+	ChoiceWithoutOptions  = "choice_without_options"
+	DuplicateChoiceOption = "duplicate_choice_option"
+)
+
+// option returns the declared option holding value, or nil.
+func (f *Field) option(value string) *ChoiceOption {
+	for i := range f.Options {
+		if f.Options[i].Value == value {
+			return &f.Options[i]
+		}
+	}
+	return nil
+}
+
+// optionValues returns the values of the field's options.
+func (f *Field) optionValues() []string {
+	if len(f.Options) == 0 {
+		return nil
+	}
+	values := make([]string, len(f.Options))
+	for i, o := range f.Options {
+		values[i] = o.Value
+	}
+	return values
+}
+
+// optionLabel is what a choice shows for value: the option's label when
+// it declares one, and value itself otherwise.
+func (f *Field) optionLabel(value string) string {
+	if o := f.option(value); o != nil && o.Label != "" {
+		return o.Label
+	}
+	return value
+}
+
+// exportValue is the PDF form data value of a choice: the option's export
+// value when it declares one, and value itself otherwise.
+func (f *Field) exportValue(value string) string {
+	if o := f.option(value); o != nil && o.ExportValue != "" {
+		return o.ExportValue
+	}
+	return value
+}
+
+// checkChoices reports choice fields without options and options declared
+// twice on a field.
+func (fa *FormAnnotation) checkChoices() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, page := range fa.Pages {
+		for _, field := range page.Fields {
+			at := ValidationIssue{Severity: SeverityError, FieldID: field.FieldID, Page: page.PageNumber}
+			if field.FieldType == FieldTypeChoice && len(field.Options) == 0 {
+				at.Code, at.Message = ChoiceWithoutOptions, "choice field declares no options"
+				issues = append(issues, at)
+			}
+			seen := map[string]bool{}
+			for _, o := range field.Options {
+				if seen[o.Value] {
+					at.Code, at.Message = DuplicateChoiceOption, fmt.Sprintf("option %q is declared twice", o.Value)
+					issues = append(issues, at)
+				}
+				seen[o.Value] = true
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,171 @@
+package annotation
+
+import (
+	"fmt"
+	"maps"
+)
+
+// Clone returns a deep copy of the annotation. Nothing in the copy, including
+// the optional style, formatting and validation blocks, aliases the original.
+func (fa *FormAnnotation) Clone() *FormAnnotation {
+	if fa == nil {
+		return nil
+	}
+	out := &FormAnnotation{FormMetadata: fa.FormMetadata, CaseInsensitiveIDs: fa.CaseInsensitiveIDs, ExprLimits: clonePtr(fa.ExprLimits)}
+	out.FormMetadata.NameMapping = fa.FormMetadata.NameMapping.clone()
+	out.FormMetadata.RenderTargets = cloneSlice(fa.FormMetadata.RenderTargets)
+	out.FormMetadata.RequiredCapabilities = cloneSlice(fa.FormMetadata.RequiredCapabilities)
+	out.FormMetadata.PageSize.Frame = clonePtr(fa.FormMetadata.PageSize.Frame)
+	out.FormMetadata.Rounding = clonePtr(fa.FormMetadata.Rounding)
+	out.FormMetadata.Extends = clonePtr(fa.FormMetadata.Extends)
+	if fa.FormMetadata.Parts != nil {
+		out.FormMetadata.Parts = make([]PacketPart, len(fa.FormMetadata.Parts))
+		for i, part := range fa.FormMetadata.Parts {
+			out.FormMetadata.Parts[i] = part.clone()
+		}
+	}
+	if fa.Pages != nil {
+		out.Pages = make([]Page, len(fa.Pages))
+		for i, page := range fa.Pages {
+			out.Pages[i] = Page{PageNumber: page.PageNumber, Includes: cloneSlice(page.Includes), Frame: clonePtr(page.Frame), Anchors: cloneSlice(page.Anchors), Rotation: page.Rotation}
+			if page.PageSize != nil {
+				out.Pages[i].PageSize = clonePtr(page.PageSize)
+				out.Pages[i].PageSize.Frame = clonePtr(page.PageSize.Frame)
+			}
+			if page.Fields != nil {
+				out.Pages[i].Fields = make([]Field, len(page.Fields))
+				for j := range page.Fields {
+					out.Pages[i].Fields[j] = page.Fields[j].Clone()
+				}
+			}
+		}
+	}
+	if fa.FieldGroups != nil {
+		out.FieldGroups = make([]FieldGroup, len(fa.FieldGroups))
+		for i, g := range fa.FieldGroups {
+			out.FieldGroups[i] = g
+			out.FieldGroups[i].FieldIDs = cloneSlice(g.FieldIDs)
+			out.FieldGroups[i].ExpectedOptions = cloneSlice(g.ExpectedOptions)
+			if g.Repeat != nil {
+				out.FieldGroups[i].Repeat = clonePtr(g.Repeat)
+				out.FieldGroups[i].Repeat.TemplateFieldIDs = cloneSlice(g.Repeat.TemplateFieldIDs)
+			}
+			out.FieldGroups[i].Validation = clonePtr(g.Validation)
+		}
+	}
+	out.History = cloneHistory(fa.History)
+	return out
+}
+
+// Clone returns a deep copy of the field.
+func (f *Field) Clone() Field {
+	out := *f
+	out.Segments = cloneSlice(f.Segments)
+	out.Style = clonePtr(f.Style)
+	out.CheckStyle = clonePtr(f.CheckStyle)
+	out.Formatting = clonePtr(f.Formatting)
+	out.Validation = clonePtr(f.Validation)
+	out.Calculation = clonePtr(f.Calculation)
+	out.Conditions = clonePtr(f.Conditions)
+	out.Options = cloneSlice(f.Options)
+	out.RelativeTo = clonePtr(f.RelativeTo)
+	out.Barcode = clonePtr(f.Barcode)
+	out.Image = clonePtr(f.Image)
+	out.Signature = clonePtr(f.Signature)
+	if f.Help != nil {
+		out.Help = clonePtr(f.Help)
+		out.Help.RelatedFieldIDs = cloneSlice(f.Help.RelatedFieldIDs)
+	}
+	out.Transforms = cloneSlice(f.Transforms)
+	out.IntroducedYear = clonePtr(f.IntroducedYear)
+	out.RetiredYear = clonePtr(f.RetiredYear)
+	out.Provenance = clonePtr(f.Provenance)
+	out.Localized = maps.Clone(f.Localized)
+	if f.Overrides != nil {
+		out.Overrides = make(map[RenderTarget]FieldRenderOverride, len(f.Overrides))
+		for k, v := range f.Overrides {
+			out.Overrides[k] = v
+		}
+	}
+	return out
+}
+
+// UpdateField applies fn to a copy of the field with the given ID and, if
+// fn succeeds, stores the copy in its place. The field is left as it was
+// when fn fails, or when the change would leave the annotation
+// inconsistent. A changed field_id is applied as RenameField does, so
+// references follow it, and a changed group_id moves the field between
+// groups' member lists as AddFieldToGroup does.
+func (fa *FormAnnotation) UpdateField(fieldID string, fn func(f *Field) error) error {
+	field := fa.GetFieldByID(fieldID)
+	if field == nil {
+		return fmt.Errorf("update field: no field with ID %q", fieldID)
+	}
+	c := field.Clone()
+	if err := fn(&c); err != nil {
+		return fmt.Errorf("update field %q: %w", field.FieldID, err)
+	}
+	newID := c.FieldID
+	if newID == "" {
+		return fmt.Errorf("update field %q: empty field ID", field.FieldID)
+	}
+	if other := fa.GetFieldByID(newID); other != nil && other != field {
+		return fmt.Errorf("update field %q: ID %q is already in use", field.FieldID, newID)
+	}
+	if c.GroupID != field.GroupID && c.GroupID != "" && fa.GetGroup(c.GroupID) == nil {
+		return fmt.Errorf("update field %q: no group with ID %q", field.FieldID, c.GroupID)
+	}
+
+	oldID, oldGroup := field.FieldID, field.GroupID
+	c.FieldID = oldID
+	*field = c
+	fa.InvalidateIndex()
+	if newID != oldID {
+		fa.renameField(field, newID, func(ref string) bool { return fa.sameID(ref, oldID) })
+	}
+	if field.GroupID != oldGroup {
+		fa.leaveGroups(field.FieldID)
+		fa.joinGroup(field)
+	}
+	return nil
+}
+
+func (m *NameMapping) clone() *NameMapping {
+	if m == nil {
+		return nil
+	}
+	out := &NameMapping{Rules: cloneSlice(m.Rules)}
+	if m.Pairs != nil {
+		out.Pairs = make(map[string]string, len(m.Pairs))
+		for k, v := range m.Pairs {
+			out.Pairs[k] = v
+		}
+	}
+	return out
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+func cloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+func (p PacketPart) clone() PacketPart {
+	out := p
+	out.PageSize.Frame = clonePtr(p.PageSize.Frame)
+	out.NameMapping = p.NameMapping.clone()
+	out.RenderTargets = cloneSlice(p.RenderTargets)
+	out.Groups = cloneSlice(p.Groups)
+	out.RenamedFields = maps.Clone(p.RenamedFields)
+	out.RenamedGroups = maps.Clone(p.RenamedGroups)
+	return out
+}
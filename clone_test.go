@@ -0,0 +1,157 @@
+package annotation
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// populate sets every exported field reachable from v to a value derived
+// from seed: pointers are allocated, slices get two elements and maps one
+// entry, so that a field added later is covered without editing this test.
+// Recursive types stop at a fixed depth.
+func populate(v reflect.Value, seed *int, depth int) {
+	*seed++
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(fmt.Sprintf("s%d", *seed))
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(*seed % 100))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(*seed % 100))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(*seed) + 0.5)
+	case reflect.Pointer:
+		if depth > 0 {
+			v.Set(reflect.New(v.Type().Elem()))
+			populate(v.Elem(), seed, depth-1)
+		}
+	case reflect.Slice:
+		if depth > 0 {
+			v.Set(reflect.MakeSlice(v.Type(), 2, 2))
+			for i := range v.Len() {
+				populate(v.Index(i), seed, depth-1)
+			}
+		}
+	case reflect.Map:
+		if depth > 0 {
+			v.Set(reflect.MakeMap(v.Type()))
+			key, elem := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+			populate(key, seed, depth-1)
+			populate(elem, seed, depth-1)
+			v.SetMapIndex(key, elem)
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				populate(v.Field(i), seed, depth)
+			}
+		}
+	}
+}
+
+// mutate changes every value reachable from v in place: through pointers,
+// slice elements and map entries, the places a shallow copy would share.
+func mutate(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(v.String() + "!")
+	case reflect.Bool:
+		v.SetBool(!v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + 1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(v.Uint() + 1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(v.Float() + 1)
+	case reflect.Pointer:
+		if !v.IsNil() {
+			mutate(v.Elem())
+		}
+	case reflect.Slice:
+		for i := range v.Len() {
+			mutate(v.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			mutate(elem)
+			v.SetMapIndex(key, elem)
+		}
+		extra := reflect.New(v.Type().Key()).Elem()
+		seed := 1000
+		populate(extra, &seed, 1)
+		v.SetMapIndex(extra, reflect.New(v.Type().Elem()).Elem())
+	case reflect.Struct:
+		for i := range v.NumField() {
+			if v.Type().Field(i).IsExported() {
+				mutate(v.Field(i))
+			}
+		}
+	}
+}
+
+func populatedForm() *FormAnnotation {
+	var fa FormAnnotation
+	seed := 0
+	populate(reflect.ValueOf(&fa).Elem(), &seed, 6)
+	return &fa
+}
+
+// TestCloneIsDeep fills every field of an annotation, clones it, changes
+// everything in the clone and checks that the original is as it was.
+func TestCloneIsDeep(t *testing.T) {
+	fa, want := populatedForm(), populatedForm()
+	c := fa.Clone()
+	if !reflect.DeepEqual(c, fa) {
+		t.Fatal("the clone differs from the original")
+	}
+	mutate(reflect.ValueOf(c).Elem())
+	if reflect.DeepEqual(c, fa) {
+		t.Fatal("mutating the clone changed nothing")
+	}
+	if !reflect.DeepEqual(fa, want) {
+		diffPaths(t, "FormAnnotation", reflect.ValueOf(fa).Elem(), reflect.ValueOf(want).Elem())
+	}
+
+	fa = populatedForm()
+	field := fa.Pages[0].Fields[0].Clone()
+	mutate(reflect.ValueOf(&field).Elem())
+	if !reflect.DeepEqual(fa, want) {
+		diffPaths(t, "Field", reflect.ValueOf(fa).Elem(), reflect.ValueOf(want).Elem())
+	}
+}
+
+// diffPaths reports the paths at which got and want differ, naming the
+// members Clone shares with the original.
+func diffPaths(t *testing.T, path string, got, want reflect.Value) {
+	t.Helper()
+	if reflect.DeepEqual(got.Interface(), want.Interface()) {
+		return
+	}
+	switch got.Kind() {
+	case reflect.Pointer:
+		if !got.IsNil() && !want.IsNil() {
+			diffPaths(t, path, got.Elem(), want.Elem())
+			return
+		}
+	case reflect.Slice:
+		if got.Len() == want.Len() {
+			for i := range got.Len() {
+				diffPaths(t, fmt.Sprintf("%s[%d]", path, i), got.Index(i), want.Index(i))
+			}
+			return
+		}
+	case reflect.Struct:
+		for i := range got.NumField() {
+			if got.Type().Field(i).IsExported() {
+				diffPaths(t, path+"."+got.Type().Field(i).Name, got.Field(i), want.Field(i))
+			}
+		}
+		return
+	}
+	t.Errorf("%s is shared with the clone", path)
+}
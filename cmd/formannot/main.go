@@ -0,0 +1,308 @@
+// Command formannot validates, lints, compares, fills, renders and converts
+// form annotations from the command line, for CI pipelines and authors who
+// do not write Go.
+//
+// Usage:
+//
+//	formannot validate [-json] annotation.json
+//	formannot lint [-json] annotation.json
+//	formannot diff old.json new.json
+//	formannot fill -values data.json [-o filled.json] annotation.json
+//	formannot render -template f1040.pdf [-values data.json] -o out.pdf annotation.json
+//	formannot convert -to yaml|json [-o out] annotation.json
+//
+// Annotations named .yaml or .yml are read as YAML. Flags come before the
+// file arguments. validate and lint exit 1 when they find an error, diff
+// when the annotations differ, and fill when a value cannot be placed;
+// usage and I/O errors exit 2.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+	"github.com/amoghkashyap86/form-annotation/lint"
+	"github.com/amoghkashyap86/form-annotation/render"
+)
+
+// errFindings reports that a command ran but found problems, for exit
+// status 1; its findings have already been printed.
+var errFindings = errors.New("findings")
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"validate": {"validate [-json] annotation", runValidate},
+	"lint":     {"lint [-json] annotation", runLint},
+	"diff":     {"diff old new", runDiff},
+	"fill":     {"fill -values data.json [-o out] annotation", runFill},
+	"render":   {"render -template form.pdf [-values data.json] -o out.pdf annotation", runRender},
+	"convert":  {"convert -to yaml|json [-o out] annotation", runConvert},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	err := cmd.run(os.Args[2:])
+	switch {
+	case err == nil:
+	case errors.Is(err, errFindings):
+		os.Exit(1)
+	default:
+		fmt.Fprintf(os.Stderr, "formannot %s: %v\n", os.Args[1], err)
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	for _, name := range []string{"validate", "lint", "diff", "fill", "render", "convert"} {
+		fmt.Fprintf(os.Stderr, "  formannot %s\n", commands[name].usage)
+	}
+	os.Exit(2)
+}
+
+// parse parses a command's flags and requires n file arguments.
+func parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != n {
+		return nil, fmt.Errorf("want %d file arguments, got %d", n, fs.NArg())
+	}
+	return fs.Args(), nil
+}
+
+func load(path string) (*annotation.FormAnnotation, error) {
+	return annotation.LoadFile(context.Background(), path, annotation.LoadOptions{})
+}
+
+// loadValues reads a JSON document of values for FillFromData.
+func loadValues(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return values, nil
+}
+
+// output opens path for writing, or stdout when path is empty.
+func output(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// report prints issues one per line, or as a JSON array, and returns
+// errFindings when any is an error.
+func report(issues []annotation.ValidationIssue, asJSON bool) error {
+	if asJSON {
+		if issues == nil {
+			issues = []annotation.ValidationIssue{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(issues); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Printf("%s: %s\n", issue.Severity, issue.Error())
+		}
+	}
+	for _, issue := range issues {
+		if issue.Severity == annotation.SeverityError {
+			return errFindings
+		}
+	}
+	return nil
+}
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the issues as JSON")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	fa, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	return report(fa.ValidateAll(annotation.ValidateOptions{}).Issues, *asJSON)
+}
+
+func runLint(args []string) error {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the findings as JSON")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	fa, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	return report(append(fa.LintLayout(), lint.Lint(fa)...), *asJSON)
+}
+
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	files, err := parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	old, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	updated, err := load(files[1])
+	if err != nil {
+		return err
+	}
+	d := annotation.Diff(old, updated)
+	if d.Empty() {
+		return nil
+	}
+	fmt.Print(d.String())
+	return errFindings
+}
+
+func runFill(args []string) error {
+	fs := flag.NewFlagSet("fill", flag.ExitOnError)
+	valuesPath := fs.String("values", "", "JSON `file` of values keyed by value path")
+	out := fs.String("o", "", "write the filled annotation to `file` instead of stdout")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *valuesPath == "" {
+		return fmt.Errorf("-values is required")
+	}
+	fa, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	values, err := loadValues(*valuesPath)
+	if err != nil {
+		return err
+	}
+	rep := fa.FillFromData(values, annotation.FillOptions{})
+	for _, issue := range rep.Issues {
+		fmt.Fprintf(os.Stderr, "%s: %s: field %q: %s\n", issue.Severity, issue.Code, issue.FieldID, issue.Message)
+	}
+	w, err := output(*out)
+	if err != nil {
+		return err
+	}
+	if err := fa.Save(w); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if rep.HasErrors() {
+		return errFindings
+	}
+	return nil
+}
+
+func runRender(args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	template := fs.String("template", "", "PDF `file` of the blank form")
+	valuesPath := fs.String("values", "", "JSON `file` of values to fill first")
+	out := fs.String("o", "", "write the PDF to `file`")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *template == "" || *out == "" {
+		return fmt.Errorf("-template and -o are required")
+	}
+	fa, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	if *valuesPath != "" {
+		values, err := loadValues(*valuesPath)
+		if err != nil {
+			return err
+		}
+		if rep := fa.FillFromData(values, annotation.FillOptions{}); rep.HasErrors() {
+			for _, issue := range rep.Issues {
+				fmt.Fprintf(os.Stderr, "%s: %s: field %q: %s\n", issue.Severity, issue.Code, issue.FieldID, issue.Message)
+			}
+			return errFindings
+		}
+	}
+	in, err := os.Open(*template)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	w, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := render.RenderPDF(fa, in, w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	to := fs.String("to", "", "output format, yaml or json")
+	out := fs.String("o", "", "write to `file` instead of stdout")
+	files, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	fa, err := load(files[0])
+	if err != nil {
+		return err
+	}
+	var save func(io.Writer) error
+	switch *to {
+	case "yaml":
+		save = fa.SaveYAML
+	case "json":
+		save = fa.Save
+	default:
+		return fmt.Errorf("-to must be yaml or json, not %q", *to)
+	}
+	w, err := output(*out)
+	if err != nil {
+		return err
+	}
+	if err := save(w); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,227 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CoercionMode selects how FillFromData treats an upstream value whose JSON
+// type does not match the field's data type.
+type CoercionMode int
+
+const (
+	// CoerceStrict refuses mismatched values with FillTypeMismatch. It is the
+	// default, so accepting messy upstream data is a choice made per
+	// integration.
+	CoerceStrict CoercionMode = iota
+	// CoerceLenient applies the coercions listed under the Coerce* rule
+	// constants and refuses anything else as CoerceStrict does.
+	CoerceLenient
+	// CoerceCustom uses CoercionPolicy.Funcs for the data types it lists and
+	// CoerceStrict for the rest.
+	CoerceCustom
+)
+
+// CoercionFunc converts an upstream value for a field. v is a decoded JSON
+// scalar: a string, bool, float64, json.Number, int or int64.
+type CoercionFunc func(field *Field, v any) (string, error)
+
+// CoercionPolicy is FillOptions.Coercion.
+type CoercionPolicy struct {
+	Mode  CoercionMode
+	Funcs map[DataType]CoercionFunc
+}
+
+// Coercion records one value converted during a fill.
+type Coercion struct {
+	FieldID string `json:"field_id"`
+	Page    int    `json:"page,omitempty"`
+	Rule    string `json:"rule"`
+	// Original is the upstream value as JSON, e.g. "1,234.00" with its quotes.
+	// Both it and Value are masked for sensitive fields, as reports are.
+	Original string `json:"original"`
+	Value    string `json:"value"`
+}
+
+// Coercion rules. These are the only conversions CoerceLenient makes:
+//
+//	string  <- number                "number_to_string"   1234.5 -> "1234.5"
+//	string  <- bool                  "bool_to_string"     true -> "true"
+//	decimal <- formatted string      "formatted_number"   "$1,234.00" -> "1234.00", "(12)" -> "-12"
+//	integer <- formatted string      "formatted_number"   "1,234" -> "1234", "12.00" -> "12"
+//	boolean <- 1 or 0                "number_to_bool"     1 -> "true"
+//	boolean <- yes/no spelling       "bool_spelling"      "Yes", "X", "off" -> "true", "true", "false"
+//	date    <- ISO 8601 timestamp    "timestamp_to_date"  "2024-04-15T23:30:00-05:00" -> "2024-04-15"
+//
+// A number or string with a nonzero fractional part is never truncated to
+// an integer, a timestamp keeps the calendar date it was written with, and
+// numbers are never read as dates.
+const (
+	CoerceNumberToString = "number_to_string"
+	CoerceBoolToString   = "bool_to_string"
+	CoerceFormatted      = "formatted_number"
+	CoerceNumberToBool   = "number_to_bool"
+	CoerceBoolSpelling   = "bool_spelling"
+	CoerceTimestamp      = "timestamp_to_date"
+	// CoerceCustomRule marks a value changed by a CoerceCustom function.
+	CoerceCustomRule = "custom"
+)
+
+var (
+	plainInteger = regexp.MustCompile(`^-?[0-9]+$`)
+	plainDecimal = regexp.MustCompile(`^-?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+)
+
+// coerce converts the scalar raw for field, returning the rule applied, or ""
+// when the value was taken as it is.
+func (p CoercionPolicy) coerce(field *Field, raw any) (string, string, error) {
+	plain, err := scalarString(raw)
+	if err != nil || raw == nil {
+		return plain, "", err
+	}
+	if fn := p.Funcs[field.DataType]; p.Mode == CoerceCustom && fn != nil {
+		value, err := fn(field, raw)
+		if err != nil || value == plain {
+			return value, "", err
+		}
+		return value, CoerceCustomRule, nil
+	}
+	value, err := strictValue(field, raw, plain)
+	if err == nil || p.Mode != CoerceLenient {
+		return value, "", err
+	}
+	if value, rule, ok := lenientValue(field, raw, plain); ok {
+		return value, rule, nil
+	}
+	return "", "", err
+}
+
+// strictValue accepts raw only when its JSON type is the field's data type.
+// Numbers may arrive as plain numeric strings, since JSON numbers cannot
+// carry every decimal exactly.
+func strictValue(field *Field, raw any, plain string) (string, error) {
+	_, isString := raw.(string)
+	_, isBool := raw.(bool)
+	isNumber := !isString && !isBool
+	ok := true
+	switch field.DataType {
+	case DataTypeString:
+		ok = isString
+	case DataTypeDecimal:
+		ok = isNumber || plainDecimal.MatchString(plain)
+	case DataTypeInteger:
+		ok = (isNumber && isIntegral(plain)) || (isString && plainInteger.MatchString(plain))
+	case DataTypeBoolean:
+		ok = isBool
+	case DataTypeDate:
+		if ok = isString; ok {
+			_, problem := parseDate(plain, field.dateFormat())
+			ok = problem == ""
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("%s is not a valid %s value", displayValue(field, jsonText(raw)), field.DataType)
+	}
+	return plain, nil
+}
+
+func lenientValue(field *Field, raw any, plain string) (string, string, bool) {
+	switch v := raw.(type) {
+	case string:
+		switch field.DataType {
+		case DataTypeDecimal:
+			if n, ok := formattedNumber(v); ok {
+				return n, CoerceFormatted, true
+			}
+		case DataTypeInteger:
+			if n, ok := formattedNumber(v); ok {
+				if whole, frac, _ := strings.Cut(n, "."); strings.Trim(frac, "0") == "" && whole != "" && whole != "-" {
+					return whole, CoerceFormatted, true
+				}
+			}
+		case DataTypeBoolean:
+			if b, ok := parseBoolValue(v); ok {
+				return fmt.Sprint(b), CoerceBoolSpelling, true
+			}
+		case DataTypeDate:
+			if d, ok := timestampDate(v); ok {
+				return d.String(), CoerceTimestamp, true
+			}
+		}
+	case bool:
+		if field.DataType == DataTypeString {
+			return plain, CoerceBoolToString, true
+		}
+	default:
+		switch field.DataType {
+		case DataTypeString:
+			return plain, CoerceNumberToString, true
+		case DataTypeBoolean:
+			switch plain {
+			case "1":
+				return "true", CoerceNumberToBool, true
+			case "0":
+				return "false", CoerceNumberToBool, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// formattedNumber strips grouping commas, a dollar sign, spaces and
+// accounting parentheses, keeping the digits as written.
+func formattedNumber(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative, s = true, s[1:len(s)-1]
+	}
+	s = strings.NewReplacer(",", "", "$", "", " ", "").Replace(s)
+	if strings.HasPrefix(s, "-") {
+		negative, s = !negative, s[1:]
+	}
+	if !plainDecimal.MatchString(s) || strings.HasPrefix(s, "-") {
+		return "", false
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s, true
+}
+
+// timestampDate reads the calendar date of an ISO 8601 timestamp as written,
+// without converting it to another zone.
+func timestampDate(s string) (Date, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return DateOf(t), true
+		}
+	}
+	return Date{}, false
+}
+
+func isIntegral(number string) bool {
+	n, ok := parseDecimal(number)
+	return ok && n.IsInt()
+}
+
+// coercion records the conversion of raw to value for field, with both
+// passed through the sensitive formatter.
+func coercion(field *Field, page int, rule string, raw any, value string) Coercion {
+	return Coercion{
+		FieldID: field.FieldID, Page: page, Rule: rule,
+		Original: displayValue(field, jsonText(raw)), Value: displayValue(field, value),
+	}
+}
+
+func jsonText(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,53 @@
+package annotation
+
+import (
+	"strings"
+	"testing"
+)
+
+func ssnForm() *FormAnnotation {
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{{
+			FieldID: "ssn", FieldType: FieldTypeText, DataType: DataTypeString, FieldValue: "taxpayer.ssn",
+			Position: Position{X: 36, Y: 36, Width: 120, Height: 18, Unit: "pt"},
+		}}}},
+	}
+}
+
+func TestTypeMismatchMasksSensitiveValue(t *testing.T) {
+	data := map[string]any{"taxpayer": map[string]any{"ssn": 123456789.0}}
+	report := ssnForm().FillFromData(data, FillOptions{})
+	if len(report.Issues) != 1 || report.Issues[0].Code != FillTypeMismatch {
+		t.Fatalf("issues = %v, want one %s", report.Issues, FillTypeMismatch)
+	}
+	if msg := report.Issues[0].Message; strings.Contains(msg, "12345") || !strings.Contains(msg, "6789") {
+		t.Errorf("message %q is not masked", msg)
+	}
+}
+
+func TestCoercionMasksSensitiveValue(t *testing.T) {
+	data := map[string]any{"taxpayer": map[string]any{"ssn": 123456789.0}}
+	report := ssnForm().FillFromData(data, FillOptions{Coercion: CoercionPolicy{Mode: CoerceLenient}})
+	if len(report.Coercions) != 1 {
+		t.Fatalf("coercions = %v, want one", report.Coercions)
+	}
+	c := report.Coercions[0]
+	if c.Original != "*****6789" || c.Value != "*****6789" {
+		t.Errorf("coercion = %+v, want masked original and value", c)
+	}
+}
+
+func TestImportValuesMasksSensitiveValue(t *testing.T) {
+	fa := ssnForm()
+	fa.Pages[0].Fields[0].DataType = DataTypeInteger
+	report, err := fa.ImportValues(strings.NewReader("field_id,value\nssn,123-45-6789x\n"), ValuesCSV, FillOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, issue := range report.Issues {
+		if strings.Contains(issue.Message, "12") {
+			t.Errorf("issue %q is not masked", issue.Message)
+		}
+	}
+}
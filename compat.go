@@ -0,0 +1,317 @@
+package annotation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SchemaVersion identifies a released version of the annotation schema.
+type SchemaVersion int
+
+const (
+	// SchemaV1 is the original schema: typed fields, segments, groups and formatting.
+	SchemaV1 SchemaVersion = 1
+	// SchemaV2 adds filled values, virtual fields and conditional requirements.
+	SchemaV2 SchemaVersion = 2
+	// SchemaV3 adds sensitivity, requirement levels, read-only fields,
+	// option codes, fractional sizes, help content and encrypted values.
+	SchemaV3 SchemaVersion = 3
+)
+
+// CurrentSchemaVersion is the version this package reads and writes.
+const CurrentSchemaVersion = SchemaV3
+
+// schemaCapabilities records the version in which each capability first
+// appeared. Every new capability must be added here in the same change that
+// adds its detector; KnownCapabilities without an entry are treated as
+// belonging to the current version.
+var schemaCapabilities = map[Capability]SchemaVersion{
+	CapNumericFields:           SchemaV1,
+	CapSegmentedFields:         SchemaV1,
+	CapSignatureFields:         SchemaV1,
+	CapFieldGroups:             SchemaV1,
+	CapFormatting:              SchemaV1,
+	CapFilledValues:            SchemaV2,
+	CapVirtualFields:           SchemaV2,
+	CapConditionalRequirements: SchemaV2,
+	CapSensitiveFields:         SchemaV3,
+	CapRequirementLevels:       SchemaV3,
+	CapReadOnlyFields:          SchemaV3,
+	CapOptionCodes:             SchemaV3,
+	CapFractionalSizes:         SchemaV3,
+	CapHelpContent:             SchemaV3,
+	CapEncryptedValues:         SchemaV3,
+	CapRenderOverrides:         SchemaV3,
+	CapYearRanges:              SchemaV3,
+	CapValueTransforms:         SchemaV3,
+	CapPageIncludes:            SchemaV3,
+	CapYesNoGroups:             SchemaV3,
+	CapCoordinateFrames:        SchemaV3,
+	CapPacketParts:             SchemaV3,
+	CapCoordinateOrigins:       SchemaV3,
+	CapSegmentLayout:           SchemaV3,
+	CapTabOrder:                SchemaV3,
+	CapProvenance:              SchemaV3,
+	CapCalculations:            SchemaV3,
+	CapConditionalVisibility:   SchemaV3,
+	CapRepeatingRows:           SchemaV3,
+	CapChoiceFields:            SchemaV3,
+	CapRelativePositions:       SchemaV3,
+	CapLocalizedLabels:         SchemaV3,
+	CapBarcodeFields:           SchemaV3,
+	CapImageFields:             SchemaV3,
+	CapRoundingPolicy:          SchemaV3,
+	CapTemplateInheritance:     SchemaV3,
+	CapSignatureWorkflow:       SchemaV3,
+	CapPageLayout:              SchemaV3,
+	CapGroupValidation:         SchemaV3,
+}
+
+// CompatibilityImpact classifies how an older reader treats a construct it
+// does not know.
+type CompatibilityImpact string
+
+const (
+	// ImpactSafe constructs are ignored without changing what the form means.
+	ImpactSafe CompatibilityImpact = "safe"
+	// ImpactLossy constructs are dropped, and behavior silently changes.
+	ImpactLossy CompatibilityImpact = "lossy"
+	// ImpactBreaking constructs make the older reader reject or misplace the form.
+	ImpactBreaking CompatibilityImpact = "breaking"
+)
+
+// capabilityImpact says what happens to each capability in a version that
+// predates it. Unknown JSON keys are ignored by older readers, so most
+// additions are lossy; new field types and value shapes break them.
+var capabilityImpact = map[Capability]CompatibilityImpact{
+	CapFilledValues:            ImpactLossy,
+	CapVirtualFields:           ImpactBreaking,
+	CapConditionalRequirements: ImpactLossy,
+	CapSensitiveFields:         ImpactLossy,
+	CapRequirementLevels:       ImpactLossy,
+	CapReadOnlyFields:          ImpactLossy,
+	CapOptionCodes:             ImpactSafe,
+	CapFractionalSizes:         ImpactBreaking,
+	CapHelpContent:             ImpactSafe,
+	CapEncryptedValues:         ImpactBreaking,
+	CapRenderOverrides:         ImpactLossy,
+	CapYearRanges:              ImpactBreaking,
+	CapValueTransforms:         ImpactLossy,
+	CapPageIncludes:            ImpactBreaking,
+	CapYesNoGroups:             ImpactLossy,
+	CapCoordinateFrames:        ImpactBreaking,
+	CapPacketParts:             ImpactSafe,
+	CapCoordinateOrigins:       ImpactSafe,
+	CapSegmentLayout:           ImpactLossy,
+	CapTabOrder:                ImpactSafe,
+	CapProvenance:              ImpactSafe,
+	CapCalculations:            ImpactLossy,
+	CapConditionalVisibility:   ImpactLossy,
+	CapRepeatingRows:           ImpactSafe,
+	CapChoiceFields:            ImpactBreaking,
+	CapRelativePositions:       ImpactSafe,
+	CapLocalizedLabels:         ImpactSafe,
+	CapBarcodeFields:           ImpactBreaking,
+	CapImageFields:             ImpactBreaking,
+	CapRoundingPolicy:          ImpactLossy,
+	CapTemplateInheritance:     ImpactBreaking,
+	CapSignatureWorkflow:       ImpactLossy,
+	CapPageLayout:              ImpactLossy,
+	CapGroupValidation:         ImpactLossy,
+}
+
+// VersionCapabilities returns the capabilities readers of version v understand.
+func VersionCapabilities(v SchemaVersion) CapabilitySet {
+	set := CapabilitySet{}
+	for c := range KnownCapabilities() {
+		since, ok := schemaCapabilities[c]
+		if !ok {
+			since = CurrentSchemaVersion
+		}
+		if since <= v {
+			set[c] = true
+		}
+	}
+	return set
+}
+
+// CompatibilityIssue is one construct an older schema version cannot represent.
+type CompatibilityIssue struct {
+	Capability Capability          `json:"capability"`
+	Since      SchemaVersion       `json:"since"`
+	Impact     CompatibilityImpact `json:"impact"`
+	Message    string              `json:"message"`
+}
+
+// CompatibilityCheck reports the features the annotation uses that readers
+// of target do not understand, ordered by capability.
+func (fa *FormAnnotation) CompatibilityCheck(target SchemaVersion) []CompatibilityIssue {
+	supported := VersionCapabilities(target)
+	var issues []CompatibilityIssue
+	for _, c := range fa.Capabilities().List() {
+		if supported.Has(c) {
+			continue
+		}
+		impact, ok := capabilityImpact[c]
+		if !ok {
+			impact = ImpactBreaking
+		}
+		since, ok := schemaCapabilities[c]
+		if !ok {
+			since = CurrentSchemaVersion
+		}
+		issues = append(issues, CompatibilityIssue{
+			Capability: c,
+			Since:      since,
+			Impact:     impact,
+			Message:    fmt.Sprintf("%s requires schema version %d; version %d readers treat it as %s", c, since, target, impact),
+		})
+	}
+	return issues
+}
+
+// CompatibilityError refuses a save that would change the form's meaning
+// for readers of the target version.
+type CompatibilityError struct {
+	Target SchemaVersion
+	Issues []CompatibilityIssue
+}
+
+func (e *CompatibilityError) Error() string {
+	names := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		names[i] = fmt.Sprintf("%s (%s)", issue.Capability, issue.Impact)
+	}
+	return fmt.Sprintf("not representable in schema version %d: %s", e.Target, strings.Join(names, ", "))
+}
+
+// SaveOptions controls SaveToFileWithOptions.
+type SaveOptions struct {
+	// TargetVersion, when set, writes a file readers of that schema version
+	// interpret exactly as this package does.
+	TargetVersion SchemaVersion
+	// Gates opens the experimental capabilities the file may use.
+	Gates FeatureGates
+	// OmitHistory leaves the change history out of the file. Field
+	// provenance is kept.
+	OmitHistory bool
+}
+
+// SaveToFileWithOptions writes the annotation like SaveToFile. With a
+// TargetVersion, constructs that version safely ignores are removed first;
+// any lossy or breaking construct refuses the save with a *CompatibilityError.
+// Experimental capabilities whose gates are not open refuse the save with
+// an *UngatedFeatureError.
+func (fa *FormAnnotation) SaveToFileWithOptions(filepath string, opts SaveOptions) error {
+	out := fa
+	if opts.TargetVersion != 0 && opts.TargetVersion < CurrentSchemaVersion {
+		var refused []CompatibilityIssue
+		for _, issue := range fa.CompatibilityCheck(opts.TargetVersion) {
+			if issue.Impact != ImpactSafe {
+				refused = append(refused, issue)
+			}
+		}
+		if len(refused) > 0 {
+			return &CompatibilityError{Target: opts.TargetVersion, Issues: refused}
+		}
+		profile := CapabilityProfile{
+			Name:      fmt.Sprintf("schema_v%d", opts.TargetVersion),
+			Supported: VersionCapabilities(opts.TargetVersion),
+		}
+		var err error
+		if out, _, err = fa.Downgrade(profile); err != nil {
+			return err
+		}
+	}
+	if opts.OmitHistory && len(out.History) > 0 {
+		if out == fa {
+			out = fa.Clone()
+		}
+		out.History = nil
+	}
+	out, err := out.forSave()
+	if err != nil {
+		return err
+	}
+	if err := opts.Gates.checkEmit(out); err != nil {
+		return err
+	}
+	data, err := out.withReaderRequirements().ToJSON()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath, []byte(data), 0644)
+}
+
+// ErrUnsupportedDocument is matched by errors.Is for every
+// *UnsupportedDocumentError.
+var ErrUnsupportedDocument = errors.New("document requires a newer reader")
+
+// UnsupportedDocumentError refuses a document written for a newer reader.
+// Capabilities lists the recorded capabilities this package cannot honor;
+// SchemaVersion is set when the document is shaped for a newer schema.
+type UnsupportedDocumentError struct {
+	MinReaderVersion SchemaVersion
+	Capabilities     []Capability
+	SchemaVersion    SchemaVersion
+}
+
+func (e *UnsupportedDocumentError) Error() string {
+	if e.SchemaVersion > CurrentSchemaVersion {
+		return fmt.Sprintf("%v: document is schema version %d, this reader supports up to %d",
+			ErrUnsupportedDocument, e.SchemaVersion, CurrentSchemaVersion)
+	}
+	if len(e.Capabilities) == 0 {
+		return fmt.Sprintf("%v: needs schema version %d, this reader supports %d",
+			ErrUnsupportedDocument, e.MinReaderVersion, CurrentSchemaVersion)
+	}
+	names := make([]string, len(e.Capabilities))
+	for i, c := range e.Capabilities {
+		names[i] = string(c)
+	}
+	return fmt.Sprintf("%v: unsupported capabilities %s", ErrUnsupportedDocument, strings.Join(names, ", "))
+}
+
+func (e *UnsupportedDocumentError) Is(target error) bool { return target == ErrUnsupportedDocument }
+
+// readerRequirements returns the oldest schema version that understands
+// every capability the annotation uses, and those capabilities.
+func (fa *FormAnnotation) readerRequirements() (SchemaVersion, []Capability) {
+	version := SchemaV1
+	caps := fa.Capabilities().List()
+	for _, c := range caps {
+		since, ok := schemaCapabilities[c]
+		if !ok {
+			since = CurrentSchemaVersion
+		}
+		version = max(version, since)
+	}
+	return version, caps
+}
+
+// withReaderRequirements returns a shallow copy of the annotation whose
+// metadata records its current reader requirements.
+func (fa *FormAnnotation) withReaderRequirements() *FormAnnotation {
+	out := *fa
+	out.FormMetadata.SchemaVersion = CurrentSchemaVersion
+	out.FormMetadata.MinReaderVersion, out.FormMetadata.RequiredCapabilities = fa.readerRequirements()
+	return &out
+}
+
+// checkReader refuses metadata that asks for a newer reader or records
+// capabilities this package does not know.
+func (m FormMetadata) checkReader() error {
+	known := KnownCapabilities()
+	var unknown []Capability
+	for _, c := range m.RequiredCapabilities {
+		if !known.Has(c) {
+			unknown = append(unknown, c)
+		}
+	}
+	if m.MinReaderVersion > CurrentSchemaVersion || len(unknown) > 0 {
+		return &UnsupportedDocumentError{MinReaderVersion: m.MinReaderVersion, Capabilities: unknown}
+	}
+	return nil
+}
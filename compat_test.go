@@ -0,0 +1,215 @@
+package annotation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// versionedCapabilities is the released capability set of each schema
+// version, written out so that moving a capability between versions, or
+// adding one without deciding its version, fails here.
+var versionedCapabilities = map[SchemaVersion][]Capability{
+	SchemaV1: {CapFieldGroups, CapFormatting, CapNumericFields, CapSegmentedFields, CapSignatureFields},
+	SchemaV2: {CapConditionalRequirements, CapFieldGroups, CapFilledValues, CapFormatting, CapNumericFields,
+		CapSegmentedFields, CapSignatureFields, CapVirtualFields},
+}
+
+func TestVersionCapabilities(t *testing.T) {
+	for v, want := range versionedCapabilities {
+		if got := VersionCapabilities(v).List(); !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d capabilities = %v, want %v", v, got, want)
+		}
+	}
+	if got, want := VersionCapabilities(CurrentSchemaVersion), KnownCapabilities(); !reflect.DeepEqual(got, want) {
+		t.Errorf("current version capabilities = %v, want every known capability", got.List())
+	}
+	for v := SchemaV1; v < CurrentSchemaVersion; v++ {
+		for c := range VersionCapabilities(v) {
+			if !VersionCapabilities(v + 1).Has(c) {
+				t.Errorf("%s is in version %d but not %d", c, v, v+1)
+			}
+		}
+	}
+}
+
+// TestCapabilityTables checks that every detectable capability has a
+// version, and an impact unless it has been there from the start.
+func TestCapabilityTables(t *testing.T) {
+	for _, c := range KnownCapabilities().List() {
+		since, ok := schemaCapabilities[c]
+		if !ok {
+			t.Errorf("%s has no schema version", c)
+			continue
+		}
+		if _, ok := capabilityImpact[c]; !ok && since > SchemaV1 {
+			t.Errorf("%s has no compatibility impact", c)
+		}
+	}
+	for c := range capabilityImpact {
+		if !KnownCapabilities().Has(c) {
+			t.Errorf("impact recorded for unknown capability %s", c)
+		}
+	}
+}
+
+func compatForm() *FormAnnotation {
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{{
+			FieldID: "name", FieldType: FieldTypeText, DataType: DataTypeString,
+			Position: Position{X: 36, Y: 36, Width: 200, Height: 18, Unit: "pt"},
+		}}}},
+	}
+}
+
+func TestCompatibilityCheck(t *testing.T) {
+	fa := compatForm()
+	for v := SchemaV1; v <= CurrentSchemaVersion; v++ {
+		if issues := fa.CompatibilityCheck(v); len(issues) != 0 {
+			t.Errorf("version %d: plain form reported %v", v, issues)
+		}
+	}
+	fa.GetFieldByID("name").Value = "Ada"
+	fa.GetFieldByID("name").Help = &HelpContent{Guidance: "Your legal name."}
+	want := []CompatibilityIssue{
+		{Capability: CapFilledValues, Since: SchemaV2, Impact: ImpactLossy},
+		{Capability: CapHelpContent, Since: SchemaV3, Impact: ImpactSafe},
+	}
+	for v, want := range map[SchemaVersion][]CompatibilityIssue{SchemaV1: want, SchemaV2: want[1:], SchemaV3: nil} {
+		got := fa.CompatibilityCheck(v)
+		for i := range got {
+			got[i].Message = ""
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d issues = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestSaveTargetVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	fa := compatForm()
+	fa.GetFieldByID("name").Help = &HelpContent{Guidance: "Your legal name."}
+	path := filepath.Join(dir, "safe.json")
+	if err := fa.SaveToFileWithOptions(path, SaveOptions{TargetVersion: SchemaV2}); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadFile(context.Background(), path, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.GetFieldByID("name").Help != nil {
+		t.Error("help content was kept for a version 2 reader")
+	}
+	if got := saved.FormMetadata.MinReaderVersion; got > SchemaV2 {
+		t.Errorf("min reader version = %d, want at most 2", got)
+	}
+	if fa.GetFieldByID("name").Help == nil {
+		t.Error("saving changed the annotation")
+	}
+
+	fa.GetFieldByID("name").Sensitive = true
+	path = filepath.Join(dir, "lossy.json")
+	err = fa.SaveToFileWithOptions(path, SaveOptions{TargetVersion: SchemaV2})
+	var compatErr *CompatibilityError
+	if !errors.As(err, &compatErr) {
+		t.Fatalf("err = %v, want a *CompatibilityError", err)
+	}
+	if compatErr.Target != SchemaV2 || len(compatErr.Issues) != 1 ||
+		compatErr.Issues[0].Capability != CapSensitiveFields || compatErr.Issues[0].Impact != ImpactLossy {
+		t.Errorf("refused with %v", compatErr.Issues)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("a refused save wrote the file")
+	}
+}
+
+// futureDocument returns a saved annotation with its metadata edited as a
+// newer writer might leave it.
+func futureDocument(t *testing.T, edit func(meta map[string]any)) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "form.json")
+	if err := compatForm().SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	edit(doc["form_metadata"].(map[string]any))
+	if data, err = json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestLoadFutureDocument(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(meta map[string]any)
+		want UnsupportedDocumentError
+	}{
+		{"newer reader", func(meta map[string]any) { meta["min_reader_version"] = 99 },
+			UnsupportedDocumentError{MinReaderVersion: 99}},
+		{"unknown capability", func(meta map[string]any) {
+			meta["required_capabilities"] = []string{string(CapFieldGroups), "teleported_fields"}
+		}, UnsupportedDocumentError{MinReaderVersion: SchemaV1, Capabilities: []Capability{"teleported_fields"}}},
+		{"newer schema", func(meta map[string]any) { meta["schema_version"] = 99 },
+			UnsupportedDocumentError{SchemaVersion: 99}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := futureDocument(t, tt.edit)
+			path := filepath.Join(t.TempDir(), "future.json")
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadFile(context.Background(), path, LoadOptions{})
+			if !errors.Is(err, ErrUnsupportedDocument) {
+				t.Fatalf("err = %v, want ErrUnsupportedDocument", err)
+			}
+			var docErr *UnsupportedDocumentError
+			if !errors.As(err, &docErr) || !reflect.DeepEqual(*docErr, tt.want) {
+				t.Errorf("err = %#v, want %#v", docErr, tt.want)
+			}
+			if _, err := FromJSON(string(data)); !errors.Is(err, ErrUnsupportedDocument) {
+				t.Errorf("FromJSON err = %v, want ErrUnsupportedDocument", err)
+			}
+
+			fa, err := LoadFile(context.Background(), path, LoadOptions{IgnoreReaderVersion: true})
+			if err != nil {
+				t.Fatalf("override: %v", err)
+			}
+			if fa.GetFieldByID("name") == nil {
+				t.Error("override lost the fields")
+			}
+		})
+	}
+}
+
+func TestSaveRecordsReaderRequirements(t *testing.T) {
+	fa := compatForm()
+	fa.GetFieldByID("name").Sensitive = true
+	path := filepath.Join(t.TempDir(), "form.json")
+	if err := fa.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadFile(context.Background(), path, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := saved.FormMetadata
+	if meta.MinReaderVersion != SchemaV3 || !reflect.DeepEqual(meta.RequiredCapabilities, []Capability{CapSensitiveFields}) {
+		t.Errorf("min reader version %d, capabilities %v", meta.MinReaderVersion, meta.RequiredCapabilities)
+	}
+}
@@ -0,0 +1,127 @@
+package annotation
+
+// Conditions make a field's presence depend on other fields' values, as
+// in "complete line 12 only if box 11 is checked". Whether the field is
+// required is set by Validation.RequiredIf; a field that is hidden or
+// disabled is never required.
+type Conditions struct {
+	// VisibleIf shows the field only while the expression holds.
+	VisibleIf string `json:"visible_if,omitempty"`
+	// DisabledIf marks the field as closed to input while the expression
+	// holds, for editors to grey out.
+	DisabledIf string `json:"disabled_if,omitempty"`
+}
+
+// FieldState is the effect of a field's conditions on the current values.
+type FieldState struct {
+	FieldID  string `json:"field_id"`
+	Visible  bool   `json:"visible"`
+	Disabled bool   `json:"disabled,omitempty"`
+	// Required is the tier at which the field is currently required; empty
+	// when it is not.
+	Required RequirementLevel `json:"required,omitempty"`
+}
+
+// EvaluateConditions evaluates every field's conditions and requirement
+// against the filled values, returning each field's state in document
+// order. A condition that fails to evaluate leaves the field visible,
+// enabled and, if it has a requirement, required at the hard tier, so
+// that a broken rule never hides a line or lets a form look complete;
+// Validate reports the rule itself.
+func (fa *FormAnnotation) EvaluateConditions() []FieldState {
+	lookup := fa.valueLookup()
+	var states []FieldState
+	for _, field := range fa.Fields() {
+		states = append(states, fa.fieldState(field, lookup))
+	}
+	return states
+}
+
+func (fa *FormAnnotation) fieldState(field *Field, lookup func(string) string) FieldState {
+	st := FieldState{FieldID: field.FieldID, Visible: true}
+	if c := field.Conditions; c != nil {
+		st.Visible, st.Disabled, _ = conditionState(c.VisibleIf, c.DisabledIf, fa.exprLimits(), lookup)
+	}
+	if v := field.Validation; v != nil && st.Visible && !st.Disabled {
+		level, err := requirementOf(v.Required, v.Level, v.RequiredIf, fa.exprLimits(), lookup)
+		if err != nil {
+			level = RequirementHard
+		}
+		st.Required = level
+	}
+	return st
+}
+
+// fieldRule is one of a field's condition expressions, named by its key.
+type fieldRule struct{ key, src string }
+
+// rules returns the field's non-empty required_if, visible_if and
+// disabled_if expressions.
+func (f *Field) rules() []fieldRule {
+	var rules []fieldRule
+	if v := f.Validation; v != nil && v.RequiredIf != "" {
+		rules = append(rules, fieldRule{"required_if", v.RequiredIf})
+	}
+	if c := f.Conditions; c != nil {
+		if c.VisibleIf != "" {
+			rules = append(rules, fieldRule{"visible_if", c.VisibleIf})
+		}
+		if c.DisabledIf != "" {
+			rules = append(rules, fieldRule{"disabled_if", c.DisabledIf})
+		}
+	}
+	return rules
+}
+
+// valueLookup resolves field IDs to their filled values for expressions.
+func (fa *FormAnnotation) valueLookup() func(string) string {
+	values := map[string]string{}
+	for _, field := range fa.Fields() {
+		values[field.FieldID] = field.Value
+	}
+	return func(id string) string { return values[id] }
+}
+
+// conditionState evaluates a field's visible_if and disabled_if. An empty
+// expression leaves the field visible and enabled; one that fails to
+// evaluate does too, and its error is returned.
+func conditionState(visibleIf, disabledIf string, limits ExprLimits, lookup func(string) string) (visible, disabled bool, err error) {
+	visible = true
+	if visibleIf != "" {
+		if ok, evalErr := evalCondition(visibleIf, limits, lookup); evalErr != nil {
+			err = evalErr
+		} else {
+			visible = ok
+		}
+	}
+	if disabledIf != "" {
+		if ok, evalErr := evalCondition(disabledIf, limits, lookup); evalErr != nil {
+			err = evalErr
+		} else {
+			disabled = ok
+		}
+	}
+	return visible, disabled, err
+}
+
+func evalCondition(src string, limits ExprLimits, lookup func(string) string) (bool, error) {
+	expr, err := ParseExprWithLimits(src, limits)
+	if err != nil {
+		return false, err
+	}
+	return expr.EvalBool(lookup)
+}
+
+// clearHidden empties the values of fields their conditions currently hide.
+func (fa *FormAnnotation) clearHidden() {
+	lookup := fa.valueLookup()
+	for _, field := range fa.Fields() {
+		if field.Conditions == nil || field.Value == "" {
+			continue
+		}
+		visible, _, err := conditionState(field.Conditions.VisibleIf, "", fa.exprLimits(), lookup)
+		if err == nil && !visible {
+			field.Value = ""
+		}
+	}
+}
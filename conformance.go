@@ -0,0 +1,276 @@
+package annotation
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path"
+	"reflect"
+	"sort"
+)
+
+// Each conformance case is a directory under conformance/ holding:
+//
+//	input.json      the annotation as an implementation would read it
+//	canonical.json  the expected re-serialization of input.json
+//	report.json     the expected structural validation issues
+//	data.json       optional fill data for FillFromData, coerced leniently
+//	extracted.json  the values ExtractValues returns after filling data.json
+//
+//go:embed conformance
+var conformanceFS embed.FS
+
+// ConformanceCase is one fixture of the conformance suite.
+type ConformanceCase struct {
+	Name      string
+	Input     []byte
+	Canonical []byte
+	Issues    []ConformanceIssue
+	Data      []byte
+	Extracted []byte
+}
+
+// ConformanceIssue is the implementation-independent part of a validation
+// issue. Messages are not compared.
+type ConformanceIssue struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	FieldID  string   `json:"field_id,omitempty"`
+	GroupID  string   `json:"group_id,omitempty"`
+	Page     int      `json:"page,omitempty"`
+}
+
+// ConformanceCases returns the conformance suite in name order.
+func ConformanceCases() ([]ConformanceCase, error) {
+	dirs, err := fs.ReadDir(conformanceFS, "conformance")
+	if err != nil {
+		return nil, err
+	}
+	var cases []ConformanceCase
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		c, err := loadConformanceCase(d.Name())
+		if err != nil {
+			return nil, fmt.Errorf("conformance case %s: %w", d.Name(), err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+func loadConformanceCase(name string) (ConformanceCase, error) {
+	c := ConformanceCase{Name: name}
+	read := func(file string, optional bool) ([]byte, error) {
+		data, err := conformanceFS.ReadFile(path.Join("conformance", name, file))
+		if optional && errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return data, err
+	}
+	var err error
+	if c.Input, err = read("input.json", false); err != nil {
+		return c, err
+	}
+	if c.Canonical, err = read("canonical.json", false); err != nil {
+		return c, err
+	}
+	report, err := read("report.json", false)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(report, &c.Issues); err != nil {
+		return c, fmt.Errorf("report.json: %w", err)
+	}
+	if c.Data, err = read("data.json", true); err != nil {
+		return c, err
+	}
+	if c.Extracted, err = read("extracted.json", true); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// ExternalRunner drives an implementation of the format under test.
+type ExternalRunner interface {
+	// Canonicalize reads an annotation and writes it back out.
+	Canonicalize(input []byte) ([]byte, error)
+	// Validate returns the structural validation issues for an annotation.
+	Validate(input []byte) ([]ConformanceIssue, error)
+	// FillAndExtract fills an annotation from data and returns the extracted values.
+	FillAndExtract(input, data []byte) ([]byte, error)
+}
+
+// ConformanceFailure is one expectation an implementation did not meet.
+type ConformanceFailure struct {
+	Case    string `json:"case"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// VerifyImplementation runs every conformance case through runner and
+// returns the failures. JSON outputs are compared as decoded values, so key
+// order and whitespace do not matter; issues are compared as sets.
+func VerifyImplementation(runner ExternalRunner) ([]ConformanceFailure, error) {
+	cases, err := ConformanceCases()
+	if err != nil {
+		return nil, err
+	}
+	var failures []ConformanceFailure
+	fail := func(c, check, format string, args ...any) {
+		failures = append(failures, ConformanceFailure{c, check, fmt.Sprintf(format, args...)})
+	}
+	for _, c := range cases {
+		if out, err := runner.Canonicalize(c.Input); err != nil {
+			fail(c.Name, "canonical", "%v", err)
+		} else if !sameJSON(out, c.Canonical) {
+			fail(c.Name, "canonical", "re-serialization differs from canonical.json")
+		}
+
+		if issues, err := runner.Validate(c.Input); err != nil {
+			fail(c.Name, "report", "%v", err)
+		} else if got, want := sortedIssues(issues), sortedIssues(c.Issues); !reflect.DeepEqual(got, want) {
+			fail(c.Name, "report", "got issues %v, want %v", got, want)
+		}
+
+		if c.Data == nil {
+			continue
+		}
+		if out, err := runner.FillAndExtract(c.Input, c.Data); err != nil {
+			fail(c.Name, "extracted", "%v", err)
+		} else if !sameJSON(out, c.Extracted) {
+			fail(c.Name, "extracted", "extracted values differ from extracted.json")
+		}
+	}
+	return failures, nil
+}
+
+func sameJSON(a, b []byte) bool {
+	decode := func(data []byte) (any, bool) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var v any
+		return v, dec.Decode(&v) == nil
+	}
+	va, okA := decode(a)
+	vb, okB := decode(b)
+	return okA && okB && reflect.DeepEqual(va, vb)
+}
+
+func sortedIssues(issues []ConformanceIssue) []ConformanceIssue {
+	out := append([]ConformanceIssue{}, issues...)
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		if a.FieldID != b.FieldID {
+			return a.FieldID < b.FieldID
+		}
+		if a.GroupID != b.GroupID {
+			return a.GroupID < b.GroupID
+		}
+		return a.Page < b.Page
+	})
+	return out
+}
+
+// NativeRunner runs the conformance suite against this package.
+type NativeRunner struct{}
+
+func (NativeRunner) Canonicalize(input []byte) ([]byte, error) {
+	fa, err := FromJSON(string(input))
+	if err != nil {
+		return nil, err
+	}
+	out, err := fa.ToJSON()
+	return []byte(out), err
+}
+
+func (NativeRunner) Validate(input []byte) ([]ConformanceIssue, error) {
+	fa, err := FromJSON(string(input))
+	if err != nil {
+		return nil, err
+	}
+	issues := []ConformanceIssue{}
+	for _, issue := range fa.Validate() {
+		issues = append(issues, ConformanceIssue{issue.Code, issue.Severity, issue.FieldID, issue.GroupID, issue.Page})
+	}
+	return issues, nil
+}
+
+func (NativeRunner) FillAndExtract(input, data []byte) ([]byte, error) {
+	fa, err := FromJSON(string(input))
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if report := fa.FillFromData(doc, FillOptions{Coercion: CoercionPolicy{Mode: CoerceLenient}}); report.HasErrors() {
+		return nil, fmt.Errorf("fill failed: %s", report.Issues[0].Message)
+	}
+	values, err := fa.ExtractValues(ExtractOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(values)
+}
+
+// CommandRunner runs another implementation as a subprocess. The operation
+// name ("canonicalize", "validate" or "fill") is appended to Args, and a JSON
+// object {"input": ..., "data": ...} is written to its standard input. The
+// command writes the result JSON to standard output: the annotation, an
+// array of issues, or the extracted values.
+type CommandRunner struct {
+	Path string
+	Args []string
+}
+
+func (r CommandRunner) run(op string, input, data []byte) ([]byte, error) {
+	req := map[string]json.RawMessage{"input": input}
+	if data != nil {
+		req["data"] = data
+	}
+	stdin, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(r.Path, append(append([]string{}, r.Args...), op)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %v: %s", r.Path, op, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return out, nil
+}
+
+func (r CommandRunner) Canonicalize(input []byte) ([]byte, error) {
+	return r.run("canonicalize", input, nil)
+}
+
+func (r CommandRunner) Validate(input []byte) ([]ConformanceIssue, error) {
+	out, err := r.run("validate", input, nil)
+	if err != nil {
+		return nil, err
+	}
+	var issues []ConformanceIssue
+	if err := json.Unmarshal(out, &issues); err != nil {
+		return nil, fmt.Errorf("%s validate: %w", r.Path, err)
+	}
+	return issues, nil
+}
+
+func (r CommandRunner) FillAndExtract(input, data []byte) ([]byte, error) {
+	return r.run("fill", input, data)
+}
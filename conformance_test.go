@@ -0,0 +1,48 @@
+package annotation
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestConformance runs the embedded suite against this package, so the
+// fixtures partners certify against cannot drift from the implementation.
+func TestConformance(t *testing.T) {
+	cases, err := ConformanceCases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("the conformance suite is empty")
+	}
+	failures, err := VerifyImplementation(NativeRunner{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range failures {
+		t.Errorf("%s: %s: %s", f.Case, f.Check, f.Message)
+	}
+	for _, c := range cases {
+		if c.Data != nil && c.Extracted == nil {
+			t.Errorf("%s: data.json without extracted.json", c.Name)
+		}
+	}
+}
+
+// TestConformanceInvalidCases checks that every invalid_ case expects at
+// least one error, so a fixture cannot silently become valid.
+func TestConformanceInvalidCases(t *testing.T) {
+	cases, err := ConformanceCases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		if !strings.HasPrefix(c.Name, "invalid_") {
+			continue
+		}
+		if !slices.ContainsFunc(c.Issues, func(i ConformanceIssue) bool { return i.Severity == SeverityError }) {
+			t.Errorf("%s: report.json expects no error", c.Name)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Library consistency issue codes.
+const (
+	LibraryFormIDCollision  = "library_form_id_collision"
+	LibraryUnknownTemplate  = "library_unknown_template"
+	LibraryUnusedTemplate   = "library_unused_template"
+	LibraryDataTypeMismatch = "library_data_type_mismatch"
+)
+
+// SeverityOff, as a CrossValidateOptions severity, suppresses a code.
+const SeverityOff Severity = "off"
+
+// FormRef identifies a form in a library.
+type FormRef struct {
+	FormID string `json:"form_id"`
+	Year   int    `json:"year"`
+}
+
+func formRef(fa *FormAnnotation) *FormRef {
+	return &FormRef{FormID: fa.FormMetadata.FormID, Year: fa.FormMetadata.Year}
+}
+
+func (r *FormRef) String() string { return fmt.Sprintf("%s/%d", r.FormID, r.Year) }
+
+// CrossValidateOptions controls Library.CrossValidate.
+type CrossValidateOptions struct {
+	// Severity overrides the default severity of library issue codes, so a
+	// rule can be adopted as a warning first or switched off.
+	Severity map[string]Severity
+}
+
+// CrossValidate checks the rules that span forms: form IDs that differ only
+// by case within a year, page includes naming templates the library does
+// not hold, templates nothing includes, and value paths whose data type
+// differs between forms. Form names the form an issue is reported on and
+// OtherForm the form it clashes with; colliding IDs are reported on both.
+func (l *Library) CrossValidate(opts CrossValidateOptions) *ValidationReport {
+	return l.crossValidate(nil, opts)
+}
+
+// CrossValidateForm runs only the checks that involve the form with formID
+// and year, for re-checking after that form alone changed, and returns the
+// issues naming it as Form or OtherForm.
+func (l *Library) CrossValidateForm(formID string, year int, opts CrossValidateOptions) *ValidationReport {
+	focus := l.Get(formID, year)
+	if focus == nil {
+		return &ValidationReport{}
+	}
+	return l.crossValidate(focus, opts)
+}
+
+func (l *Library) crossValidate(focus *FormAnnotation, opts CrossValidateOptions) *ValidationReport {
+	report := &ValidationReport{}
+	add := func(code string, severity Severity, form, other *FormAnnotation, issue ValidationIssue) {
+		if s, ok := opts.Severity[code]; ok {
+			severity = s
+		}
+		if severity == SeverityOff || (focus != nil && form != focus && other != focus) {
+			return
+		}
+		issue.Code, issue.Severity = code, severity
+		if form != nil {
+			issue.Form = formRef(form)
+		}
+		if other != nil {
+			issue.OtherForm = formRef(other)
+		}
+		report.add(issue)
+	}
+	forms := l.Forms()
+	involves := func(fa *FormAnnotation) bool { return focus == nil || fa == focus }
+
+	// Form IDs differing only by case collide on case-insensitive stores.
+	byFolded := map[libraryKey][]*FormAnnotation{}
+	for _, fa := range forms {
+		key := libraryKey{strings.ToLower(strings.TrimSpace(fa.FormMetadata.FormID)), fa.FormMetadata.Year}
+		byFolded[key] = append(byFolded[key], fa)
+	}
+	for _, fa := range forms {
+		key := libraryKey{strings.ToLower(strings.TrimSpace(fa.FormMetadata.FormID)), fa.FormMetadata.Year}
+		for _, other := range byFolded[key] {
+			if other != fa && (involves(fa) || involves(other)) {
+				add(LibraryFormIDCollision, SeverityError, fa, other, ValidationIssue{
+					Message: fmt.Sprintf("form ID %q differs from %q only by case or spacing", fa.FormMetadata.FormID, other.FormMetadata.FormID),
+				})
+			}
+		}
+	}
+
+	// Includes must name library templates, and every template should be
+	// included somewhere.
+	used := map[string]bool{}
+	for _, fa := range forms {
+		for _, page := range fa.Pages {
+			for _, inc := range page.Includes {
+				used[inc.Template] = true
+				if involves(fa) && l.Template(inc.Template) == nil {
+					add(LibraryUnknownTemplate, SeverityError, fa, nil, ValidationIssue{
+						Page:    page.PageNumber,
+						Message: fmt.Sprintf("page includes template %q, which the library does not hold", inc.Template),
+					})
+				}
+			}
+		}
+	}
+	if focus == nil {
+		for _, name := range l.Templates() {
+			if !used[name] {
+				add(LibraryUnusedTemplate, SeverityWarning, nil, nil, ValidationIssue{
+					Message: fmt.Sprintf("template %q is not included by any form", name),
+				})
+			}
+		}
+	}
+
+	// A value path should hold the same data type on every form. The type
+	// most forms use is taken as intended; for the focused form only its
+	// own paths are compared.
+	var paths map[string]bool
+	if focus != nil {
+		paths = map[string]bool{}
+		for _, field := range focus.Fields() {
+			paths[field.FieldValue] = true
+		}
+	}
+	type use struct {
+		form  *FormAnnotation
+		field string
+		dt    DataType
+	}
+	var order []string
+	uses := map[string][]use{}
+	for _, fa := range forms {
+		seen := map[string]bool{}
+		for _, page := range fa.Pages {
+			for _, field := range page.Fields {
+				path := field.FieldValue
+				if path == "" || field.DataType == "" || seen[path] || (paths != nil && !paths[path]) {
+					continue
+				}
+				seen[path] = true
+				if uses[path] == nil {
+					order = append(order, path)
+				}
+				uses[path] = append(uses[path], use{fa, field.FieldID, field.DataType})
+			}
+		}
+	}
+	for _, path := range order {
+		counts := map[DataType]int{}
+		for _, u := range uses[path] {
+			counts[u.dt]++
+		}
+		if len(counts) < 2 {
+			continue
+		}
+		var major use
+		for _, u := range uses[path] {
+			if counts[u.dt] > counts[major.dt] {
+				major = u
+			}
+		}
+		for _, u := range uses[path] {
+			if u.dt != major.dt {
+				add(LibraryDataTypeMismatch, SeverityError, u.form, major.form, ValidationIssue{
+					FieldID: u.field,
+					Message: fmt.Sprintf("value path %q is %s here but %s on %d other form(s), such as %s",
+						path, u.dt, major.dt, counts[major.dt], formRef(major.form)),
+				})
+			}
+		}
+	}
+	return report
+}
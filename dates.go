@@ -0,0 +1,324 @@
+package annotation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date is a calendar date. Tax forms record civil dates, not instants, so a
+// Date carries no time of day or location and cannot shift across a day
+// boundary when converted.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// ISODateFormat is the canonical date format used for stored and extracted values.
+const ISODateFormat = "YYYY-MM-DD"
+
+// TwoDigitYearPivot decides the century of two-digit years: years below the
+// pivot are in the 2000s and the rest in the 1900s, so with the default of
+// 50, "49" is 2049 and "50" is 1950.
+var TwoDigitYearPivot = 50
+
+// DateOf returns the calendar date of t in t's own location.
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{Year: y, Month: m, Day: d}
+}
+
+// Time returns the date at noon UTC. Noon keeps the calendar date stable
+// under any conversion to a location within twelve hours of UTC.
+func (d Date) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 12, 0, 0, 0, time.UTC)
+}
+
+// IsZero reports whether d is the zero Date.
+func (d Date) IsZero() bool {
+	return d == Date{}
+}
+
+// Valid reports whether d names a real calendar day, so that February 30 and
+// February 29 outside leap years are rejected.
+func (d Date) Valid() bool {
+	if d.Year < 1 || d.Month < time.January || d.Month > time.December || d.Day < 1 {
+		return false
+	}
+	return d.Day <= daysIn(d.Month, d.Year)
+}
+
+// Before reports whether d is earlier than other.
+func (d Date) Before(other Date) bool {
+	return d.compare(other) < 0
+}
+
+// After reports whether d is later than other.
+func (d Date) After(other Date) bool {
+	return d.compare(other) > 0
+}
+
+// AddDate returns the date years, months and days later, or earlier for
+// negative counts. Years and months are added first, keeping the day within
+// the month they land in, so January 31 plus one month is the last day of
+// February; the days are added after.
+func (d Date) AddDate(years, months, days int) Date {
+	m := int(d.Month) - 1 + months
+	y := d.Year + years + m/12
+	if m %= 12; m < 0 {
+		m += 12
+		y--
+	}
+	out := Date{Year: y, Month: time.Month(m + 1)}
+	out.Day = min(d.Day, daysIn(out.Month, y))
+	if days != 0 {
+		out = DateOf(out.Time().AddDate(0, 0, days))
+	}
+	return out
+}
+
+func (d Date) compare(other Date) int {
+	switch {
+	case d.Year != other.Year:
+		return d.Year - other.Year
+	case d.Month != other.Month:
+		return int(d.Month) - int(other.Month)
+	}
+	return d.Day - other.Day
+}
+
+// String returns the date in YYYY-MM-DD form.
+func (d Date) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, int(d.Month), d.Day)
+}
+
+// Format renders the date using an annotation date format such as
+// "MM/DD/YYYY". The tokens are YYYY, YY, MM and DD; anything else is copied.
+func (d Date) Format(format string) string {
+	if format == "" {
+		format = ISODateFormat
+	}
+	var sb strings.Builder
+	for i := 0; i < len(format); {
+		switch {
+		case strings.HasPrefix(format[i:], "YYYY"):
+			fmt.Fprintf(&sb, "%04d", d.Year)
+			i += 4
+		case strings.HasPrefix(format[i:], "YY"):
+			fmt.Fprintf(&sb, "%02d", d.Year%100)
+			i += 2
+		case strings.HasPrefix(format[i:], "MM"):
+			fmt.Fprintf(&sb, "%02d", int(d.Month))
+			i += 2
+		case strings.HasPrefix(format[i:], "DD"):
+			fmt.Fprintf(&sb, "%02d", d.Day)
+			i += 2
+		default:
+			sb.WriteByte(format[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// ParseDate parses s as a calendar date. The canonical YYYY-MM-DD form is
+// always accepted; otherwise s must match format. Two-digit years are
+// expanded using TwoDigitYearPivot, and impossible dates are rejected.
+func ParseDate(s, format string) (Date, error) {
+	d, problem := parseDate(s, format)
+	if problem != "" {
+		return Date{}, fmt.Errorf("date %q %s", strings.TrimSpace(s), problem)
+	}
+	return d, nil
+}
+
+// parseDate is ParseDate reporting failure as a description that leaves out
+// the input, so callers can decide how the value may be shown.
+func parseDate(s, format string) (Date, string) {
+	s = strings.TrimSpace(s)
+	if d, problem := parseDateFormat(s, ISODateFormat); problem == "" {
+		return d, ""
+	}
+	if format == "" || format == ISODateFormat {
+		return Date{}, "is not in " + ISODateFormat + " form"
+	}
+	return parseDateFormat(s, format)
+}
+
+func parseDateFormat(s, format string) (Date, string) {
+	var d Date
+	pos := 0
+	digits := func(n int) (int, bool) {
+		if pos+n > len(s) {
+			return 0, false
+		}
+		v, err := strconv.Atoi(s[pos : pos+n])
+		if err != nil || strings.ContainsAny(s[pos:pos+n], "+-") {
+			return 0, false
+		}
+		pos += n
+		return v, true
+	}
+	for i := 0; i < len(format); {
+		var ok bool
+		switch {
+		case strings.HasPrefix(format[i:], "YYYY"):
+			d.Year, ok = digits(4)
+			i += 4
+		case strings.HasPrefix(format[i:], "YY"):
+			var yy int
+			if yy, ok = digits(2); ok {
+				d.Year = expandTwoDigitYear(yy)
+			}
+			i += 2
+		case strings.HasPrefix(format[i:], "MM"):
+			var m int
+			m, ok = digits(2)
+			d.Month = time.Month(m)
+			i += 2
+		case strings.HasPrefix(format[i:], "DD"):
+			d.Day, ok = digits(2)
+			i += 2
+		default:
+			ok = pos < len(s) && s[pos] == format[i]
+			pos++
+			i++
+		}
+		if !ok {
+			return Date{}, "does not match format " + format
+		}
+	}
+	if pos != len(s) {
+		return Date{}, "does not match format " + format
+	}
+	if !d.Valid() {
+		return Date{}, "is not a real calendar date"
+	}
+	return d, ""
+}
+
+func expandTwoDigitYear(yy int) int {
+	if yy < TwoDigitYearPivot {
+		return 2000 + yy
+	}
+	return 1900 + yy
+}
+
+func daysIn(m time.Month, year int) int {
+	switch m {
+	case time.February:
+		if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			return 29
+		}
+		return 28
+	case time.April, time.June, time.September, time.November:
+		return 30
+	}
+	return 31
+}
+
+// Date bound tokens accepted by Validation.MinDate and MaxDate in addition to
+// literal YYYY-MM-DD dates. The tax year tokens resolve against
+// FormMetadata.Year; "today" resolves when the value is validated.
+//
+// A token or date may be followed by an offset of years, months and days,
+// added as Date.AddDate adds them: "tax_year_end+3m15d" is April 15 after
+// the tax year and "today-18y" the birthday of someone turning 18 today.
+const (
+	DateBoundToday        = "today"
+	DateBoundTaxYearStart = "tax_year_start"
+	DateBoundTaxYearEnd   = "tax_year_end"
+)
+
+// dateOffsetPattern matches the offset of a date bound.
+var dateOffsetPattern = regexp.MustCompile(`^([+-])(?:(\d+)y)?(?:(\d+)m)?(?:(\d+)d)?$`)
+
+// dateOffset is a span of years, months and days added to a bound.
+type dateOffset struct{ years, months, days int }
+
+func (d Date) add(o dateOffset) Date {
+	if o == (dateOffset{}) {
+		return d
+	}
+	return d.AddDate(o.years, o.months, o.days)
+}
+
+// splitDateBound separates a bound into its token or literal date and its
+// offset, checking the literal date.
+func splitDateBound(bound string) (string, dateOffset, error) {
+	base, offset := bound, ""
+	if i := strings.LastIndexAny(bound, "+-"); i > 0 && (isDateToken(bound[:i]) || strings.ContainsAny(bound[i:], "ymd")) {
+		base, offset = bound[:i], bound[i:]
+	}
+	var off dateOffset
+	if offset != "" {
+		m := dateOffsetPattern.FindStringSubmatch(offset)
+		if m == nil || offset == m[1] {
+			return "", off, fmt.Errorf("date bound %q has a malformed offset; use one like +1y, -18y or +3m15d", bound)
+		}
+		sign := 1
+		if m[1] == "-" {
+			sign = -1
+		}
+		for i, p := range []*int{&off.years, &off.months, &off.days} {
+			if m[i+2] != "" {
+				n, err := strconv.Atoi(m[i+2])
+				if err != nil {
+					return "", off, fmt.Errorf("date bound %q: offset out of range", bound)
+				}
+				*p = sign * n
+			}
+		}
+	}
+	if base == "" || isDateToken(base) {
+		return base, off, nil
+	}
+	if _, err := ParseDate(base, ISODateFormat); err != nil {
+		return "", off, fmt.Errorf("invalid date bound: %w", err)
+	}
+	return base, off, nil
+}
+
+func isDateToken(s string) bool {
+	return s == DateBoundToday || s == DateBoundTaxYearStart || s == DateBoundTaxYearEnd
+}
+
+// resolveDateBound turns a bound into a literal date, leaving "today", with
+// its offset, for evaluation time. An empty bound stays empty.
+func resolveDateBound(bound string, year int) (string, error) {
+	base, off, err := splitDateBound(bound)
+	if err != nil {
+		return "", err
+	}
+	var d Date
+	switch base {
+	case "", DateBoundToday:
+		return bound, nil
+	case DateBoundTaxYearStart, DateBoundTaxYearEnd:
+		if year == 0 {
+			return "", fmt.Errorf("date bound %q needs a form year", bound)
+		}
+		d = Date{year, time.January, 1}
+		if base == DateBoundTaxYearEnd {
+			d = Date{year, time.December, 31}
+		}
+	default:
+		d, _ = ParseDate(base, ISODateFormat)
+	}
+	return d.add(off).String(), nil
+}
+
+// boundDate returns the date a resolved bound stands for.
+func boundDate(bound string, now time.Time) (Date, error) {
+	base, off, err := splitDateBound(bound)
+	if err != nil {
+		return Date{}, err
+	}
+	if base == DateBoundToday {
+		return DateOf(now).add(off), nil
+	}
+	return ParseDate(bound, ISODateFormat)
+}
@@ -0,0 +1,90 @@
+package annotation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateLeapDays(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want Date
+		ok   bool
+	}{
+		{"02/29/2024", Date{2024, time.February, 29}, true},
+		{"02/29/2000", Date{2000, time.February, 29}, true},
+		{"02/29/2023", Date{}, false},
+		{"02/29/1900", Date{}, false},
+		{"02/30/2024", Date{}, false},
+		{"04/31/2024", Date{}, false},
+		{"2024-02-29", Date{2024, time.February, 29}, true},
+	} {
+		got, err := ParseDate(tc.in, "MM/DD/YYYY")
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("ParseDate(%q) = %v, %v; want %v, ok %v", tc.in, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestAddDateLeapDay(t *testing.T) {
+	leap := Date{2024, time.February, 29}
+	if got, want := leap.AddDate(1, 0, 0), (Date{2025, time.February, 28}); got != want {
+		t.Errorf("leap day plus a year = %v, want %v", got, want)
+	}
+	if got, want := leap.AddDate(4, 0, 0), (Date{2028, time.February, 29}); got != want {
+		t.Errorf("leap day plus four years = %v, want %v", got, want)
+	}
+	if got, want := (Date{2024, time.January, 31}).AddDate(0, 1, 0), leap; got != want {
+		t.Errorf("January 31 plus a month = %v, want %v", got, want)
+	}
+	if got, want := leap.AddDate(0, 0, 1), (Date{2024, time.March, 1}); got != want {
+		t.Errorf("leap day plus a day = %v, want %v", got, want)
+	}
+}
+
+func TestTwoDigitYearExpansion(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int
+	}{
+		{"01/15/00", 2000},
+		{"01/15/49", 2049},
+		{"01/15/50", 1950},
+		{"01/15/99", 1999},
+	} {
+		d, err := ParseDate(tc.in, "MM/DD/YY")
+		if err != nil || d.Year != tc.want {
+			t.Errorf("ParseDate(%q) = %v, %v; want year %d", tc.in, d, err, tc.want)
+		}
+	}
+
+	defer func(pivot int) { TwoDigitYearPivot = pivot }(TwoDigitYearPivot)
+	TwoDigitYearPivot = 30
+	if d, _ := ParseDate("01/15/35", "MM/DD/YY"); d.Year != 1935 {
+		t.Errorf("with pivot 30, 35 expanded to %d, want 1935", d.Year)
+	}
+	if _, err := ParseDate("02/29/01", "MM/DD/YY"); err == nil {
+		t.Error("February 29, 2001 was accepted")
+	}
+	if d, err := ParseDate("02/29/28", "MM/DD/YY"); err != nil || d != (Date{2028, time.February, 29}) {
+		t.Errorf("ParseDate(02/29/28) = %v, %v", d, err)
+	}
+}
+
+func TestSetTypedValueKeepsCivilDate(t *testing.T) {
+	f := &Field{FieldID: "d", FieldType: FieldTypeDate, DataType: DataTypeDate}
+	// Time is noon UTC, which stays on the same day in any zone less
+	// than twelve hours from UTC.
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("east", 11*3600+59*60), time.FixedZone("west", -11*3600-59*60)} {
+		if err := f.SetTypedValue(time.Date(2024, 12, 31, 23, 30, 0, 0, loc)); err != nil {
+			t.Fatal(err)
+		}
+		if f.Value != "2024-12-31" {
+			t.Errorf("in %s: value = %q, want 2024-12-31", loc, f.Value)
+		}
+		d, err := f.DateValue()
+		if err != nil || DateOf(d.Time().In(loc)) != d {
+			t.Errorf("in %s: %v round-trips through Time to %v (%v)", loc, d, DateOf(d.Time().In(loc)), err)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Dependency kinds, named after the expression creating them.
+const (
+	DependsByCalculation = "calculation"
+	DependsByRequiredIf  = "required_if"
+	DependsByVisibleIf   = "visible_if"
+	DependsByDisabledIf  = "disabled_if"
+)
+
+// Dependency is one edge of a DependencyGraph: field From reads field To
+// through the expression of the given kind.
+type Dependency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// DependencyGraph records which fields read which others through their
+// calculations and conditions. Nodes are field IDs in document order.
+type DependencyGraph struct {
+	nodes []string
+	deps  map[string][]Dependency // by From
+	users map[string][]Dependency // by To
+}
+
+// BuildDependencyGraph extracts the dependencies of every field's
+// calculation, required_if, visible_if and disabled_if expressions.
+// Expressions that do not parse and references to fields that do not
+// exist are left out; Validate reports both.
+func (fa *FormAnnotation) BuildDependencyGraph() *DependencyGraph {
+	g := &DependencyGraph{deps: map[string][]Dependency{}, users: map[string][]Dependency{}}
+	for _, field := range fa.Fields() {
+		g.nodes = append(g.nodes, field.FieldID)
+		rules := field.rules()
+		if c := field.Calculation; c != nil {
+			rules = append([]fieldRule{{DependsByCalculation, c.Expr}}, rules...)
+		}
+		for _, rule := range rules {
+			expr, err := ParseExprWithLimits(rule.src, fa.exprLimits())
+			if err != nil {
+				continue
+			}
+			for _, ref := range expr.Refs() {
+				to := fa.GetFieldByID(ref)
+				if to == nil {
+					continue
+				}
+				d := Dependency{From: field.FieldID, To: to.FieldID, Kind: rule.key}
+				if slices.Contains(g.deps[d.From], d) {
+					continue
+				}
+				g.deps[d.From] = append(g.deps[d.From], d)
+				g.users[d.To] = append(g.users[d.To], d)
+			}
+		}
+	}
+	return g
+}
+
+// Fields returns the graph's field IDs in document order.
+func (g *DependencyGraph) Fields() []string { return slices.Clone(g.nodes) }
+
+// Dependencies returns the edges from fieldID to the fields it reads.
+func (g *DependencyGraph) Dependencies(fieldID string) []Dependency {
+	return slices.Clone(g.deps[fieldID])
+}
+
+// Dependents returns the edges from the fields reading fieldID.
+func (g *DependencyGraph) Dependents(fieldID string) []Dependency {
+	return slices.Clone(g.users[fieldID])
+}
+
+// Affected returns the fields whose calculation or conditions may change
+// when fieldID's value does, directly or through other fields, in an order
+// in which they can be updated. fieldID itself is not included.
+func (g *DependencyGraph) Affected(fieldID string) []string {
+	reached := map[string]bool{}
+	queue := []string{fieldID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, d := range g.users[id] {
+			if !reached[d.From] {
+				reached[d.From] = true
+				queue = append(queue, d.From)
+			}
+		}
+	}
+	delete(reached, fieldID)
+	order, _ := g.order()
+	var out []string
+	for _, id := range order {
+		if reached[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// TopologicalOrder returns every field after the fields it reads, keeping
+// document order where dependencies allow. It fails if the graph has a
+// cycle, naming one.
+func (g *DependencyGraph) TopologicalOrder() ([]string, error) {
+	order, ok := g.order()
+	if !ok {
+		cycles := g.Cycles()
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycles[0], " -> "))
+	}
+	return order, nil
+}
+
+// order sorts the fields topologically, picking the earliest ready field
+// in document order at each step. Fields on or behind a cycle are appended
+// in document order, and ok is false.
+func (g *DependencyGraph) order() (order []string, ok bool) {
+	pending := map[string]int{}
+	for _, id := range g.nodes {
+		pending[id] = len(g.deps[id])
+	}
+	placed := map[string]bool{}
+	for len(order) < len(g.nodes) {
+		next := ""
+		for _, id := range g.nodes {
+			if !placed[id] && pending[id] == 0 {
+				next = id
+				break
+			}
+		}
+		if next == "" {
+			for _, id := range g.nodes {
+				if !placed[id] {
+					order = append(order, id)
+				}
+			}
+			return order, false
+		}
+		placed[next] = true
+		order = append(order, next)
+		for _, d := range g.users[next] {
+			pending[d.From]--
+		}
+	}
+	return order, true
+}
+
+// Cycles returns the graph's dependency cycles, each as the field IDs
+// around it ending where it starts, found in document order.
+func (g *DependencyGraph) Cycles() [][]string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := map[string]int{}
+	var stack []string
+	var cycles [][]string
+	var visit func(id string)
+	visit = func(id string) {
+		switch state[id] {
+		case done:
+			return
+		case visiting:
+			at := slices.Index(stack, id)
+			cycles = append(cycles, append(slices.Clone(stack[at:]), id))
+			return
+		}
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, d := range g.deps[id] {
+			visit(d.To)
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = done
+	}
+	for _, id := range g.nodes {
+		visit(id)
+	}
+	return cycles
+}
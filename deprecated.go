@@ -0,0 +1,160 @@
+package annotation
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+)
+
+// StrictAPIMode makes every deprecated function panic, naming its
+// replacement, so that tests and development builds find the callers left
+// to migrate. Building with the annotation_strict tag turns it on. Set it
+// before the package is used; it is not synchronized.
+var StrictAPIMode = strictAPIDefault
+
+var (
+	apiUsageMu sync.Mutex
+	apiUsage   map[string]int
+)
+
+// EnableAPIUsageCounting starts counting calls to deprecated functions, for
+// services that want to see what they still depend on.
+func EnableAPIUsageCounting() {
+	apiUsageMu.Lock()
+	defer apiUsageMu.Unlock()
+	if apiUsage == nil {
+		apiUsage = map[string]int{}
+	}
+}
+
+// APIUsage is the number of calls made to one deprecated function.
+type APIUsage struct {
+	Function    string `json:"function"`
+	Replacement string `json:"replacement"`
+	Calls       int    `json:"calls"`
+}
+
+// APIUsageReport returns the calls counted since EnableAPIUsageCounting,
+// ordered by function name. Functions never called are left out.
+func APIUsageReport() []APIUsage {
+	apiUsageMu.Lock()
+	defer apiUsageMu.Unlock()
+	report := make([]APIUsage, 0, len(apiUsage))
+	for name, n := range apiUsage {
+		report = append(report, APIUsage{Function: name, Replacement: deprecatedAPIs[name], Calls: n})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Function < report[j].Function })
+	return report
+}
+
+// deprecatedAPIs maps each deprecated function to its replacement.
+var deprecatedAPIs = map[string]string{
+	"LoadFromFile":            "LoadFile",
+	"LoadFromFileWithOptions": "LoadFile",
+	"LoadLibrary":             "LoadLibraryStore with a DirStore",
+	"LoadLibraryFS":           "LoadLibraryStore with an FSStore",
+	"GetFieldsByFieldValue":   "FieldsByValuePath",
+	"GetFieldsOnPage":         "FieldsOnPage",
+	"GetFieldsByGroupID":      "FieldsInGroup",
+	"GetAllFields":            "Fields",
+}
+
+// deprecated records a call to a deprecated function, panicking under
+// StrictAPIMode.
+func deprecated(name string) {
+	if StrictAPIMode {
+		panic(fmt.Sprintf("annotation: %s is deprecated; use %s", name, deprecatedAPIs[name]))
+	}
+	apiUsageMu.Lock()
+	defer apiUsageMu.Unlock()
+	if apiUsage != nil {
+		apiUsage[name]++
+	}
+}
+
+// LoadFromFile reads an annotation file with default options.
+//
+// Deprecated: Use LoadFile.
+func LoadFromFile(filepath string) (*FormAnnotation, error) {
+	deprecated("LoadFromFile")
+	return LoadFile(context.Background(), filepath, LoadOptions{})
+}
+
+// LoadFromFileWithOptions reads an annotation file.
+//
+// Deprecated: Use LoadFile.
+func LoadFromFileWithOptions(filepath string, opts LoadOptions) (*FormAnnotation, error) {
+	deprecated("LoadFromFileWithOptions")
+	return LoadFile(context.Background(), filepath, opts)
+}
+
+// LoadLibrary loads every .json annotation under dir.
+//
+// Deprecated: Use LoadLibraryStore with a DirStore.
+func LoadLibrary(dir string, opts LibraryOptions) (*Library, error) {
+	deprecated("LoadLibrary")
+	return LoadLibraryStore(context.Background(), FSStore{FS: os.DirFS(dir)}, "", opts)
+}
+
+// LoadLibraryFS loads every .json annotation in fsys.
+//
+// Deprecated: Use LoadLibraryStore with an FSStore.
+func LoadLibraryFS(fsys fs.FS, opts LibraryOptions) (*Library, error) {
+	deprecated("LoadLibraryFS")
+	return LoadLibraryStore(context.Background(), FSStore{FS: fsys}, "", opts)
+}
+
+// GetFieldsByFieldValue returns copies of the fields bound to a field value path.
+//
+// Deprecated: Use FieldsByValuePath.
+func (fa *FormAnnotation) GetFieldsByFieldValue(fieldValue string) []Field {
+	deprecated("GetFieldsByFieldValue")
+	return fieldCopies(fa.FieldsByValuePath(fieldValue))
+}
+
+// GetFieldsOnPage returns the fields slice of a page. Unlike the other
+// Get functions it does not copy: appending to the result does not add a
+// field, but changing an element changes the page's field.
+//
+// Deprecated: Use FieldsOnPage.
+func (fa *FormAnnotation) GetFieldsOnPage(pageNum int) []Field {
+	deprecated("GetFieldsOnPage")
+	for _, page := range fa.Pages {
+		if page.PageNumber == pageNum {
+			return page.Fields
+		}
+	}
+	return nil
+}
+
+// GetFieldsByGroupID returns copies of the fields belonging to a group.
+//
+// Deprecated: Use FieldsInGroup.
+func (fa *FormAnnotation) GetFieldsByGroupID(groupID string) []Field {
+	deprecated("GetFieldsByGroupID")
+	return fieldCopies(fa.FieldsInGroup(groupID))
+}
+
+// GetAllFields returns copies of all fields across all pages. Changes to
+// the copies do not reach the annotation, and on a large form the copies
+// cost far more than the pointers Fields returns.
+//
+// Deprecated: Use Fields.
+func (fa *FormAnnotation) GetAllFields() []Field {
+	deprecated("GetAllFields")
+	return fieldCopies(fa.Fields())
+}
+
+func fieldCopies(fields []*Field) []Field {
+	if len(fields) == 0 {
+		return nil
+	}
+	out := make([]Field, 0, len(fields))
+	for _, f := range fields {
+		out = append(out, *f)
+	}
+	return out
+}
@@ -0,0 +1,181 @@
+package annotation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// legacy lets a test call deprecated functions whatever the build's
+// StrictAPIMode, with usage counting off, and restores both afterwards.
+func legacy(t testing.TB) {
+	t.Helper()
+	strict := StrictAPIMode
+	StrictAPIMode = false
+	apiUsageMu.Lock()
+	apiUsage = nil
+	apiUsageMu.Unlock()
+	t.Cleanup(func() {
+		StrictAPIMode = strict
+		apiUsageMu.Lock()
+		apiUsage = nil
+		apiUsageMu.Unlock()
+	})
+}
+
+// exampleDir returns a directory holding only the example annotation.
+func exampleDir(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile("example_form_1040_annotation.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "f1040.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func derefFields(fields []*Field) []Field {
+	out := make([]Field, len(fields))
+	for i, f := range fields {
+		out[i] = *f
+	}
+	return out
+}
+
+// deprecatedCalls calls every deprecated function once.
+func deprecatedCalls(t *testing.T) map[string]func() {
+	fa := loadExample(t)
+	dir := exampleDir(t)
+	path := filepath.Join(dir, "f1040.json")
+	return map[string]func(){
+		"LoadFromFile":            func() { LoadFromFile(path) },
+		"LoadFromFileWithOptions": func() { LoadFromFileWithOptions(path, LoadOptions{}) },
+		"LoadLibrary":             func() { LoadLibrary(dir, LibraryOptions{}) },
+		"LoadLibraryFS":           func() { LoadLibraryFS(os.DirFS(dir), LibraryOptions{}) },
+		"GetFieldsByFieldValue":   func() { fa.GetFieldsByFieldValue("taxpayer.ssn") },
+		"GetFieldsOnPage":         func() { fa.GetFieldsOnPage(1) },
+		"GetFieldsByGroupID":      func() { fa.GetFieldsByGroupID("filing_status") },
+		"GetAllFields":            func() { fa.GetAllFields() },
+	}
+}
+
+// TestDeprecatedQueries checks that each deprecated query returns what its
+// replacement does, as copies.
+func TestDeprecatedQueries(t *testing.T) {
+	legacy(t)
+	fa := loadExample(t)
+	tests := []struct {
+		name      string
+		got, want []Field
+	}{
+		{"GetAllFields", fa.GetAllFields(), derefFields(fa.Fields())},
+		{"GetFieldsOnPage", fa.GetFieldsOnPage(1), derefFields(fa.FieldsOnPage(1))},
+		{"GetFieldsByGroupID", fa.GetFieldsByGroupID("filing_status"), derefFields(fa.FieldsInGroup("filing_status"))},
+		{"GetFieldsByFieldValue", fa.GetFieldsByFieldValue("taxpayer.ssn"), derefFields(fa.FieldsByValuePath("taxpayer.ssn"))},
+	}
+	for _, tt := range tests {
+		if len(tt.want) == 0 {
+			t.Errorf("%s: fixture has nothing to return", tt.name)
+		}
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if got := fa.GetFieldsOnPage(99); got != nil {
+		t.Errorf("GetFieldsOnPage(99) = %v", got)
+	}
+	all := fa.GetAllFields()
+	all[0].Value = "changed"
+	if fa.Fields()[0].Value == "changed" {
+		t.Error("GetAllFields returned the annotation's own field")
+	}
+}
+
+func TestDeprecatedLoads(t *testing.T) {
+	legacy(t)
+	dir := exampleDir(t)
+	path := filepath.Join(dir, "f1040.json")
+	want, err := LoadFile(context.Background(), path, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, load := range map[string]func() (*FormAnnotation, error){
+		"LoadFromFile":            func() (*FormAnnotation, error) { return LoadFromFile(path) },
+		"LoadFromFileWithOptions": func() (*FormAnnotation, error) { return LoadFromFileWithOptions(path, LoadOptions{}) },
+	} {
+		got, err := load()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s loaded a different annotation than LoadFile", name)
+		}
+	}
+
+	store, err := LoadLibraryStore(context.Background(), DirStore{Dir: dir}, "", LibraryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, load := range map[string]func() (*Library, error){
+		"LoadLibrary":   func() (*Library, error) { return LoadLibrary(dir, LibraryOptions{}) },
+		"LoadLibraryFS": func() (*Library, error) { return LoadLibraryFS(os.DirFS(dir), LibraryOptions{}) },
+	} {
+		lib, err := load()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(lib.Forms(), store.Forms()) {
+			t.Errorf("%s loaded different forms than LoadLibraryStore", name)
+		}
+	}
+}
+
+func TestStrictAPIMode(t *testing.T) {
+	legacy(t)
+	calls := deprecatedCalls(t)
+	for name := range deprecatedAPIs {
+		if calls[name] == nil {
+			t.Errorf("%s is not exercised by this test", name)
+		}
+	}
+	StrictAPIMode = true
+	for name, call := range calls {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, name) || !strings.Contains(msg, deprecatedAPIs[name]) {
+					t.Errorf("%s panicked with %q, want its name and replacement", name, msg)
+				}
+			}()
+			call()
+		}()
+	}
+}
+
+func TestAPIUsageReport(t *testing.T) {
+	legacy(t)
+	calls := deprecatedCalls(t)
+	calls["GetAllFields"]()
+	if report := APIUsageReport(); len(report) != 0 {
+		t.Errorf("counted %v before counting was enabled", report)
+	}
+	EnableAPIUsageCounting()
+	calls["GetAllFields"]()
+	calls["GetAllFields"]()
+	calls["LoadFromFile"]()
+	want := []APIUsage{
+		{Function: "GetAllFields", Replacement: "Fields", Calls: 2},
+		{Function: "LoadFromFile", Replacement: "LoadFile", Calls: 1},
+	}
+	if got := APIUsageReport(); !reflect.DeepEqual(got, want) {
+		t.Errorf("report = %v, want %v", got, want)
+	}
+}
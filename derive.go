@@ -0,0 +1,69 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Derivation lists the fields DeriveForYear changed, by ID, in the order
+// the overrides give them.
+type Derivation struct {
+	Year     int      `json:"year"`
+	Replaced []string `json:"replaced,omitempty"`
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+}
+
+// DeriveForYear returns a copy of base for a new tax year with the fields
+// of overrides applied. Each override field replaces the base field with
+// its ID, moving it to the override's page when that differs; one marked
+// Removed deletes it instead; and one with a new ID is appended to its
+// page. Group member lists follow the fields' group IDs. Every page the
+// overrides name must exist in base, and every removed field; otherwise
+// nothing is derived and the error says which. A nil overrides only sets
+// the year.
+func DeriveForYear(base *FormAnnotation, year int, overrides *FormAnnotation) (*FormAnnotation, Derivation, error) {
+	d := Derivation{Year: year}
+	out := base.Clone()
+	out.FormMetadata.Year = year
+	if overrides == nil {
+		return out, d, nil
+	}
+	for _, page := range overrides.Pages {
+		target := slices.IndexFunc(out.Pages, func(p Page) bool { return p.PageNumber == page.PageNumber })
+		if target < 0 {
+			return nil, Derivation{}, fmt.Errorf("derive for %d: overrides name page %d, which the base does not have", year, page.PageNumber)
+		}
+		for _, override := range page.Fields {
+			field := override.Clone()
+			field.Removed = false
+			if override.Removed {
+				if err := out.RemoveField(field.FieldID); err != nil {
+					return nil, Derivation{}, fmt.Errorf("derive for %d: page %d removes field %q, which the base does not have", year, page.PageNumber, field.FieldID)
+				}
+				d.Removed = append(d.Removed, field.FieldID)
+				continue
+			}
+			existing, pageNum := out.fieldAndPage(field.FieldID)
+			switch {
+			case existing == nil:
+				d.Added = append(d.Added, field.FieldID)
+			case pageNum == page.PageNumber:
+				oldGroup := existing.GroupID
+				*existing = field
+				if field.GroupID != oldGroup {
+					out.leaveGroups(field.FieldID)
+					out.joinGroup(&field)
+				}
+				d.Replaced = append(d.Replaced, field.FieldID)
+				continue
+			default:
+				out.RemoveField(field.FieldID)
+				d.Replaced = append(d.Replaced, field.FieldID)
+			}
+			out.Pages[target].Fields = append(out.Pages[target].Fields, field)
+			out.joinGroup(&field)
+		}
+	}
+	return out, d, nil
+}
@@ -0,0 +1,330 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of annotation differences.
+const (
+	DiffAdded    = "added"
+	DiffRemoved  = "removed"
+	DiffModified = "modified"
+	DiffMoved    = "moved"
+	DiffRetyped  = "retyped"
+)
+
+// AnnotationDiff is the difference between two versions of an annotation.
+// Fields and groups are matched by ID, so reordering them is not a change.
+type AnnotationDiff struct {
+	Metadata []Change    `json:"metadata,omitempty"`
+	Pages    []PageDiff  `json:"pages,omitempty"`
+	Fields   []FieldDiff `json:"fields,omitempty"`
+	Groups   []GroupDiff `json:"groups,omitempty"`
+}
+
+// Change is one attribute that differs, named by its JSON path, as in
+// "position.y" or "validation.max". Old or New is absent when the attribute
+// was added or removed.
+type Change struct {
+	Path string          `json:"path"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+	// Note is "tightened" or "loosened" for validation bounds.
+	Note string `json:"note,omitempty"`
+}
+
+// PageDiff reports a page added, removed or with changed includes or frame.
+type PageDiff struct {
+	Page    int      `json:"page"`
+	Kind    string   `json:"kind"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// FieldDiff reports a field added, removed, moved to another page,
+// retyped or modified. A moved or retyped field may also carry other
+// changes; a field both moved and retyped is reported as moved.
+type FieldDiff struct {
+	FieldID string   `json:"field_id"`
+	Page    int      `json:"page"`
+	OldPage int      `json:"old_page,omitempty"`
+	Kind    string   `json:"kind"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// GroupDiff reports a field group added, removed or modified.
+type GroupDiff struct {
+	GroupID string   `json:"group_id"`
+	Kind    string   `json:"kind"`
+	Changes []Change `json:"changes,omitempty"`
+}
+
+// Empty reports whether the versions are the same.
+func (d *AnnotationDiff) Empty() bool {
+	return len(d.Metadata) == 0 && len(d.Pages) == 0 && len(d.Fields) == 0 && len(d.Groups) == 0
+}
+
+// Diff compares two versions of an annotation. Fields are matched by ID,
+// so a field on a different page is reported as moved rather than removed
+// and added, and one whose field type changed as retyped. Filled values
+// and provenance are not compared.
+func Diff(old, new *FormAnnotation) *AnnotationDiff {
+	d := &AnnotationDiff{Metadata: diffJSON(old.FormMetadata, new.FormMetadata)}
+
+	oldPages, newPages := map[int]*Page{}, map[int]*Page{}
+	for i := range old.Pages {
+		oldPages[old.Pages[i].PageNumber] = &old.Pages[i]
+	}
+	for i := range new.Pages {
+		newPages[new.Pages[i].PageNumber] = &new.Pages[i]
+	}
+	for _, n := range sortedUnion(oldPages, newPages) {
+		was, is := oldPages[n], newPages[n]
+		switch {
+		case was == nil:
+			d.Pages = append(d.Pages, PageDiff{Page: n, Kind: DiffAdded})
+		case is == nil:
+			d.Pages = append(d.Pages, PageDiff{Page: n, Kind: DiffRemoved})
+		default:
+			strip := func(p *Page) Page { out := *p; out.Fields = nil; return out }
+			if changes := diffJSON(strip(was), strip(is)); len(changes) > 0 {
+				d.Pages = append(d.Pages, PageDiff{Page: n, Kind: DiffModified, Changes: changes})
+			}
+		}
+	}
+
+	type placed struct {
+		field *Field
+		page  int
+	}
+	fields := func(fa *FormAnnotation) map[string]placed {
+		out := map[string]placed{}
+		for _, page := range fa.Pages {
+			for i := range page.Fields {
+				out[page.Fields[i].FieldID] = placed{&page.Fields[i], page.PageNumber}
+			}
+		}
+		return out
+	}
+	oldFields, newFields := fields(old), fields(new)
+	for _, id := range sortedUnion(oldFields, newFields) {
+		was, wasOK := oldFields[id]
+		is, isOK := newFields[id]
+		switch {
+		case !wasOK:
+			d.Fields = append(d.Fields, FieldDiff{FieldID: id, Page: is.page, Kind: DiffAdded})
+		case !isOK:
+			d.Fields = append(d.Fields, FieldDiff{FieldID: id, Page: was.page, Kind: DiffRemoved})
+		default:
+			strip := func(f *Field) Field { out := *f; out.Value, out.Provenance = "", nil; return out }
+			fd := FieldDiff{FieldID: id, Page: is.page, Kind: DiffModified, Changes: diffJSON(strip(was.field), strip(is.field))}
+			switch {
+			case was.page != is.page:
+				fd.Kind, fd.OldPage = DiffMoved, was.page
+			case was.field.FieldType != is.field.FieldType:
+				fd.Kind = DiffRetyped
+			}
+			if fd.Kind != DiffModified || len(fd.Changes) > 0 {
+				d.Fields = append(d.Fields, fd)
+			}
+		}
+	}
+	sort.SliceStable(d.Fields, func(i, j int) bool { return d.Fields[i].Page < d.Fields[j].Page })
+
+	groups := func(fa *FormAnnotation) map[string]*FieldGroup {
+		out := map[string]*FieldGroup{}
+		for i := range fa.FieldGroups {
+			out[fa.FieldGroups[i].GroupID] = &fa.FieldGroups[i]
+		}
+		return out
+	}
+	oldGroups, newGroups := groups(old), groups(new)
+	for _, id := range sortedUnion(oldGroups, newGroups) {
+		was, is := oldGroups[id], newGroups[id]
+		switch {
+		case was == nil:
+			d.Groups = append(d.Groups, GroupDiff{GroupID: id, Kind: DiffAdded})
+		case is == nil:
+			d.Groups = append(d.Groups, GroupDiff{GroupID: id, Kind: DiffRemoved})
+		default:
+			if changes := diffJSON(was, is); len(changes) > 0 {
+				d.Groups = append(d.Groups, GroupDiff{GroupID: id, Kind: DiffModified, Changes: changes})
+			}
+		}
+	}
+	return d
+}
+
+// String summarizes the diff one change per line, as in
+// `page 1: field "line_2b" position.y 410 -> 415.5`.
+func (d *AnnotationDiff) String() string {
+	var sb strings.Builder
+	line := func(prefix string, c Change) {
+		fmt.Fprintf(&sb, "%s %s %s -> %s", prefix, c.Path, rawText(c.Old), rawText(c.New))
+		if c.Note != "" {
+			fmt.Fprintf(&sb, " (%s)", c.Note)
+		}
+		sb.WriteByte('\n')
+	}
+	for _, c := range d.Metadata {
+		line("metadata:", c)
+	}
+	for _, p := range d.Pages {
+		if p.Kind != DiffModified {
+			fmt.Fprintf(&sb, "page %d: %s\n", p.Page, p.Kind)
+		}
+		for _, c := range p.Changes {
+			line(fmt.Sprintf("page %d:", p.Page), c)
+		}
+	}
+	for _, f := range d.Fields {
+		prefix := fmt.Sprintf("page %d: field %q", f.Page, f.FieldID)
+		switch f.Kind {
+		case DiffAdded, DiffRemoved:
+			fmt.Fprintf(&sb, "%s %s\n", prefix, f.Kind)
+		case DiffMoved:
+			fmt.Fprintf(&sb, "%s moved from page %d\n", prefix, f.OldPage)
+		case DiffRetyped:
+			fmt.Fprintf(&sb, "%s retyped\n", prefix)
+		}
+		for _, c := range f.Changes {
+			line(prefix, c)
+		}
+	}
+	for _, g := range d.Groups {
+		prefix := fmt.Sprintf("group %q", g.GroupID)
+		if g.Kind != DiffModified {
+			fmt.Fprintf(&sb, "%s %s\n", prefix, g.Kind)
+		}
+		for _, c := range g.Changes {
+			line(prefix, c)
+		}
+	}
+	return sb.String()
+}
+
+func rawText(v json.RawMessage) string {
+	if v == nil {
+		return "(none)"
+	}
+	return string(v)
+}
+
+// diffJSON compares a and b attribute by attribute through their JSON
+// encodings. Arrays of objects are compared element by element; other
+// arrays as a whole.
+func diffJSON(a, b any) []Change {
+	fa, fb := flattenJSON(a), flattenJSON(b)
+	var changes []Change
+	for _, path := range sortedUnion(fa, fb) {
+		was, is := fa[path], fb[path]
+		if string(was) == string(is) {
+			continue
+		}
+		changes = append(changes, Change{Path: path, Old: was, New: is, Note: validationNote(path, was, is)})
+	}
+	return changes
+}
+
+func flattenJSON(v any) map[string]json.RawMessage {
+	data, err := json.Marshal(v)
+	out := map[string]json.RawMessage{}
+	if err != nil {
+		return out
+	}
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return out
+	}
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if prefix != "" {
+					k = prefix + "." + k
+				}
+				walk(k, child)
+			}
+			return
+		case []any:
+			if len(v) > 0 {
+				if _, ok := v[0].(map[string]any); ok {
+					for i, child := range v {
+						walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+					}
+					return
+				}
+			}
+		case nil:
+			return
+		}
+		raw, _ := json.Marshal(v)
+		out[prefix] = raw
+	}
+	walk("", doc)
+	return out
+}
+
+// validationNote says whether a change to a validation bound accepts fewer
+// values or more.
+func validationNote(path string, was, is json.RawMessage) string {
+	rule, ok := strings.CutPrefix(path, "validation.")
+	if !ok {
+		return ""
+	}
+	num := func(raw json.RawMessage) (float64, bool) {
+		n, err := strconv.ParseFloat(string(raw), 64)
+		return n, err == nil
+	}
+	tighter := func(yes bool) string {
+		if yes {
+			return "tightened"
+		}
+		return "loosened"
+	}
+	switch rule {
+	case "required":
+		return tighter(string(is) == "true")
+	case "min", "min_length", "max", "max_length":
+		if was == nil || is == nil {
+			return tighter(was == nil)
+		}
+		a, okA := num(was)
+		b, okB := num(is)
+		if !okA || !okB {
+			return ""
+		}
+		if strings.HasPrefix(rule, "min") {
+			return tighter(b > a)
+		}
+		return tighter(b < a)
+	case "pattern", "required_if", "min_date", "max_date":
+		if was == nil || is == nil {
+			return tighter(was == nil)
+		}
+	}
+	return ""
+}
+
+// sortedUnion returns the keys of a and b in sorted order.
+func sortedUnion[K int | string, V any](a, b map[K]V) []K {
+	seen := map[K]bool{}
+	var keys []K
+	for _, m := range []map[K]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,8 @@
+// Package annotation describes where the fields of a printed form sit on
+// its pages and what they hold, and loads, validates, fills and renders
+// those descriptions.
+//
+// The package needs Go 1.23 or later, since FieldsSeq and StreamFields
+// return iterators from the iter package for use in range-over-func loops.
+// It depends only on the standard library.
+package annotation
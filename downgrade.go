@@ -0,0 +1,537 @@
+package annotation
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+)
+
+// CapabilityProfile names the feature set a consumer can honor.
+type CapabilityProfile struct {
+	Name      string
+	Supported CapabilitySet
+}
+
+// LegacyPrintProfile describes renderers that understand only text,
+// checkbox and date fields at absolute positions, with their values.
+var LegacyPrintProfile = CapabilityProfile{Name: "legacy_print", Supported: NewCapabilitySet(CapFilledValues)}
+
+// DowngradeChange records one transformation made by Downgrade. Lossy
+// changes drop information the original annotation carried.
+type DowngradeChange struct {
+	Capability Capability `json:"capability"`
+	FieldID    string     `json:"field_id,omitempty"`
+	GroupID    string     `json:"group_id,omitempty"`
+	Action     string     `json:"action"`
+	Lossy      bool       `json:"lossy"`
+}
+
+// DowngradeReport lists every transformation Downgrade made.
+type DowngradeReport struct {
+	Profile string            `json:"profile"`
+	Changes []DowngradeChange `json:"changes"`
+}
+
+// Lossy reports whether any change dropped information.
+func (r *DowngradeReport) Lossy() bool {
+	for _, c := range r.Changes {
+		if c.Lossy {
+			return true
+		}
+	}
+	return false
+}
+
+// downgraders rewrite a copy of an annotation so that it no longer uses a
+// capability. Capabilities without a downgrader cannot be removed.
+var downgraders = map[Capability]func(fa *FormAnnotation, r *DowngradeReport){
+	CapSegmentedFields:         downgradeSegments,
+	CapVirtualFields:           downgradeVirtual,
+	CapNumericFields:           downgradeFieldType(CapNumericFields, FieldTypeCurrency, FieldTypeNumeric),
+	CapSignatureFields:         downgradeFieldType(CapSignatureFields, FieldTypeSignature),
+	CapFieldGroups:             downgradeGroups,
+	CapFormatting:              downgradeFields(CapFormatting, "dropped formatting", func(f *Field) bool { return clearPtr(&f.Formatting) }),
+	CapConditionalRequirements: downgradeFields(CapConditionalRequirements, "dropped required_if", func(f *Field) bool { return f.Validation != nil && clearString(&f.Validation.RequiredIf) }),
+	CapFilledValues:            downgradeFields(CapFilledValues, "dropped filled value", func(f *Field) bool { return clearString(&f.Value) }),
+	CapSensitiveFields:         downgradeFields(CapSensitiveFields, "dropped sensitive flag and sensitivity", dropSensitivity),
+	CapReadOnlyFields:          downgradeFields(CapReadOnlyFields, "dropped read_only flag", func(f *Field) bool { return clearFlag(&f.ReadOnly) }),
+	CapOptionCodes:             downgradeOptions,
+	CapFractionalSizes:         downgradeFields(CapFractionalSizes, "rounded font and mark sizes", roundSizes),
+	CapHelpContent:             downgradeFields(CapHelpContent, "dropped help content", func(f *Field) bool { return clearPtr(&f.Help) }),
+	CapSignatureWorkflow:       downgradeFields(CapSignatureWorkflow, "dropped signer role and date field", func(f *Field) bool { return clearPtr(&f.Signature) }),
+	CapCalculations:            downgradeCalculations,
+	CapConditionalVisibility:   downgradeVisibility,
+	CapRenderOverrides:         downgradeOverrides,
+	CapYearRanges:              downgradeYears,
+	CapYesNoGroups:             downgradeYesNo,
+	CapCoordinateFrames:        downgradeFrames,
+	CapPacketParts:             downgradeParts,
+	CapRepeatingRows:           downgradeRepeats,
+	CapCoordinateOrigins:       downgradeOrigin,
+	CapRoundingPolicy:          downgradeRounding,
+	CapPageLayout:              downgradePageLayout,
+	CapGroupValidation:         downgradeGroupValidation,
+	CapProvenance:              downgradeHistory,
+	CapRelativePositions:       downgradeRelative,
+	CapLocalizedLabels: downgradeFields(CapLocalizedLabels, "dropped tooltip and localized labels", func(f *Field) bool {
+		had := f.Tooltip != "" || len(f.Localized) > 0
+		f.Tooltip, f.Localized = "", nil
+		return had
+	}),
+	CapBarcodeFields: downgradeFields(CapBarcodeFields, "converted barcode field to text and dropped its settings", func(f *Field) bool {
+		had := f.FieldType == FieldTypeBarcode || f.Barcode != nil
+		if f.FieldType == FieldTypeBarcode {
+			f.FieldType = FieldTypeText
+		}
+		f.Barcode = nil
+		return had
+	}),
+	CapImageFields: downgradeFields(CapImageFields, "converted image field to text and dropped its settings and picture", func(f *Field) bool {
+		had := f.FieldType == FieldTypeImage || f.Image != nil
+		if f.FieldType == FieldTypeImage {
+			f.FieldType, f.Value = FieldTypeText, ""
+		}
+		f.Image = nil
+		return had
+	}),
+	CapTabOrder: downgradeFields(CapTabOrder, "dropped tab index", func(f *Field) bool {
+		had := f.TabIndex != 0
+		f.TabIndex = 0
+		return had
+	}),
+	CapSegmentLayout: downgradeFields(CapSegmentLayout, "dropped segment alignment and strip characters", func(f *Field) bool {
+		if !f.segmentLayout() {
+			return false
+		}
+		f.Formatting.SegmentAlign, f.Formatting.StripChars = "", ""
+		return true
+	}),
+	CapValueTransforms: downgradeFields(CapValueTransforms, "dropped value transforms", func(f *Field) bool {
+		had := len(f.Transforms) > 0
+		f.Transforms = nil
+		return had
+	}),
+	CapRequirementLevels: downgradeFields(CapRequirementLevels, "mapped requirement level to required", func(f *Field) bool {
+		if f.Validation == nil || f.Validation.Level == "" {
+			return false
+		}
+		f.Validation.Required = f.Validation.Level == RequirementHard && f.Validation.RequiredIf == ""
+		f.Validation.Level = ""
+		return true
+	}),
+	CapChoiceFields: downgradeFields(CapChoiceFields, "converted choice field to text showing its option label", func(f *Field) bool {
+		had := f.FieldType == FieldTypeChoice || len(f.Options) > 0
+		if f.FieldType == FieldTypeChoice {
+			f.FieldType = FieldTypeText
+		}
+		if f.Value != "" {
+			f.Value = f.optionLabel(f.Value)
+		}
+		f.Options = nil
+		return had
+	}),
+}
+
+// Downgrade returns a copy of the annotation simplified for a consumer that
+// supports only profile's capabilities, with every transformation listed in
+// the report. The result is checked with RequireCapabilities; an error means
+// some feature could not be removed.
+func (fa *FormAnnotation) Downgrade(profile CapabilityProfile) (*FormAnnotation, DowngradeReport, error) {
+	out := fa.Clone()
+	report := DowngradeReport{Profile: profile.Name}
+	// Calculations and visibility go first, while every value they read,
+	// virtual ones included, is still there. Segments and virtual fields
+	// follow: flattening segments produces plain text fields, which later
+	// steps then treat like any other field.
+	used := fa.Capabilities()
+	first := []Capability{CapCalculations, CapConditionalVisibility, CapSegmentedFields, CapVirtualFields}
+	order := append([]Capability{}, first...)
+	for _, c := range used.List() {
+		if !slices.Contains(first, c) {
+			order = append(order, c)
+		}
+	}
+	for _, c := range order {
+		if profile.Supported.Has(c) || !used.Has(c) {
+			continue
+		}
+		if downgrade, ok := downgraders[c]; ok {
+			downgrade(out, &report)
+		}
+	}
+	if err := out.RequireCapabilities(profile.Supported); err != nil {
+		return nil, report, fmt.Errorf("downgrade to %s: %w", profile.Name, err)
+	}
+	return out, report, nil
+}
+
+// downgradeSegments flattens each segmented field into one text field
+// spanning its segments, with letter spacing that approximates the combs.
+func downgradeSegments(fa *FormAnnotation, r *DowngradeReport) {
+	unit := fa.FormMetadata.PageSize.Unit
+	eachField(fa, func(f *Field) {
+		if f.FieldType != FieldTypeSegmented && len(f.Segments) == 0 {
+			return
+		}
+		var span Position
+		chars := 0
+		for i, seg := range f.Segments {
+			p, ok := positionInPoints(seg.Position, unit)
+			if !ok {
+				continue
+			}
+			if i == 0 {
+				span = p
+			} else {
+				x0, y0 := min(span.X, p.X), min(span.Y, p.Y)
+				span.Width = max(span.X+span.Width, p.X+p.Width) - x0
+				span.Height = max(span.Y+span.Height, p.Y+p.Height) - y0
+				span.X, span.Y = x0, y0
+			}
+			chars += seg.Length
+		}
+		if f.Value != "" {
+			f.Value = strings.Join(splitSegments(f.Value, f.Segments), "")
+		}
+		span.Unit = "pt"
+		f.Position = span
+		f.Segments = nil
+		f.FieldType = FieldTypeText
+		if chars > 0 {
+			style := TextStyle{}
+			if f.Style != nil {
+				style = *f.Style
+			}
+			size := style.FontSize
+			if size == 0 {
+				size = span.Height * 0.6
+			}
+			// Monospaced digits are roughly 0.6em wide; spread them to the comb pitch.
+			style.LetterSpacing = max(0, span.Width/float64(chars)-size*0.6)
+			f.Style = &style
+		}
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapSegmentedFields, FieldID: f.FieldID,
+			Action: "flattened segments into one text field with letter spacing", Lossy: true})
+	})
+}
+
+// downgradeVirtual removes virtual fields, which such consumers cannot
+// place. Calculations and conditions that read a virtual field are
+// materialized first, so the fields they drive keep what they show.
+func downgradeVirtual(fa *FormAnnotation, r *DowngradeReport) {
+	virtual := map[string]bool{}
+	eachField(fa, func(f *Field) {
+		if f.IsVirtual() {
+			virtual[f.FieldID] = true
+		}
+	})
+	readsVirtual := func(src string) bool {
+		expr, err := ParseExprWithLimits(src, fa.exprLimits())
+		return err == nil && slices.ContainsFunc(expr.Refs(), func(id string) bool { return virtual[id] })
+	}
+	materializeCalculations(fa, CapVirtualFields, func(f *Field) bool {
+		return !virtual[f.FieldID] && readsVirtual(f.Calculation.Expr)
+	}, r)
+	materializeVisibility(fa, CapVirtualFields, func(f *Field) bool {
+		return !virtual[f.FieldID] && (readsVirtual(f.Conditions.VisibleIf) || readsVirtual(f.Conditions.DisabledIf))
+	}, r)
+	for _, id := range slices.Sorted(maps.Keys(virtual)) {
+		f := fa.GetFieldByID(id)
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapVirtualFields, FieldID: id,
+			Action: "removed virtual field", Lossy: f.Value != ""})
+		_ = fa.RemoveField(id)
+	}
+}
+
+// downgradeCalculations stores every calculation's current result in its
+// field's value, as a reader of the original would see it, and drops the
+// calculations.
+func downgradeCalculations(fa *FormAnnotation, r *DowngradeReport) {
+	materializeCalculations(fa, CapCalculations, func(*Field) bool { return true }, r)
+}
+
+// downgradeVisibility removes the fields their conditions currently hide
+// and drops the conditions of the rest.
+func downgradeVisibility(fa *FormAnnotation, r *DowngradeReport) {
+	materializeVisibility(fa, CapConditionalVisibility, func(*Field) bool { return true }, r)
+}
+
+// materializeCalculations recalculates the annotation, then drops the
+// calculations of the fields which selects, leaving their results as plain
+// values. A calculation that fails leaves its field's stored value.
+func materializeCalculations(fa *FormAnnotation, c Capability, which func(f *Field) bool, r *DowngradeReport) {
+	failed := map[string]bool{}
+	for _, issue := range fa.Recalculate().Issues {
+		failed[issue.FieldID] = true
+	}
+	eachField(fa, func(f *Field) {
+		if f.Calculation == nil || !which(f) {
+			return
+		}
+		f.Calculation = nil
+		action := "stored calculated value and dropped calculation"
+		if failed[f.FieldID] {
+			action = "dropped calculation that could not be evaluated, keeping the stored value"
+		}
+		r.Changes = append(r.Changes, DowngradeChange{Capability: c, FieldID: f.FieldID, Action: action, Lossy: true})
+	})
+}
+
+// materializeVisibility evaluates the conditions of the fields which
+// selects against the current values, removes those hidden and drops the
+// conditions of the others. A condition that fails to evaluate leaves its
+// field visible, as EvaluateConditions does.
+func materializeVisibility(fa *FormAnnotation, c Capability, which func(f *Field) bool, r *DowngradeReport) {
+	lookup := fa.valueLookup()
+	var hidden []string
+	eachField(fa, func(f *Field) {
+		if f.Conditions == nil || !which(f) {
+			return
+		}
+		visible, _, err := conditionState(f.Conditions.VisibleIf, "", fa.exprLimits(), lookup)
+		if err == nil && !visible {
+			hidden = append(hidden, f.FieldID)
+			return
+		}
+		f.Conditions = nil
+		r.Changes = append(r.Changes, DowngradeChange{Capability: c, FieldID: f.FieldID,
+			Action: "dropped visibility conditions of a shown field", Lossy: true})
+	})
+	for _, id := range hidden {
+		r.Changes = append(r.Changes, DowngradeChange{Capability: c, FieldID: id,
+			Action: "removed field hidden by its conditions", Lossy: true})
+		_ = fa.RemoveField(id)
+	}
+}
+
+// downgradeFieldType turns fields of the given types into text fields,
+// keeping their values as written.
+func downgradeFieldType(c Capability, types ...FieldType) func(*FormAnnotation, *DowngradeReport) {
+	return func(fa *FormAnnotation, r *DowngradeReport) {
+		eachField(fa, func(f *Field) {
+			for _, t := range types {
+				if f.FieldType == t {
+					r.Changes = append(r.Changes, DowngradeChange{Capability: c, FieldID: f.FieldID,
+						Action: fmt.Sprintf("converted %s field to text", t), Lossy: t == FieldTypeSignature})
+					f.FieldType = FieldTypeText
+					return
+				}
+			}
+		})
+	}
+}
+
+func downgradeGroups(fa *FormAnnotation, r *DowngradeReport) {
+	for _, g := range fa.FieldGroups {
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapFieldGroups, GroupID: g.GroupID,
+			Action: "removed field group", Lossy: true})
+	}
+	fa.FieldGroups = nil
+	eachField(fa, func(f *Field) { f.GroupID = "" })
+}
+
+func downgradeOptions(fa *FormAnnotation, r *DowngradeReport) {
+	for i := range fa.FieldGroups {
+		if len(fa.FieldGroups[i].ExpectedOptions) > 0 {
+			fa.FieldGroups[i].ExpectedOptions = nil
+			r.Changes = append(r.Changes, DowngradeChange{Capability: CapOptionCodes, GroupID: fa.FieldGroups[i].GroupID,
+				Action: "dropped expected options", Lossy: true})
+		}
+	}
+	downgradeFields(CapOptionCodes, "dropped option code", func(f *Field) bool { return clearString(&f.OptionCode) })(fa, r)
+}
+
+func downgradeGroupValidation(fa *FormAnnotation, r *DowngradeReport) {
+	for i := range fa.FieldGroups {
+		if clearPtr(&fa.FieldGroups[i].Validation) {
+			r.Changes = append(r.Changes, DowngradeChange{Capability: CapGroupValidation, GroupID: fa.FieldGroups[i].GroupID,
+				Action: "dropped group validation", Lossy: true})
+		}
+	}
+}
+
+func downgradeOverrides(fa *FormAnnotation, r *DowngradeReport) {
+	if len(fa.FormMetadata.RenderTargets) > 0 {
+		fa.FormMetadata.RenderTargets = nil
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapRenderOverrides,
+			Action: "dropped declared render targets", Lossy: true})
+	}
+	downgradeFields(CapRenderOverrides, "dropped render overrides", func(f *Field) bool {
+		had := len(f.Overrides) > 0
+		f.Overrides = nil
+		return had
+	})(fa, r)
+}
+
+// downgradeYesNo turns yes/no groups into radio groups, which keep the two
+// boxes exclusive but lose the requirement.
+func downgradeYesNo(fa *FormAnnotation, r *DowngradeReport) {
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		if g.GroupType == GroupTypeYesNo || g.Required {
+			if g.GroupType == GroupTypeYesNo {
+				g.GroupType = GroupTypeRadio
+			}
+			g.Required = false
+			r.Changes = append(r.Changes, DowngradeChange{Capability: CapYesNoGroups, GroupID: g.GroupID,
+				Action: "converted yes/no group to radio", Lossy: true})
+		}
+	}
+}
+
+// downgradeFrames moves every position into the media box, which older
+// readers assume.
+func downgradeFrames(fa *FormAnnotation, r *DowngradeReport) {
+	if err := fa.NormalizeToMediaBox(); err != nil {
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapCoordinateFrames,
+			Action: fmt.Sprintf("could not normalize to the media box: %v", err), Lossy: true})
+		return
+	}
+	r.Changes = append(r.Changes, DowngradeChange{Capability: CapCoordinateFrames, Action: "normalized positions to the media box"})
+}
+
+// downgradeOrigin measures positions from the top-left corner again.
+func downgradeOrigin(fa *FormAnnotation, r *DowngradeReport) {
+	if err := fa.TransformOrigin(OriginTopLeft); err != nil {
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapCoordinateOrigins,
+			Action: fmt.Sprintf("could not move to the top-left origin: %v", err), Lossy: true})
+		return
+	}
+	r.Changes = append(r.Changes, DowngradeChange{Capability: CapCoordinateOrigins, Action: "moved positions to the top-left origin"})
+}
+
+func downgradeRounding(fa *FormAnnotation, r *DowngradeReport) {
+	fa.FormMetadata.Rounding = nil
+	r.Changes = append(r.Changes, DowngradeChange{Capability: CapRoundingPolicy, Action: "dropped rounding policy", Lossy: true})
+}
+
+// downgradePageLayout drops page rotations and page size overrides; fields
+// keep their positions, so pages sized differently from the document are
+// checked against the document size from then on.
+func downgradePageLayout(fa *FormAnnotation, r *DowngradeReport) {
+	for i := range fa.Pages {
+		page := &fa.Pages[i]
+		if page.Rotation != 0 || clearPtr(&page.PageSize) {
+			page.Rotation = 0
+			r.Changes = append(r.Changes, DowngradeChange{Capability: CapPageLayout,
+				Action: fmt.Sprintf("dropped rotation and page size of page %d", page.PageNumber), Lossy: true})
+		}
+	}
+}
+
+// downgradeYears materializes the annotation for its form year.
+func downgradeYears(fa *FormAnnotation, r *DowngradeReport) {
+	year := fa.FormMetadata.Year
+	for _, page := range fa.Pages {
+		for _, field := range page.Fields {
+			if !field.ActiveIn(year) {
+				r.Changes = append(r.Changes, DowngradeChange{Capability: CapYearRanges, FieldID: field.FieldID,
+					Action: fmt.Sprintf("removed field not on the %d form", year), Lossy: true})
+			}
+		}
+	}
+	*fa = *fa.ForYear(year)
+	downgradeFields(CapYearRanges, "dropped year range", func(f *Field) bool {
+		introduced := clearPtr(&f.IntroducedYear)
+		return clearPtr(&f.RetiredYear) || introduced
+	})(fa, r)
+}
+
+// downgradeFields applies drop to every field and records a lossy change
+// for each field it altered.
+func downgradeFields(c Capability, action string, drop func(f *Field) bool) func(*FormAnnotation, *DowngradeReport) {
+	return func(fa *FormAnnotation, r *DowngradeReport) {
+		eachField(fa, func(f *Field) {
+			if drop(f) {
+				r.Changes = append(r.Changes, DowngradeChange{Capability: c, FieldID: f.FieldID, Action: action, Lossy: true})
+			}
+		})
+	}
+}
+
+func eachField(fa *FormAnnotation, fn func(f *Field)) {
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			fn(&fa.Pages[i].Fields[j])
+		}
+	}
+}
+
+func clearPtr[T any](p **T) bool {
+	if *p == nil {
+		return false
+	}
+	*p = nil
+	return true
+}
+
+func clearString(s *string) bool {
+	if *s == "" {
+		return false
+	}
+	*s = ""
+	return true
+}
+
+func dropSensitivity(f *Field) bool {
+	flagged := clearFlag(&f.Sensitive)
+	return clearString(&f.Sensitivity) || flagged
+}
+
+func clearFlag(b *bool) bool {
+	if !*b {
+		return false
+	}
+	*b = false
+	return true
+}
+
+// roundSizes rounds fractional font and mark sizes to whole points.
+func roundSizes(f *Field) bool {
+	changed := false
+	if f.Style != nil && f.Style.FontSize != math.Round(f.Style.FontSize) {
+		f.Style.FontSize = math.Round(f.Style.FontSize)
+		changed = true
+	}
+	if f.CheckStyle != nil && f.CheckStyle.MarkSize != math.Round(f.CheckStyle.MarkSize) {
+		f.CheckStyle.MarkSize = math.Round(f.CheckStyle.MarkSize)
+		changed = true
+	}
+	return changed
+}
+
+// downgradeRepeats drops repeat blocks. Rows already expanded stay as
+// ordinary members of their groups.
+func downgradeRepeats(fa *FormAnnotation, r *DowngradeReport) {
+	for i := range fa.FieldGroups {
+		if g := &fa.FieldGroups[i]; g.Repeat != nil {
+			g.Repeat = nil
+			r.Changes = append(r.Changes, DowngradeChange{Capability: CapRepeatingRows, GroupID: g.GroupID, Action: "dropped repeat block"})
+		}
+	}
+}
+
+// downgradeParts drops the packet parts, leaving a packet that renders the
+// same but can no longer be split.
+func downgradeParts(fa *FormAnnotation, r *DowngradeReport) {
+	fa.FormMetadata.Parts = nil
+	r.Changes = append(r.Changes, DowngradeChange{Capability: CapPacketParts, Action: "dropped packet parts"})
+}
+
+// downgradeRelative resolves relative positions, so every field keeps the
+// place its anchor gives it, then drops the relative_to blocks and anchors.
+// A form whose references cannot be resolved keeps its stored positions.
+func downgradeRelative(fa *FormAnnotation, r *DowngradeReport) {
+	_ = fa.ResolvePositions()
+	for i := range fa.Pages {
+		page := &fa.Pages[i]
+		for j := range page.Fields {
+			if clearPtr(&page.Fields[j].RelativeTo) {
+				r.Changes = append(r.Changes, DowngradeChange{Capability: CapRelativePositions, FieldID: page.Fields[j].FieldID, Action: "resolved relative position"})
+			}
+		}
+		page.Anchors = nil
+	}
+}
@@ -0,0 +1,179 @@
+package annotation
+
+import (
+	"reflect"
+	"testing"
+)
+
+// renderedValues is what a reader of fa sees: the text of every visible,
+// rendered field once calculations have run, with choices showing their
+// option labels.
+func renderedValues(fa *FormAnnotation) map[string]string {
+	fa = fa.Clone()
+	fa.Recalculate()
+	visible := map[string]bool{}
+	for _, st := range fa.EvaluateConditions() {
+		visible[st.FieldID] = st.Visible
+	}
+	out := map[string]string{}
+	for _, f := range fa.Fields() {
+		if f.IsVirtual() || !visible[f.FieldID] || f.Value == "" {
+			continue
+		}
+		shown := *f
+		shown.Value = f.optionLabel(f.Value)
+		out[f.FieldID] = shown.displayText()
+	}
+	return out
+}
+
+// stampedValues is the text a renderer draws for each field of fa.
+func stampedValues(t *testing.T, fa *FormAnnotation) map[string]string {
+	t.Helper()
+	plan, report := fa.BuildStampPlan(FillOptions{})
+	if len(report.Issues) > 0 {
+		t.Fatalf("stamp plan issues: %v", report.Issues)
+	}
+	out := map[string]string{}
+	for _, item := range plan.Items {
+		out[item.FieldID] = item.Text
+	}
+	return out
+}
+
+func downgradeForm(fields ...Field) *FormAnnotation {
+	for i := range fields {
+		if !fields[i].IsVirtual() {
+			fields[i].Position = Position{X: 36, Y: 36 + float64(i)*24, Width: 200, Height: 18, Unit: "pt"}
+		}
+		if fields[i].DataType == "" {
+			fields[i].DataType = DataTypeString
+		}
+	}
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages:        []Page{{PageNumber: 1, Fields: fields}},
+	}
+}
+
+func TestDowngradePreservesRenderedValues(t *testing.T) {
+	plainProfile := CapabilityProfile{Name: "plain",
+		Supported: NewCapabilitySet(CapFilledValues, CapCalculations, CapConditionalVisibility)}
+	tests := []struct {
+		name       string
+		capability Capability
+		profile    CapabilityProfile
+		form       *FormAnnotation
+		want       map[string]string
+	}{
+		{
+			name: "calculations", capability: CapCalculations, profile: LegacyPrintProfile,
+			form: downgradeForm(
+				Field{FieldID: "wages", FieldType: FieldTypeText, DataType: DataTypeDecimal, Value: "1000"},
+				Field{FieldID: "interest", FieldType: FieldTypeText, DataType: DataTypeDecimal, Value: "20.5"},
+				// The stored total is stale; readers of the original see the recalculated one.
+				Field{FieldID: "total", FieldType: FieldTypeText, DataType: DataTypeDecimal, Value: "5",
+					Calculation: &Calculation{Expr: "wages + interest"}},
+				Field{FieldID: "doubled", FieldType: FieldTypeText, DataType: DataTypeDecimal,
+					Calculation: &Calculation{Expr: "total * 2"}},
+			),
+			want: map[string]string{"wages": "1000", "interest": "20.5", "total": "1020.5", "doubled": "2041"},
+		},
+		{
+			name: "conditional visibility", capability: CapConditionalVisibility, profile: LegacyPrintProfile,
+			form: downgradeForm(
+				Field{FieldID: "status", FieldType: FieldTypeText, Value: "single"},
+				Field{FieldID: "spouse", FieldType: FieldTypeText, Value: "Bo",
+					Conditions: &Conditions{VisibleIf: `status == "joint"`}},
+				Field{FieldID: "note", FieldType: FieldTypeText, Value: "alone",
+					Conditions: &Conditions{VisibleIf: `status == "single"`}},
+			),
+			want: map[string]string{"status": "single", "note": "alone"},
+		},
+		{
+			name: "choice fields", capability: CapChoiceFields, profile: LegacyPrintProfile,
+			form: downgradeForm(
+				Field{FieldID: "state", FieldType: FieldTypeChoice, Value: "CA",
+					Options: []ChoiceOption{{Value: "CA", Label: "California"}, {Value: "NV", Label: "Nevada"}}},
+				Field{FieldID: "plain", FieldType: FieldTypeChoice, Value: "x",
+					Options: []ChoiceOption{{Value: "x"}}},
+			),
+			want: map[string]string{"state": "California", "plain": "x"},
+		},
+		{
+			name: "virtual fields", capability: CapVirtualFields, profile: plainProfile,
+			form: downgradeForm(
+				Field{FieldID: "agi", FieldType: FieldTypeVirtual, DataType: DataTypeDecimal, Value: "5000"},
+				Field{FieldID: "wages", FieldType: FieldTypeText, DataType: DataTypeDecimal, Value: "10"},
+				Field{FieldID: "agi_copy", FieldType: FieldTypeText, DataType: DataTypeDecimal,
+					Calculation: &Calculation{Expr: "agi"}},
+				Field{FieldID: "wages_copy", FieldType: FieldTypeText, DataType: DataTypeDecimal,
+					Calculation: &Calculation{Expr: "wages"}},
+				Field{FieldID: "high", FieldType: FieldTypeText, Value: "yes",
+					Conditions: &Conditions{VisibleIf: "agi > 1000"}},
+				Field{FieldID: "low", FieldType: FieldTypeText, Value: "yes",
+					Conditions: &Conditions{VisibleIf: "agi <= 1000"}},
+			),
+			want: map[string]string{"wages": "10", "agi_copy": "5000", "wages_copy": "10", "high": "yes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderedValues(tt.form); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("original renders %v, want %v", got, tt.want)
+			}
+			before, _ := tt.form.ToJSON()
+			out, report, err := tt.form.Downgrade(tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stampedValues(t, out); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("downgraded form stamps %v, want %v", got, tt.want)
+			}
+			if out.Capabilities().Has(tt.capability) {
+				t.Errorf("downgraded form still uses %s", tt.capability)
+			}
+			var recorded bool
+			for _, c := range report.Changes {
+				recorded = recorded || c.Capability == tt.capability
+			}
+			if !recorded {
+				t.Errorf("report has no %s change: %v", tt.capability, report.Changes)
+			}
+			if after, _ := tt.form.ToJSON(); after != before {
+				t.Error("downgrading changed the original")
+			}
+		})
+	}
+}
+
+// TestDowngradeVirtualKeepsRules checks that with calculations and
+// conditions supported, only the rules that read a virtual field are
+// materialized.
+func TestDowngradeVirtualKeepsRules(t *testing.T) {
+	fa := downgradeForm(
+		Field{FieldID: "agi", FieldType: FieldTypeVirtual, DataType: DataTypeDecimal, Value: "5000"},
+		Field{FieldID: "wages", FieldType: FieldTypeText, DataType: DataTypeDecimal, Value: "10"},
+		Field{FieldID: "agi_copy", FieldType: FieldTypeText, DataType: DataTypeDecimal, Calculation: &Calculation{Expr: "agi"}},
+		Field{FieldID: "wages_copy", FieldType: FieldTypeText, DataType: DataTypeDecimal, Calculation: &Calculation{Expr: "wages"}},
+	)
+	profile := CapabilityProfile{Name: "plain", Supported: NewCapabilitySet(CapFilledValues, CapCalculations)}
+	out, _, err := fa.Downgrade(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.GetFieldByID("agi") != nil {
+		t.Error("virtual field kept")
+	}
+	if out.GetFieldByID("agi_copy").Calculation != nil {
+		t.Error("calculation reading the virtual field kept")
+	}
+	if out.GetFieldByID("wages_copy").Calculation == nil {
+		t.Error("calculation not reading a virtual field dropped")
+	}
+	for _, issue := range out.Validate() {
+		if issue.Code == UnknownReference {
+			t.Errorf("dangling reference: %v", issue)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package editproto is the message protocol a collaborative editor uses to
+// exchange incremental annotation edits. It holds the wire types and the
+// server-side session state machine only; moving messages between peers is
+// left to the transport, such as a WebSocket or JSON-RPC channel.
+//
+// Every accepted batch of ops advances the session revision by one. A
+// client sends ApplyOps naming the revision its ops were made against. If
+// ops were accepted since then, the batch is rebased over them when no op
+// in it touches a property one of them changed, and rejected with a
+// Conflict carrying those ops otherwise. Ops on different properties
+// commute, so every peer that applies the OpsApplied broadcasts in
+// revision order reaches the same document.
+package editproto
+
+import (
+	"encoding/json"
+	"fmt"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// Version is the protocol version written in every message.
+const Version = 1
+
+// Op types.
+const (
+	OpSetValue    = "set_value"
+	OpSetLabel    = "set_label"
+	OpSetPosition = "set_position"
+)
+
+// Op is one edit to one property of one field.
+type Op struct {
+	Type     string               `json:"type"`
+	FieldID  string               `json:"field_id"`
+	Value    string               `json:"value,omitempty"`
+	Position *annotation.Position `json:"position,omitempty"`
+}
+
+// target is the property an op writes; two ops conflict when they share one.
+func (op Op) target() string {
+	return op.Type + "\x00" + op.FieldID
+}
+
+// Apply performs the op on fa. A set_value is placed as SetValues places
+// it, so read-only fields and placement checks refuse it as they refuse
+// any fill.
+func (op Op) Apply(fa *annotation.FormAnnotation) error {
+	field := fa.GetFieldByID(op.FieldID)
+	if field == nil {
+		return fmt.Errorf("%s: no field %q", op.Type, op.FieldID)
+	}
+	switch op.Type {
+	case OpSetValue:
+		report := fa.SetValues(map[string]string{field.FieldID: op.Value}, annotation.FillOptions{})
+		for _, issue := range report.Issues {
+			if issue.Severity == annotation.SeverityError {
+				return fmt.Errorf("%s: field %q: %s", op.Type, op.FieldID, issue.Message)
+			}
+		}
+	case OpSetLabel:
+		field.Label = op.Value
+	case OpSetPosition:
+		if op.Position == nil {
+			return fmt.Errorf("%s: field %q: no position", op.Type, op.FieldID)
+		}
+		field.Position = *op.Position
+	default:
+		return fmt.Errorf("unknown op type %q", op.Type)
+	}
+	return nil
+}
+
+// Message types.
+const (
+	TypeApplyOps   = "apply_ops"
+	TypeOpsApplied = "ops_applied"
+	TypeConflict   = "conflict"
+)
+
+// Message is one of ApplyOps, OpsApplied or Conflict.
+type Message interface {
+	messageType() string
+}
+
+// ApplyOps is sent by a client: ops made against BaseRevision.
+type ApplyOps struct {
+	ClientID     string `json:"client_id"`
+	BatchID      string `json:"batch_id,omitempty"`
+	BaseRevision int    `json:"base_revision"`
+	Ops          []Op   `json:"ops"`
+}
+
+// OpsApplied is broadcast for every accepted batch. Ops are as applied,
+// after rebasing, and Revision is the revision they produced.
+type OpsApplied struct {
+	ClientID string `json:"client_id"`
+	BatchID  string `json:"batch_id,omitempty"`
+	Revision int    `json:"revision"`
+	Ops      []Op   `json:"ops"`
+}
+
+// Conflict is sent back to a client whose batch was rejected. Diverging
+// holds the accepted batches since its base revision that touch the same
+// properties; the client applies them, resolves, and resends against
+// Revision.
+type Conflict struct {
+	ClientID  string       `json:"client_id"`
+	BatchID   string       `json:"batch_id,omitempty"`
+	Revision  int          `json:"revision"`
+	Diverging []OpsApplied `json:"diverging"`
+}
+
+func (ApplyOps) messageType() string   { return TypeApplyOps }
+func (OpsApplied) messageType() string { return TypeOpsApplied }
+func (Conflict) messageType() string   { return TypeConflict }
+
+type envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	Body    json.RawMessage `json:"body"`
+}
+
+// Encode writes msg as a versioned JSON message.
+func Encode(msg Message) ([]byte, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(envelope{Version: Version, Type: msg.messageType(), Body: body})
+}
+
+// Decode reads a message written by Encode. Messages from a newer protocol
+// version are refused.
+func Decode(data []byte) (Message, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, err
+	}
+	if env.Version != Version {
+		return nil, fmt.Errorf("protocol version %d is not supported (want %d)", env.Version, Version)
+	}
+	var msg Message
+	switch env.Type {
+	case TypeApplyOps:
+		msg = &ApplyOps{}
+	case TypeOpsApplied:
+		msg = &OpsApplied{}
+	case TypeConflict:
+		msg = &Conflict{}
+	default:
+		return nil, fmt.Errorf("unknown message type %q", env.Type)
+	}
+	if err := json.Unmarshal(env.Body, msg); err != nil {
+		return nil, fmt.Errorf("%s: %w", env.Type, err)
+	}
+	return msg, nil
+}
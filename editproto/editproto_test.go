@@ -0,0 +1,65 @@
+package editproto
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// testForm returns a one-page form with n text fields, f1 to fn.
+func testForm(n int) *annotation.FormAnnotation {
+	fa := &annotation.FormAnnotation{FormMetadata: annotation.FormMetadata{
+		FormID: "test", PageCount: 1, PageSize: annotation.PageSize{Width: 612, Height: 792, Unit: "pt"},
+	}}
+	page := annotation.Page{PageNumber: 1}
+	for i := range n {
+		page.Fields = append(page.Fields, annotation.Field{
+			FieldID: fieldID(i), FieldType: annotation.FieldTypeText, DataType: annotation.DataTypeString,
+			Position: annotation.Position{X: 36, Y: 36 + float64(i)*24, Width: 200, Height: 18, Unit: "pt"},
+		})
+	}
+	fa.Pages = []annotation.Page{page}
+	return fa
+}
+
+func fieldID(i int) string { return "f" + strconv.Itoa(i+1) }
+
+func TestSetValueRefusesReadOnlyField(t *testing.T) {
+	fa := testForm(1)
+	fa.Pages[0].Fields[0].ReadOnly = true
+	err := Op{Type: OpSetValue, FieldID: "f1", Value: "x"}.Apply(fa)
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Fatalf("err = %v, want a read-only refusal", err)
+	}
+	if v := fa.GetFieldByID("f1").Value; v != "" {
+		t.Errorf("value = %q, want it unchanged", v)
+	}
+
+	s := NewSession(fa)
+	if _, err := s.Apply(ApplyOps{ClientID: "a", Ops: []Op{{Type: OpSetValue, FieldID: "f1", Value: "x"}}}); err == nil {
+		t.Error("session accepted a set_value on a read-only field")
+	}
+	if s.Revision() != 0 {
+		t.Errorf("revision = %d, want 0", s.Revision())
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	msg := ApplyOps{ClientID: "a", BatchID: "b1", BaseRevision: 3, Ops: []Op{{Type: OpSetLabel, FieldID: "f1", Value: "Name"}}}
+	data, err := Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back, ok := got.(*ApplyOps); !ok || back.BatchID != "b1" || back.Ops[0] != msg.Ops[0] {
+		t.Errorf("decoded %#v", got)
+	}
+	if _, err := Decode([]byte(`{"v":2,"type":"apply_ops","body":{}}`)); err == nil {
+		t.Error("decoded a message from a newer protocol version")
+	}
+}
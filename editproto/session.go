@@ -0,0 +1,98 @@
+package editproto
+
+import (
+	"fmt"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// SessionState is the authoritative copy of a document being edited and
+// the history of accepted batches. It is not safe for concurrent use; the
+// transport serializes incoming messages.
+type SessionState struct {
+	doc     *annotation.FormAnnotation
+	history []OpsApplied // history[i] produced revision i+1
+}
+
+// NewSession starts a session at revision 0 on a copy of fa.
+func NewSession(fa *annotation.FormAnnotation) *SessionState {
+	return &SessionState{doc: fa.Clone()}
+}
+
+// Revision returns the number of batches accepted so far.
+func (s *SessionState) Revision() int { return len(s.history) }
+
+// Document returns a copy of the document at the current revision.
+func (s *SessionState) Document() *annotation.FormAnnotation { return s.doc.Clone() }
+
+// Since returns the batches accepted after rev, for a client catching up.
+func (s *SessionState) Since(rev int) ([]OpsApplied, error) {
+	if rev < 0 || rev > len(s.history) {
+		return nil, fmt.Errorf("revision %d is outside 0-%d", rev, len(s.history))
+	}
+	return append([]OpsApplied(nil), s.history[rev:]...), nil
+}
+
+// Apply handles a client batch and returns the message to send: an
+// OpsApplied to broadcast to every client, including the sender, or a
+// Conflict for the sender alone. A batch whose ops cannot be applied,
+// such as one naming an unknown field, is an error and changes nothing.
+func (s *SessionState) Apply(msg ApplyOps) (Message, error) {
+	since, err := s.Since(msg.BaseRevision)
+	if err != nil {
+		return nil, err
+	}
+	touched := map[string]bool{}
+	for _, op := range msg.Ops {
+		touched[op.target()] = true
+	}
+	var diverging []OpsApplied
+	for _, batch := range since {
+		for _, op := range batch.Ops {
+			if touched[op.target()] {
+				diverging = append(diverging, batch)
+				break
+			}
+		}
+	}
+	if len(diverging) > 0 {
+		return Conflict{ClientID: msg.ClientID, BatchID: msg.BatchID, Revision: s.Revision(), Diverging: diverging}, nil
+	}
+	next := s.doc.Clone()
+	for _, op := range msg.Ops {
+		if err := op.Apply(next); err != nil {
+			return nil, err
+		}
+	}
+	s.doc = next
+	applied := OpsApplied{ClientID: msg.ClientID, BatchID: msg.BatchID, Revision: s.Revision() + 1,
+		Ops: append([]Op(nil), msg.Ops...)}
+	s.history = append(s.history, applied)
+	return applied, nil
+}
+
+// Replica is a client's copy of the session document, advanced by the
+// OpsApplied broadcasts in revision order.
+type Replica struct {
+	Doc      *annotation.FormAnnotation
+	Revision int
+}
+
+// Receive applies a broadcast. Broadcasts must arrive in revision order;
+// one that skips a revision is an error, and one already applied is ignored.
+func (r *Replica) Receive(msg OpsApplied) error {
+	switch {
+	case msg.Revision <= r.Revision:
+		return nil
+	case msg.Revision != r.Revision+1:
+		return fmt.Errorf("broadcast for revision %d received at revision %d", msg.Revision, r.Revision)
+	}
+	next := r.Doc.Clone()
+	for _, op := range msg.Ops {
+		if err := op.Apply(next); err != nil {
+			return fmt.Errorf("revision %d: %w", msg.Revision, err)
+		}
+	}
+	r.Doc, r.Revision = next, msg.Revision
+	return nil
+}
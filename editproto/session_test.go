@@ -0,0 +1,118 @@
+package editproto
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// client is one peer in a simulated session: its replica, the broadcasts
+// the transport has yet to deliver to it, and a batch awaiting resend
+// after a conflict.
+type client struct {
+	id      string
+	replica Replica
+	inbox   []OpsApplied
+	pending []Op
+}
+
+func randomOps(rng *rand.Rand, fields int) []Op {
+	ops := make([]Op, 1+rng.IntN(3))
+	for i := range ops {
+		id := fieldID(rng.IntN(fields))
+		switch rng.IntN(3) {
+		case 0:
+			ops[i] = Op{Type: OpSetValue, FieldID: id, Value: fmt.Sprintf("v%d", rng.IntN(100))}
+		case 1:
+			ops[i] = Op{Type: OpSetLabel, FieldID: id, Value: fmt.Sprintf("Label %d", rng.IntN(100))}
+		default:
+			ops[i] = Op{Type: OpSetPosition, FieldID: id, Position: &annotation.Position{
+				X: float64(36 + rng.IntN(300)), Y: float64(36 + rng.IntN(600)), Width: 120, Height: 18, Unit: "pt"}}
+		}
+	}
+	return ops
+}
+
+// TestInterleavedClientsConverge replays random interleavings of batches
+// from several clients, each made against whatever revision its replica
+// has reached, with broadcasts delivered late and conflicting batches
+// resent after catching up. Once every broadcast is delivered, every
+// replica must equal the session document.
+func TestInterleavedClientsConverge(t *testing.T) {
+	const fields = 4
+	for seed := range uint64(200) {
+		rng := rand.New(rand.NewPCG(seed, 0))
+		fa := testForm(fields)
+		s := NewSession(fa)
+		clients := make([]*client, 2+rng.IntN(3))
+		for i := range clients {
+			clients[i] = &client{id: fmt.Sprintf("c%d", i), replica: Replica{Doc: fa.Clone()}}
+		}
+		deliver := func(c *client) {
+			if err := c.replica.Receive(c.inbox[0]); err != nil {
+				t.Fatalf("seed %d: %s: %v", seed, c.id, err)
+			}
+			c.inbox = c.inbox[1:]
+		}
+		accepted, conflicts := 0, 0
+		for step := 0; step < 60; step++ {
+			c := clients[rng.IntN(len(clients))]
+			if len(c.inbox) > 0 && rng.IntN(2) == 0 {
+				deliver(c)
+				continue
+			}
+			ops := c.pending
+			if ops == nil {
+				ops = randomOps(rng, fields)
+			}
+			msg := ApplyOps{ClientID: c.id, BatchID: fmt.Sprintf("%s-%d", c.id, step), BaseRevision: c.replica.Revision, Ops: ops}
+			reply, err := s.Apply(msg)
+			if err != nil {
+				t.Fatalf("seed %d: apply: %v", seed, err)
+			}
+			switch reply := reply.(type) {
+			case OpsApplied:
+				accepted++
+				c.pending = nil
+				for _, other := range clients {
+					other.inbox = append(other.inbox, reply)
+				}
+			case Conflict:
+				conflicts++
+				if len(reply.Diverging) == 0 || reply.Revision != s.Revision() {
+					t.Fatalf("seed %d: conflict %+v", seed, reply)
+				}
+				c.pending = ops
+			}
+		}
+		for _, c := range clients {
+			for len(c.inbox) > 0 {
+				deliver(c)
+			}
+		}
+		if accepted == 0 {
+			t.Fatalf("seed %d: no batch was accepted", seed)
+		}
+		want := s.Document()
+		for _, c := range clients {
+			if c.replica.Revision != s.Revision() {
+				t.Errorf("seed %d: %s is at revision %d, session at %d", seed, c.id, c.replica.Revision, s.Revision())
+			}
+			if !annotation.Equal(c.replica.Doc, want) {
+				t.Errorf("seed %d: %s diverged from the session document after %d batches and %d conflicts", seed, c.id, accepted, conflicts)
+			}
+		}
+	}
+}
+
+func TestSinceRejectsUnknownRevision(t *testing.T) {
+	s := NewSession(testForm(1))
+	if _, err := s.Since(1); err == nil {
+		t.Error("Since(1) at revision 0 succeeded")
+	}
+	if _, err := s.Apply(ApplyOps{ClientID: "a", BaseRevision: 2}); err == nil {
+		t.Error("Apply against a future revision succeeded")
+	}
+}
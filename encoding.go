@@ -0,0 +1,98 @@
+package annotation
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Encoding names the character encoding of an annotation file.
+type Encoding string
+
+const (
+	// EncodingUTF8 is the only encoding annotations are written in.
+	EncodingUTF8 Encoding = ""
+	// EncodingLatin1 is ISO 8859-1.
+	EncodingLatin1 Encoding = "latin1"
+	// EncodingWindows1252 is the Windows superset of Latin-1.
+	EncodingWindows1252 Encoding = "windows-1252"
+)
+
+// LoadOptions controls how annotation files are read.
+type LoadOptions struct {
+	// SourceEncoding, when set, transcodes input that is not valid UTF-8
+	// from this encoding. Valid UTF-8 input is never transcoded.
+	SourceEncoding Encoding
+	// IgnoreReaderVersion loads documents written for a newer reader instead
+	// of refusing them, for inspection tools that do not interpret them.
+	IgnoreReaderVersion bool
+	// Gates opens experimental capabilities; documents using one whose
+	// gate is closed are refused with an *UngatedFeatureError.
+	Gates FeatureGates
+	// OnStripped, when set, receives what GateStrip gates removed.
+	OnStripped func(DowngradeReport)
+	// Strict refuses documents with keys the schema does not define, values
+	// of the wrong JSON type, or enumerated values such as field types
+	// outside the known sets, with a *StrictError listing every one.
+	// Without it such keys are ignored and unknown values loaded as they
+	// are, so that documents from newer writers still load.
+	Strict bool
+}
+
+// NotUTF8Error reports input that is not UTF-8 and was not transcoded.
+type NotUTF8Error struct {
+	Offset int
+	Byte   byte
+}
+
+func (e *NotUTF8Error) Error() string {
+	return fmt.Sprintf("file is not UTF-8: invalid byte 0x%02x at offset %d (set LoadOptions.SourceEncoding to transcode)", e.Byte, e.Offset)
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// decodeInput strips a leading UTF-8 byte order mark and returns the input
+// as UTF-8, transcoding from opts.SourceEncoding when it is not already.
+func decodeInput(data []byte, opts LoadOptions) ([]byte, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if utf8.Valid(data) {
+		return data, nil
+	}
+	switch opts.SourceEncoding {
+	case EncodingUTF8:
+		offset := 0
+		for offset < len(data) {
+			r, size := utf8.DecodeRune(data[offset:])
+			if r == utf8.RuneError && size <= 1 {
+				break
+			}
+			offset += size
+		}
+		return nil, &NotUTF8Error{Offset: offset, Byte: data[offset]}
+	case EncodingLatin1, EncodingWindows1252:
+		var b strings.Builder
+		for _, c := range data {
+			b.WriteRune(decodeSingleByte(c, opts.SourceEncoding))
+		}
+		return []byte(b.String()), nil
+	default:
+		return nil, fmt.Errorf("unsupported source encoding %q", opts.SourceEncoding)
+	}
+}
+
+// windows1252 maps the bytes 0x80-0x9F, where Windows-1252 differs from
+// Latin-1; zero entries are undefined and decode as in Latin-1.
+var windows1252 = [32]rune{
+	'€', 0, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0, 'Ž', 0,
+	0, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0, 'ž', 'Ÿ',
+}
+
+func decodeSingleByte(c byte, enc Encoding) rune {
+	if enc == EncodingWindows1252 && c >= 0x80 && c < 0xA0 {
+		if r := windows1252[c-0x80]; r != 0 {
+			return r
+		}
+	}
+	return rune(c)
+}
@@ -0,0 +1,247 @@
+package annotation
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Encrypted values are stored in Field.Value as
+//
+//	enc:<key id>:<base64 ciphertext>
+//
+// so every value names the key that sealed it and can be rotated on its own.
+const encryptedPrefix = "enc:"
+
+// ValueCipher seals and opens field values under one key.
+type ValueCipher interface {
+	// KeyID identifies the key; it is recorded with every value it seals
+	// and must not contain ':'.
+	KeyID() string
+	Encrypt(plaintext []byte) ([]byte, error)
+	Decrypt(ciphertext []byte) ([]byte, error)
+}
+
+type aesGCMCipher struct {
+	keyID string
+	aead  cipher.AEAD
+}
+
+// NewAESGCMCipher returns a ValueCipher using AES-GCM with a 16, 24 or
+// 32 byte key. Each ciphertext carries its own random nonce.
+func NewAESGCMCipher(keyID string, key []byte) (ValueCipher, error) {
+	if keyID == "" || strings.Contains(keyID, ":") {
+		return nil, fmt.Errorf("invalid key ID %q", keyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMCipher{keyID: keyID, aead: aead}, nil
+}
+
+func (c *aesGCMCipher) KeyID() string { return c.keyID }
+
+func (c *aesGCMCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (c *aesGCMCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext is too short")
+	}
+	return c.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+}
+
+// encryptedKeyID returns the key ID of an encrypted value.
+func encryptedKeyID(value string) (string, bool) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", false
+	}
+	keyID, _, ok := strings.Cut(rest, ":")
+	return keyID, ok
+}
+
+func sealValue(c ValueCipher, plaintext string) (string, error) {
+	sealed, err := c.Encrypt([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + c.KeyID() + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openValue(c ValueCipher, value string) (string, error) {
+	keyID, ok := encryptedKeyID(value)
+	if !ok {
+		return "", errors.New("value is not encrypted")
+	}
+	if keyID != c.KeyID() {
+		return "", fmt.Errorf("value is sealed with key %q, not %q", keyID, c.KeyID())
+	}
+	payload := strings.TrimPrefix(value, encryptedPrefix+keyID+":")
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("malformed ciphertext: %w", err)
+	}
+	plaintext, err := c.Decrypt(sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// IsEncrypted reports whether the field's value is stored encrypted.
+func (f *Field) IsEncrypted() bool {
+	_, ok := encryptedKeyID(f.Value)
+	return ok
+}
+
+// EncryptSensitiveValues seals the filled value of every sensitive field
+// that is not already encrypted and returns how many were sealed.
+func (fa *FormAnnotation) EncryptSensitiveValues(c ValueCipher) (int, error) {
+	n := 0
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			if field.Value == "" || field.IsEncrypted() || !field.IsSensitive() {
+				continue
+			}
+			sealed, err := sealValue(c, field.Value)
+			if err != nil {
+				return n, fmt.Errorf("field %q: %w", field.FieldID, err)
+			}
+			field.Value = sealed
+			n++
+		}
+	}
+	return n, nil
+}
+
+// DecryptValues opens every value sealed with c's key in place. Values under
+// other keys are left as they are.
+func (fa *FormAnnotation) DecryptValues(c ValueCipher) error {
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			if keyID, ok := encryptedKeyID(field.Value); !ok || keyID != c.KeyID() {
+				continue
+			}
+			plaintext, err := openValue(c, field.Value)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", field.FieldID, err)
+			}
+			field.Value = plaintext
+		}
+	}
+	return nil
+}
+
+// RotationFailure is one value that could not be rotated.
+type RotationFailure struct {
+	FieldID string `json:"field_id"`
+	Reason  string `json:"reason"`
+}
+
+// RotationReport counts the outcome of a key rotation.
+type RotationReport struct {
+	Rotated  int               `json:"rotated"`
+	Skipped  int               `json:"skipped"`
+	Failed   int               `json:"failed"`
+	Failures []RotationFailure `json:"failures,omitempty"`
+}
+
+// RotateEncryption re-seals every encrypted value from oldCipher's key under
+// newCipher's in one pass. Values already under the new key are skipped, so
+// an interrupted rotation can simply be run again. The annotation is changed
+// only if every value rotates; otherwise it is left untouched and the report
+// lists the failures.
+func (fa *FormAnnotation) RotateEncryption(oldCipher, newCipher ValueCipher) (RotationReport, error) {
+	report, rotated := fa.rotate(oldCipher, newCipher, false)
+	if report.Failed > 0 {
+		return report, fmt.Errorf("key rotation failed for %d fields", report.Failed)
+	}
+	fa.Pages = rotated.Pages
+	return report, nil
+}
+
+// CheckRotation is the dry run of RotateEncryption: it verifies that every
+// value not yet under the new key opens under oldCipher, without sealing or
+// changing anything.
+func (fa *FormAnnotation) CheckRotation(oldCipher, newCipher ValueCipher) RotationReport {
+	report, _ := fa.rotate(oldCipher, newCipher, true)
+	return report
+}
+
+func (fa *FormAnnotation) rotate(oldCipher, newCipher ValueCipher, dryRun bool) (RotationReport, *FormAnnotation) {
+	var report RotationReport
+	out := fa
+	if !dryRun {
+		out = fa.Clone()
+	}
+	for i := range out.Pages {
+		for j := range out.Pages[i].Fields {
+			field := &out.Pages[i].Fields[j]
+			keyID, ok := encryptedKeyID(field.Value)
+			if !ok {
+				continue
+			}
+			if keyID == newCipher.KeyID() {
+				report.Skipped++
+				continue
+			}
+			fail := func(err error) {
+				report.Failed++
+				report.Failures = append(report.Failures, RotationFailure{FieldID: field.FieldID, Reason: err.Error()})
+			}
+			plaintext, err := openValue(oldCipher, field.Value)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			if !dryRun {
+				sealed, err := sealValue(newCipher, plaintext)
+				if err != nil {
+					fail(err)
+					continue
+				}
+				field.Value = sealed
+			}
+			report.Rotated++
+		}
+	}
+	return report, out
+}
+
+// RotateFile rotates the keys of the annotation file at path and replaces it
+// atomically, so readers see either the old file or the fully rotated one.
+// Nothing is written when any value fails to rotate or none needed to.
+func RotateFile(path string, oldCipher, newCipher ValueCipher) (RotationReport, error) {
+	fa, err := LoadFile(context.Background(), path, LoadOptions{})
+	if err != nil {
+		return RotationReport{}, err
+	}
+	report, err := fa.RotateEncryption(oldCipher, newCipher)
+	if err != nil || report.Rotated == 0 {
+		return report, err
+	}
+	data, err := fa.ToJSON()
+	if err != nil {
+		return report, err
+	}
+	return report, writeFileAtomic(path, strings.NewReader(data))
+}
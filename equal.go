@@ -0,0 +1,116 @@
+package annotation
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// EqualOptions controls EqualWithOptions.
+type EqualOptions struct {
+	// IgnoreFieldOrder compares each page's fields, and each group's
+	// members, as sets keyed by field ID rather than in document order.
+	IgnoreFieldOrder bool
+	// FloatTolerance is how far apart two numbers, such as coordinates
+	// converted between units, may be and still be equal.
+	FloatTolerance float64
+}
+
+// Equal reports whether a and b describe the same document: everything
+// they would save, including values and history, compared exactly and in
+// order. Two nil annotations are equal.
+func Equal(a, b *FormAnnotation) bool {
+	return EqualWithOptions(a, b, EqualOptions{})
+}
+
+// EqualWithOptions is Equal with options.
+func EqualWithOptions(a, b *FormAnnotation, opts EqualOptions) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	da, err := equalDoc(a, opts)
+	if err != nil {
+		return false
+	}
+	db, err := equalDoc(b, opts)
+	if err != nil {
+		return false
+	}
+	return equalJSON(da, db, opts.FloatTolerance)
+}
+
+// equalDoc is the generic JSON document of fa, with fields and group
+// members sorted by ID under IgnoreFieldOrder.
+func equalDoc(fa *FormAnnotation, opts EqualOptions) (any, error) {
+	data, err := json.Marshal(fa)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if !opts.IgnoreFieldOrder {
+		return doc, nil
+	}
+	byID := func(items []any, key string) {
+		sort.SliceStable(items, func(i, j int) bool {
+			a, _ := items[i].(map[string]any)
+			b, _ := items[j].(map[string]any)
+			x, _ := a[key].(string)
+			y, _ := b[key].(string)
+			return x < y
+		})
+	}
+	pages, _ := doc["pages"].([]any)
+	for _, p := range pages {
+		page, _ := p.(map[string]any)
+		fields, _ := page["fields"].([]any)
+		byID(fields, "field_id")
+	}
+	groups, _ := doc["field_groups"].([]any)
+	for _, g := range groups {
+		group, _ := g.(map[string]any)
+		if ids, ok := group["field_ids"].([]any); ok {
+			sort.SliceStable(ids, func(i, j int) bool {
+				x, _ := ids[i].(string)
+				y, _ := ids[j].(string)
+				return x < y
+			})
+		}
+	}
+	return doc, nil
+}
+
+// equalJSON compares generic JSON values, numbers within tolerance.
+func equalJSON(a, b any, tolerance float64) bool {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, va := range a {
+			vb, ok := b[k]
+			if !ok || !equalJSON(va, vb, tolerance) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalJSON(a[i], b[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		b, ok := b.(float64)
+		return ok && math.Abs(a-b) <= tolerance
+	}
+	return a == b
+}
@@ -0,0 +1,689 @@
+package annotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed rule expression. Expressions use a small, dependency-free
+// syntax shared by every rule in an annotation and by the exported validation
+// bundle, so that other implementations can evaluate them identically:
+//
+//	expr    = or
+//	or      = and { "||" and }
+//	and     = compare { "&&" compare }
+//	compare = sum [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" ) sum ]
+//	sum     = product { ( "+" | "-" ) product }
+//	product = unary { ( "*" | "/" ) unary }
+//	unary   = ( "!" | "-" ) unary | primary
+//	primary = number | string | "true" | "false" | ident | call | "(" expr ")"
+//	call    = ident "(" [ expr { "," expr } ] ")"
+//
+// Identifiers name field IDs and evaluate to the field's value. Strings are
+// double-quoted with backslash escapes. Numbers are exact decimals, and field
+// values used in arithmetic are parsed with commas and a leading "$" ignored;
+// an empty value counts as zero. Comparisons are numeric when both sides are
+// numbers, boolean when either side is a boolean, and textual otherwise. A
+// value is truthy when it is true, a non-zero number, or a string other than
+// "", "0", "false", "no" and "off". The built-in functions are empty(x),
+// sum(x, ...), min(x, ...) and max(x, ...).
+type Expr struct {
+	src    string
+	root   exprNode
+	refs   []string
+	limits ExprLimits
+}
+
+// ParseExpr parses src in the rule expression syntax under DefaultExprLimits.
+func ParseExpr(src string) (*Expr, error) {
+	return ParseExprWithLimits(src, DefaultExprLimits)
+}
+
+// ParseExprWithLimits parses src, refusing expressions that exceed limits.
+// The step budget is enforced when the expression is evaluated.
+func ParseExprWithLimits(src string, limits ExprLimits) (*Expr, error) {
+	if n := len([]rune(src)); limits.MaxLength > 0 && n > limits.MaxLength {
+		return nil, &ExprLimitError{Err: ErrExprTooLong, Limit: limits.MaxLength}
+	}
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens, seen: map[string]bool{}, maxDepth: limits.MaxDepth}
+	root, err := p.parseOr()
+	if err != nil {
+		var limitErr *ExprLimitError
+		if errors.As(err, &limitErr) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("expression %q: %w", src, err)
+	}
+	if p.peek().kind != tokEOF {
+		return nil, fmt.Errorf("expression %q: unexpected %q at offset %d", src, p.peek().text, p.peek().pos)
+	}
+	if limits.MaxRefs > 0 && len(p.refs) > limits.MaxRefs {
+		return nil, &ExprLimitError{Err: ErrExprTooManyRefs, Limit: limits.MaxRefs}
+	}
+	return &Expr{src: src, root: root, refs: p.refs, limits: limits}, nil
+}
+
+// String returns the source text of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Refs returns the field IDs the expression references, in first-use order.
+func (e *Expr) Refs() []string {
+	return e.refs
+}
+
+// RenameRefs returns the expression with every field reference replaced by
+// rename(id), leaving literals, keywords and function names untouched.
+func (e *Expr) RenameRefs(rename func(fieldID string) string) (*Expr, error) {
+	tokens, err := tokenizeExpr(e.src)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(e.src)
+	for i := len(tokens) - 1; i >= 0; i-- {
+		t := tokens[i]
+		if t.kind != tokIdent || t.text == "true" || t.text == "false" {
+			continue
+		}
+		if next := tokens[i+1]; next.kind == tokOp && next.text == "(" {
+			continue
+		}
+		if id := rename(t.text); id != t.text {
+			end := t.pos + len([]rune(t.text))
+			runes = append(runes[:t.pos], append([]rune(id), runes[end:]...)...)
+		}
+	}
+	return ParseExprWithLimits(string(runes), e.limits)
+}
+
+// EvalBool evaluates the expression and reports its truthiness. lookup
+// returns the current value of a referenced field.
+func (e *Expr) EvalBool(lookup func(fieldID string) string) (bool, error) {
+	return e.EvalBoolContext(context.Background(), lookup)
+}
+
+// EvalBoolContext is EvalBool, abandoning evaluation when ctx is done.
+func (e *Expr) EvalBoolContext(ctx context.Context, lookup func(fieldID string) string) (bool, error) {
+	v, err := e.root.eval(e.env(ctx, lookup))
+	if err != nil {
+		return false, e.evalError(err)
+	}
+	return v.truthy(), nil
+}
+
+// EvalNumber evaluates the expression as an exact decimal.
+func (e *Expr) EvalNumber(lookup func(fieldID string) string) (*big.Rat, error) {
+	return e.EvalNumberContext(context.Background(), lookup)
+}
+
+// EvalNumberContext is EvalNumber, abandoning evaluation when ctx is done.
+func (e *Expr) EvalNumberContext(ctx context.Context, lookup func(fieldID string) string) (*big.Rat, error) {
+	v, err := e.root.eval(e.env(ctx, lookup))
+	if err != nil {
+		return nil, e.evalError(err)
+	}
+	n, err := v.number()
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", e.src, err)
+	}
+	return n, nil
+}
+
+func (e *Expr) env(ctx context.Context, lookup func(string) string) *evalEnv {
+	return &evalEnv{ctx: ctx, lookup: lookup, maxSteps: e.limits.MaxSteps}
+}
+
+// evalError adds the source text to evaluation errors other than limit errors,
+// which are reported as they are.
+func (e *Expr) evalError(err error) error {
+	var limitErr *ExprLimitError
+	if errors.As(err, &limitErr) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("expression %q: %w", e.src, err)
+}
+
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindNumber
+	kindBool
+)
+
+type exprValue struct {
+	kind valueKind
+	str  string
+	num  *big.Rat
+	b    bool
+	// ref names the field a string came from; its text is then kept out of
+	// error messages, which have no field to redact it with.
+	ref string
+}
+
+func (v exprValue) truthy() bool {
+	switch v.kind {
+	case kindBool:
+		return v.b
+	case kindNumber:
+		return v.num.Sign() != 0
+	}
+	switch strings.ToLower(strings.TrimSpace(v.str)) {
+	case "", "0", "false", "no", "off":
+		return false
+	}
+	return true
+}
+
+func (v exprValue) number() (*big.Rat, error) {
+	switch v.kind {
+	case kindNumber:
+		return v.num, nil
+	case kindBool:
+		if v.b {
+			return big.NewRat(1, 1), nil
+		}
+		return new(big.Rat), nil
+	}
+	if strings.TrimSpace(v.str) == "" {
+		return new(big.Rat), nil
+	}
+	n, ok := parseDecimal(v.str)
+	if !ok {
+		if v.ref != "" {
+			return nil, fmt.Errorf("value of %s is not a number", v.ref)
+		}
+		return nil, fmt.Errorf("%q is not a number", v.str)
+	}
+	return n, nil
+}
+
+func (v exprValue) text() string {
+	switch v.kind {
+	case kindNumber:
+		return v.num.RatString()
+	case kindBool:
+		return strconv.FormatBool(v.b)
+	}
+	return v.str
+}
+
+// parseDecimal parses a human-entered number, ignoring thousands separators,
+// a leading "$" and treating surrounding parentheses as a negative sign.
+func parseDecimal(s string) (*big.Rat, bool) {
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	s = strings.ReplaceAll(s, ",", "")
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = s[1:]
+	}
+	s = strings.TrimPrefix(s, "$")
+	if s == "" || strings.ContainsAny(s, "/+-") {
+		return nil, false
+	}
+	n, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, false
+	}
+	if negative {
+		n.Neg(n)
+	}
+	return n, true
+}
+
+type exprNode interface {
+	eval(env *evalEnv) (exprValue, error)
+}
+
+type literalNode struct{ v exprValue }
+
+func (n literalNode) eval(env *evalEnv) (exprValue, error) { return n.v, env.step() }
+
+type refNode struct{ id string }
+
+func (n refNode) eval(env *evalEnv) (exprValue, error) {
+	return exprValue{kind: kindString, str: env.lookup(n.id), ref: n.id}, env.step()
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n unaryNode) eval(env *evalEnv) (exprValue, error) {
+	if err := env.step(); err != nil {
+		return exprValue{}, err
+	}
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if n.op == "!" {
+		return exprValue{kind: kindBool, b: !v.truthy()}, nil
+	}
+	num, err := v.number()
+	if err != nil {
+		return exprValue{}, err
+	}
+	return exprValue{kind: kindNumber, num: new(big.Rat).Neg(num)}, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n binaryNode) eval(env *evalEnv) (exprValue, error) {
+	if err := env.step(); err != nil {
+		return exprValue{}, err
+	}
+	l, err := n.left.eval(env)
+	if err != nil {
+		return exprValue{}, err
+	}
+	// Logical operators short-circuit.
+	switch n.op {
+	case "&&":
+		if !l.truthy() {
+			return exprValue{kind: kindBool}, nil
+		}
+		r, err := n.right.eval(env)
+		if err != nil {
+			return exprValue{}, err
+		}
+		return exprValue{kind: kindBool, b: r.truthy()}, nil
+	case "||":
+		if l.truthy() {
+			return exprValue{kind: kindBool, b: true}, nil
+		}
+		r, err := n.right.eval(env)
+		if err != nil {
+			return exprValue{}, err
+		}
+		return exprValue{kind: kindBool, b: r.truthy()}, nil
+	}
+	r, err := n.right.eval(env)
+	if err != nil {
+		return exprValue{}, err
+	}
+	switch n.op {
+	case "+", "-", "*", "/":
+		a, err := l.number()
+		if err != nil {
+			return exprValue{}, err
+		}
+		b, err := r.number()
+		if err != nil {
+			return exprValue{}, err
+		}
+		out := new(big.Rat)
+		switch n.op {
+		case "+":
+			out.Add(a, b)
+		case "-":
+			out.Sub(a, b)
+		case "*":
+			out.Mul(a, b)
+		case "/":
+			if b.Sign() == 0 {
+				return exprValue{}, fmt.Errorf("division by zero")
+			}
+			out.Quo(a, b)
+		}
+		return exprValue{kind: kindNumber, num: out}, nil
+	}
+	return exprValue{kind: kindBool, b: compareValues(n.op, l, r)}, nil
+}
+
+// compareValues applies a comparison operator using the typing rules
+// documented on Expr.
+func compareValues(op string, l, r exprValue) bool {
+	var cmp int
+	if l.kind == kindBool || r.kind == kindBool {
+		lb, rb := l.truthy(), r.truthy()
+		switch {
+		case lb == rb:
+			cmp = 0
+		case !lb:
+			cmp = -1
+		default:
+			cmp = 1
+		}
+	} else if a, errA := l.number(); errA == nil && strings.TrimSpace(l.text()) != "" {
+		if b, errB := r.number(); errB == nil && strings.TrimSpace(r.text()) != "" {
+			cmp = a.Cmp(b)
+		} else {
+			cmp = strings.Compare(l.text(), r.text())
+		}
+	} else {
+		cmp = strings.Compare(l.text(), r.text())
+	}
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+type callNode struct {
+	name string
+	args []exprNode
+}
+
+func (n callNode) eval(env *evalEnv) (exprValue, error) {
+	if err := env.step(); err != nil {
+		return exprValue{}, err
+	}
+	values := make([]exprValue, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(env)
+		if err != nil {
+			return exprValue{}, err
+		}
+		values[i] = v
+	}
+	if n.name == "empty" {
+		return exprValue{kind: kindBool, b: strings.TrimSpace(values[0].text()) == ""}, nil
+	}
+	var acc *big.Rat
+	for _, v := range values {
+		num, err := v.number()
+		if err != nil {
+			return exprValue{}, err
+		}
+		switch {
+		case acc == nil:
+			acc = new(big.Rat).Set(num)
+		case n.name == "sum":
+			acc.Add(acc, num)
+		case n.name == "min" && num.Cmp(acc) < 0:
+			acc.Set(num)
+		case n.name == "max" && num.Cmp(acc) > 0:
+			acc.Set(num)
+		}
+	}
+	return exprValue{kind: kindNumber, num: acc}, nil
+}
+
+// exprFuncs lists the built-in functions and their minimum argument counts.
+var exprFuncs = map[string]int{"empty": 1, "sum": 1, "min": 1, "max": 1}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenizeExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{tokIdent, string(runes[start:i]), start})
+		case r == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("expression %q: unterminated string at offset %d", src, start)
+			}
+			i++
+			tokens = append(tokens, exprToken{tokString, sb.String(), start})
+		default:
+			op := ""
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if op == "" && strings.ContainsRune("+-*/()<>!,", r) {
+				op = string(r)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("expression %q: unexpected character %q at offset %d", src, r, i)
+			}
+			tokens = append(tokens, exprToken{tokOp, op, i})
+			i += len([]rune(op))
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, pos: len(runes)}), nil
+}
+
+type exprParser struct {
+	tokens   []exprToken
+	pos      int
+	refs     []string
+	seen     map[string]bool
+	depth    int
+	maxDepth int
+}
+
+// enter guards the parser's own recursion so that deeply nested input is
+// refused before it can exhaust the stack.
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		return &ExprLimitError{Err: ErrExprTooDeep, Limit: p.maxDepth}
+	}
+	return nil
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) acceptOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseBinary(p.parseCompare, "&&")
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if op, ok := p.acceptOp("==", "!=", "<=", ">=", "<", ">"); ok {
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode{op, left, right}, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseSum() (exprNode, error) {
+	return p.parseBinary(p.parseProduct, "+", "-")
+}
+
+func (p *exprParser) parseProduct() (exprNode, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *exprParser) parseBinary(operand func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.acceptOp(ops...)
+		if !ok {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode{op, left, right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.acceptOp("!", "-"); ok {
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op, operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		n, ok := new(big.Rat).SetString(t.text)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q at offset %d", t.text, t.pos)
+		}
+		return literalNode{exprValue{kind: kindNumber, num: n}}, nil
+	case tokString:
+		return literalNode{exprValue{kind: kindString, str: t.text}}, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return literalNode{exprValue{kind: kindBool, b: t.text == "true"}}, nil
+		}
+		if _, ok := p.acceptOp("("); ok {
+			return p.parseCall(t)
+		}
+		if !p.seen[t.text] {
+			p.seen[t.text] = true
+			p.refs = append(p.refs, t.text)
+		}
+		return refNode{t.text}, nil
+	case tokOp:
+		if t.text == "(" {
+			if err := p.enter(); err != nil {
+				return nil, err
+			}
+			defer func() { p.depth-- }()
+			inner, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.acceptOp(")"); !ok {
+				return nil, fmt.Errorf("missing ')' at offset %d", p.peek().pos)
+			}
+			return inner, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	minArgs, ok := exprFuncs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at offset %d", name.text, name.pos)
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	var args []exprNode
+	if _, ok := p.acceptOp(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.acceptOp(","); ok {
+				continue
+			}
+			if _, ok := p.acceptOp(")"); !ok {
+				return nil, fmt.Errorf("missing ')' at offset %d", p.peek().pos)
+			}
+			break
+		}
+	}
+	if len(args) < minArgs || (name.text == "empty" && len(args) != 1) {
+		return nil, fmt.Errorf("%s: wrong number of arguments (%d)", name.text, len(args))
+	}
+	return callNode{name.text, args}, nil
+}
@@ -0,0 +1,99 @@
+package annotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ExprLimits bounds the cost of parsing and evaluating an expression, so
+// that a pathological or hostile annotation cannot pin a CPU. A zero limit
+// is unlimited.
+type ExprLimits struct {
+	// MaxLength is the longest accepted source, in characters.
+	MaxLength int
+	// MaxDepth is the deepest accepted nesting of parentheses, calls and
+	// unary operators.
+	MaxDepth int
+	// MaxRefs is the most distinct field references an expression may make.
+	MaxRefs int
+	// MaxSteps is the evaluation budget, counted in nodes visited.
+	MaxSteps int
+}
+
+// DefaultExprLimits are generous for hand-written rules and far below what
+// could stall a fill.
+var DefaultExprLimits = ExprLimits{
+	MaxLength: 4096,
+	MaxDepth:  64,
+	MaxRefs:   256,
+	MaxSteps:  100000,
+}
+
+// Expression limit errors, wrapped in an ExprLimitError.
+var (
+	ErrExprTooLong     = errors.New("expression is too long")
+	ErrExprTooDeep     = errors.New("expression is nested too deeply")
+	ErrExprTooManyRefs = errors.New("expression references too many fields")
+	ErrExprStepBudget  = errors.New("expression exceeded its evaluation budget")
+)
+
+// ExprLimitError reports an expression refused by an ExprLimits bound.
+// FieldID names the field whose rule it is, when known.
+type ExprLimitError struct {
+	FieldID string
+	Err     error
+	Limit   int
+}
+
+func (e *ExprLimitError) Error() string {
+	if e.FieldID == "" {
+		return fmt.Sprintf("%v (limit %d)", e.Err, e.Limit)
+	}
+	return fmt.Sprintf("field %q: %v (limit %d)", e.FieldID, e.Err, e.Limit)
+}
+
+func (e *ExprLimitError) Unwrap() error {
+	return e.Err
+}
+
+// forField attributes a limit error to fieldID; other errors are returned as is.
+func forField(err error, fieldID string) error {
+	var limitErr *ExprLimitError
+	if errors.As(err, &limitErr) && limitErr.FieldID == "" {
+		out := *limitErr
+		out.FieldID = fieldID
+		return &out
+	}
+	return err
+}
+
+// exprLimits returns the limits rules in this annotation are parsed under.
+func (fa *FormAnnotation) exprLimits() ExprLimits {
+	if fa.ExprLimits != nil {
+		return *fa.ExprLimits
+	}
+	return DefaultExprLimits
+}
+
+// ctxCheckInterval is how many evaluation steps pass between context checks.
+const ctxCheckInterval = 1024
+
+// evalEnv is the state of one evaluation.
+type evalEnv struct {
+	ctx      context.Context
+	lookup   func(string) string
+	steps    int
+	maxSteps int
+}
+
+func (env *evalEnv) step() error {
+	env.steps++
+	if env.maxSteps > 0 && env.steps > env.maxSteps {
+		return &ExprLimitError{Err: ErrExprStepBudget, Limit: env.maxSteps}
+	}
+	if env.steps%ctxCheckInterval == 0 {
+		return env.ctx.Err()
+	}
+	return nil
+}
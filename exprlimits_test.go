@@ -0,0 +1,167 @@
+package annotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// within fails the test if fn does not return within d, so that a limit
+// that stops being enforced shows up as a failure rather than a hang.
+func within(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("did not finish within %v", d)
+	}
+}
+
+func TestExprLimitsAdversarial(t *testing.T) {
+	manyRefs := make([]string, 300)
+	for i := range manyRefs {
+		manyRefs[i] = fmt.Sprintf("f%d", i)
+	}
+	tests := []struct {
+		name   string
+		src    string
+		limits ExprLimits
+		want   error
+	}{
+		{"nested parentheses", strings.Repeat("(", 1000) + "1" + strings.Repeat(")", 1000), DefaultExprLimits, ErrExprTooDeep},
+		{"unclosed parentheses", strings.Repeat("(", 4000), DefaultExprLimits, ErrExprTooDeep},
+		{"stacked unary", strings.Repeat("-", 4000) + "1", DefaultExprLimits, ErrExprTooDeep},
+		{"nested calls", strings.Repeat("sum(", 500) + "1" + strings.Repeat(")", 500), DefaultExprLimits, ErrExprTooDeep},
+		{"unbounded length", strings.Repeat("(", 1000000) + "1" + strings.Repeat(")", 1000000), ExprLimits{MaxDepth: 64}, ErrExprTooDeep},
+		{"long source", strings.Repeat("1+", 5000) + "1", DefaultExprLimits, ErrExprTooLong},
+		{"many references", strings.Join(manyRefs, "+"), DefaultExprLimits, ErrExprTooManyRefs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			within(t, 5*time.Second, func() {
+				_, err := ParseExprWithLimits(tt.src, tt.limits)
+				if !errors.Is(err, tt.want) {
+					t.Errorf("err = %v, want %v", err, tt.want)
+				}
+			})
+		})
+	}
+}
+
+func TestExprStepBudget(t *testing.T) {
+	expr, err := ParseExprWithLimits(strings.Repeat("a+", 500)+"a", ExprLimits{MaxSteps: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	within(t, 5*time.Second, func() {
+		_, err = expr.EvalNumber(func(string) string { return "1" })
+	})
+	var limitErr *ExprLimitError
+	if !errors.As(err, &limitErr) || limitErr.Err != ErrExprStepBudget || limitErr.Limit != 100 {
+		t.Fatalf("err = %v, want the step budget", err)
+	}
+}
+
+func TestExprEvalCancelled(t *testing.T) {
+	expr, err := ParseExprWithLimits(strings.Repeat("a+", 5000)+"a", ExprLimits{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = expr.EvalNumberContext(ctx, func(string) string { return "1" })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+}
+
+// chainForm has n decimal fields, each calculated as the one before it
+// plus one; the first has no calculation unless closed is set, in which
+// case it is calculated from the last and the whole chain is a cycle.
+func chainForm(n int, closed bool) *FormAnnotation {
+	fields := make([]Field, n)
+	for i := range fields {
+		fields[i] = Field{FieldID: fmt.Sprintf("c%d", i), FieldType: FieldTypeText, DataType: DataTypeDecimal,
+			Position: Position{X: 36, Y: 36, Width: 100, Height: 18, Unit: "pt"}}
+		if i > 0 {
+			fields[i].Calculation = &Calculation{Expr: fmt.Sprintf("c%d + 1", i-1)}
+		}
+	}
+	if closed {
+		fields[0].Calculation = &Calculation{Expr: fmt.Sprintf("c%d + 1", n-1)}
+	}
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages:        []Page{{PageNumber: 1, Fields: fields}},
+	}
+}
+
+// TestRecalculateLongChain runs a chain just short of a cycle, which must
+// evaluate in one pass, and the same chain closed into a cycle, which must
+// be refused without evaluating anything.
+func TestRecalculateLongChain(t *testing.T) {
+	const n = 2000
+	within(t, 10*time.Second, func() {
+		fa := chainForm(n, false)
+		report := fa.Recalculate()
+		if len(report.Issues) != 0 {
+			t.Fatalf("issues: %v", report.Issues)
+		}
+		last := fa.GetFieldByID(fmt.Sprintf("c%d", n-1))
+		if want := fmt.Sprint(n - 1); last.Value != want {
+			t.Errorf("last value = %q, want %q", last.Value, want)
+		}
+	})
+	within(t, 10*time.Second, func() {
+		fa := chainForm(n, true)
+		report := fa.Recalculate()
+		if len(report.Filled) != 0 {
+			t.Errorf("filled %d fields of a cycle", len(report.Filled))
+		}
+		if len(report.Issues) != n {
+			t.Errorf("got %d issues, want one per field on the cycle", len(report.Issues))
+		}
+		for _, issue := range report.Issues {
+			if issue.Code != FillCalculationFailed {
+				t.Errorf("issue code = %s", issue.Code)
+			}
+		}
+		var cycles int
+		for _, issue := range fa.Validate() {
+			if issue.Code == CalculationCycle {
+				cycles++
+			}
+		}
+		if cycles != 1 {
+			t.Errorf("Validate reported %d cycles, want 1", cycles)
+		}
+	})
+}
+
+// TestExprLimitErrorNamesField checks that a rule refused by a limit is
+// reported against the field it belongs to.
+func TestExprLimitErrorNamesField(t *testing.T) {
+	fa := chainForm(2, false)
+	fa.GetFieldByID("c1").Calculation.Expr = strings.Repeat("(", 100) + "c0" + strings.Repeat(")", 100)
+	report := fa.Recalculate()
+	if len(report.Issues) != 1 || !strings.Contains(report.Issues[0].Message, `field "c1"`) {
+		t.Fatalf("issues: %v", report.Issues)
+	}
+	var found bool
+	for _, issue := range fa.Validate() {
+		if issue.Code == RuleInvalid && issue.FieldID == "c1" && strings.Contains(issue.Message, ErrExprTooDeep.Error()) {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Validate did not report the nesting limit")
+	}
+}
@@ -0,0 +1,97 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// IsOverlay reports whether the annotation extends a base form and must be
+// resolved with ResolveTemplate before it is filled or rendered.
+func (fa *FormAnnotation) IsOverlay() bool {
+	return fa.FormMetadata.Extends != nil
+}
+
+// ResolveTemplate returns the complete annotation an overlay describes:
+// the base form its metadata extends, looked up in lib and resolved in
+// turn if it is itself an overlay, with the overlay's fields applied as
+// DeriveForYear applies overrides. An overlay field with a field type
+// replaces the base field with its ID, or is added; one marked removed
+// deletes it; and one without a field type moves the base field, taking
+// the overlay's position and segments where they are given and keeping
+// everything else. Overlay groups replace the base groups with their IDs
+// or are added. The result has the overlay's year, and its form ID, form
+// name and page size when it gives them. An annotation that extends
+// nothing is returned as a copy.
+func (fa *FormAnnotation) ResolveTemplate(lib *Library) (*FormAnnotation, error) {
+	return fa.resolveTemplate(lib, nil)
+}
+
+func (fa *FormAnnotation) resolveTemplate(lib *Library, chain []FormRef) (*FormAnnotation, error) {
+	ref := fa.FormMetadata.Extends
+	if ref == nil {
+		return fa.Clone(), nil
+	}
+	self := FormRef{FormID: fa.FormMetadata.FormID, Year: fa.FormMetadata.Year}
+	if slices.Contains(chain, *ref) || *ref == self {
+		return nil, fmt.Errorf("%s extends %s, which extends it in turn", self.String(), ref.String())
+	}
+	var base *FormAnnotation
+	if lib != nil {
+		base = lib.Get(ref.FormID, ref.Year)
+	}
+	if base == nil {
+		return nil, fmt.Errorf("%s extends %s, which is not in the library", self.String(), ref.String())
+	}
+	base, err := base.resolveTemplate(lib, append(chain, self))
+	if err != nil {
+		return nil, err
+	}
+	overrides := fa.Clone()
+	for _, g := range overrides.FieldGroups {
+		if i := slices.IndexFunc(base.FieldGroups, func(b FieldGroup) bool { return b.GroupID == g.GroupID }); i >= 0 {
+			base.FieldGroups[i] = g
+		} else {
+			base.FieldGroups = append(base.FieldGroups, g)
+		}
+	}
+	for i := range overrides.Pages {
+		for j := range overrides.Pages[i].Fields {
+			patch := &overrides.Pages[i].Fields[j]
+			if patch.FieldType != "" || patch.Removed {
+				continue
+			}
+			field := base.GetFieldByID(patch.FieldID)
+			if field == nil {
+				return nil, fmt.Errorf("%s: page %d moves field %q, which %s does not have",
+					self.String(), overrides.Pages[i].PageNumber, patch.FieldID, ref.String())
+			}
+			moved := field.Clone()
+			if patch.Position != (Position{}) {
+				moved.Position = patch.Position
+			}
+			if len(patch.Segments) > 0 {
+				moved.Segments = patch.Segments
+			}
+			*patch = moved
+		}
+	}
+	year := fa.FormMetadata.Year
+	if year == 0 {
+		year = base.FormMetadata.Year
+	}
+	out, _, err := DeriveForYear(base, year, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", self.String(), err)
+	}
+	if fa.FormMetadata.FormID != "" {
+		out.FormMetadata.FormID = fa.FormMetadata.FormID
+	}
+	if fa.FormMetadata.FormName != "" {
+		out.FormMetadata.FormName = fa.FormMetadata.FormName
+	}
+	if fa.FormMetadata.PageSize != (PageSize{}) {
+		out.FormMetadata.PageSize = overrides.FormMetadata.PageSize
+	}
+	out.FormMetadata.Extends = nil
+	return out, nil
+}
@@ -0,0 +1,385 @@
+package annotation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// How a form's field was aligned to a dictionary entry.
+const (
+	AlignSeed       = "seed"
+	AlignPinned     = "pinned"
+	AlignLineAndVal = "line_ref_and_value"
+	AlignValuePath  = "value_path"
+	AlignLineRef    = "line_ref"
+	AlignFuzzy      = "fuzzy"
+)
+
+// Field difference aspects.
+const (
+	DiffPosition   = "position"
+	DiffValidation = "validation"
+	DiffDataType   = "data_type"
+)
+
+// DefaultFuzzyThreshold is the label similarity FormFamily needs for a
+// fuzzy match when FamilyOptions.FuzzyThreshold is zero.
+const DefaultFuzzyThreshold = 0.75
+
+// positionTolerance is how far apart, in points, two positions may be and
+// still count as the same.
+const positionTolerance = 0.5
+
+// FormFamily is a set of related forms, such as the 1040, 1040-SR and
+// 1040-NR, whose fields are aligned into one dictionary.
+type FormFamily struct {
+	Name  string
+	forms []*FormAnnotation
+	pins  map[string]map[string]string // form ID -> field ID -> entry key
+}
+
+// NewFormFamily groups forms, which must have distinct form IDs.
+func NewFormFamily(name string, forms ...*FormAnnotation) (*FormFamily, error) {
+	seen := map[string]bool{}
+	for _, fa := range forms {
+		id := fa.FormMetadata.FormID
+		if seen[id] {
+			return nil, fmt.Errorf("form %q appears twice in family %q", id, name)
+		}
+		seen[id] = true
+	}
+	return &FormFamily{Name: name, forms: forms, pins: map[string]map[string]string{}}, nil
+}
+
+// Pin assigns a form's field to the dictionary entry called key, overriding
+// automatic alignment. Fields pinned to the same key always share an entry.
+func (f *FormFamily) Pin(key, formID, fieldID string) {
+	if f.pins[formID] == nil {
+		f.pins[formID] = map[string]string{}
+	}
+	f.pins[formID][fieldID] = key
+}
+
+// FamilyOptions controls FormFamily.Dictionary.
+type FamilyOptions struct {
+	// FuzzyThreshold is the label similarity, from 0 to 1, a fuzzy match
+	// needs; DefaultFuzzyThreshold when zero. A negative value turns fuzzy
+	// matching off.
+	FuzzyThreshold float64
+}
+
+// FieldDictionary is the unified field list of a form family.
+type FieldDictionary struct {
+	Family    string            `json:"family"`
+	Forms     []string          `json:"forms"`
+	Entries   []DictionaryEntry `json:"entries"`
+	Conflicts []FamilyConflict  `json:"conflicts,omitempty"`
+}
+
+// DictionaryEntry is one logical field. Its line ref, value path and data
+// type are those of the first form that has it.
+type DictionaryEntry struct {
+	Key        string   `json:"key"`
+	LineRef    string   `json:"line_ref,omitempty"`
+	FieldValue string   `json:"field_value,omitempty"`
+	Label      string   `json:"label,omitempty"`
+	DataType   DataType `json:"data_type"`
+	// Presence maps each form that has the field to its field ID there.
+	Presence map[string]string `json:"presence"`
+	// Alignment records how each form's field was matched to the entry.
+	Alignment   map[string]string `json:"alignment"`
+	Differences []FieldDifference `json:"differences,omitempty"`
+}
+
+// FieldDifference is a way a form's field differs from the entry's first form.
+type FieldDifference struct {
+	FormID string `json:"form_id"`
+	Aspect string `json:"aspect"`
+	Detail string `json:"detail"`
+}
+
+// FamilyConflict reports fields that share a line reference but disagree on
+// what it holds.
+type FamilyConflict struct {
+	LineRef string `json:"line_ref"`
+	Kind    string `json:"kind"`
+	// Fields maps "form_id/field_id" to the conflicting value.
+	Fields  map[string]string `json:"fields"`
+	Message string            `json:"message"`
+}
+
+type familyMember struct {
+	formID string
+	field  *Field
+	unit   string
+}
+
+// Dictionary aligns the family's fields. Each form's fields are matched,
+// in order, by pin, by line reference and value path together, by value
+// path, by line reference, and by fuzzy label similarity, each time only
+// against entries the form does not already fill; a field matching none
+// starts a new entry. Entries created by a pin receive only pinned fields.
+// Forms are processed in the order given to NewFormFamily, so the result
+// is deterministic.
+func (f *FormFamily) Dictionary(opts FamilyOptions) *FieldDictionary {
+	threshold := opts.FuzzyThreshold
+	if threshold == 0 {
+		threshold = DefaultFuzzyThreshold
+	}
+	dict := &FieldDictionary{Family: f.Name}
+	var members [][]familyMember // parallel to dict.Entries
+	byKey := map[string]int{}
+	pinned := map[string]bool{}
+	for _, fields := range f.pins {
+		for _, key := range fields {
+			pinned[key] = true
+		}
+	}
+	newEntry := func(key string, m familyMember) int {
+		if key == "" {
+			key = m.field.FieldValue
+			if key == "" {
+				key = m.field.FieldID
+			}
+			for base, n := key, 2; ; n++ {
+				if _, taken := byKey[key]; !taken && !pinned[key] {
+					break
+				}
+				key = fmt.Sprintf("%s_%d", base, n)
+			}
+		}
+		dict.Entries = append(dict.Entries, DictionaryEntry{
+			Key:        key,
+			LineRef:    m.field.IRSLineRef,
+			FieldValue: m.field.FieldValue,
+			Label:      m.field.Label,
+			DataType:   m.field.DataType,
+			Presence:   map[string]string{},
+			Alignment:  map[string]string{},
+		})
+		members = append(members, nil)
+		byKey[key] = len(dict.Entries) - 1
+		return len(dict.Entries) - 1
+	}
+	for _, fa := range f.forms {
+		formID := fa.FormMetadata.FormID
+		dict.Forms = append(dict.Forms, formID)
+		for pi := range fa.Pages {
+			for fi := range fa.Pages[pi].Fields {
+				field := &fa.Pages[pi].Fields[fi]
+				m := familyMember{formID: formID, field: field, unit: fa.FormMetadata.PageSize.Unit}
+				free := func(i int) bool { _, has := dict.Entries[i].Presence[formID]; return !has }
+				idx, how := -1, ""
+				if key, ok := f.pins[formID][field.FieldID]; ok {
+					if i, ok := byKey[key]; ok {
+						idx = i
+					} else {
+						idx = newEntry(key, m)
+					}
+					how = AlignPinned
+				}
+				for _, rule := range []struct {
+					name  string
+					match func(e *DictionaryEntry) bool
+				}{
+					{AlignLineAndVal, func(e *DictionaryEntry) bool {
+						return field.IRSLineRef != "" && field.FieldValue != "" &&
+							sameLineRef(e.LineRef, field.IRSLineRef) && e.FieldValue == field.FieldValue
+					}},
+					{AlignValuePath, func(e *DictionaryEntry) bool {
+						return field.FieldValue != "" && e.FieldValue == field.FieldValue
+					}},
+					{AlignLineRef, func(e *DictionaryEntry) bool {
+						return field.IRSLineRef != "" && sameLineRef(e.LineRef, field.IRSLineRef)
+					}},
+				} {
+					if idx >= 0 {
+						break
+					}
+					for i := range dict.Entries {
+						if free(i) && !pinned[dict.Entries[i].Key] && rule.match(&dict.Entries[i]) {
+							idx, how = i, rule.name
+							break
+						}
+					}
+				}
+				if idx < 0 && threshold > 0 {
+					best := threshold
+					for i := range dict.Entries {
+						if !free(i) || pinned[dict.Entries[i].Key] {
+							continue
+						}
+						if score := labelSimilarity(dict.Entries[i].Label, field.Label); score >= best {
+							if score > best || idx < 0 {
+								idx, how, best = i, AlignFuzzy, score
+							}
+						}
+					}
+				}
+				if idx < 0 {
+					idx, how = newEntry("", m), AlignSeed
+				}
+				if !free(idx) {
+					// A second pin of one form to the same entry; keep the first.
+					idx = newEntry("", m)
+					how = AlignSeed
+				}
+				e := &dict.Entries[idx]
+				e.Presence[formID] = field.FieldID
+				e.Alignment[formID] = how
+				members[idx] = append(members[idx], m)
+			}
+		}
+	}
+
+	for i := range dict.Entries {
+		dict.Entries[i].Differences = entryDifferences(members[i])
+	}
+	dict.Conflicts = familyConflicts(members)
+	return dict
+}
+
+// sameLineRef compares line references ignoring case, spaces and a leading
+// "Line".
+func sameLineRef(a, b string) bool {
+	norm := func(s string) string {
+		s = strings.ToLower(strings.Join(strings.Fields(s), ""))
+		return strings.TrimPrefix(s, "line")
+	}
+	return a != "" && norm(a) == norm(b)
+}
+
+// labelSimilarity is the Jaccard index of the labels' lowercase words.
+func labelSimilarity(a, b string) float64 {
+	words := func(s string) map[string]bool {
+		set := map[string]bool{}
+		for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			set[w] = true
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	inter := 0
+	for w := range wa {
+		if wb[w] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(wa)+len(wb)-inter)
+}
+
+// entryDifferences compares each member with the entry's first member.
+func entryDifferences(members []familyMember) []FieldDifference {
+	if len(members) < 2 {
+		return nil
+	}
+	ref := members[0]
+	var diffs []FieldDifference
+	for _, m := range members[1:] {
+		if m.field.DataType != ref.field.DataType {
+			diffs = append(diffs, FieldDifference{m.formID, DiffDataType,
+				fmt.Sprintf("%s instead of %s", m.field.DataType, ref.field.DataType)})
+		}
+		if !m.field.IsVirtual() && !ref.field.IsVirtual() {
+			a, okA := positionInPoints(ref.field.Position, ref.unit)
+			b, okB := positionInPoints(m.field.Position, m.unit)
+			if okA && okB && !samePosition(a, b) {
+				diffs = append(diffs, FieldDifference{m.formID, DiffPosition,
+					fmt.Sprintf("at %g,%g %gx%g pt instead of %g,%g %gx%g pt",
+						b.X, b.Y, b.Width, b.Height, a.X, a.Y, a.Width, a.Height)})
+			}
+		}
+		if !reflect.DeepEqual(m.field.Validation, ref.field.Validation) {
+			diffs = append(diffs, FieldDifference{m.formID, DiffValidation, "validation rules differ"})
+		}
+	}
+	return diffs
+}
+
+func samePosition(a, b Position) bool {
+	return math.Abs(a.X-b.X) <= positionTolerance && math.Abs(a.Y-b.Y) <= positionTolerance &&
+		math.Abs(a.Width-b.Width) <= positionTolerance && math.Abs(a.Height-b.Height) <= positionTolerance
+}
+
+// familyConflicts finds line references whose fields, in any entry, do not
+// agree on a data type.
+func familyConflicts(members [][]familyMember) []FamilyConflict {
+	type group struct {
+		ref    string
+		fields map[string]string
+		types  map[DataType]bool
+	}
+	var order []string
+	groups := map[string]*group{}
+	for _, entry := range members {
+		for _, m := range entry {
+			if m.field.IRSLineRef == "" {
+				continue
+			}
+			key := strings.TrimPrefix(strings.ToLower(strings.Join(strings.Fields(m.field.IRSLineRef), "")), "line")
+			g := groups[key]
+			if g == nil {
+				g = &group{ref: m.field.IRSLineRef, fields: map[string]string{}, types: map[DataType]bool{}}
+				groups[key] = g
+				order = append(order, key)
+			}
+			g.fields[m.formID+"/"+m.field.FieldID] = string(m.field.DataType)
+			g.types[m.field.DataType] = true
+		}
+	}
+	var conflicts []FamilyConflict
+	for _, key := range order {
+		g := groups[key]
+		if len(g.types) < 2 {
+			continue
+		}
+		types := make([]string, 0, len(g.types))
+		for t := range g.types {
+			types = append(types, string(t))
+		}
+		sort.Strings(types)
+		conflicts = append(conflicts, FamilyConflict{
+			LineRef: g.ref,
+			Kind:    DiffDataType,
+			Fields:  g.fields,
+			Message: fmt.Sprintf("%s holds different data types: %s", g.ref, strings.Join(types, ", ")),
+		})
+	}
+	return conflicts
+}
+
+// WriteFieldDictionaryCSV renders the dictionary with one column per form
+// holding the form's field ID, empty where the form lacks the field.
+func WriteFieldDictionaryCSV(w io.Writer, d *FieldDictionary) error {
+	cw := csv.NewWriter(w)
+	header := append([]string{"key", "line_ref", "field_value", "data_type", "label"}, d.Forms...)
+	if err := cw.Write(append(header, "differences")); err != nil {
+		return err
+	}
+	for _, e := range d.Entries {
+		row := []string{e.Key, e.LineRef, e.FieldValue, string(e.DataType), e.Label}
+		for _, form := range d.Forms {
+			row = append(row, e.Presence[form])
+		}
+		var diffs []string
+		for _, diff := range e.Differences {
+			diffs = append(diffs, fmt.Sprintf("%s %s: %s", diff.FormID, diff.Aspect, diff.Detail))
+		}
+		if err := cw.Write(append(row, strings.Join(diffs, "; "))); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,189 @@
+package annotation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// fieldColumns are the ExportFieldsCSV columns, in order.
+var fieldColumns = []string{"form_id", "page", "field_id", "irs_line_reference", "field_type", "data_type",
+	"x", "y", "width", "height", "unit", "group_id", "required", "field_value"}
+
+// ExportFieldsCSV writes one row per field, in page order, for review in a
+// spreadsheet. ApplyFieldsCSV reads the edited sheet back.
+func (fa *FormAnnotation) ExportFieldsCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fieldColumns); err != nil {
+		return err
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, page := range fa.Pages {
+		for _, f := range page.Fields {
+			required := f.Validation != nil && f.Validation.Required
+			if err := cw.Write([]string{
+				fa.FormMetadata.FormID,
+				strconv.Itoa(page.PageNumber),
+				f.FieldID,
+				f.IRSLineRef,
+				string(f.FieldType),
+				string(f.DataType),
+				num(f.Position.X),
+				num(f.Position.Y),
+				num(f.Position.Width),
+				num(f.Position.Height),
+				f.Position.Unit,
+				f.GroupID,
+				strconv.FormatBool(required),
+				f.FieldValue,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FieldsCSVOptions controls ApplyFieldsCSV.
+type FieldsCSVOptions struct {
+	// AllowNew adds a field for each row whose field_id the form does not
+	// have, on the row's page, instead of refusing the sheet.
+	AllowNew bool
+}
+
+// ApplyFieldsCSV updates fields from a sheet in the ExportFieldsCSV layout.
+// Rows are matched by field_id; only field_id is a required column. The
+// position, unit, irs_line_reference and required cells of existing fields
+// are applied, and an empty cell leaves its attribute as it is. Other
+// columns describe the field and are read only for new fields. An unknown
+// field ID, unless opts.AllowNew is set, or a malformed number or boolean
+// fails the whole sheet with its line number, leaving the annotation
+// unchanged.
+func (fa *FormAnnotation) ApplyFieldsCSV(r io.Reader, opts FieldsCSVOptions) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("fields CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	if _, ok := columns["field_id"]; !ok {
+		return fmt.Errorf("fields CSV: no field_id column (columns are %s)", strings.Join(fieldColumns, ", "))
+	}
+
+	out := fa.Clone()
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("fields CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+		cell := func(name string) (string, bool) {
+			if i, ok := columns[name]; ok && i < len(record) {
+				v := strings.TrimSpace(record[i])
+				return v, v != ""
+			}
+			return "", false
+		}
+		id, _ := cell("field_id")
+		if id == "" {
+			return fmt.Errorf("fields CSV line %d: empty field_id", line)
+		}
+		field := out.GetFieldByID(id)
+		if field == nil {
+			if !opts.AllowNew {
+				return fmt.Errorf("fields CSV line %d: no field with ID %q", line, id)
+			}
+			if field, err = out.addCSVField(id, cell); err != nil {
+				return fmt.Errorf("fields CSV line %d: %w", line, err)
+			}
+		}
+		if v, ok := cell("irs_line_reference"); ok {
+			field.IRSLineRef = v
+		}
+		for _, c := range []struct {
+			name string
+			dst  *float64
+		}{
+			{"x", &field.Position.X}, {"y", &field.Position.Y},
+			{"width", &field.Position.Width}, {"height", &field.Position.Height},
+		} {
+			v, ok := cell(c.name)
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("fields CSV line %d: %s %q is not a number", line, c.name, v)
+			}
+			*c.dst = n
+		}
+		if v, ok := cell("unit"); ok {
+			if _, known := toPoints(1, v); !known {
+				return fmt.Errorf("fields CSV line %d: unknown unit %q", line, v)
+			}
+			field.Position.Unit = v
+		}
+		if v, ok := cell("required"); ok {
+			required, valid := parseBoolValue(v)
+			if !valid {
+				return fmt.Errorf("fields CSV line %d: required %q is not a boolean", line, v)
+			}
+			if required && field.Validation == nil {
+				field.Validation = &Validation{}
+			}
+			if field.Validation != nil {
+				field.Validation.Required = required
+			}
+		}
+	}
+	*fa = *out
+	return nil
+}
+
+// addCSVField adds a field described by a sheet row to the row's page,
+// adding the page when the form does not have it.
+func (fa *FormAnnotation) addCSVField(id string, cell func(string) (string, bool)) (*Field, error) {
+	v, ok := cell("page")
+	if !ok {
+		return nil, fmt.Errorf("new field %q has no page", id)
+	}
+	pageNum, err := strconv.Atoi(v)
+	if err != nil || pageNum < 1 {
+		return nil, fmt.Errorf("page %q is not a page number", v)
+	}
+	f := Field{FieldID: id, FieldType: FieldTypeText, DataType: DataTypeString}
+	if v, ok := cell("field_type"); ok {
+		f.FieldType = FieldType(v)
+	}
+	if v, ok := cell("data_type"); ok {
+		f.DataType = DataType(v)
+	}
+	f.GroupID, _ = cell("group_id")
+	f.FieldValue, _ = cell("field_value")
+	at := len(fa.Pages)
+	for i, page := range fa.Pages {
+		if page.PageNumber >= pageNum {
+			at = i
+			break
+		}
+	}
+	if at == len(fa.Pages) || fa.Pages[at].PageNumber != pageNum {
+		fa.Pages = slices.Insert(fa.Pages, at, Page{PageNumber: pageNum, Fields: []Field{}})
+		fa.FormMetadata.PageCount = len(fa.Pages)
+	}
+	page := &fa.Pages[at]
+	page.Fields = append(page.Fields, f)
+	return &page.Fields[len(page.Fields)-1], nil
+}
@@ -0,0 +1,304 @@
+package annotation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity classifies a reported issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// GeometryPolicy decides what happens when a value targets a field that cannot be drawn.
+type GeometryPolicy int
+
+const (
+	// GeometryReject refuses to place the value and reports an error.
+	GeometryReject GeometryPolicy = iota
+	// GeometryWarn places the value anyway and reports a warning.
+	GeometryWarn
+)
+
+// FillOptions controls how values are placed into fields.
+type FillOptions struct {
+	GeometryPolicy GeometryPolicy
+	// Target selects the render overrides BuildStampPlan applies.
+	Target RenderTarget
+	// Coercion decides what happens to upstream values of the wrong JSON
+	// type; the default is CoerceStrict.
+	Coercion CoercionPolicy
+	// Redact, when set, masks sensitive values in the stamp plan as Redact
+	// does, leaving the annotation as it is, so that a client copy can be
+	// drawn from the same annotation.
+	Redact *RedactOptions
+}
+
+// FillIssue describes a problem encountered while filling a single field.
+type FillIssue struct {
+	FieldID  string   `json:"field_id,omitempty"`
+	Page     int      `json:"page,omitempty"`
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// FillReport summarizes the outcome of a fill operation.
+type FillReport struct {
+	Filled []string    `json:"filled"`
+	Issues []FillIssue `json:"issues,omitempty"`
+	// Coercions lists the values converted under FillOptions.Coercion.
+	Coercions []Coercion `json:"coercions,omitempty"`
+	// Incomplete marks a fill cut short by its context.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// Fill issue codes.
+const (
+	FillUnknownField     = "unknown_field"
+	FillUnplaceableField = "unplaceable_field"
+	FillUnsupportedValue = "unsupported_value"
+	FillReadOnlyField    = "read_only_field"
+	FillTransformFailed  = "transform_failed"
+	FillTypeMismatch     = "type_mismatch"
+)
+
+// HasErrors reports whether any issue in the report is an error.
+func (r *FillReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *FillReport) add(issue FillIssue) {
+	r.Issues = append(r.Issues, issue)
+}
+
+// SetValues fills fields by field ID. Values destined for fields that cannot be
+// drawn are refused or warned about according to opts.GeometryPolicy.
+func (fa *FormAnnotation) SetValues(values map[string]string, opts FillOptions) *FillReport {
+	report := &FillReport{}
+	index := fa.BuildIndex()
+	for _, id := range sortedKeys(values) {
+		field, page := index.lookup(id)
+		if field == nil {
+			report.add(FillIssue{
+				FieldID:  id,
+				Code:     FillUnknownField,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("no field with ID %q", id),
+			})
+			continue
+		}
+		fa.place(field, page, values[id], opts, report)
+	}
+	return report
+}
+
+// FillFromData fills every field whose FieldValue path resolves to a scalar in data.
+// Paths are dotted keys into nested maps, e.g. "taxpayer.first_name". An
+// option code at a radio group's path checks the member representing it.
+func (fa *FormAnnotation) FillFromData(data map[string]any, opts FillOptions) *FillReport {
+	report, _ := fa.FillFromDataContext(context.Background(), data, opts)
+	return report
+}
+
+// FillFromDataContext is FillFromData under ctx, checked before each page and
+// before the radio groups. When ctx ends first, the fields already placed
+// stay filled, the report is marked Incomplete, and the error is a
+// *PartialResultError naming the skipped pages.
+func (fa *FormAnnotation) FillFromDataContext(ctx context.Context, data map[string]any, opts FillOptions) (*FillReport, error) {
+	report := &FillReport{}
+	for i := range fa.Pages {
+		if ctx.Err() != nil {
+			report.Incomplete = true
+			var skipped []int
+			for _, page := range fa.Pages[i:] {
+				skipped = append(skipped, page.PageNumber)
+			}
+			return report, &PartialResultError{Stages: []string{StageOptionGroups}, Pages: skipped, Err: ctx.Err()}
+		}
+		page := fa.Pages[i].PageNumber
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			if field.FieldValue == "" {
+				continue
+			}
+			raw, ok := lookupPath(data, field.FieldValue)
+			if !ok {
+				continue
+			}
+			if _, err := scalarString(raw); err != nil {
+				report.add(FillIssue{
+					FieldID:  field.FieldID,
+					Page:     page,
+					Code:     FillUnsupportedValue,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s: %v", field.FieldValue, err),
+				})
+				continue
+			}
+			value, rule, err := opts.Coercion.coerce(field, raw)
+			if err != nil {
+				report.add(FillIssue{
+					FieldID:  field.FieldID,
+					Page:     page,
+					Code:     FillTypeMismatch,
+					Severity: SeverityError,
+					Message:  fmt.Sprintf("%s: %v", field.FieldValue, err),
+				})
+				continue
+			}
+			if rule != "" {
+				report.Coercions = append(report.Coercions, coercion(field, page, rule, raw, value))
+			}
+			fa.placeTransformed(field, page, value, opts, report)
+		}
+	}
+	if ctx.Err() != nil {
+		report.Incomplete = true
+		return report, &PartialResultError{Stages: []string{StageOptionGroups}, Err: ctx.Err()}
+	}
+	fa.fillOptionGroups(data, opts, report)
+	return report, nil
+}
+
+// place writes value into field unless the field is read-only or the
+// placement guard refuses it.
+func (fa *FormAnnotation) place(field *Field, page int, value string, opts FillOptions, report *FillReport) {
+	if field.ReadOnly {
+		report.add(FillIssue{
+			FieldID:  field.FieldID,
+			Page:     page,
+			Code:     FillReadOnlyField,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("field %q is read-only", field.FieldID),
+		})
+		return
+	}
+	value, err := runFieldSetHooks(field, value)
+	if err != nil {
+		report.add(FillIssue{
+			FieldID:  field.FieldID,
+			Page:     page,
+			Code:     FillHookFailed,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("field set hook: %v", err),
+		})
+		return
+	}
+	if problem := fa.checkPlacement(field, page, value); problem != "" {
+		issue, proceed := placementIssue(field, page, problem, opts)
+		report.add(issue)
+		if !proceed {
+			return
+		}
+	}
+	field.Value = value
+	fa.InvalidateIndex()
+	report.Filled = append(report.Filled, field.FieldID)
+}
+
+// placeTransformed runs the field's transforms over an upstream value and
+// places the result; a failing transform is reported instead.
+func (fa *FormAnnotation) placeTransformed(field *Field, page int, value string, opts FillOptions, report *FillReport) {
+	value, err := applyTransforms(field, value)
+	if err != nil {
+		report.add(FillIssue{
+			FieldID:  field.FieldID,
+			Page:     page,
+			Code:     FillTransformFailed,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		})
+		return
+	}
+	fa.place(field, page, value, opts, report)
+}
+
+// fieldAndPage finds a field by ID and returns it with its page number.
+func (fa *FormAnnotation) fieldAndPage(fieldID string) (*Field, int) {
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			if fa.sameID(fa.Pages[i].Fields[j].FieldID, fieldID) {
+				return &fa.Pages[i].Fields[j], fa.Pages[i].PageNumber
+			}
+		}
+	}
+	return nil, 0
+}
+
+// lookupPath walks a dotted path through nested maps.
+func lookupPath(data map[string]any, path string) (any, bool) {
+	var current any = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// scalarString renders a decoded JSON scalar as a field value.
+func scalarString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
+
+// isChecked reports whether a checkbox value means the box is marked.
+func isChecked(value string) bool {
+	b, ok := parseBoolValue(value)
+	return ok && b
+}
+
+// parseBoolValue parses the spellings accepted for boolean and checkbox values.
+func parseBoolValue(value string) (bool, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", "x", "yes", "on", "checked":
+		return true, true
+	case "false", "0", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,121 @@
+package annotation
+
+import "fmt"
+
+// FillUnmatchedKey reports a value path in Fill's input that no field or
+// group is bound to.
+const FillUnmatchedKey = "unmatched_key"
+
+// FilledForm is an annotation filled from a flat map of value paths, with
+// each filled field paired with the text drawn for it.
+type FilledForm struct {
+	FormID string        `json:"form_id"`
+	Year   int           `json:"year"`
+	Fields []FilledField `json:"fields"`
+	// Annotation is the filled copy the fields point into.
+	Annotation *FormAnnotation `json:"-"`
+}
+
+// FilledField is one filled field. Value is the canonical value stored in
+// the field; Rendered is that value formatted by the field's Formatting,
+// and Segments its split across a segmented field's cells.
+type FilledField struct {
+	FieldID   string   `json:"field_id"`
+	Page      int      `json:"page"`
+	ValuePath string   `json:"field_value,omitempty"`
+	Value     string   `json:"value"`
+	Rendered  string   `json:"rendered"`
+	Segments  []string `json:"segments,omitempty"`
+	Field     *Field   `json:"-"`
+}
+
+// Error implements the error interface so issues can be returned directly.
+func (i FillIssue) Error() string {
+	if i.FieldID != "" {
+		return fmt.Sprintf("%s: field %q: %s", i.Code, i.FieldID, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Code, i.Message)
+}
+
+// Fill fills a copy of the annotation from values keyed by value path, as in
+// "taxpayer.ssn", leaving fa unchanged. Strings are the only input, so they
+// are coerced leniently to each field's data type. A key every field bound
+// to it receives, and an option code at a radio group's path checks its
+// member. The filled values are then validated, and each filled field is
+// formatted for rendering.
+//
+// The errors are FillIssue values for keys no field is bound to and values
+// that could not be placed, ValidationIssue values for rule violations,
+// required fields left empty among them, and formatting failures. The
+// FilledForm is returned even when there are errors.
+func (fa *FormAnnotation) Fill(values map[string]string) (*FilledForm, []error) {
+	filled := fa.Clone()
+	var errs []error
+	bound := map[string]bool{}
+	for _, f := range filled.Fields() {
+		bound[f.FieldValue] = true
+	}
+	for i := range filled.FieldGroups {
+		bound[filled.FieldGroups[i].valuePath()] = true
+	}
+	data := map[string]any{}
+	for _, path := range sortedKeys(values) {
+		if path == "" || !bound[path] {
+			errs = append(errs, FillIssue{Code: FillUnmatchedKey, Severity: SeverityError,
+				Message: fmt.Sprintf("no field is bound to %q", path)})
+			continue
+		}
+		if err := setPath(data, path, values[path]); err != nil {
+			errs = append(errs, FillIssue{Code: FillUnsupportedValue, Severity: SeverityError, Message: err.Error()})
+		}
+	}
+	report := filled.FillFromData(data, FillOptions{Coercion: CoercionPolicy{Mode: CoerceLenient}})
+	for _, issue := range report.Issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+
+	byID := map[string]string{}
+	for _, f := range filled.Fields() {
+		byID[f.FieldID] = f.Value
+	}
+	for _, issue := range filled.ValidateValues(byID).Issues {
+		if issue.Severity == SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+
+	form := &FilledForm{FormID: fa.FormMetadata.FormID, Year: fa.FormMetadata.Year, Annotation: filled}
+	for i := range filled.Pages {
+		for j := range filled.Pages[i].Fields {
+			field := &filled.Pages[i].Fields[j]
+			if field.Value == "" {
+				continue
+			}
+			ff := FilledField{FieldID: field.FieldID, Page: filled.Pages[i].PageNumber,
+				ValuePath: field.FieldValue, Value: field.Value, Field: field}
+			var err error
+			if ff.Rendered, err = field.FormatValue(field.Value); err != nil {
+				errs = append(errs, err)
+				ff.Rendered = field.Value
+			}
+			if field.FieldType == FieldTypeSegmented && len(field.Segments) > 0 {
+				if ff.Segments, err = field.FormatSegments(field.Value); err != nil {
+					errs = append(errs, err)
+				}
+			}
+			form.Fields = append(form.Fields, ff)
+		}
+	}
+	return form, errs
+}
+
+// Export returns the rendered value of each filled field, keyed by field ID.
+func (f *FilledForm) Export() map[string]string {
+	out := make(map[string]string, len(f.Fields))
+	for _, ff := range f.Fields {
+		out[ff.FieldID] = ff.Rendered
+	}
+	return out
+}
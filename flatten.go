@@ -0,0 +1,182 @@
+package annotation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IndexStyle selects how array indexes in value paths are rendered in flat keys.
+type IndexStyle int
+
+const (
+	// IndexBrackets renders "dependents[0]".
+	IndexBrackets IndexStyle = iota
+	// IndexDelimited renders the index as its own segment, "dependents.0".
+	IndexDelimited
+)
+
+// FlattenOptions controls ExtractFlatValues and FillFromFlatValues.
+type FlattenOptions struct {
+	// Delimiter joins path segments; the default is ".".
+	Delimiter  string
+	IndexStyle IndexStyle
+	// DateFormat renders date values; the default is YYYY-MM-DD.
+	DateFormat string
+}
+
+// FlatValue is one flattened key and its value.
+type FlatValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// FlatKeyCollisionError reports two value paths that flatten to the same key.
+type FlatKeyCollisionError struct {
+	Key   string
+	Paths [2]string
+}
+
+func (e *FlatKeyCollisionError) Error() string {
+	return fmt.Sprintf("paths %q and %q both flatten to key %q", e.Paths[0], e.Paths[1], e.Key)
+}
+
+// ExtractFlatValues returns the filled values keyed by each field's
+// FieldValue path flattened with opts. Values are written in canonical form:
+// booleans as true/false, numbers without grouping, and dates in
+// opts.DateFormat. Distinct paths that flatten to the same key return a
+// *FlatKeyCollisionError.
+func (fa *FormAnnotation) ExtractFlatValues(opts FlattenOptions) (map[string]string, error) {
+	out := map[string]string{}
+	pathOf := map[string]string{}
+	for _, field := range fa.Fields() {
+		if field.FieldValue == "" || field.Value == "" {
+			continue
+		}
+		key := flatKey(field.FieldValue, opts)
+		if prev, ok := pathOf[key]; ok && prev != field.FieldValue {
+			return nil, &FlatKeyCollisionError{Key: key, Paths: [2]string{prev, field.FieldValue}}
+		}
+		v, err := extractedValue(field, ExtractOptions{DateFormat: opts.DateFormat})
+		if err != nil {
+			return nil, err
+		}
+		s, err := scalarString(v)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", field.FieldID, err)
+		}
+		out[key] = s
+		pathOf[key] = field.FieldValue
+	}
+	return out, nil
+}
+
+// ExtractFlatPairs is ExtractFlatValues ordered by key, for writing CSV.
+func (fa *FormAnnotation) ExtractFlatPairs(opts FlattenOptions) ([]FlatValue, error) {
+	values, err := fa.ExtractFlatValues(opts)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]FlatValue, 0, len(values))
+	for _, key := range sortedKeys(values) {
+		pairs = append(pairs, FlatValue{Key: key, Value: values[key]})
+	}
+	return pairs, nil
+}
+
+// FillFromFlatValues is the inverse of ExtractFlatValues: it fills every field
+// whose flattened FieldValue path appears in values. Keys matching no field
+// are reported as unknown.
+func (fa *FormAnnotation) FillFromFlatValues(values map[string]string, flat FlattenOptions, opts FillOptions) *FillReport {
+	report := &FillReport{}
+	matched := map[string]bool{}
+	for i := range fa.Pages {
+		page := fa.Pages[i].PageNumber
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			if field.FieldValue == "" {
+				continue
+			}
+			key := flatKey(field.FieldValue, flat)
+			value, ok := values[key]
+			if !ok {
+				continue
+			}
+			matched[key] = true
+			if field.DataType == DataTypeDate && flat.DateFormat != "" && strings.TrimSpace(value) != "" {
+				d, problem := parseDate(value, flat.DateFormat)
+				if problem != "" {
+					report.add(FillIssue{
+						FieldID:  field.FieldID,
+						Page:     page,
+						Code:     FillUnsupportedValue,
+						Severity: SeverityError,
+						Message:  fmt.Sprintf("%s: date %s %s", key, quoteValue(field, value), problem),
+					})
+					continue
+				}
+				value = d.String()
+			}
+			fa.placeTransformed(field, page, value, opts, report)
+		}
+	}
+	for _, key := range sortedKeys(values) {
+		if !matched[key] {
+			report.add(FillIssue{
+				Code:     FillUnknownField,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("no field with value path for key %q", key),
+			})
+		}
+	}
+	return report
+}
+
+// flatKey renders a value path such as "dependents[0].first_name" (or
+// "dependents.0.first_name") under opts.
+func flatKey(path string, opts FlattenOptions) string {
+	delim := opts.Delimiter
+	if delim == "" {
+		delim = "."
+	}
+	var sb strings.Builder
+	for i, seg := range pathSegments(path) {
+		if seg.index && opts.IndexStyle == IndexBrackets {
+			sb.WriteString("[" + seg.name + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteString(delim)
+		}
+		sb.WriteString(seg.name)
+	}
+	return sb.String()
+}
+
+type pathSegment struct {
+	name  string
+	index bool
+}
+
+// pathSegments splits a dotted value path into keys and array indexes.
+// Indexes may be written in brackets or as all-digit segments.
+func pathSegments(path string) []pathSegment {
+	var segs []pathSegment
+	for _, part := range strings.Split(path, ".") {
+		name, rest, _ := strings.Cut(part, "[")
+		if name != "" {
+			_, err := strconv.Atoi(name)
+			segs = append(segs, pathSegment{name: name, index: err == nil && len(segs) > 0})
+		}
+		for rest != "" {
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				segs = append(segs, pathSegment{name: "[" + rest})
+				break
+			}
+			segs = append(segs, pathSegment{name: idx, index: true})
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+	return segs
+}
@@ -0,0 +1,298 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Negative formats.
+const (
+	NegativeMinus       = "minus"
+	NegativeParentheses = "parentheses"
+)
+
+// Text alignments.
+const (
+	TextAlignLeft   = "left"
+	TextAlignCenter = "center"
+	TextAlignRight  = "right"
+)
+
+// Segment alignments.
+const (
+	SegmentAlignLeft  = "left"
+	SegmentAlignRight = "right"
+)
+
+// Text transforms.
+const (
+	TextUppercase = "uppercase"
+	TextLowercase = "lowercase"
+	TextTitle     = "title"
+)
+
+// FormatValue renders raw, a canonical value as filled, the way the field's
+// Formatting asks it to be drawn. Numbers are rounded to DecimalPlaces,
+// zero meaning whole amounts, grouped with commas when ShowCommas is set and
+// written negative with a minus or in parentheses; the prefix and suffix
+// sit inside the parentheses, as in "($1,234.00)". Dates are read as
+// YYYY-MM-DD, or already in DateFormat, and written in DateFormat, so
+// formatting a formatted date leaves it as it is. Text is transformed by
+// TextTransform. Fields without Formatting, and checkboxes, are returned
+// as they are.
+func (f *Field) FormatValue(raw string) (string, error) {
+	fm := f.Formatting
+	if fm == nil || raw == "" || f.FieldType == FieldTypeCheckbox || f.DataType == DataTypeBoolean {
+		return raw, nil
+	}
+	switch {
+	case f.DataType == DataTypeDate || f.FieldType == FieldTypeDate:
+		d, problem := parseDate(raw, fm.DateFormat)
+		if problem != "" {
+			return "", fmt.Errorf("field %q: date %s %s", f.FieldID, quoteValue(f, raw), problem)
+		}
+		return fm.Prefix + d.Format(fm.DateFormat) + fm.Suffix, nil
+	case f.DataType == DataTypeDecimal || f.DataType == DataTypeInteger ||
+		f.FieldType == FieldTypeCurrency || f.FieldType == FieldTypeNumeric:
+		n, ok := parseDecimal(raw)
+		if !ok {
+			return "", fmt.Errorf("field %q: %s is not a number", f.FieldID, quoteValue(f, raw))
+		}
+		negative := n.Sign() < 0
+		digits := n.Abs(n).FloatString(max(fm.DecimalPlaces, 0))
+		if fm.ShowCommas {
+			digits = groupThousands(digits)
+		}
+		s := fm.Prefix + digits + fm.Suffix
+		switch {
+		case !negative || strings.Trim(digits, "0.,") == "":
+			return s, nil
+		case fm.NegativeFormat == NegativeParentheses:
+			return "(" + s + ")", nil
+		case fm.NegativeFormat == "" || fm.NegativeFormat == NegativeMinus:
+			return "-" + s, nil
+		default:
+			return "", fmt.Errorf("field %q: unknown negative format %q", f.FieldID, fm.NegativeFormat)
+		}
+	}
+	s, err := transformText(raw, fm.TextTransform)
+	if err != nil {
+		return "", fmt.Errorf("field %q: %w", f.FieldID, err)
+	}
+	return fm.Prefix + s + fm.Suffix, nil
+}
+
+// FormatSegments is FormatValue split into the text drawn in each segment
+// of a segmented field, so an SSN becomes three, two and four digits.
+// Separators such as dashes are dropped when the value does not fit the
+// segments exactly. A date without a DateFormat is laid out by its
+// segments, as segmentDateFormat describes.
+func (f *Field) FormatSegments(raw string) ([]string, error) {
+	if len(f.Segments) == 0 {
+		return nil, fmt.Errorf("field %q has no segments", f.FieldID)
+	}
+	s, err := f.FormatValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	s = f.segmentDate(s)
+	if f.segmentLayout() {
+		return f.DistributeValue(s)
+	}
+	chunks := splitSegments(s, f.Segments)
+	if got, want := len([]rune(strings.Join(chunks, ""))), segmentCells(f.Segments); got != want && raw != "" {
+		return nil, fmt.Errorf("field %q: value %s fills %d of %d segment cells", f.FieldID, quoteValue(f, raw), got, want)
+	}
+	return chunks, nil
+}
+
+// ParseFormattedValue reads a value written as FormatValue writes it, such
+// as one read from a scanned form, back to its canonical form. The prefix
+// and suffix are removed, numbers lose their grouping, parentheses and
+// currency sign, and dates are read in DateFormat. Text is returned as it
+// is, since a case transform cannot be undone.
+func (f *Field) ParseFormattedValue(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if fm := f.Formatting; fm != nil && s != "" {
+		wrapped := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+		if wrapped {
+			s = s[1 : len(s)-1]
+		}
+		minus := strings.HasPrefix(s, "-") && fm.Prefix != "" && strings.HasPrefix(s[1:], fm.Prefix)
+		if minus {
+			s = s[1:]
+		}
+		s = strings.TrimSuffix(strings.TrimPrefix(s, fm.Prefix), fm.Suffix)
+		if minus {
+			s = "-" + s
+		}
+		if wrapped {
+			s = "(" + s + ")"
+		}
+	}
+	if f.FieldType == FieldTypeSegmented && len(f.Segments) > 0 {
+		s = strings.Join(splitSegments(s, f.Segments), "")
+	}
+	v, err := canonicalValue(f, s)
+	if err != nil {
+		return "", fmt.Errorf("field %q: %w", f.FieldID, err)
+	}
+	return v, nil
+}
+
+// displayText is the field's value as it is drawn, or as filled when it
+// cannot be formatted.
+func (f *Field) displayText() string {
+	if s, err := f.FormatValue(f.Value); err == nil {
+		return s
+	}
+	return f.Value
+}
+
+// groupThousands inserts commas into the integer part of a plain decimal.
+func groupThousands(digits string) string {
+	whole, frac, hasFrac := strings.Cut(digits, ".")
+	var sb strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(r)
+	}
+	if hasFrac {
+		sb.WriteString("." + frac)
+	}
+	return sb.String()
+}
+
+func transformText(s, transform string) (string, error) {
+	switch transform {
+	case "":
+		return s, nil
+	case TextUppercase:
+		return strings.ToUpper(s), nil
+	case TextLowercase:
+		return strings.ToLower(s), nil
+	case TextTitle:
+		runes := []rune(strings.ToLower(s))
+		for i, r := range runes {
+			if i == 0 || !unicode.IsLetter(runes[i-1]) && runes[i-1] != '\'' {
+				runes[i] = unicode.ToUpper(r)
+			}
+		}
+		return string(runes), nil
+	}
+	return "", fmt.Errorf("unknown text transform %q", transform)
+}
+
+// DistributeValue splits value across the field's segments by their
+// lengths, after removing the Formatting's StripChars. A value shorter than
+// the cells is padded with spaces on the side SegmentAlign leaves empty, so
+// every chunk fills its segment; a longer one is an error.
+func (f *Field) DistributeValue(value string) ([]string, error) {
+	if len(f.Segments) == 0 {
+		return nil, fmt.Errorf("field %q has no segments to distribute a value across", f.FieldID)
+	}
+	align := SegmentAlignLeft
+	if f.DataType == DataTypeInteger || f.DataType == DataTypeDecimal {
+		align = SegmentAlignRight
+	}
+	if fm := f.Formatting; fm != nil {
+		value = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(fm.StripChars, r) {
+				return -1
+			}
+			return r
+		}, value)
+		if fm.SegmentAlign != "" {
+			align = fm.SegmentAlign
+		}
+	}
+	runes := []rune(value)
+	total := segmentCells(f.Segments)
+	if len(runes) > total {
+		return nil, fmt.Errorf("field %q: value %s has %d characters for %d segment cells", f.FieldID, quoteValue(f, value), len(runes), total)
+	}
+	pad := []rune(strings.Repeat(" ", total-len(runes)))
+	switch align {
+	case SegmentAlignLeft:
+		runes = append(runes, pad...)
+	case SegmentAlignRight:
+		runes = append(pad, runes...)
+	default:
+		return nil, fmt.Errorf("field %q: unknown segment alignment %q", f.FieldID, align)
+	}
+	chunks := make([]string, len(f.Segments))
+	for i, seg := range f.Segments {
+		chunks[i] = string(runes[:seg.Length])
+		runes = runes[seg.Length:]
+	}
+	return chunks, nil
+}
+
+// segmentLayout reports whether the field's formatting configures how
+// values are distributed across its segments.
+func (f *Field) segmentLayout() bool {
+	return f.Formatting != nil && (f.Formatting.SegmentAlign != "" || f.Formatting.StripChars != "")
+}
+
+// segmentText is the text drawn in each segment for value: as
+// DistributeValue gives it when the formatting configures the layout and
+// the value fits, and split as it is otherwise.
+func (f *Field) segmentText(value string) []string {
+	value = f.segmentDate(value)
+	if f.segmentLayout() {
+		if chunks, err := f.DistributeValue(value); err == nil {
+			return chunks
+		}
+	}
+	return splitSegments(value, f.Segments)
+}
+
+// segmentDate rewrites a YYYY-MM-DD value of a date field with no
+// DateFormat in the order its segments imply, leaving other values as
+// they are.
+func (f *Field) segmentDate(value string) string {
+	if f.DataType != DataTypeDate || f.Formatting != nil && f.Formatting.DateFormat != "" {
+		return value
+	}
+	format := segmentDateFormat(f.Segments)
+	if format == "" {
+		return value
+	}
+	d, problem := parseDateFormat(strings.TrimSpace(value), ISODateFormat)
+	if problem != "" {
+		return value
+	}
+	return d.Format(format)
+}
+
+// segmentDateFormat returns the date layout of segments by their lengths:
+// month, day and year boxes of 2, 2 and 4 or 2, 2 and 2 cells, as IRS
+// forms print them, year first for 4, 2 and 2, or a single comb of 8 or 6
+// cells read as MMDDYYYY or MMDDYY. Other segments have none.
+func segmentDateFormat(segments []Segment) string {
+	var lengths []int
+	for _, seg := range segments {
+		lengths = append(lengths, seg.Length)
+	}
+	switch {
+	case slices.Equal(lengths, []int{2, 2, 4}), slices.Equal(lengths, []int{8}):
+		return "MMDDYYYY"
+	case slices.Equal(lengths, []int{2, 2, 2}), slices.Equal(lengths, []int{6}):
+		return "MMDDYY"
+	case slices.Equal(lengths, []int{4, 2, 2}):
+		return "YYYYMMDD"
+	}
+	return ""
+}
+
+func segmentCells(segments []Segment) int {
+	total := 0
+	for _, seg := range segments {
+		total += seg.Length
+	}
+	return total
+}
@@ -0,0 +1,549 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DocFormat selects the output of GenerateFormatDocs.
+type DocFormat int
+
+const (
+	DocMarkdown DocFormat = iota
+	DocHTML
+)
+
+func (format DocFormat) valid() bool { return format == DocMarkdown || format == DocHTML }
+
+// formatDocs describes every JSON key of the annotation format, keyed by
+// "Type.Field". MissingFormatDocs reports serialized fields without an entry.
+var formatDocs = map[string]string{
+	"FormAnnotation.FormMetadata": "Identifies the form and its page geometry.",
+	"FormAnnotation.Pages":        "The pages of the form, each holding its fields.",
+	"FormAnnotation.FieldGroups":  "Groups of related fields, such as radio options.",
+	"FormAnnotation.History":      "Tracked changes to fields, oldest first.",
+
+	"FormMetadata.FormID":               "Form identifier, such as IRS-1040.",
+	"FormMetadata.FormName":             "Human-readable form title.",
+	"FormMetadata.Year":                 "Tax year the annotation applies to.",
+	"FormMetadata.PageCount":            "Number of pages in the form.",
+	"FormMetadata.PageSize":             "Size of every page.",
+	"FormMetadata.NameMapping":          "Mapping between field IDs and PDF AcroForm names.",
+	"FormMetadata.RenderTargets":        "Render targets beyond screen and print, such as a specific printer.",
+	"FormMetadata.SchemaVersion":        "Schema version the document is shaped for; version 1 when absent, written on save.",
+	"FormMetadata.MinReaderVersion":     "Oldest schema version that can read the document; written on save.",
+	"FormMetadata.RequiredCapabilities": "Optional features the document uses; written on save.",
+	"FormMetadata.Parts":                "Forms a packet was merged from, for splitting it again.",
+	"FormMetadata.CoordinateOrigin":     "Page corner positions are measured from; the top left when absent.",
+	"FormMetadata.Revision":             "Number of recorded revisions of the annotation.",
+	"FormMetadata.SourcePDFHash":        "SHA-256 digest of the template PDF the annotation was traced from, as sha256:<hex>.",
+	"FormMetadata.CreatedAt":            "When the annotation was first revised.",
+	"FormMetadata.UpdatedAt":            "When the annotation was last revised.",
+	"FormMetadata.Author":               "Who last revised the annotation.",
+	"FormMetadata.Rounding":             "How amounts are rounded when totaled, set or calculated, such as to whole dollars.",
+	"FormMetadata.Extends":              "Base form an overlay document adds to, overrides or removes fields from.",
+
+	"FormRef.FormID": "Form identifier of the base form.",
+	"FormRef.Year":   "Tax year of the base form.",
+
+	"ChangeRecord.FieldID":   "ID of the changed field, after the change.",
+	"ChangeRecord.Author":    "Who made the change.",
+	"ChangeRecord.Timestamp": "When the change was made.",
+	"ChangeRecord.Changes":   "The field attributes that changed.",
+
+	"Change.Path": "Path of the attribute within the field, such as validation.max_length.",
+	"Change.Old":  "Value before the change; absent when the attribute was added.",
+	"Change.New":  "Value after the change; absent when the attribute was removed.",
+	"Change.Note": "For validation bounds, whether the change tightened or loosened them.",
+
+	"Provenance.Author":    "Who last changed the field.",
+	"Provenance.Timestamp": "When the field was last changed.",
+	"Provenance.Tool":      "Program that made the change.",
+	"Provenance.Note":      "Why the field was changed.",
+
+	"PacketPart.FormID":        "Form ID of the merged form.",
+	"PacketPart.FormName":      "Form name of the merged form.",
+	"PacketPart.Year":          "Tax year of the merged form.",
+	"PacketPart.FirstPage":     "Packet page number of the form's first page.",
+	"PacketPart.PageCount":     "Number of packet pages that came from the form.",
+	"PacketPart.PageSize":      "Page size of the merged form.",
+	"PacketPart.NameMapping":   "PDF name mapping of the merged form.",
+	"PacketPart.RenderTargets": "Render targets the merged form declared.",
+	"PacketPart.Groups":        "Packet IDs of the field groups that came from the form.",
+	"PacketPart.RenamedFields": "Field IDs renamed to avoid collisions, mapped to the form's own IDs.",
+	"PacketPart.RenamedGroups": "Group IDs renamed to avoid collisions, mapped to the form's own IDs.",
+
+	"PageSize.Width":  "Page width.",
+	"PageSize.Height": "Page height.",
+	"PageSize.Unit":   "Unit of the page size and of positions that declare none.",
+	"PageSize.Frame":  "Coordinate frame positions are measured in; the media box when absent.",
+
+	"CoordinateFrame.Origin":       "Whether positions are measured from the page corner or the printable area.",
+	"CoordinateFrame.MarginTop":    "Top margin of the printable area, in the page unit.",
+	"CoordinateFrame.MarginLeft":   "Left margin of the printable area, in the page unit.",
+	"CoordinateFrame.MarginBottom": "Bottom margin of the printable area, in the page unit.",
+	"CoordinateFrame.MarginRight":  "Right margin of the printable area, in the page unit.",
+
+	"Page.PageNumber": "One-based page number.",
+	"Page.Fields":     "Fields placed on the page.",
+	"Page.Includes":   "Shared page templates whose fields are added to the page when resolved.",
+	"Page.Frame":      "Coordinate frame of this page, overriding the document's.",
+	"Page.Anchors":    "Named points on the page that fields can be positioned from.",
+	"Page.Rotation":   "Degrees clockwise viewers turn the page: 0, 90, 180 or 270.",
+	"Page.PageSize":   "Width and height of this page, overriding the document's.",
+
+	"Anchor.Name": "Name relative_to blocks refer to the anchor by, unique on the page.",
+	"Anchor.X":    "Horizontal position of the anchor.",
+	"Anchor.Y":    "Vertical position of the anchor.",
+	"Anchor.Unit": "Unit of the anchor's coordinates; the page unit when omitted.",
+
+	"PageInclude.Template": "Name of the included template.",
+	"PageInclude.OffsetX":  "Horizontal offset of the template, in the page unit.",
+	"PageInclude.OffsetY":  "Vertical offset of the template, in the page unit.",
+	"PageInclude.Prefix":   "Prefix added to every included field ID.",
+
+	"Field.FieldID":        "Unique identifier of the field.",
+	"Field.IRSLineRef":     "Line on the form the field fills.",
+	"Field.Label":          "Human-readable label.",
+	"Field.PDFName":        "Name of the matching PDF form field.",
+	"Field.FieldType":      "How the field is drawn.",
+	"Field.DataType":       "Type of the value the field holds.",
+	"Field.Position":       "Box the value is drawn in.",
+	"Field.Segments":       "Boxes of a segmented field, such as an SSN, in order.",
+	"Field.Style":          "Text style of the value.",
+	"Field.CheckStyle":     "Mark style of a checkbox.",
+	"Field.Formatting":     "How the value is formatted for display.",
+	"Field.Validation":     "Constraints on the value.",
+	"Field.Calculation":    "How the value is computed from other fields.",
+	"Field.Conditions":     "When the field is shown or open to input.",
+	"Field.Options":        "Values a choice field may hold, in display order.",
+	"Field.RelativeTo":     "Anchor or field the position is computed from by ResolvePositions.",
+	"Field.Tooltip":        "Short hint shown for the field.",
+	"Field.Localized":      "Label and tooltip in other languages, keyed by language tag such as \"es\" or \"es-MX\".",
+	"Field.Barcode":        "Symbology and encoded data of a barcode field.",
+	"Field.Image":          "How an image field's picture fits its box.",
+	"Field.Signature":      "Signer role and signing date field of a signature field.",
+	"Field.GroupID":        "Group the field belongs to.",
+	"Field.FieldValue":     "Dotted path of the field's value in fill data.",
+	"Field.Value":          "Filled value.",
+	"Field.Sensitive":      "Marks the value as sensitive so that it is redacted.",
+	"Field.Sensitivity":    "Kind of personal data the value is, such as ssn or bank_account; implies sensitive.",
+	"Field.ReadOnly":       "Refuses fills of the field.",
+	"Field.OptionCode":     "Option the field represents within its radio group.",
+	"Field.Help":           "Guidance shown next to the field.",
+	"Field.Overrides":      "Adjustments applied when rendering for a target, keyed by target.",
+	"Field.IntroducedYear": "First tax year the field appears on the form.",
+	"Field.RetiredYear":    "Last tax year the field appears on the form.",
+	"Field.Transforms":     "Registered transforms applied in order to upstream values, such as trim, upper, lower, title, strip_non_digits and usps_state.",
+	"Field.TabIndex":       "Place of the field in its page's tab order, from 1.",
+	"Field.Removed":        "In year overrides, deletes the base field with this ID.",
+	"Field.Provenance":     "Who last changed the field, when and with what.",
+
+	"Position.X":      "Left edge.",
+	"Position.Y":      "Top edge.",
+	"Position.Width":  "Box width.",
+	"Position.Height": "Box height.",
+	"Position.Unit":   "Unit of the coordinates; the page unit when empty.",
+
+	"Segment.Position": "Box of the segment.",
+	"Segment.Length":   "Number of characters in the segment.",
+
+	"TextStyle.FontFamily":    "Font family name.",
+	"TextStyle.FontSize":      "Font size in points; the renderer default when omitted.",
+	"TextStyle.FontWeight":    "Font weight, such as bold.",
+	"TextStyle.TextAlign":     "Horizontal alignment within the box.",
+	"TextStyle.VerticalAlign": "Vertical alignment within the box.",
+	"TextStyle.Color":         "Text color.",
+	"TextStyle.LetterSpacing": "Extra space between characters, in points.",
+
+	"CheckStyle.MarkType":   "Shape of the check mark.",
+	"CheckStyle.MarkSize":   "Size of the mark in points.",
+	"CheckStyle.MarkWeight": "Stroke weight of the mark.",
+
+	"Formatting.DecimalPlaces":  "Digits after the decimal point.",
+	"Formatting.ShowCommas":     "Groups thousands with commas.",
+	"Formatting.NegativeFormat": "How negative amounts are written.",
+	"Formatting.Prefix":         "Text written before the value.",
+	"Formatting.Suffix":         "Text written after the value.",
+	"Formatting.DateFormat":     "Layout dates are written in.",
+	"Formatting.TextTransform":  "Case transformation of text.",
+	"Formatting.SegmentAlign":   "End of a segmented field's cells a short value is placed against.",
+	"Formatting.StripChars":     "Characters removed from a value before it is split across segments.",
+
+	"Validation.Required":   "The field must be filled.",
+	"Validation.Level":      "How strictly a required field is enforced.",
+	"Validation.RequiredIf": "Expression that makes the field required when true.",
+	"Validation.Pattern":    "Regular expression the value must match.",
+	"Validation.Min":        "Smallest allowed number.",
+	"Validation.Max":        "Largest allowed number.",
+	"Validation.MinLength":  "Fewest allowed characters.",
+	"Validation.MaxLength":  "Most allowed characters.",
+	"Validation.MinDate":    "Earliest allowed date: YYYY-MM-DD, today, tax_year_start or tax_year_end, with an optional offset such as -18y or +3m15d.",
+	"Validation.MaxDate":    "Latest allowed date, written as for min_date.",
+
+	"Calculation.Expr": "Expression over field IDs giving the value, such as line_1a + line_1b.",
+
+	"ChoiceOption.Value":       "Value the field holds when the option is chosen.",
+	"ChoiceOption.Label":       "Text shown for the option; the value when omitted.",
+	"ChoiceOption.ExportValue": "Value written to PDF form data; the value when omitted.",
+
+	"BarcodeSpec.Symbology": "Barcode symbology, such as pdf417 or code128.",
+	"BarcodeSpec.Template":  "Encoded data built from other fields' values, each {field_id} replaced; the field's own value when omitted.",
+	"ImageSpec.Fit":         "How the image is scaled into the field; contain when omitted.",
+
+	"SignatureSpec.Role":      "Who signs the field, such as taxpayer, spouse or preparer.",
+	"SignatureSpec.DateField": "ID of the date field dated when the field is signed.",
+
+	"Rounding.Places": "Decimal places amounts keep; whole dollars when omitted.",
+	"Rounding.Mode":   "How the dropped digits round the amount; half_up when omitted.",
+
+	"LabelSet.Label":   "Label in the language.",
+	"LabelSet.Tooltip": "Tooltip in the language.",
+
+	"RelativePosition.Anchor":  "Anchor on the same page the field is placed from.",
+	"RelativePosition.FieldID": "Field on the same page the field is placed from, by its top-left corner.",
+	"RelativePosition.OffsetX": "Horizontal offset from the reference point, in the field's position unit.",
+	"RelativePosition.OffsetY": "Vertical offset from the reference point, in the field's position unit.",
+
+	"Conditions.VisibleIf":  "Expression that shows the field only while true.",
+	"Conditions.DisabledIf": "Expression that closes the field to input while true.",
+
+	"FieldGroup.GroupID":         "Unique identifier of the group.",
+	"FieldGroup.GroupType":       "Kind of group.",
+	"FieldGroup.FieldIDs":        "Member field IDs.",
+	"FieldGroup.ExpectedOptions": "Option codes a radio group must cover.",
+	"FieldGroup.FieldValue":      "Data path of a radio group's selected option or a yes/no group's answer; the group ID when empty.",
+	"FieldGroup.Required":        "A yes/no group must be answered.",
+	"FieldGroup.Repeat":          "Makes the group a section of rows copied from a template row.",
+	"FieldGroup.Validation":      "Constraints on the members' values taken together.",
+	"GroupValidation.AtMostOne":  "At most one member may be filled or checked.",
+	"GroupValidation.AllOrNone":  "Every member must be filled once any is.",
+	"GroupValidation.SumEquals":  "ID of the field that must hold the sum of the members' values.",
+
+	"RepeatingRows.TemplateFieldIDs": "Members forming the first row, which later rows copy.",
+	"RepeatingRows.Spacing":          "Distance from one row to the next, in the page unit.",
+	"RepeatingRows.MaxRows":          "Most rows the form has room for.",
+
+	"NameMapping.Pairs": "Explicit field ID to PDF name pairs.",
+	"NameMapping.Rules": "Pattern rules mapping names in both directions.",
+
+	"NameRule.FieldPattern":  "Regular expression matched against field IDs.",
+	"NameRule.PDFTemplate":   "PDF name built from the field pattern's matches.",
+	"NameRule.PDFPattern":    "Regular expression matched against PDF names.",
+	"NameRule.FieldTemplate": "Field ID built from the PDF pattern's matches.",
+
+	"HelpContent.InstructionRef":  "Citation of the IRS instructions.",
+	"HelpContent.Guidance":        "Short guidance text.",
+	"HelpContent.Slug":            "URL slug of the full help article.",
+	"HelpContent.RelatedFieldIDs": "Fields the help also concerns.",
+
+	"FieldRenderOverride.DX":            "Horizontal nudge in points.",
+	"FieldRenderOverride.DY":            "Vertical nudge in points.",
+	"FieldRenderOverride.FontSizeDelta": "Points added to an explicit font size.",
+	"FieldRenderOverride.Hidden":        "Leaves the field out on the target.",
+}
+
+// formatEnums lists the allowed values of enumerated keys, from the
+// package's constants.
+var formatEnums = map[string][]string{
+	"Field.FieldType": enumStrings(FieldTypeText, FieldTypeCurrency, FieldTypeNumeric, FieldTypeCheckbox,
+		FieldTypeDate, FieldTypeSegmented, FieldTypeSignature, FieldTypeVirtual, FieldTypeChoice, FieldTypeBarcode, FieldTypeImage),
+	"Field.DataType":                enumStrings(DataTypeString, DataTypeDecimal, DataTypeInteger, DataTypeBoolean, DataTypeDate),
+	"Validation.Level":              enumStrings(RequirementHard, RequirementSoft, RequirementRecommended),
+	"FieldGroup.GroupType":          {GroupTypeRadio, GroupTypeTable, GroupTypeYesNo},
+	"PageSize.Unit":                 unitNames(),
+	"CoordinateFrame.Origin":        {FrameMediaBox, FrameMarginBox},
+	"FormMetadata.CoordinateOrigin": enumStrings(OriginTopLeft, OriginBottomLeft),
+	"Position.Unit":                 unitNames(),
+	"Formatting.NegativeFormat":     {NegativeMinus, NegativeParentheses},
+	"Formatting.TextTransform":      {TextUppercase, TextLowercase, TextTitle},
+	"Formatting.SegmentAlign":       {SegmentAlignLeft, SegmentAlignRight},
+	"TextStyle.TextAlign":           {TextAlignLeft, TextAlignCenter, TextAlignRight},
+	"BarcodeSpec.Symbology":         {SymbologyCode128, SymbologyCode39, SymbologyPDF417, SymbologyQR, SymbologyDataMatrix},
+	"ImageSpec.Fit":                 {ImageFitContain, ImageFitCover, ImageFitFill, ImageFitNone},
+	"SignatureSpec.Role":            {SignerTaxpayer, SignerSpouse, SignerPreparer, SignerDesignee, SignerOfficer},
+	"Rounding.Mode":                 {RoundHalfUp, RoundHalfEven, RoundDown},
+	"Field.Sensitivity":             {SensitivitySSN, SensitivityEIN, SensitivityITIN, SensitivityBankAccount, SensitivityRoutingNumber, SensitivityDateOfBirth, SensitivityPhone, SensitivityEmail},
+}
+
+func enumStrings[T ~string](values ...T) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = string(v)
+	}
+	return out
+}
+
+func unitNames() []string {
+	var units []string
+	for u := range pointsPerUnit {
+		units = append(units, u)
+	}
+	sort.Strings(units)
+	return units
+}
+
+// docType is one struct type of the format.
+type docType struct {
+	name   string
+	fields []docField
+}
+
+type docField struct {
+	key, goField string
+	typ          reflect.Type
+	optional     bool
+}
+
+// formatTypes walks the serialized struct types reachable from
+// FormAnnotation in breadth-first order.
+func formatTypes() []docType {
+	var types []docType
+	seen := map[reflect.Type]bool{}
+	queue := []reflect.Type{reflect.TypeOf(FormAnnotation{})}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		dt := docType{name: t.Name()}
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("json")
+			if !sf.IsExported() || tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = sf.Name
+			}
+			dt.fields = append(dt.fields, docField{
+				key:      name,
+				goField:  sf.Name,
+				typ:      sf.Type,
+				optional: strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero") || sf.Type.Kind() == reflect.Pointer,
+			})
+			if st := structOf(sf.Type); st != nil {
+				queue = append(queue, st)
+			}
+		}
+		types = append(types, dt)
+	}
+	return types
+}
+
+// structOf returns the struct type t holds, through pointers, slices and maps.
+func structOf(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && !selfDecoding(t) {
+		return t
+	}
+	return nil
+}
+
+// selfDecoding reports whether t decodes its own JSON, as time.Time and
+// json.RawMessage do, so that it is one value rather than an object.
+func selfDecoding(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]())
+}
+
+// MissingFormatDocs returns the "Type.Field" keys of serialized fields that
+// have no description, so that a test can keep the reference complete.
+func MissingFormatDocs() []string {
+	var missing []string
+	for _, t := range formatTypes() {
+		for _, f := range t.fields {
+			if _, ok := formatDocs[t.name+"."+f.goField]; !ok {
+				missing = append(missing, t.name+"."+f.goField)
+			}
+		}
+	}
+	return missing
+}
+
+// jsonTypeName describes t in JSON terms; link renders a reference to a
+// documented struct type.
+func jsonTypeName(t reflect.Type, link func(string) string) string {
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return "string (RFC 3339 timestamp)"
+	case t == reflect.TypeFor[json.RawMessage]():
+		return "any"
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonTypeName(t.Elem(), link)
+	case reflect.Slice:
+		return "array of " + jsonTypeName(t.Elem(), link)
+	case reflect.Map:
+		return "object of " + jsonTypeName(t.Elem(), link)
+	case reflect.Struct:
+		return link(t.Name())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return "integer"
+	case reflect.Float64, reflect.Float32:
+		return "number"
+	}
+	return t.Kind().String()
+}
+
+// GenerateFormatDocs renders the reference of the annotation JSON format
+// from the Go types: a section per object type with a row per key giving
+// its JSON type, whether it may be omitted, its allowed values and its
+// description.
+func GenerateFormatDocs(format DocFormat) ([]byte, error) {
+	if !format.valid() {
+		return nil, fmt.Errorf("unknown doc format %d", format)
+	}
+	var b bytes.Buffer
+	anchor := strings.ToLower
+	types := formatTypes()
+	if format == DocMarkdown {
+		b.WriteString("# Annotation format reference\n")
+		for _, t := range types {
+			fmt.Fprintf(&b, "\n## %s\n\n| Key | Type | Optional | Values | Description |\n|---|---|---|---|---|\n", t.name)
+			for _, f := range t.fields {
+				typ := jsonTypeName(f.typ, func(name string) string { return fmt.Sprintf("[%s](#%s)", name, anchor(name)) })
+				fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", f.key, typ, yesNo(f.optional),
+					strings.Join(formatEnums[t.name+"."+f.goField], ", "), formatDocs[t.name+"."+f.goField])
+			}
+		}
+		return b.Bytes(), nil
+	}
+	b.WriteString("<h1>Annotation format reference</h1>\n")
+	for _, t := range types {
+		fmt.Fprintf(&b, "<h2 id=\"%s\">%s</h2>\n<table>\n<tr><th>Key</th><th>Type</th><th>Optional</th><th>Values</th><th>Description</th></tr>\n",
+			anchor(t.name), t.name)
+		for _, f := range t.fields {
+			typ := jsonTypeName(f.typ, func(name string) string { return fmt.Sprintf("<a href=\"#%s\">%s</a>", anchor(name), name) })
+			fmt.Fprintf(&b, "<tr><td><code>%s</code></td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+				f.key, typ, yesNo(f.optional),
+				html.EscapeString(strings.Join(formatEnums[t.name+"."+f.goField], ", ")),
+				html.EscapeString(formatDocs[t.name+"."+f.goField]))
+		}
+		b.WriteString("</table>\n")
+	}
+	return b.Bytes(), nil
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// GenerateFormatSchema writes a JSON Schema (draft-07) of the annotation
+// format itself, for validating hand-written annotations in an editor. It
+// is built from the same types as GenerateFormatDocs: each object type is a
+// definition whose description, required keys and allowed values match the
+// reference, and keys no type declares are refused, as strict loading
+// refuses them. Keys are written in sorted order.
+func GenerateFormatSchema() ([]byte, error) {
+	defs := map[string]any{}
+	for _, t := range formatTypes() {
+		props := map[string]any{}
+		var required []string
+		for _, f := range t.fields {
+			key := t.name + "." + f.goField
+			prop := schemaOfType(f.typ)
+			if doc := formatDocs[key]; doc != "" {
+				prop["description"] = doc
+			}
+			if values, ok := formatEnums[key]; ok {
+				if f.goField == "Unit" {
+					prop["pattern"] = foldedAlternation(values)
+				} else {
+					prop["enum"] = values
+				}
+			}
+			props[f.key] = prop
+			if !f.optional {
+				required = append(required, f.key)
+			}
+		}
+		def := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+		if len(required) > 0 {
+			sort.Strings(required)
+			def["required"] = required
+		}
+		defs[t.name] = def
+	}
+	schema := map[string]any{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "Form annotation",
+		"$ref":        "#/definitions/FormAnnotation",
+		"definitions": defs,
+	}
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// schemaOfType is the JSON Schema of a serialized Go type, referring to
+// struct types by their definitions.
+func schemaOfType(t reflect.Type) map[string]any {
+	switch {
+	case t == reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == reflect.TypeFor[json.RawMessage]():
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOfType(t.Elem())
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOfType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOfType(t.Elem())}
+	case reflect.Struct:
+		return map[string]any{"$ref": "#/definitions/" + t.Name()}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// foldedAlternation is a pattern matching any of values in any case, for
+// the units strict loading compares without case. JSON Schema patterns have
+// no case-insensitive flag, so each letter becomes a class.
+func foldedAlternation(values []string) string {
+	alts := make([]string, len(values))
+	for i, v := range values {
+		var b strings.Builder
+		for _, r := range v {
+			lo, up := strings.ToLower(string(r)), strings.ToUpper(string(r))
+			if lo != up {
+				b.WriteString("[" + lo + up + "]")
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+		alts[i] = b.String()
+	}
+	return "^(" + strings.Join(alts, "|") + ")$"
+}
@@ -0,0 +1,79 @@
+package annotation
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestFormatDocsComplete fails when a serialized field is added without a
+// description, or a description outlives its field.
+func TestFormatDocsComplete(t *testing.T) {
+	for _, key := range MissingFormatDocs() {
+		t.Errorf("%s has no entry in formatDocs", key)
+	}
+	documented := map[string]bool{}
+	for _, dt := range formatTypes() {
+		for _, f := range dt.fields {
+			documented[dt.name+"."+f.goField] = true
+		}
+	}
+	for key, desc := range formatDocs {
+		if !documented[key] {
+			t.Errorf("formatDocs describes %s, which is not a serialized field", key)
+		}
+		if strings.TrimSpace(desc) == "" {
+			t.Errorf("formatDocs has an empty description for %s", key)
+		}
+	}
+	for key := range formatEnums {
+		if !documented[key] {
+			t.Errorf("formatEnums lists values for %s, which is not a serialized field", key)
+		}
+	}
+}
+
+// TestGenerateFormatDocsLinks checks that every type gets a section and
+// every cross-link points at one.
+func TestGenerateFormatDocsLinks(t *testing.T) {
+	types := formatTypes()
+	for _, tt := range []struct {
+		format          DocFormat
+		section, target string
+	}{
+		{DocMarkdown, `(?m)^## (\w+)$`, `\]\(#(\w+)\)`},
+		{DocHTML, `<h2 id="(\w+)">`, `<a href="#(\w+)">`},
+	} {
+		out, err := GenerateFormatDocs(tt.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anchors := map[string]bool{}
+		for _, m := range regexp.MustCompile(tt.section).FindAllStringSubmatch(string(out), -1) {
+			anchors[strings.ToLower(m[1])] = true
+		}
+		if len(anchors) != len(types) {
+			t.Errorf("format %d: %d sections for %d types", tt.format, len(anchors), len(types))
+		}
+		for _, m := range regexp.MustCompile(tt.target).FindAllStringSubmatch(string(out), -1) {
+			if !anchors[m[1]] {
+				t.Errorf("format %d: link to missing section %q", tt.format, m[1])
+			}
+		}
+	}
+	if _, err := GenerateFormatDocs(DocFormat(99)); err == nil {
+		t.Error("unknown format was accepted")
+	}
+}
+
+func TestGenerateFormatSchema(t *testing.T) {
+	out, err := GenerateFormatSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatalf("schema is not JSON: %v", err)
+	}
+}
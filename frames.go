@@ -0,0 +1,211 @@
+package annotation
+
+import "fmt"
+
+// Coordinate frame origins. Positions in the media box are measured from the
+// page's top-left corner; positions in the margin box from the top-left
+// corner of the printable area, so they may be negative near the margins.
+const (
+	FrameMediaBox  = "media_box"
+	FrameMarginBox = "margin_box"
+)
+
+// Coordinate frame issue codes.
+const (
+	InvalidCoordinateFrame = "invalid_coordinate_frame"
+	MixedCoordinateFrames  = "mixed_coordinate_frames"
+)
+
+// CoordinateFrame declares what field positions are measured from. Margins
+// are in the page unit; only the top and left margins move the origin, the
+// others are recorded for tooling.
+type CoordinateFrame struct {
+	Origin       string  `json:"origin"`
+	MarginTop    float64 `json:"margin_top,omitempty"`
+	MarginLeft   float64 `json:"margin_left,omitempty"`
+	MarginBottom float64 `json:"margin_bottom,omitempty"`
+	MarginRight  float64 `json:"margin_right,omitempty"`
+}
+
+// pageFrame returns the frame positions on pageNum are measured in: the
+// page's own declaration, else the document's, else nil for the media box.
+func (fa *FormAnnotation) pageFrame(pageNum int) *CoordinateFrame {
+	for i := range fa.Pages {
+		if fa.Pages[i].PageNumber == pageNum && fa.Pages[i].Frame != nil {
+			return fa.Pages[i].Frame
+		}
+	}
+	return fa.FormMetadata.PageSize.Frame
+}
+
+// HasCoordinateFrames reports whether the document or any page declares a frame.
+func (fa *FormAnnotation) HasCoordinateFrames() bool {
+	if fa.FormMetadata.PageSize.Frame != nil {
+		return true
+	}
+	for _, page := range fa.Pages {
+		if page.Frame != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// frameOffset returns, in points, where the origin of pageNum's frame lies
+// on the media box.
+func (fa *FormAnnotation) frameOffset(pageNum int) (dx, dy float64, ok bool) {
+	frame := fa.pageFrame(pageNum)
+	if frame == nil || frame.Origin != FrameMarginBox {
+		return 0, 0, true
+	}
+	unit := fa.FormMetadata.PageSize.Unit
+	dx, okX := toPoints(frame.MarginLeft, unit)
+	dy, okY := toPoints(frame.MarginTop, unit)
+	return dx, dy, okX && okY
+}
+
+// shiftPosition moves p by dx, dy points, keeping its own unit.
+func shiftPosition(p Position, dx, dy float64, fallbackUnit string) (Position, bool) {
+	unit := p.Unit
+	if unit == "" {
+		unit = fallbackUnit
+	}
+	factor, ok := toPoints(1, unit)
+	if !ok {
+		return p, false
+	}
+	p.X += dx / factor
+	p.Y += dy / factor
+	return p, true
+}
+
+// absoluteField returns field with its positions in media-box coordinates,
+// measured from the top-left corner. Without a margin frame on pageNum or
+// a bottom-left origin it returns field itself; the copy otherwise shares
+// nothing but the segment lengths with it.
+func (fa *FormAnnotation) absoluteField(field *Field, pageNum int) *Field {
+	if field.IsVirtual() {
+		return field
+	}
+	unit := fa.FormMetadata.PageSize.Unit
+	if fa.origin() == OriginBottomLeft {
+		height, ok := toPoints(fa.PageSizeOf(pageNum).Height, unit)
+		if !ok {
+			return field
+		}
+		out := *field
+		out.Position, _ = topLeftPosition(field.Position, height, unit)
+		out.Segments = cloneSlice(field.Segments)
+		for i := range out.Segments {
+			out.Segments[i].Position, _ = topLeftPosition(out.Segments[i].Position, height, unit)
+		}
+		return &out
+	}
+	dx, dy, ok := fa.frameOffset(pageNum)
+	if !ok || (dx == 0 && dy == 0) {
+		return field
+	}
+	out := *field
+	out.Position, _ = shiftPosition(field.Position, dx, dy, unit)
+	out.Segments = cloneSlice(field.Segments)
+	for i := range out.Segments {
+		out.Segments[i].Position, _ = shiftPosition(out.Segments[i].Position, dx, dy, unit)
+	}
+	return &out
+}
+
+// NormalizeToMediaBox shifts every position into absolute page coordinates
+// and removes the frame declarations. Pages are shifted by their own
+// frames, so it also repairs a document validation refuses as mixed. A
+// bottom-left document is moved to the top-left origin. A top-left
+// document without frames is left unchanged.
+func (fa *FormAnnotation) NormalizeToMediaBox() error {
+	for pi := range fa.Pages {
+		page := &fa.Pages[pi]
+		if _, _, ok := fa.frameOffset(page.PageNumber); !ok {
+			return fmt.Errorf("page %d: unknown page unit %q", page.PageNumber, fa.FormMetadata.PageSize.Unit)
+		}
+		for fi := range page.Fields {
+			field := &page.Fields[fi]
+			if field.IsVirtual() {
+				continue
+			}
+			for _, p := range append([]Position{field.Position}, segmentPositions(field)...) {
+				if _, ok := positionInPoints(p, fa.FormMetadata.PageSize.Unit); !ok {
+					return fmt.Errorf("field %q: unknown position unit %q", field.FieldID, p.Unit)
+				}
+			}
+		}
+	}
+	for pi := range fa.Pages {
+		page := &fa.Pages[pi]
+		for fi := range page.Fields {
+			page.Fields[fi] = *fa.absoluteField(&page.Fields[fi], page.PageNumber)
+		}
+	}
+	fa.FormMetadata.PageSize.Frame = nil
+	fa.FormMetadata.CoordinateOrigin = ""
+	for pi := range fa.Pages {
+		fa.Pages[pi].Frame = nil
+	}
+	return nil
+}
+
+func segmentPositions(field *Field) []Position {
+	positions := make([]Position, len(field.Segments))
+	for i, seg := range field.Segments {
+		positions[i] = seg.Position
+	}
+	return positions
+}
+
+// checkCoordinateFrames reports unknown origins and negative margins, and
+// refuses documents whose pages are measured in different frames.
+func (fa *FormAnnotation) checkCoordinateFrames() []ValidationIssue {
+	var issues []ValidationIssue
+	check := func(frame *CoordinateFrame, pageNum int) {
+		if frame == nil {
+			return
+		}
+		at := ValidationIssue{Code: InvalidCoordinateFrame, Severity: SeverityError, Page: pageNum}
+		switch {
+		case frame.Origin != FrameMediaBox && frame.Origin != FrameMarginBox:
+			at.Message = fmt.Sprintf("unknown coordinate frame origin %q", frame.Origin)
+		case frame.MarginTop < 0 || frame.MarginLeft < 0 || frame.MarginBottom < 0 || frame.MarginRight < 0:
+			at.Message = "coordinate frame margins must not be negative"
+		default:
+			return
+		}
+		issues = append(issues, at)
+	}
+	check(fa.FormMetadata.PageSize.Frame, 0)
+	var first *CoordinateFrame
+	firstPage := 0
+	for i, page := range fa.Pages {
+		check(page.Frame, page.PageNumber)
+		frame := fa.pageFrame(page.PageNumber)
+		if i == 0 {
+			first, firstPage = frame, page.PageNumber
+			continue
+		}
+		if !sameFrame(first, frame) {
+			issues = append(issues, ValidationIssue{
+				Code:     MixedCoordinateFrames,
+				Severity: SeverityError,
+				Page:     page.PageNumber,
+				Message:  fmt.Sprintf("page is measured in a different coordinate frame than page %d", firstPage),
+			})
+		}
+	}
+	return issues
+}
+
+// sameFrame compares frames by the origin they put positions at; a nil
+// frame and an explicit media box are the same.
+func sameFrame(a, b *CoordinateFrame) bool {
+	isMargin := func(f *CoordinateFrame) bool { return f != nil && f.Origin == FrameMarginBox }
+	if !isMargin(a) || !isMargin(b) {
+		return isMargin(a) == isMargin(b)
+	}
+	return a.MarginTop == b.MarginTop && a.MarginLeft == b.MarginLeft
+}
@@ -0,0 +1,109 @@
+package annotation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Maturity is how settled a capability's schema is.
+type Maturity string
+
+const (
+	MaturityStable       Maturity = "stable"
+	MaturityExperimental Maturity = "experimental"
+)
+
+// experimentalCapabilities lists the capabilities still being rolled out.
+// Documents using them load and save only for consumers that open their
+// gate; promoting one to stable is deleting its line.
+var experimentalCapabilities = map[Capability]bool{
+	CapYesNoGroups:      true,
+	CapCoordinateFrames: true,
+}
+
+// CapabilityMaturity returns the maturity of c.
+func CapabilityMaturity(c Capability) Maturity {
+	if experimentalCapabilities[c] {
+		return MaturityExperimental
+	}
+	return MaturityStable
+}
+
+// GateMode is what a consumer does with an experimental capability.
+type GateMode int
+
+const (
+	// GateClosed refuses documents using the capability. It is the mode of
+	// every gate not listed.
+	GateClosed GateMode = iota
+	// GateStrip removes the construct on load, as Downgrade would, and
+	// reports what was removed. Capabilities that cannot be removed are
+	// refused as if closed.
+	GateStrip
+	// GateOpen accepts the capability.
+	GateOpen
+)
+
+// FeatureGates opens experimental capabilities for one consumer. Stable
+// capabilities need no gate.
+type FeatureGates map[Capability]GateMode
+
+// ErrUngatedFeature is matched by errors.Is for every *UngatedFeatureError.
+var ErrUngatedFeature = errors.New("document uses experimental features that are not enabled")
+
+// UngatedFeatureError lists the experimental capabilities a document uses
+// whose gates are closed.
+type UngatedFeatureError struct {
+	Capabilities []Capability
+}
+
+func (e *UngatedFeatureError) Error() string {
+	names := make([]string, len(e.Capabilities))
+	for i, c := range e.Capabilities {
+		names[i] = string(c)
+	}
+	return fmt.Sprintf("%v: %s", ErrUngatedFeature, strings.Join(names, ", "))
+}
+
+func (e *UngatedFeatureError) Is(target error) bool { return target == ErrUngatedFeature }
+
+// ungated returns the experimental capabilities fa uses whose gates are
+// not open.
+func (g FeatureGates) ungated(fa *FormAnnotation) []Capability {
+	var out []Capability
+	for _, c := range fa.Capabilities().List() {
+		if experimentalCapabilities[c] && g[c] != GateOpen {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// apply enforces the gates on a loaded document: stripped capabilities are
+// removed in place and reported, and any other closed gate is an error.
+func (g FeatureGates) apply(fa *FormAnnotation) (DowngradeReport, error) {
+	report := DowngradeReport{Profile: "feature_gates"}
+	var refused []Capability
+	for _, c := range g.ungated(fa) {
+		if g[c] != GateStrip || downgraders[c] == nil {
+			refused = append(refused, c)
+		}
+	}
+	if len(refused) > 0 {
+		return report, &UngatedFeatureError{Capabilities: refused}
+	}
+	for _, c := range g.ungated(fa) {
+		downgraders[c](fa, &report)
+	}
+	return report, nil
+}
+
+// checkEmit refuses to write a document using experimental capabilities
+// whose gates are not open.
+func (g FeatureGates) checkEmit(fa *FormAnnotation) error {
+	if refused := g.ungated(fa); len(refused) > 0 {
+		return &UngatedFeatureError{Capabilities: refused}
+	}
+	return nil
+}
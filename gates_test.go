@@ -0,0 +1,139 @@
+package annotation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gatedForms has a document using each experimental capability and
+// nothing else experimental.
+func gatedForms() map[Capability]*FormAnnotation {
+	yesNo := compatForm()
+	for i, id := range []string{"yes", "no"} {
+		yesNo.Pages[0].Fields = append(yesNo.Pages[0].Fields, Field{FieldID: id, FieldType: FieldTypeCheckbox,
+			DataType: DataTypeBoolean, Position: Position{X: 36 + float64(i)*36, Y: 72, Width: 12, Height: 12, Unit: "pt"}})
+	}
+	yesNo.FieldGroups = []FieldGroup{{GroupID: "question", GroupType: GroupTypeYesNo, FieldIDs: []string{"yes", "no"}}}
+
+	framed := compatForm()
+	framed.FormMetadata.PageSize.Frame = &CoordinateFrame{Origin: FrameMarginBox, MarginTop: 36, MarginLeft: 36}
+
+	return map[Capability]*FormAnnotation{CapYesNoGroups: yesNo, CapCoordinateFrames: framed}
+}
+
+// TestFeatureGatesLoad flips each experimental capability's gate and checks
+// that a document using it is refused, stripped or accepted on load.
+func TestFeatureGatesLoad(t *testing.T) {
+	forms := gatedForms()
+	for c := range experimentalCapabilities {
+		if forms[c] == nil {
+			t.Errorf("%s has no gated fixture", c)
+		}
+	}
+	for c, fa := range forms {
+		if !fa.Capabilities().Has(c) || CapabilityMaturity(c) != MaturityExperimental {
+			t.Fatalf("%s fixture does not use an experimental %s", c, c)
+		}
+		data, err := fa.ToJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var other Capability
+		for o := range forms {
+			if o != c {
+				other = o
+			}
+		}
+		tests := []struct {
+			name  string
+			gates FeatureGates
+			want  GateMode
+		}{
+			{"no gates", nil, GateClosed},
+			{"closed", FeatureGates{c: GateClosed}, GateClosed},
+			{"other gate open", FeatureGates{other: GateOpen}, GateClosed},
+			{"strip", FeatureGates{c: GateStrip}, GateStrip},
+			{"open", FeatureGates{c: GateOpen}, GateOpen},
+		}
+		for _, tt := range tests {
+			t.Run(string(c)+"/"+tt.name, func(t *testing.T) {
+				var stripped []DowngradeReport
+				opts := LoadOptions{Gates: tt.gates, OnStripped: func(r DowngradeReport) { stripped = append(stripped, r) }}
+				got, err := Load(strings.NewReader(data), opts)
+				switch tt.want {
+				case GateClosed:
+					var ungated *UngatedFeatureError
+					if !errors.As(err, &ungated) || !errors.Is(err, ErrUngatedFeature) {
+						t.Fatalf("err = %v, want an *UngatedFeatureError", err)
+					}
+					if want := []Capability{c}; !reflect.DeepEqual(ungated.Capabilities, want) {
+						t.Errorf("refused %v, want %v", ungated.Capabilities, want)
+					}
+				case GateStrip:
+					if err != nil {
+						t.Fatal(err)
+					}
+					if got.Capabilities().Has(c) {
+						t.Errorf("%s was not stripped", c)
+					}
+					if len(stripped) != 1 || len(stripped[0].Changes) == 0 || stripped[0].Changes[0].Capability != c {
+						t.Errorf("OnStripped got %v, want the %s changes", stripped, c)
+					}
+				case GateOpen:
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !got.Capabilities().Has(c) || len(stripped) != 0 {
+						t.Errorf("open gate changed the document: stripped %v", stripped)
+					}
+				}
+			})
+		}
+	}
+}
+
+// TestFeatureGatesEmit checks that only an open gate lets a document using
+// an experimental capability be written.
+func TestFeatureGatesEmit(t *testing.T) {
+	for c, fa := range gatedForms() {
+		dir := t.TempDir()
+		if err := fa.SaveToFile(filepath.Join(dir, "plain.json")); !errors.Is(err, ErrUngatedFeature) {
+			t.Errorf("%s: SaveToFile err = %v, want ErrUngatedFeature", c, err)
+		}
+		for _, tt := range []struct {
+			name  string
+			gates FeatureGates
+			ok    bool
+		}{
+			{"no gates", nil, false},
+			{"closed", FeatureGates{c: GateClosed}, false},
+			{"strip", FeatureGates{c: GateStrip}, false},
+			{"open", FeatureGates{c: GateOpen}, true},
+		} {
+			path := filepath.Join(dir, tt.name+".json")
+			err := fa.SaveToFileWithOptions(path, SaveOptions{Gates: tt.gates})
+			if tt.ok != (err == nil) {
+				t.Errorf("%s %s: SaveToFileWithOptions err = %v, want success %v", c, tt.name, err, tt.ok)
+				continue
+			}
+			if !tt.ok {
+				if !errors.Is(err, ErrUngatedFeature) {
+					t.Errorf("%s %s: err = %v, want ErrUngatedFeature", c, tt.name, err)
+				}
+				continue
+			}
+			saved, err := LoadFile(context.Background(), path, LoadOptions{Gates: tt.gates})
+			if err != nil || !saved.Capabilities().Has(c) {
+				t.Errorf("%s: reloading the saved file: %v", c, err)
+			}
+		}
+	}
+
+	if err := compatForm().SaveToFile(filepath.Join(t.TempDir(), "stable.json")); err != nil {
+		t.Errorf("a document using only stable capabilities: %v", err)
+	}
+}
@@ -0,0 +1,360 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A field's membership is recorded twice, in its group_id and in the
+// group's field_ids. The mutators here keep both sides in step; a field
+// belongs to at most one group.
+
+// GetGroup returns the group with the given ID, or nil.
+func (fa *FormAnnotation) GetGroup(groupID string) *FieldGroup {
+	for i := range fa.FieldGroups {
+		if fa.FieldGroups[i].GroupID == groupID {
+			return &fa.FieldGroups[i]
+		}
+	}
+	return nil
+}
+
+// CreateGroup adds a group with the given members. It fails if the group
+// exists, or if a member is not a field or already belongs to a group.
+func (fa *FormAnnotation) CreateGroup(groupID, groupType string, fieldIDs ...string) error {
+	if groupID == "" {
+		return fmt.Errorf("create group: empty group ID")
+	}
+	if fa.GetGroup(groupID) != nil {
+		return fmt.Errorf("create group: group %q already exists", groupID)
+	}
+	members := make([]*Field, len(fieldIDs))
+	for i, id := range fieldIDs {
+		f := fa.GetFieldByID(id)
+		switch {
+		case f == nil:
+			return fmt.Errorf("create group %q: no field with ID %q", groupID, id)
+		case f.GroupID != "":
+			return fmt.Errorf("create group %q: field %q already belongs to group %q", groupID, id, f.GroupID)
+		case slices.Contains(members[:i], f):
+			return fmt.Errorf("create group %q: field %q is listed twice", groupID, id)
+		}
+		members[i] = f
+	}
+	ids := make([]string, len(members))
+	for i, f := range members {
+		f.GroupID = groupID
+		ids[i] = f.FieldID
+	}
+	fa.FieldGroups = append(fa.FieldGroups, FieldGroup{GroupID: groupID, GroupType: groupType, FieldIDs: ids})
+	return nil
+}
+
+// AddFieldToGroup makes a field a member of a group, moving it out of the
+// group it was in.
+func (fa *FormAnnotation) AddFieldToGroup(fieldID, groupID string) error {
+	f := fa.GetFieldByID(fieldID)
+	if f == nil {
+		return fmt.Errorf("add to group %q: no field with ID %q", groupID, fieldID)
+	}
+	g := fa.GetGroup(groupID)
+	if g == nil {
+		return fmt.Errorf("add %q to group: no group with ID %q", fieldID, groupID)
+	}
+	fa.leaveGroups(f.FieldID)
+	f.GroupID = groupID
+	g.FieldIDs = append(g.FieldIDs, f.FieldID)
+	fa.InvalidateIndex()
+	return nil
+}
+
+// AddField adds a copy of field to the end of a page. The field's ID
+// must be new, and a group_id must name an existing group, which lists the
+// field as a member.
+func (fa *FormAnnotation) AddField(pageNum int, field Field) error {
+	if field.FieldID == "" {
+		return fmt.Errorf("add field: empty field ID")
+	}
+	if fa.GetFieldByID(field.FieldID) != nil {
+		return fmt.Errorf("add field: ID %q is already in use", field.FieldID)
+	}
+	if field.GroupID != "" && fa.GetGroup(field.GroupID) == nil {
+		return fmt.Errorf("add field %q: no group with ID %q", field.FieldID, field.GroupID)
+	}
+	page := fa.page(pageNum)
+	if page == nil {
+		return fmt.Errorf("add field %q: no page %d", field.FieldID, pageNum)
+	}
+	page.Fields = append(page.Fields, field.Clone())
+	fa.joinGroup(&field)
+	fa.InvalidateIndex()
+	return nil
+}
+
+// MoveField moves a field to the end of another page, keeping its
+// position and group. Its tab index numbered its old page and is cleared.
+func (fa *FormAnnotation) MoveField(fieldID string, pageNum int) error {
+	field, from := fa.fieldAndPage(fieldID)
+	if field == nil {
+		return fmt.Errorf("move field: no field with ID %q", fieldID)
+	}
+	target := fa.page(pageNum)
+	if target == nil {
+		return fmt.Errorf("move field %q: no page %d", fieldID, pageNum)
+	}
+	if from == pageNum {
+		return nil
+	}
+	moved := *field
+	moved.TabIndex = 0
+	source := fa.page(from)
+	source.Fields = slices.DeleteFunc(source.Fields, func(f Field) bool { return f.FieldID == moved.FieldID })
+	target.Fields = append(target.Fields, moved)
+	fa.InvalidateIndex()
+	return nil
+}
+
+// RemoveField deletes a field and the references that would dangle
+// without it: its membership of any group, its place in a repeating row
+// template, a group sum into it, its mention in other fields' related
+// help, signature dates and barcode templates, and its PDF name mapping.
+// Expressions that reference the field are left for Validate to report,
+// since there is nothing to rewrite them to.
+func (fa *FormAnnotation) RemoveField(fieldID string) error {
+	for pi := range fa.Pages {
+		page := &fa.Pages[pi]
+		for fi := range page.Fields {
+			if id := page.Fields[fi].FieldID; fa.sameID(id, fieldID) {
+				page.Fields = slices.Delete(page.Fields, fi, fi+1)
+				fa.dropReferences(id)
+				fa.InvalidateIndex()
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("remove field: no field with ID %q", fieldID)
+}
+
+// dropReferences removes fieldID from group members, repeat templates,
+// group sums, related help, signature dates, barcode templates and the
+// name mapping. Fields positioned from it keep their
+// resolved position and lose their relative_to block.
+func (fa *FormAnnotation) dropReferences(fieldID string) {
+	matches := func(id string) bool { return fa.sameID(id, fieldID) }
+	fa.leaveGroups(fieldID)
+	for i := range fa.FieldGroups {
+		if r := fa.FieldGroups[i].Repeat; r != nil {
+			r.TemplateFieldIDs = slices.DeleteFunc(r.TemplateFieldIDs, matches)
+		}
+		if v := fa.FieldGroups[i].Validation; v != nil && v.SumEquals != "" && matches(v.SumEquals) {
+			v.SumEquals = ""
+		}
+	}
+	for _, f := range fa.Fields() {
+		if h := f.Help; h != nil {
+			h.RelatedFieldIDs = slices.DeleteFunc(h.RelatedFieldIDs, matches)
+		}
+		if rel := f.RelativeTo; rel != nil && rel.FieldID != "" && matches(rel.FieldID) {
+			f.RelativeTo = nil
+		}
+		if s := f.Signature; s != nil && s.DateField != "" && matches(s.DateField) {
+			s.DateField = ""
+		}
+		if b := f.Barcode; b != nil && b.Template != "" {
+			b.Template = rewriteTemplateRefs(b.Template, func(id string) string {
+				if matches(id) {
+					return ""
+				}
+				return id
+			})
+		}
+	}
+	if m := fa.FormMetadata.NameMapping; m != nil {
+		for id := range m.Pairs {
+			if matches(id) {
+				delete(m.Pairs, id)
+			}
+		}
+	}
+}
+
+// leaveGroups drops fieldID from the members of every group.
+func (fa *FormAnnotation) leaveGroups(fieldID string) {
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		g.FieldIDs = slices.DeleteFunc(g.FieldIDs, func(id string) bool { return fa.sameID(id, fieldID) })
+	}
+}
+
+// joinGroup lists field among the members of the group its group_id names,
+// when that group exists and does not list it already.
+func (fa *FormAnnotation) joinGroup(field *Field) {
+	g := fa.GetGroup(field.GroupID)
+	if g == nil || slices.ContainsFunc(g.FieldIDs, func(id string) bool { return fa.sameID(id, field.FieldID) }) {
+		return
+	}
+	g.FieldIDs = append(g.FieldIDs, field.FieldID)
+}
+
+// GroupRepair is one change RepairGroups made.
+type GroupRepair struct {
+	GroupID string `json:"group_id"`
+	FieldID string `json:"field_id,omitempty"`
+	Action  string `json:"action"`
+}
+
+// RepairGroups reconciles the fields' group IDs with the groups' member
+// lists. A field's own group_id wins when it names a group: the field is
+// added to that group and dropped from any other that lists it. A field
+// without a group_id, or naming an undefined group, joins the first group
+// listing it. Members that are not fields and repeated members are
+// dropped, and a group_id naming no group creates it, as a radio group
+// when its fields are all checkboxes and a table otherwise. It returns the
+// changes in the order made.
+func (fa *FormAnnotation) RepairGroups() []GroupRepair {
+	var repairs []GroupRepair
+	note := func(groupID, fieldID, format string, args ...any) {
+		repairs = append(repairs, GroupRepair{groupID, fieldID, fmt.Sprintf(format, args...)})
+	}
+
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		var kept []string
+		for _, id := range g.FieldIDs {
+			f := fa.GetFieldByID(id)
+			switch {
+			case f == nil:
+				note(g.GroupID, id, "dropped member that is not a field")
+			case slices.ContainsFunc(kept, func(k string) bool { return fa.sameID(k, id) }):
+				note(g.GroupID, id, "dropped repeated member")
+			case f.GroupID == "":
+				f.GroupID = g.GroupID
+				note(g.GroupID, f.FieldID, "set the field's group_id")
+				kept = append(kept, id)
+			case f.GroupID != g.GroupID && fa.GetGroup(f.GroupID) != nil:
+				note(g.GroupID, id, "dropped member that belongs to group %q", f.GroupID)
+			case f.GroupID != g.GroupID:
+				note(g.GroupID, f.FieldID, "replaced the field's undefined group_id %q", f.GroupID)
+				f.GroupID = g.GroupID
+				kept = append(kept, id)
+			default:
+				kept = append(kept, id)
+			}
+		}
+		if kept == nil {
+			kept = []string{}
+		}
+		g.FieldIDs = kept
+	}
+
+	for _, f := range fa.Fields() {
+		if f.GroupID == "" {
+			continue
+		}
+		g := fa.GetGroup(f.GroupID)
+		if g == nil {
+			groupType := GroupTypeTable
+			if fa.Query().InGroup(f.GroupID).Where(func(m *Field) bool { return m.FieldType != FieldTypeCheckbox }).First() == nil {
+				groupType = GroupTypeRadio
+			}
+			fa.FieldGroups = append(fa.FieldGroups, FieldGroup{GroupID: f.GroupID, GroupType: groupType, FieldIDs: []string{}})
+			g = &fa.FieldGroups[len(fa.FieldGroups)-1]
+			note(g.GroupID, "", "created %s group named by its fields", groupType)
+		}
+		if !slices.ContainsFunc(g.FieldIDs, func(id string) bool { return fa.sameID(id, f.FieldID) }) {
+			g.FieldIDs = append(g.FieldIDs, f.FieldID)
+			note(g.GroupID, f.FieldID, "added the field to the members")
+		}
+	}
+	return repairs
+}
+
+// RadioGroup is a radio group whose members are all checkboxes, with its
+// members in listed order.
+type RadioGroup struct {
+	*FieldGroup
+	Members []*Field
+}
+
+// RadioGroups returns the radio groups whose members are all checkboxes.
+func (fa *FormAnnotation) RadioGroups() []RadioGroup {
+	var out []RadioGroup
+groups:
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		if g.GroupType != GroupTypeRadio {
+			continue
+		}
+		members := make([]*Field, len(g.FieldIDs))
+		for j, id := range g.FieldIDs {
+			if members[j] = fa.GetFieldByID(id); members[j] == nil || members[j].FieldType != FieldTypeCheckbox {
+				continue groups
+			}
+		}
+		out = append(out, RadioGroup{g, members})
+	}
+	return out
+}
+
+// Checked returns the members checked in values, keyed by field ID.
+func (r RadioGroup) Checked(values map[string]string) []*Field {
+	var checked []*Field
+	for _, f := range r.Members {
+		if isChecked(values[f.FieldID]) {
+			checked = append(checked, f)
+		}
+	}
+	return checked
+}
+
+// CheckExactlyOne reports, for value validation, a group in which no
+// member or more than one is checked in values, keyed by field ID.
+func (r RadioGroup) CheckExactlyOne(values map[string]string) []ValidationIssue {
+	checked := r.Checked(values)
+	at := ValidationIssue{Severity: SeverityError, GroupID: r.GroupID}
+	switch len(checked) {
+	case 1:
+		return nil
+	case 0:
+		at.Code, at.Message = ValueRequired, "one option must be checked"
+	default:
+		ids := make([]string, len(checked))
+		for i, f := range checked {
+			ids[i] = f.FieldID
+		}
+		at.Code, at.Message = GroupMultipleChecked, fmt.Sprintf("only one option may be checked, got %s", strings.Join(ids, ", "))
+	}
+	return []ValidationIssue{at}
+}
+
+// SetGroupValue checks one member of a radio group and clears the rest;
+// an empty fieldID clears them all. Every member must be a checkbox, and
+// each is placed like any other fill.
+func (fa *FormAnnotation) SetGroupValue(groupID, fieldID string, opts FillOptions) (*FillReport, error) {
+	g := fa.GetGroup(groupID)
+	switch {
+	case g == nil:
+		return nil, fmt.Errorf("set group value: no group with ID %q", groupID)
+	case g.GroupType != GroupTypeRadio:
+		return nil, fmt.Errorf("set group value: group %q has type %q, not %q", groupID, g.GroupType, GroupTypeRadio)
+	case fieldID != "" && !slices.ContainsFunc(g.FieldIDs, func(id string) bool { return fa.sameID(id, fieldID) }):
+		return nil, fmt.Errorf("set group value: %q is not a member of group %q", fieldID, groupID)
+	}
+	members := make([]*Field, len(g.FieldIDs))
+	for i, id := range g.FieldIDs {
+		members[i] = fa.GetFieldByID(id)
+		switch {
+		case members[i] == nil:
+			return nil, fmt.Errorf("set group value: group %q: member %q is not a field", groupID, id)
+		case members[i].FieldType != FieldTypeCheckbox:
+			return nil, fmt.Errorf("set group value: group %q: member %q is a %s field, not a checkbox", groupID, id, members[i].FieldType)
+		}
+	}
+	report := &FillReport{}
+	for _, f := range members {
+		_, page := fa.fieldAndPage(f.FieldID)
+		fa.place(f, page, checkValue(fieldID != "" && fa.sameID(f.FieldID, fieldID)), opts, report)
+	}
+	return report, nil
+}
@@ -0,0 +1,148 @@
+package annotation
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// GroupValidation constrains the values of a group's members together.
+// Any combination of the constraints may be set.
+type GroupValidation struct {
+	// AtMostOne allows at most one member to be filled, or checked.
+	AtMostOne bool `json:"at_most_one,omitempty"`
+	// AllOrNone requires every member to be filled once any is, as the
+	// lines of an address are.
+	AllOrNone bool `json:"all_or_none,omitempty"`
+	// SumEquals is the ID of a decimal or integer field whose value must be
+	// the sum of the members', rounded by the form's rounding policy.
+	SumEquals string `json:"sum_equals,omitempty"`
+}
+
+// Group rules exported in validation bundles for GroupValidation, besides
+// GroupRuleAtMostOne.
+const (
+	GroupRuleAllOrNone = "all_or_none"
+	GroupRuleSumEquals = "sum_equals"
+)
+
+// Group validation issue codes: InvalidGroupValidation for a constraint
+// that cannot be checked, and the value codes GroupPartiallyFilled and
+// GroupSumMismatch. AtMostOne reports GroupMultipleChecked.
+const (
+	InvalidGroupValidation = "invalid_group_validation"
+	GroupPartiallyFilled   = "partially_filled"
+	GroupSumMismatch       = "sum_mismatch"
+)
+
+// checkGroupValidations reports sums over members or into a field that do
+// not hold numbers, and sum fields that do not exist.
+func (fa *FormAnnotation) checkGroupValidations() []ValidationIssue {
+	var issues []ValidationIssue
+	numeric := func(f *Field) bool { return f.DataType == DataTypeDecimal || f.DataType == DataTypeInteger }
+	for _, g := range fa.FieldGroups {
+		v := g.Validation
+		if v == nil || v.SumEquals == "" {
+			continue
+		}
+		add := func(format string, args ...any) {
+			issues = append(issues, ValidationIssue{Code: InvalidGroupValidation, Severity: SeverityError, GroupID: g.GroupID,
+				Message: fmt.Sprintf(format, args...)})
+		}
+		switch target := fa.GetFieldByID(v.SumEquals); {
+		case target == nil:
+			add("sum field %q does not exist", v.SumEquals)
+		case !numeric(target):
+			add("sum field %q holds %s, not a decimal or integer", target.FieldID, target.DataType)
+		}
+		for _, id := range g.FieldIDs {
+			if f := fa.GetFieldByID(id); f != nil && !numeric(f) {
+				add("member %q holds %s and cannot be summed", f.FieldID, f.DataType)
+			}
+		}
+	}
+	return issues
+}
+
+// bundleGroupRules exports g's validation as bundle group rules.
+func (fa *FormAnnotation) bundleGroupRules(g FieldGroup) []BundleGroup {
+	v := g.Validation
+	if v == nil {
+		return nil
+	}
+	ids := fa.resolveIDs(g.FieldIDs)
+	var rules []BundleGroup
+	if v.AtMostOne {
+		rules = append(rules, BundleGroup{GroupID: g.GroupID, Rule: GroupRuleAtMostOne, FieldIDs: ids})
+	}
+	if v.AllOrNone {
+		rules = append(rules, BundleGroup{GroupID: g.GroupID, Rule: GroupRuleAllOrNone, FieldIDs: ids})
+	}
+	if v.SumEquals != "" {
+		target := v.SumEquals
+		if f := fa.GetFieldByID(target); f != nil {
+			target = f.FieldID
+		}
+		rules = append(rules, BundleGroup{GroupID: g.GroupID, Rule: GroupRuleSumEquals, FieldIDs: ids,
+			SumField: target, Rounding: clonePtr(fa.FormMetadata.Rounding)})
+	}
+	return rules
+}
+
+// checkAllOrNone reports a group some but not all of whose members are
+// filled.
+func (b *ValidationBundle) checkAllOrNone(group BundleGroup, values map[string]string) []ValidationIssue {
+	var empty []string
+	for _, id := range group.FieldIDs {
+		bf := b.Fields[id]
+		if isEmptyValue(bf.FieldType, bf.DataType, values[id]) {
+			empty = append(empty, id)
+		}
+	}
+	if len(empty) == 0 || len(empty) == len(group.FieldIDs) {
+		return nil
+	}
+	return []ValidationIssue{{Code: GroupPartiallyFilled, Severity: SeverityError, GroupID: group.GroupID,
+		Message: fmt.Sprintf("fill every member or none; %s empty", strings.Join(empty, ", "))}}
+}
+
+// checkSum reports a sum field whose value differs from the sum of the
+// members. Values that are not numbers are left to the field checks.
+func (b *ValidationBundle) checkSum(group BundleGroup, values map[string]string) []ValidationIssue {
+	sum := new(big.Rat)
+	filled := false
+	for _, id := range group.FieldIDs {
+		v := strings.TrimSpace(values[id])
+		if v == "" {
+			continue
+		}
+		n, ok := parseDecimal(v)
+		if !ok {
+			return nil
+		}
+		sum.Add(sum, n)
+		filled = true
+	}
+	if group.Rounding != nil {
+		sum = group.Rounding.Round(sum)
+	}
+	mismatch := func(format string, args ...any) []ValidationIssue {
+		return []ValidationIssue{{Code: GroupSumMismatch, Severity: SeverityError, GroupID: group.GroupID, FieldID: group.SumField,
+			Message: fmt.Sprintf(format, args...)}}
+	}
+	got := strings.TrimSpace(values[group.SumField])
+	if got == "" {
+		if filled && sum.Sign() != 0 {
+			return mismatch("members sum to %s, but %q is empty", sum.FloatString(2), group.SumField)
+		}
+		return nil
+	}
+	n, ok := parseDecimal(got)
+	if !ok {
+		return nil
+	}
+	if n.Cmp(sum) != 0 {
+		return mismatch("%q is %s, but the members sum to %s", group.SumField, got, sum.FloatString(2))
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package annotation
+
+import "testing"
+
+func sumForm() *FormAnnotation {
+	amount := func(id string, y float64) Field {
+		return Field{FieldID: id, FieldType: FieldTypeCurrency, DataType: DataTypeDecimal,
+			Position: Position{X: 400, Y: y, Width: 120, Height: 18, Unit: "pt"}}
+	}
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages:        []Page{{PageNumber: 1, Fields: []Field{amount("wages", 36), amount("interest", 72), amount("total", 108)}}},
+		FieldGroups: []FieldGroup{{GroupID: "income", GroupType: GroupTypeTable, FieldIDs: []string{"wages", "interest"},
+			Validation: &GroupValidation{SumEquals: "total"}}},
+	}
+}
+
+func TestRenameFieldUpdatesGroupSum(t *testing.T) {
+	fa := sumForm()
+	if err := fa.RenameField("total", "total_income"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fa.GetGroup("income").Validation.SumEquals; got != "total_income" {
+		t.Errorf("sum_equals = %q, want total_income", got)
+	}
+}
+
+func TestRemoveFieldClearsGroupSum(t *testing.T) {
+	fa := sumForm()
+	if err := fa.RemoveField("total"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fa.GetGroup("income").Validation.SumEquals; got != "" {
+		t.Errorf("sum_equals = %q, want it cleared", got)
+	}
+	for _, issue := range fa.Validate() {
+		if issue.Code == InvalidGroupValidation {
+			t.Errorf("issue after removal: %v", issue)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkPlacement reports why value cannot be drawn into field, or "" when it can.
+// It is the last line of defense before stamping and applies even when the
+// annotation was never validated: an empty value never trips it, and neither
+// does an unchecked checkbox or a virtual field, since nothing would be drawn.
+// Positions are checked in the media box of pageNum.
+func (fa *FormAnnotation) checkPlacement(field *Field, pageNum int, value string) string {
+	if field.IsVirtual() || strings.TrimSpace(value) == "" {
+		return ""
+	}
+	field = fa.absoluteField(field, pageNum)
+	page, ok := pageInPoints(fa.PageSizeOf(pageNum))
+	if !ok {
+		return fmt.Sprintf("unknown page unit %q", fa.FormMetadata.PageSize.Unit)
+	}
+	unit := fa.FormMetadata.PageSize.Unit
+
+	switch field.FieldType {
+	case FieldTypeSegmented:
+		if len(field.Segments) == 0 {
+			return "segmented field has no segments"
+		}
+		for i, seg := range field.Segments {
+			if problem := checkRect(seg.Position, unit, page, false); problem != "" {
+				return fmt.Sprintf("segment %d: %s", i, problem)
+			}
+		}
+		return ""
+	case FieldTypeCheckbox:
+		if !isChecked(value) {
+			return ""
+		}
+		return checkMark(field, unit, page)
+	case FieldTypeSignature, FieldTypeBarcode, FieldTypeImage:
+		// These are drawn as a single block; clipping any part of a
+		// signature invalidates it and a clipped barcode does not scan, so
+		// they must sit entirely on the page.
+		return checkRect(field.Position, unit, page, true)
+	default:
+		return checkRect(field.Position, unit, page, false)
+	}
+}
+
+// checkRect verifies that p has positive area and lies on the page. When
+// contained is set the rectangle must be fully inside the page; otherwise it
+// only needs to overlap it.
+func checkRect(p Position, fallbackUnit string, page PageSize, contained bool) string {
+	r, ok := positionInPoints(p, fallbackUnit)
+	if !ok {
+		return fmt.Sprintf("unknown position unit %q", p.Unit)
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return fmt.Sprintf("zero or negative area (%gx%g)", p.Width, p.Height)
+	}
+	if contained {
+		if r.X < 0 || r.Y < 0 || r.X+r.Width > page.Width || r.Y+r.Height > page.Height {
+			return "not fully inside the page"
+		}
+		return ""
+	}
+	if r.X+r.Width <= 0 || r.Y+r.Height <= 0 || r.X >= page.Width || r.Y >= page.Height {
+		return "lies outside the page"
+	}
+	return ""
+}
+
+// checkMark verifies that a checkbox can hold its mark: the box needs positive
+// area, its center must be on the page, and a declared mark must fit inside it.
+func checkMark(field *Field, fallbackUnit string, page PageSize) string {
+	r, ok := positionInPoints(field.Position, fallbackUnit)
+	if !ok {
+		return fmt.Sprintf("unknown position unit %q", field.Position.Unit)
+	}
+	if r.Width <= 0 || r.Height <= 0 {
+		return fmt.Sprintf("zero or negative area (%gx%g)", field.Position.Width, field.Position.Height)
+	}
+	cx, cy := r.X+r.Width/2, r.Y+r.Height/2
+	if cx < 0 || cy < 0 || cx > page.Width || cy > page.Height {
+		return "checkbox center lies outside the page"
+	}
+	if field.CheckStyle != nil && field.CheckStyle.MarkSize > min(r.Width, r.Height) {
+		return fmt.Sprintf("mark size %g does not fit a %gx%g box", field.CheckStyle.MarkSize, r.Width, r.Height)
+	}
+	return ""
+}
+
+// placementIssue builds the report entry for a field the guard objected to and
+// reports whether the value may still be placed under opts.
+func placementIssue(field *Field, page int, problem string, opts FillOptions) (FillIssue, bool) {
+	issue := FillIssue{
+		FieldID:  field.FieldID,
+		Page:     page,
+		Code:     FillUnplaceableField,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("field %q cannot be drawn: %s", field.FieldID, problem),
+	}
+	if opts.GeometryPolicy != GeometryWarn {
+		return issue, false
+	}
+	issue.Severity = SeverityWarning
+	return issue, true
+}
@@ -0,0 +1,122 @@
+package annotation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// StructuralHash returns a SHA-256 digest of the annotation's structure:
+// everything except the filled values, the change history, and the field
+// and document provenance other than SourcePDFHash. Two annotations that differ only in their values share a
+// structural hash.
+func (fa *FormAnnotation) StructuralHash() (string, error) {
+	return fa.StructuralHashWithOptions(HashOptions{})
+}
+
+// HashOptions controls StructuralHashWithOptions.
+type HashOptions struct {
+	// IgnoreRenderOverrides leaves the presentation-only render overrides and
+	// declared render targets out of the hash.
+	IgnoreRenderOverrides bool
+	// Templates, when set, hashes the annotation with its page includes
+	// resolved against this library instead of as written.
+	Templates *Library
+}
+
+// ContentHash returns the ContentHash of the bytes SaveToFileCanonical
+// writes, so two annotations share it exactly when their canonical files
+// are identical. Unlike StructuralHash it covers the filled values.
+func (fa *FormAnnotation) ContentHash() (string, error) {
+	data, err := fa.canonicalJSON(NormalizeOptions{})
+	if err != nil {
+		return "", err
+	}
+	return ContentHash(data), nil
+}
+
+// ComputeFingerprint returns the ContentHash of the canonical form of the
+// annotation without its audit trail: the change history, field provenance,
+// and the metadata's Revision, CreatedAt, UpdatedAt and Author. Revising an
+// annotation without changing it leaves its fingerprint alone, while any
+// change to a field, a value or SourcePDFHash changes it.
+func (fa *FormAnnotation) ComputeFingerprint() (string, error) {
+	c := fa.Clone()
+	c.History = nil
+	for _, f := range c.Fields() {
+		f.Provenance = nil
+	}
+	md := &c.FormMetadata
+	md.Revision, md.CreatedAt, md.UpdatedAt, md.Author = 0, time.Time{}, time.Time{}, ""
+	c.Normalize()
+	data, err := json.Marshal(c.withReaderRequirements())
+	if err != nil {
+		return "", err
+	}
+	return ContentHash(data), nil
+}
+
+// MatchesSourcePDF reports whether pdf is the template the annotation was
+// traced from, by its SourcePDFHash. It is false when no hash is recorded.
+func (fa *FormAnnotation) MatchesSourcePDF(pdf []byte) bool {
+	return fa.FormMetadata.SourcePDFHash != "" && fa.FormMetadata.SourcePDFHash == ContentHash(pdf)
+}
+
+// revisionKeys are the metadata keys Revise writes.
+var revisionKeys = []string{"revision", "created_at", "updated_at", "author"}
+
+// StructuralHashWithOptions is StructuralHash with options.
+func (fa *FormAnnotation) StructuralHashWithOptions(opts HashOptions) (string, error) {
+	if opts.Templates != nil {
+		resolved, err := fa.ResolveIncludes(opts.Templates)
+		if err != nil {
+			return "", err
+		}
+		fa = resolved
+	}
+	data, err := json.Marshal(fa)
+	if err != nil {
+		return "", err
+	}
+	// Round-trip through a generic document so that map keys are emitted in
+	// sorted order and the filled values can be dropped.
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	if pages, ok := doc["pages"].([]any); ok {
+		for _, p := range pages {
+			page, _ := p.(map[string]any)
+			fields, _ := page["fields"].([]any)
+			for _, f := range fields {
+				if field, ok := f.(map[string]any); ok {
+					delete(field, "value")
+					delete(field, "provenance")
+					if opts.IgnoreRenderOverrides {
+						delete(field, "overrides")
+					}
+				}
+			}
+		}
+	}
+	if meta, ok := doc["form_metadata"].(map[string]any); ok {
+		// The schema version and reader requirements are written on save.
+		delete(meta, "schema_version")
+		delete(meta, "min_reader_version")
+		delete(meta, "required_capabilities")
+		for _, key := range revisionKeys {
+			delete(meta, key)
+		}
+		if opts.IgnoreRenderOverrides {
+			delete(meta, "render_targets")
+		}
+	}
+	delete(doc, "history")
+	canonical, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,145 @@
+package annotation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// HelpContent is the guidance a filing UI shows next to a field.
+type HelpContent struct {
+	// InstructionRef cites the IRS instructions, such as "1040 instructions p. 23".
+	InstructionRef  string   `json:"instruction_ref,omitempty"`
+	Guidance        string   `json:"guidance,omitempty"`
+	Slug            string   `json:"slug,omitempty"`
+	RelatedFieldIDs []string `json:"related_field_ids,omitempty"`
+}
+
+// HelpEntry is the help for one field in a HelpIndex.
+type HelpEntry struct {
+	HelpContent
+	LineRef string `json:"irs_line_reference,omitempty"`
+	Label   string `json:"label,omitempty"`
+	Page    int    `json:"page"`
+}
+
+// HelpIndex is the flat help document consumed by the front end: entries
+// keyed by field ID, and the field IDs that carry each line reference.
+type HelpIndex struct {
+	FormID   string               `json:"form_id"`
+	Fields   map[string]HelpEntry `json:"fields"`
+	LineRefs map[string][]string  `json:"line_refs"`
+}
+
+// ExportHelpIndex collects the help of every field that has some.
+func (fa *FormAnnotation) ExportHelpIndex() *HelpIndex {
+	index := &HelpIndex{
+		FormID:   fa.FormMetadata.FormID,
+		Fields:   map[string]HelpEntry{},
+		LineRefs: map[string][]string{},
+	}
+	for _, page := range fa.Pages {
+		for _, field := range page.Fields {
+			if field.Help == nil {
+				continue
+			}
+			index.Fields[field.FieldID] = HelpEntry{
+				HelpContent: *field.Help,
+				LineRef:     field.IRSLineRef,
+				Label:       field.Label,
+				Page:        page.PageNumber,
+			}
+			if field.IRSLineRef != "" {
+				index.LineRefs[field.IRSLineRef] = append(index.LineRefs[field.IRSLineRef], field.FieldID)
+			}
+		}
+	}
+	return index
+}
+
+// HelpImportReport summarizes a BulkImportHelp run.
+type HelpImportReport struct {
+	// Applied lists the fields that received help, in file order.
+	Applied []string `json:"applied"`
+	// Unmatched lists the line references that matched no field.
+	Unmatched []string `json:"unmatched,omitempty"`
+}
+
+// helpColumns are the recognized BulkImportHelp CSV headers.
+var helpColumns = []string{"line_ref", "instruction_ref", "guidance", "slug", "related_field_ids"}
+
+// BulkImportHelp loads help from CSV with a header row naming the columns
+// line_ref, instruction_ref, guidance, slug and related_field_ids; only
+// line_ref is required, and related field IDs are separated by semicolons.
+// Each row replaces the help of every field whose IRS line reference matches
+// line_ref exactly, so a line shared by several fields fans out to all of them.
+func (fa *FormAnnotation) BulkImportHelp(r io.Reader) (*HelpImportReport, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("help CSV: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	if _, ok := columns["line_ref"]; !ok {
+		return nil, fmt.Errorf("help CSV: no line_ref column (columns are %s)", strings.Join(helpColumns, ", "))
+	}
+
+	byRef := map[string][]*Field{}
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			if field.IRSLineRef != "" {
+				byRef[field.IRSLineRef] = append(byRef[field.IRSLineRef], field)
+			}
+		}
+	}
+
+	report := &HelpImportReport{}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("help CSV: %w", err)
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		ref := cell("line_ref")
+		if ref == "" {
+			line, _ := cr.FieldPos(0)
+			return report, fmt.Errorf("help CSV line %d: empty line_ref", line)
+		}
+		fields := byRef[ref]
+		if len(fields) == 0 {
+			report.Unmatched = append(report.Unmatched, ref)
+			continue
+		}
+		var related []string
+		for _, id := range strings.Split(cell("related_field_ids"), ";") {
+			if id = strings.TrimSpace(id); id != "" {
+				related = append(related, id)
+			}
+		}
+		for _, field := range fields {
+			field.Help = &HelpContent{
+				InstructionRef:  cell("instruction_ref"),
+				Guidance:        cell("guidance"),
+				Slug:            cell("slug"),
+				RelatedFieldIDs: cloneSlice(related),
+			}
+			report.Applied = append(report.Applied, field.FieldID)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,134 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Provenance records who last changed a field, when, and with what.
+type Provenance struct {
+	Author    string    `json:"author,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Tool names the program that made the change, such as an editor.
+	Tool string `json:"source_tool,omitempty"`
+	// Note says why the field was changed.
+	Note string `json:"revision_note,omitempty"`
+}
+
+// ChangeRecord is one tracked change to a field, with the attributes that
+// changed and their values before and after. FieldID is the field's ID
+// after the change.
+type ChangeRecord struct {
+	FieldID   string    `json:"field_id"`
+	Author    string    `json:"author,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Changes   []Change  `json:"changes"`
+}
+
+// redactedChange stands in for the old and new values of a sensitive field
+// in its history.
+var redactedChange = json.RawMessage(`"[redacted]"`)
+
+// TrackedUpdate applies fn to the field as UpdateField does, then stamps the
+// field's provenance with author and the current time and appends the
+// change to History and revises the document as Revise does. The Tool and
+// Note fn leaves in the field's provenance are kept. A sensitive field's
+// values are recorded as "[redacted]". When fn changes nothing, neither
+// provenance nor history is touched.
+func (fa *FormAnnotation) TrackedUpdate(fieldID string, author string, fn func(f *Field) error) error {
+	field := fa.GetFieldByID(fieldID)
+	if field == nil {
+		return fmt.Errorf("tracked update: no field with ID %q", fieldID)
+	}
+	before := field.Clone()
+	if err := fa.UpdateField(fieldID, fn); err != nil {
+		return err
+	}
+	after := field.Clone()
+	before.Provenance, after.Provenance = nil, nil
+	changes := diffJSON(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+	if before.IsSensitive() || after.IsSensitive() {
+		for i := range changes {
+			if changes[i].Path == "value" {
+				changes[i].Old, changes[i].New = redactedChange, redactedChange
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	prov := Provenance{Author: author, Timestamp: now}
+	if field.Provenance != nil {
+		prov.Tool, prov.Note = field.Provenance.Tool, field.Provenance.Note
+	}
+	field.Provenance = &prov
+	fa.History = append(fa.History, ChangeRecord{FieldID: field.FieldID, Author: author, Timestamp: now, Changes: changes})
+	fa.revise(author, now)
+	return nil
+}
+
+// Revise records an edit of the document by author: it increments Revision
+// and sets UpdatedAt to the current time, and CreatedAt too when it is not
+// yet set, and Author to author.
+func (fa *FormAnnotation) Revise(author string) {
+	fa.revise(author, time.Now().UTC())
+}
+
+func (fa *FormAnnotation) revise(author string, now time.Time) {
+	md := &fa.FormMetadata
+	md.Revision++
+	if md.CreatedAt.IsZero() {
+		md.CreatedAt = now
+	}
+	md.UpdatedAt, md.Author = now, author
+}
+
+// HistorySince returns the recorded changes made at or after t, oldest
+// first.
+func (fa *FormAnnotation) HistorySince(t time.Time) []ChangeRecord {
+	var out []ChangeRecord
+	for _, rec := range fa.History {
+		if !rec.Timestamp.Before(t) {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// hasProvenance reports whether any of the revision and source fields is set.
+func (md *FormMetadata) hasProvenance() bool {
+	return md.Revision != 0 || md.SourcePDFHash != "" || !md.CreatedAt.IsZero() || !md.UpdatedAt.IsZero() || md.Author != ""
+}
+
+func cloneHistory(history []ChangeRecord) []ChangeRecord {
+	if history == nil {
+		return nil
+	}
+	out := make([]ChangeRecord, len(history))
+	for i, rec := range history {
+		out[i] = rec
+		out[i].Changes = cloneSlice(rec.Changes)
+		for j := range out[i].Changes {
+			out[i].Changes[j].Old = cloneSlice(rec.Changes[j].Old)
+			out[i].Changes[j].New = cloneSlice(rec.Changes[j].New)
+		}
+	}
+	return out
+}
+
+// downgradeHistory drops the document's provenance, the change history and
+// every field's provenance.
+func downgradeHistory(fa *FormAnnotation, r *DowngradeReport) {
+	if md := &fa.FormMetadata; md.hasProvenance() {
+		md.Revision, md.SourcePDFHash, md.CreatedAt, md.UpdatedAt, md.Author = 0, "", time.Time{}, time.Time{}, ""
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapProvenance, Action: "dropped document provenance"})
+	}
+	if len(fa.History) > 0 {
+		fa.History = nil
+		r.Changes = append(r.Changes, DowngradeChange{Capability: CapProvenance, Action: "dropped change history"})
+	}
+	downgradeFields(CapProvenance, "dropped provenance", func(f *Field) bool { return clearPtr(&f.Provenance) })(fa, r)
+}
@@ -0,0 +1,138 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// LoadHook runs on every annotation the package decodes, after migration
+// and gates, and may change it; an error fails the load.
+type LoadHook func(fa *FormAnnotation) error
+
+// SaveHook runs on a copy of every annotation about to be written, so it
+// can change what is written, such as uppercasing text values, without
+// changing the annotation itself; an error fails the save.
+type SaveHook func(fa *FormAnnotation) error
+
+// FieldSetHook runs before a fill places value in field and returns the
+// value to place instead, such as value with disallowed characters
+// stripped; an error refuses the value. It should change the field only
+// through the value it returns.
+type FieldSetHook func(field *Field, value string) (string, error)
+
+// FillHookFailed is the fill issue code for a value a FieldSetHook refused.
+const FillHookFailed = "hook_failed"
+
+// hookList is the hooks of one kind, in registration order.
+type hookList[H any] struct {
+	mu    sync.RWMutex
+	next  int
+	hooks []registeredHook[H]
+}
+
+type registeredHook[H any] struct {
+	id   int
+	hook H
+}
+
+func (l *hookList[H]) add(h H) (remove func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.next++
+	id := l.next
+	l.hooks = append(l.hooks, registeredHook[H]{id, h})
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.hooks = slices.DeleteFunc(l.hooks, func(r registeredHook[H]) bool { return r.id == id })
+	}
+}
+
+func (l *hookList[H]) list() []H {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]H, len(l.hooks))
+	for i, r := range l.hooks {
+		out[i] = r.hook
+	}
+	return out
+}
+
+var (
+	loadHooks     hookList[LoadHook]
+	saveHooks     hookList[SaveHook]
+	fieldSetHooks hookList[FieldSetHook]
+)
+
+// OnLoad adds h to the hooks run, in the order they were added, on each
+// annotation read by Load, LoadFile, LoadYAML, LoadFromStore, LoadPage
+// and the library loaders. StreamPages and StreamFields, which never hold
+// a whole annotation, do not run them. The returned function removes h.
+func OnLoad(h LoadHook) (remove func()) {
+	if h == nil {
+		panic("annotation: OnLoad needs a hook")
+	}
+	return loadHooks.add(h)
+}
+
+// OnSave adds h to the hooks run, in the order they were added, on a copy
+// of each annotation written by Save, SaveCanonical, SaveToFile and its
+// variants, SaveYAML and SaveToStore. ContentHash covers what the hooks
+// leave, as it covers what SaveToFileCanonical writes. The returned
+// function removes h.
+func OnSave(h SaveHook) (remove func()) {
+	if h == nil {
+		panic("annotation: OnSave needs a hook")
+	}
+	return saveHooks.add(h)
+}
+
+// OnFieldSet adds h to the hooks run, in the order they were added and
+// each on the value the last returned, when a fill such as SetValues,
+// FillFromData or ImportValues places a value, after the field's
+// transforms, and when SetAmount stores one. Calculations are not passed
+// through them. The returned function removes h.
+func OnFieldSet(h FieldSetHook) (remove func()) {
+	if h == nil {
+		panic("annotation: OnFieldSet needs a hook")
+	}
+	return fieldSetHooks.add(h)
+}
+
+// runLoadHooks applies the load hooks to a decoded annotation.
+func runLoadHooks(fa *FormAnnotation) (*FormAnnotation, error) {
+	for _, h := range loadHooks.list() {
+		if err := h(fa); err != nil {
+			return nil, fmt.Errorf("load hook: %w", err)
+		}
+	}
+	return fa, nil
+}
+
+// forSave returns the annotation as the save hooks leave it: fa itself
+// when there are none, and a changed copy otherwise.
+func (fa *FormAnnotation) forSave() (*FormAnnotation, error) {
+	hooks := saveHooks.list()
+	if len(hooks) == 0 {
+		return fa, nil
+	}
+	out := fa.Clone()
+	for _, h := range hooks {
+		if err := h(out); err != nil {
+			return nil, fmt.Errorf("save hook: %w", err)
+		}
+	}
+	return out, nil
+}
+
+// runFieldSetHooks passes a value through the field set hooks.
+func runFieldSetHooks(field *Field, value string) (string, error) {
+	for _, h := range fieldSetHooks.list() {
+		var err error
+		if value, err = h(field, value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,344 @@
+package annotation
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cssPixelsPerInch is the fixed resolution of a CSS pixel.
+const cssPixelsPerInch = 96
+
+// HTMLOptions controls RenderHTML.
+type HTMLOptions struct {
+	// Scale multiplies the page and field sizes; the default is 1, at which
+	// a page is drawn at its size in CSS pixels (96 per inch).
+	Scale float64
+	// Values prefills fields by ID, in place of their filled values.
+	Values map[string]string
+	// Stylesheet, when set, is the href of an external stylesheet the page
+	// links to instead of carrying its CSS inline. HTMLStylesheet returns
+	// the CSS to serve there.
+	Stylesheet string
+	// Target selects the render overrides applied to the fields.
+	Target RenderTarget
+	// PageImages maps page numbers to the URLs of images drawn behind the
+	// fields, such as scans or renderings of the printed page, stretched to
+	// the page box.
+	PageImages map[int]string
+	// Language picks the fields' localized labels and tooltips, with the
+	// fallbacks of LabelFor, and is declared as the page's lang.
+	Language string
+}
+
+// RenderHTML renders the annotation as a self-contained HTML page for
+// clicking through the form: each page is a box of the page's size, and
+// each field an input of its type positioned over it. Text fields are text
+// inputs, numeric and currency fields number inputs, date fields date
+// inputs, checkboxes checkboxes, choice fields selects of their options,
+// image fields file inputs for a PNG or JPEG, and signatures, and barcodes
+// encoding a template, a disabled placeholder. A segmented field is one input
+// spanning its segments, with each segment's box outlined beneath it.
+// Checkboxes sharing a group_id, other than table groups, share a name so
+// that they behave as radio buttons. Validation maps to the required,
+// pattern, min, max, minlength and maxlength attributes. Each page's
+// inputs appear in the order of their tab indexes, so that focus follows
+// it, then those without one. Virtual fields are not rendered.
+func (fa *FormAnnotation) RenderHTML(opts HTMLOptions) ([]byte, error) {
+	doc, _, err := fa.renderHTML(opts)
+	return doc, err
+}
+
+// HTMLStylesheet returns the CSS RenderHTML inlines, for serving at the
+// href of HTMLOptions.Stylesheet. It positions and styles the fields by
+// the element IDs RenderHTML gives them, so both must be rendered with the
+// same options.
+func (fa *FormAnnotation) HTMLStylesheet(opts HTMLOptions) ([]byte, error) {
+	_, css, err := fa.renderHTML(opts)
+	return css, err
+}
+
+// htmlBaseCSS styles the page boxes and inputs; field rules follow it.
+const htmlBaseCSS = `body { margin: 0; padding: 16px 0; background: #e5e5e5; font-family: sans-serif; }
+.page { position: relative; margin: 0 auto 16px; background: #ffffff; box-shadow: 0 1px 4px rgba(0, 0, 0, 0.3); }
+.field { position: absolute; box-sizing: border-box; margin: 0; padding: 0 2px; border: 1px solid #8fa8c8; background: rgba(200, 220, 255, 0.35); font: inherit; }
+.field:focus { outline: 2px solid #3366cc; }
+.field:invalid { border-color: #cc3333; }
+input.field[type=checkbox], input.field[type=radio] { padding: 0; }
+input.field.signature { border-style: dashed; background: rgba(230, 230, 230, 0.5); }
+input.field.segmented { border-color: transparent; background: transparent; }
+.segment { position: absolute; box-sizing: border-box; border: 1px solid #8fa8c8; background: rgba(200, 220, 255, 0.35); pointer-events: none; }
+`
+
+func (fa *FormAnnotation) renderHTML(opts HTMLOptions) (doc, css []byte, err error) {
+	scale := opts.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	if scale < 0 {
+		return nil, nil, fmt.Errorf("html: scale %g is negative", scale)
+	}
+	geom, err := newPageGeometry(fa.FormMetadata.PageSize, cssPixelsPerInch*scale)
+	if err != nil {
+		return nil, nil, err
+	}
+	num := func(v float64) string { return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64) }
+	// Font sizes and letter spacing are in points.
+	px := func(pt float64) string { return num(pt*geom.scale) + "px" }
+
+	var rules, body bytes.Buffer
+	rules.WriteString(htmlBaseCSS)
+	fmt.Fprintf(&rules, ".page { width: %spx; height: %spx; }\n", num(geom.width), num(geom.height))
+	now := time.Now()
+	for _, page := range fa.Pages {
+		if img, ok := opts.PageImages[page.PageNumber]; ok && img != "" {
+			fmt.Fprintf(&rules, "#page-%d { background: #ffffff url(\"%s\") no-repeat; background-size: 100%% 100%%; }\n", page.PageNumber, cssValue(img))
+		}
+		fmt.Fprintf(&body, "<div class=\"page\" id=\"page-%d\" data-page=\"%d\">\n", page.PageNumber, page.PageNumber)
+		fields := fa.targetFields(page.Fields, opts.Target)
+		for _, i := range tabSequence(fields) {
+			field := fa.absoluteField(fields[i], page.PageNumber)
+			boxes := geom.fieldBoxes(field)
+			if len(boxes) == 0 {
+				continue
+			}
+			box := boxes[0]
+			for _, b := range boxes[1:] {
+				box = box.union(b)
+			}
+			id := fmt.Sprintf("p%d-f%d", page.PageNumber, i+1)
+			segmented := field.FieldType == FieldTypeSegmented && len(boxes) > 1
+			if segmented {
+				for s, b := range boxes {
+					fmt.Fprintf(&rules, "#%s-s%d { left: %spx; top: %spx; width: %spx; height: %spx; }\n",
+						id, s+1, num(b.X), num(b.Y), num(b.W), num(b.H))
+					fmt.Fprintf(&body, "  <div class=\"segment\" id=\"%s-s%d\"></div>\n", id, s+1)
+				}
+			}
+			decl := []string{
+				"left: " + num(box.X) + "px", "top: " + num(box.Y) + "px",
+				"width: " + num(box.W) + "px", "height: " + num(box.H) + "px",
+			}
+			if s := field.Style; s != nil {
+				if s.FontFamily != "" {
+					decl = append(decl, "font-family: "+cssFontFamily(s.FontFamily))
+				}
+				if s.FontSize > 0 {
+					decl = append(decl, "font-size: "+px(s.FontSize))
+				}
+				if s.FontWeight != "" {
+					decl = append(decl, "font-weight: "+cssValue(s.FontWeight))
+				}
+				if s.TextAlign != "" {
+					decl = append(decl, "text-align: "+cssValue(s.TextAlign))
+				}
+				if s.Color != "" {
+					decl = append(decl, "color: "+cssValue(s.Color))
+				}
+				if s.LetterSpacing != 0 {
+					decl = append(decl, "letter-spacing: "+px(s.LetterSpacing))
+				}
+			}
+			fmt.Fprintf(&rules, "#%s { %s; }\n", id, strings.Join(decl, "; "))
+
+			value, ok := opts.Values[field.FieldID]
+			if !ok {
+				value = field.Value
+			}
+			attrs := [][2]string{{"id", id}, {"class", "field"}}
+			var choices strings.Builder
+			switch field.FieldType {
+			case FieldTypeCheckbox:
+				name := field.FieldID
+				typ := "checkbox"
+				if g := fa.GetGroup(field.GroupID); g != nil && g.GroupType != GroupTypeTable {
+					name, typ = g.GroupID, "radio"
+				}
+				attrs = append(attrs, [2]string{"type", typ}, [2]string{"name", name},
+					[2]string{"value", cmp.Or(field.OptionCode, field.FieldID)})
+				if isChecked(value) {
+					attrs = append(attrs, [2]string{"checked", ""})
+				}
+			case FieldTypeSignature:
+				attrs[1][1] = "field signature"
+				attrs = append(attrs, [2]string{"type", "text"}, [2]string{"name", field.FieldID},
+					[2]string{"placeholder", "Signature"}, [2]string{"disabled", ""})
+			case FieldTypeImage:
+				attrs[1][1] = "field image"
+				attrs = append(attrs, [2]string{"type", "file"}, [2]string{"name", field.FieldID},
+					[2]string{"accept", "image/png,image/jpeg"})
+			case FieldTypeBarcode:
+				attrs[1][1] = "field barcode"
+				attrs = append(attrs, [2]string{"type", "text"}, [2]string{"name", field.FieldID})
+				if field.Barcode != nil && field.Barcode.Template != "" {
+					attrs = append(attrs, [2]string{"placeholder", "Barcode"}, [2]string{"disabled", ""})
+				} else if value != "" {
+					attrs = append(attrs, [2]string{"value", value})
+				}
+			case FieldTypeNumeric, FieldTypeCurrency:
+				attrs = append(attrs, [2]string{"type", "number"}, [2]string{"name", field.FieldID})
+				step := "any"
+				if f := field.Formatting; f != nil && f.DecimalPlaces > 0 {
+					step = strconv.FormatFloat(math.Pow10(-f.DecimalPlaces), 'f', -1, 64)
+				}
+				attrs = append(attrs, [2]string{"step", step})
+				if n, ok := parseDecimal(value); ok {
+					f, _ := n.Float64()
+					attrs = append(attrs, [2]string{"value", strconv.FormatFloat(f, 'f', -1, 64)})
+				}
+			case FieldTypeDate:
+				attrs = append(attrs, [2]string{"type", "date"}, [2]string{"name", field.FieldID})
+				format := ""
+				if field.Formatting != nil {
+					format = field.Formatting.DateFormat
+				}
+				if d, err := ParseDate(value, format); err == nil {
+					attrs = append(attrs, [2]string{"value", d.String()})
+				}
+			case FieldTypeChoice:
+				attrs = append(attrs, [2]string{"name", field.FieldID})
+				choices.WriteString("    <option value=\"\"></option>\n")
+				for _, o := range field.Options {
+					selected := ""
+					if o.Value == value {
+						selected = " selected"
+					}
+					fmt.Fprintf(&choices, "    <option value=\"%s\"%s>%s</option>\n",
+						html.EscapeString(o.Value), selected, html.EscapeString(cmp.Or(o.Label, o.Value)))
+				}
+			default:
+				if segmented {
+					attrs[1][1] = "field segmented"
+				}
+				attrs = append(attrs, [2]string{"type", "text"}, [2]string{"name", field.FieldID})
+				if value != "" {
+					attrs = append(attrs, [2]string{"value", value})
+				}
+			}
+			if field.FieldType != FieldTypeSignature {
+				attrs = append(attrs, htmlValidationAttrs(field, fa.FormMetadata.Year, now)...)
+			}
+			if field.ReadOnly {
+				attrs = append(attrs, [2]string{"readonly", ""})
+			}
+			if title := cmp.Or(field.TooltipFor(opts.Language), field.LabelFor(opts.Language), field.IRSLineRef); title != "" {
+				attrs = append(attrs, [2]string{"title", title})
+			}
+			attrs = append(attrs, [2]string{"data-field-id", field.FieldID})
+
+			tag := "input"
+			if field.FieldType == FieldTypeChoice {
+				tag = "select"
+			}
+			body.WriteString("  <" + tag)
+			for _, a := range attrs {
+				if a[1] == "" {
+					// Boolean attributes are present or absent.
+					fmt.Fprintf(&body, " %s", a[0])
+				} else {
+					fmt.Fprintf(&body, " %s=\"%s\"", a[0], html.EscapeString(a[1]))
+				}
+			}
+			body.WriteString(">\n")
+			if tag == "select" {
+				body.WriteString(choices.String() + "  </select>\n")
+			}
+		}
+		body.WriteString("</div>\n")
+	}
+
+	var out bytes.Buffer
+	title := cmp.Or(fa.FormMetadata.FormName, fa.FormMetadata.FormID)
+	htmlTag := "<html>"
+	if opts.Language != "" {
+		htmlTag = fmt.Sprintf("<html lang=\"%s\">", html.EscapeString(opts.Language))
+	}
+	fmt.Fprintf(&out, "<!DOCTYPE html>\n%s\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", htmlTag, html.EscapeString(title))
+	if opts.Stylesheet != "" {
+		fmt.Fprintf(&out, "<link rel=\"stylesheet\" href=\"%s\">\n", html.EscapeString(opts.Stylesheet))
+	} else {
+		fmt.Fprintf(&out, "<style>\n%s</style>\n", rules.String())
+	}
+	fmt.Fprintf(&out, "</head>\n<body>\n<form>\n%s</form>\n</body>\n</html>\n", body.String())
+	return out.Bytes(), rules.Bytes(), nil
+}
+
+// htmlValidationAttrs maps a field's validation to HTML constraint
+// attributes. Conditional requirements and soft levels are left to the
+// form's own validation.
+func htmlValidationAttrs(field *Field, year int, now time.Time) [][2]string {
+	v := field.Validation
+	if v == nil {
+		return nil
+	}
+	var attrs [][2]string
+	if alwaysRequired(v) {
+		attrs = append(attrs, [2]string{"required", ""})
+	}
+	switch field.FieldType {
+	case FieldTypeCheckbox:
+		return attrs
+	case FieldTypeNumeric, FieldTypeCurrency:
+		if v.Min != 0 {
+			attrs = append(attrs, [2]string{"min", strconv.FormatFloat(v.Min, 'f', -1, 64)})
+		}
+		if v.Max != 0 {
+			attrs = append(attrs, [2]string{"max", strconv.FormatFloat(v.Max, 'f', -1, 64)})
+		}
+		return attrs
+	case FieldTypeDate:
+		for _, b := range []struct{ name, bound string }{{"min", v.MinDate}, {"max", v.MaxDate}} {
+			resolved, err := resolveDateBound(b.bound, year)
+			if err != nil || resolved == "" {
+				continue
+			}
+			if d, err := boundDate(resolved, now); err == nil {
+				attrs = append(attrs, [2]string{b.name, d.String()})
+			}
+		}
+		return attrs
+	}
+	if v.Pattern != "" {
+		attrs = append(attrs, [2]string{"pattern", v.Pattern})
+	}
+	if v.MinLength > 0 {
+		attrs = append(attrs, [2]string{"minlength", strconv.Itoa(v.MinLength)})
+	}
+	if v.MaxLength > 0 {
+		attrs = append(attrs, [2]string{"maxlength", strconv.Itoa(v.MaxLength)})
+	}
+	return attrs
+}
+
+// cssFontFamily quotes each family name that is not a generic family.
+func cssFontFamily(family string) string {
+	var names []string
+	for _, name := range strings.Split(cssValue(family), ",") {
+		name = strings.TrimSpace(name)
+		switch strings.ToLower(name) {
+		case "":
+			continue
+		case "serif", "sans-serif", "monospace", "cursive", "fantasy", "system-ui":
+			names = append(names, strings.ToLower(name))
+		default:
+			names = append(names, `"`+name+`"`)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// cssValue drops the characters that could end a style declaration or the
+// style element, such as a semicolon or brace.
+func cssValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`;{}<>\"'`, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
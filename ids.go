@@ -0,0 +1,370 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LookupOptions controls a single field lookup.
+type LookupOptions struct {
+	CaseInsensitive bool
+}
+
+// FindField finds a field by ID, ignoring case when opts or the annotation
+// asks for it.
+func (fa *FormAnnotation) FindField(fieldID string, opts LookupOptions) *Field {
+	fold := opts.CaseInsensitive || fa.CaseInsensitiveIDs
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			id := fa.Pages[i].Fields[j].FieldID
+			if id == fieldID || fold && foldID(id) == foldID(fieldID) {
+				return &fa.Pages[i].Fields[j]
+			}
+		}
+	}
+	return nil
+}
+
+// foldID is the comparison key for case-insensitive IDs.
+func foldID(id string) string {
+	return strings.ToLower(id)
+}
+
+// sameID compares two field IDs under the annotation's case rule.
+func (fa *FormAnnotation) sameID(a, b string) bool {
+	return a == b || fa.CaseInsensitiveIDs && foldID(a) == foldID(b)
+}
+
+// FieldIndex answers field lookups from maps built once, for callers that
+// look fields up repeatedly, such as a rendering loop. The pointers refer
+// to the annotation's own fields, so edits through them are saved. An
+// index does not follow structural edits: adding, removing or reordering
+// pages or fields, or changing an ID, group, value path or line reference,
+// needs a new one; Index keeps one up to date.
+// Under CaseInsensitiveIDs IDs are folded once per field when the index
+// is built.
+type FieldIndex struct {
+	fold    bool
+	shape   []pageShape
+	byID    map[string]fieldRef
+	byGroup map[string][]*Field
+	byPage  map[int][]*Field
+	byValue map[string][]*Field
+	byLine  map[string][]lineRefEntry
+}
+
+type lineRefEntry struct {
+	field *Field
+	ref   IRSLineRef
+}
+
+type fieldRef struct {
+	field *Field
+	page  int
+}
+
+// pageShape is where a page's fields were when an index was built, so that
+// Index notices fields added, removed or reallocated behind its back.
+type pageShape struct {
+	number int
+	first  *Field
+	n      int
+}
+
+// BuildIndex indexes the annotation's fields; when IDs repeat, the first
+// field wins.
+func (fa *FormAnnotation) BuildIndex() *FieldIndex {
+	ix := &FieldIndex{
+		fold:    fa.CaseInsensitiveIDs,
+		byID:    map[string]fieldRef{},
+		byGroup: map[string][]*Field{},
+		byPage:  map[int][]*Field{},
+		byValue: map[string][]*Field{},
+		byLine:  map[string][]lineRefEntry{},
+	}
+	for i := range fa.Pages {
+		page := fa.Pages[i].PageNumber
+		ix.shape = append(ix.shape, shapeOf(&fa.Pages[i]))
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			key := ix.key(field.FieldID)
+			if _, ok := ix.byID[key]; !ok {
+				ix.byID[key] = fieldRef{field, page}
+			}
+			ix.byPage[page] = append(ix.byPage[page], field)
+			if field.GroupID != "" {
+				ix.byGroup[field.GroupID] = append(ix.byGroup[field.GroupID], field)
+			}
+			if field.FieldValue != "" {
+				ix.byValue[field.FieldValue] = append(ix.byValue[field.FieldValue], field)
+			}
+			if ref, err := ParseIRSLineRef(field.IRSLineRef); err == nil {
+				key := lineRefKey(ref)
+				ix.byLine[key] = append(ix.byLine[key], lineRefEntry{field, ref})
+			}
+		}
+	}
+	return ix
+}
+
+// Index returns an index of the annotation's fields, built on first use and
+// kept until the annotation changes. The annotation's methods that add,
+// remove, reorder or rename fields, change their groups or line
+// references, or set values discard it, as do fields added or removed by
+// editing a page directly; other direct edits to IDs, groups, value paths
+// or line references need InvalidateIndex. Unlike BuildIndex it is not
+// safe for concurrent use: goroutines sharing an annotation should share
+// one BuildIndex.
+func (fa *FormAnnotation) Index() *FieldIndex {
+	if fa.index == nil || !fa.index.fits(fa) {
+		fa.index = fa.BuildIndex()
+	}
+	return fa.index
+}
+
+// InvalidateIndex discards the index Index returns, after fields were
+// edited directly in a way it would not notice.
+func (fa *FormAnnotation) InvalidateIndex() {
+	fa.index = nil
+}
+
+func shapeOf(page *Page) pageShape {
+	if len(page.Fields) == 0 {
+		return pageShape{number: page.PageNumber}
+	}
+	return pageShape{page.PageNumber, &page.Fields[0], len(page.Fields)}
+}
+
+// fits reports whether fa's pages hold their fields where they did when
+// the index was built, and as many of them.
+func (ix *FieldIndex) fits(fa *FormAnnotation) bool {
+	if ix.fold != fa.CaseInsensitiveIDs || len(ix.shape) != len(fa.Pages) {
+		return false
+	}
+	for i := range fa.Pages {
+		if shapeOf(&fa.Pages[i]) != ix.shape[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ByID returns the field with an ID, or nil.
+func (ix *FieldIndex) ByID(id string) *Field {
+	field, _ := ix.lookup(id)
+	return field
+}
+
+// ByGroupID returns the fields naming a group, in page order.
+func (ix *FieldIndex) ByGroupID(groupID string) []*Field { return ix.byGroup[groupID] }
+
+// ByPage returns the fields on a page.
+func (ix *FieldIndex) ByPage(pageNum int) []*Field { return ix.byPage[pageNum] }
+
+// ByFieldValue returns the fields bound to a value path, in page order.
+func (ix *FieldIndex) ByFieldValue(path string) []*Field { return ix.byValue[path] }
+
+// ByLineRef returns the fields whose IRS line reference names the same line
+// as ref, in page order, matching as GetFieldsByLineRef does.
+func (ix *FieldIndex) ByLineRef(ref string) []*Field {
+	want, err := ParseIRSLineRef(ref)
+	if err != nil {
+		return nil
+	}
+	var fields []*Field
+	for _, e := range ix.byLine[lineRefKey(want)] {
+		if want.sameLine(e.ref) {
+			fields = append(fields, e.field)
+		}
+	}
+	return fields
+}
+
+// lineRefKey buckets references that may name the same line; sameLine
+// decides within a bucket.
+func lineRefKey(r IRSLineRef) string {
+	if !r.Structured() {
+		return "?" + strings.ToLower(r.Unstructured)
+	}
+	return fmt.Sprintf("%t %d%s", r.Box, r.Line, r.Sub)
+}
+
+func (ix *FieldIndex) key(id string) string {
+	if ix.fold {
+		return foldID(id)
+	}
+	return id
+}
+
+func (ix *FieldIndex) lookup(id string) (*Field, int) {
+	ref, ok := ix.byID[ix.key(id)]
+	if !ok {
+		return nil, 0
+	}
+	return ref.field, ref.page
+}
+
+// resolveIDs maps references to the IDs of the fields they resolve to,
+// keeping unresolved references as written.
+func (fa *FormAnnotation) resolveIDs(ids []string) []string {
+	if !fa.CaseInsensitiveIDs {
+		return ids
+	}
+	ix := fa.BuildIndex()
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id
+		if field, _ := ix.lookup(id); field != nil {
+			out[i] = field.FieldID
+		}
+	}
+	return out
+}
+
+// RenameField changes a field's ID and updates every reference to it: group
+// membership, conditional requirements, related help, signature dates,
+// barcode templates and the PDF name mapping.
+func (fa *FormAnnotation) RenameField(oldID, newID string) error {
+	if newID == "" {
+		return fmt.Errorf("cannot rename field %q to an empty ID", oldID)
+	}
+	field := fa.GetFieldByID(oldID)
+	if field == nil {
+		return fmt.Errorf("no field with ID %q", oldID)
+	}
+	if other := fa.GetFieldByID(newID); other != nil && other != field {
+		return fmt.Errorf("cannot rename field %q: ID %q is already in use", oldID, newID)
+	}
+	old := field.FieldID
+	fa.renameField(field, newID, func(ref string) bool { return fa.sameID(ref, old) })
+	return nil
+}
+
+// renameField sets field's ID and rewrites every reference for which matches
+// reports true. Expressions that no longer parse are left as they are.
+func (fa *FormAnnotation) renameField(field *Field, newID string, matches func(ref string) bool) {
+	fa.InvalidateIndex()
+	field.FieldID = newID
+	for i := range fa.FieldGroups {
+		for j, id := range fa.FieldGroups[i].FieldIDs {
+			if matches(id) {
+				fa.FieldGroups[i].FieldIDs[j] = newID
+			}
+		}
+		if r := fa.FieldGroups[i].Repeat; r != nil {
+			for j, id := range r.TemplateFieldIDs {
+				if matches(id) {
+					r.TemplateFieldIDs[j] = newID
+				}
+			}
+		}
+		if v := fa.FieldGroups[i].Validation; v != nil && v.SumEquals != "" && matches(v.SumEquals) {
+			v.SumEquals = newID
+		}
+	}
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			if h := fa.Pages[i].Fields[j].Help; h != nil {
+				for k, id := range h.RelatedFieldIDs {
+					if matches(id) {
+						h.RelatedFieldIDs[k] = newID
+					}
+				}
+			}
+			if rel := fa.Pages[i].Fields[j].RelativeTo; rel != nil && rel.FieldID != "" && matches(rel.FieldID) {
+				rel.FieldID = newID
+			}
+			if s := fa.Pages[i].Fields[j].Signature; s != nil && s.DateField != "" && matches(s.DateField) {
+				s.DateField = newID
+			}
+			if b := fa.Pages[i].Fields[j].Barcode; b != nil && b.Template != "" {
+				b.Template = rewriteTemplateRefs(b.Template, func(id string) string {
+					if matches(id) {
+						return newID
+					}
+					return id
+				})
+			}
+			if v := fa.Pages[i].Fields[j].Validation; v != nil {
+				renameExprRefs(&v.RequiredIf, matches, newID)
+			}
+			if c := fa.Pages[i].Fields[j].Calculation; c != nil {
+				renameExprRefs(&c.Expr, matches, newID)
+			}
+			if c := fa.Pages[i].Fields[j].Conditions; c != nil {
+				renameExprRefs(&c.VisibleIf, matches, newID)
+				renameExprRefs(&c.DisabledIf, matches, newID)
+			}
+		}
+	}
+	if m := fa.FormMetadata.NameMapping; m != nil {
+		for id, name := range m.Pairs {
+			if matches(id) && id != newID {
+				delete(m.Pairs, id)
+				m.Pairs[newID] = name
+			}
+		}
+	}
+}
+
+// renameExprRefs rewrites the references matches accepts in the expression
+// *src to newID, leaving an empty or unparsable expression as it is.
+func renameExprRefs(src *string, matches func(string) bool, newID string) {
+	if *src == "" {
+		return
+	}
+	expr, err := ParseExpr(*src)
+	if err != nil {
+		return
+	}
+	renamed, err := expr.RenameRefs(func(id string) string {
+		if matches(id) {
+			return newID
+		}
+		return id
+	})
+	if err == nil {
+		*src = renamed.String()
+	}
+}
+
+// IDCaseStrategy selects the casing NormalizeFieldIDCase applies.
+type IDCaseStrategy int
+
+const (
+	IDCaseLower IDCaseStrategy = iota
+	IDCaseUpper
+	// IDCasePreserveFirstSeen keeps each field's ID and rewrites references
+	// that differ from it only by case.
+	IDCasePreserveFirstSeen
+)
+
+// NormalizeFieldIDCase recases every field ID by strategy and rewrites all
+// references that match an ID case-insensitively, so that lookups succeed
+// without CaseInsensitiveIDs. Fields whose IDs differ only by case cannot be
+// normalized and are reported before anything is changed.
+func (fa *FormAnnotation) NormalizeFieldIDCase(strategy IDCaseStrategy) error {
+	seen := map[string]string{}
+	for _, field := range fa.Fields() {
+		key := foldID(field.FieldID)
+		if prev, ok := seen[key]; ok {
+			return fmt.Errorf("fields %q and %q differ only by case", prev, field.FieldID)
+		}
+		seen[key] = field.FieldID
+	}
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			target := field.FieldID
+			switch strategy {
+			case IDCaseLower:
+				target = strings.ToLower(target)
+			case IDCaseUpper:
+				target = strings.ToUpper(target)
+			}
+			key := foldID(field.FieldID)
+			fa.renameField(field, target, func(ref string) bool { return foldID(ref) == key })
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package annotation
+
+import "testing"
+
+func TestIndexCached(t *testing.T) {
+	fa := denseForm(3, 2)
+	ix := fa.Index()
+	if fa.Index() != ix {
+		t.Fatal("an unchanged annotation rebuilt its index")
+	}
+	if ix.ByID("r1_c2") != fa.GetFieldByID("r1_c2") {
+		t.Fatal("ByID does not return the annotation's own field")
+	}
+	tests := []struct {
+		name   string
+		mutate func() error
+		found  string
+		gone   string
+	}{
+		{"UpdateField", func() error {
+			return fa.UpdateField("r0_c0", func(f *Field) error { f.FieldID = "first"; return nil })
+		}, "first", "r0_c0"},
+		{"SetFieldValue", func() error { return fa.SetFieldValue("r0_c1", "x") }, "r0_c1", ""},
+		{"SetValues", func() error { fa.SetValues(map[string]string{"r0_c2": "y"}, FillOptions{}); return nil }, "r0_c2", ""},
+		{"RenameField", func() error { return fa.RenameField("r1_c0", "renamed") }, "renamed", "r1_c0"},
+		{"AddField", func() error {
+			return fa.AddField(1, Field{FieldID: "added", FieldType: FieldTypeText, DataType: DataTypeString})
+		}, "added", ""},
+		{"RemoveField", func() error { return fa.RemoveField("added") }, "", "added"},
+		{"direct append", func() error {
+			fa.Pages[0].Fields = append(fa.Pages[0].Fields, Field{FieldID: "appended"})
+			return nil
+		}, "appended", ""},
+		{"InvalidateIndex", func() error {
+			fa.Pages[0].Fields[1].FieldID = "edited"
+			fa.InvalidateIndex()
+			return nil
+		}, "edited", "r0_c1"},
+	}
+	for _, tt := range tests {
+		before := fa.Index()
+		if err := tt.mutate(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		after := fa.Index()
+		if after == before {
+			t.Errorf("%s kept the old index", tt.name)
+		}
+		if tt.found != "" && after.ByID(tt.found) != fa.GetFieldByID(tt.found) {
+			t.Errorf("%s: index has no %s", tt.name, tt.found)
+		}
+		if tt.gone != "" && after.ByID(tt.gone) != nil {
+			t.Errorf("%s: index still has %s", tt.name, tt.gone)
+		}
+	}
+	if c := fa.Clone(); c.index != nil {
+		t.Error("Clone copied the index")
+	}
+}
+
+// denseIDs returns the field IDs of fa in page order, for lookups spread
+// over the whole page.
+func denseIDs(fa *FormAnnotation) []string {
+	var ids []string
+	for field := range fa.FieldsSeq() {
+		ids = append(ids, field.FieldID)
+	}
+	return ids
+}
+
+// BenchmarkFieldIndexByID measures a lookup against a prebuilt index of a
+// 1,000-field page, as a rendering loop makes for every field it draws.
+func BenchmarkFieldIndexByID(b *testing.B) {
+	fa := denseForm(20, 50)
+	ids := denseIDs(fa)
+	ix := fa.BuildIndex()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ix.ByID(ids[i%len(ids)]) == nil {
+			b.Fatal("field not found")
+		}
+	}
+}
+
+// BenchmarkGetFieldByID measures the same lookups without an index, which
+// scan the page each time.
+func BenchmarkGetFieldByID(b *testing.B) {
+	fa := denseForm(20, 50)
+	ids := denseIDs(fa)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if fa.GetFieldByID(ids[i%len(ids)]) == nil {
+			b.Fatal("field not found")
+		}
+	}
+}
+
+func BenchmarkBuildIndex(b *testing.B) {
+	fa := denseForm(20, 50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fa.BuildIndex()
+	}
+}
@@ -0,0 +1,121 @@
+package pdf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Page is a page of a PDF with the attributes it inherits from the page
+// tree.
+type Page struct {
+	Ref       Ref
+	Dict      Dict
+	Resources any
+	// MediaBox is normalized so that its first corner is the lower left.
+	MediaBox [4]float64
+	Rotate   int64
+}
+
+// Trailer returns the newest trailer dictionary.
+func (f *File) Trailer() Dict { return f.trailer }
+
+// Catalog returns the document catalog.
+func (f *File) Catalog() (Dict, error) {
+	root, err := f.Resolve(f.trailer["Root"])
+	if err != nil {
+		return nil, err
+	}
+	catalog, ok := root.(Dict)
+	if !ok {
+		return nil, errors.New("no document catalog")
+	}
+	return catalog, nil
+}
+
+// Pages walks the page tree in order.
+func (f *File) Pages() ([]Page, error) {
+	catalog, err := f.Catalog()
+	if err != nil {
+		return nil, err
+	}
+	pagesRef, ok := catalog["Pages"].(Ref)
+	if !ok {
+		return nil, errors.New("no page tree")
+	}
+	var pages []Page
+	seen := map[int]bool{}
+	var walk func(ref Ref, inherited Page) error
+	walk = func(ref Ref, inherited Page) error {
+		if seen[ref.Num] {
+			return fmt.Errorf("page tree loops at object %d", ref.Num)
+		}
+		seen[ref.Num] = true
+		obj, err := f.Resolve(ref)
+		if err != nil {
+			return err
+		}
+		node, ok := obj.(Dict)
+		if !ok {
+			return fmt.Errorf("page tree node %d is not a dictionary", ref.Num)
+		}
+		if r, ok := node["Resources"]; ok {
+			inherited.Resources = r
+		}
+		if mb, err := f.Resolve(node["MediaBox"]); err == nil {
+			if box, ok := Rect(mb); ok {
+				inherited.MediaBox = box
+			}
+		}
+		if rot, ok := node["Rotate"].(int64); ok {
+			inherited.Rotate = rot
+		}
+		if node["Type"] == Name("Page") || node["Kids"] == nil {
+			inherited.Ref, inherited.Dict = ref, node
+			pages = append(pages, inherited)
+			return nil
+		}
+		kids, err := f.Resolve(node["Kids"])
+		if err != nil {
+			return err
+		}
+		list, _ := kids.(Array)
+		for _, kid := range list {
+			r, ok := kid.(Ref)
+			if !ok {
+				return fmt.Errorf("page tree node %d has a direct kid", ref.Num)
+			}
+			if err := walk(r, inherited); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	// US Letter, should no node give a media box.
+	if err := walk(pagesRef, Page{MediaBox: [4]float64{0, 0, 612, 792}}); err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// Rect reads a rectangle, normalized so that its first corner is the lower
+// left.
+func Rect(v any) ([4]float64, bool) {
+	arr, ok := v.(Array)
+	if !ok || len(arr) != 4 {
+		return [4]float64{}, false
+	}
+	var r [4]float64
+	for i, n := range arr {
+		switch n := n.(type) {
+		case int64:
+			r[i] = float64(n)
+		case float64:
+			r[i] = n
+		default:
+			return [4]float64{}, false
+		}
+	}
+	r[0], r[2] = min(r[0], r[2]), max(r[0], r[2])
+	r[1], r[3] = min(r[1], r[3]), max(r[1], r[3])
+	return r, true
+}
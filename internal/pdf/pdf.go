@@ -0,0 +1,810 @@
+// Package pdf reads PDF files and appends incremental updates to them:
+// enough of the format to walk pages and form fields and to add content
+// without rewriting what is there.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// The PDF object model: nil, bool, int64, float64, Name, String, Array,
+// Dict, Ref and *Stream.
+type (
+	Name   string
+	String []byte
+	Array  []any
+	Dict   map[Name]any
+	Ref    struct{ Num, Gen int }
+	Stream struct {
+		Dict Dict
+		Data []byte // as stored, still encoded
+	}
+)
+
+// xrefEntry locates an object: at an offset in the file, or as the index-th
+// object of an object stream.
+type xrefEntry struct {
+	inStream bool
+	offset   int64
+	gen      int
+	stream   int
+	index    int
+}
+
+// File is a parsed PDF. Objects are read on demand.
+type File struct {
+	data      []byte
+	xref      map[int]xrefEntry
+	trailer   Dict
+	startxref int64
+	// xrefStream reports that the newest cross-reference section is a
+	// stream, so that an update must be one too.
+	xrefStream bool
+	objects    map[int]any
+	loading    map[int]bool
+}
+
+// Parse reads the cross-reference sections of a PDF, newest first.
+// Encrypted files are refused.
+func Parse(data []byte) (*File, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-")) {
+		return nil, errors.New("not a PDF file")
+	}
+	tail := data[max(0, len(data)-1024):]
+	i := bytes.LastIndex(tail, []byte("startxref"))
+	if i < 0 {
+		return nil, errors.New("no startxref")
+	}
+	p := &parser{data: tail, pos: i + len("startxref")}
+	start, err := p.object()
+	if err != nil {
+		return nil, fmt.Errorf("startxref: %w", err)
+	}
+	offset, ok := start.(int64)
+	if !ok {
+		return nil, errors.New("startxref is not an offset")
+	}
+	f := &File{data: data, xref: map[int]xrefEntry{}, startxref: offset, objects: map[int]any{}, loading: map[int]bool{}}
+	seen := map[int64]bool{}
+	for next, first := offset, true; ; first = false {
+		if seen[next] {
+			return nil, fmt.Errorf("cross-reference loop at offset %d", next)
+		}
+		seen[next] = true
+		trailer, isStream, err := f.readXref(next)
+		if err != nil {
+			return nil, err
+		}
+		if first {
+			f.trailer, f.xrefStream = trailer, isStream
+		}
+		if hybrid, ok := trailer["XRefStm"].(int64); ok {
+			if _, _, err := f.readXref(hybrid); err != nil {
+				return nil, err
+			}
+		}
+		prev, ok := trailer["Prev"].(int64)
+		if !ok {
+			break
+		}
+		next = prev
+	}
+	if _, ok := f.trailer["Encrypt"]; ok {
+		return nil, errors.New("encrypted PDFs are not supported")
+	}
+	return f, nil
+}
+
+// readXref reads the cross-reference section at offset, keeping entries a
+// newer section already set, and returns its trailer.
+func (f *File) readXref(offset int64) (Dict, bool, error) {
+	if offset < 0 || offset >= int64(len(f.data)) {
+		return nil, false, fmt.Errorf("cross-reference offset %d is outside the file", offset)
+	}
+	p := &parser{data: f.data, pos: int(offset)}
+	p.skipSpace()
+	if !bytes.HasPrefix(f.data[p.pos:], []byte("xref")) {
+		trailer, err := f.readXrefStream(p)
+		return trailer, true, err
+	}
+	p.pos += len("xref")
+	for {
+		p.skipSpace()
+		if bytes.HasPrefix(f.data[p.pos:], []byte("trailer")) {
+			p.pos += len("trailer")
+			break
+		}
+		startObj, err1 := p.object()
+		countObj, err2 := p.object()
+		start, ok1 := startObj.(int64)
+		count, ok2 := countObj.(int64)
+		if err1 != nil || err2 != nil || !ok1 || !ok2 {
+			return nil, false, fmt.Errorf("malformed cross-reference table at offset %d", offset)
+		}
+		for n := start; n < start+count; n++ {
+			off, err1 := p.object()
+			gen, err2 := p.object()
+			kind := p.keyword()
+			o, ok1 := off.(int64)
+			g, ok2 := gen.(int64)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 || (kind != "n" && kind != "f") {
+				return nil, false, fmt.Errorf("malformed cross-reference entry for object %d", n)
+			}
+			if _, ok := f.xref[int(n)]; !ok && kind == "n" {
+				f.xref[int(n)] = xrefEntry{offset: o, gen: int(g)}
+			} else if !ok {
+				f.xref[int(n)] = xrefEntry{offset: -1}
+			}
+		}
+	}
+	obj, err := p.object()
+	if err != nil {
+		return nil, false, fmt.Errorf("trailer: %w", err)
+	}
+	trailer, ok := obj.(Dict)
+	if !ok {
+		return nil, false, errors.New("trailer is not a dictionary")
+	}
+	return trailer, false, nil
+}
+
+func (f *File) readXrefStream(p *parser) (Dict, error) {
+	_, obj, err := p.indirect(f)
+	if err != nil {
+		return nil, fmt.Errorf("cross-reference stream: %w", err)
+	}
+	s, ok := obj.(*Stream)
+	if !ok || s.Dict["Type"] != Name("XRef") {
+		return nil, errors.New("startxref does not point to a cross-reference section")
+	}
+	data, err := f.Decode(s)
+	if err != nil {
+		return nil, fmt.Errorf("cross-reference stream: %w", err)
+	}
+	w, _ := s.Dict["W"].(Array)
+	if len(w) != 3 {
+		return nil, errors.New("cross-reference stream has no /W")
+	}
+	var widths [3]int
+	for i, v := range w {
+		n, ok := v.(int64)
+		if !ok || n < 0 || n > 8 {
+			return nil, errors.New("cross-reference stream has a bad /W")
+		}
+		widths[i] = int(n)
+	}
+	size, _ := s.Dict["Size"].(int64)
+	index := Array{int64(0), size}
+	if ix, ok := s.Dict["Index"].(Array); ok {
+		index = ix
+	}
+	row := widths[0] + widths[1] + widths[2]
+	field := func(b []byte) int64 {
+		var v int64
+		for _, c := range b {
+			v = v<<8 | int64(c)
+		}
+		return v
+	}
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := index[i].(int64)
+		count, _ := index[i+1].(int64)
+		for n := start; n < start+count; n++ {
+			if len(data) < row {
+				return nil, errors.New("cross-reference stream is truncated")
+			}
+			kind := int64(1)
+			if widths[0] > 0 {
+				kind = field(data[:widths[0]])
+			}
+			a := field(data[widths[0] : widths[0]+widths[1]])
+			b := field(data[widths[0]+widths[1] : row])
+			data = data[row:]
+			if _, ok := f.xref[int(n)]; ok {
+				continue
+			}
+			switch kind {
+			case 1:
+				f.xref[int(n)] = xrefEntry{offset: a, gen: int(b)}
+			case 2:
+				f.xref[int(n)] = xrefEntry{inStream: true, stream: int(a), index: int(b)}
+			default:
+				f.xref[int(n)] = xrefEntry{offset: -1}
+			}
+		}
+	}
+	return s.Dict, nil
+}
+
+// Resolve follows a reference to its object; other values are returned as
+// they are. A missing object is null.
+func (f *File) Resolve(v any) (any, error) {
+	r, ok := v.(Ref)
+	if !ok {
+		return v, nil
+	}
+	if obj, ok := f.objects[r.Num]; ok {
+		return obj, nil
+	}
+	if f.loading[r.Num] {
+		return nil, fmt.Errorf("object %d refers to itself", r.Num)
+	}
+	f.loading[r.Num] = true
+	defer delete(f.loading, r.Num)
+	e, ok := f.xref[r.Num]
+	if !ok || (!e.inStream && e.offset < 0) {
+		return nil, nil
+	}
+	var obj any
+	var err error
+	if e.inStream {
+		obj, err = f.streamObject(e)
+	} else {
+		if e.offset >= int64(len(f.data)) {
+			return nil, fmt.Errorf("object %d: offset %d is outside the file", r.Num, e.offset)
+		}
+		var num int
+		num, obj, err = (&parser{data: f.data, pos: int(e.offset)}).indirect(f)
+		if err == nil && num != r.Num {
+			err = fmt.Errorf("offset %d holds object %d", e.offset, num)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("object %d: %w", r.Num, err)
+	}
+	f.objects[r.Num] = obj
+	return obj, nil
+}
+
+// streamObject reads an object stored in an object stream.
+func (f *File) streamObject(e xrefEntry) (any, error) {
+	obj, err := f.Resolve(Ref{Num: e.stream})
+	if err != nil {
+		return nil, err
+	}
+	s, ok := obj.(*Stream)
+	if !ok {
+		return nil, fmt.Errorf("object stream %d is not a stream", e.stream)
+	}
+	data, err := f.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	n, _ := s.Dict["N"].(int64)
+	first, _ := s.Dict["First"].(int64)
+	if int64(e.index) >= n || first > int64(len(data)) {
+		return nil, fmt.Errorf("object stream %d has no object %d", e.stream, e.index)
+	}
+	header := &parser{data: data[:first]}
+	var offset int64
+	for i := 0; i <= e.index; i++ {
+		header.object()
+		o, err := header.object()
+		if err != nil {
+			return nil, fmt.Errorf("object stream %d: %w", e.stream, err)
+		}
+		offset, _ = o.(int64)
+	}
+	if first+offset > int64(len(data)) {
+		return nil, fmt.Errorf("object stream %d is truncated", e.stream)
+	}
+	return (&parser{data: data, pos: int(first + offset)}).object()
+}
+
+// Decode returns a stream's data with its filters undone. Only Flate, with
+// or without a PNG predictor, is supported; it is what cross-reference and
+// object streams use.
+func (f *File) Decode(s *Stream) ([]byte, error) {
+	filters, _ := f.Resolve(s.Dict["Filter"])
+	parms, _ := f.Resolve(s.Dict["DecodeParms"])
+	var names []any
+	var params []any
+	switch v := filters.(type) {
+	case nil:
+		return s.Data, nil
+	case Name:
+		names, params = []any{v}, []any{parms}
+	case Array:
+		names = v
+		params, _ = parms.(Array)
+	}
+	data := s.Data
+	for i, name := range names {
+		if name != Name("FlateDecode") {
+			return nil, fmt.Errorf("unsupported stream filter %v", name)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(zr); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, err
+		}
+		var p Dict
+		if i < len(params) {
+			v, _ := f.Resolve(params[i])
+			p, _ = v.(Dict)
+		}
+		if data, err = unpredict(data, p); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// unpredict reverses a PNG predictor.
+func unpredict(data []byte, parms Dict) ([]byte, error) {
+	predictor, _ := parms["Predictor"].(int64)
+	if predictor < 10 {
+		if predictor > 1 {
+			return nil, fmt.Errorf("unsupported predictor %d", predictor)
+		}
+		return data, nil
+	}
+	columns, colors, bits := int64(1), int64(1), int64(8)
+	if v, ok := parms["Columns"].(int64); ok {
+		columns = v
+	}
+	if v, ok := parms["Colors"].(int64); ok {
+		colors = v
+	}
+	if v, ok := parms["BitsPerComponent"].(int64); ok {
+		bits = v
+	}
+	bpp := int(max((colors*bits+7)/8, 1))
+	rowLen := int((columns*colors*bits + 7) / 8)
+	var out []byte
+	prev := make([]byte, rowLen)
+	for len(data) > 0 {
+		if len(data) < rowLen+1 {
+			return nil, errors.New("predicted data is truncated")
+		}
+		filter, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+		for i := range row {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 0:
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			default:
+				return nil, fmt.Errorf("unknown PNG filter %d", filter)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// parser reads PDF objects from data.
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == 0
+}
+
+func isDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case isSpace(c):
+			p.pos++
+		case c == '%':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// keyword reads a run of regular characters.
+func (p *parser) keyword() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// indirect reads "num gen obj ... endobj", with a stream resolved against f
+// when its length is a reference.
+func (p *parser) indirect(f *File) (int, any, error) {
+	num, err1 := p.object()
+	_, err2 := p.object()
+	if err1 != nil || err2 != nil || p.keyword() != "obj" {
+		return 0, nil, errors.New("malformed indirect object")
+	}
+	n, ok := num.(int64)
+	if !ok {
+		return 0, nil, errors.New("malformed indirect object")
+	}
+	obj, err := p.object()
+	if err != nil {
+		return 0, nil, err
+	}
+	d, ok := obj.(Dict)
+	if !ok {
+		return int(n), obj, nil
+	}
+	save := p.pos
+	if p.keyword() != "stream" {
+		p.pos = save
+		return int(n), obj, nil
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\r' {
+		p.pos++
+	}
+	if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+		p.pos++
+	}
+	start := p.pos
+	length := int64(-1)
+	if l, err := f.Resolve(d["Length"]); err == nil {
+		if v, ok := l.(int64); ok {
+			length = v
+		}
+	}
+	end := start + int(length)
+	if length < 0 || end > len(p.data) || !bytes.HasPrefix(bytes.TrimLeft(p.data[end:], "\r\n "), []byte("endstream")) {
+		// Fall back to the endstream keyword when /Length is wrong.
+		i := bytes.Index(p.data[start:], []byte("endstream"))
+		if i < 0 {
+			return 0, nil, errors.New("stream has no endstream")
+		}
+		end = start + i
+		for end > start && (p.data[end-1] == '\n' || p.data[end-1] == '\r') {
+			end--
+		}
+	}
+	p.pos = end
+	p.keyword()
+	return int(n), &Stream{Dict: d, Data: p.data[start:end]}, nil
+}
+
+// object reads one direct object, or a reference.
+func (p *parser) object() (any, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c := p.data[p.pos]; {
+	case c == '/':
+		p.pos++
+		start := p.pos
+		for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !isDelimiter(p.data[p.pos]) {
+			p.pos++
+		}
+		return Name(unescapeName(p.data[start:p.pos])), nil
+	case c == '(':
+		return p.literalString()
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		d := Dict{}
+		for {
+			p.skipSpace()
+			if bytes.HasPrefix(p.data[p.pos:], []byte(">>")) {
+				p.pos += 2
+				return d, nil
+			}
+			key, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := key.(Name)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key %v is not a name", key)
+			}
+			v, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			d[k] = v
+		}
+	case c == '<':
+		p.pos++
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, errors.New("unterminated hex string")
+		}
+		var digits []byte
+		for _, d := range p.data[p.pos : p.pos+end] {
+			if !isSpace(d) {
+				digits = append(digits, d)
+			}
+		}
+		p.pos += end + 1
+		if len(digits)%2 == 1 {
+			digits = append(digits, '0')
+		}
+		out := make([]byte, len(digits)/2)
+		for i := range out {
+			v, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+			if err != nil {
+				return nil, fmt.Errorf("bad hex string: %w", err)
+			}
+			out[i] = byte(v)
+		}
+		return String(out), nil
+	case c == '[':
+		p.pos++
+		arr := Array{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			v, err := p.object()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		tok := p.keyword()
+		if n, err := strconv.ParseInt(tok, 10, 64); err == nil {
+			// A non-negative integer may start "num gen R".
+			save := p.pos
+			if gen, err := strconv.ParseInt(p.keyword(), 10, 64); err == nil && n >= 0 {
+				if p.keyword() == "R" {
+					return Ref{Num: int(n), Gen: int(gen)}, nil
+				}
+			}
+			p.pos = save
+			return n, nil
+		}
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", tok)
+		}
+		return v, nil
+	}
+	switch tok := p.keyword(); tok {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.data[p.pos], p.pos)
+	default:
+		return nil, fmt.Errorf("unexpected keyword %q", tok)
+	}
+}
+
+func (p *parser) literalString() (any, error) {
+	p.pos++
+	var out []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return String(out), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				break
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil, errors.New("unterminated string")
+}
+
+func unescapeName(b []byte) string {
+	if bytes.IndexByte(b, '#') < 0 {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
+
+// WriteObject serializes v, with dictionary keys in sorted order.
+func WriteObject(b *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case int64:
+		b.WriteString(strconv.FormatInt(v, 10))
+	case int:
+		b.WriteString(strconv.Itoa(v))
+	case float64:
+		b.WriteString(FormatNumber(v))
+	case Name:
+		b.WriteByte('/')
+		for i := 0; i < len(v); i++ {
+			if c := v[i]; c < '!' || c > '~' || c == '#' || isDelimiter(c) {
+				fmt.Fprintf(b, "#%02X", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	case String:
+		fmt.Fprintf(b, "<%X>", []byte(v))
+	case Array:
+		b.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			WriteObject(b, item)
+		}
+		b.WriteByte(']')
+	case Dict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, string(k))
+		}
+		sort.Strings(keys)
+		b.WriteString("<<")
+		for _, k := range keys {
+			WriteObject(b, Name(k))
+			b.WriteByte(' ')
+			WriteObject(b, v[Name(k)])
+		}
+		b.WriteString(">>")
+	case Ref:
+		fmt.Fprintf(b, "%d %d R", v.Num, v.Gen)
+	case *Stream:
+		d := Dict{}
+		for k, val := range v.Dict {
+			d[k] = val
+		}
+		d["Length"] = int64(len(v.Data))
+		WriteObject(b, d)
+		b.WriteString("\nstream\n")
+		b.Write(v.Data)
+		b.WriteString("\nendstream")
+	}
+}
+
+// FormatNumber writes a real without exponent, to 4 decimal places.
+func FormatNumber(v float64) string {
+	s := strings.TrimSuffix(strings.TrimRight(strconv.FormatFloat(v, 'f', 4, 64), "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// Text decodes a text string: UTF-16BE or UTF-8 after a byte order mark,
+// and PDFDocEncoding otherwise, read as Latin-1, which it matches outside
+// a few punctuation marks.
+func (s String) Text() string {
+	switch {
+	case bytes.HasPrefix(s, []byte{0xFE, 0xFF}):
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(s, []byte{0xEF, 0xBB, 0xBF}):
+		return string(s[3:])
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// TextString encodes s as a text string: in PDFDocEncoding when it is
+// printable Latin-1, and in UTF-16BE otherwise.
+func TextString(s string) String {
+	latin := true
+	for _, r := range s {
+		if r > 0xFF || r >= 0x7F && r < 0xA1 || r == 0xAD {
+			latin = false
+			break
+		}
+	}
+	if latin {
+		out := make(String, 0, len(s))
+		for _, r := range s {
+			out = append(out, byte(r))
+		}
+		return out
+	}
+	out := String{0xFE, 0xFF}
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
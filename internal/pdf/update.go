@@ -0,0 +1,129 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Update is an incremental update of a PDF: objects added or replaced,
+// appended after the original bytes with a cross-reference section that
+// chains to the original's.
+type Update struct {
+	f       *File
+	next    int
+	objects map[int]any
+	gens    map[int]int
+}
+
+// NewUpdate starts an update of f.
+func NewUpdate(f *File) *Update {
+	next := 0
+	if size, ok := f.trailer["Size"].(int64); ok {
+		next = int(size)
+	}
+	for n := range f.xref {
+		next = max(next, n+1)
+	}
+	return &Update{f: f, next: next, objects: map[int]any{}, gens: map[int]int{}}
+}
+
+// Add adds an object and returns its reference.
+func (u *Update) Add(v any) Ref {
+	r := Ref{Num: u.next}
+	u.next++
+	u.objects[r.Num] = v
+	return r
+}
+
+// Replace replaces the object r refers to with v.
+func (u *Update) Replace(r Ref, v any) {
+	u.objects[r.Num] = v
+	u.gens[r.Num] = r.Gen
+}
+
+// Write writes the original file followed by the update.
+func (u *Update) Write(w io.Writer) error {
+	var b bytes.Buffer
+	b.Write(u.f.data)
+	if len(u.f.data) > 0 && u.f.data[len(u.f.data)-1] != '\n' {
+		b.WriteByte('\n')
+	}
+	nums := make([]int, 0, len(u.objects)+1)
+	for n := range u.objects {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	offsets := map[int]int{}
+	for _, n := range nums {
+		offsets[n] = b.Len()
+		fmt.Fprintf(&b, "%d %d obj\n", n, u.gens[n])
+		WriteObject(&b, u.objects[n])
+		b.WriteString("\nendobj\n")
+	}
+
+	trailer := Dict{"Prev": u.f.startxref}
+	for _, k := range []Name{"Root", "Info", "ID"} {
+		if v, ok := u.f.trailer[k]; ok {
+			trailer[k] = v
+		}
+	}
+	xrefAt := b.Len()
+	if u.f.xrefStream {
+		// The cross-reference stream lists itself.
+		self := u.next
+		nums = append(nums, self)
+		offsets[self] = xrefAt
+		trailer["Type"] = Name("XRef")
+		trailer["Size"] = int64(self + 1)
+		width := 1
+		for xrefAt>>(8*width) > 0 {
+			width++
+		}
+		trailer["W"] = Array{int64(1), int64(width), int64(2)}
+		var index Array
+		var rows []byte
+		for _, run := range runs(nums) {
+			index = append(index, int64(run[0]), int64(len(run)))
+			for _, n := range run {
+				rows = append(rows, 1)
+				for i := width - 1; i >= 0; i-- {
+					rows = append(rows, byte(offsets[n]>>(8*i)))
+				}
+				rows = append(rows, byte(u.gens[n]>>8), byte(u.gens[n]))
+			}
+		}
+		trailer["Index"] = index
+		fmt.Fprintf(&b, "%d 0 obj\n", self)
+		WriteObject(&b, &Stream{Dict: trailer, Data: rows})
+		b.WriteString("\nendobj\n")
+	} else {
+		trailer["Size"] = int64(u.next)
+		b.WriteString("xref\n")
+		for _, run := range runs(nums) {
+			fmt.Fprintf(&b, "%d %d\n", run[0], len(run))
+			for _, n := range run {
+				fmt.Fprintf(&b, "%010d %05d n\r\n", offsets[n], u.gens[n])
+			}
+		}
+		b.WriteString("trailer\n")
+		WriteObject(&b, trailer)
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "startxref\n%d\n%%%%EOF\n", xrefAt)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// runs splits sorted object numbers into runs of consecutive numbers.
+func runs(nums []int) [][]int {
+	var out [][]int
+	for i, n := range nums {
+		if i == 0 || n != nums[i-1]+1 {
+			out = append(out, nil)
+		}
+		out[len(out)-1] = append(out[len(out)-1], n)
+	}
+	return out
+}
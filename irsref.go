@@ -0,0 +1,237 @@
+package annotation
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// IRSLineRef is a parsed IRS line reference such as "Line 2b",
+// "Schedule 1, Line 10" or "Part III, Box 12a - Retirement plan". A
+// reference that names no schedule, part, line or box, such as "ZIP code",
+// is kept whole in Unstructured.
+type IRSLineRef struct {
+	// Schedule is the schedule's name, upper-cased, as "1" or "K-1"; empty
+	// for the main form.
+	Schedule string
+	// Part is the part's numeral, upper-cased, as "III".
+	Part string
+	// Box reports that the reference names a box rather than a line.
+	Box bool
+	// Line is the line or box number; zero when the reference names none.
+	Line int
+	// Sub is the sub-line letter, lower-cased, as "b".
+	Sub string
+	// Description is the text after the reference's " - ".
+	Description string
+	// Unstructured is the reference as written, with spaces collapsed, when
+	// it could not be parsed.
+	Unstructured string
+}
+
+var (
+	lineRefDescription = regexp.MustCompile(`\s+[-–—]\s+`)
+	lineRefPattern     = regexp.MustCompile(`(?i)^` +
+		`(?:(?:schedule\s+|sch\.\s*|sch\s+)([a-z0-9]+(?:-[a-z0-9]+)?)(?:\s*,\s*|\s+|$))?` +
+		`(?:part\s+([ivxlcdm]+|\d+)(?:\s*,\s*|\s+|$))?` +
+		`(?:(line|box)\s*(\d+)\s*(?:\(([a-z]{1,2})\)|([a-z]{0,2})))?$`)
+	lineRefKeyword = regexp.MustCompile(`(?i)^(?:line|box|part|sch(?:edule)?)\b`)
+)
+
+// ParseIRSLineRef parses a line reference. Case, spacing and a
+// parenthesized sub-line ("Line 2(b)") are not significant. A reference
+// that does not parse is returned in Unstructured, not as an error; the
+// error is for an empty reference.
+func ParseIRSLineRef(s string) (IRSLineRef, error) {
+	s = strings.Join(strings.Fields(s), " ")
+	if s == "" {
+		return IRSLineRef{}, fmt.Errorf("empty line reference")
+	}
+	head, desc := s, ""
+	if loc := lineRefDescription.FindStringIndex(s); loc != nil {
+		head, desc = s[:loc[0]], s[loc[1]:]
+	}
+	m := lineRefPattern.FindStringSubmatch(head)
+	if m == nil || m[1]+m[2]+m[3] == "" {
+		return IRSLineRef{Unstructured: s}, nil
+	}
+	ref := IRSLineRef{
+		Schedule:    strings.ToUpper(m[1]),
+		Part:        strings.ToUpper(m[2]),
+		Box:         strings.EqualFold(m[3], "box"),
+		Sub:         strings.ToLower(m[5] + m[6]),
+		Description: desc,
+	}
+	if m[4] != "" {
+		line, err := strconv.Atoi(m[4])
+		if err != nil || line == 0 {
+			return IRSLineRef{Unstructured: s}, nil
+		}
+		ref.Line = line
+	}
+	return ref, nil
+}
+
+// Structured reports whether the reference was parsed into components.
+func (r IRSLineRef) Structured() bool { return r.Unstructured == "" }
+
+// String renders the reference in canonical form, as
+// "Schedule 1, Part II, Line 10b - Description".
+func (r IRSLineRef) String() string {
+	if !r.Structured() {
+		return r.Unstructured
+	}
+	var parts []string
+	if r.Schedule != "" {
+		parts = append(parts, "Schedule "+r.Schedule)
+	}
+	if r.Part != "" {
+		parts = append(parts, "Part "+r.Part)
+	}
+	if r.Line != 0 {
+		kind := "Line "
+		if r.Box {
+			kind = "Box "
+		}
+		parts = append(parts, kind+strconv.Itoa(r.Line)+r.Sub)
+	}
+	s := strings.Join(parts, ", ")
+	if r.Description != "" {
+		s += " - " + r.Description
+	}
+	return s
+}
+
+// Compare orders references in IRS line order: the main form before its
+// schedules, numbered schedules before lettered ones, then by part, line
+// and sub-line, with a line before a box of the same number. Unstructured
+// references sort after structured ones, by text. Descriptions are not
+// compared.
+func (r IRSLineRef) Compare(o IRSLineRef) int {
+	if r.Structured() != o.Structured() {
+		if r.Structured() {
+			return -1
+		}
+		return 1
+	}
+	if !r.Structured() {
+		return cmp.Compare(strings.ToLower(r.Unstructured), strings.ToLower(o.Unstructured))
+	}
+	return cmp.Or(
+		compareNumbered(r.Schedule, o.Schedule, strconv.Atoi),
+		compareNumbered(r.Part, o.Part, romanValue),
+		cmp.Compare(r.Line, o.Line),
+		cmp.Compare(r.Sub, o.Sub),
+		compareBool(r.Box, o.Box),
+	)
+}
+
+// sameLine reports whether r names the same line as o. Descriptions are
+// compared only when r has one.
+func (r IRSLineRef) sameLine(o IRSLineRef) bool {
+	if r.Compare(o) != 0 {
+		return false
+	}
+	return r.Description == "" || strings.EqualFold(r.Description, o.Description)
+}
+
+// compareNumbered orders empty names first, then names value parses, by
+// value, then the rest by text.
+func compareNumbered(a, b string, value func(string) (int, error)) int {
+	if a == "" || b == "" {
+		return cmp.Compare(a, b)
+	}
+	va, errA := value(a)
+	vb, errB := value(b)
+	switch {
+	case errA == nil && errB == nil:
+		return cmp.Or(cmp.Compare(va, vb), cmp.Compare(a, b))
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return cmp.Compare(a, b)
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	}
+	return 1
+}
+
+// romanValue returns the value of a part numeral, written in Roman or
+// Arabic digits.
+func romanValue(s string) (int, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, nil
+	}
+	digits := map[byte]int{'I': 1, 'V': 5, 'X': 10, 'L': 50, 'C': 100, 'D': 500, 'M': 1000}
+	total := 0
+	for i := 0; i < len(s); i++ {
+		v, ok := digits[s[i]]
+		if !ok {
+			return 0, fmt.Errorf("%q is not a numeral", s)
+		}
+		if i+1 < len(s) && digits[s[i+1]] > v {
+			v = -v
+		}
+		total += v
+	}
+	return total, nil
+}
+
+// GetFieldsByLineRef returns the fields whose IRS line reference names the
+// same line as ref, in page order. References are compared parsed, so
+// "line 2(b)" finds "Line 2b - Taxable interest"; a description in ref must
+// match too. Unstructured references are compared as text, ignoring case
+// and spacing.
+func (fa *FormAnnotation) GetFieldsByLineRef(ref string) []*Field {
+	want, err := ParseIRSLineRef(ref)
+	if err != nil {
+		return nil
+	}
+	return fa.Query().Where(func(f *Field) bool {
+		got, err := ParseIRSLineRef(f.IRSLineRef)
+		return err == nil && want.sameLine(got)
+	}).Fields()
+}
+
+// SortByLineRef orders fields by their parsed IRS line references, as
+// IRSLineRef.Compare does. Fields without a reference sort last; ties keep
+// their order.
+func SortByLineRef(fields []*Field) {
+	type keyed struct {
+		f   *Field
+		ref IRSLineRef
+		ok  bool
+	}
+	keys := make([]keyed, len(fields))
+	for i, f := range fields {
+		ref, err := ParseIRSLineRef(f.IRSLineRef)
+		keys[i] = keyed{f, ref, err == nil}
+	}
+	slices.SortStableFunc(keys, func(a, b keyed) int {
+		if a.ok != b.ok {
+			return compareBool(!a.ok, !b.ok)
+		}
+		return a.ref.Compare(b.ref)
+	})
+	for i, k := range keys {
+		fields[i] = k.f
+	}
+}
+
+// malformedLineRef reports whether ref starts like a structured reference
+// but does not parse as one, as "Line twelve" does.
+func malformedLineRef(ref string) bool {
+	parsed, err := ParseIRSLineRef(ref)
+	return err == nil && !parsed.Structured() && lineRefKeyword.MatchString(parsed.Unstructured)
+}
@@ -0,0 +1,133 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jsonFrame is an object or array open at some point of a token scan.
+type jsonFrame struct {
+	array   bool
+	index   int
+	key     string
+	wantKey bool
+	// pageNumber and fieldID are read from a page or field object.
+	pageNumber int
+	fieldID    string
+}
+
+// jsonLocation is where in an annotation document an offset falls: the
+// path of the innermost value starting before it, such as
+// pages[0].fields[12].value, and the page number and field ID of the page
+// and field objects it is in, where those could be read.
+type jsonLocation struct {
+	path    string
+	page    int
+	fieldID string
+}
+
+// locateJSONPath scans data up to offset, and on to the end of the page
+// object the offset falls in, to find its jsonLocation. A scan stopped by
+// malformed JSON reports what it read before.
+func locateJSONPath(data []byte, offset int64) jsonLocation {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var stack, at []*jsonFrame
+	var loc jsonLocation
+	// pageDepth is the depth of a page object: the document, its pages
+	// array, then the page. A field object is two deeper.
+	const pageDepth = 3
+	done := func() bool {
+		return at != nil && (len(at) < pageDepth || len(stack) < pageDepth || stack[pageDepth-1] != at[pageDepth-1])
+	}
+	for !done() {
+		before := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			if at == nil {
+				loc.path = jsonFramePath(stack)
+			}
+			break
+		}
+		if at == nil && before >= offset {
+			at = slices.Clone(stack)
+		}
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				valueDone(stack[len(stack)-1])
+			}
+			continue
+		}
+		if top != nil && !top.array && top.wantKey {
+			top.key, _ = tok.(string)
+			top.wantKey = false
+			continue
+		}
+		if at == nil {
+			loc.path = jsonFramePath(stack)
+		}
+		if top != nil {
+			switch {
+			case top.key == "page_number" && len(stack) == pageDepth:
+				if n, ok := tok.(json.Number); ok {
+					top.pageNumber, _ = strconv.Atoi(n.String())
+				}
+			case top.key == "field_id" && len(stack) == pageDepth+2:
+				top.fieldID, _ = tok.(string)
+			}
+		}
+		if d, ok := tok.(json.Delim); ok {
+			stack = append(stack, &jsonFrame{array: d == '[', wantKey: d == '{'})
+			continue
+		}
+		if top != nil {
+			valueDone(top)
+		}
+	}
+	if at == nil {
+		at = stack
+	}
+	if strings.HasPrefix(loc.path, "pages[") && len(at) >= pageDepth {
+		loc.page = at[pageDepth-1].pageNumber
+		if strings.Contains(loc.path, "].fields[") && len(at) >= pageDepth+2 {
+			loc.fieldID = at[pageDepth+1].fieldID
+		}
+	}
+	return loc
+}
+
+// jsonFramePath renders the path of the value read next in the innermost
+// frame, or of the object itself when a key is read next.
+func jsonFramePath(stack []*jsonFrame) string {
+	var sb strings.Builder
+	for _, f := range stack {
+		switch {
+		case f.array:
+			sb.WriteString("[" + strconv.Itoa(f.index) + "]")
+		case f.key != "" && !f.wantKey:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(f.key)
+		}
+	}
+	return sb.String()
+}
+
+// valueDone moves a container past a completed value: an object to its
+// next key, an array to its next index.
+func valueDone(f *jsonFrame) {
+	if f.array {
+		f.index++
+	} else {
+		f.wantKey = true
+	}
+}
@@ -0,0 +1,132 @@
+package annotation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LabelSet is a field's label and tooltip in one language.
+type LabelSet struct {
+	Label   string `json:"label,omitempty"`
+	Tooltip string `json:"tooltip,omitempty"`
+}
+
+// InvalidLocalization is the validation issue code for a localized label
+// set keyed by a malformed language tag or holding nothing.
+const InvalidLocalization = "invalid_localization"
+
+// LabelFor returns the field's label in lang, a BCP 47 tag such as "es" or
+// "es-MX". It falls back from the full tag to ever shorter prefixes, so
+// "es-MX" uses "es" when it has no entry of its own, and then to Label.
+// Tags match regardless of case, with "_" read as "-".
+func (f *Field) LabelFor(lang string) string {
+	for _, tag := range languageFallbacks(lang) {
+		if set, ok := f.localized(tag); ok && set.Label != "" {
+			return set.Label
+		}
+	}
+	return f.Label
+}
+
+// TooltipFor returns the field's tooltip in lang, falling back like
+// LabelFor and then to Tooltip.
+func (f *Field) TooltipFor(lang string) string {
+	for _, tag := range languageFallbacks(lang) {
+		if set, ok := f.localized(tag); ok && set.Tooltip != "" {
+			return set.Tooltip
+		}
+	}
+	return f.Tooltip
+}
+
+// Languages returns the language tags the field has localized text in.
+func (f *Field) Languages() []string {
+	var tags []string
+	for tag := range f.Localized {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// localized returns the entry of Localized whose key is tag, compared in
+// normalized form.
+func (f *Field) localized(tag string) (LabelSet, bool) {
+	if set, ok := f.Localized[tag]; ok {
+		return set, true
+	}
+	for key, set := range f.Localized {
+		if normalizeLanguage(key) == tag {
+			return set, true
+		}
+	}
+	return LabelSet{}, false
+}
+
+// languageFallbacks returns lang normalized and each of its shorter
+// prefixes, longest first: "es-Latn-MX", "es-latn", "es".
+func languageFallbacks(lang string) []string {
+	tag := normalizeLanguage(lang)
+	var chain []string
+	for tag != "" {
+		chain = append(chain, tag)
+		i := strings.LastIndexByte(tag, '-')
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+	return chain
+}
+
+func normalizeLanguage(lang string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+}
+
+// validLanguage reports whether tag is shaped like a BCP 47 tag: a primary
+// language of two or three letters, then subtags of one to eight letters
+// or digits.
+func validLanguage(tag string) bool {
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	for i, p := range parts {
+		if len(p) < 1 || len(p) > 8 || (i == 0 && (len(p) < 2 || len(p) > 3)) {
+			return false
+		}
+		for _, r := range p {
+			isLetter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+			if !isLetter && (i == 0 || r < '0' || r > '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// checkLocalizations reports localized label sets keyed by a malformed
+// tag, keyed twice once normalized, or with neither a label nor a tooltip.
+func (fa *FormAnnotation) checkLocalizations() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, page := range fa.Pages {
+		for _, f := range page.Fields {
+			seen := map[string]string{}
+			for _, tag := range f.Languages() {
+				set := f.Localized[tag]
+				var msg string
+				switch {
+				case !validLanguage(tag):
+					msg = fmt.Sprintf("localized labels keyed by %q, which is not a language tag", tag)
+				case seen[normalizeLanguage(tag)] != "":
+					msg = fmt.Sprintf("localized labels %q and %q are the same language", seen[normalizeLanguage(tag)], tag)
+				case set.Label == "" && set.Tooltip == "":
+					msg = fmt.Sprintf("localized labels for %q are empty", tag)
+				}
+				seen[normalizeLanguage(tag)] = tag
+				if msg != "" {
+					issues = append(issues, ValidationIssue{Code: InvalidLocalization, Severity: SeverityError, FieldID: f.FieldID, Page: page.PageNumber, Message: msg})
+				}
+			}
+		}
+	}
+	return issues
+}
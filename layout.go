@@ -0,0 +1,349 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LayoutDocument is the output of the layout-analysis pipeline: per page,
+// the detected text boxes and the widget candidates that may be fields.
+type LayoutDocument struct {
+	Pages []LayoutPage `json:"pages"`
+}
+
+// LayoutPage holds the detections of one page.
+type LayoutPage struct {
+	Page      int         `json:"page"`
+	TextBoxes []LayoutBox `json:"text_boxes"`
+	Widgets   []LayoutBox `json:"widgets"`
+}
+
+// LayoutBox is one detection. BBox is x, y, width, height in pixels from
+// the page's top-left corner.
+type LayoutBox struct {
+	ID         string     `json:"id,omitempty"`
+	Text       string     `json:"text,omitempty"`
+	BBox       [4]float64 `json:"bbox"`
+	Confidence float64    `json:"confidence"`
+}
+
+// Overlap handling for widget candidates.
+const (
+	OverlapMerge = "merge"
+	OverlapFlag  = "flag"
+)
+
+// LayoutHeuristics classifies widget candidates. Sizes are in points.
+type LayoutHeuristics struct {
+	// CheckboxMaxSide is the largest side of a checkbox.
+	CheckboxMaxSide float64
+	// CheckboxAspectTolerance is how far width/height may stray from 1.
+	CheckboxAspectTolerance float64
+	// TextMinAspect is the smallest width/height of a text box.
+	TextMinAspect float64
+	// LabelMaxDistance is the farthest a label may sit from its widget.
+	LabelMaxDistance float64
+}
+
+// DefaultLayoutHeuristics suit IRS forms scanned at print size.
+var DefaultLayoutHeuristics = LayoutHeuristics{
+	CheckboxMaxSide:         14,
+	CheckboxAspectTolerance: 0.25,
+	TextMinAspect:           2.5,
+	LabelMaxDistance:        144,
+}
+
+// LayoutImportOptions controls ImportFromLayout.
+type LayoutImportOptions struct {
+	FormID   string
+	FormName string
+	Year     int
+	// DPI is the resolution the pages were rasterized at; required.
+	DPI float64
+	// PageSize is the size of every page and sets the unit of the result.
+	PageSize PageSize
+	// Heuristics classify candidates; zero fields take the defaults.
+	Heuristics LayoutHeuristics
+	// MinConfidence is the confidence below which a candidate is listed for
+	// review. Zero uses 0.5.
+	MinConfidence float64
+	// Overlap is OverlapMerge or OverlapFlag; empty merges.
+	Overlap string
+	// OverlapThreshold is the intersection-over-union at which candidates
+	// overlap. Zero uses 0.5.
+	OverlapThreshold float64
+}
+
+// Layout candidate classifications.
+const (
+	LayoutCheckbox     = "checkbox"
+	LayoutText         = "text"
+	LayoutUnclassified = "unclassified"
+)
+
+// ImportedField records where an imported field came from.
+type ImportedField struct {
+	FieldID        string   `json:"field_id"`
+	Page           int      `json:"page"`
+	Sources        []string `json:"sources"`
+	Confidence     float64  `json:"confidence"`
+	Classification string   `json:"classification"`
+	Label          string   `json:"label,omitempty"`
+}
+
+// LayoutCandidate is a widget candidate that needs a human, positioned in
+// the result's unit.
+type LayoutCandidate struct {
+	Source     string   `json:"source"`
+	Page       int      `json:"page"`
+	Position   Position `json:"position"`
+	Confidence float64  `json:"confidence"`
+	FieldID    string   `json:"field_id,omitempty"`
+	Reason     string   `json:"reason"`
+}
+
+// LayoutOverlap is a pair of overlapping candidates that were merged or flagged.
+type LayoutOverlap struct {
+	Page    int      `json:"page"`
+	Sources []string `json:"sources"`
+	Ratio   float64  `json:"ratio"`
+	Merged  bool     `json:"merged"`
+}
+
+// ImportReport is the outcome of ImportFromLayout. Fields carries the
+// provenance of every imported field; LowConfidence lists imported fields
+// to review and Unclassified the candidates that were not imported.
+type ImportReport struct {
+	Fields        []ImportedField   `json:"fields"`
+	LowConfidence []LayoutCandidate `json:"low_confidence,omitempty"`
+	Unclassified  []LayoutCandidate `json:"unclassified,omitempty"`
+	Overlaps      []LayoutOverlap   `json:"overlaps,omitempty"`
+}
+
+// layoutRowTolerance groups candidates into rows, in points, when ordering
+// them for ID generation.
+const layoutRowTolerance = 4
+
+var layoutSlugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// layoutCandidate is a widget candidate in points.
+type layoutCandidate struct {
+	sources    []string
+	rect       Position
+	confidence float64
+}
+
+// ImportFromLayout builds an annotation from layout-analysis output. Pixel
+// boxes are converted to the page unit at opts.DPI; widget candidates are
+// classified as checkboxes (small squares) or text fields (wide, short
+// boxes) and labeled with the nearest text box to their left, above or, for
+// checkboxes, right. Field IDs are derived from the page and label, so the
+// same input always yields the same IDs. Candidates that fit neither shape
+// are left out of the annotation and listed in the report.
+func ImportFromLayout(r io.Reader, opts LayoutImportOptions) (*FormAnnotation, ImportReport, error) {
+	var report ImportReport
+	if opts.DPI <= 0 {
+		return nil, report, fmt.Errorf("layout import: DPI must be positive")
+	}
+	factor, ok := toPoints(1, opts.PageSize.Unit)
+	if !ok {
+		return nil, report, fmt.Errorf("layout import: unknown page unit %q", opts.PageSize.Unit)
+	}
+	switch opts.Overlap {
+	case "", OverlapMerge, OverlapFlag:
+	default:
+		return nil, report, fmt.Errorf("layout import: unknown overlap mode %q", opts.Overlap)
+	}
+	var doc LayoutDocument
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, report, fmt.Errorf("layout import: %w", err)
+	}
+
+	h := opts.Heuristics
+	def := DefaultLayoutHeuristics
+	h.CheckboxMaxSide = orDefault(h.CheckboxMaxSide, def.CheckboxMaxSide)
+	h.CheckboxAspectTolerance = orDefault(h.CheckboxAspectTolerance, def.CheckboxAspectTolerance)
+	h.TextMinAspect = orDefault(h.TextMinAspect, def.TextMinAspect)
+	h.LabelMaxDistance = orDefault(h.LabelMaxDistance, def.LabelMaxDistance)
+	minConfidence := orDefault(opts.MinConfidence, 0.5)
+	threshold := orDefault(opts.OverlapThreshold, 0.5)
+
+	pxToPt := 72 / opts.DPI
+	toRect := func(b [4]float64) Position {
+		return Position{X: b[0] * pxToPt, Y: b[1] * pxToPt, Width: b[2] * pxToPt, Height: b[3] * pxToPt, Unit: "pt"}
+	}
+	inUnit := func(p Position) Position {
+		return Position{X: p.X / factor, Y: p.Y / factor, Width: p.Width / factor, Height: p.Height / factor, Unit: opts.PageSize.Unit}
+	}
+
+	fa := &FormAnnotation{FormMetadata: FormMetadata{
+		FormID:    opts.FormID,
+		FormName:  opts.FormName,
+		Year:      opts.Year,
+		PageCount: len(doc.Pages),
+		PageSize:  opts.PageSize,
+	}}
+	usedIDs := map[string]bool{}
+	for _, lp := range doc.Pages {
+		var candidates []layoutCandidate
+		for i, w := range lp.Widgets {
+			source := w.ID
+			if source == "" {
+				source = "widget:" + strconv.Itoa(lp.Page) + ":" + strconv.Itoa(i)
+			}
+			candidates = append(candidates, layoutCandidate{sources: []string{source}, rect: toRect(w.BBox), confidence: w.Confidence})
+		}
+		candidates = resolveLayoutOverlaps(lp.Page, candidates, opts.Overlap != OverlapFlag, threshold, &report)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i].rect, candidates[j].rect
+			if math.Abs(a.Y-b.Y) > layoutRowTolerance {
+				return a.Y < b.Y
+			}
+			return a.X < b.X
+		})
+
+		page := Page{PageNumber: lp.Page}
+		for n, c := range candidates {
+			class := classifyLayout(c.rect, h)
+			if class == LayoutUnclassified {
+				report.Unclassified = append(report.Unclassified, LayoutCandidate{
+					Source: strings.Join(c.sources, "+"), Page: lp.Page, Position: inUnit(c.rect),
+					Confidence: c.confidence, Reason: "shape matches neither a checkbox nor a text field",
+				})
+				continue
+			}
+			label := nearestLabel(c.rect, class, lp.TextBoxes, toRect, h.LabelMaxDistance)
+			id := layoutFieldID(lp.Page, label, n+1, usedIDs)
+			field := Field{FieldID: id, Label: label, Position: inUnit(c.rect)}
+			if class == LayoutCheckbox {
+				field.FieldType, field.DataType = FieldTypeCheckbox, DataTypeBoolean
+			} else {
+				field.FieldType, field.DataType = FieldTypeText, DataTypeString
+			}
+			page.Fields = append(page.Fields, field)
+			report.Fields = append(report.Fields, ImportedField{
+				FieldID: id, Page: lp.Page, Sources: c.sources, Confidence: c.confidence,
+				Classification: class, Label: label,
+			})
+			if c.confidence < minConfidence {
+				report.LowConfidence = append(report.LowConfidence, LayoutCandidate{
+					Source: strings.Join(c.sources, "+"), Page: lp.Page, Position: field.Position,
+					Confidence: c.confidence, FieldID: id,
+					Reason: fmt.Sprintf("confidence %.2f is below %.2f", c.confidence, minConfidence),
+				})
+			}
+		}
+		fa.Pages = append(fa.Pages, page)
+	}
+	return fa, report, nil
+}
+
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
+}
+
+// resolveLayoutOverlaps merges, or only reports, candidates whose overlap
+// reaches threshold. A merged candidate covers both boxes and keeps the
+// higher confidence.
+func resolveLayoutOverlaps(page int, candidates []layoutCandidate, merge bool, threshold float64, report *ImportReport) []layoutCandidate {
+	for i := 0; i < len(candidates); i++ {
+		for j := i + 1; j < len(candidates); j++ {
+			ratio := overlapRatio(candidates[i].rect, candidates[j].rect, "pt")
+			if ratio < threshold {
+				continue
+			}
+			report.Overlaps = append(report.Overlaps, LayoutOverlap{
+				Page:    page,
+				Sources: append(cloneSlice(candidates[i].sources), candidates[j].sources...),
+				Ratio:   ratio,
+				Merged:  merge,
+			})
+			if !merge {
+				continue
+			}
+			a, b := candidates[i].rect, candidates[j].rect
+			x0, y0 := min(a.X, b.X), min(a.Y, b.Y)
+			x1, y1 := max(a.X+a.Width, b.X+b.Width), max(a.Y+a.Height, b.Y+b.Height)
+			candidates[i] = layoutCandidate{
+				sources:    append(candidates[i].sources, candidates[j].sources...),
+				rect:       Position{X: x0, Y: y0, Width: x1 - x0, Height: y1 - y0, Unit: "pt"},
+				confidence: max(candidates[i].confidence, candidates[j].confidence),
+			}
+			candidates = append(candidates[:j], candidates[j+1:]...)
+			j = i
+		}
+	}
+	return candidates
+}
+
+func classifyLayout(rect Position, h LayoutHeuristics) string {
+	if rect.Width <= 0 || rect.Height <= 0 {
+		return LayoutUnclassified
+	}
+	aspect := rect.Width / rect.Height
+	switch {
+	case rect.Width <= h.CheckboxMaxSide && rect.Height <= h.CheckboxMaxSide &&
+		math.Abs(aspect-1) <= h.CheckboxAspectTolerance:
+		return LayoutCheckbox
+	case aspect >= h.TextMinAspect:
+		return LayoutText
+	}
+	return LayoutUnclassified
+}
+
+// nearestLabel returns the text of the closest text box that ends left of
+// the widget on its row or above it in its column, or for checkboxes starts
+// right of it on its row, within maxDistance points.
+func nearestLabel(rect Position, class string, boxes []LayoutBox, toRect func([4]float64) Position, maxDistance float64) string {
+	best, bestDistance := "", maxDistance
+	for _, b := range boxes {
+		text := strings.TrimSpace(b.Text)
+		if text == "" {
+			continue
+		}
+		t := toRect(b.BBox)
+		sameRow := t.Y < rect.Y+rect.Height && rect.Y < t.Y+t.Height
+		sameColumn := t.X < rect.X+rect.Width && rect.X < t.X+t.Width
+		distance := math.Inf(1)
+		switch {
+		case sameRow && t.X+t.Width <= rect.X:
+			distance = rect.X - (t.X + t.Width)
+		case sameRow && class == LayoutCheckbox && t.X >= rect.X+rect.Width:
+			distance = t.X - (rect.X + rect.Width)
+		case sameColumn && t.Y+t.Height <= rect.Y:
+			distance = rect.Y - (t.Y + t.Height)
+		}
+		if distance <= bestDistance {
+			best, bestDistance = text, distance
+		}
+	}
+	return best
+}
+
+// layoutFieldID derives a unique ID from the page and label, falling back to
+// the candidate's reading-order position.
+func layoutFieldID(page int, label string, n int, used map[string]bool) string {
+	slug := strings.Trim(layoutSlugPattern.ReplaceAllString(strings.ToLower(label), "_"), "_")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "_")
+	}
+	if slug == "" {
+		slug = "field_" + strconv.Itoa(n)
+	}
+	base := "p" + strconv.Itoa(page) + "_" + slug
+	id := base
+	for i := 2; used[id]; i++ {
+		id = base + "_" + strconv.Itoa(i)
+	}
+	used[id] = true
+	return id
+}
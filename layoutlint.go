@@ -0,0 +1,71 @@
+package annotation
+
+import "fmt"
+
+// Layout lint issue codes.
+const (
+	FieldsOverlap    = "fields_overlap"
+	ZeroSizePosition = "zero_size_position"
+	SegmentsOverlap  = "segments_overlap"
+)
+
+// LintLayout checks where fields sit on their pages: fields whose boxes
+// overlap, fields extending past the page, positions with no width or
+// height, and segments of one segmented field that collide. Overlapping
+// fields are warnings, since some forms print boxes inside one another;
+// the rest are errors. Virtual fields have no position and are skipped.
+func (fa *FormAnnotation) LintLayout() []ValidationIssue {
+	var issues []ValidationIssue
+	unit := fa.FormMetadata.PageSize.Unit
+	for _, page := range fa.Pages {
+		size := fa.PageSizeOf(page.PageNumber)
+		pageSize, pageOK := pageInPoints(size)
+		for _, field := range page.Fields {
+			if field.IsVirtual() {
+				continue
+			}
+			at := ValidationIssue{FieldID: field.FieldID, Page: page.PageNumber, Severity: SeverityError}
+			add := func(code, format string, args ...any) {
+				at.Code, at.Message = code, fmt.Sprintf(format, args...)
+				issues = append(issues, at)
+			}
+			segments := segmentPositions(&field)
+			if len(segments) == 0 {
+				if pos := field.Position; pos.Width == 0 || pos.Height == 0 {
+					add(ZeroSizePosition, "position has zero size %gx%g", pos.Width, pos.Height)
+				}
+			}
+			for i, seg := range segments {
+				if seg.Width == 0 || seg.Height == 0 {
+					add(ZeroSizePosition, "segment %d has zero size %gx%g", i+1, seg.Width, seg.Height)
+				}
+			}
+			if pageOK && fa.offPage(&field, page.PageNumber, pageSize) {
+				add(PositionOffPage, "field extends beyond the %gx%g page", size.Width, size.Height)
+			}
+			for i := range segments {
+				a, ok := positionInPoints(segments[i], unit)
+				if !ok {
+					continue
+				}
+				for j := i + 1; j < len(segments); j++ {
+					if b, ok := positionInPoints(segments[j], unit); ok {
+						if _, hit := intersect(a, b); hit {
+							add(SegmentsOverlap, "segments %d and %d overlap", i+1, j+1)
+						}
+					}
+				}
+			}
+		}
+	}
+	for _, o := range fa.FindOverlaps() {
+		issues = append(issues, ValidationIssue{
+			Code:     FieldsOverlap,
+			Severity: SeverityWarning,
+			FieldID:  o.FieldA,
+			Page:     o.Page,
+			Message:  fmt.Sprintf("field overlaps %q by %.4gx%.4g pt", o.FieldB, o.Intersection.Width, o.Intersection.Height),
+		})
+	}
+	return issues
+}
@@ -0,0 +1,180 @@
+package annotation
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Library is a collection of annotations indexed by form ID and year.
+type Library struct {
+	forms     map[libraryKey]*FormAnnotation
+	paths     map[libraryKey]string
+	templates map[string]*PageTemplate
+}
+
+type libraryKey struct {
+	formID string
+	year   int
+}
+
+// LibraryOptions controls library loading.
+type LibraryOptions struct {
+	// Conformance, when set, rejects any annotation whose metadata fails
+	// ValidateMetadata under these rules.
+	Conformance *MetadataRules
+	Load        LoadOptions
+	// ResolveIncludes replaces every form with its resolved copy once all
+	// documents are loaded, so conflicts surface at load time.
+	ResolveIncludes bool
+	// ResolveTemplates replaces every overlay with the form ResolveTemplate
+	// builds from it and its base, before includes are resolved.
+	ResolveTemplates bool
+}
+
+// ConformanceError reports an annotation rejected by library conformance checks.
+type ConformanceError struct {
+	Path   string
+	Report *ValidationReport
+}
+
+func (e *ConformanceError) Error() string {
+	errs := e.Report.Errors()
+	if len(errs) == 1 {
+		return fmt.Sprintf("%s: %v", e.Path, errs[0])
+	}
+	return fmt.Sprintf("%s: %v (and %d more)", e.Path, errs[0], len(errs)-1)
+}
+
+// LoadLibraryStore loads every .json annotation under prefix in store, and
+// every .template.json page template. Two documents claiming the same form
+// ID and year, or the same template name, are an error.
+func LoadLibraryStore(ctx context.Context, store Store, prefix string, opts LibraryOptions) (*Library, error) {
+	lib := &Library{forms: map[libraryKey]*FormAnnotation{}, paths: map[libraryKey]string{}}
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if path.Ext(key) != ".json" {
+			continue
+		}
+		if strings.HasSuffix(key, templateSuffix) {
+			t, err := LoadTemplateFromStore(ctx, store, key, opts.Load)
+			if err != nil {
+				return nil, err
+			}
+			if lib.Template(t.Name) != nil {
+				return nil, fmt.Errorf("%s: template %q is already defined", key, t.Name)
+			}
+			lib.SetTemplate(t)
+			continue
+		}
+		fa, err := LoadFromStore(ctx, store, key, opts.Load)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Conformance != nil {
+			if report := fa.ValidateMetadata(*opts.Conformance); report.HasErrors() {
+				return nil, &ConformanceError{Path: key, Report: report}
+			}
+		}
+		if err := lib.add(key, fa); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ResolveTemplates {
+		resolved := map[libraryKey]*FormAnnotation{}
+		for key, fa := range lib.forms {
+			if !fa.IsOverlay() {
+				continue
+			}
+			out, err := fa.ResolveTemplate(lib)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", lib.paths[key], err)
+			}
+			resolved[key] = out
+		}
+		maps.Copy(lib.forms, resolved)
+	}
+	if opts.ResolveIncludes {
+		for key, fa := range lib.forms {
+			resolved, err := fa.ResolveIncludes(lib)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", lib.paths[key], err)
+			}
+			lib.forms[key] = resolved
+		}
+	}
+	return lib, nil
+}
+
+func (l *Library) add(name string, fa *FormAnnotation) error {
+	key := libraryKey{fa.FormMetadata.FormID, fa.FormMetadata.Year}
+	if prev, ok := l.paths[key]; ok {
+		return fmt.Errorf("%s: form %q year %d is already defined in %s", name, key.formID, key.year, prev)
+	}
+	l.forms[key] = fa
+	l.paths[key] = name
+	return nil
+}
+
+// Get returns the annotation for formID and year, or nil.
+func (l *Library) Get(formID string, year int) *FormAnnotation {
+	return l.forms[libraryKey{formID, year}]
+}
+
+// Forms returns every annotation in the library ordered by form ID and year.
+func (l *Library) Forms() []*FormAnnotation {
+	keys := make([]libraryKey, 0, len(l.forms))
+	for key := range l.forms {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].formID != keys[j].formID {
+			return keys[i].formID < keys[j].formID
+		}
+		return keys[i].year < keys[j].year
+	})
+	forms := make([]*FormAnnotation, len(keys))
+	for i, key := range keys {
+		forms[i] = l.forms[key]
+	}
+	return forms
+}
+
+// LibraryReportOptions controls Library.Report.
+type LibraryReportOptions struct {
+	// Validate is applied to every form; its Progress is ignored in favor
+	// of Progress below, which is reported once per form.
+	Validate ValidateOptions
+	Progress ProgressFunc
+}
+
+// FormReport is the validation outcome for one form in a library.
+type FormReport struct {
+	FormID string            `json:"form_id"`
+	Year   int               `json:"year"`
+	Report *ValidationReport `json:"report"`
+}
+
+// Report runs ValidateAll over every form in the library, in Forms order.
+func (l *Library) Report(opts LibraryReportOptions) []FormReport {
+	forms := l.Forms()
+	validate := opts.Validate
+	validate.Progress = nil
+	reports := make([]FormReport, 0, len(forms))
+	opts.Progress.report(StageForms, 0, len(forms))
+	for i, fa := range forms {
+		reports = append(reports, FormReport{
+			FormID: fa.FormMetadata.FormID,
+			Year:   fa.FormMetadata.Year,
+			Report: fa.ValidateAll(validate),
+		})
+		opts.Progress.report(StageForms, i+1, len(forms))
+	}
+	return reports
+}
@@ -0,0 +1,236 @@
+package annotation
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Line reference suggestion signals, strongest first.
+const (
+	SignalLabel         = "label"
+	SignalFieldID       = "field_id"
+	SignalInterpolation = "reading_order"
+	SignalGroup         = "group"
+)
+
+// SuggestOptions controls SuggestLineRefs.
+type SuggestOptions struct {
+	// Force also proposes refs for fields that already have one. Without it
+	// an existing non-empty IRSLineRef is never suggested over or replaced.
+	Force bool
+	// RowTolerance groups fields into rows for reading order; zero uses the default.
+	RowTolerance float64
+}
+
+// LineRefSuggestion is a proposed IRSLineRef for one field.
+type LineRefSuggestion struct {
+	FieldID    string  `json:"field_id"`
+	Page       int     `json:"page"`
+	CurrentRef string  `json:"current_ref,omitempty"`
+	Suggested  string  `json:"suggested_ref"`
+	Confidence float64 `json:"confidence"`
+	Signal     string  `json:"signal"`
+	Rationale  string  `json:"rationale"`
+}
+
+var (
+	labelLinePattern   = regexp.MustCompile(`(?i)\bline\s*(\d+)\(?([a-z]?)\)?\b`)
+	fieldIDLinePattern = regexp.MustCompile(`(?i)(?:^|_)line_?(\d+)([a-z]?)(?:_|$)`)
+)
+
+// SuggestLineRefs proposes IRS line references for fields that lack one,
+// using label text, the field ID, the reading-order position between fields
+// that do have refs, and group membership. Each field gets at most one
+// suggestion, from its strongest signal.
+func (fa *FormAnnotation) SuggestLineRefs(opts SuggestOptions) []LineRefSuggestion {
+	tolerance := opts.RowTolerance
+	if tolerance == 0 {
+		tolerance = defaultRowTolerance
+	}
+	groupPrefixes := fa.groupRefPrefixes()
+
+	var suggestions []LineRefSuggestion
+	for _, page := range fa.Pages {
+		ordered := readingOrder(page.Fields, tolerance, fa.origin())
+		for i, field := range ordered {
+			if field.IRSLineRef != "" && !opts.Force {
+				continue
+			}
+			s, ok := suggestFromText(field)
+			if !ok {
+				s, ok = suggestFromNeighbours(ordered, i)
+			}
+			if !ok {
+				s, ok = suggestFromGroup(field, groupPrefixes)
+			}
+			if !ok || s.Suggested == field.IRSLineRef {
+				continue
+			}
+			s.FieldID = field.FieldID
+			s.Page = page.PageNumber
+			s.CurrentRef = field.IRSLineRef
+			suggestions = append(suggestions, s)
+		}
+	}
+	return suggestions
+}
+
+// ApplyLineRefSuggestions writes every suggestion at or above minConfidence
+// and returns the ones applied. Existing refs are only replaced with opts.Force.
+func (fa *FormAnnotation) ApplyLineRefSuggestions(minConfidence float64, opts SuggestOptions) []LineRefSuggestion {
+	var applied []LineRefSuggestion
+	for _, s := range fa.SuggestLineRefs(opts) {
+		if s.Confidence < minConfidence {
+			continue
+		}
+		field := fa.GetFieldByID(s.FieldID)
+		if field == nil || (field.IRSLineRef != "" && !opts.Force) {
+			continue
+		}
+		field.IRSLineRef = s.Suggested
+		fa.InvalidateIndex()
+		applied = append(applied, s)
+	}
+	return applied
+}
+
+// WriteLineRefSuggestionsCSV renders suggestions for review in a spreadsheet.
+func WriteLineRefSuggestionsCSV(w io.Writer, suggestions []LineRefSuggestion) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"page", "field_id", "current_ref", "suggested_ref", "confidence", "signal", "rationale"}); err != nil {
+		return err
+	}
+	for _, s := range suggestions {
+		if err := cw.Write([]string{
+			strconv.Itoa(s.Page),
+			s.FieldID,
+			s.CurrentRef,
+			s.Suggested,
+			strconv.FormatFloat(s.Confidence, 'f', 2, 64),
+			s.Signal,
+			s.Rationale,
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func suggestFromText(field *Field) (LineRefSuggestion, bool) {
+	if m := labelLinePattern.FindStringSubmatch(field.Label); m != nil {
+		return LineRefSuggestion{
+			Suggested:  "Line " + m[1] + strings.ToLower(m[2]),
+			Confidence: 0.9,
+			Signal:     SignalLabel,
+			Rationale:  fmt.Sprintf("label %q names the line", field.Label),
+		}, true
+	}
+	if m := fieldIDLinePattern.FindStringSubmatch(field.FieldID); m != nil {
+		return LineRefSuggestion{
+			Suggested:  "Line " + m[1] + strings.ToLower(m[2]),
+			Confidence: 0.75,
+			Signal:     SignalFieldID,
+			Rationale:  fmt.Sprintf("field ID %q names the line", field.FieldID),
+		}, true
+	}
+	return LineRefSuggestion{}, false
+}
+
+// suggestFromNeighbours interpolates a plain line number for ordered[i] from
+// the nearest referenced fields before and after it in reading order, when
+// the gap between them holds exactly as many unreferenced fields as missing
+// line numbers.
+func suggestFromNeighbours(ordered []*Field, i int) (LineRefSuggestion, bool) {
+	prev, prevLine := -1, 0
+	for j := i - 1; j >= 0; j-- {
+		if n, ok := plainLineNumber(ordered[j].IRSLineRef); ok {
+			prev, prevLine = j, n
+			break
+		}
+	}
+	next, nextLine := -1, 0
+	for j := i + 1; j < len(ordered); j++ {
+		if n, ok := plainLineNumber(ordered[j].IRSLineRef); ok {
+			next, nextLine = j, n
+			break
+		}
+	}
+	if prev < 0 || next < 0 || nextLine-prevLine-1 != next-prev-1 {
+		return LineRefSuggestion{}, false
+	}
+	line := prevLine + (i - prev)
+	confidence := 0.6
+	if next-prev > 2 {
+		confidence = 0.5
+	}
+	return LineRefSuggestion{
+		Suggested:  "Line " + strconv.Itoa(line),
+		Confidence: confidence,
+		Signal:     SignalInterpolation,
+		Rationale: fmt.Sprintf("between %q (line %d) and %q (line %d) in reading order",
+			ordered[prev].FieldID, prevLine, ordered[next].FieldID, nextLine),
+	}, true
+}
+
+func suggestFromGroup(field *Field, prefixes map[string]string) (LineRefSuggestion, bool) {
+	prefix, ok := prefixes[field.GroupID]
+	if field.GroupID == "" || !ok {
+		return LineRefSuggestion{}, false
+	}
+	suggested := prefix
+	if field.Label != "" {
+		suggested += " - " + field.Label
+	}
+	return LineRefSuggestion{
+		Suggested:  suggested,
+		Confidence: 0.4,
+		Signal:     SignalGroup,
+		Rationale:  fmt.Sprintf("other members of group %q share the reference %q", field.GroupID, prefix),
+	}, true
+}
+
+// groupRefPrefixes returns, per group, the leading part of the refs shared by
+// every member that has one (e.g. "Filing Status" from "Filing Status - Single").
+func (fa *FormAnnotation) groupRefPrefixes() map[string]string {
+	refs := map[string][]string{}
+	for _, field := range fa.Fields() {
+		if field.GroupID != "" && field.IRSLineRef != "" {
+			refs[field.GroupID] = append(refs[field.GroupID], field.IRSLineRef)
+		}
+	}
+	prefixes := map[string]string{}
+	for group, list := range refs {
+		sort.Strings(list)
+		prefix := list[0]
+		if i := strings.Index(prefix, " - "); i >= 0 {
+			prefix = prefix[:i]
+		}
+		for _, ref := range list[1:] {
+			if !strings.HasPrefix(ref, prefix) {
+				prefix = ""
+				break
+			}
+		}
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes[group] = prefix
+		}
+	}
+	return prefixes
+}
+
+// plainLineNumber extracts the number from a ref like "Line 12" or
+// "Line 12 - Total", rejecting refs with a sub-line letter.
+func plainLineNumber(ref string) (int, bool) {
+	m := labelLinePattern.FindStringSubmatch(ref)
+	if m == nil || m[2] != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
@@ -0,0 +1,219 @@
+// Package lint checks annotations against authoring conventions: how field
+// IDs are spelled, what every currency line must carry, which font sizes
+// are allowed. Conventions differ between teams, so rules are registered
+// by ID and run as a configurable set, and findings are reported as
+// annotation.ValidationIssue values whose Code is the rule ID.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// Rule is one convention.
+type Rule struct {
+	// ID names the rule and is the Code of its findings.
+	ID          string
+	Description string
+	// Severity is the default severity of the rule's findings; empty is a
+	// warning.
+	Severity annotation.Severity
+	// Check returns the rule's findings. Code and Severity are filled in by
+	// the linter; Check sets the location and Message.
+	Check func(fa *annotation.FormAnnotation) []annotation.ValidationIssue
+}
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{}
+)
+
+// Register adds a rule to those Lint runs by default. It panics if the
+// rule has no ID or check, or its ID is already registered.
+func Register(r Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if r.ID == "" || r.Check == nil {
+		panic("lint: Register needs a rule ID and a check")
+	}
+	if _, dup := rules[r.ID]; dup {
+		panic("lint: rule " + r.ID + " is already registered")
+	}
+	rules[r.ID] = r
+}
+
+// Rules returns the registered rules sorted by ID.
+func Rules() []Rule {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	out := make([]Rule, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// RuleSet is a selection of rules run together.
+type RuleSet struct {
+	// Rules are run in order; nil runs every registered rule.
+	Rules []Rule
+	// Severity overrides the severity of rules by ID. SeverityOff turns a
+	// rule off, so a team can adopt the defaults less one.
+	Severity map[string]annotation.Severity
+}
+
+// Lint runs every registered rule over fa.
+func Lint(fa *annotation.FormAnnotation) []annotation.ValidationIssue {
+	return RuleSet{}.Lint(fa)
+}
+
+// Lint runs the set's rules over fa and returns their findings in rule
+// order.
+func (s RuleSet) Lint(fa *annotation.FormAnnotation) []annotation.ValidationIssue {
+	set := s.Rules
+	if set == nil {
+		set = Rules()
+	}
+	var out []annotation.ValidationIssue
+	for _, r := range set {
+		severity := r.Severity
+		if override, ok := s.Severity[r.ID]; ok {
+			severity = override
+		}
+		if severity == annotation.SeverityOff {
+			continue
+		}
+		if severity == "" {
+			severity = annotation.SeverityWarning
+		}
+		for _, issue := range r.Check(fa) {
+			issue.Code, issue.Severity = r.ID, severity
+			out = append(out, issue)
+		}
+	}
+	return out
+}
+
+// Built-in rule IDs.
+const (
+	RuleFieldIDConvention     = "field_id_convention"
+	RuleCurrencyLineRef       = "currency_line_ref"
+	RuleFontSize              = "font_size"
+	RuleRequiredUnconstrained = "required_unconstrained"
+)
+
+// DefaultFieldIDPattern is the snake_case spelling FieldIDConvention
+// enforces when registered by default.
+var DefaultFieldIDPattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// DefaultFontSizes are the font sizes FontSizes allows when registered by
+// default, in points.
+var DefaultFontSizes = []float64{8, 9, 10, 11, 12}
+
+func init() {
+	Register(FieldIDConvention(DefaultFieldIDPattern))
+	Register(CurrencyLineRef())
+	Register(FontSizes(DefaultFontSizes...))
+	Register(RequiredConstraints())
+}
+
+// eachField calls fn with every field and the page it is on, collecting
+// the findings fn returns.
+func eachField(fa *annotation.FormAnnotation, fn func(f *annotation.Field) string) []annotation.ValidationIssue {
+	var out []annotation.ValidationIssue
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			f := &page.Fields[i]
+			if msg := fn(f); msg != "" {
+				out = append(out, annotation.ValidationIssue{FieldID: f.FieldID, Page: page.PageNumber, Message: msg})
+			}
+		}
+	}
+	return out
+}
+
+// FieldIDConvention requires field IDs to match pattern.
+func FieldIDConvention(pattern *regexp.Regexp) Rule {
+	return Rule{
+		ID:          RuleFieldIDConvention,
+		Description: "Field IDs follow the naming convention " + pattern.String() + ".",
+		Severity:    annotation.SeverityWarning,
+		Check: func(fa *annotation.FormAnnotation) []annotation.ValidationIssue {
+			return eachField(fa, func(f *annotation.Field) string {
+				if f.FieldID == "" || pattern.MatchString(f.FieldID) {
+					return ""
+				}
+				return fmt.Sprintf("field ID does not match %s", pattern)
+			})
+		},
+	}
+}
+
+// CurrencyLineRef requires every currency field to name its IRS line.
+func CurrencyLineRef() Rule {
+	return Rule{
+		ID:          RuleCurrencyLineRef,
+		Description: "Currency fields carry an irs_line_ref.",
+		Severity:    annotation.SeverityWarning,
+		Check: func(fa *annotation.FormAnnotation) []annotation.ValidationIssue {
+			return eachField(fa, func(f *annotation.Field) string {
+				if f.FieldType != annotation.FieldTypeCurrency || f.IRSLineRef != "" {
+					return ""
+				}
+				return "currency field has no irs_line_ref"
+			})
+		},
+	}
+}
+
+// FontSizes allows only the given font sizes in field styles. Fields that
+// leave the size to the renderer are not checked.
+func FontSizes(allowed ...float64) Rule {
+	return Rule{
+		ID:          RuleFontSize,
+		Description: fmt.Sprintf("Field font sizes are one of %v points.", allowed),
+		Severity:    annotation.SeverityWarning,
+		Check: func(fa *annotation.FormAnnotation) []annotation.ValidationIssue {
+			return eachField(fa, func(f *annotation.Field) string {
+				if f.Style == nil || f.Style.FontSize == 0 || slices.Contains(allowed, f.Style.FontSize) {
+					return ""
+				}
+				return fmt.Sprintf("font size %g is not one of %v", f.Style.FontSize, allowed)
+			})
+		},
+	}
+}
+
+// RequiredConstraints flags required fields whose validation checks
+// nothing but presence: no pattern, length, range or date bounds. A
+// required line that accepts anything usually means the constraints were
+// forgotten. Checkboxes and signatures are exempt.
+func RequiredConstraints() Rule {
+	return Rule{
+		ID:          RuleRequiredUnconstrained,
+		Description: "Required fields constrain what they accept.",
+		Severity:    annotation.SeverityWarning,
+		Check: func(fa *annotation.FormAnnotation) []annotation.ValidationIssue {
+			return eachField(fa, func(f *annotation.Field) string {
+				v := f.Validation
+				switch {
+				case v == nil || !v.Required && v.RequiredIf == "":
+					return ""
+				case f.FieldType == annotation.FieldTypeCheckbox || f.FieldType == annotation.FieldTypeSignature:
+					return ""
+				case v.Pattern != "" || v.Min != 0 || v.Max != 0 || v.MinLength != 0 || v.MaxLength != 0 || v.MinDate != "" || v.MaxDate != "":
+					return ""
+				case len(f.Options) > 0:
+					return ""
+				}
+				return "required field has no pattern, length, range or date bounds"
+			})
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package annotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// StageLoad is the progress stage of LoadDirectory, counted in files.
+const StageLoad = "load"
+
+// DirectoryOptions controls LoadDirectory.
+type DirectoryOptions struct {
+	// Recursive also loads files in subdirectories.
+	Recursive bool
+	// Pattern selects files by base name, as path.Match does; the default
+	// is "*.json". Page templates (.template.json) are always skipped.
+	Pattern string
+	// Workers is the number of files decoded at once; the default is
+	// GOMAXPROCS.
+	Workers int
+	Load    LoadOptions
+	// Progress is reported as files finish, in any order.
+	Progress ProgressFunc
+}
+
+// FileError is a failure to load one file of a directory.
+type FileError struct {
+	Path string
+	Err  error
+}
+
+func (e *FileError) Error() string { return e.Path + ": " + e.Err.Error() }
+
+func (e *FileError) Unwrap() error { return e.Err }
+
+// LoadDirectory loads the annotation files in dir concurrently, keyed by
+// form ID. A file that fails to load, or claims a form ID an earlier path
+// already has, does not stop the others: the forms that loaded are
+// returned with an error joining a *FileError for each failing path, in
+// path order. Cancelling ctx stops the load and returns ctx.Err().
+func LoadDirectory(ctx context.Context, dir string, opts DirectoryOptions) (map[string]*FormAnnotation, error) {
+	pattern := opts.Pattern
+	if pattern == "" {
+		pattern = "*.json"
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("load directory: pattern %q: %w", pattern, err)
+	}
+	var paths []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && !opts.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ok, _ := path.Match(pattern, d.Name()); ok && !strings.HasSuffix(d.Name(), templateSuffix) {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	type result struct {
+		fa  *FormAnnotation
+		err error
+	}
+	results := make([]result, len(paths))
+	jobs := make(chan int)
+	done := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				data, err := os.ReadFile(paths[i])
+				if err == nil {
+					results[i].fa, err = parseAnnotationFile(paths[i], data, opts.Load)
+				}
+				results[i].err = err
+				done <- i
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := range paths {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	opts.Progress.report(StageLoad, 0, len(paths))
+	finished := 0
+	for range done {
+		finished++
+		opts.Progress.report(StageLoad, finished, len(paths))
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	forms := map[string]*FormAnnotation{}
+	from := map[string]string{}
+	var errs []error
+	for i, r := range results {
+		if r.err != nil {
+			errs = append(errs, &FileError{Path: paths[i], Err: r.err})
+			continue
+		}
+		id := r.fa.FormMetadata.FormID
+		if prev, ok := from[id]; ok {
+			errs = append(errs, &FileError{Path: paths[i], Err: fmt.Errorf("form %q is already loaded from %s", id, prev)})
+			continue
+		}
+		forms[id], from[id] = r.fa, paths[i]
+	}
+	return forms, errors.Join(errs...)
+}
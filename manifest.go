@@ -0,0 +1,181 @@
+package annotation
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+)
+
+// ManifestAlgorithm names the digest scheme of an IntegrityManifest. The
+// scheme is part of the package's compatibility promise: a manifest written
+// by any version verifies under every later one.
+//
+// Every digest is SHA-256 over a sequence of components, each encoded as a
+// 4-byte big-endian length followed by its UTF-8 bytes. Numbers are written
+// in Go's shortest round-trip form (strconv.FormatFloat with 'g', -1) and
+// page numbers in decimal. The components are:
+//
+//	field: "field/v1", field_id, field_type, value, x, y, width, height, unit
+//	page:  "page/v1", page number, then field_id and field digest for each
+//	       field on the page, in field ID order
+//	root:  "root/v1", then page number and page digest for each page, in
+//	       page order
+//
+// Digests are hex encoded. Only these components are covered, so labels,
+// styles, notes and other metadata may change without tripping verification.
+const ManifestAlgorithm = "sha256-lp/v1"
+
+// IntegrityManifest records digests of an annotation's fields and pages.
+type IntegrityManifest struct {
+	Algorithm string        `json:"algorithm"`
+	FormID    string        `json:"form_id"`
+	Year      int           `json:"year"`
+	Root      string        `json:"root"`
+	Pages     []PageDigest  `json:"pages"`
+	Fields    []FieldDigest `json:"fields"`
+}
+
+// PageDigest is the rollup of one page's field digests.
+type PageDigest struct {
+	Page   int    `json:"page"`
+	Digest string `json:"digest"`
+}
+
+// FieldDigest is the digest of one field.
+type FieldDigest struct {
+	FieldID string `json:"field_id"`
+	Page    int    `json:"page"`
+	Digest  string `json:"digest"`
+}
+
+// Integrity violation kinds.
+const (
+	IntegrityModified = "modified"
+	IntegrityAdded    = "added"
+	IntegrityRemoved  = "removed"
+)
+
+// IntegrityViolation is one difference between an annotation and its manifest.
+type IntegrityViolation struct {
+	Kind    string `json:"kind"`
+	FieldID string `json:"field_id,omitempty"`
+	Page    int    `json:"page,omitempty"`
+	Message string `json:"message"`
+}
+
+type digester struct{ h hash.Hash }
+
+func newDigester(parts ...string) *digester {
+	d := &digester{h: sha256.New()}
+	d.add(parts...)
+	return d
+}
+
+func (d *digester) add(parts ...string) {
+	var n [4]byte
+	for _, p := range parts {
+		binary.BigEndian.PutUint32(n[:], uint32(len(p)))
+		d.h.Write(n[:])
+		d.h.Write([]byte(p))
+	}
+}
+
+func (d *digester) sum() string {
+	return hex.EncodeToString(d.h.Sum(nil))
+}
+
+func formatManifestFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func fieldDigest(f *Field) string {
+	p := f.Position
+	return newDigester("field/v1", f.FieldID, string(f.FieldType), f.Value,
+		formatManifestFloat(p.X), formatManifestFloat(p.Y),
+		formatManifestFloat(p.Width), formatManifestFloat(p.Height), p.Unit).sum()
+}
+
+// GenerateManifest computes the integrity manifest of the annotation. Field
+// IDs must be unique so that each digest identifies one field.
+func (fa *FormAnnotation) GenerateManifest() (*IntegrityManifest, error) {
+	m := &IntegrityManifest{
+		Algorithm: ManifestAlgorithm,
+		FormID:    fa.FormMetadata.FormID,
+		Year:      fa.FormMetadata.Year,
+	}
+	seen := map[string]bool{}
+	root := newDigester("root/v1")
+	for _, page := range fa.Pages {
+		var fields []FieldDigest
+		for i := range page.Fields {
+			field := &page.Fields[i]
+			if seen[field.FieldID] {
+				return nil, fmt.Errorf("field ID %q is used more than once", field.FieldID)
+			}
+			seen[field.FieldID] = true
+			fields = append(fields, FieldDigest{FieldID: field.FieldID, Page: page.PageNumber, Digest: fieldDigest(field)})
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].FieldID < fields[j].FieldID })
+		pd := newDigester("page/v1", strconv.Itoa(page.PageNumber))
+		for _, f := range fields {
+			pd.add(f.FieldID, f.Digest)
+		}
+		digest := pd.sum()
+		root.add(strconv.Itoa(page.PageNumber), digest)
+		m.Pages = append(m.Pages, PageDigest{Page: page.PageNumber, Digest: digest})
+		m.Fields = append(m.Fields, fields...)
+	}
+	m.Root = root.sum()
+	return m, nil
+}
+
+// VerifyManifest compares the annotation with m and returns every field
+// modified, added or removed since m was generated. A matching root digest
+// short-circuits the comparison.
+func (fa *FormAnnotation) VerifyManifest(m *IntegrityManifest) []IntegrityViolation {
+	if m.Algorithm != ManifestAlgorithm {
+		return []IntegrityViolation{{Kind: IntegrityModified, Message: fmt.Sprintf("unsupported manifest algorithm %q", m.Algorithm)}}
+	}
+	current, err := fa.GenerateManifest()
+	if err != nil {
+		return []IntegrityViolation{{Kind: IntegrityModified, Message: err.Error()}}
+	}
+	if current.Root == m.Root {
+		return nil
+	}
+	recorded := map[string]FieldDigest{}
+	for _, f := range m.Fields {
+		recorded[f.FieldID] = f
+	}
+	var violations []IntegrityViolation
+	for _, f := range current.Fields {
+		was, ok := recorded[f.FieldID]
+		delete(recorded, f.FieldID)
+		switch {
+		case !ok:
+			violations = append(violations, IntegrityViolation{Kind: IntegrityAdded, FieldID: f.FieldID, Page: f.Page,
+				Message: "field is not in the manifest"})
+		case was.Page != f.Page:
+			violations = append(violations, IntegrityViolation{Kind: IntegrityModified, FieldID: f.FieldID, Page: f.Page,
+				Message: fmt.Sprintf("field moved from page %d", was.Page)})
+		case was.Digest != f.Digest:
+			violations = append(violations, IntegrityViolation{Kind: IntegrityModified, FieldID: f.FieldID, Page: f.Page,
+				Message: "field type, value or position changed"})
+		}
+	}
+	for _, f := range m.Fields {
+		if _, ok := recorded[f.FieldID]; ok {
+			violations = append(violations, IntegrityViolation{Kind: IntegrityRemoved, FieldID: f.FieldID, Page: f.Page,
+				Message: "field in the manifest no longer exists"})
+		}
+	}
+	if len(violations) == 0 {
+		// Every field matches, so pages were added, removed or renumbered.
+		violations = append(violations, IntegrityViolation{Kind: IntegrityModified, Message: "page structure differs from the manifest"})
+	}
+	return violations
+}
@@ -0,0 +1,193 @@
+// Package mapping carries values between forms: a schedule's total copied
+// to the line of the main form it feeds, such as Schedule 1 line 10 to Form
+// 1040 line 8. Links between fields are registered once, by form and by
+// field ID or IRS line reference, and applied to a pair of filled forms or
+// as a pipeline's carryovers phase.
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// Endpoint names a field of a form, by exactly one of FieldID and Line.
+type Endpoint struct {
+	FormID string `json:"form_id"`
+	// Year limits the endpoint to one year of the form; zero matches any.
+	Year    int    `json:"year,omitempty"`
+	FieldID string `json:"field_id,omitempty"`
+	// Line is an IRS line reference, such as "line 10", matched as
+	// FormAnnotation.GetFieldsByLineRef matches it.
+	Line string `json:"line,omitempty"`
+}
+
+func (e Endpoint) String() string {
+	s := e.FormID
+	if e.Year != 0 {
+		s += fmt.Sprintf("/%d", e.Year)
+	}
+	if e.FieldID != "" {
+		return s + " " + e.FieldID
+	}
+	return s + " " + e.Line
+}
+
+// matches reports whether fa is the endpoint's form.
+func (e Endpoint) matches(fa *annotation.FormAnnotation) bool {
+	md := fa.FormMetadata
+	return md.FormID == e.FormID && (e.Year == 0 || md.Year == e.Year)
+}
+
+// resolve returns the endpoint's field in fa.
+func (e Endpoint) resolve(fa *annotation.FormAnnotation) (*annotation.Field, error) {
+	if e.FieldID != "" {
+		if f := fa.GetFieldByID(e.FieldID); f != nil {
+			return f, nil
+		}
+		return nil, fmt.Errorf("no field %q", e.FieldID)
+	}
+	fields := fa.GetFieldsByLineRef(e.Line)
+	switch len(fields) {
+	case 0:
+		return nil, fmt.Errorf("no field has line reference %q", e.Line)
+	case 1:
+		return fields[0], nil
+	}
+	return nil, fmt.Errorf("%d fields have line reference %q; link one by field ID", len(fields), e.Line)
+}
+
+func (e Endpoint) check() error {
+	switch {
+	case e.FormID == "":
+		return fmt.Errorf("endpoint has no form ID")
+	case (e.FieldID == "") == (e.Line == ""):
+		return fmt.Errorf("%s: endpoint must name exactly one of a field ID and a line", e)
+	}
+	return nil
+}
+
+// Link copies the value of the Source field into the Target field.
+type Link struct {
+	Source Endpoint `json:"source"`
+	Target Endpoint `json:"target"`
+}
+
+func (l Link) String() string { return l.Source.String() + " -> " + l.Target.String() }
+
+func (l Link) check() error {
+	if err := l.Source.check(); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	if err := l.Target.check(); err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	return nil
+}
+
+var (
+	linksMu sync.RWMutex
+	links   []Link
+	targets = map[Endpoint]bool{}
+)
+
+// Register adds links to those ApplyCarryovers applies by default. It
+// panics if a link does not name both of its fields, or its target is
+// already the target of a registered link, since one of the two values
+// would silently be lost.
+func Register(ls ...Link) {
+	linksMu.Lock()
+	defer linksMu.Unlock()
+	for _, l := range ls {
+		if err := l.check(); err != nil {
+			panic("mapping: Register: " + err.Error())
+		}
+		if targets[l.Target] {
+			panic("mapping: " + l.Target.String() + " is already the target of a link")
+		}
+		targets[l.Target] = true
+		links = append(links, l)
+	}
+}
+
+// Links returns the registered links in registration order.
+func Links() []Link {
+	linksMu.RLock()
+	defer linksMu.RUnlock()
+	return append([]Link(nil), links...)
+}
+
+// Set is a selection of links applied together.
+type Set struct {
+	// Links are applied in order; nil applies every registered link.
+	Links []Link
+	Fill  annotation.FillOptions
+}
+
+// ApplyCarryovers copies every registered link's value from source into
+// target.
+func ApplyCarryovers(source, target *annotation.FormAnnotation) (*annotation.FillReport, error) {
+	return Set{}.ApplyCarryovers(source, target)
+}
+
+// ApplyCarryovers copies the value of each link running from source's form
+// to target's into target, with SetValues under the set's fill options.
+// Empty source values are skipped, so a carryover never clears a value the
+// target already has. The error reports links whose fields cannot be
+// resolved or that target one field twice; the other links are applied.
+func (s Set) ApplyCarryovers(source, target *annotation.FormAnnotation) (*annotation.FillReport, error) {
+	set := s.Links
+	if set == nil {
+		set = Links()
+	}
+	values := map[string]string{}
+	from := map[string]Link{}
+	var errs []error
+	for _, l := range set {
+		if !l.Source.matches(source) || !l.Target.matches(target) {
+			continue
+		}
+		src, err := l.Source.resolve(source)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: source: %w", l, err))
+			continue
+		}
+		dst, err := l.Target.resolve(target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: target: %w", l, err))
+			continue
+		}
+		if prev, dup := from[dst.FieldID]; dup {
+			errs = append(errs, fmt.Errorf("%s: field %q is already the target of %s", l, dst.FieldID, prev))
+			continue
+		}
+		from[dst.FieldID] = l
+		if src.Value != "" {
+			values[dst.FieldID] = src.Value
+		}
+	}
+	return target.SetValues(values, s.Fill), errors.Join(errs...)
+}
+
+// Phase returns a pipeline phase applying the set's carryovers from each
+// of sources, in order, into the form being processed, for
+// Pipeline.Set(annotation.PhaseCarryovers, ...). Unresolvable links and
+// fill errors fail the phase.
+func (s Set) Phase(sources ...*annotation.FormAnnotation) annotation.PhaseFunc {
+	return func(fa *annotation.FormAnnotation) error {
+		for _, src := range sources {
+			report, err := s.ApplyCarryovers(src, fa)
+			if err != nil {
+				return err
+			}
+			for _, issue := range report.Issues {
+				if issue.Severity == annotation.SeverityError {
+					return fmt.Errorf("carryovers from %s: field %q: %s", src.FormMetadata.FormID, issue.FieldID, issue.Message)
+				}
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,287 @@
+// Package mef moves an annotation's values to and from IRS Modernized
+// e-File (MeF) return XML, so that one annotation drives both the printed
+// form and the e-filed return. A Mapping ties field IDs to the schema
+// elements holding them.
+package mef
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// DefaultNamespace is the namespace of the MeF return schemas.
+const DefaultNamespace = "http://www.irs.gov/efile"
+
+// checked is how MeF writes a checked box (CheckboxType).
+const checked = "X"
+
+// Mapping ties fields to MeF elements.
+type Mapping struct {
+	// Namespace is the XML namespace of the root element; the default is
+	// DefaultNamespace.
+	Namespace string `json:"namespace,omitempty"`
+	// Entries map fields to elements, written in this order within each
+	// parent element.
+	Entries []Entry `json:"entries"`
+}
+
+// Entry maps one field to one element.
+type Entry struct {
+	FieldID string `json:"field_id"`
+	// XPath is the element's absolute path of element names, as in
+	// "/Return/ReturnData/IRS1040/WagesAmt". Predicates, attributes and
+	// wildcards are not supported.
+	XPath string `json:"xpath"`
+	// Amount writes the value in whole dollars, rounded half away from
+	// zero, as USAmountType requires.
+	Amount bool `json:"amount,omitempty"`
+	// DigitsOnly writes only the value's digits, as for SSNs and EINs.
+	DigitsOnly bool `json:"digits_only,omitempty"`
+}
+
+// LoadMapping reads a JSON mapping and checks that its paths are usable.
+func LoadMapping(r io.Reader) (*Mapping, error) {
+	var m Mapping
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("mef: mapping: %w", err)
+	}
+	for _, e := range m.Entries {
+		if _, err := steps(e.XPath); err != nil {
+			return nil, fmt.Errorf("mef: mapping field %q: %w", e.FieldID, err)
+		}
+	}
+	return &m, nil
+}
+
+// Check reports mapping entries that name no field of fa, fields mapped
+// twice, and elements two fields are mapped to.
+func (m *Mapping) Check(fa *annotation.FormAnnotation) error {
+	fields, paths := map[string]bool{}, map[string]string{}
+	for _, e := range m.Entries {
+		if fa.GetFieldByID(e.FieldID) == nil {
+			return fmt.Errorf("mef: mapping names unknown field %q", e.FieldID)
+		}
+		if fields[e.FieldID] {
+			return fmt.Errorf("mef: field %q is mapped twice", e.FieldID)
+		}
+		fields[e.FieldID] = true
+		if other, ok := paths[e.XPath]; ok {
+			return fmt.Errorf("mef: fields %q and %q are both mapped to %s", other, e.FieldID, e.XPath)
+		}
+		paths[e.XPath] = e.FieldID
+	}
+	return nil
+}
+
+// steps splits an absolute element path into element names.
+func steps(xpath string) ([]string, error) {
+	if !strings.HasPrefix(xpath, "/") {
+		return nil, fmt.Errorf("xpath %q is not absolute", xpath)
+	}
+	parts := strings.Split(strings.TrimPrefix(xpath, "/"), "/")
+	for _, p := range parts {
+		if p == "" || strings.ContainsAny(p, "[]@*():") {
+			return nil, fmt.Errorf("xpath %q: step %q is not an element name", xpath, p)
+		}
+	}
+	return parts, nil
+}
+
+// node is an element of the return being written.
+type node struct {
+	name     string
+	text     string
+	children []*node
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	c := &node{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// Export writes values, keyed by field ID, as MeF XML under the mapping. A
+// nil map takes the fields' filled values. Empty values and unchecked
+// boxes are left out, as MeF omits elements with nothing to report;
+// checked boxes are written "X". Values of unmapped fields are ignored.
+func Export(fa *annotation.FormAnnotation, m *Mapping, values map[string]string) ([]byte, error) {
+	root := &node{}
+	for _, e := range m.Entries {
+		field := fa.GetFieldByID(e.FieldID)
+		if field == nil {
+			return nil, fmt.Errorf("mef: mapping names unknown field %q", e.FieldID)
+		}
+		value := field.Value
+		if values != nil {
+			value = values[field.FieldID]
+		}
+		text, err := exportValue(field, e, value)
+		if err != nil {
+			return nil, fmt.Errorf("mef: field %q: %w", e.FieldID, err)
+		}
+		if text == "" {
+			continue
+		}
+		path, err := steps(e.XPath)
+		if err != nil {
+			return nil, fmt.Errorf("mef: field %q: %w", e.FieldID, err)
+		}
+		n := root
+		for _, name := range path {
+			n = n.child(name)
+		}
+		n.text = text
+	}
+	if len(root.children) > 1 {
+		return nil, fmt.Errorf("mef: mapping has %d root elements", len(root.children))
+	}
+
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	enc := xml.NewEncoder(&b)
+	enc.Indent("", "  ")
+	ns := m.Namespace
+	if ns == "" {
+		ns = DefaultNamespace
+	}
+	var write func(n *node, top bool) error
+	write = func(n *node, top bool) error {
+		start := xml.StartElement{Name: xml.Name{Local: n.name}}
+		if top {
+			start.Attr = []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: ns}}
+		}
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		if n.text != "" {
+			if err := enc.EncodeToken(xml.CharData(n.text)); err != nil {
+				return err
+			}
+		}
+		for _, c := range n.children {
+			if err := write(c, false); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	}
+	for _, n := range root.children {
+		if err := write(n, true); err != nil {
+			return nil, fmt.Errorf("mef: %w", err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, fmt.Errorf("mef: %w", err)
+	}
+	b.WriteByte('\n')
+	return b.Bytes(), nil
+}
+
+// exportValue is the element text for a field's value, or "" to omit it.
+func exportValue(field *annotation.Field, e Entry, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if field.FieldType == annotation.FieldTypeCheckbox {
+		if v := strings.ToLower(value); v == "true" || v == "1" || v == "x" || v == "yes" || v == "on" {
+			return checked, nil
+		}
+		return "", nil
+	}
+	switch {
+	case e.Amount:
+		r, ok := new(big.Rat).SetString(value)
+		if !ok {
+			return "", fmt.Errorf("value is not an amount")
+		}
+		return roundDollars(r), nil
+	case e.DigitsOnly:
+		return strings.Map(func(c rune) rune {
+			if '0' <= c && c <= '9' {
+				return c
+			}
+			return -1
+		}, value), nil
+	}
+	return value, nil
+}
+
+// roundDollars rounds r to a whole number, half away from zero.
+func roundDollars(r *big.Rat) string {
+	abs := new(big.Rat).Abs(r)
+	abs.Add(abs, big.NewRat(1, 2))
+	whole := new(big.Int).Quo(abs.Num(), abs.Denom())
+	if r.Sign() < 0 && whole.Sign() != 0 {
+		whole.Neg(whole)
+	}
+	return whole.String()
+}
+
+// Import reads the mapped elements of MeF XML back to values keyed by
+// field ID. "X" marks a checkbox "true"; mapped checkboxes whose element is
+// absent are "false". Other values are read to their canonical form with
+// ParseFormattedValue. Elements the mapping does not name are ignored, and
+// namespaces are not compared.
+func Import(fa *annotation.FormAnnotation, m *Mapping, data []byte) (map[string]string, error) {
+	byPath := map[string]Entry{}
+	out := map[string]string{}
+	for _, e := range m.Entries {
+		field := fa.GetFieldByID(e.FieldID)
+		if field == nil {
+			return nil, fmt.Errorf("mef: mapping names unknown field %q", e.FieldID)
+		}
+		byPath[e.XPath] = e
+		if field.FieldType == annotation.FieldTypeCheckbox {
+			out[field.FieldID] = "false"
+		}
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var path []string
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mef: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			e, ok := byPath["/"+strings.Join(path, "/")]
+			path = path[:len(path)-1]
+			if !ok {
+				continue
+			}
+			field := fa.GetFieldByID(e.FieldID)
+			value := strings.TrimSpace(text.String())
+			if field.FieldType == annotation.FieldTypeCheckbox {
+				out[field.FieldID] = fmt.Sprint(strings.EqualFold(value, checked))
+				continue
+			}
+			canonical, err := field.ParseFormattedValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("mef: %s: %w", e.XPath, err)
+			}
+			out[field.FieldID] = canonical
+		}
+	}
+	return out, nil
+}
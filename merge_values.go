@@ -0,0 +1,192 @@
+package annotation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValueChange is a field whose filled value differs between two annotations.
+type ValueChange struct {
+	FieldID string `json:"field_id"`
+	Old     string `json:"old"`
+	New     string `json:"new"`
+}
+
+// ValueConflict is a field, or a whole radio group, that both sides of a
+// three-way merge changed to different values. Base, Mine and Theirs hold
+// the field value, or for a group the ID of the checked member.
+type ValueConflict struct {
+	FieldID string `json:"field_id,omitempty"`
+	GroupID string `json:"group_id,omitempty"`
+	Base    string `json:"base"`
+	Mine    string `json:"mine"`
+	Theirs  string `json:"theirs"`
+}
+
+// DiffValues lists the fields whose values differ from a to b, ordered by
+// field ID. Checkbox values are compared by whether they are checked, so "X"
+// and "true" are the same value. Fields present in only one annotation are
+// compared against an empty value.
+func DiffValues(a, b *FormAnnotation) []ValueChange {
+	av, bv := valuesByID(a), valuesByID(b)
+	ids := map[string]bool{}
+	for id := range av {
+		ids[id] = true
+	}
+	for id := range bv {
+		ids[id] = true
+	}
+	var changes []ValueChange
+	for _, id := range sortedKeys(ids) {
+		if !sameValue(a.GetFieldByID(id), b.GetFieldByID(id), av[id], bv[id]) {
+			changes = append(changes, ValueChange{FieldID: id, Old: av[id], New: bv[id]})
+		}
+	}
+	return changes
+}
+
+// MergeValues performs a three-way merge of filled values. Changes made on
+// only one side since base are applied; the same change on both sides is
+// applied once; different changes to the same field conflict, keep the base
+// value, and are returned for resolution. Radio groups merge by their
+// selected member rather than box by box. The three annotations must share
+// base's structure. For non-conflicting changes the result does not depend on
+// which side is passed as mine and which as theirs.
+func MergeValues(base, mine, theirs *FormAnnotation) (*FormAnnotation, []ValueConflict, error) {
+	for _, other := range []*FormAnnotation{mine, theirs} {
+		if err := sameFieldSet(base, other); err != nil {
+			return nil, nil, err
+		}
+	}
+	merged := base.Clone()
+	var conflicts []ValueConflict
+
+	grouped := map[string]bool{}
+	for _, group := range base.FieldGroups {
+		if group.GroupType != GroupTypeRadio && group.GroupType != GroupTypeYesNo {
+			continue
+		}
+		for _, id := range group.FieldIDs {
+			if f := base.GetFieldByID(id); f != nil {
+				grouped[f.FieldID] = true
+			}
+		}
+		b, m, t := selection(base, group), selection(mine, group), selection(theirs, group)
+		pick := func(id string) string { return min(valueOf(mine, id), valueOf(theirs, id)) }
+		switch {
+		case m == t:
+		case m == b:
+			pick = func(id string) string { return valueOf(theirs, id) }
+		case t == b:
+			pick = func(id string) string { return valueOf(mine, id) }
+		default:
+			conflicts = append(conflicts, ValueConflict{GroupID: group.GroupID, Base: b, Mine: m, Theirs: t})
+			continue
+		}
+		for _, id := range group.FieldIDs {
+			if f := merged.GetFieldByID(id); f != nil {
+				f.Value = pick(id)
+			}
+		}
+	}
+
+	mineChanges := changesByID(DiffValues(base, mine))
+	theirChanges := changesByID(DiffValues(base, theirs))
+	for _, id := range sortedKeys(unionKeys(mineChanges, theirChanges)) {
+		if grouped[id] {
+			continue
+		}
+		field := merged.GetFieldByID(id)
+		m, mineChanged := mineChanges[id]
+		t, theirsChanged := theirChanges[id]
+		switch {
+		case mineChanged && !theirsChanged:
+			field.Value = m.New
+		case theirsChanged && !mineChanged:
+			field.Value = t.New
+		case sameValue(field, field, m.New, t.New):
+			// Both sides made the same change; prefer the lexically smaller
+			// spelling so the result is independent of argument order.
+			field.Value = min(m.New, t.New)
+		default:
+			conflicts = append(conflicts, ValueConflict{FieldID: id, Base: m.Old, Mine: m.New, Theirs: t.New})
+		}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].GroupID+"\x00"+conflicts[i].FieldID < conflicts[j].GroupID+"\x00"+conflicts[j].FieldID
+	})
+	return merged, conflicts, nil
+}
+
+// selection returns the checked members of a group, comma-joined in sorted order.
+func selection(fa *FormAnnotation, group FieldGroup) string {
+	var checked []string
+	for _, id := range group.FieldIDs {
+		if isChecked(valueOf(fa, id)) {
+			checked = append(checked, id)
+		}
+	}
+	sort.Strings(checked)
+	return strings.Join(checked, ",")
+}
+
+func sameFieldSet(a, b *FormAnnotation) error {
+	av, bv := map[string]bool{}, map[string]bool{}
+	for _, f := range a.Fields() {
+		av[f.FieldID] = true
+	}
+	for _, f := range b.Fields() {
+		bv[f.FieldID] = true
+		if !av[f.FieldID] {
+			return fmt.Errorf("field %q is not in the base annotation", f.FieldID)
+		}
+	}
+	for id := range av {
+		if !bv[id] {
+			return fmt.Errorf("field %q is missing from a merged annotation", id)
+		}
+	}
+	return nil
+}
+
+func sameValue(fa, fb *Field, a, b string) bool {
+	if (fa != nil && fa.FieldType == FieldTypeCheckbox) || (fb != nil && fb.FieldType == FieldTypeCheckbox) {
+		return isChecked(a) == isChecked(b)
+	}
+	return a == b
+}
+
+func valuesByID(fa *FormAnnotation) map[string]string {
+	values := map[string]string{}
+	for _, f := range fa.Fields() {
+		values[f.FieldID] = f.Value
+	}
+	return values
+}
+
+func valueOf(fa *FormAnnotation, id string) string {
+	if f := fa.GetFieldByID(id); f != nil {
+		return f.Value
+	}
+	return ""
+}
+
+func changesByID(changes []ValueChange) map[string]ValueChange {
+	m := make(map[string]ValueChange, len(changes))
+	for _, c := range changes {
+		m[c.FieldID] = c
+	}
+	return m
+}
+
+func unionKeys[V any](a, b map[string]V) map[string]bool {
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
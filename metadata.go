@@ -0,0 +1,158 @@
+package annotation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// MetadataRules configures ValidateMetadata. Zero values disable the
+// corresponding check, except that a form ID, a positive year, a form name
+// and a usable page size are always required.
+type MetadataRules struct {
+	// FormIDPattern is a regular expression the form ID must match, such as
+	// `^f\d+[a-z]*(_[a-z]+)?$` for "f1040" or "f1040_sb".
+	FormIDPattern string
+	MinYear       int
+	MaxYear       int
+	// Registry, when set, must know the form ID; the form name and page
+	// count must then agree with its entry.
+	Registry *Registry
+}
+
+// Metadata validation issue codes.
+const (
+	MetadataMissingFormID   = "missing_form_id"
+	MetadataFormIDPattern   = "form_id_pattern"
+	MetadataInvalidYear     = "invalid_year"
+	MetadataMissingFormName = "missing_form_name"
+	MetadataUnknownForm     = "unknown_form"
+	MetadataNameMismatch    = "form_name_mismatch"
+	MetadataPageCount       = "page_count_mismatch"
+	MetadataPageSize        = "invalid_page_size"
+	MetadataProvenance      = "invalid_provenance"
+)
+
+// ValidateMetadata checks the form metadata for completeness and, when
+// rules carries a registry, for conformance with the organization's catalog.
+func (fa *FormAnnotation) ValidateMetadata(rules MetadataRules) *ValidationReport {
+	report := &ValidationReport{}
+	fail := func(code, format string, args ...any) {
+		report.add(ValidationIssue{Code: code, Severity: SeverityError, Message: fmt.Sprintf(format, args...)})
+	}
+	md := fa.FormMetadata
+
+	switch {
+	case md.FormID == "":
+		fail(MetadataMissingFormID, "form_id is empty")
+	case rules.FormIDPattern != "":
+		re, err := compilePattern(rules.FormIDPattern)
+		if err != nil {
+			fail(RuleInvalid, "form ID pattern: %v", err)
+		} else if !re.MatchString(md.FormID) {
+			fail(MetadataFormIDPattern, "form_id %q does not match %s", md.FormID, rules.FormIDPattern)
+		}
+	}
+
+	switch {
+	case md.Year <= 0:
+		fail(MetadataInvalidYear, "year is not set")
+	case rules.MinYear != 0 && md.Year < rules.MinYear, rules.MaxYear != 0 && md.Year > rules.MaxYear:
+		fail(MetadataInvalidYear, "year %d is outside the supported range %s", md.Year, yearRange(rules.MinYear, rules.MaxYear))
+	}
+
+	if md.FormName == "" {
+		fail(MetadataMissingFormName, "form_name is empty")
+	}
+
+	if rules.Registry != nil && md.FormID != "" {
+		entry, ok := rules.Registry.Lookup(md.FormID)
+		switch {
+		case !ok:
+			fail(MetadataUnknownForm, "form_id %q is not in the registry", md.FormID)
+		default:
+			if md.FormName != "" && entry.Name != "" && md.FormName != entry.Name {
+				fail(MetadataNameMismatch, "form_name %q does not match the registry name %q", md.FormName, entry.Name)
+			}
+			if entry.PageCount != 0 && md.PageCount != entry.PageCount {
+				fail(MetadataPageCount, "page_count is %d, the registry expects %d", md.PageCount, entry.PageCount)
+			}
+		}
+	}
+
+	if md.PageSize.Unit == "" {
+		fail(MetadataPageSize, "page size unit is not set")
+	} else if _, ok := pageInPoints(md.PageSize); !ok {
+		fail(MetadataPageSize, "page size unit %q is not a known unit", md.PageSize.Unit)
+	} else if md.PageSize.Width <= 0 || md.PageSize.Height <= 0 {
+		fail(MetadataPageSize, "page size %gx%g is not positive", md.PageSize.Width, md.PageSize.Height)
+	}
+
+	switch {
+	case md.Revision < 0:
+		fail(MetadataProvenance, "revision %d is negative", md.Revision)
+	case md.SourcePDFHash != "" && !validContentHash(md.SourcePDFHash):
+		fail(MetadataProvenance, "source_pdf_hash %q is not a sha256:<hex> digest", md.SourcePDFHash)
+	case !md.CreatedAt.IsZero() && md.UpdatedAt.Before(md.CreatedAt):
+		fail(MetadataProvenance, "updated_at %s is before created_at %s", md.UpdatedAt.Format(time.RFC3339), md.CreatedAt.Format(time.RFC3339))
+	}
+	return report
+}
+
+// validContentHash reports whether s has the form ContentHash returns.
+func validContentHash(s string) bool {
+	digest, ok := strings.CutPrefix(s, "sha256:")
+	if !ok || len(digest) != 64 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+func yearRange(lo, hi int) string {
+	switch {
+	case lo == 0:
+		return fmt.Sprintf("up to %d", hi)
+	case hi == 0:
+		return fmt.Sprintf("from %d", lo)
+	}
+	return fmt.Sprintf("%d-%d", lo, hi)
+}
+
+// Registry is an organization's catalog of known forms keyed by form ID.
+type Registry struct {
+	Forms map[string]RegistryEntry `json:"forms"`
+}
+
+// RegistryEntry describes a known form. A zero PageCount is not checked.
+type RegistryEntry struct {
+	Name      string `json:"name"`
+	PageCount int    `json:"page_count,omitempty"`
+}
+
+// Lookup returns the registry entry for formID.
+func (r *Registry) Lookup(formID string) (RegistryEntry, bool) {
+	entry, ok := r.Forms[formID]
+	return entry, ok
+}
+
+// ParseRegistry decodes a registry from JSON.
+func ParseRegistry(data []byte) (*Registry, error) {
+	var r Registry
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// LoadRegistryFromFile loads a registry from a JSON file.
+func LoadRegistryFromFile(filename string) (*Registry, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRegistry(data)
+}
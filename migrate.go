@@ -0,0 +1,262 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// MigrationFunc rewrites a document decoded as generic JSON, with numbers
+// as json.Number, from one schema version's shape to the next. It runs on
+// every document older than its target version that is loaded, and must
+// leave a document already in the new shape alone.
+type MigrationFunc func(doc map[string]any) error
+
+type migration struct {
+	from, to SchemaVersion
+	fn       MigrationFunc
+}
+
+var (
+	migrationsMu sync.RWMutex
+	migrations   = []migration{
+		{SchemaV1, SchemaV2, migrateSegmentLengths},
+		{SchemaV2, SchemaV3, migrateCheckStyleString},
+	}
+)
+
+// RegisterMigration adds a step that brings documents of schema version
+// from to version to. Steps from the same version run in registration
+// order, after the package's own. It panics unless from is a version and
+// to a later one no newer than CurrentSchemaVersion.
+func RegisterMigration(from, to SchemaVersion, fn MigrationFunc) {
+	if fn == nil || from < SchemaV1 || to <= from || to > CurrentSchemaVersion {
+		panic(fmt.Sprintf("annotation: RegisterMigration needs a migration from a version to a later one up to %d, got %d to %d",
+			CurrentSchemaVersion, from, to))
+	}
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	migrations = append(migrations, migration{from, to, fn})
+	sort.SliceStable(migrations, func(i, j int) bool { return migrations[i].from < migrations[j].from })
+}
+
+// documentVersion returns the schema version data declares, v1 when none.
+func documentVersion(data []byte) (SchemaVersion, error) {
+	var head struct {
+		FormMetadata struct {
+			SchemaVersion SchemaVersion `json:"schema_version"`
+		} `json:"form_metadata"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return 0, err
+	}
+	if head.FormMetadata.SchemaVersion == 0 {
+		return SchemaV1, nil
+	}
+	return head.FormMetadata.SchemaVersion, nil
+}
+
+// migrate brings data from schema version v to the current version. It
+// returns data itself when no step changed anything, so that decoding
+// errors can still be located in the input.
+func migrate(data []byte, v SchemaVersion) ([]byte, bool, error) {
+	if v >= CurrentSchemaVersion {
+		return data, false, nil
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false, err
+	}
+	if doc == nil {
+		return data, false, nil
+	}
+	before, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	migrationsMu.RLock()
+	steps := append([]migration(nil), migrations...)
+	migrationsMu.RUnlock()
+	for v < CurrentSchemaVersion {
+		next := v
+		for _, m := range steps {
+			if m.from != v {
+				continue
+			}
+			if err := m.fn(doc); err != nil {
+				return nil, false, fmt.Errorf("migrating schema version %d to %d: %w", m.from, m.to, err)
+			}
+			next = max(next, m.to)
+		}
+		if next == v {
+			return nil, false, fmt.Errorf("no migration from schema version %d", v)
+		}
+		v = next
+	}
+	after, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if bytes.Equal(before, after) {
+		return data, false, nil
+	}
+	return after, true, nil
+}
+
+// MigrateDocument upgrades an annotation document to CurrentSchemaVersion
+// without loading it, for rewriting stored files in bulk: the registered
+// migrations run and schema_version is updated, while everything else,
+// unknown keys included, is kept. The result is indented, with keys in
+// sorted order. A document already at the current version, or newer, is
+// returned as it is.
+func MigrateDocument(data []byte) ([]byte, error) {
+	v, err := documentVersion(data)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	if v >= CurrentSchemaVersion {
+		return data, nil
+	}
+	migrated, _, err := migrate(data, v)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	var doc map[string]any
+	dec := json.NewDecoder(bytes.NewReader(migrated))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	md, ok := doc["form_metadata"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("migrate: document has no form_metadata")
+	}
+	md["schema_version"] = CurrentSchemaVersion
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return out, nil
+}
+
+// eachFieldJSON calls fn with every field object of a generic document.
+func eachFieldJSON(doc map[string]any, fn func(field map[string]any) error) error {
+	pages, _ := doc["pages"].([]any)
+	for _, p := range pages {
+		page, _ := p.(map[string]any)
+		fields, _ := page["fields"].([]any)
+		for _, f := range fields {
+			if field, ok := f.(map[string]any); ok {
+				if err := fn(field); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// migrateSegmentLengths expands segments written as a flat array of
+// lengths into segment objects, dividing the field's box among them in
+// proportion to their lengths.
+func migrateSegmentLengths(doc map[string]any) error {
+	return eachFieldJSON(doc, func(field map[string]any) error {
+		segs, _ := field["segments"].([]any)
+		lengths := make([]int64, len(segs))
+		var total int64
+		for i, s := range segs {
+			n, ok := s.(json.Number)
+			if !ok {
+				return nil
+			}
+			length, err := n.Int64()
+			if err != nil || length < 1 {
+				return fmt.Errorf("field %v: segment length %v is not a positive integer", field["field_id"], s)
+			}
+			lengths[i], total = length, total+length
+		}
+		if total == 0 {
+			return nil
+		}
+		pos, _ := field["position"].(map[string]any)
+		x, _ := jsonFloat(pos["x"])
+		width, _ := jsonFloat(pos["width"])
+		out := make([]any, len(segs))
+		for i, length := range lengths {
+			w := width * float64(length) / float64(total)
+			seg := map[string]any{
+				"x":      x,
+				"y":      pos["y"],
+				"width":  w,
+				"height": pos["height"],
+			}
+			if unit, ok := pos["unit"]; ok {
+				seg["unit"] = unit
+			}
+			out[i] = map[string]any{"position": seg, "length": length}
+			x += w
+		}
+		field["segments"] = out
+		return nil
+	})
+}
+
+// defaultMarkSize is the mark size, in points, given to check styles that
+// were written as the mark type alone.
+const defaultMarkSize = 10.0
+
+// migrateCheckStyleString expands a check_style written as the mark type
+// alone into a check style object, with a mark that fits the box.
+func migrateCheckStyleString(doc map[string]any) error {
+	return eachFieldJSON(doc, func(field map[string]any) error {
+		mark, ok := field["check_style"].(string)
+		if !ok {
+			return nil
+		}
+		size := defaultMarkSize
+		pos, _ := field["position"].(map[string]any)
+		w, errW := jsonFloat(pos["width"])
+		h, errH := jsonFloat(pos["height"])
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			size = min(size, w, h)
+		}
+		field["check_style"] = map[string]any{"mark_type": mark, "mark_size": size, "mark_weight": "normal"}
+		return nil
+	})
+}
+
+func jsonFloat(v any) (float64, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a number", v)
+	}
+	return n.Float64()
+}
+
+// migratePage brings one page of a schema version v document to the
+// current version, for StreamPages. Steps see the page as the only page
+// of a document with empty metadata.
+func migratePage(raw json.RawMessage, v SchemaVersion) (json.RawMessage, bool, error) {
+	doc, err := json.Marshal(map[string]any{"form_metadata": map[string]any{}, "pages": []json.RawMessage{raw}})
+	if err != nil {
+		return nil, false, err
+	}
+	migrated, changed, err := migrate(doc, v)
+	if err != nil || !changed {
+		return raw, false, err
+	}
+	var out struct {
+		Pages []json.RawMessage `json:"pages"`
+	}
+	if err := json.Unmarshal(migrated, &out); err != nil {
+		return nil, false, err
+	}
+	if len(out.Pages) != 1 {
+		return nil, false, fmt.Errorf("migration left %d pages in a one-page document", len(out.Pages))
+	}
+	return out.Pages[0], true, nil
+}
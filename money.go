@@ -0,0 +1,136 @@
+package annotation
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Rounding modes.
+const (
+	// RoundHalfUp rounds halves away from zero, as IRS whole-dollar
+	// rounding does: 49 cents and less are dropped and 50 to 99 cents raise
+	// the amount to the next dollar. It is the default.
+	RoundHalfUp = "half_up"
+	// RoundHalfEven rounds halves to the even neighbor.
+	RoundHalfEven = "half_even"
+	// RoundDown drops the digits past the precision, toward zero.
+	RoundDown = "down"
+)
+
+// InvalidRounding is the issue code for a rounding policy with an unknown
+// mode or a negative number of places.
+const InvalidRounding = "invalid_rounding"
+
+// Rounding is how amounts are rounded: to Places decimal places by Mode.
+type Rounding struct {
+	// Places is the number of decimal places kept; zero rounds to whole
+	// dollars.
+	Places int `json:"places,omitempty"`
+	// Mode is one of the Round constants; RoundHalfUp when empty.
+	Mode string `json:"mode,omitempty"`
+}
+
+// Common rounding policies.
+var (
+	// WholeDollars is the IRS whole-dollar rounding.
+	WholeDollars = Rounding{}
+	// Cents keeps amounts to the cent.
+	Cents = Rounding{Places: 2}
+)
+
+// Round returns n rounded by the policy. An unknown mode rounds half up.
+func (r Rounding) Round(n *big.Rat) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(max(r.Places, 0))), nil)
+	scaled := new(big.Rat).Mul(n, new(big.Rat).SetInt(scale))
+	whole, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Sign() != 0 && r.Mode != RoundDown {
+		// Compare twice the remainder with the denominator to find which
+		// side of the half the dropped digits fall.
+		half := new(big.Int).Abs(rem)
+		half.Lsh(half, 1)
+		c := half.Cmp(scaled.Denom())
+		if c > 0 || c == 0 && (r.Mode != RoundHalfEven || whole.Bit(0) == 1) {
+			whole.Add(whole, big.NewInt(int64(rem.Sign())))
+		}
+	}
+	return new(big.Rat).SetFrac(whole, scale)
+}
+
+// SumFields adds the amounts of the given fields exactly, with empty values
+// counting as zero and formatted values such as "(1,234.50)" read as
+// DecimalValue reads them. Cents are kept while adding and only the total
+// is rounded, by FormMetadata.Rounding when it is set, as the IRS
+// instructions ask of whole-dollar filers. A field that is not found or
+// does not hold a decimal or integer is an error.
+func (fa *FormAnnotation) SumFields(ids ...string) (*big.Rat, error) {
+	total := new(big.Rat)
+	for _, id := range ids {
+		field := fa.GetFieldByID(id)
+		if field == nil {
+			return nil, fmt.Errorf("sum fields: no field with ID %q", id)
+		}
+		if field.Value == "" && (field.DataType == DataTypeDecimal || field.DataType == DataTypeInteger) {
+			continue
+		}
+		n, err := field.DecimalValue()
+		if err != nil {
+			return nil, fmt.Errorf("sum fields: %w", err)
+		}
+		total.Add(total, n)
+	}
+	if r := fa.FormMetadata.Rounding; r != nil {
+		total = r.Round(total)
+	}
+	return total, nil
+}
+
+// SetAmount stores n in a decimal or integer field in canonical form,
+// rounded by FormMetadata.Rounding when it is set, so that an amount is
+// written once, exactly, rather than through a float. Negative amounts are
+// stored with a minus and drawn as the field's NegativeFormat asks.
+// Read-only fields are refused.
+func (fa *FormAnnotation) SetAmount(fieldID string, n *big.Rat) error {
+	field := fa.GetFieldByID(fieldID)
+	if field == nil {
+		return fmt.Errorf("set amount: no field with ID %q", fieldID)
+	}
+	if field.DataType != DataTypeDecimal && field.DataType != DataTypeInteger {
+		return fmt.Errorf("set amount: field %q has data type %q, not decimal or integer", fieldID, field.DataType)
+	}
+	if field.ReadOnly {
+		return fmt.Errorf("set amount: field %q is read-only", fieldID)
+	}
+	if r := fa.FormMetadata.Rounding; r != nil {
+		n = r.Round(n)
+	}
+	value, err := canonicalValue(field, n)
+	if err == nil {
+		value, err = runFieldSetHooks(field, value)
+	}
+	if err != nil {
+		return fmt.Errorf("set amount: field %q: %w", fieldID, err)
+	}
+	field.Value = value
+	return nil
+}
+
+// checkRounding reports a rounding policy Round cannot apply as declared.
+func (fa *FormAnnotation) checkRounding() []ValidationIssue {
+	r := fa.FormMetadata.Rounding
+	if r == nil {
+		return nil
+	}
+	at := ValidationIssue{Code: InvalidRounding, Severity: SeverityError}
+	var issues []ValidationIssue
+	switch r.Mode {
+	case "", RoundHalfUp, RoundHalfEven, RoundDown:
+	default:
+		at.Message = fmt.Sprintf("rounding mode %q is not half_up, half_even or down", r.Mode)
+		issues = append(issues, at)
+	}
+	if r.Places < 0 {
+		at.Message = fmt.Sprintf("rounding to %d decimal places", r.Places)
+		issues = append(issues, at)
+	}
+	return issues
+}
@@ -0,0 +1,172 @@
+package annotation
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"slices"
+)
+
+// Normalize puts the annotation into its canonical form with the default
+// options, so that two documents with the same content serialize to the
+// same bytes. See NormalizeWithOptions.
+func (fa *FormAnnotation) Normalize() {
+	fa.NormalizeWithOptions(NormalizeOptions{})
+}
+
+// NormalizeOptions controls NormalizeWithOptions.
+type NormalizeOptions struct {
+	// SortFieldsByID orders each page's fields by ID instead of by position.
+	SortFieldsByID bool
+	// ReadingOrder orders each page's fields as ComputeReadingOrder reads
+	// them, with virtual fields last, instead of strictly by position.
+	ReadingOrder bool
+	// Precision is the number of decimal places field and segment positions
+	// are rounded to; the default is 6. A negative precision leaves them as
+	// they are.
+	Precision int
+}
+
+// NormalizeWithOptions puts the annotation's collections into their
+// canonical shape and order. Required collections (pages, each page's
+// fields, each group's field IDs) become empty slices rather than nil, and
+// optional ones (segments, field groups, expected options) become nil when
+// empty so they are omitted on output; marshaling applies these two rules
+// without modifying the annotation, and unmarshaling accepts null, [] or a
+// missing key for any collection. Pages are sorted by number, each page's
+// fields top-to-bottom then left-to-right, with ties broken by ID, and field
+// groups and their members by ID, and anchors by name. Positions are
+// rounded to opts.Precision to drop floating-point noise.
+func (fa *FormAnnotation) NormalizeWithOptions(opts NormalizeOptions) {
+	fa.InvalidateIndex()
+	precision := opts.Precision
+	if precision == 0 {
+		precision = 6
+	}
+	if fa.Pages == nil {
+		fa.Pages = []Page{}
+	}
+	slices.SortStableFunc(fa.Pages, func(a, b Page) int { return cmp.Compare(a.PageNumber, b.PageNumber) })
+	for i := range fa.Pages {
+		page := &fa.Pages[i]
+		if page.Fields == nil {
+			page.Fields = []Field{}
+		}
+		for j := range page.Fields {
+			f := &page.Fields[j]
+			if len(f.Segments) == 0 {
+				f.Segments = nil
+			}
+			if precision > 0 {
+				roundPosition(&f.Position, precision)
+				for k := range f.Segments {
+					roundPosition(&f.Segments[k].Position, precision)
+				}
+			}
+		}
+		if opts.SortFieldsByID {
+			slices.SortStableFunc(page.Fields, func(a, b Field) int { return cmp.Compare(a.FieldID, b.FieldID) })
+		} else {
+			fa.sortByPosition(page.Fields)
+			if opts.ReadingOrder {
+				fa.sortByReading(page.Fields)
+			}
+		}
+		if len(page.Anchors) == 0 {
+			page.Anchors = nil
+		}
+		slices.SortStableFunc(page.Anchors, func(a, b Anchor) int { return cmp.Compare(a.Name, b.Name) })
+	}
+	if len(fa.FieldGroups) == 0 {
+		fa.FieldGroups = nil
+	}
+	slices.SortStableFunc(fa.FieldGroups, func(a, b FieldGroup) int { return cmp.Compare(a.GroupID, b.GroupID) })
+	for i := range fa.FieldGroups {
+		if fa.FieldGroups[i].FieldIDs == nil {
+			fa.FieldGroups[i].FieldIDs = []string{}
+		}
+		slices.Sort(fa.FieldGroups[i].FieldIDs)
+		if len(fa.FieldGroups[i].ExpectedOptions) == 0 {
+			fa.FieldGroups[i].ExpectedOptions = nil
+		}
+	}
+}
+
+// sortByPosition orders fields by the top edge of their reading position,
+// from the top of the page, then by left edge and ID. Positions are
+// compared in points; one in an unknown unit is compared as written.
+func (fa *FormAnnotation) sortByPosition(fields []Field) {
+	origin, unit := fa.origin(), fa.FormMetadata.PageSize.Unit
+	key := func(f *Field) (float64, float64) {
+		p := readingPosition(f)
+		if pt, ok := positionInPoints(p, unit); ok {
+			p = pt
+		}
+		if origin == OriginBottomLeft {
+			return -(p.Y + p.Height), p.X
+		}
+		return p.Y, p.X
+	}
+	slices.SortStableFunc(fields, func(a, b Field) int {
+		ay, ax := key(&a)
+		by, bx := key(&b)
+		return cmp.Or(cmp.Compare(ay, by), cmp.Compare(ax, bx), cmp.Compare(a.FieldID, b.FieldID))
+	})
+}
+
+// sortByReading orders fields as ComputeReadingOrder does with the default
+// options, keeping the order of the virtual fields it leaves out and
+// putting them last.
+func (fa *FormAnnotation) sortByReading(fields []Field) {
+	placed := map[*Field]bool{}
+	var sorted []Field
+	for _, b := range xyCut(fa.tabBoxes(fields), defaultMinGutter, defaultRowOverlap) {
+		placed[b.f] = true
+		sorted = append(sorted, *b.f)
+	}
+	for i := range fields {
+		if !placed[&fields[i]] {
+			sorted = append(sorted, fields[i])
+		}
+	}
+	copy(fields, sorted)
+}
+
+func roundPosition(p *Position, precision int) {
+	scale := math.Pow10(precision)
+	for _, v := range []*float64{&p.X, &p.Y, &p.Width, &p.Height} {
+		if *v = math.Round(*v*scale) / scale; *v == 0 {
+			*v = 0 // not -0
+		}
+	}
+}
+
+// MarshalJSON emits pages as an array even when there are none.
+func (fa FormAnnotation) MarshalJSON() ([]byte, error) {
+	type formAnnotation FormAnnotation
+	out := formAnnotation(fa)
+	if out.Pages == nil {
+		out.Pages = []Page{}
+	}
+	return json.Marshal(out)
+}
+
+// MarshalJSON emits fields as an array even when the page has none.
+func (p Page) MarshalJSON() ([]byte, error) {
+	type page Page
+	out := page(p)
+	if out.Fields == nil {
+		out.Fields = []Field{}
+	}
+	return json.Marshal(out)
+}
+
+// MarshalJSON emits field IDs as an array even when the group is empty.
+func (g FieldGroup) MarshalJSON() ([]byte, error) {
+	type fieldGroup FieldGroup
+	out := fieldGroup(g)
+	if out.FieldIDs == nil {
+		out.FieldIDs = []string{}
+	}
+	return json.Marshal(out)
+}
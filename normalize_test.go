@@ -0,0 +1,63 @@
+package annotation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCollectionShapesGolden reads the three shapes collections have been
+// written in, null, [] and a missing key, and checks that each marshals,
+// before and after Normalize, to the same golden output: required
+// collections as arrays and optional ones omitted.
+func TestCollectionShapesGolden(t *testing.T) {
+	for _, shape := range []string{"null", "empty", "missing"} {
+		t.Run(shape, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "collections", shape+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			fa, err := FromJSON(string(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(fa, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden(t, "collections/golden.json", append(got, '\n'))
+
+			fa.Normalize()
+			normalized, err := json.MarshalIndent(fa, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden(t, "collections/golden.json", append(normalized, '\n'))
+		})
+	}
+}
+
+func TestEmptyAnnotationMarshalsRequiredArrays(t *testing.T) {
+	for _, fa := range []*FormAnnotation{{}, {Pages: []Page{{PageNumber: 1}}}} {
+		data, err := json.Marshal(fa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := doc["pages"].([]any); !ok {
+			t.Errorf("%s: pages is not an array", data)
+		}
+		if _, ok := doc["field_groups"]; ok {
+			t.Errorf("%s: empty field_groups is not omitted", data)
+		}
+		for _, p := range doc["pages"].([]any) {
+			if _, ok := p.(map[string]any)["fields"].([]any); !ok {
+				t.Errorf("%s: fields is not an array", data)
+			}
+		}
+	}
+}
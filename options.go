@@ -0,0 +1,205 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GroupTypeRadio marks a group whose members are mutually exclusive options.
+const GroupTypeRadio = "radio"
+
+// Option group issue codes.
+const (
+	GroupOptionsUndeclared = "group_options_undeclared"
+	GroupOptionCount       = "group_option_count"
+	MissingOptionCode      = "missing_option_code"
+	UnexpectedOptionCode   = "unexpected_option_code"
+	DuplicateOptionCode    = "duplicate_option_code"
+	MissingOption          = "missing_option"
+)
+
+// valuePath is where an option group's selected code is read and written.
+func (g *FieldGroup) valuePath() string {
+	if g.FieldValue != "" {
+		return g.FieldValue
+	}
+	return g.GroupID
+}
+
+// optionGroup reports whether the group is an exclusive group with declared options.
+func (g *FieldGroup) optionGroup() bool {
+	return g.GroupType == GroupTypeRadio && len(g.ExpectedOptions) > 0
+}
+
+// checkGroupOptions verifies that every exclusive group declares its options
+// and that its members cover them exactly, one member per option.
+func (fa *FormAnnotation) checkGroupOptions() []ValidationIssue {
+	var issues []ValidationIssue
+	add := func(code string, severity Severity, group, field, format string, args ...any) {
+		issues = append(issues, ValidationIssue{
+			Code:     code,
+			Severity: severity,
+			GroupID:  group,
+			FieldID:  field,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+	for _, g := range fa.FieldGroups {
+		if g.GroupType != GroupTypeRadio {
+			continue
+		}
+		if len(g.ExpectedOptions) == 0 {
+			add(GroupOptionsUndeclared, SeverityWarning, g.GroupID, "",
+				"exclusive group declares no expected_options and cannot be checked for completeness")
+			continue
+		}
+		if len(g.FieldIDs) != len(g.ExpectedOptions) {
+			add(GroupOptionCount, SeverityError, g.GroupID, "",
+				"group has %d members for %d expected options", len(g.FieldIDs), len(g.ExpectedOptions))
+		}
+		expected := map[string]bool{}
+		for _, code := range g.ExpectedOptions {
+			expected[code] = true
+		}
+		covered := map[string]string{}
+		for _, id := range g.FieldIDs {
+			field := fa.GetFieldByID(id)
+			if field == nil {
+				continue // reported as an unknown group member
+			}
+			switch code := field.OptionCode; {
+			case code == "":
+				add(MissingOptionCode, SeverityError, g.GroupID, field.FieldID, "member has no option_code")
+			case !expected[code]:
+				add(UnexpectedOptionCode, SeverityError, g.GroupID, field.FieldID,
+					"option_code %q is not one of %s", code, strings.Join(g.ExpectedOptions, ", "))
+			case covered[code] != "":
+				add(DuplicateOptionCode, SeverityError, g.GroupID, field.FieldID,
+					"option_code %q is also used by %q", code, covered[code])
+			default:
+				covered[code] = field.FieldID
+			}
+		}
+		for _, code := range g.ExpectedOptions {
+			if covered[code] == "" {
+				add(MissingOption, SeverityError, g.GroupID, "", "no member represents option %q", code)
+			}
+		}
+	}
+	return issues
+}
+
+// fillYesNo answers a yes/no group from the value at its path in data.
+func (fa *FormAnnotation) fillYesNo(g *FieldGroup, data map[string]any, opts FillOptions, report *FillReport) {
+	raw, ok := lookupPath(data, g.valuePath())
+	if !ok {
+		return
+	}
+	state, ok := yesNoFromData(raw)
+	if !ok {
+		report.add(FillIssue{
+			Code:     FillUnsupportedValue,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("group %q: %s is not a yes/no answer", g.GroupID, fa.groupValue(g, jsonText(raw))),
+		})
+		return
+	}
+	if err := fa.setYesNo(g.GroupID, state, opts, report); err != nil {
+		report.add(FillIssue{Code: FillUnsupportedValue, Severity: SeverityError, Message: err.Error()})
+	}
+}
+
+// extractOptionGroups writes the option code of each option group's checked
+// member, and the true, false or null answer of each yes/no group, at the
+// group's value path and returns the member field IDs, whose individual
+// values are then left out of the extraction.
+func (fa *FormAnnotation) extractOptionGroups(out map[string]any) (map[string]bool, error) {
+	members := map[string]bool{}
+	for _, g := range fa.FieldGroups {
+		if g.GroupType == GroupTypeYesNo {
+			yes, no, err := fa.yesNoMembers(g.GroupID)
+			if err != nil {
+				return nil, err
+			}
+			members[yes.FieldID], members[no.FieldID] = true, true
+			state, err := fa.GetYesNo(g.GroupID)
+			if err != nil {
+				return nil, err
+			}
+			var answer any
+			if state != YesNoUnanswered {
+				answer = state == YesNoYes
+			}
+			if err := setPath(out, g.valuePath(), answer); err != nil {
+				return nil, fmt.Errorf("group %q: %w", g.GroupID, err)
+			}
+			continue
+		}
+		if !g.optionGroup() {
+			continue
+		}
+		var checked []*Field
+		for _, id := range g.FieldIDs {
+			field := fa.GetFieldByID(id)
+			if field == nil {
+				continue
+			}
+			members[field.FieldID] = true
+			if isChecked(field.Value) {
+				checked = append(checked, field)
+			}
+		}
+		switch len(checked) {
+		case 0:
+			continue
+		case 1:
+			if err := setPath(out, g.valuePath(), checked[0].OptionCode); err != nil {
+				return nil, fmt.Errorf("group %q: %w", g.GroupID, err)
+			}
+		default:
+			return nil, fmt.Errorf("group %q: %d options are checked", g.GroupID, len(checked))
+		}
+	}
+	return members, nil
+}
+
+// fillOptionGroups checks the member of each option group whose option code
+// appears at the group's value path in data, and clears the others.
+func (fa *FormAnnotation) fillOptionGroups(data map[string]any, opts FillOptions, report *FillReport) {
+	for _, g := range fa.FieldGroups {
+		if g.GroupType == GroupTypeYesNo {
+			fa.fillYesNo(&g, data, opts, report)
+			continue
+		}
+		if !g.optionGroup() {
+			continue
+		}
+		raw, ok := lookupPath(data, g.valuePath())
+		if !ok {
+			continue
+		}
+		code, ok := raw.(string)
+		if !ok {
+			continue // a nested map of per-option values is filled field by field
+		}
+		found := false
+		for _, id := range g.FieldIDs {
+			field, page := fa.fieldAndPage(id)
+			if field == nil {
+				continue
+			}
+			value := "false"
+			if code != "" && field.OptionCode == code {
+				value, found = "true", true
+			}
+			fa.place(field, page, value, opts, report)
+		}
+		if code != "" && !found {
+			report.add(FillIssue{
+				Code:     FillUnsupportedValue,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("group %q has no option %q", g.GroupID, fa.groupValue(&g, code)),
+			})
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package annotation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYesNoIssueMasksSensitiveGroup(t *testing.T) {
+	box := func(id, code string, x float64) Field {
+		return Field{FieldID: id, FieldType: FieldTypeCheckbox, DataType: DataTypeBoolean, GroupID: "consent",
+			OptionCode: code, Sensitive: true, Position: Position{X: x, Y: 36, Width: 10, Height: 10, Unit: "pt"}}
+	}
+	fa := &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages:        []Page{{PageNumber: 1, Fields: []Field{box("consent_yes", "yes", 36), box("consent_no", "no", 72)}}},
+		FieldGroups:  []FieldGroup{{GroupID: "consent", GroupType: GroupTypeYesNo, FieldIDs: []string{"consent_yes", "consent_no"}}},
+	}
+	report := fa.FillFromData(map[string]any{"consent": "123456789"}, FillOptions{})
+	if len(report.Issues) != 1 {
+		t.Fatalf("issues = %v, want one", report.Issues)
+	}
+	if msg := report.Issues[0].Message; strings.Contains(msg, "12345") {
+		t.Errorf("message %q is not masked", msg)
+	}
+}
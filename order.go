@@ -0,0 +1,59 @@
+package annotation
+
+import "sort"
+
+// defaultRowTolerance is how far apart, in position units, two fields' top
+// edges may be while still counting as the same row.
+const defaultRowTolerance = 4.0
+
+// readingOrder returns pointers to fields sorted top-to-bottom and, within a
+// row, left-to-right, with Y measured from origin. Virtual fields have no
+// place in reading order and are left out.
+func readingOrder(fields []Field, tolerance float64, origin Origin) []*Field {
+	var ordered []*Field
+	for _, row := range readingRows(fields, tolerance, origin) {
+		ordered = append(ordered, row...)
+	}
+	return ordered
+}
+
+// readingRows groups fields into rows in reading order. Fields whose Y
+// coordinates differ from the row's first field by no more than tolerance
+// share a row; each row is sorted left-to-right. A segmented field is
+// placed by its first segment. With a bottom-left origin rows are ordered
+// by their top edges, from the highest down.
+func readingRows(fields []Field, tolerance float64, origin Origin) [][]*Field {
+	ordered := renderedFields(fields)
+	depth := func(f *Field) float64 {
+		p := readingPosition(f)
+		if origin == OriginBottomLeft {
+			return -(p.Y + p.Height)
+		}
+		return p.Y
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return depth(ordered[i]) < depth(ordered[j])
+	})
+	var rows [][]*Field
+	for start := 0; start < len(ordered); {
+		end := start + 1
+		for end < len(ordered) && depth(ordered[end])-depth(ordered[start]) <= tolerance {
+			end++
+		}
+		row := ordered[start:end:end]
+		sort.SliceStable(row, func(i, j int) bool {
+			return readingPosition(row[i]).X < readingPosition(row[j]).X
+		})
+		rows = append(rows, row)
+		start = end
+	}
+	return rows
+}
+
+// readingPosition is where a field sits in reading order.
+func readingPosition(f *Field) Position {
+	if f.FieldType == FieldTypeSegmented && len(f.Segments) > 0 {
+		return f.Segments[0].Position
+	}
+	return f.Position
+}
@@ -0,0 +1,161 @@
+package annotation
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// Origin is the page corner Y is measured from. Top-left positions grow
+// down the page, as the annotation tool exports them; bottom-left
+// positions grow up it, as in PDF user space, and Y is the bottom edge of
+// the box.
+type Origin string
+
+const (
+	OriginTopLeft    Origin = "top_left"
+	OriginBottomLeft Origin = "bottom_left"
+)
+
+// InvalidCoordinateOrigin is the issue code for an unknown origin, or a
+// bottom-left origin combined with a margin frame.
+const InvalidCoordinateOrigin = "invalid_coordinate_origin"
+
+// origin returns the document's origin; top-left when none is declared.
+func (fa *FormAnnotation) origin() Origin {
+	if fa.FormMetadata.CoordinateOrigin == "" {
+		return OriginTopLeft
+	}
+	return fa.FormMetadata.CoordinateOrigin
+}
+
+// TransformOrigin flips every field and segment Y to be measured from the
+// target corner, using the page height, and records the new origin. Each
+// position keeps its unit, as FlipPosition keeps it; only Y changes.
+// Computed in decimal, the flip is exact for positions of up to 15
+// significant digits, so transforming back returns the original values
+// bit for bit. Documents with coordinate frames are refused; normalize
+// them with NormalizeToMediaBox first. On error the annotation is left
+// unchanged.
+func (fa *FormAnnotation) TransformOrigin(target Origin) error {
+	if target != OriginTopLeft && target != OriginBottomLeft {
+		return fmt.Errorf("unknown coordinate origin %q", target)
+	}
+	if fa.origin() == target {
+		return nil
+	}
+	if fa.HasCoordinateFrames() {
+		return fmt.Errorf("coordinate origin: document declares coordinate frames; normalize it to the media box first")
+	}
+	ps := fa.FormMetadata.PageSize
+	if _, ok := toPoints(1, ps.Unit); !ok {
+		return fmt.Errorf("coordinate origin: unknown page unit %q", ps.Unit)
+	}
+	out := fa.Clone()
+	flip := func(p *Position, size PageSize, fieldID string) error {
+		if *p == (Position{}) {
+			return nil
+		}
+		flipped, err := FlipPosition(*p, size)
+		if err != nil {
+			return fmt.Errorf("field %q: %w", fieldID, err)
+		}
+		*p = flipped
+		return nil
+	}
+	for i := range out.Pages {
+		size := out.PageSizeOf(out.Pages[i].PageNumber)
+		for j := range out.Pages[i].Fields {
+			f := &out.Pages[i].Fields[j]
+			if f.IsVirtual() {
+				continue
+			}
+			if err := flip(&f.Position, size, f.FieldID); err != nil {
+				return fmt.Errorf("coordinate origin: %w", err)
+			}
+			for s := range f.Segments {
+				if err := flip(&f.Segments[s].Position, size, f.FieldID); err != nil {
+					return fmt.Errorf("coordinate origin: %w", err)
+				}
+			}
+		}
+	}
+	out.FormMetadata.CoordinateOrigin = target
+	if target == OriginTopLeft {
+		out.FormMetadata.CoordinateOrigin = ""
+	}
+	*fa = *out
+	return nil
+}
+
+// FlipPosition returns p with Y measured from the other corner of a page
+// of size ps: a top-left position becomes a bottom-left one, as in PDF user
+// space, and a bottom-left one top-left. p keeps its unit; one without a
+// unit is taken to be in the page unit.
+func FlipPosition(p Position, ps PageSize) (Position, error) {
+	pageUnit := ps.Unit
+	if pageUnit == "" {
+		pageUnit = "pt"
+	}
+	unit := p.Unit
+	if unit == "" {
+		unit = pageUnit
+	}
+	k, ok := UnitOptions{}.scale(pageUnit, unit)
+	if !ok {
+		return Position{}, fmt.Errorf("unknown page unit %q", ps.Unit)
+	}
+	if _, ok := toPoints(1, unit); !ok {
+		return Position{}, fmt.Errorf("unknown unit %q", p.Unit)
+	}
+	p.Y = flipY(roundUnit(ps.Height*k), p.Y, p.Height)
+	return p, nil
+}
+
+// flipY returns pageHeight - y - height, computed on the shortest decimals
+// of its operands so that flipping twice is the identity.
+func flipY(pageHeight, y, height float64) float64 {
+	dec := func(v float64) *big.Rat {
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+		return r
+	}
+	r := dec(pageHeight)
+	r.Sub(r, dec(y))
+	r.Sub(r, dec(height))
+	f, _ := r.Float64()
+	return f
+}
+
+// topLeftPosition returns p measured from the top-left corner of a page
+// pageHeight points tall, keeping its own unit.
+func topLeftPosition(p Position, pageHeight float64, fallbackUnit string) (Position, bool) {
+	unit := p.Unit
+	if unit == "" {
+		unit = fallbackUnit
+	}
+	factor, ok := toPoints(1, unit)
+	if !ok || p == (Position{}) {
+		return p, ok
+	}
+	p.Y = pageHeight/factor - p.Y - p.Height
+	return p, true
+}
+
+// checkCoordinateOrigin reports an unknown origin, and a bottom-left
+// origin on a document with a margin frame, whose margins are measured
+// from the top.
+func (fa *FormAnnotation) checkCoordinateOrigin() []ValidationIssue {
+	at := ValidationIssue{Code: InvalidCoordinateOrigin, Severity: SeverityError}
+	switch fa.origin() {
+	case OriginTopLeft:
+		return nil
+	case OriginBottomLeft:
+		if !fa.HasCoordinateFrames() {
+			return nil
+		}
+		at.Message = "a bottom-left coordinate origin cannot be combined with a coordinate frame"
+	default:
+		at.Message = fmt.Sprintf("unknown coordinate origin %q", fa.FormMetadata.CoordinateOrigin)
+	}
+	return []ValidationIssue{at}
+}
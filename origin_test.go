@@ -0,0 +1,79 @@
+package annotation
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func positions(fa *FormAnnotation) []Position {
+	var out []Position
+	for _, f := range fa.Fields() {
+		out = append(out, f.Position)
+		for _, s := range f.Segments {
+			out = append(out, s.Position)
+		}
+	}
+	return out
+}
+
+func TestTransformOriginRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	coord := func(limit float64) float64 { return math.Round(rng.Float64()*limit*1e4) / 1e4 }
+	for _, pageUnit := range []string{"pt", "", "in", "mm"} {
+		fa := &FormAnnotation{FormMetadata: FormMetadata{FormID: "test", PageCount: 2,
+			PageSize: PageSize{Width: 8.5, Height: 11, Unit: pageUnit}}}
+		if pageUnit != "in" {
+			fa.FormMetadata.PageSize = PageSize{Width: 612, Height: 792, Unit: pageUnit}
+		}
+		for n := 1; n <= 2; n++ {
+			page := Page{PageNumber: n}
+			if n == 2 {
+				page.PageSize = &PageSize{Width: 842, Height: 595.276, Unit: "pt"}
+			}
+			for i, unit := range []string{"pt", "mm", "in", ""} {
+				page.Fields = append(page.Fields, Field{
+					FieldID: fmt.Sprintf("p%d_f%d", n, i), FieldType: FieldTypeText, DataType: DataTypeString,
+					Position: Position{X: coord(5), Y: coord(10), Width: coord(3), Height: coord(1), Unit: unit},
+					Segments: []Segment{{Position: Position{X: coord(5), Y: coord(10), Width: coord(1), Height: coord(1), Unit: unit}}},
+				})
+			}
+			fa.Pages = append(fa.Pages, page)
+		}
+		want := positions(fa)
+		if err := fa.TransformOrigin(OriginBottomLeft); err != nil {
+			t.Fatalf("page unit %q: %v", pageUnit, err)
+		}
+		flipped := positions(fa)
+		for i := range want {
+			if got := flipped[i]; got.X != want[i].X || got.Width != want[i].Width || got.Height != want[i].Height || got.Unit != want[i].Unit {
+				t.Errorf("page unit %q: position %d changed beyond Y: %+v, was %+v", pageUnit, i, got, want[i])
+			}
+		}
+		if err := fa.TransformOrigin(OriginTopLeft); err != nil {
+			t.Fatalf("page unit %q: %v", pageUnit, err)
+		}
+		for i, got := range positions(fa) {
+			if got != want[i] {
+				t.Errorf("page unit %q: position %d = %+v after two flips, want %+v", pageUnit, i, got, want[i])
+			}
+		}
+	}
+}
+
+func TestTransformOriginKeepsUnits(t *testing.T) {
+	fa := &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{{FieldID: "a", FieldType: FieldTypeText, DataType: DataTypeString,
+			Position: Position{X: 1, Y: 2, Width: 30, Height: 5, Unit: "mm"}}}}},
+	}
+	if err := fa.TransformOrigin(OriginBottomLeft); err != nil {
+		t.Fatal(err)
+	}
+	got := fa.GetFieldByID("a").Position
+	want := Position{X: 1, Y: roundUnit(792*25.4/72) - 2 - 5, Width: 30, Height: 5, Unit: "mm"}
+	if math.Abs(got.Y-want.Y) > 1e-9 || got.X != want.X || got.Unit != want.Unit {
+		t.Errorf("position = %+v, want %+v", got, want)
+	}
+}
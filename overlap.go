@@ -0,0 +1,82 @@
+package annotation
+
+import "sort"
+
+// Overlap is a pair of fields whose boxes intersect on a page.
+type Overlap struct {
+	Page   int    `json:"page"`
+	FieldA string `json:"field_a"`
+	FieldB string `json:"field_b"`
+	// Intersection is the shared rectangle, in points. For segmented fields
+	// it is the largest intersection between their segments.
+	Intersection Position `json:"intersection"`
+}
+
+// FindOverlaps reports every pair of fields on the same page whose boxes
+// intersect. Positions are compared in points, segmented fields by their
+// segments, and fields with zero-area or unconvertible positions are
+// skipped; Validate reports unknown units.
+func (fa *FormAnnotation) FindOverlaps() []Overlap {
+	return fa.FindOverlapsWithTolerance(0)
+}
+
+// FindOverlapsWithTolerance is FindOverlaps ignoring intersections no more
+// than tolerance points wide or high, such as rounding noise from the
+// annotation tool.
+func (fa *FormAnnotation) FindOverlapsWithTolerance(tolerance float64) []Overlap {
+	unit := fa.FormMetadata.PageSize.Unit
+	type box struct {
+		id    string
+		rects []Position
+	}
+	var out []Overlap
+	for _, page := range fa.Pages {
+		var boxes []box
+		for _, field := range page.Fields {
+			if field.IsVirtual() {
+				continue
+			}
+			positions := []Position{field.Position}
+			if field.FieldType == FieldTypeSegmented && len(field.Segments) > 0 {
+				positions = segmentPositions(&field)
+			}
+			b := box{id: field.FieldID}
+			for _, p := range positions {
+				if r, ok := positionInPoints(p, unit); ok && r.Width > 0 && r.Height > 0 {
+					b.rects = append(b.rects, r)
+				}
+			}
+			if len(b.rects) > 0 {
+				boxes = append(boxes, b)
+			}
+		}
+		sort.SliceStable(boxes, func(i, j int) bool { return boxes[i].id < boxes[j].id })
+		for i := range boxes {
+			for j := i + 1; j < len(boxes); j++ {
+				var best Position
+				for _, ra := range boxes[i].rects {
+					for _, rb := range boxes[j].rects {
+						if inter, ok := intersect(ra, rb); ok && inter.Width*inter.Height > best.Width*best.Height {
+							best = inter
+						}
+					}
+				}
+				if best.Width > tolerance && best.Height > tolerance {
+					out = append(out, Overlap{Page: page.PageNumber, FieldA: boxes[i].id, FieldB: boxes[j].id, Intersection: best})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// intersect returns the intersection of two rectangles in points.
+func intersect(a, b Position) (Position, bool) {
+	x, y := max(a.X, b.X), max(a.Y, b.Y)
+	w := min(a.X+a.Width, b.X+b.Width) - x
+	h := min(a.Y+a.Height, b.Y+b.Height) - y
+	if w <= 0 || h <= 0 {
+		return Position{}, false
+	}
+	return Position{X: x, Y: y, Width: w, Height: h, Unit: "pt"}, true
+}
@@ -0,0 +1,133 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RenderTarget names an output the annotation is rendered for.
+type RenderTarget string
+
+// Built-in render targets. Other targets, such as a specific printer, must
+// be declared in FormMetadata.RenderTargets.
+const (
+	RenderScreen RenderTarget = "screen"
+	RenderPrint  RenderTarget = "print"
+)
+
+// FieldRenderOverride adjusts a field for one render target. Nudges and the
+// font size delta are in points; the delta applies only to fields with an
+// explicit font size.
+type FieldRenderOverride struct {
+	DX            float64 `json:"dx,omitempty"`
+	DY            float64 `json:"dy,omitempty"`
+	FontSizeDelta float64 `json:"font_size_delta,omitempty"`
+	Hidden        bool    `json:"hidden,omitempty"`
+}
+
+// KnownRenderTarget reports whether target is built in or declared by the form.
+func (fa *FormAnnotation) KnownRenderTarget(target RenderTarget) bool {
+	return target == RenderScreen || target == RenderPrint || slices.Contains(fa.FormMetadata.RenderTargets, target)
+}
+
+// EffectiveField returns a copy of field with its overrides for target
+// applied, and false when the override hides the field. An empty target
+// returns the field as annotated.
+func (fa *FormAnnotation) EffectiveField(field *Field, target RenderTarget) (Field, bool) {
+	o, ok := field.Overrides[target]
+	if target == "" || !ok {
+		return *field, true
+	}
+	if o.Hidden {
+		return Field{}, false
+	}
+	out := field.Clone()
+	unit := fa.FormMetadata.PageSize.Unit
+	out.Position = nudge(out.Position, o, unit)
+	for i := range out.Segments {
+		out.Segments[i].Position = nudge(out.Segments[i].Position, o, unit)
+	}
+	if o.FontSizeDelta != 0 && out.Style != nil && out.Style.FontSize > 0 {
+		out.Style.FontSize += o.FontSizeDelta
+	}
+	return out, true
+}
+
+// nudge moves p by the override's point offsets, converted to p's unit.
+// Positions in an unknown unit are left in place.
+func nudge(p Position, o FieldRenderOverride, fallbackUnit string) Position {
+	unit := p.Unit
+	if unit == "" {
+		unit = fallbackUnit
+	}
+	factor, ok := toPoints(1, unit)
+	if !ok {
+		return p
+	}
+	p.X += o.DX / factor
+	p.Y += o.DY / factor
+	return p
+}
+
+// targetFields returns the rendered fields of a page as they appear on
+// target, leaving out hidden ones.
+func (fa *FormAnnotation) targetFields(fields []Field, target RenderTarget) []*Field {
+	rendered := renderedFields(fields)
+	if target == "" {
+		return rendered
+	}
+	out := make([]*Field, 0, len(rendered))
+	for _, field := range rendered {
+		if eff, ok := fa.EffectiveField(field, target); ok {
+			out = append(out, &eff)
+		}
+	}
+	return out
+}
+
+// checkOverrides reports overrides for undeclared targets and nudges that
+// move the field, or any of its segments, off the page.
+func (fa *FormAnnotation) checkOverrides(field *Field, pageNum int) []ValidationIssue {
+	if len(field.Overrides) == 0 {
+		return nil
+	}
+	targets := make([]RenderTarget, 0, len(field.Overrides))
+	for target := range field.Overrides {
+		targets = append(targets, target)
+	}
+	slices.Sort(targets)
+	page, pageOK := pageInPoints(fa.PageSizeOf(pageNum))
+	unit := fa.FormMetadata.PageSize.Unit
+	var issues []ValidationIssue
+	for _, target := range targets {
+		at := ValidationIssue{FieldID: field.FieldID, Page: pageNum, Severity: SeverityError}
+		if !fa.KnownRenderTarget(target) {
+			at.Code = UnknownRenderTarget
+			at.Message = fmt.Sprintf("override for undeclared render target %q", target)
+			issues = append(issues, at)
+			continue
+		}
+		eff, visible := fa.EffectiveField(field, target)
+		if !visible || !pageOK || field.IsVirtual() {
+			continue
+		}
+		abs := fa.absoluteField(&eff, pageNum)
+		rects := []Position{abs.Position}
+		if len(abs.Segments) > 0 {
+			rects = rects[:0]
+			for _, seg := range abs.Segments {
+				rects = append(rects, seg.Position)
+			}
+		}
+		for _, r := range rects {
+			p, ok := positionInPoints(r, unit)
+			if ok && (p.X < 0 || p.Y < 0 || p.X+p.Width > page.Width || p.Y+p.Height > page.Height) {
+				at.Code = OverrideOffPage
+				at.Message = fmt.Sprintf("override for %q moves the field off the page", target)
+				issues = append(issues, at)
+				break
+			}
+		}
+	}
+	return issues
+}
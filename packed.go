@@ -0,0 +1,296 @@
+package annotation
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// A packed library starts with a fixed header: the magic bytes, the format
+// version, the index length and the CRC-32 of the index. The JSON index
+// follows, then the documents, each with its own CRC-32 in the index so a
+// lookup verifies only the bytes it reads.
+const (
+	packedMagic      = "FAPK"
+	packedVersion    = 1
+	packedHeaderSize = 4 + 4 + 8 + 4
+)
+
+// DefaultPackedCacheSize is the number of decoded forms a PackedLibrary
+// keeps when PackedOptions.CacheSize is zero.
+const DefaultPackedCacheSize = 64
+
+// ErrPackedCorrupt is wrapped by errors reading a packed library whose
+// header, index or document bytes do not match their checksums.
+var ErrPackedCorrupt = errors.New("packed library is corrupt")
+
+type packedIndex struct {
+	Forms     []packedEntry `json:"forms"`
+	Templates []packedEntry `json:"templates,omitempty"`
+}
+
+// packedEntry locates one document; Offset is relative to the end of the index.
+type packedEntry struct {
+	FormID       string `json:"form_id,omitempty"`
+	Year         int    `json:"year,omitempty"`
+	FormName     string `json:"form_name,omitempty"`
+	TemplateName string `json:"template_name,omitempty"`
+	Offset       int64  `json:"offset"`
+	Length       int64  `json:"length"`
+	CRC          uint32 `json:"crc32"`
+}
+
+// CompileLibrary writes lib in the packed read-only format opened by
+// OpenPackedLibrary. Forms are written in Forms order and templates in
+// name order, so compiling the same library twice gives the same bytes.
+func CompileLibrary(lib *Library, w io.Writer) error {
+	var index packedIndex
+	var body bytes.Buffer
+	add := func(v any) (packedEntry, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return packedEntry{}, err
+		}
+		entry := packedEntry{Offset: int64(body.Len()), Length: int64(len(data)), CRC: crc32.ChecksumIEEE(data)}
+		body.Write(data)
+		return entry, nil
+	}
+	for _, fa := range lib.Forms() {
+		entry, err := add(fa)
+		if err != nil {
+			return fmt.Errorf("form %q year %d: %w", fa.FormMetadata.FormID, fa.FormMetadata.Year, err)
+		}
+		entry.FormID, entry.Year, entry.FormName = fa.FormMetadata.FormID, fa.FormMetadata.Year, fa.FormMetadata.FormName
+		index.Forms = append(index.Forms, entry)
+	}
+	for _, name := range lib.Templates() {
+		entry, err := add(lib.Template(name))
+		if err != nil {
+			return fmt.Errorf("template %q: %w", name, err)
+		}
+		entry.TemplateName = name
+		index.Templates = append(index.Templates, entry)
+	}
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, packedHeaderSize)
+	copy(header, packedMagic)
+	binary.BigEndian.PutUint32(header[4:], packedVersion)
+	binary.BigEndian.PutUint64(header[8:], uint64(len(indexData)))
+	binary.BigEndian.PutUint32(header[16:], crc32.ChecksumIEEE(indexData))
+	for _, part := range [][]byte{header, indexData, body.Bytes()} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PackedOptions controls OpenPackedLibrary.
+type PackedOptions struct {
+	// CacheSize bounds the decoded forms kept in memory, least recently
+	// used first out; DefaultPackedCacheSize when zero.
+	CacheSize int
+	Load      LoadOptions
+}
+
+// PackedLibrary is a read-only library backed by a packed file. Only the
+// index is read when it is opened; each form is read, verified and decoded
+// on first use and then served from a bounded cache. It is safe for
+// concurrent use.
+type PackedLibrary struct {
+	r         io.ReaderAt
+	closer    io.Closer
+	base      int64
+	opts      PackedOptions
+	forms     map[libraryKey]packedEntry
+	templates map[string]packedEntry
+
+	mu    sync.Mutex
+	cache map[string]*list.Element
+	lru   *list.List
+}
+
+type packedCached struct {
+	key   string
+	value any
+}
+
+// OpenPackedLibrary opens a file written by CompileLibrary. The caller must
+// Close the library when done.
+func OpenPackedLibrary(path string, opts PackedOptions) (*PackedLibrary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	lib, err := NewPackedLibrary(f, opts)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	lib.closer = f
+	return lib, nil
+}
+
+// NewPackedLibrary reads a packed library from r, for callers that hold the
+// bytes in memory or in a memory-mapped region.
+func NewPackedLibrary(r io.ReaderAt, opts PackedOptions) (*PackedLibrary, error) {
+	header := make([]byte, packedHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("%w: reading header: %v", ErrPackedCorrupt, err)
+	}
+	if string(header[:4]) != packedMagic {
+		return nil, fmt.Errorf("%w: not a packed library", ErrPackedCorrupt)
+	}
+	if v := binary.BigEndian.Uint32(header[4:]); v != packedVersion {
+		return nil, fmt.Errorf("packed library format version %d is not supported (want %d)", v, packedVersion)
+	}
+	indexLen := binary.BigEndian.Uint64(header[8:])
+	if indexLen > 1<<31 {
+		return nil, fmt.Errorf("%w: index length %d", ErrPackedCorrupt, indexLen)
+	}
+	indexData := make([]byte, indexLen)
+	if _, err := r.ReadAt(indexData, packedHeaderSize); err != nil {
+		return nil, fmt.Errorf("%w: reading index: %v", ErrPackedCorrupt, err)
+	}
+	if crc32.ChecksumIEEE(indexData) != binary.BigEndian.Uint32(header[16:]) {
+		return nil, fmt.Errorf("%w: index checksum mismatch", ErrPackedCorrupt)
+	}
+	var index packedIndex
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPackedCorrupt, err)
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = DefaultPackedCacheSize
+	}
+	lib := &PackedLibrary{
+		r:         r,
+		base:      packedHeaderSize + int64(indexLen),
+		opts:      opts,
+		forms:     make(map[libraryKey]packedEntry, len(index.Forms)),
+		templates: make(map[string]packedEntry, len(index.Templates)),
+		cache:     map[string]*list.Element{},
+		lru:       list.New(),
+	}
+	for _, e := range index.Forms {
+		lib.forms[libraryKey{e.FormID, e.Year}] = e
+	}
+	for _, e := range index.Templates {
+		lib.templates[e.TemplateName] = e
+	}
+	return lib, nil
+}
+
+// Close releases the file opened by OpenPackedLibrary.
+func (l *PackedLibrary) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// PackedForm is the index entry of one form in a packed library.
+type PackedForm struct {
+	FormID   string
+	Year     int
+	FormName string
+}
+
+// Forms lists the forms in the index, ordered by form ID and year, without
+// decoding any of them.
+func (l *PackedLibrary) Forms() []PackedForm {
+	forms := make([]PackedForm, 0, len(l.forms))
+	for _, e := range l.forms {
+		forms = append(forms, PackedForm{FormID: e.FormID, Year: e.Year, FormName: e.FormName})
+	}
+	sort.Slice(forms, func(i, j int) bool {
+		if forms[i].FormID != forms[j].FormID {
+			return forms[i].FormID < forms[j].FormID
+		}
+		return forms[i].Year < forms[j].Year
+	})
+	return forms
+}
+
+// Get returns the annotation for formID and year, or nil when the library
+// has none. The returned annotation is shared with the cache and must not
+// be modified; Clone it first.
+func (l *PackedLibrary) Get(formID string, year int) (*FormAnnotation, error) {
+	e, ok := l.forms[libraryKey{formID, year}]
+	if !ok {
+		return nil, nil
+	}
+	v, err := l.load(fmt.Sprintf("form\x00%s\x00%d", formID, year), e, func(data []byte) (any, error) {
+		return parseAnnotation(data, l.opts.Load)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("form %q year %d: %w", formID, year, err)
+	}
+	return v.(*FormAnnotation), nil
+}
+
+// Template returns the template called name, or nil.
+func (l *PackedLibrary) Template(name string) (*PageTemplate, error) {
+	e, ok := l.templates[name]
+	if !ok {
+		return nil, nil
+	}
+	v, err := l.load("template\x00"+name, e, func(data []byte) (any, error) {
+		var t PageTemplate
+		err := json.Unmarshal(data, &t)
+		return &t, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("template %q: %w", name, err)
+	}
+	return v.(*PageTemplate), nil
+}
+
+// load returns the cached document under key, or reads, verifies and
+// decodes entry e and caches the result.
+func (l *PackedLibrary) load(key string, e packedEntry, decode func([]byte) (any, error)) (any, error) {
+	l.mu.Lock()
+	if el, ok := l.cache[key]; ok {
+		l.lru.MoveToFront(el)
+		l.mu.Unlock()
+		return el.Value.(*packedCached).value, nil
+	}
+	l.mu.Unlock()
+
+	data := make([]byte, e.Length)
+	if _, err := l.r.ReadAt(data, l.base+e.Offset); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPackedCorrupt, err)
+	}
+	if crc32.ChecksumIEEE(data) != e.CRC {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrPackedCorrupt)
+	}
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.cache[key]; ok {
+		// Decoded concurrently; keep the first copy so callers share one.
+		l.lru.MoveToFront(el)
+		return el.Value.(*packedCached).value, nil
+	}
+	l.cache[key] = l.lru.PushFront(&packedCached{key: key, value: v})
+	for l.lru.Len() > l.opts.CacheSize {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.cache, oldest.Value.(*packedCached).key)
+	}
+	return v, nil
+}
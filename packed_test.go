@@ -0,0 +1,194 @@
+package annotation
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// packedFixture writes two years of the example form and a template to a
+// directory, and returns it with the packed bytes of the library loaded
+// from it.
+func packedFixture(t *testing.T) (string, []byte) {
+	t.Helper()
+	dir := exampleDir(t)
+	data, err := os.ReadFile(filepath.Join(dir, "f1040.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := strings.Replace(string(data), `"year": 2024`, `"year": 2023`, 1)
+	template := `{"template_name": "header", "unit": "pt", "fields": [{"field_id": "name", "field_type": "text",
+		"data_type": "string", "position": {"x": 0, "y": 0, "width": 200, "height": 18}}]}`
+	for name, content := range map[string]string{"f1040_2023.json": older, "header.template.json": template} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lib, err := LoadLibraryStore(context.Background(), DirStore{Dir: dir}, "", LibraryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := CompileLibrary(lib, &buf); err != nil {
+		t.Fatal(err)
+	}
+	return dir, buf.Bytes()
+}
+
+// TestPackedLibraryRoundTrip checks that every form and template read from
+// a packed library equals the one loaded from its original file.
+func TestPackedLibraryRoundTrip(t *testing.T) {
+	dir, data := packedFixture(t)
+	path := filepath.Join(t.TempDir(), "library.fapk")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	packed, err := OpenPackedLibrary(path, PackedOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer packed.Close()
+
+	want := []PackedForm{{"IRS-1040", 2023, "U.S. Individual Income Tax Return"}, {"IRS-1040", 2024, "U.S. Individual Income Tax Return"}}
+	if got := packed.Forms(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Forms = %v, want %v", got, want)
+	}
+	for file, year := range map[string]int{"f1040.json": 2024, "f1040_2023.json": 2023} {
+		original, err := LoadFile(context.Background(), filepath.Join(dir, file), LoadOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := packed.Get("IRS-1040", year)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, original) {
+			t.Errorf("packed %s differs from the original file", file)
+		}
+	}
+	original, err := LoadTemplateFromStore(context.Background(), DirStore{Dir: dir}, "header.template.json", LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := packed.Template("header"); err != nil || !reflect.DeepEqual(got, original) {
+		t.Errorf("packed template = %v, %v, want %v", got, err, original)
+	}
+	if got, err := packed.Get("IRS-1040", 1999); got != nil || err != nil {
+		t.Errorf("Get of a missing form = %v, %v", got, err)
+	}
+	if got, err := packed.Template("footer"); got != nil || err != nil {
+		t.Errorf("Template of a missing template = %v, %v", got, err)
+	}
+
+	lib, err := LoadLibraryStore(context.Background(), DirStore{Dir: dir}, "", LibraryOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var again bytes.Buffer
+	if err := CompileLibrary(lib, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), data) {
+		t.Error("compiling the same library twice gave different bytes")
+	}
+}
+
+func TestPackedLibraryCorrupt(t *testing.T) {
+	_, data := packedFixture(t)
+	indexLen := int(binary.BigEndian.Uint64(data[8:]))
+	tests := []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{"truncated header", func(b []byte) []byte { return b[:packedHeaderSize-1] }},
+		{"bad magic", func(b []byte) []byte { b[0] = 'X'; return b }},
+		{"huge index length", func(b []byte) []byte { binary.BigEndian.PutUint64(b[8:], 1<<40); return b }},
+		{"truncated index", func(b []byte) []byte { return b[:packedHeaderSize+indexLen/2] }},
+		{"index checksum", func(b []byte) []byte { b[packedHeaderSize+1] ^= 0xff; return b }},
+		{"header checksum", func(b []byte) []byte { b[16] ^= 0xff; return b }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPackedLibrary(bytes.NewReader(tt.corrupt(bytes.Clone(data))), PackedOptions{})
+			if !errors.Is(err, ErrPackedCorrupt) {
+				t.Errorf("err = %v, want ErrPackedCorrupt", err)
+			}
+		})
+	}
+
+	future := bytes.Clone(data)
+	binary.BigEndian.PutUint32(future[4:], packedVersion+1)
+	if _, err := NewPackedLibrary(bytes.NewReader(future), PackedOptions{}); err == nil || errors.Is(err, ErrPackedCorrupt) {
+		t.Errorf("newer format version: err = %v, want a version error", err)
+	}
+
+	// A damaged document opens, since only the index is read, and fails
+	// its own checksum on first use; the other documents still load.
+	damaged := bytes.Clone(data)
+	damaged[packedHeaderSize+indexLen+10] ^= 0xff
+	packed, err := NewPackedLibrary(bytes.NewReader(damaged), PackedOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var failed int
+	for _, form := range packed.Forms() {
+		if _, err := packed.Get(form.FormID, form.Year); err != nil {
+			if !errors.Is(err, ErrPackedCorrupt) {
+				t.Errorf("%d: err = %v, want ErrPackedCorrupt", form.Year, err)
+			}
+			failed++
+		}
+	}
+	if failed != 1 {
+		t.Errorf("%d forms failed their checksum, want 1", failed)
+	}
+}
+
+func TestPackedLibraryEviction(t *testing.T) {
+	_, data := packedFixture(t)
+	packed, err := NewPackedLibrary(bytes.NewReader(data), PackedOptions{CacheSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(year int) any {
+		t.Helper()
+		fa, err := packed.Get("IRS-1040", year)
+		if err != nil || fa == nil {
+			t.Fatalf("Get(%d) = %v, %v", year, fa, err)
+		}
+		return fa
+	}
+	template := func() any {
+		t.Helper()
+		tmpl, err := packed.Template("header")
+		if err != nil || tmpl == nil {
+			t.Fatalf("Template = %v, %v", tmpl, err)
+		}
+		return tmpl
+	}
+
+	first, second := get(2024), get(2023)
+	if get(2024) != first {
+		t.Error("a cached form was decoded again")
+	}
+	// 2023 is now the least recently used, so the template evicts it.
+	tmpl := template()
+	if get(2024) != first {
+		t.Error("the most recently used form was evicted")
+	}
+	if get(2023) == second {
+		t.Error("the least recently used form was not evicted")
+	}
+	if template() == tmpl {
+		t.Error("the template outlived two newer entries")
+	}
+	if n := packed.lru.Len(); n != 2 || len(packed.cache) != 2 {
+		t.Errorf("cache holds %d entries (%d indexed), want 2", n, len(packed.cache))
+	}
+}
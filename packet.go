@@ -0,0 +1,311 @@
+package annotation
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// PacketPart records one form merged into a packet, so that Split can
+// recover it.
+type PacketPart struct {
+	FormID        string         `json:"form_id"`
+	FormName      string         `json:"form_name"`
+	Year          int            `json:"year"`
+	FirstPage     int            `json:"first_page"`
+	PageCount     int            `json:"page_count"`
+	PageSize      PageSize       `json:"page_size"`
+	NameMapping   *NameMapping   `json:"name_mapping,omitempty"`
+	RenderTargets []RenderTarget `json:"render_targets,omitempty"`
+	// Groups lists the packet's IDs of the groups that came from the form.
+	Groups []string `json:"groups,omitempty"`
+	// RenamedFields and RenamedGroups map IDs changed to avoid collisions
+	// back to the form's own IDs.
+	RenamedFields map[string]string `json:"renamed_fields,omitempty"`
+	RenamedGroups map[string]string `json:"renamed_groups,omitempty"`
+}
+
+// CollisionPolicy picks new IDs for fields and groups whose IDs are
+// already taken by an earlier form in a packet.
+type CollisionPolicy int
+
+const (
+	// CollisionSuffix appends "_2", "_3" and so on.
+	CollisionSuffix CollisionPolicy = iota
+	// CollisionPrefixFormID prefixes the ID with the form ID, as in
+	// "SCH-A_wages".
+	CollisionPrefixFormID
+	// CollisionError refuses the merge, naming every ID that collides.
+	CollisionError
+)
+
+// MergeOptions controls Merge.
+type MergeOptions struct {
+	Collisions CollisionPolicy
+	// PrefixIDs prefixes every field and group ID, the first form's too,
+	// with its form's ID, as in "IRS-1040_wages", so that the packet's IDs
+	// say which form they belong to. IDs that still collide, as when a form
+	// is merged twice, are suffixed unless Collisions is CollisionError.
+	PrefixIDs bool
+	// AllowMixedPageSizes merges forms whose page sizes differ. The packet
+	// takes the first form's size; each part keeps its own.
+	AllowMixedPageSizes bool
+	// FormID and FormName name the packet; by default the forms' IDs are
+	// joined with "+" and their names with "; ".
+	FormID   string
+	FormName string
+}
+
+// Merge concatenates forms into one packet, renumbering pages in order.
+// The first form keeps its IDs, unless opts.PrefixIDs prefixes them all;
+// later fields and groups whose IDs are taken are renamed by
+// opts.Collisions, with every reference to them rewritten, or refused. Forms whose page sizes differ are refused unless
+// opts.AllowMixedPageSizes is set. The packet records each part in
+// FormMetadata.Parts for Split.
+func Merge(opts MergeOptions, forms ...*FormAnnotation) (*FormAnnotation, error) {
+	if len(forms) == 0 {
+		return nil, fmt.Errorf("merge: no forms")
+	}
+	first := forms[0].FormMetadata
+	// Frames move onto the pages, since the parts may measure differently.
+	out := &FormAnnotation{FormMetadata: FormMetadata{
+		Year:          first.Year,
+		PageSize:      PageSize{Width: first.PageSize.Width, Height: first.PageSize.Height, Unit: first.PageSize.Unit},
+		SchemaVersion: first.SchemaVersion,
+	}}
+	var ids, names, conflicts []string
+	fieldTaken, groupTaken := map[string]bool{}, map[string]bool{}
+	policy := opts.Collisions
+	if opts.PrefixIDs && policy == CollisionPrefixFormID {
+		policy = CollisionSuffix
+	}
+	for _, src := range forms {
+		md := src.FormMetadata
+		if !opts.AllowMixedPageSizes && !samePageSize(md.PageSize, first.PageSize) {
+			return nil, fmt.Errorf("merge: %s pages are %gx%g %s, %s pages are %gx%g %s",
+				md.FormID, md.PageSize.Width, md.PageSize.Height, md.PageSize.Unit,
+				first.FormID, first.PageSize.Width, first.PageSize.Height, first.PageSize.Unit)
+		}
+		if len(src.FormMetadata.Parts) > 0 {
+			return nil, fmt.Errorf("merge: %s is already a packet; split it first", md.FormID)
+		}
+		fa := src.Clone()
+		// Parts may measure from different corners; the packet measures
+		// from the top left.
+		if err := fa.TransformOrigin(OriginTopLeft); err != nil {
+			return nil, fmt.Errorf("merge: %s: %w", md.FormID, err)
+		}
+		part := PacketPart{
+			FormID:        md.FormID,
+			FormName:      md.FormName,
+			Year:          md.Year,
+			FirstPage:     len(out.Pages) + 1,
+			PageCount:     len(fa.Pages),
+			PageSize:      fa.FormMetadata.PageSize,
+			NameMapping:   fa.FormMetadata.NameMapping.clone(),
+			RenderTargets: cloneSlice(md.RenderTargets),
+		}
+		own := map[string]bool{}
+		for _, f := range fa.Fields() {
+			own[f.FieldID] = true
+		}
+		for _, f := range fa.Fields() {
+			old := f.FieldID
+			id := old
+			if opts.PrefixIDs {
+				id = md.FormID + "_" + old
+			}
+			if fieldTaken[id] || id != old && own[id] {
+				if policy == CollisionError {
+					conflicts = append(conflicts, fmt.Sprintf("field %q of %s", old, md.FormID))
+					continue
+				}
+				id = collisionID(id, md.FormID, policy, func(id string) bool { return fieldTaken[id] || own[id] })
+			}
+			if id == old {
+				fieldTaken[id] = true
+				continue
+			}
+			fa.renameField(f, id, func(ref string) bool { return ref == old })
+			fieldTaken[id] = true
+			if part.RenamedFields == nil {
+				part.RenamedFields = map[string]string{}
+			}
+			part.RenamedFields[id] = old
+		}
+		ownGroups := map[string]bool{}
+		for _, g := range fa.FieldGroups {
+			ownGroups[g.GroupID] = true
+		}
+		for i := range fa.FieldGroups {
+			g := &fa.FieldGroups[i]
+			old := g.GroupID
+			id := old
+			if opts.PrefixIDs {
+				id = md.FormID + "_" + old
+			}
+			if groupTaken[id] || id != old && ownGroups[id] {
+				if policy == CollisionError {
+					conflicts = append(conflicts, fmt.Sprintf("group %q of %s", old, md.FormID))
+					continue
+				}
+				id = collisionID(id, md.FormID, policy, func(id string) bool { return groupTaken[id] || ownGroups[id] })
+			}
+			if id != old {
+				g.GroupID = id
+				fa.renameGroupRefs(old, g.GroupID)
+				if part.RenamedGroups == nil {
+					part.RenamedGroups = map[string]string{}
+				}
+				part.RenamedGroups[g.GroupID] = old
+			}
+			groupTaken[g.GroupID] = true
+			part.Groups = append(part.Groups, g.GroupID)
+		}
+		for _, page := range fa.Pages {
+			page.PageNumber = len(out.Pages) + 1
+			if page.Frame == nil && md.PageSize.Frame != nil {
+				page.Frame = clonePtr(md.PageSize.Frame)
+			}
+			out.Pages = append(out.Pages, page)
+		}
+		out.FieldGroups = append(out.FieldGroups, fa.FieldGroups...)
+		out.FormMetadata.mergeNames(fa.FormMetadata.NameMapping)
+		for _, t := range md.RenderTargets {
+			if !slices.Contains(out.FormMetadata.RenderTargets, t) {
+				out.FormMetadata.RenderTargets = append(out.FormMetadata.RenderTargets, t)
+			}
+		}
+		out.FormMetadata.Parts = append(out.FormMetadata.Parts, part)
+		ids = append(ids, md.FormID)
+		names = append(names, md.FormName)
+	}
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("merge: IDs already used by an earlier form: %s", strings.Join(conflicts, ", "))
+	}
+	out.FormMetadata.FormID = opts.FormID
+	if out.FormMetadata.FormID == "" {
+		out.FormMetadata.FormID = strings.Join(ids, "+")
+	}
+	out.FormMetadata.FormName = opts.FormName
+	if out.FormMetadata.FormName == "" {
+		out.FormMetadata.FormName = strings.Join(names, "; ")
+	}
+	out.FormMetadata.PageCount = len(out.Pages)
+	return out, nil
+}
+
+// Split breaks a packet built by Merge back into its forms, keyed by form
+// ID, restoring each form's page numbers, IDs, page size and metadata.
+func (fa *FormAnnotation) Split() (map[string]*FormAnnotation, error) {
+	if len(fa.FormMetadata.Parts) == 0 {
+		return nil, fmt.Errorf("split: %s is not a packet", fa.FormMetadata.FormID)
+	}
+	packet := fa.Clone()
+	out := map[string]*FormAnnotation{}
+	for _, part := range packet.FormMetadata.Parts {
+		if out[part.FormID] != nil {
+			return nil, fmt.Errorf("split: form %s appears more than once", part.FormID)
+		}
+		form := &FormAnnotation{
+			FormMetadata: FormMetadata{
+				FormID:        part.FormID,
+				FormName:      part.FormName,
+				Year:          part.Year,
+				PageSize:      part.PageSize,
+				NameMapping:   part.NameMapping.clone(),
+				RenderTargets: cloneSlice(part.RenderTargets),
+				SchemaVersion: packet.FormMetadata.SchemaVersion,
+			},
+			CaseInsensitiveIDs: fa.CaseInsensitiveIDs,
+			ExprLimits:         clonePtr(fa.ExprLimits),
+		}
+		for _, page := range packet.Pages {
+			if page.PageNumber < part.FirstPage || page.PageNumber >= part.FirstPage+part.PageCount {
+				continue
+			}
+			page.PageNumber -= part.FirstPage - 1
+			if page.Frame != nil && part.PageSize.Frame != nil && *page.Frame == *part.PageSize.Frame {
+				page.Frame = nil
+			}
+			form.Pages = append(form.Pages, page)
+		}
+		for _, g := range packet.FieldGroups {
+			for _, id := range part.Groups {
+				if g.GroupID == id {
+					form.FieldGroups = append(form.FieldGroups, g)
+				}
+			}
+		}
+		for _, f := range form.Fields() {
+			if old, ok := part.RenamedFields[f.FieldID]; ok {
+				id := f.FieldID
+				form.renameField(f, old, func(ref string) bool { return ref == id })
+			}
+		}
+		for i := range form.FieldGroups {
+			if old, ok := part.RenamedGroups[form.FieldGroups[i].GroupID]; ok {
+				form.renameGroupRefs(form.FieldGroups[i].GroupID, old)
+				form.FieldGroups[i].GroupID = old
+			}
+		}
+		form.FormMetadata.PageCount = len(form.Pages)
+		out[part.FormID] = form
+	}
+	return out, nil
+}
+
+// collisionID returns a replacement for id that taken rejects.
+func collisionID(id, formID string, policy CollisionPolicy, taken func(string) bool) string {
+	base := id
+	if policy == CollisionPrefixFormID {
+		base = formID + "_" + id
+		if !taken(base) {
+			return base
+		}
+	}
+	for n := 2; ; n++ {
+		if candidate := fmt.Sprintf("%s_%d", base, n); !taken(candidate) {
+			return candidate
+		}
+	}
+}
+
+// renameGroupRefs points the fields of group oldID at newID.
+func (fa *FormAnnotation) renameGroupRefs(oldID, newID string) {
+	for _, f := range fa.Fields() {
+		if f.GroupID == oldID {
+			f.GroupID = newID
+		}
+	}
+}
+
+// mergeNames adds a part's PDF name mapping to the packet's.
+func (md *FormMetadata) mergeNames(m *NameMapping) {
+	if m == nil {
+		return
+	}
+	if md.NameMapping == nil {
+		md.NameMapping = &NameMapping{}
+	}
+	for id, name := range m.Pairs {
+		if md.NameMapping.Pairs == nil {
+			md.NameMapping.Pairs = map[string]string{}
+		}
+		md.NameMapping.Pairs[id] = name
+	}
+	md.NameMapping.Rules = append(md.NameMapping.Rules, m.Rules...)
+}
+
+func samePageSize(a, b PageSize) bool {
+	aw, okA := toPoints(a.Width, a.Unit)
+	bw, okB := toPoints(b.Width, b.Unit)
+	ah, _ := toPoints(a.Height, a.Unit)
+	bh, _ := toPoints(b.Height, b.Unit)
+	return okA && okB && nearlyEqual(aw, bw) && nearlyEqual(ah, bh)
+}
+
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
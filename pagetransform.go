@@ -0,0 +1,208 @@
+package annotation
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+)
+
+// InvalidPageLayout is the issue code for a page rotation that is not a
+// multiple of 90 degrees, or a page size override that is not positive,
+// names another unit than the document's, or declares a coordinate frame.
+const InvalidPageLayout = "invalid_page_layout"
+
+// PageSizeOf returns the size of page pageNum: its own override when it
+// has one, else the document's page size. An override is in the document
+// unit and frame, which PageSizeOf fills in.
+func (fa *FormAnnotation) PageSizeOf(pageNum int) PageSize {
+	ps := fa.FormMetadata.PageSize
+	for i := range fa.Pages {
+		if p := &fa.Pages[i]; p.PageNumber == pageNum && p.PageSize != nil {
+			ps.Width, ps.Height = p.PageSize.Width, p.PageSize.Height
+			break
+		}
+	}
+	return ps
+}
+
+// checkPageLayout reports rotations that are not 0, 90, 180 or 270, and
+// page size overrides that are not positive, in another unit, or framed.
+func (fa *FormAnnotation) checkPageLayout() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, page := range fa.Pages {
+		at := ValidationIssue{Code: InvalidPageLayout, Severity: SeverityError, Page: page.PageNumber}
+		add := func(format string, args ...any) {
+			at.Message = fmt.Sprintf(format, args...)
+			issues = append(issues, at)
+		}
+		if page.Rotation%90 != 0 || page.Rotation < 0 || page.Rotation >= 360 {
+			add("rotation %d is not 0, 90, 180 or 270", page.Rotation)
+		}
+		ps := page.PageSize
+		switch {
+		case ps == nil:
+		case ps.Width <= 0 || ps.Height <= 0:
+			add("page size %gx%g is not positive", ps.Width, ps.Height)
+		case ps.Unit != "" && ps.Unit != fa.FormMetadata.PageSize.Unit:
+			add("page size unit %q differs from the document's %q", ps.Unit, fa.FormMetadata.PageSize.Unit)
+		case ps.Frame != nil:
+			add("page size declares a coordinate frame; set the page's coordinate_frame instead")
+		}
+	}
+	return issues
+}
+
+// Matrix is an affine transform of page coordinates in the PDF order
+// [a b c d e f], mapping x, y to a*x + c*y + e, b*x + d*y + f.
+type Matrix [6]float64
+
+// IdentityMatrix leaves coordinates unchanged.
+func IdentityMatrix() Matrix { return Matrix{1, 0, 0, 1, 0, 0} }
+
+// TranslateMatrix moves coordinates by dx, dy.
+func TranslateMatrix(dx, dy float64) Matrix { return Matrix{1, 0, 0, 1, dx, dy} }
+
+// ScaleMatrix scales coordinates by sx, sy about the origin.
+func ScaleMatrix(sx, sy float64) Matrix { return Matrix{sx, 0, 0, sy, 0, 0} }
+
+// Then returns the transform applying m and then n.
+func (m Matrix) Then(n Matrix) Matrix {
+	return Matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// Apply maps the point x, y.
+func (m Matrix) Apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+// PageRotationMatrix returns the transform from positions traced on page
+// pageNum as a viewer displays it, turned clockwise by its rotation, to
+// positions on the page itself, which is what fields store. Applied with
+// TransformPage it fixes a page whose fields were drawn on the rotated
+// view. Documents with coordinate frames are refused.
+func (fa *FormAnnotation) PageRotationMatrix(pageNum int) (Matrix, error) {
+	page := fa.page(pageNum)
+	if page == nil {
+		return Matrix{}, fmt.Errorf("page %d does not exist", pageNum)
+	}
+	if fa.HasCoordinateFrames() {
+		return Matrix{}, fmt.Errorf("page %d: document declares coordinate frames; normalize it to the media box first", pageNum)
+	}
+	ps := fa.PageSizeOf(pageNum)
+	w, h := ps.Width, ps.Height
+	var m Matrix
+	switch page.Rotation {
+	case 0:
+		return IdentityMatrix(), nil
+	case 90:
+		m = Matrix{0, -1, 1, 0, 0, h}
+	case 180:
+		m = Matrix{-1, 0, 0, -1, w, h}
+	case 270:
+		m = Matrix{0, 1, -1, 0, w, 0}
+	default:
+		return Matrix{}, fmt.Errorf("page %d: rotation %d is not 0, 90, 180 or 270", pageNum, page.Rotation)
+	}
+	if fa.origin() == OriginBottomLeft {
+		// The matrices above work top-down; flip into and out of them.
+		shown := h
+		if page.Rotation != 180 {
+			shown = w
+		}
+		m = Matrix{1, 0, 0, -1, 0, shown}.Then(m).Then(Matrix{1, 0, 0, -1, 0, h})
+	}
+	return m, nil
+}
+
+// TransformPage maps every field and segment box and every anchor on page
+// pageNum through m, in the document's page unit and as positions are
+// stored. A box becomes the bounding box of its transformed corners, so
+// rotations by multiples of 90 degrees, scaling and translation keep boxes
+// exact. A position in another unit is converted to the page unit first.
+// Relative positions get new offsets that place their fields where they
+// now are. The page's rotation and size are not changed; set them to
+// match. On error the annotation is left unchanged.
+func (fa *FormAnnotation) TransformPage(pageNum int, m Matrix) error {
+	unit := fa.FormMetadata.PageSize.Unit
+	if _, ok := toPoints(1, unit); !ok {
+		return fmt.Errorf("transform page: unknown page unit %q", unit)
+	}
+	out := fa.Clone()
+	page := out.page(pageNum)
+	if page == nil {
+		return fmt.Errorf("transform page: page %d does not exist", pageNum)
+	}
+	box := func(p *Position, fieldID string) error {
+		if *p == (Position{}) {
+			return nil
+		}
+		if p.Unit != "" && p.Unit != unit {
+			converted, err := UnitOptions{}.convertPosition(*p, unit, unit)
+			if err != nil {
+				return fmt.Errorf("transform page: field %q: %w", fieldID, err)
+			}
+			*p = converted
+		}
+		lo, hi := [2]float64{math.Inf(1), math.Inf(1)}, [2]float64{math.Inf(-1), math.Inf(-1)}
+		for _, c := range [][2]float64{{p.X, p.Y}, {p.X + p.Width, p.Y}, {p.X, p.Y + p.Height}, {p.X + p.Width, p.Y + p.Height}} {
+			x, y := m.Apply(c[0], c[1])
+			lo = [2]float64{min(lo[0], x), min(lo[1], y)}
+			hi = [2]float64{max(hi[0], x), max(hi[1], y)}
+		}
+		p.X, p.Y = roundUnit(lo[0]), roundUnit(lo[1])
+		p.Width, p.Height = roundUnit(hi[0]-lo[0]), roundUnit(hi[1]-lo[1])
+		return nil
+	}
+	for i := range page.Fields {
+		f := &page.Fields[i]
+		if f.IsVirtual() {
+			continue
+		}
+		if err := box(&f.Position, f.FieldID); err != nil {
+			return err
+		}
+		for j := range f.Segments {
+			if err := box(&f.Segments[j].Position, f.FieldID); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range page.Anchors {
+		a := &page.Anchors[i]
+		k := convertScale(cmp.Or(a.Unit, unit), unit)
+		x, y := m.Apply(a.X*k, a.Y*k)
+		a.X, a.Y, a.Unit = roundUnit(x), roundUnit(y), ""
+	}
+	for i := range page.Fields {
+		f := &page.Fields[i]
+		rel := f.RelativeTo
+		if rel == nil {
+			continue
+		}
+		var ref [2]float64
+		if rel.Anchor != "" {
+			a := page.anchor(rel.Anchor)
+			if a == nil {
+				continue
+			}
+			ref = [2]float64{a.X, a.Y}
+		} else {
+			other := pageField(page, rel.FieldID, out.sameID)
+			if other == nil {
+				continue
+			}
+			k := convertScale(cmp.Or(other.Position.Unit, unit), cmp.Or(f.Position.Unit, unit))
+			ref = [2]float64{other.Position.X * k, other.Position.Y * k}
+		}
+		rel.OffsetX, rel.OffsetY = roundUnit(f.Position.X-ref[0]), roundUnit(f.Position.Y-ref[1])
+	}
+	*fa = *out
+	return nil
+}
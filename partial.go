@@ -0,0 +1,32 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PartialResultError reports an operation stopped by its context at a safe
+// boundary. The work before the boundary is kept and consistent; Stages and
+// Pages name what did not run. It unwraps to the context's error.
+type PartialResultError struct {
+	Stages []string
+	Pages  []int
+	Err    error
+}
+
+func (e *PartialResultError) Error() string {
+	var skipped []string
+	if len(e.Stages) > 0 {
+		skipped = append(skipped, "stages "+strings.Join(e.Stages, ", "))
+	}
+	if len(e.Pages) > 0 {
+		pages := make([]string, len(e.Pages))
+		for i, p := range e.Pages {
+			pages[i] = fmt.Sprint(p)
+		}
+		skipped = append(skipped, "pages "+strings.Join(pages, ", "))
+	}
+	return fmt.Sprintf("partial result (%v): skipped %s", e.Err, strings.Join(skipped, "; "))
+}
+
+func (e *PartialResultError) Unwrap() error { return e.Err }
@@ -0,0 +1,137 @@
+package annotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// expiringContext reports its deadline as exceeded from the nth call to Err
+// on, so that a test can stop an operation at an exact boundary.
+type expiringContext struct {
+	context.Context
+	n int
+}
+
+func (c *expiringContext) Err() error {
+	if c.n > 0 {
+		c.n--
+		return nil
+	}
+	return context.DeadlineExceeded
+}
+
+// requiredForm is pagedForm with every field required and empty, so that
+// each page of value rules reports one issue per field.
+func requiredForm(pages int) *FormAnnotation {
+	fa := pagedForm("f", pages)
+	for field := range fa.FieldsSeq() {
+		field.Validation = &Validation{Required: true}
+	}
+	return fa
+}
+
+func TestValidateAllDeadline(t *testing.T) {
+	full := requiredForm(3).ValidateAll(ValidateOptions{})
+	if full.Incomplete {
+		t.Fatal("a full run is marked incomplete")
+	}
+	tests := []struct {
+		name    string
+		checks  int
+		stages  []string
+		skipped []int
+	}{
+		{"before structure", 0, []string{StageStructure, StageMetadata, StageValues}, []int{1, 2, 3}},
+		{"before values", 2, []string{StageValues}, []int{1, 2, 3}},
+		{"after first page", 4, nil, []int{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &expiringContext{Context: context.Background(), n: tt.checks}
+			report, err := requiredForm(3).ValidateAllContext(ctx, ValidateOptions{})
+			var partial *PartialResultError
+			if !errors.As(err, &partial) || !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want a *PartialResultError for the deadline", err)
+			}
+			if !report.Incomplete {
+				t.Error("partial report is not marked incomplete")
+			}
+			if !reflect.DeepEqual(partial.Stages, tt.stages) || !reflect.DeepEqual(partial.Pages, tt.skipped) {
+				t.Errorf("skipped stages %v pages %v, want %v %v", partial.Stages, partial.Pages, tt.stages, tt.skipped)
+			}
+			// The partial report is exactly the full report's issues from
+			// the work that ran.
+			var want []ValidationIssue
+			ran := func(issue ValidationIssue) bool {
+				if len(tt.stages) > 0 && tt.stages[0] == StageStructure {
+					return false
+				}
+				if len(tt.stages) > 0 && tt.stages[0] == StageValues {
+					return issue.Code != ValueRequired
+				}
+				for _, p := range tt.skipped {
+					if issue.Page == p {
+						return false
+					}
+				}
+				return true
+			}
+			for _, issue := range full.Issues {
+				if ran(issue) {
+					want = append(want, issue)
+				}
+			}
+			if !reflect.DeepEqual(report.Issues, want) {
+				t.Errorf("issues = %v\nwant %v", report.Issues, want)
+			}
+		})
+	}
+}
+
+func TestValidateAllExpiredDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	report, err := requiredForm(3).ValidateAllContext(ctx, ValidateOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) || !report.Incomplete || len(report.Issues) != 0 {
+		t.Errorf("err = %v, incomplete %v, issues %v", err, report.Incomplete, report.Issues)
+	}
+}
+
+func TestFillFromDataDeadline(t *testing.T) {
+	data := map[string]any{}
+	for p := 1; p <= 3; p++ {
+		data[fmt.Sprintf("page%d", p)] = map[string]any{"f0": "a", "f1": "b"}
+	}
+	fa := pagedForm("f", 3)
+	ctx := &expiringContext{Context: context.Background(), n: 1}
+	report, err := fa.FillFromDataContext(ctx, data, FillOptions{})
+	var partial *PartialResultError
+	if !errors.As(err, &partial) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want a *PartialResultError for the deadline", err)
+	}
+	if !report.Incomplete {
+		t.Error("partial report is not marked incomplete")
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(partial.Pages, want) {
+		t.Errorf("skipped pages %v, want %v", partial.Pages, want)
+	}
+	if want := []string{"p1_f0", "p1_f1"}; !reflect.DeepEqual(report.Filled, want) {
+		t.Errorf("filled %v, want %v", report.Filled, want)
+	}
+	for field := range fa.FieldsSeq() {
+		if filled := field.Value != ""; filled != strings.HasPrefix(field.FieldID, "p1_") {
+			t.Errorf("%s has value %q", field.FieldID, field.Value)
+		}
+	}
+
+	full, err := pagedForm("f", 3).FillFromDataContext(context.Background(), data, FillOptions{})
+	if err != nil || full.Incomplete || len(full.Filled) != 6 {
+		t.Errorf("full fill: err %v, incomplete %v, filled %v", err, full.Incomplete, full.Filled)
+	}
+}
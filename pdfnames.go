@@ -0,0 +1,272 @@
+package annotation
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// NameMapping translates between field IDs and the hierarchical AcroForm
+// names used in PDFs, such as "topmostSubform[0].Page1[0].f1_01[0]".
+// Explicit pairs win over rules, and rules are tried in order.
+type NameMapping struct {
+	Pairs map[string]string `json:"pairs,omitempty"`
+	Rules []NameRule        `json:"rules,omitempty"`
+}
+
+// NameRule maps names by pattern in both directions. FieldPattern matched
+// against a field ID expands PDFTemplate; PDFPattern matched against a PDF
+// name expands FieldTemplate. Templates use regexp.Expand syntax ("$1").
+type NameRule struct {
+	FieldPattern  string `json:"field_pattern"`
+	PDFTemplate   string `json:"pdf_template"`
+	PDFPattern    string `json:"pdf_pattern"`
+	FieldTemplate string `json:"field_template"`
+}
+
+// PDFName returns the PDF name mapped to fieldID.
+func (m *NameMapping) PDFName(fieldID string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	if name, ok := m.Pairs[fieldID]; ok {
+		return name, true
+	}
+	for _, rule := range m.Rules {
+		if name, ok := expandRule(rule.FieldPattern, rule.PDFTemplate, fieldID); ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// FieldID returns the field ID mapped to a PDF name.
+func (m *NameMapping) FieldID(pdfName string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	for id, name := range m.Pairs {
+		if name == pdfName {
+			return id, true
+		}
+	}
+	for _, rule := range m.Rules {
+		if id, ok := expandRule(rule.PDFPattern, rule.FieldTemplate, pdfName); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func expandRule(pattern, template, name string) (string, bool) {
+	if pattern == "" {
+		return "", false
+	}
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return "", false
+	}
+	match := re.FindStringSubmatchIndex(name)
+	if match == nil {
+		return "", false
+	}
+	return string(re.ExpandString(nil, template, name, match)), true
+}
+
+// PDFNameFor returns the PDF field name for field: its own pdf_name, then the
+// metadata name mapping, then the field ID itself.
+func (fa *FormAnnotation) PDFNameFor(field *Field) string {
+	if field.PDFName != "" {
+		return field.PDFName
+	}
+	if name, ok := fa.FormMetadata.NameMapping.PDFName(field.FieldID); ok {
+		return name
+	}
+	return field.FieldID
+}
+
+// FieldByPDFName resolves a PDF field name back to the annotated field.
+func (fa *FormAnnotation) FieldByPDFName(pdfName string) *Field {
+	for i := range fa.Pages {
+		for j := range fa.Pages[i].Fields {
+			if fa.Pages[i].Fields[j].PDFName == pdfName {
+				return &fa.Pages[i].Fields[j]
+			}
+		}
+	}
+	if id, ok := fa.FormMetadata.NameMapping.FieldID(pdfName); ok {
+		return fa.GetFieldByID(id)
+	}
+	return fa.GetFieldByID(pdfName)
+}
+
+// PDFWidget is a form field widget found in a PDF. Rect is in the
+// annotation's media box (top-left origin, page units) whatever coordinate
+// frame the annotation declares; a zero Rect means the geometry is unknown.
+type PDFWidget struct {
+	Name string   `json:"name"`
+	Page int      `json:"page"`
+	Rect Position `json:"rect"`
+}
+
+// NameProposal pairs a field with a PDF widget name.
+type NameProposal struct {
+	FieldID    string  `json:"field_id"`
+	PDFName    string  `json:"pdf_name"`
+	Confidence float64 `json:"confidence"`
+	Method     string  `json:"method"`
+}
+
+// NameInferenceReport is the outcome of InferPDFNames. Unmapped entries on
+// either side are the fields that would silently fail to stamp.
+type NameInferenceReport struct {
+	Proposals        []NameProposal `json:"proposals"`
+	UnmappedFields   []string       `json:"unmapped_fields,omitempty"`
+	UnmappedPDFNames []string       `json:"unmapped_pdf_names,omitempty"`
+}
+
+// Name inference methods.
+const (
+	NameMethodExisting = "existing"
+	NameMethodGeometry = "geometry"
+	NameMethodOrdinal  = "ordinal"
+)
+
+// minNameOverlap is the smallest intersection-over-union accepted as a geometric match.
+const minNameOverlap = 0.3
+
+var pdfOrdinalPattern = regexp.MustCompile(`_(\d+)(?:\[\d+\])?$`)
+
+// InferPDFNames proposes a PDF name for every rendered field. Names already
+// resolved through pdf_name or the name mapping are kept; the rest are
+// matched to widgets on the same page by rectangle overlap, and widgets
+// without geometry fall back to matching their trailing ordinal (f1_03 is the
+// third remaining field) against reading order. Virtual fields are skipped.
+func InferPDFNames(fa *FormAnnotation, widgets []PDFWidget) *NameInferenceReport {
+	report := &NameInferenceReport{}
+	usedWidget := map[string]bool{}
+	mapped := map[string]bool{}
+
+	byName := map[string]PDFWidget{}
+	for _, w := range widgets {
+		byName[w.Name] = w
+	}
+	for _, field := range fa.Fields() {
+		if field.IsVirtual() {
+			continue
+		}
+		name := fa.PDFNameFor(field)
+		if _, ok := byName[name]; ok {
+			report.Proposals = append(report.Proposals, NameProposal{field.FieldID, name, 1, NameMethodExisting})
+			usedWidget[name] = true
+			mapped[field.FieldID] = true
+		}
+	}
+
+	for _, page := range fa.Pages {
+		unit := fa.FormMetadata.PageSize.Unit
+		type candidate struct {
+			field, widget string
+			score         float64
+		}
+		var candidates []candidate
+		var ordinalWidgets []PDFWidget
+		for _, w := range widgets {
+			if w.Page != page.PageNumber || usedWidget[w.Name] {
+				continue
+			}
+			if w.Rect.Width <= 0 || w.Rect.Height <= 0 {
+				ordinalWidgets = append(ordinalWidgets, w)
+				continue
+			}
+			for _, field := range renderedFields(page.Fields) {
+				if mapped[field.FieldID] {
+					continue
+				}
+				if score := overlapRatio(fa.absoluteField(field, page.PageNumber).Position, w.Rect, unit); score >= minNameOverlap {
+					candidates = append(candidates, candidate{field.FieldID, w.Name, score})
+				}
+			}
+		}
+		// Greedily accept the strongest overlaps first.
+		sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+		for _, c := range candidates {
+			if mapped[c.field] || usedWidget[c.widget] {
+				continue
+			}
+			report.Proposals = append(report.Proposals, NameProposal{c.field, c.widget, c.score, NameMethodGeometry})
+			mapped[c.field] = true
+			usedWidget[c.widget] = true
+		}
+
+		var remaining []*Field
+		for _, field := range readingOrder(page.Fields, defaultRowTolerance, fa.origin()) {
+			if !mapped[field.FieldID] {
+				remaining = append(remaining, field)
+			}
+		}
+		for _, w := range ordinalWidgets {
+			m := pdfOrdinalPattern.FindStringSubmatch(w.Name)
+			if m == nil {
+				continue
+			}
+			n, _ := strconv.Atoi(m[1])
+			if n < 1 || n > len(remaining) || mapped[remaining[n-1].FieldID] {
+				continue
+			}
+			field := remaining[n-1]
+			report.Proposals = append(report.Proposals, NameProposal{field.FieldID, w.Name, 0.3, NameMethodOrdinal})
+			mapped[field.FieldID] = true
+			usedWidget[w.Name] = true
+		}
+	}
+
+	for _, field := range fa.Fields() {
+		if !mapped[field.FieldID] && !field.IsVirtual() {
+			report.UnmappedFields = append(report.UnmappedFields, field.FieldID)
+		}
+	}
+	for _, w := range widgets {
+		if !usedWidget[w.Name] {
+			report.UnmappedPDFNames = append(report.UnmappedPDFNames, w.Name)
+		}
+	}
+	return report
+}
+
+// ApplyNameProposals records proposals at or above minConfidence as the
+// fields' pdf_name and returns how many were written.
+func (fa *FormAnnotation) ApplyNameProposals(report *NameInferenceReport, minConfidence float64) (int, error) {
+	applied := 0
+	for _, p := range report.Proposals {
+		if p.Confidence < minConfidence {
+			continue
+		}
+		field := fa.GetFieldByID(p.FieldID)
+		if field == nil {
+			return applied, fmt.Errorf("proposal references unknown field %q", p.FieldID)
+		}
+		if field.PDFName != p.PDFName {
+			field.PDFName = p.PDFName
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// overlapRatio returns the intersection-over-union of two rectangles.
+func overlapRatio(a, b Position, fallbackUnit string) float64 {
+	ra, okA := positionInPoints(a, fallbackUnit)
+	rb, okB := positionInPoints(b, fallbackUnit)
+	if !okA || !okB {
+		return 0
+	}
+	w := min(ra.X+ra.Width, rb.X+rb.Width) - max(ra.X, rb.X)
+	h := min(ra.Y+ra.Height, rb.Y+rb.Height) - max(ra.Y, rb.Y)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	inter := w * h
+	return inter / (ra.Width*ra.Height + rb.Width*rb.Height - inter)
+}
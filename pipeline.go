@@ -0,0 +1,189 @@
+package annotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Pipeline phase names, in their default order.
+const (
+	PhaseApplyDefaults = "apply_defaults"
+	PhaseFill          = "fill"
+	PhaseCarryovers    = "carryovers"
+	PhaseRecalculate   = "recalculate"
+	PhaseVisibility    = "visibility"
+	PhaseValidate      = "validate"
+)
+
+// DefaultPhaseOrder is the order in which a new Pipeline runs its phases.
+var DefaultPhaseOrder = []string{
+	PhaseApplyDefaults,
+	PhaseFill,
+	PhaseCarryovers,
+	PhaseRecalculate,
+	PhaseVisibility,
+	PhaseValidate,
+}
+
+// PhaseFunc is a pipeline phase. It mutates or inspects the form being processed.
+type PhaseFunc func(fa *FormAnnotation) error
+
+// PipelineOptions controls a Pipeline.
+type PipelineOptions struct {
+	Fill     FillOptions
+	Validate ValidateOptions
+}
+
+// Pipeline runs a fixed, declared sequence of phases over a copy of a
+// template, so that every caller processes a form in the same order.
+// The fill, recalculate, visibility and validate phases are built in;
+// visibility clears the values of fields their conditions hide. The other
+// default phases do nothing until they are given an implementation with Set.
+type Pipeline struct {
+	template *FormAnnotation
+	opts     PipelineOptions
+	phases   []pipelinePhase
+}
+
+type pipelinePhase struct {
+	name string
+	run  func(r *pipelineRun) error
+}
+
+// pipelineRun is the state of one Run.
+type pipelineRun struct {
+	ctx    context.Context
+	data   map[string]any
+	result *PipelineResult
+}
+
+// PhaseResult records how one phase ran.
+type PhaseResult struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	// Skipped is set for declared phases that have no implementation.
+	Skipped bool `json:"skipped,omitempty"`
+}
+
+// PipelineResult is the processed form and the aggregated reports of a Run.
+type PipelineResult struct {
+	Form       *FormAnnotation   `json:"-"`
+	Fill       *FillReport       `json:"fill,omitempty"`
+	Validation *ValidationReport `json:"validation,omitempty"`
+	Phases     []PhaseResult     `json:"phases"`
+}
+
+// NewPipeline returns a pipeline over template with the default phase order.
+// The template itself is never modified.
+func NewPipeline(template *FormAnnotation, opts PipelineOptions) *Pipeline {
+	p := &Pipeline{template: template, opts: opts}
+	for _, name := range DefaultPhaseOrder {
+		p.phases = append(p.phases, pipelinePhase{name: name})
+	}
+	p.setBuiltin(PhaseFill, func(r *pipelineRun) error {
+		report, err := r.result.Form.FillFromDataContext(r.ctx, r.data, p.opts.Fill)
+		r.result.Fill.Filled = append(r.result.Fill.Filled, report.Filled...)
+		r.result.Fill.Issues = append(r.result.Fill.Issues, report.Issues...)
+		r.result.Fill.Incomplete = r.result.Fill.Incomplete || report.Incomplete
+		return err
+	})
+	p.setBuiltin(PhaseRecalculate, func(r *pipelineRun) error {
+		report := r.result.Form.Recalculate()
+		r.result.Fill.Issues = append(r.result.Fill.Issues, report.Issues...)
+		return nil
+	})
+	p.setBuiltin(PhaseVisibility, func(r *pipelineRun) error {
+		r.result.Form.clearHidden()
+		return nil
+	})
+	p.setBuiltin(PhaseValidate, func(r *pipelineRun) error {
+		var err error
+		r.result.Validation, err = r.result.Form.ValidateAllContext(r.ctx, p.opts.Validate)
+		return err
+	})
+	return p
+}
+
+func (p *Pipeline) setBuiltin(name string, run func(r *pipelineRun) error) {
+	p.phases[p.index(name)].run = run
+}
+
+func (p *Pipeline) index(name string) int {
+	for i, phase := range p.phases {
+		if phase.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func custom(fn PhaseFunc) func(r *pipelineRun) error {
+	return func(r *pipelineRun) error { return fn(r.result.Form) }
+}
+
+// Phases returns the phase names in run order.
+func (p *Pipeline) Phases() []string {
+	names := make([]string, len(p.phases))
+	for i, phase := range p.phases {
+		names[i] = phase.name
+	}
+	return names
+}
+
+// Set replaces the implementation of an existing phase.
+func (p *Pipeline) Set(name string, fn PhaseFunc) error {
+	i := p.index(name)
+	if i < 0 {
+		return fmt.Errorf("pipeline has no phase %q", name)
+	}
+	p.phases[i].run = custom(fn)
+	return nil
+}
+
+// InsertBefore adds a custom phase immediately before the phase named before.
+func (p *Pipeline) InsertBefore(before, name string, fn PhaseFunc) error {
+	return p.insert(before, 0, name, fn)
+}
+
+// InsertAfter adds a custom phase immediately after the phase named after.
+func (p *Pipeline) InsertAfter(after, name string, fn PhaseFunc) error {
+	return p.insert(after, 1, name, fn)
+}
+
+func (p *Pipeline) insert(anchor string, offset int, name string, fn PhaseFunc) error {
+	if p.index(name) >= 0 {
+		return fmt.Errorf("pipeline already has a phase %q", name)
+	}
+	i := p.index(anchor)
+	if i < 0 {
+		return fmt.Errorf("pipeline has no phase %q", anchor)
+	}
+	i += offset
+	p.phases = append(p.phases[:i], append([]pipelinePhase{{name: name, run: custom(fn)}}, p.phases[i:]...)...)
+	return nil
+}
+
+// Run processes a fresh copy of the template with data through every phase
+// in order. Each run starts from the template, so running the same data
+// twice yields identical forms and reports. A phase error or a cancelled
+// context stops the run.
+func (p *Pipeline) Run(ctx context.Context, data map[string]any) (*PipelineResult, error) {
+	r := &pipelineRun{ctx: ctx, data: data, result: &PipelineResult{Form: p.template.Clone(), Fill: &FillReport{}}}
+	for _, phase := range p.phases {
+		if err := ctx.Err(); err != nil {
+			return r.result, err
+		}
+		if phase.run == nil {
+			r.result.Phases = append(r.result.Phases, PhaseResult{Name: phase.name, Skipped: true})
+			continue
+		}
+		start := time.Now()
+		err := phase.run(r)
+		r.result.Phases = append(r.result.Phases, PhaseResult{Name: phase.name, Duration: time.Since(start)})
+		if err != nil {
+			return r.result, fmt.Errorf("phase %s: %w", phase.name, err)
+		}
+	}
+	return r.result, nil
+}
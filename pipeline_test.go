@@ -0,0 +1,118 @@
+package annotation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// pipelineForm has two amounts summed into a total, a spouse name shown
+// only for joint filers, and a filing status.
+func pipelineForm() *FormAnnotation {
+	field := func(id string, ft FieldType, dt DataType, path string, y float64) Field {
+		return Field{FieldID: id, FieldType: ft, DataType: dt, FieldValue: path,
+			Position: Position{X: 36, Y: y, Width: 200, Height: 18, Unit: "pt"}}
+	}
+	fa := &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", Year: 2024, PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{
+			field("status", FieldTypeText, DataTypeString, "status", 36),
+			field("spouse", FieldTypeText, DataTypeString, "spouse", 72),
+			field("wages", FieldTypeCurrency, DataTypeDecimal, "income.wages", 108),
+			field("interest", FieldTypeCurrency, DataTypeDecimal, "income.interest", 144),
+			field("total", FieldTypeCurrency, DataTypeDecimal, "", 180),
+			field("note", FieldTypeText, DataTypeString, "", 216),
+		}}},
+	}
+	fa.GetFieldByID("spouse").Conditions = &Conditions{VisibleIf: `status == "joint"`}
+	fa.GetFieldByID("total").Calculation = &Calculation{Expr: "wages + interest"}
+	return fa
+}
+
+func resultState(t *testing.T, r *PipelineResult) (string, []FillIssue, []ValidationIssue) {
+	t.Helper()
+	doc, err := r.Form.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc, r.Fill.Issues, r.Validation.Issues
+}
+
+// TestPipelineIdempotent checks that running the pipeline again, on the
+// same template or on a form it already processed, changes nothing.
+func TestPipelineIdempotent(t *testing.T) {
+	for _, data := range []map[string]any{
+		{"status": "single", "spouse": "Bo", "income": map[string]any{"wages": "1000.50", "interest": "20"}},
+		{"status": "joint", "spouse": "Bo", "income": map[string]any{"wages": "1000.50"}},
+		{},
+	} {
+		newPipeline := func(template *FormAnnotation) *Pipeline {
+			p := NewPipeline(template, PipelineOptions{})
+			if err := p.Set(PhaseApplyDefaults, func(fa *FormAnnotation) error {
+				if f := fa.GetFieldByID("note"); f.Value == "" {
+					f.Value = "see attached"
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			return p
+		}
+		p := newPipeline(pipelineForm())
+		first, err := p.Run(context.Background(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, fill, validation := resultState(t, first)
+
+		again, err := p.Run(context.Background(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, f, v := resultState(t, again); d != doc || !reflect.DeepEqual(f, fill) || !reflect.DeepEqual(v, validation) {
+			t.Errorf("data %v: a second run of the pipeline differs", data)
+		}
+
+		reprocessed, err := newPipeline(first.Form).Run(context.Background(), data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, _, v := resultState(t, reprocessed); d != doc || !reflect.DeepEqual(v, validation) {
+			t.Errorf("data %v: reprocessing the output changed it:\n%s\nwas\n%s", data, d, doc)
+		}
+	}
+}
+
+func TestPipelinePhaseOrder(t *testing.T) {
+	p := NewPipeline(pipelineForm(), PipelineOptions{})
+	var order []string
+	mark := func(name string) PhaseFunc {
+		return func(*FormAnnotation) error { order = append(order, name); return nil }
+	}
+	if err := p.InsertAfter(PhaseFill, "after_fill", mark("after_fill")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.InsertBefore(PhaseValidate, "before_validate", mark("before_validate")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.InsertAfter(PhaseFill, "after_fill", mark("again")); err == nil {
+		t.Error("a duplicate phase name was accepted")
+	}
+	want := []string{PhaseApplyDefaults, PhaseFill, "after_fill", PhaseCarryovers, PhaseRecalculate, PhaseVisibility, "before_validate", PhaseValidate}
+	if got := p.Phases(); !reflect.DeepEqual(got, want) {
+		t.Errorf("phases = %v, want %v", got, want)
+	}
+	result, err := p.Run(context.Background(), map[string]any{"income": map[string]any{"wages": "5", "interest": "7"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Form.GetFieldByID("total").Value; got != "12" {
+		t.Errorf("total = %q, want 12", got)
+	}
+	if !reflect.DeepEqual(order, []string{"after_fill", "before_validate"}) {
+		t.Errorf("custom phases ran as %v", order)
+	}
+	if len(result.Phases) != len(want) || !result.Phases[0].Skipped || result.Phases[1].Skipped {
+		t.Errorf("phase results = %+v", result.Phases)
+	}
+}
@@ -0,0 +1,59 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+)
+
+// PreviewOptions controls RenderPreview.
+type PreviewOptions struct {
+	// Background, when set, is drawn under the fields, such as a scan of
+	// the printed page.
+	Background image.Image
+	// DPI is the PNG resolution; the default is 72. SVG output is sized in
+	// the page unit and ignores it.
+	DPI float64
+	// Target selects the render overrides applied to the drawn fields.
+	Target RenderTarget
+}
+
+// RenderPreview draws a page's layout for review in format "svg" or "png":
+// field rectangles color-coded by type, segment cells, field ID labels and
+// group outlines, over the background when one is given. It is RenderPageSVG
+// or RenderPNG with every overlay turned on, encoded; use those directly for
+// finer control.
+func (fa *FormAnnotation) RenderPreview(pageNum int, format string, opts PreviewOptions) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case "svg":
+		svgOpts := SVGOptions{GroupOutlines: true, Target: opts.Target}
+		if opts.Background != nil {
+			var img bytes.Buffer
+			if err := png.Encode(&img, opts.Background); err != nil {
+				return nil, fmt.Errorf("preview: encode background: %w", err)
+			}
+			svgOpts.Background = "data:image/png;base64," + base64.StdEncoding.EncodeToString(img.Bytes())
+		}
+		return fa.RenderPageSVG(pageNum, svgOpts)
+	case "png":
+		img, err := fa.RenderPNG(pageNum, RasterOptions{
+			DPI:        opts.DPI,
+			Labels:     true,
+			Groups:     true,
+			Target:     opts.Target,
+			Background: opts.Background,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var out bytes.Buffer
+		if err := png.Encode(&out, img); err != nil {
+			return nil, fmt.Errorf("preview: %w", err)
+		}
+		return out.Bytes(), nil
+	}
+	return nil, fmt.Errorf("preview: unknown format %q, want svg or png", format)
+}
@@ -0,0 +1,84 @@
+package annotation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PreparerPrefix is the value path prefix of the paid preparer block.
+const PreparerPrefix = "preparer."
+
+// Profile is a named set of values keyed by semantic key, such as the
+// preparer block an office stamps on every return ("preparer.ptin").
+type Profile struct {
+	Name   string            `json:"name"`
+	Values map[string]string `json:"values"`
+}
+
+// BindProfile fills the fields named by bindings, which map semantic keys to
+// field IDs, with the profile's values. Keys absent from the profile are
+// skipped; read-only fields are refused as in any other fill.
+func (fa *FormAnnotation) BindProfile(profile map[string]string, bindings map[string]string, opts FillOptions) *FillReport {
+	report := &FillReport{}
+	index := fa.BuildIndex()
+	for _, key := range sortedKeys(bindings) {
+		value, ok := profile[key]
+		if !ok {
+			continue
+		}
+		id := bindings[key]
+		field, page := index.lookup(id)
+		if field == nil {
+			report.add(FillIssue{
+				FieldID:  id,
+				Code:     FillUnknownField,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("profile key %q is bound to unknown field %q", key, id),
+			})
+			continue
+		}
+		fa.place(field, page, value, opts, report)
+	}
+	return report
+}
+
+// ProfileBindings derives bindings from the fields whose FieldValue path
+// starts with prefix: each path is its own semantic key. When several fields
+// share a path the first in page order is bound.
+func (fa *FormAnnotation) ProfileBindings(prefix string) map[string]string {
+	bindings := map[string]string{}
+	for _, field := range fa.Fields() {
+		if !strings.HasPrefix(field.FieldValue, prefix) {
+			continue
+		}
+		if _, ok := bindings[field.FieldValue]; !ok {
+			bindings[field.FieldValue] = field.FieldID
+		}
+	}
+	return bindings
+}
+
+// ApplyProfile binds p to the annotation's preparer fields.
+func (fa *FormAnnotation) ApplyProfile(p Profile, opts FillOptions) *FillReport {
+	return fa.BindProfile(p.Values, fa.ProfileBindings(PreparerPrefix), opts)
+}
+
+// FormSet is the set of forms that make up one return.
+type FormSet struct {
+	Forms []*FormAnnotation `json:"forms"`
+}
+
+// ApplyProfile binds p to every form in the set and returns one report per
+// form, in set order. bindings supplies explicit bindings per form ID; forms
+// without an entry use bindings derived from their preparer fields.
+func (s *FormSet) ApplyProfile(p Profile, bindings map[string]map[string]string, opts FillOptions) []*FillReport {
+	reports := make([]*FillReport, len(s.Forms))
+	for i, fa := range s.Forms {
+		b, ok := bindings[fa.FormMetadata.FormID]
+		if !ok {
+			b = fa.ProfileBindings(PreparerPrefix)
+		}
+		reports[i] = fa.BindProfile(p.Values, b, opts)
+	}
+	return reports
+}
@@ -0,0 +1,27 @@
+package annotation
+
+// ProgressFunc receives progress from long-running operations. It is called
+// synchronously on the goroutine running the operation, at page or form
+// granularity, with done counts that never decrease within a stage; each
+// stage ends with a call where done equals total. Callbacks must return
+// quickly and must not call back into the object being processed. A nil
+// ProgressFunc is never called.
+type ProgressFunc func(stage string, done, total int)
+
+func (p ProgressFunc) report(stage string, done, total int) {
+	if p != nil {
+		p(stage, done, total)
+	}
+}
+
+// Progress stages.
+const (
+	StageStructure = "structure"
+	StageMetadata  = "metadata"
+	StageValues    = "values"
+	StageForms     = "forms"
+	StageFill      = "fill"
+	// StageOptionGroups is the radio group pass of a fill; it reports no
+	// progress but may be named as skipped by a *PartialResultError.
+	StageOptionGroups = "option_groups"
+)
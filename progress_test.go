@@ -0,0 +1,82 @@
+package annotation
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type progressCall struct {
+	stage       string
+	done, total int
+}
+
+// recorder returns a ProgressFunc appending each call to calls.
+func recorder(calls *[]progressCall) ProgressFunc {
+	return func(stage string, done, total int) {
+		*calls = append(*calls, progressCall{stage, done, total})
+	}
+}
+
+// pagedForm returns a form of pages pages with two text fields each.
+func pagedForm(id string, pages int) *FormAnnotation {
+	fa := &FormAnnotation{FormMetadata: FormMetadata{FormID: id, FormName: "Form " + id, Year: 2024, PageCount: pages,
+		PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}}}
+	for p := 1; p <= pages; p++ {
+		page := Page{PageNumber: p}
+		for i := range 2 {
+			page.Fields = append(page.Fields, Field{FieldID: fmt.Sprintf("p%d_f%d", p, i), FieldType: FieldTypeText,
+				DataType: DataTypeString, FieldValue: fmt.Sprintf("page%d.f%d", p, i),
+				Position: Position{X: 36, Y: 36 + float64(i)*24, Width: 200, Height: 18, Unit: "pt"}})
+		}
+		fa.Pages = append(fa.Pages, page)
+	}
+	return fa
+}
+
+func TestValidateAllProgress(t *testing.T) {
+	var calls []progressCall
+	pagedForm("f", 3).ValidateAll(ValidateOptions{Metadata: &MetadataRules{}, Progress: recorder(&calls)})
+	want := []progressCall{
+		{StageStructure, 0, 1}, {StageStructure, 1, 1},
+		{StageMetadata, 0, 1}, {StageMetadata, 1, 1},
+		{StageValues, 0, 3}, {StageValues, 1, 3}, {StageValues, 2, 3}, {StageValues, 3, 3},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v\nwant   %v", calls, want)
+	}
+}
+
+func TestBatchFillerProgress(t *testing.T) {
+	var calls []progressCall
+	records := []map[string]any{{"page1": map[string]any{"f0": "a"}}, {}, {"page2": map[string]any{"f1": "b"}}}
+	NewBatchFiller(pagedForm("f", 2), BatchOptions{Progress: recorder(&calls)}).Run(records)
+	want := []progressCall{{StageFill, 0, 3}, {StageFill, 1, 3}, {StageFill, 2, 3}, {StageFill, 3, 3}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v\nwant   %v", calls, want)
+	}
+}
+
+func TestLibraryReportProgress(t *testing.T) {
+	lib := &Library{forms: map[libraryKey]*FormAnnotation{}, paths: map[libraryKey]string{}}
+	for _, id := range []string{"a", "b"} {
+		if err := lib.add(id+".json", pagedForm(id, 2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var calls, inner []progressCall
+	lib.Report(LibraryReportOptions{Validate: ValidateOptions{Progress: recorder(&inner)}, Progress: recorder(&calls)})
+	want := []progressCall{{StageForms, 0, 2}, {StageForms, 1, 2}, {StageForms, 2, 2}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v\nwant   %v", calls, want)
+	}
+	if len(inner) != 0 {
+		t.Errorf("per-form progress was reported: %v", inner)
+	}
+}
+
+func TestNilProgress(t *testing.T) {
+	var p ProgressFunc
+	p.report(StageValues, 1, 1)
+	pagedForm("f", 2).ValidateAll(ValidateOptions{})
+}
@@ -0,0 +1,178 @@
+package annotation
+
+import (
+	"slices"
+	"strings"
+)
+
+// FieldQuery selects fields by combining conditions, as in
+//
+//	fa.Query().OnPage(2).OfType(FieldTypeCurrency).Ungrouped().Where(isRequired).Fields()
+//	fa.Query().GroupPrefix("dep_").InReadingOrder().IDs()
+//
+// Every condition must hold. Conditions are checked in the order they were
+// added, stopping at the first that fails, and pages are walked in order
+// without collecting the fields first, so First stops at the first match.
+// Terminal operations return pointers to the annotation's own fields.
+type FieldQuery struct {
+	fa      *FormAnnotation
+	pages   []int // nil for every page
+	conds   []func(f *Field) bool
+	reading bool
+}
+
+// Query starts a query over every field of the annotation.
+func (fa *FormAnnotation) Query() *FieldQuery {
+	return &FieldQuery{fa: fa}
+}
+
+// OnPage restricts the query to the given pages. Calling it again narrows
+// to the pages both calls name.
+func (q *FieldQuery) OnPage(pageNums ...int) *FieldQuery {
+	if q.pages == nil {
+		q.pages = append([]int{}, pageNums...)
+		return q
+	}
+	q.pages = slices.DeleteFunc(q.pages, func(n int) bool { return !slices.Contains(pageNums, n) })
+	return q
+}
+
+// OfType keeps fields of any of the given types.
+func (q *FieldQuery) OfType(types ...FieldType) *FieldQuery {
+	return q.Where(func(f *Field) bool { return slices.Contains(types, f.FieldType) })
+}
+
+// OfDataType keeps fields holding any of the given data types.
+func (q *FieldQuery) OfDataType(types ...DataType) *FieldQuery {
+	return q.Where(func(f *Field) bool { return slices.Contains(types, f.DataType) })
+}
+
+// InGroup keeps fields whose group_id is groupID.
+func (q *FieldQuery) InGroup(groupID string) *FieldQuery {
+	return q.Where(func(f *Field) bool { return f.GroupID == groupID })
+}
+
+// GroupPrefix keeps fields whose group_id starts with prefix, such as
+// "dep_" for every dependent's group.
+func (q *FieldQuery) GroupPrefix(prefix string) *FieldQuery {
+	return q.Where(func(f *Field) bool { return f.GroupID != "" && strings.HasPrefix(f.GroupID, prefix) })
+}
+
+// Ungrouped keeps fields that belong to no group.
+func (q *FieldQuery) Ungrouped() *FieldQuery {
+	return q.Where(func(f *Field) bool { return f.GroupID == "" })
+}
+
+// Required keeps fields whose validation requires a value.
+func (q *FieldQuery) Required() *FieldQuery {
+	return q.Where(func(f *Field) bool { return f.Validation != nil && f.Validation.Required })
+}
+
+// LineRef keeps fields whose IRS line reference starts with prefix, as
+// "Line 12" keeps "Line 12a" and "Line 12 - Standard deduction". A prefix
+// ending in a digit does not match a longer number: "Line 1" leaves out
+// "Line 12". Case is ignored.
+func (q *FieldQuery) LineRef(prefix string) *FieldQuery {
+	return q.Where(func(f *Field) bool { return hasLineRefPrefix(f.IRSLineRef, prefix) })
+}
+
+// Where keeps fields for which match returns true.
+func (q *FieldQuery) Where(match func(f *Field) bool) *FieldQuery {
+	q.conds = append(q.conds, match)
+	return q
+}
+
+// InReadingOrder returns each page's matches top-to-bottom and, within a
+// row, left-to-right, instead of in the order the page lists them. Virtual
+// fields have no position and follow the page's other matches.
+func (q *FieldQuery) InReadingOrder() *FieldQuery {
+	q.reading = true
+	return q
+}
+
+// each calls fn with every matching field, in page order, until fn
+// returns false.
+func (q *FieldQuery) each(fn func(f *Field) bool) {
+	for pi := range q.fa.Pages {
+		page := &q.fa.Pages[pi]
+		if q.pages != nil && !slices.Contains(q.pages, page.PageNumber) {
+			continue
+		}
+		fields := make([]*Field, len(page.Fields))
+		for fi := range page.Fields {
+			fields[fi] = &page.Fields[fi]
+		}
+		if q.reading {
+			fields = readingOrder(page.Fields, defaultRowTolerance, q.fa.origin())
+			for fi := range page.Fields {
+				if page.Fields[fi].IsVirtual() {
+					fields = append(fields, &page.Fields[fi])
+				}
+			}
+		}
+	next:
+		for _, f := range fields {
+			for _, cond := range q.conds {
+				if !cond(f) {
+					continue next
+				}
+			}
+			if !fn(f) {
+				return
+			}
+		}
+	}
+}
+
+// Fields returns the matching fields in page order.
+func (q *FieldQuery) Fields() []*Field {
+	var out []*Field
+	q.each(func(f *Field) bool {
+		out = append(out, f)
+		return true
+	})
+	return out
+}
+
+// IDs returns the IDs of the matching fields in page order.
+func (q *FieldQuery) IDs() []string {
+	var out []string
+	q.each(func(f *Field) bool {
+		out = append(out, f.FieldID)
+		return true
+	})
+	return out
+}
+
+// Count returns the number of matching fields.
+func (q *FieldQuery) Count() int {
+	n := 0
+	q.each(func(*Field) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// First returns the first matching field, or nil when none matches.
+func (q *FieldQuery) First() *Field {
+	var first *Field
+	q.each(func(f *Field) bool {
+		first = f
+		return false
+	})
+	return first
+}
+
+func hasLineRefPrefix(ref, prefix string) bool {
+	if len(ref) < len(prefix) || !strings.EqualFold(ref[:len(prefix)], prefix) {
+		return false
+	}
+	if prefix == "" || len(ref) == len(prefix) {
+		return true
+	}
+	last, next := prefix[len(prefix)-1], ref[len(prefix)]
+	return !isDigit(last) || !isDigit(next)
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
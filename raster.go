@@ -0,0 +1,176 @@
+package annotation
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"slices"
+)
+
+// RasterOptions controls RenderPNG.
+type RasterOptions struct {
+	// DPI is the output resolution; the default is 72, one pixel per point.
+	DPI float64
+	// Labels draws each field's ID above its rectangle.
+	Labels bool
+	// Groups outlines the bounding box of each field group.
+	Groups bool
+	// Target selects the render overrides applied to the drawn fields.
+	Target RenderTarget
+	// Background, when set, is drawn stretched over the page in place of
+	// white, such as a scan of the printed form.
+	Background image.Image
+}
+
+// fieldColors color-codes field rectangles by type.
+var fieldColors = map[FieldType]color.RGBA{
+	FieldTypeText:      {0x1f, 0x77, 0xb4, 0xff},
+	FieldTypeCurrency:  {0x2c, 0xa0, 0x2c, 0xff},
+	FieldTypeNumeric:   {0x17, 0xbe, 0xcf, 0xff},
+	FieldTypeCheckbox:  {0xff, 0x7f, 0x0e, 0xff},
+	FieldTypeDate:      {0x94, 0x67, 0xbd, 0xff},
+	FieldTypeSegmented: {0x8c, 0x56, 0x4b, 0xff},
+	FieldTypeSignature: {0xd6, 0x27, 0x28, 0xff},
+	FieldTypeChoice:    {0xbc, 0xbd, 0x22, 0xff},
+	FieldTypeBarcode:   {0x00, 0x00, 0x00, 0xff},
+	FieldTypeImage:     {0xaa, 0x40, 0xfc, 0xff},
+}
+
+var (
+	defaultFieldColor = color.RGBA{0x7f, 0x7f, 0x7f, 0xff}
+	segmentColor      = color.RGBA{0x55, 0x55, 0x55, 0xff}
+	groupColor        = color.RGBA{0xe3, 0x77, 0xc2, 0xff}
+	labelColor        = color.RGBA{0x00, 0x00, 0x00, 0xff}
+)
+
+// RenderPNG draws the field layout of a page: a white page at opts.DPI with
+// field rectangles color-coded by type, segment boxes, and optionally field
+// ID labels, group outlines and a page image underneath. Virtual fields are
+// not drawn.
+func (fa *FormAnnotation) RenderPNG(pageNum int, opts RasterOptions) (image.Image, error) {
+	var page *Page
+	for i := range fa.Pages {
+		if fa.Pages[i].PageNumber == pageNum {
+			page = &fa.Pages[i]
+		}
+	}
+	if page == nil {
+		return nil, fmt.Errorf("no page %d", pageNum)
+	}
+	dpi := opts.DPI
+	if dpi <= 0 {
+		dpi = 72
+	}
+	geom, err := newPageGeometry(fa.PageSizeOf(pageNum), dpi)
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(renderBox{W: geom.width, H: geom.height}.pixels())
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	if opts.Background != nil {
+		drawStretched(img, opts.Background)
+	}
+
+	fields := fa.targetFields(page.Fields, opts.Target)
+	for i, field := range fields {
+		fields[i] = fa.absoluteField(field, pageNum)
+	}
+	for _, field := range fields {
+		c, ok := fieldColors[field.FieldType]
+		if !ok {
+			c = defaultFieldColor
+		}
+		if b, ok := geom.box(field.Position); ok && b.W > 0 && b.H > 0 {
+			r := b.pixels()
+			fillRect(img, r, color.NRGBA{c.R, c.G, c.B, 0x30})
+			strokeRect(img, r, c)
+		}
+		for _, seg := range field.Segments {
+			if b, ok := geom.box(seg.Position); ok {
+				strokeRect(img, b.pixels(), segmentColor)
+			}
+		}
+	}
+
+	if opts.Groups {
+		for _, group := range fa.FieldGroups {
+			var bounds renderBox
+			found := false
+			for _, field := range fields {
+				if field.GroupID != group.GroupID && !slices.ContainsFunc(group.FieldIDs, func(id string) bool { return fa.sameID(id, field.FieldID) }) {
+					continue
+				}
+				for _, b := range geom.fieldBoxes(field) {
+					if !found {
+						bounds, found = b, true
+					} else {
+						bounds = bounds.union(b)
+					}
+				}
+			}
+			if found {
+				strokeRect(img, bounds.pixels().Inset(-3), groupColor)
+			}
+		}
+	}
+
+	if opts.Labels {
+		for _, field := range fields {
+			if b, ok := geom.box(field.Position); ok {
+				r := b.pixels()
+				drawText(img, image.Pt(r.Min.X, r.Min.Y-glyphHeight-1), field.FieldID, labelColor)
+			}
+		}
+	}
+	return img, nil
+}
+
+// EncodePNGToFile writes img to filename as PNG.
+func EncodePNGToFile(img image.Image, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// drawStretched draws src over all of dst, scaled by nearest neighbor.
+func drawStretched(dst *image.RGBA, src image.Image) {
+	d, s := dst.Bounds(), src.Bounds()
+	if s.Empty() {
+		return
+	}
+	for y := d.Min.Y; y < d.Max.Y; y++ {
+		sy := s.Min.Y + (y-d.Min.Y)*s.Dy()/d.Dy()
+		for x := d.Min.X; x < d.Max.X; x++ {
+			sx := s.Min.X + (x-d.Min.X)*s.Dx()/d.Dx()
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r.Intersect(img.Bounds()), image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+func strokeRect(img *image.RGBA, r image.Rectangle, c color.Color) {
+	if r.Empty() {
+		return
+	}
+	u := image.NewUniform(c)
+	for _, edge := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1),
+		image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y),
+		image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(img, edge.Intersect(img.Bounds()), u, image.Point{}, draw.Src)
+	}
+}
@@ -0,0 +1,108 @@
+package annotation
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func loadExample(t *testing.T) *FormAnnotation {
+	t.Helper()
+	data, err := os.ReadFile("example_form_1040_annotation.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fa, err := FromJSON(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fa
+}
+
+// imageDiff returns the fraction of pixels of a and b whose color differs
+// by more than tolerance in any channel, out of 255, or 1 when their sizes
+// differ.
+func imageDiff(a, b image.Image, tolerance uint32) float64 {
+	if a.Bounds().Size() != b.Bounds().Size() {
+		return 1
+	}
+	ab, bb := a.Bounds(), b.Bounds()
+	differ := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			r1, g1, b1, a1 := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, a2 := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			for _, d := range [][2]uint32{{r1, r2}, {g1, g2}, {b1, b2}, {a1, a2}} {
+				lo, hi := min(d[0], d[1]), max(d[0], d[1])
+				if (hi-lo)>>8 > tolerance {
+					differ++
+					break
+				}
+			}
+		}
+	}
+	return float64(differ) / float64(ab.Dx()*ab.Dy())
+}
+
+// TestRenderPNGGolden compares rendered pages of the example form with
+// golden images, allowing small color and pixel differences so that
+// rounding changes do not fail it, while moved or missing boxes do.
+func TestRenderPNGGolden(t *testing.T) {
+	fa := loadExample(t)
+	for _, tc := range []struct {
+		name string
+		opts RasterOptions
+	}{
+		{"page1.png", RasterOptions{DPI: 36}},
+		{"page1_labels_groups.png", RasterOptions{DPI: 72, Labels: true, Groups: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := fa.RenderPNG(1, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", "raster", tc.name)
+			if *update {
+				var buf bytes.Buffer
+				if err := png.Encode(&buf, img); err != nil {
+					t.Fatal(err)
+				}
+				golden(t, filepath.Join("raster", tc.name), buf.Bytes())
+				return
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("%v (run go test -update to create it)", err)
+			}
+			defer f.Close()
+			want, err := png.Decode(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := imageDiff(img, want, 8); diff > 0.001 {
+				t.Errorf("%.2f%% of pixels differ from %s", diff*100, path)
+			}
+		})
+	}
+}
+
+func TestRenderPNGScalesWithDPI(t *testing.T) {
+	fa := loadExample(t)
+	for _, dpi := range []float64{36, 72, 144} {
+		img, err := fa.RenderPNG(1, RasterOptions{DPI: dpi})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ps := fa.PageSizeOf(1)
+		w, _ := toPoints(ps.Width, ps.Unit)
+		if got, want := img.Bounds().Dx(), int(w*dpi/72+0.5); got != want {
+			t.Errorf("at %v DPI: width %d, want %d", dpi, got, want)
+		}
+	}
+	if _, err := fa.RenderPNG(99, RasterOptions{}); err == nil {
+		t.Error("rendered a page that does not exist")
+	}
+}
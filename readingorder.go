@@ -0,0 +1,153 @@
+package annotation
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// defaultMinGutter is the narrowest gap, in points, that separates two
+// columns: a quarter inch.
+const defaultMinGutter = 18.0
+
+// ReadingOrderOptions controls ComputeReadingOrder.
+type ReadingOrderOptions struct {
+	// MinGutter is the narrowest horizontal gap, in points, between two
+	// columns; the default is 18, a quarter inch.
+	MinGutter float64
+	// RowOverlap is as for TabOrderOptions.
+	RowOverlap float64
+}
+
+// ComputeReadingOrder returns the IDs of a page's fields in the order a
+// reader takes them, inferring the columns from the layout rather than
+// being told them. The page is split into bands at horizontal gaps, and a
+// band into columns where a gutter of at least MinGutter runs through it,
+// recursively; bands are read from the top, columns from the left, and a
+// block that splits neither way in rows. Columns whose rows line up, like
+// the cells of a table, are read row by row instead, and consecutive bands
+// sharing columns are read as one, so a two-column section is read column
+// by column below a full-width heading. Virtual fields are left out.
+func (fa *FormAnnotation) ComputeReadingOrder(pageNum int, opts ReadingOrderOptions) ([]string, error) {
+	page := fa.page(pageNum)
+	if page == nil {
+		return nil, fmt.Errorf("no page %d", pageNum)
+	}
+	gutter := opts.MinGutter
+	if gutter <= 0 {
+		gutter = defaultMinGutter
+	}
+	overlap := opts.RowOverlap
+	if overlap <= 0 {
+		overlap = defaultRowOverlap
+	}
+	var ids []string
+	for _, b := range xyCut(fa.tabBoxes(page.Fields), gutter, overlap) {
+		ids = append(ids, b.f.FieldID)
+	}
+	return ids, nil
+}
+
+// xyCut orders boxes by recursive cuts, as ComputeReadingOrder describes.
+func xyCut(boxes []*tabBox, gutter, overlap float64) []*tabBox {
+	if len(boxes) <= 1 {
+		return boxes
+	}
+	if cols := columnsOf(boxes, gutter, overlap); cols != nil {
+		var out []*tabBox
+		for _, col := range cols {
+			out = append(out, xyCut(col, gutter, overlap)...)
+		}
+		return out
+	}
+	bands := runs(boxes, func(b *tabBox) (float64, float64) { return b.top, b.bottom }, 0)
+	if len(bands) == 1 {
+		return inRows(boxes, overlap)
+	}
+	// Join consecutive bands that read as columns together, which a cut
+	// through a common gap of both columns would otherwise interleave.
+	var merged [][]*tabBox
+	for _, band := range bands {
+		if n := len(merged); n > 0 {
+			joined := append(slices.Clone(merged[n-1]), band...)
+			if columnsOf(joined, gutter, overlap) != nil {
+				merged[n-1] = joined
+				continue
+			}
+		}
+		merged = append(merged, band)
+	}
+	var out []*tabBox
+	for _, band := range merged {
+		out = append(out, xyCut(band, gutter, overlap)...)
+	}
+	return out
+}
+
+// columnsOf splits boxes at gutters of at least gutter points, returning
+// nil when none runs through them or the columns line up like a table.
+func columnsOf(boxes []*tabBox, gutter, overlap float64) [][]*tabBox {
+	cols := runs(boxes, func(b *tabBox) (float64, float64) { return b.left, b.right }, gutter)
+	if len(cols) < 2 {
+		return nil
+	}
+	for i := 1; i < len(cols); i++ {
+		if !rowsAlign(cols[i-1], cols[i], overlap) {
+			return cols
+		}
+	}
+	return nil
+}
+
+// rowsAlign reports whether each row of the column with fewer rows shares
+// a row of the other, as the columns of a table do.
+func rowsAlign(a, b []*tabBox, overlap float64) bool {
+	bandsOf := func(boxes []*tabBox) [][]*tabBox {
+		return runs(boxes, func(b *tabBox) (float64, float64) { return b.top, b.bottom }, 0)
+	}
+	ra, rb := bandsOf(a), bandsOf(b)
+	if len(ra) > len(rb) {
+		ra, rb = rb, ra
+	}
+	extent := func(band []*tabBox) *tabBox {
+		e := &tabBox{top: band[0].top, bottom: band[0].bottom}
+		for _, b := range band[1:] {
+			e.top, e.bottom = min(e.top, b.top), max(e.bottom, b.bottom)
+		}
+		return e
+	}
+	for _, x := range ra {
+		ex := extent(x)
+		if !slices.ContainsFunc(rb, func(y []*tabBox) bool {
+			ey := extent(y)
+			return sameRow(ex, ey, overlap) || sameRow(ey, ex, overlap)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// runs sorts boxes along one axis, by the extent span returns, and splits
+// them into runs separated by gaps of at least gap; a gap of zero splits
+// wherever the extents do not overlap.
+func runs(boxes []*tabBox, span func(*tabBox) (lo, hi float64), gap float64) [][]*tabBox {
+	sorted := slices.Clone(boxes)
+	slices.SortStableFunc(sorted, func(a, b *tabBox) int {
+		lo, _ := span(a)
+		lo2, _ := span(b)
+		return cmp.Compare(lo, lo2)
+	})
+	var out [][]*tabBox
+	var end float64
+	for i, b := range sorted {
+		lo, hi := span(b)
+		if i == 0 || lo-end >= gap {
+			out = append(out, nil)
+			end = hi
+		}
+		out[len(out)-1] = append(out[len(out)-1], b)
+		end = max(end, hi)
+	}
+	return out
+}
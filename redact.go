@@ -0,0 +1,198 @@
+package annotation
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	sensitiveMu        sync.RWMutex
+	sensitiveFormatter = DefaultSensitiveFormatter
+)
+
+// SetSensitiveFormatter installs the hook consulted whenever a report, error
+// message or Describe output would include a field's value. Passing nil
+// restores DefaultSensitiveFormatter.
+func SetSensitiveFormatter(fn func(f *Field, value string) string) {
+	if fn == nil {
+		fn = DefaultSensitiveFormatter
+	}
+	sensitiveMu.Lock()
+	sensitiveFormatter = fn
+	sensitiveMu.Unlock()
+}
+
+// identifierPattern matches field IDs and data paths naming a taxpayer identifier.
+var identifierPattern = regexp.MustCompile(`(?i)(^|[._])(ssn|ein|itin|tin|ptin)($|[._])`)
+
+// Sensitivity kinds of personal data.
+const (
+	SensitivitySSN           = "ssn"
+	SensitivityEIN           = "ein"
+	SensitivityITIN          = "itin"
+	SensitivityBankAccount   = "bank_account"
+	SensitivityRoutingNumber = "routing_number"
+	SensitivityDateOfBirth   = "date_of_birth"
+	SensitivityPhone         = "phone"
+	SensitivityEmail         = "email"
+)
+
+// InvalidSensitivity reports a sensitivity that is not a known kind.
+const InvalidSensitivity = "invalid_sensitivity"
+
+// IsSensitive reports whether the field's value should be kept out of logs:
+// it is flagged sensitive or has a sensitivity, is a signature, or holds an
+// SSN or EIN by its ID or data path.
+func (f *Field) IsSensitive() bool {
+	return f.Sensitive || f.Sensitivity != "" || f.FieldType == FieldTypeSignature ||
+		identifierPattern.MatchString(f.FieldID) || identifierPattern.MatchString(f.FieldValue)
+}
+
+// sensitivity returns the field's Sensitivity or, without one, the
+// identifier its ID or data path names, such as ssn.
+func (f *Field) sensitivity() string {
+	if f.Sensitivity != "" {
+		return f.Sensitivity
+	}
+	for _, s := range []string{f.FieldID, f.FieldValue} {
+		if m := identifierPattern.FindStringSubmatch(s); m != nil {
+			return strings.ToLower(m[2])
+		}
+	}
+	return ""
+}
+
+// RedactOptions controls Redact and RedactInPlace.
+type RedactOptions struct {
+	// Sensitivities limits the redaction to fields of these kinds, by their
+	// Sensitivity or, without one, the identifier their ID or data path
+	// names. Every sensitive field is redacted when it is empty.
+	Sensitivities []string
+	// Keep is how many trailing letters and digits stay readable; zero
+	// keeps 4, and a negative count masks them all.
+	Keep int
+	// Mask replaces the masked letters and digits; X when zero.
+	Mask rune
+}
+
+// Redact returns a copy of the annotation with its sensitive values masked
+// as RedactInPlace masks them, such as for a client copy of a return.
+func (fa *FormAnnotation) Redact(opts RedactOptions) *FormAnnotation {
+	out := fa.Clone()
+	out.RedactInPlace(opts)
+	return out
+}
+
+// RedactInPlace masks the filled value of each sensitive field opts selects
+// and returns their IDs. The value is masked as it is displayed, formatting
+// applied, and separators are kept, so an SSN shown as 123-45-6789 becomes
+// XXX-XX-6789. Signatures are left as they are, and encrypted values, which
+// cannot be masked, are cleared.
+func (fa *FormAnnotation) RedactInPlace(opts RedactOptions) []string {
+	keep, mask := opts.Keep, opts.Mask
+	if keep == 0 {
+		keep = 4
+	}
+	if mask == 0 {
+		mask = 'X'
+	}
+	var ids []string
+	eachField(fa, func(f *Field) {
+		if f.Value == "" || f.FieldType == FieldTypeSignature || !f.IsSensitive() {
+			return
+		}
+		if len(opts.Sensitivities) > 0 && !slices.Contains(opts.Sensitivities, f.sensitivity()) {
+			return
+		}
+		if f.IsEncrypted() {
+			f.Value = ""
+		} else {
+			f.Value = maskValue(f.displayText(), keep, mask)
+		}
+		ids = append(ids, f.FieldID)
+	})
+	return ids
+}
+
+// checkSensitivity reports a sensitivity that is not one of the constants.
+func checkSensitivity(field *Field, pageNum int) []ValidationIssue {
+	switch field.Sensitivity {
+	case "", SensitivitySSN, SensitivityEIN, SensitivityITIN, SensitivityBankAccount,
+		SensitivityRoutingNumber, SensitivityDateOfBirth, SensitivityPhone, SensitivityEmail:
+		return nil
+	}
+	return []ValidationIssue{{Code: InvalidSensitivity, Severity: SeverityError, FieldID: field.FieldID, Page: pageNum,
+		Message: fmt.Sprintf("sensitivity %q is not a known kind of personal data", field.Sensitivity)}}
+}
+
+// DefaultSensitiveFormatter masks every letter and digit of a sensitive
+// field's value except the last four, keeping separators, and replaces
+// signatures entirely. Other values, amounts included, are returned as is.
+func DefaultSensitiveFormatter(f *Field, value string) string {
+	if !f.IsSensitive() {
+		return value
+	}
+	if f.FieldType == FieldTypeSignature {
+		return "[redacted]"
+	}
+	return maskValue(value, 4, '*')
+}
+
+// maskValue replaces every letter and digit of value but the last keep
+// with mask.
+func maskValue(value string, keep int, mask rune) string {
+	runes := []rune(value)
+	for i := len(runes) - 1; i >= 0; i-- {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		runes[i] = mask
+	}
+	return string(runes)
+}
+
+// displayValue returns value as it may appear in reports and errors.
+func displayValue(f *Field, value string) string {
+	sensitiveMu.RLock()
+	fn := sensitiveFormatter
+	sensitiveMu.RUnlock()
+	return fn(f, value)
+}
+
+// groupValue returns a value bound to a group as it may appear in reports,
+// masked as the group's first sensitive member would mask it.
+func (fa *FormAnnotation) groupValue(g *FieldGroup, value string) string {
+	for _, id := range g.FieldIDs {
+		if f := fa.GetFieldByID(id); f != nil && f.IsSensitive() {
+			return displayValue(f, value)
+		}
+	}
+	return value
+}
+
+// quoteValue is displayValue quoted for use in messages.
+func quoteValue(f *Field, value string) string {
+	return fmt.Sprintf("%q", displayValue(f, value))
+}
+
+// Describe summarizes the field for logs, with its value passed through the
+// sensitive formatter.
+func (f *Field) Describe() string {
+	if f.Value == "" {
+		return fmt.Sprintf("%s (%s)", f.FieldID, f.FieldType)
+	}
+	return fmt.Sprintf("%s (%s) = %s", f.FieldID, f.FieldType, quoteValue(f, f.Value))
+}
+
+// String returns Describe so that printing a field never leaks its value.
+func (f *Field) String() string {
+	return f.Describe()
+}
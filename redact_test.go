@@ -0,0 +1,169 @@
+package annotation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fixtureSSN is planted in every sensitive value below; no report, error
+// or description may show it, or the part of it the mask hides.
+const fixtureSSN = "987-65-4321"
+
+var leaks = []string{fixtureSSN, "987-65", "98765", "987654"}
+
+func assertRedacted(t *testing.T, what, out string) {
+	t.Helper()
+	for _, leak := range leaks {
+		if strings.Contains(out, leak) {
+			t.Errorf("%s shows %q: %s", what, leak, out)
+		}
+	}
+}
+
+func sensitiveForm() *FormAnnotation {
+	field := func(id string, ft FieldType, dt DataType, y float64) Field {
+		return Field{FieldID: id, FieldType: ft, DataType: dt, FieldValue: "taxpayer." + id, Sensitive: true,
+			Position: Position{X: 36, Y: y, Width: 200, Height: 18, Unit: "pt"}}
+	}
+	fa := &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{
+			field("ssn", FieldTypeText, DataTypeString, 36),
+			field("id_number", FieldTypeNumeric, DataTypeInteger, 72),
+			field("birth_date", FieldTypeDate, DataTypeDate, 108),
+			field("account", FieldTypeCurrency, DataTypeDecimal, 144),
+			field("consent", FieldTypeCheckbox, DataTypeBoolean, 180),
+			{FieldID: "tin", FieldType: FieldTypeSegmented, DataType: DataTypeString, Sensitive: true,
+				Segments: BuildSegments(Position{X: 36, Y: 216, Height: 18, Unit: "pt"}, 12, 6, []int{3, 2, 4})},
+		}}},
+	}
+	fa.Pages[0].Fields[0].Validation = &Validation{Pattern: `^\d{9}$`, MaxLength: 9}
+	return fa
+}
+
+// TestSensitiveValuesNeverShown runs the fixture through every path that
+// formats a value into a report, error or description, and greps the
+// output.
+func TestSensitiveValuesNeverShown(t *testing.T) {
+	fa := sensitiveForm()
+	bad := map[string]string{}
+	for _, f := range fa.Fields() {
+		bad[f.FieldID] = fixtureSSN
+	}
+
+	report := fa.ValidateValues(bad)
+	data, _ := json.Marshal(report)
+	assertRedacted(t, "ValidateValues", string(data))
+
+	withValues := fa.Clone()
+	for _, f := range withValues.Fields() {
+		f.Value = fixtureSSN
+	}
+	data, _ = json.Marshal(withValues.ValidateFilled())
+	assertRedacted(t, "ValidateFilled", string(data))
+	_, err := withValues.ExtractValues(ExtractOptions{})
+	assertRedacted(t, "ExtractValues", fmt.Sprint(err))
+
+	upstream := map[string]any{}
+	for _, f := range fa.Fields() {
+		upstream[f.FieldID] = fixtureSSN
+	}
+	for _, mode := range []CoercionMode{CoerceStrict, CoerceLenient} {
+		fill := fa.Clone().FillFromData(map[string]any{"taxpayer": upstream}, FillOptions{Coercion: CoercionPolicy{Mode: mode}})
+		data, _ = json.Marshal(fill)
+		assertRedacted(t, "FillFromData", string(data))
+	}
+
+	for _, f := range withValues.Fields() {
+		_, err := f.DateValue()
+		assertRedacted(t, f.FieldID+" DateValue", fmt.Sprint(err))
+		_, err = f.DecimalValue()
+		assertRedacted(t, f.FieldID+" DecimalValue", fmt.Sprint(err))
+		_, err = f.BoolValue()
+		assertRedacted(t, f.FieldID+" BoolValue", fmt.Sprint(err))
+		_, err = f.TypedValue()
+		assertRedacted(t, f.FieldID+" TypedValue", fmt.Sprint(err))
+		_, err = f.FormatValue(fixtureSSN + "0")
+		assertRedacted(t, f.FieldID+" FormatValue", fmt.Sprint(err))
+		_, err = f.DistributeValue(fixtureSSN + "0")
+		assertRedacted(t, f.FieldID+" DistributeValue", fmt.Sprint(err))
+		c := f.Clone()
+		err = c.SetTypedValue(fixtureSSN)
+		assertRedacted(t, f.FieldID+" SetTypedValue", fmt.Sprint(err))
+		assertRedacted(t, f.FieldID+" Describe", f.Describe())
+		assertRedacted(t, f.FieldID+" String", fmt.Sprint(f))
+	}
+}
+
+// TestImportValuesErrorsRedacted checks that malformed import lines are
+// reported by line number and field ID, without the text of the line.
+func TestImportValuesErrorsRedacted(t *testing.T) {
+	for _, input := range []string{
+		"ssn " + fixtureSSN + "\n",
+		`ssn = "` + fixtureSSN + "\n",
+		`ssn = "` + fixtureSSN + `\q"` + "\n",
+	} {
+		_, err := sensitiveForm().ImportValues(strings.NewReader(input), ValuesKeyValue, FillOptions{})
+		if err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Errorf("ImportValues(%q) err = %v, want one naming line 1", input, err)
+		}
+		assertRedacted(t, "ImportValues", fmt.Sprint(err))
+	}
+}
+
+func init() {
+	RegisterTransform("test_echo_reject", TransformFunc(func(v string) (string, error) {
+		return "", fmt.Errorf("rejected %q", v)
+	}))
+}
+
+// TestTransformErrorsRedacted checks that a failing transform on a
+// sensitive field is reported without the value, even when the transform
+// itself quotes it.
+func TestTransformErrorsRedacted(t *testing.T) {
+	for _, name := range []string{"usps_state", "test_echo_reject"} {
+		fa := sensitiveForm()
+		fa.Pages[0].Fields[0].Transforms = []string{name}
+		fill := fa.FillFromData(map[string]any{"taxpayer": map[string]any{"ssn": fixtureSSN}}, FillOptions{})
+		if len(fill.Issues) != 1 || fill.Issues[0].Code != FillTransformFailed {
+			t.Fatalf("%s: issues = %v, want one transform failure", name, fill.Issues)
+		}
+		data, _ := json.Marshal(fill)
+		assertRedacted(t, name+" FillFromData", string(data))
+	}
+
+	fa := sensitiveForm()
+	fa.Pages[0].Fields[0].Transforms = []string{"usps_state"}
+	fa.Pages[0].Fields[0].Value = fixtureSSN
+	_, err := fa.ExtractValues(ExtractOptions{})
+	var te *TransformError
+	if !errors.As(err, &te) || te.Transform != "usps_state" {
+		t.Fatalf("ExtractValues err = %v, want a *TransformError", err)
+	}
+	assertRedacted(t, "ExtractValues", err.Error())
+
+	plain := &Field{FieldID: "state", Transforms: []string{"usps_state"}}
+	if _, err := applyTransforms(plain, "Atlantis"); err == nil || !strings.Contains(err.Error(), "not a US state") {
+		t.Errorf("non-sensitive field err = %v, want the transform's reason", err)
+	}
+}
+
+func TestSetSensitiveFormatter(t *testing.T) {
+	defer SetSensitiveFormatter(nil)
+	SetSensitiveFormatter(func(f *Field, value string) string { return "<" + f.FieldID + ">" })
+	f := &Field{FieldID: "ssn", FieldType: FieldTypeText, Value: fixtureSSN}
+	if got, want := f.Describe(), `ssn (text) = "<ssn>"`; got != want {
+		t.Errorf("Describe = %q, want %q", got, want)
+	}
+	SetSensitiveFormatter(nil)
+	if got := displayValue(f, fixtureSSN); got != "***-**-4321" {
+		t.Errorf("default formatter shows %q", got)
+	}
+	amount := &Field{FieldID: "wages", FieldType: FieldTypeCurrency, Value: "1234.56"}
+	if got := displayValue(amount, amount.Value); got != "1234.56" {
+		t.Errorf("amount shown as %q", got)
+	}
+}
@@ -0,0 +1,108 @@
+// Package registry looks up annotations bundled with an application, such
+// as with embed, by form ID and year. Filesystems are registered once, at
+// start-up; each annotation is read on its first lookup and kept.
+package registry
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+	"sync"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// entry is one registered annotation, loaded on first use.
+type entry struct {
+	origin string
+	load   func() (*annotation.FormAnnotation, error)
+}
+
+var (
+	mu    sync.RWMutex
+	forms = map[string]map[int]*entry{}
+)
+
+// Register indexes every .json annotation in fsys by the form ID and year
+// of its metadata, reading only the metadata, as ListForms does. It panics
+// if fsys cannot be read or holds a form and year already registered,
+// since a bundled filesystem that fails once always will.
+func Register(fsys fs.FS) {
+	RegisterWithOptions(fsys, annotation.LoadOptions{})
+}
+
+// RegisterWithOptions is Register with the options each annotation is
+// loaded with.
+func RegisterWithOptions(fsys fs.FS, opts annotation.LoadOptions) {
+	store := annotation.FSStore{FS: fsys}
+	entries, err := annotation.ListForms(context.Background(), store, "")
+	if err != nil {
+		panic("registry: Register: " + err.Error())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	for _, e := range entries {
+		md := e.Metadata
+		if md.FormID == "" {
+			panic("registry: " + e.Key + " has no form ID")
+		}
+		years := forms[md.FormID]
+		if years == nil {
+			years = map[int]*entry{}
+			forms[md.FormID] = years
+		}
+		if prev, ok := years[md.Year]; ok {
+			panic(fmt.Sprintf("registry: %s and %s both hold %s/%d", prev.origin, e.Key, md.FormID, md.Year))
+		}
+		key := e.Key
+		years[md.Year] = &entry{origin: key, load: sync.OnceValues(func() (*annotation.FormAnnotation, error) {
+			return annotation.LoadFromStore(context.Background(), store, key, opts)
+		})}
+	}
+}
+
+// Get returns a copy of the annotation for formID and year, which the
+// caller owns. A year with no annotation falls back to the latest earlier
+// year, as a form is usually unchanged until a new revision is annotated,
+// and a zero year asks for the latest; the copy's FormMetadata.Year is the
+// year found. A form or year that cannot be found wraps fs.ErrNotExist.
+// The first Get of an annotation reads it and later ones copy the cached
+// document; a read error is kept as well.
+func Get(formID string, year int) (*annotation.FormAnnotation, error) {
+	mu.RLock()
+	years := forms[formID]
+	e, ok := years[year]
+	if !ok {
+		found := 0
+		for y := range years {
+			if (year == 0 || y < year) && (found == 0 || y > found) {
+				e, found, ok = years[y], y, true
+			}
+		}
+	}
+	mu.RUnlock()
+	switch {
+	case len(years) == 0:
+		return nil, fmt.Errorf("registry: form %q: %w", formID, fs.ErrNotExist)
+	case !ok:
+		return nil, fmt.Errorf("registry: form %q has no annotation for %d or earlier: %w", formID, year, fs.ErrNotExist)
+	}
+	fa, err := e.load()
+	if err != nil {
+		return nil, fmt.Errorf("registry: %s: %w", e.origin, err)
+	}
+	return fa.Clone(), nil
+}
+
+// Years returns the registered years of formID in ascending order.
+func Years(formID string) []int {
+	mu.RLock()
+	defer mu.RUnlock()
+	years := make([]int, 0, len(forms[formID]))
+	for y := range forms[formID] {
+		years = append(years, y)
+	}
+	slices.Sort(years)
+	return years
+}
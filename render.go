@@ -0,0 +1,77 @@
+package annotation
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// pageGeometry converts annotation coordinates to output-device coordinates
+// for one page. Annotation positions use a top-left origin, as do images and
+// SVG, so only the scale changes. Every renderer goes through this type so
+// that unit handling stays in one place.
+type pageGeometry struct {
+	unit   string  // fallback unit for positions that declare none
+	scale  float64 // output units per point
+	width  float64 // page width in output units
+	height float64 // page height in output units
+}
+
+// renderBox is a rectangle in output units.
+type renderBox struct {
+	X, Y, W, H float64
+}
+
+// newPageGeometry prepares the conversion for a page of size ps rendered at
+// dpi output units per inch; 72 keeps output in points.
+func newPageGeometry(ps PageSize, dpi float64) (pageGeometry, error) {
+	page, ok := pageInPoints(ps)
+	if !ok {
+		return pageGeometry{}, fmt.Errorf("unknown page unit %q", ps.Unit)
+	}
+	if page.Width <= 0 || page.Height <= 0 {
+		return pageGeometry{}, fmt.Errorf("page size %gx%g is not positive", ps.Width, ps.Height)
+	}
+	scale := dpi / 72
+	return pageGeometry{unit: ps.Unit, scale: scale, width: page.Width * scale, height: page.Height * scale}, nil
+}
+
+// box converts p to output units.
+func (g pageGeometry) box(p Position) (renderBox, bool) {
+	r, ok := positionInPoints(p, g.unit)
+	if !ok {
+		return renderBox{}, false
+	}
+	return renderBox{r.X * g.scale, r.Y * g.scale, r.Width * g.scale, r.Height * g.scale}, true
+}
+
+// union returns the smallest box covering b and o.
+func (b renderBox) union(o renderBox) renderBox {
+	x0, y0 := min(b.X, o.X), min(b.Y, o.Y)
+	x1, y1 := max(b.X+b.W, o.X+o.W), max(b.Y+b.H, o.Y+o.H)
+	return renderBox{x0, y0, x1 - x0, y1 - y0}
+}
+
+// pixels rounds the box to whole pixels.
+func (b renderBox) pixels() image.Rectangle {
+	return image.Rect(int(math.Round(b.X)), int(math.Round(b.Y)),
+		int(math.Round(b.X+b.W)), int(math.Round(b.Y+b.H)))
+}
+
+// fieldBoxes returns the boxes drawn for a field: one per segment for
+// segmented fields, otherwise the field's own position.
+func (g pageGeometry) fieldBoxes(f *Field) []renderBox {
+	var boxes []renderBox
+	if len(f.Segments) > 0 {
+		for _, seg := range f.Segments {
+			if b, ok := g.box(seg.Position); ok {
+				boxes = append(boxes, b)
+			}
+		}
+		return boxes
+	}
+	if b, ok := g.box(f.Position); ok {
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	"strings"
+)
+
+// stdFont is one of the standard Type 1 fonts every PDF reader provides,
+// with the metrics needed to place text.
+type stdFont struct {
+	base string
+	// widths holds the advance of ASCII 32-126 in thousandths of the font
+	// size; nil for monospaced fonts, which use fixed.
+	widths *[95]int
+	fixed  int
+	// capHeight and descent are in thousandths of the font size.
+	capHeight, descent int
+}
+
+// helveticaWidths are the Helvetica advances of ASCII 32-126.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// timesWidths are the Times-Roman advances of ASCII 32-126.
+var timesWidths = [95]int{
+	250, 333, 408, 500, 500, 833, 778, 180, 333, 333, 500, 564, 250, 333, 250, 278,
+	500, 500, 500, 500, 500, 500, 500, 500, 500, 500, 278, 278, 564, 564, 564, 444,
+	921, 722, 667, 667, 722, 611, 556, 722, 722, 333, 389, 722, 611, 889, 722, 722,
+	556, 722, 667, 556, 611, 722, 722, 944, 722, 722, 611, 333, 278, 333, 469, 500,
+	333, 444, 500, 444, 500, 444, 333, 500, 500, 278, 278, 500, 278, 778, 500, 500,
+	500, 500, 333, 389, 278, 500, 500, 722, 500, 500, 444, 480, 200, 480, 541,
+}
+
+// pickFont maps a TextStyle font family and weight to a standard font:
+// Courier for monospaced families, Times for serif ones, and Helvetica for
+// the rest. Bold faces are measured with the regular face's widths, which
+// is close enough to align digits, whose widths the faces share.
+func pickFont(family, weight string) stdFont {
+	family = strings.ToLower(family)
+	bold := strings.EqualFold(weight, "bold") || weight == "600" || weight == "700" || weight == "800" || weight == "900"
+	switch {
+	case strings.Contains(family, "courier") || strings.Contains(family, "mono"):
+		f := stdFont{base: "Courier", fixed: 600, capHeight: 571, descent: 157}
+		if bold {
+			f.base = "Courier-Bold"
+		}
+		return f
+	case strings.Contains(family, "times") || (strings.Contains(family, "serif") && !strings.Contains(family, "sans")):
+		f := stdFont{base: "Times-Roman", widths: &timesWidths, capHeight: 662, descent: 217}
+		if bold {
+			f.base = "Times-Bold"
+		}
+		return f
+	}
+	f := stdFont{base: "Helvetica", widths: &helveticaWidths, capHeight: 718, descent: 207}
+	if bold {
+		f.base = "Helvetica-Bold"
+	}
+	return f
+}
+
+// width returns the advance of text, encoded in WinAnsi, in thousandths
+// of the font size. Characters beyond ASCII are measured as an "n".
+func (f stdFont) width(text []byte) int {
+	w := 0
+	for _, c := range text {
+		switch {
+		case f.widths == nil:
+			w += f.fixed
+		case c >= 32 && c <= 126:
+			w += f.widths[c-32]
+		default:
+			w += f.widths['n'-32]
+		}
+	}
+	return w
+}
+
+// winAnsiHigh maps the characters WinAnsiEncoding places at 0x80-0x9F.
+var winAnsiHigh = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// winAnsi encodes s in WinAnsiEncoding, the encoding the standard fonts
+// are used with. Control characters become spaces and characters the
+// encoding lacks become "?".
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r < 32:
+			out = append(out, ' ')
+		case r < 127 || (r >= 0xA0 && r <= 0xFF):
+			out = append(out, byte(r))
+		case winAnsiHigh[r] != 0:
+			out = append(out, winAnsiHigh[r])
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
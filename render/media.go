@@ -0,0 +1,177 @@
+package render
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+	"github.com/amoghkashyap86/form-annotation/internal/pdf"
+)
+
+// barcode fills the dark modules of a symbol, stretched over b, one
+// rectangle per run of dark modules in a row.
+func (d *drawer) barcode(b rect, bc *annotation.StampBarcode) {
+	if len(bc.Modules) == 0 || len(bc.Modules[0]) == 0 {
+		return
+	}
+	mw, mh := b.w/float64(len(bc.Modules[0])), b.h/float64(len(bc.Modules))
+	d.ops.WriteString("q 0 0 0 rg\n")
+	for i, row := range bc.Modules {
+		y := b.y + b.h - float64(i+1)*mh
+		for j := 0; j < len(row); j++ {
+			if row[j] != '1' {
+				continue
+			}
+			k := j
+			for k < len(row) && row[k] == '1' {
+				k++
+			}
+			fmt.Fprintf(&d.ops, "%s %s %s %s re\n", num(b.x+float64(j)*mw), num(y), num(float64(k-j)*mw), num(mh))
+			j = k
+		}
+	}
+	d.ops.WriteString("f Q\n")
+}
+
+// picture draws an image in b by its fit mode, clipped to b.
+func (d *drawer) picture(b rect, img *annotation.StampImage) error {
+	xo, err := imageXObject(img)
+	if err != nil {
+		return err
+	}
+	w, h := float64(xo.width), float64(xo.height)
+	dw, dh := b.w, b.h
+	switch img.Fit {
+	case annotation.ImageFitContain, "":
+		s := min(b.w/w, b.h/h)
+		dw, dh = w*s, h*s
+	case annotation.ImageFitCover:
+		s := max(b.w/w, b.h/h)
+		dw, dh = w*s, h*s
+	case annotation.ImageFitNone:
+		// A pixel is a point of the annotation's page.
+		dw, dh = w*d.sx, h*d.sy
+	}
+	x, y := b.x+(b.w-dw)/2, b.y+(b.h-dh)/2
+	name := pdf.Name("FAnnotImg" + strconv.Itoa(len(d.images)+1))
+	d.images = append(d.images, placedImage{name: name, xobject: xo})
+	fmt.Fprintf(&d.ops, "q %s %s %s %s re W n %s 0 0 %s %s %s cm /%s Do Q\n",
+		num(b.x), num(b.y), num(b.w), num(b.h), num(dw), num(dh), num(x), num(y), name)
+	return nil
+}
+
+// placedImage is an image drawn on a page under a placeholder name.
+type placedImage struct {
+	name    pdf.Name
+	xobject *xobject
+}
+
+// xobject is an image XObject with its soft mask, if it has transparency.
+type xobject struct {
+	width, height int
+	image, mask   *pdf.Stream
+}
+
+// imageXObject builds the XObject of a PNG or JPEG image. JPEG data is
+// embedded as it is; PNG images are decoded and stored compressed, with
+// their alpha channel as a soft mask.
+func imageXObject(img *annotation.StampImage) (*xobject, error) {
+	if img.MediaType == "image/jpeg" {
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(img.Data))
+		if err != nil {
+			return nil, fmt.Errorf("image: %w", err)
+		}
+		dict := pdf.Dict{"Type": pdf.Name("XObject"), "Subtype": pdf.Name("Image"),
+			"Width": int64(cfg.Width), "Height": int64(cfg.Height), "BitsPerComponent": int64(8),
+			"Filter": pdf.Name("DCTDecode")}
+		switch cfg.ColorModel {
+		case color.GrayModel:
+			dict["ColorSpace"] = pdf.Name("DeviceGray")
+		case color.CMYKModel:
+			// Adobe writes CMYK JPEGs inverted.
+			dict["ColorSpace"] = pdf.Name("DeviceCMYK")
+			dict["Decode"] = pdf.Array{int64(1), int64(0), int64(1), int64(0), int64(1), int64(0), int64(1), int64(0)}
+		default:
+			dict["ColorSpace"] = pdf.Name("DeviceRGB")
+		}
+		return &xobject{width: cfg.Width, height: cfg.Height, image: &pdf.Stream{Dict: dict, Data: img.Data}}, nil
+	}
+	src, err := png.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("image: %w", err)
+	}
+	bounds := src.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	opaque := true
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(src.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 0xff
+		}
+	}
+	stream := func(data []byte, colorSpace string) (*pdf.Stream, error) {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(data); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return &pdf.Stream{Dict: pdf.Dict{"Type": pdf.Name("XObject"), "Subtype": pdf.Name("Image"),
+			"Width": int64(bounds.Dx()), "Height": int64(bounds.Dy()), "BitsPerComponent": int64(8),
+			"ColorSpace": pdf.Name(colorSpace), "Filter": pdf.Name("FlateDecode")}, Data: z.Bytes()}, nil
+	}
+	xo := &xobject{width: bounds.Dx(), height: bounds.Dy()}
+	if xo.image, err = stream(rgb, "DeviceRGB"); err != nil {
+		return nil, err
+	}
+	if !opaque {
+		if xo.mask, err = stream(alpha, "DeviceGray"); err != nil {
+			return nil, err
+		}
+	}
+	return xo, nil
+}
+
+// addImages adds the page's images to the update and to resources under
+// names it does not already use, returning the renaming of placeholders.
+func (s *stamper) addImages(d *drawer, resources pdf.Dict) (map[pdf.Name]pdf.Name, error) {
+	names := map[pdf.Name]pdf.Name{}
+	if len(d.images) == 0 {
+		return names, nil
+	}
+	xobjects := pdf.Dict{}
+	existing, err := s.f.Resolve(resources["XObject"])
+	if err != nil {
+		return nil, err
+	}
+	if xd, ok := existing.(pdf.Dict); ok {
+		for k, v := range xd {
+			xobjects[k] = v
+		}
+	}
+	n := 0
+	for _, img := range d.images {
+		name := pdf.Name("")
+		for name == "" || xobjects[name] != nil {
+			n++
+			name = pdf.Name("FAnnotImg" + strconv.Itoa(n))
+		}
+		if img.xobject.mask != nil {
+			img.xobject.image.Dict["SMask"] = s.u.Add(img.xobject.mask)
+		}
+		xobjects[name] = s.u.Add(img.xobject.image)
+		names[img.name] = name
+	}
+	resources["XObject"] = xobjects
+	return names, nil
+}
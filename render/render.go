@@ -0,0 +1,439 @@
+// Package render stamps an annotation's filled values onto the PDF of the
+// form it describes. The template is left byte for byte as it was: the
+// values are appended as an incremental update that adds a content stream
+// to each stamped page, drawn in the standard PDF fonts so that nothing
+// needs embedding.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+	"github.com/amoghkashyap86/form-annotation/internal/pdf"
+)
+
+// defaultFontSize is used for fields whose style sets no font size.
+const defaultFontSize = 10
+
+// textInset keeps text this many points off the left and right edges of
+// its box.
+const textInset = 2
+
+// RenderPDF writes templatePDF to w with the annotation's filled values
+// drawn on it, as RenderPDFWithOptions does with the default options. A
+// value that cannot be placed fails the render.
+func RenderPDF(fa *annotation.FormAnnotation, templatePDF io.Reader, w io.Writer) error {
+	_, err := RenderPDFWithOptions(fa, templatePDF, w, annotation.FillOptions{})
+	return err
+}
+
+// RenderPDFWithOptions writes templatePDF to w with the values of the stamp
+// plan BuildStampPlan builds under opts drawn on it. Annotation page N is
+// the template's Nth page; positions are scaled from the annotation's page
+// size to each page's media box. Text is drawn in Courier, Times or
+// Helvetica after the field's font family, aligned and sized by its style;
+// segmented values are drawn one character per cell; checked boxes get
+// their mark as a vector shape: an X, a check, a filled square or a dot.
+// Barcodes are drawn as filled modules stretched over the field, and
+// images are embedded, JPEGs as they are, and placed by their fit mode.
+// The pages are drawn through FormAnnotation.RenderWith.
+//
+// Nothing is written when the plan reports errors; the error joins them.
+// Rotated pages and encrypted templates are refused.
+func RenderPDFWithOptions(fa *annotation.FormAnnotation, templatePDF io.Reader, w io.Writer, opts annotation.FillOptions) (*annotation.FillReport, error) {
+	data, err := io.ReadAll(templatePDF)
+	if err != nil {
+		return nil, fmt.Errorf("render pdf: %w", err)
+	}
+	f, err := pdf.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("render pdf: template: %w", err)
+	}
+	pages, err := f.Pages()
+	if err != nil {
+		return nil, fmt.Errorf("render pdf: template: %w", err)
+	}
+	r := &pdfRenderer{fa: fa, pages: pages, s: &stamper{f: f, u: pdf.NewUpdate(f), fonts: map[string]pdf.Ref{}}}
+	report, err := fa.RenderWith(r, opts)
+	if err != nil {
+		return report, fmt.Errorf("render pdf: %w", err)
+	}
+	if err := r.s.u.Write(w); err != nil {
+		return report, fmt.Errorf("render pdf: %w", err)
+	}
+	return report, nil
+}
+
+// pdfRenderer is the annotation.Renderer that draws on the template's
+// pages. A page is only looked up, and stamped, once something is drawn on
+// it, so annotation pages left blank need not be in the template.
+type pdfRenderer struct {
+	fa    *annotation.FormAnnotation
+	pages []pdf.Page
+	s     *stamper
+	n     int
+	size  annotation.PageSize
+	d     *drawer
+}
+
+func (r *pdfRenderer) BeginPage(n int, size annotation.PageSize) error {
+	r.n, r.size, r.d = n, size, nil
+	return nil
+}
+
+func (r *pdfRenderer) EndPage(n int) error {
+	if r.d == nil {
+		return nil
+	}
+	return r.s.stampPage(r.d.page, r.d)
+}
+
+// drawer returns the drawer of the current page, starting it on first use.
+func (r *pdfRenderer) drawer() (*drawer, error) {
+	if r.d != nil {
+		return r.d, nil
+	}
+	if r.n < 1 || r.n > len(r.pages) {
+		return nil, fmt.Errorf("annotation page %d is not in the %d-page template", r.n, len(r.pages))
+	}
+	page := r.pages[r.n-1]
+	if page.Rotate%360 != 0 {
+		return nil, fmt.Errorf("template page %d is rotated %d degrees", r.n, page.Rotate)
+	}
+	d := &drawer{fa: r.fa, page: page, fonts: map[string]pdf.Name{}}
+	d.sx, d.sy = 1, 1
+	if r.size.Width > 0 && r.size.Height > 0 {
+		d.sx = (page.MediaBox[2] - page.MediaBox[0]) / r.size.Width
+		d.sy = (page.MediaBox[3] - page.MediaBox[1]) / r.size.Height
+	}
+	r.d = d
+	return d, nil
+}
+
+// draw runs fn with the current page's drawer and the item's box.
+func (r *pdfRenderer) draw(item annotation.StampItem, fn func(d *drawer, b rect, style *annotation.TextStyle) error) error {
+	d, err := r.drawer()
+	if err != nil {
+		return err
+	}
+	b, err := d.box(item.Position)
+	if err != nil {
+		return err
+	}
+	style := item.Style
+	if style == nil {
+		style = &annotation.TextStyle{}
+	}
+	return fn(d, b, style)
+}
+
+func (r *pdfRenderer) RenderText(item annotation.StampItem) error {
+	return r.draw(item, func(d *drawer, b rect, style *annotation.TextStyle) error {
+		d.text(b, item.Text, style, style.TextAlign)
+		return nil
+	})
+}
+
+func (r *pdfRenderer) RenderCheckbox(item annotation.StampItem) error {
+	return r.draw(item, func(d *drawer, b rect, style *annotation.TextStyle) error {
+		d.mark(b, item.Check, style)
+		return nil
+	})
+}
+
+func (r *pdfRenderer) RenderSegmented(item annotation.StampItem) error {
+	return r.draw(item, func(d *drawer, _ rect, style *annotation.TextStyle) error {
+		return d.cells(item, style)
+	})
+}
+
+func (r *pdfRenderer) RenderBarcode(item annotation.StampItem) error {
+	return r.draw(item, func(d *drawer, b rect, _ *annotation.TextStyle) error {
+		d.barcode(b, item.Barcode)
+		return nil
+	})
+}
+
+func (r *pdfRenderer) RenderImage(item annotation.StampItem) error {
+	return r.draw(item, func(d *drawer, b rect, _ *annotation.TextStyle) error {
+		return d.picture(b, item.Image)
+	})
+}
+
+// drawer builds the content stream of one page.
+type drawer struct {
+	fa     *annotation.FormAnnotation
+	page   pdf.Page
+	sx, sy float64
+	ops    bytes.Buffer
+	// fonts maps the base fonts used to their resource names, assigned
+	// when the page's resources are written.
+	fonts map[string]pdf.Name
+	used  []stdFont
+	// images are the pictures drawn, named by placeholders until the
+	// page's resources are written.
+	images []placedImage
+}
+
+// rect is a box in PDF user space, from its lower left corner.
+type rect struct{ x, y, w, h float64 }
+
+// box converts an annotation position, measured in its unit from the top
+// left of the page, to user space.
+func (d *drawer) box(p annotation.Position) (rect, error) {
+	if p.Unit == "" {
+		p.Unit = d.fa.FormMetadata.PageSize.Unit
+	}
+	pt, err := annotation.ConvertPosition(p, "pt")
+	if err != nil {
+		return rect{}, err
+	}
+	w, h := pt.Width*d.sx, pt.Height*d.sy
+	return rect{x: d.page.MediaBox[0] + pt.X*d.sx, y: d.page.MediaBox[3] - pt.Y*d.sy - h, w: w, h: h}, nil
+}
+
+// cells draws a segmented value one character per cell of each segment,
+// each centered.
+func (d *drawer) cells(item annotation.StampItem, style *annotation.TextStyle) error {
+	var lengths []int
+	if f := d.fa.GetFieldByID(item.FieldID); f != nil {
+		for _, seg := range f.Segments {
+			lengths = append(lengths, seg.Length)
+		}
+	}
+	for i, cell := range item.Cells {
+		cb, err := d.box(cell.Position)
+		if err != nil {
+			return err
+		}
+		runes := []rune(cell.Text)
+		n := len(runes)
+		if i < len(lengths) && lengths[i] > n {
+			n = lengths[i]
+		}
+		for j, r := range runes {
+			sub := rect{x: cb.x + cb.w*float64(j)/float64(n), y: cb.y, w: cb.w / float64(n), h: cb.h}
+			if r != ' ' {
+				d.text(sub, string(r), style, annotation.TextAlignCenter)
+			}
+		}
+	}
+	return nil
+}
+
+// font returns the resource name of f on this page.
+func (d *drawer) font(f stdFont) pdf.Name {
+	if name, ok := d.fonts[f.base]; ok {
+		return name
+	}
+	// Placeholder names are replaced by unused ones in stampPage.
+	name := pdf.Name("FAnnot" + strconv.Itoa(len(d.used)+1))
+	d.fonts[f.base] = name
+	d.used = append(d.used, f)
+	return name
+}
+
+func (d *drawer) text(b rect, s string, style *annotation.TextStyle, align string) {
+	text := winAnsi(s)
+	if len(text) == 0 {
+		return
+	}
+	f := pickFont(style.FontFamily, style.FontWeight)
+	size := style.FontSize
+	if size <= 0 {
+		size = defaultFontSize
+	}
+	size *= d.sy
+	spacing := style.LetterSpacing * d.sx
+	width := float64(f.width(text))*size/1000 + spacing*float64(len(text)-1)
+	x := b.x + textInset
+	switch align {
+	case annotation.TextAlignRight:
+		x = b.x + b.w - textInset - width
+	case annotation.TextAlignCenter:
+		x = b.x + (b.w-width)/2
+	}
+	capHeight := float64(f.capHeight) * size / 1000
+	var y float64
+	switch style.VerticalAlign {
+	case "top":
+		y = b.y + b.h - capHeight - 1
+	case "bottom":
+		y = b.y + float64(f.descent)*size/1000 + 1
+	default:
+		y = b.y + (b.h-capHeight)/2
+	}
+	r, g, bl := parseColor(style.Color)
+	fmt.Fprintf(&d.ops, "BT /%s %s Tf %s %s %s rg", d.font(f), num(size), num(r), num(g), num(bl))
+	if spacing != 0 {
+		fmt.Fprintf(&d.ops, " %s Tc", num(spacing))
+	}
+	fmt.Fprintf(&d.ops, " %s %s Td <%X> Tj ET\n", num(x), num(y), text)
+}
+
+// mark draws a checkbox mark centered in b.
+func (d *drawer) mark(b rect, cs *annotation.CheckStyle, style *annotation.TextStyle) {
+	size := 0.7 * min(b.w, b.h)
+	markType, weight := "X", ""
+	if cs != nil {
+		if cs.MarkSize > 0 {
+			size = min(cs.MarkSize*d.sy, b.w, b.h)
+		}
+		if cs.MarkType != "" {
+			markType = cs.MarkType
+		}
+		weight = cs.MarkWeight
+	}
+	line := size * 0.1
+	switch strings.ToLower(weight) {
+	case "bold", "heavy":
+		line = size * 0.15
+	case "light", "thin":
+		line = size * 0.06
+	}
+	cx, cy, h := b.x+b.w/2, b.y+b.h/2, size/2
+	r, g, bl := parseColor(style.Color)
+	fmt.Fprintf(&d.ops, "q %s %s %s RG %s %s %s rg %s w 1 J 1 j\n", num(r), num(g), num(bl), num(r), num(g), num(bl), num(line))
+	// Strokes are inset by half the line width so the mark stays in size.
+	in := h - line/2
+	switch strings.ToLower(markType) {
+	case "check", "checkmark", "✓", "✔":
+		fmt.Fprintf(&d.ops, "%s %s m %s %s l %s %s l S\n",
+			num(cx-in), num(cy), num(cx-in/3), num(cy-in), num(cx+in), num(cy+in))
+	case "fill", "filled", "solid", "square", "■":
+		fmt.Fprintf(&d.ops, "%s %s %s %s re f\n", num(cx-h), num(cy-h), num(size), num(size))
+	case "circle", "dot", "●":
+		// Four Bezier arcs approximate the circle.
+		k := 0.5523 * h
+		fmt.Fprintf(&d.ops, "%s %s m %s %s %s %s %s %s c %s %s %s %s %s %s c %s %s %s %s %s %s c %s %s %s %s %s %s c f\n",
+			num(cx+h), num(cy),
+			num(cx+h), num(cy+k), num(cx+k), num(cy+h), num(cx), num(cy+h),
+			num(cx-k), num(cy+h), num(cx-h), num(cy+k), num(cx-h), num(cy),
+			num(cx-h), num(cy-k), num(cx-k), num(cy-h), num(cx), num(cy-h),
+			num(cx+k), num(cy-h), num(cx+h), num(cy-k), num(cx+h), num(cy))
+	default:
+		fmt.Fprintf(&d.ops, "%s %s m %s %s l %s %s m %s %s l S\n",
+			num(cx-in), num(cy-in), num(cx+in), num(cy+in), num(cx-in), num(cy+in), num(cx+in), num(cy-in))
+	}
+	d.ops.WriteString("Q\n")
+}
+
+func num(v float64) string { return pdf.FormatNumber(v) }
+
+// parseColor reads a "#rrggbb" or "#rgb" color as fractions; anything else
+// is black.
+func parseColor(s string) (r, g, b float64) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return 0, 0, 0
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, 0, 0
+	}
+	return float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255
+}
+
+// stamper writes the drawn pages as an update of the template, sharing one
+// font object per base font across pages.
+type stamper struct {
+	f     *pdf.File
+	u     *pdf.Update
+	fonts map[string]pdf.Ref
+}
+
+// stampPage replaces the page's dictionary with one whose contents end with
+// the drawer's stream, wrapped so that the original contents cannot leave
+// the graphics state changed, and whose resources name the fonts and
+// images used.
+func (s *stamper) stampPage(page pdf.Page, d *drawer) error {
+	if d.ops.Len() == 0 {
+		return nil
+	}
+	dict := pdf.Dict{}
+	for k, v := range page.Dict {
+		dict[k] = v
+	}
+	contents := pdf.Array{s.u.Add(&pdf.Stream{Dict: pdf.Dict{}, Data: []byte("q\n")})}
+	existing, err := s.f.Resolve(page.Dict["Contents"])
+	if err != nil {
+		return err
+	}
+	switch c := existing.(type) {
+	case pdf.Array:
+		contents = append(contents, c...)
+	case *pdf.Stream:
+		contents = append(contents, page.Dict["Contents"])
+	}
+
+	res, err := s.f.Resolve(page.Resources)
+	if err != nil {
+		return err
+	}
+	resources := pdf.Dict{}
+	if rd, ok := res.(pdf.Dict); ok {
+		for k, v := range rd {
+			resources[k] = v
+		}
+	}
+	fonts := pdf.Dict{}
+	if fd, err := s.f.Resolve(resources["Font"]); err == nil {
+		if fd, ok := fd.(pdf.Dict); ok {
+			for k, v := range fd {
+				fonts[k] = v
+			}
+		}
+	}
+	// Give each font used a name the page does not already use.
+	names := map[pdf.Name]pdf.Name{}
+	n := 0
+	for _, f := range d.used {
+		name := pdf.Name("")
+		for name == "" || fonts[name] != nil {
+			n++
+			name = pdf.Name("FAnnot" + strconv.Itoa(n))
+		}
+		ref, ok := s.fonts[f.base]
+		if !ok {
+			ref = s.u.Add(pdf.Dict{"Type": pdf.Name("Font"), "Subtype": pdf.Name("Type1"), "BaseFont": pdf.Name(f.base), "Encoding": pdf.Name("WinAnsiEncoding")})
+			s.fonts[f.base] = ref
+		}
+		fonts[name] = ref
+		names[d.fonts[f.base]] = name
+	}
+	resources["Font"] = fonts
+	images, err := s.addImages(d, resources)
+	if err != nil {
+		return err
+	}
+	for p, name := range images {
+		names[p] = name
+	}
+	ops := d.ops.String()
+	// Rename the placeholders, longest first so FAnnot12 is not read as
+	// FAnnot1 followed by 2.
+	placeholders := make([]string, 0, len(names))
+	for p := range names {
+		placeholders = append(placeholders, string(p))
+	}
+	sort.Slice(placeholders, func(i, j int) bool { return len(placeholders[i]) > len(placeholders[j]) })
+	var pairs []string
+	for _, p := range placeholders {
+		pairs = append(pairs, "/"+p+" ", "/"+string(names[pdf.Name(p)])+" ")
+	}
+	ops = strings.NewReplacer(pairs...).Replace(ops)
+
+	contents = append(contents, s.u.Add(&pdf.Stream{Dict: pdf.Dict{}, Data: []byte("Q\nq\n" + ops + "Q\n")}))
+	dict["Contents"] = contents
+	dict["Resources"] = resources
+	s.u.Replace(page.Ref, dict)
+	return nil
+}
@@ -0,0 +1,114 @@
+package render
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+	"github.com/amoghkashyap86/form-annotation/internal/pdf"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files under testdata")
+
+// golden compares got with the file testdata/name, or writes it under -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file:\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// stampForm describes testdata/template.pdf: a name, an amount and a
+// checkbox below the page's printed title.
+func stampForm() *annotation.FormAnnotation {
+	return &annotation.FormAnnotation{
+		FormMetadata: annotation.FormMetadata{FormID: "test", PageCount: 1,
+			PageSize: annotation.PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []annotation.Page{{PageNumber: 1, Fields: []annotation.Field{
+			{FieldID: "name", FieldType: annotation.FieldTypeText, DataType: annotation.DataTypeString, Value: "Ada Lovelace",
+				Position: annotation.Position{X: 36, Y: 72, Width: 200, Height: 18, Unit: "pt"}},
+			{FieldID: "wages", FieldType: annotation.FieldTypeCurrency, DataType: annotation.DataTypeDecimal, Value: "1234.5",
+				Position:   annotation.Position{X: 36, Y: 96, Width: 120, Height: 18, Unit: "pt"},
+				Formatting: &annotation.Formatting{DecimalPlaces: 2, ShowCommas: true},
+				Style:      &annotation.TextStyle{FontFamily: "Courier", FontSize: 12, TextAlign: "right"}},
+			{FieldID: "agree", FieldType: annotation.FieldTypeCheckbox, DataType: annotation.DataTypeBoolean, Value: "true",
+				Position:   annotation.Position{X: 36, Y: 120, Width: 12, Height: 12, Unit: "pt"},
+				CheckStyle: &annotation.CheckStyle{MarkType: "x"}},
+		}}},
+	}
+}
+
+// TestRenderPDF stamps a fixture template and checks that the original
+// bytes are kept, the page count is unchanged and the added content stream
+// draws the values.
+func TestRenderPDF(t *testing.T) {
+	template, err := os.ReadFile(filepath.Join("testdata", "template.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := RenderPDF(stampForm(), bytes.NewReader(template), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(out.Bytes(), template) {
+		t.Fatal("the template was not kept as an incremental update's base")
+	}
+
+	f, err := pdf.Parse(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages, err := f.Pages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 1 {
+		t.Fatalf("%d pages, want 1", len(pages))
+	}
+	contents, ok := pages[0].Dict["Contents"].(pdf.Array)
+	if !ok || len(contents) != 3 {
+		t.Fatalf("Contents = %v, want the original stream wrapped by two new ones", pages[0].Dict["Contents"])
+	}
+	if contents[1] != (pdf.Ref{Num: 4}) {
+		t.Errorf("the original contents %v were not kept in place", contents[1])
+	}
+	obj, err := f.Resolve(contents[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := obj.(*pdf.Stream)
+	if !ok {
+		t.Fatalf("stamped contents are a %T", obj)
+	}
+	ops, err := f.Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "stamped.txt", ops)
+
+	res, err := f.Resolve(pages[0].Resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fonts, err := f.Resolve(res.(pdf.Dict)["Font"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fonts.(pdf.Dict)["F1"]; !ok {
+		t.Error("the template's own font was dropped from the page resources")
+	}
+}
@@ -0,0 +1,120 @@
+package annotation
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Renderer draws a filled form on a drawing backend, such as a PDF library
+// or an image, for RenderWith. Each hook receives an item of the stamp plan
+// with its positions, and those of its cells, in points from the top left
+// of the page; an error stops the render.
+type Renderer interface {
+	// BeginPage starts a page of the given size in points. Every page of
+	// the annotation is begun, in order, including pages with no values.
+	BeginPage(page int, size PageSize) error
+	// RenderText draws a text run: the value of a text, currency, numeric,
+	// date, choice or signature field, aligned by its style. Currency and
+	// numeric values with no alignment of their own are aligned right.
+	RenderText(item StampItem) error
+	// RenderCheckbox draws the mark of a checked box, by its check style.
+	RenderCheckbox(item StampItem) error
+	// RenderSegmented draws each cell of a segmented value, one character
+	// per cell of the segment.
+	RenderSegmented(item StampItem) error
+	// RenderBarcode draws the modules of item.Barcode over the position.
+	RenderBarcode(item StampItem) error
+	// RenderImage places item.Image in the position by its fit mode.
+	RenderImage(item StampItem) error
+	// EndPage finishes the page begun last.
+	EndPage(page int) error
+}
+
+// RenderWith draws the filled values with r, walking the pages in order and
+// calling the Renderer hook of each item of the stamp plan BuildStampPlan
+// builds under opts. The layout is worked out here; r only draws. Nothing
+// is drawn when the plan reports errors; the error joins them.
+func (fa *FormAnnotation) RenderWith(r Renderer, opts FillOptions) (*FillReport, error) {
+	plan, report := fa.BuildStampPlan(opts)
+	if report.HasErrors() {
+		var errs []error
+		for _, issue := range report.Issues {
+			if issue.Severity == SeverityError {
+				errs = append(errs, issue)
+			}
+		}
+		return report, errors.Join(errs...)
+	}
+	ps := fa.FormMetadata.PageSize
+	if _, ok := pageInPoints(ps); !ok {
+		return report, fmt.Errorf("unknown page unit %q", ps.Unit)
+	}
+	byPage := map[int][]StampItem{}
+	for _, item := range plan.Items {
+		byPage[item.Page] = append(byPage[item.Page], item)
+	}
+	var pageNums []int
+	for _, page := range fa.Pages {
+		pageNums = append(pageNums, page.PageNumber)
+	}
+	slices.Sort(pageNums)
+	for _, n := range slices.Compact(pageNums) {
+		size, _ := pageInPoints(fa.PageSizeOf(n))
+		if err := r.BeginPage(n, size); err != nil {
+			return report, fmt.Errorf("page %d: %w", n, err)
+		}
+		for _, item := range byPage[n] {
+			if err := renderItem(r, item, ps.Unit); err != nil {
+				return report, fmt.Errorf("field %q: %w", item.FieldID, err)
+			}
+		}
+		if err := r.EndPage(n); err != nil {
+			return report, fmt.Errorf("page %d: %w", n, err)
+		}
+	}
+	return report, nil
+}
+
+// renderItem converts item to points and passes it to its Renderer hook.
+func renderItem(r Renderer, item StampItem, unit string) error {
+	inPoints := func(p Position) (Position, error) {
+		pt, ok := positionInPoints(p, unit)
+		if !ok {
+			return Position{}, fmt.Errorf("unknown unit %q", cmp.Or(p.Unit, unit))
+		}
+		return pt, nil
+	}
+	var err error
+	if item.Position, err = inPoints(item.Position); err != nil {
+		return err
+	}
+	item.Cells = slices.Clone(item.Cells)
+	for i := range item.Cells {
+		if item.Cells[i].Position, err = inPoints(item.Cells[i].Position); err != nil {
+			return err
+		}
+	}
+	switch {
+	case item.FieldType == FieldTypeCheckbox:
+		return r.RenderCheckbox(item)
+	case item.Barcode != nil:
+		return r.RenderBarcode(item)
+	case item.Image != nil:
+		return r.RenderImage(item)
+	case len(item.Cells) > 0:
+		return r.RenderSegmented(item)
+	}
+	if item.FieldType == FieldTypeCurrency || item.FieldType == FieldTypeNumeric {
+		if item.Style == nil || item.Style.TextAlign == "" {
+			style := TextStyle{}
+			if item.Style != nil {
+				style = *item.Style
+			}
+			style.TextAlign = TextAlignRight
+			item.Style = &style
+		}
+	}
+	return r.RenderText(item)
+}
@@ -0,0 +1,112 @@
+package annotation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ValidationIssue is a single finding produced by one of the validators.
+type ValidationIssue struct {
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	FieldID  string   `json:"field_id,omitempty"`
+	GroupID  string   `json:"group_id,omitempty"`
+	Page     int      `json:"page,omitempty"`
+	// Path is the JSON path of the field, group or page the issue is
+	// about, such as pages[0].fields[12], as Validate fills it in.
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	// Form and OtherForm are set by library checks spanning forms.
+	Form      *FormRef `json:"form,omitempty"`
+	OtherForm *FormRef `json:"other_form,omitempty"`
+}
+
+// Error implements the error interface so issues can be returned directly.
+func (i ValidationIssue) Error() string {
+	switch {
+	case i.FieldID != "":
+		return fmt.Sprintf("%s: field %q: %s", i.Code, i.FieldID, i.Message)
+	case i.GroupID != "":
+		return fmt.Sprintf("%s: group %q: %s", i.Code, i.GroupID, i.Message)
+	case i.Page != 0:
+		return fmt.Sprintf("%s: page %d: %s", i.Code, i.Page, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Code, i.Message)
+}
+
+// Is matches an issue against a ValidationIssue target by the target's
+// non-empty code, field ID, group ID and page, so that
+// errors.Is(err, ValidationIssue{Code: InvalidPosition}) finds any
+// invalid position among joined issues.
+func (i ValidationIssue) Is(target error) bool {
+	t, ok := target.(ValidationIssue)
+	if !ok {
+		return false
+	}
+	return (t.Code == "" || t.Code == i.Code) && (t.FieldID == "" || t.FieldID == i.FieldID) &&
+		(t.GroupID == "" || t.GroupID == i.GroupID) && (t.Page == 0 || t.Page == i.Page)
+}
+
+// ValidationReport collects the issues found by a validator.
+type ValidationReport struct {
+	Issues []ValidationIssue `json:"issues"`
+	// Incomplete marks a report cut short by its context; the absence of
+	// errors then says nothing about the checks that did not run.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// HasErrors reports whether any issue in the report is an error.
+func (r *ValidationReport) HasErrors() bool {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the report's errors joined into one error, or nil when it
+// has none. Each joined error is a ValidationIssue, for errors.As and
+// errors.Is.
+func (r *ValidationReport) Err() error {
+	var errs []error
+	for _, issue := range r.Errors() {
+		errs = append(errs, issue)
+	}
+	return errors.Join(errs...)
+}
+
+// Errors returns the issues with error severity.
+func (r *ValidationReport) Errors() []ValidationIssue {
+	return r.bySeverity(SeverityError)
+}
+
+// Warnings returns the issues with warning severity.
+func (r *ValidationReport) Warnings() []ValidationIssue {
+	return r.bySeverity(SeverityWarning)
+}
+
+// ForField returns the issues attributed to a field.
+func (r *ValidationReport) ForField(fieldID string) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.FieldID == fieldID {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *ValidationReport) bySeverity(s Severity) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == s {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *ValidationReport) add(issue ValidationIssue) {
+	r.Issues = append(r.Issues, issue)
+}
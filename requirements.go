@@ -0,0 +1,164 @@
+package annotation
+
+import "strings"
+
+// RequirementLevel grades how strongly a field must be filled.
+type RequirementLevel string
+
+const (
+	// RequirementHard fields block filing when missing.
+	RequirementHard RequirementLevel = "hard"
+	// RequirementSoft fields may be filed without but typically draw a notice.
+	RequirementSoft RequirementLevel = "soft"
+	// RequirementRecommended fields only count toward full completeness.
+	RequirementRecommended RequirementLevel = "recommended"
+)
+
+// requirementLevels lists the tiers from strongest to weakest.
+var requirementLevels = []RequirementLevel{RequirementHard, RequirementSoft, RequirementRecommended}
+
+// requirementOf returns the tier at which a field is currently required, or
+// "" when it is not. Required alone means hard, as does an unrecognized
+// level. A Level without RequiredIf applies unconditionally; with RequiredIf
+// it applies when the condition holds.
+func requirementOf(required bool, level RequirementLevel, requiredIf string, limits ExprLimits, lookup func(string) string) (RequirementLevel, error) {
+	if level == "" && !required && requiredIf == "" {
+		return "", nil
+	}
+	if level != RequirementSoft && level != RequirementRecommended {
+		level = RequirementHard
+	}
+	if required || requiredIf == "" {
+		return level, nil
+	}
+	expr, err := ParseExprWithLimits(requiredIf, limits)
+	if err != nil {
+		return "", err
+	}
+	ok, err := expr.EvalBool(lookup)
+	if err != nil || !ok {
+		return "", err
+	}
+	return level, nil
+}
+
+// isEmptyValue reports whether value leaves a field unfilled; an unchecked
+// checkbox or a false boolean counts as empty.
+func isEmptyValue(fieldType FieldType, dataType DataType, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		return true
+	}
+	if fieldType == FieldTypeCheckbox || dataType == DataTypeBoolean {
+		if b, ok := parseBoolValue(value); ok && !b {
+			return true
+		}
+	}
+	return false
+}
+
+// CompletionState summarizes how far along a filled annotation is.
+type CompletionState string
+
+const (
+	CompletionEmpty       CompletionState = "empty"
+	CompletionInProgress  CompletionState = "in_progress"
+	CompletionReadyToFile CompletionState = "ready_to_file"
+	CompletionComplete    CompletionState = "complete"
+)
+
+// TierCompletion counts the fields currently required at one tier.
+type TierCompletion struct {
+	Required int      `json:"required"`
+	Filled   int      `json:"filled"`
+	Missing  []string `json:"missing,omitempty"`
+}
+
+// Percent returns the filled share of the tier, 100 when nothing is required.
+func (t TierCompletion) Percent() float64 {
+	if t.Required == 0 {
+		return 100
+	}
+	return 100 * float64(t.Filled) / float64(t.Required)
+}
+
+// Completion reports per-tier progress over the filled values. The state is
+// ready to file once every hard requirement is met, and complete once every
+// tier is.
+type Completion struct {
+	State       CompletionState `json:"state"`
+	Hard        TierCompletion  `json:"hard"`
+	Soft        TierCompletion  `json:"soft"`
+	Recommended TierCompletion  `json:"recommended"`
+}
+
+// Tier returns the progress for level.
+func (c *Completion) Tier(level RequirementLevel) TierCompletion {
+	switch level {
+	case RequirementHard:
+		return c.Hard
+	case RequirementSoft:
+		return c.Soft
+	case RequirementRecommended:
+		return c.Recommended
+	}
+	return TierCompletion{}
+}
+
+func (c *Completion) tier(level RequirementLevel) *TierCompletion {
+	switch level {
+	case RequirementSoft:
+		return &c.Soft
+	case RequirementRecommended:
+		return &c.Recommended
+	}
+	return &c.Hard
+}
+
+// Completion evaluates the annotation's requirements against its filled
+// values. Conditional requirements that fail to evaluate are treated as hard
+// so that a broken rule never makes a form look ready. Fields their
+// conditions hide or disable are not required.
+func (fa *FormAnnotation) Completion() *Completion {
+	c := &Completion{}
+	lookup := fa.valueLookup()
+	anyFilled := false
+	for _, field := range fa.Fields() {
+		empty := isEmptyValue(field.FieldType, field.DataType, field.Value)
+		anyFilled = anyFilled || !empty
+		level := fa.fieldState(field, lookup).Required
+		if level == "" {
+			continue
+		}
+		t := c.tier(level)
+		t.Required++
+		if empty {
+			t.Missing = append(t.Missing, field.FieldID)
+		} else {
+			t.Filled++
+		}
+	}
+	switch {
+	case !anyFilled:
+		c.State = CompletionEmpty
+	case len(c.Hard.Missing) > 0:
+		c.State = CompletionInProgress
+	case len(c.Soft.Missing) > 0 || len(c.Recommended.Missing) > 0:
+		c.State = CompletionReadyToFile
+	default:
+		c.State = CompletionComplete
+	}
+	return c
+}
+
+// MissingRequiredFields returns the IDs of unfilled required fields by tier.
+// Tiers with nothing missing are omitted.
+func (fa *FormAnnotation) MissingRequiredFields() map[RequirementLevel][]string {
+	c := fa.Completion()
+	missing := map[RequirementLevel][]string{}
+	for _, level := range requirementLevels {
+		if ids := c.Tier(level).Missing; len(ids) > 0 {
+			missing[level] = ids
+		}
+	}
+	return missing
+}
@@ -0,0 +1,184 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RepeatingRows makes a field group a section of identical rows, such as
+// the dependents table or the interest payers of Schedule B, drawn from one
+// template row of fields.
+type RepeatingRows struct {
+	// TemplateFieldIDs are the members forming the first row.
+	TemplateFieldIDs []string `json:"template_field_ids"`
+	// Spacing is the distance from one row to the next, in the page unit.
+	Spacing float64 `json:"row_spacing"`
+	// MaxRows is the most rows the form has room for; zero is unbounded.
+	MaxRows int `json:"max_rows,omitempty"`
+}
+
+// InvalidRepeat is the validation issue code for a repeat block
+// ExpandRows cannot use.
+const InvalidRepeat = "invalid_repeat"
+
+// ExpandRows materializes n rows of the repeating group: the template row
+// and n-1 copies, each Spacing further down the page than the last. Row k
+// copies template field "name" as "name_k", bound to the template's value
+// path with "_k" added to its parent segment, so that "payers.name"
+// becomes "payers_2.name" and each row fills from its own object. Copies
+// start empty, and references among the row's own fields in calculations
+// and conditions are redirected to the same row.
+//
+// The group's members outside the template are taken to be rows from an
+// earlier expansion and are replaced, so ExpandRows may be called again to
+// grow or shrink the section; n of 1 leaves only the template.
+func (fa *FormAnnotation) ExpandRows(groupID string, n int) error {
+	g := fa.GetGroup(groupID)
+	if g == nil {
+		return fmt.Errorf("expand rows: no group %q", groupID)
+	}
+	if err := fa.checkRepeat(g); err != "" {
+		return fmt.Errorf("expand rows: group %q: %s", groupID, err)
+	}
+	switch {
+	case n < 1:
+		return fmt.Errorf("expand rows: group %q: %d rows is less than one", groupID, n)
+	case g.Repeat.MaxRows > 0 && n > g.Repeat.MaxRows:
+		return fmt.Errorf("expand rows: group %q: %d rows is more than the %d the form holds", groupID, n, g.Repeat.MaxRows)
+	}
+	template := g.Repeat.TemplateFieldIDs
+	isTemplate := func(id string) bool {
+		return slices.ContainsFunc(template, func(t string) bool { return fa.sameID(t, id) })
+	}
+	stale := slices.DeleteFunc(slices.Clone(g.FieldIDs), isTemplate)
+	g.FieldIDs = slices.DeleteFunc(g.FieldIDs, func(id string) bool { return !isTemplate(id) })
+	fa.InvalidateIndex()
+	for i := range fa.Pages {
+		fa.Pages[i].Fields = slices.DeleteFunc(fa.Pages[i].Fields, func(f Field) bool {
+			return slices.ContainsFunc(stale, func(id string) bool { return fa.sameID(id, f.FieldID) })
+		})
+	}
+
+	pageUnit := fa.FormMetadata.PageSize.Unit
+	sign := 1.0
+	if fa.origin() == OriginBottomLeft {
+		sign = -1
+	}
+	var added []string
+	for row := 2; row <= n; row++ {
+		rowID := func(id string) string { return id + "_" + strconv.Itoa(row) }
+		for _, id := range template {
+			src, page := fa.fieldAndPage(id)
+			if src == nil {
+				return fmt.Errorf("expand rows: group %q: template field %q not found", groupID, id)
+			}
+			if other := fa.GetFieldByID(rowID(src.FieldID)); other != nil {
+				return fmt.Errorf("expand rows: group %q: row field %q already exists", groupID, rowID(src.FieldID))
+			}
+			f := src.Clone()
+			f.FieldID, f.GroupID = rowID(src.FieldID), groupID
+			f.FieldValue = rowValuePath(src.FieldValue, row)
+			f.Value, f.TabIndex, f.Provenance = "", 0, nil
+			offset := sign * g.Repeat.Spacing * float64(row-1)
+			f.Position.Y += offset * unitScale(f.Position.Unit, pageUnit)
+			for s := range f.Segments {
+				f.Segments[s].Position.Y += offset * unitScale(f.Segments[s].Position.Unit, pageUnit)
+			}
+			if rel := f.RelativeTo; rel != nil {
+				// A row placed from its own row's field moves with it;
+				// one placed from an anchor or another field moves by
+				// the row offset.
+				if i := slices.IndexFunc(template, func(tid string) bool { return fa.sameID(rel.FieldID, tid) }); i >= 0 {
+					rel.FieldID = rowID(template[i])
+				} else {
+					rel.OffsetY += offset * unitScale(f.Position.Unit, pageUnit)
+				}
+			}
+			for _, tid := range template {
+				matches := func(ref string) bool { return fa.sameID(ref, tid) }
+				if f.Calculation != nil {
+					renameExprRefs(&f.Calculation.Expr, matches, rowID(tid))
+				}
+				if v := f.Validation; v != nil {
+					renameExprRefs(&v.RequiredIf, matches, rowID(tid))
+				}
+				if c := f.Conditions; c != nil {
+					renameExprRefs(&c.VisibleIf, matches, rowID(tid))
+					renameExprRefs(&c.DisabledIf, matches, rowID(tid))
+				}
+			}
+			for i := range fa.Pages {
+				if fa.Pages[i].PageNumber == page {
+					fa.Pages[i].Fields = append(fa.Pages[i].Fields, f)
+					break
+				}
+			}
+			added = append(added, f.FieldID)
+		}
+	}
+	g.FieldIDs = append(g.FieldIDs, added...)
+	return nil
+}
+
+// rowValuePath returns the value path of row's copy of a field bound to
+// path, adding the row number to the parent segment, or to the only one.
+func rowValuePath(path string, row int) string {
+	if path == "" {
+		return ""
+	}
+	segs := strings.Split(path, ".")
+	at := max(len(segs)-2, 0)
+	segs[at] += "_" + strconv.Itoa(row)
+	return strings.Join(segs, ".")
+}
+
+// unitScale converts a length in the page unit to unit, which defaults to
+// the page unit.
+func unitScale(unit, pageUnit string) float64 {
+	if unit == "" {
+		return 1
+	}
+	if s, ok := (UnitOptions{}).scale(pageUnit, unit); ok && pageUnit != "" {
+		return s
+	}
+	return 1
+}
+
+// checkRepeat describes what makes the group's repeat block unusable, or
+// returns "".
+func (fa *FormAnnotation) checkRepeat(g *FieldGroup) string {
+	r := g.Repeat
+	switch {
+	case r == nil:
+		return "not a repeating group"
+	case len(r.TemplateFieldIDs) == 0:
+		return "no template fields"
+	case r.Spacing <= 0:
+		return fmt.Sprintf("row spacing %g is not positive", r.Spacing)
+	case r.MaxRows < 0:
+		return fmt.Sprintf("max rows %d is negative", r.MaxRows)
+	}
+	for _, id := range r.TemplateFieldIDs {
+		if !slices.ContainsFunc(g.FieldIDs, func(m string) bool { return fa.sameID(m, id) }) {
+			return fmt.Sprintf("template field %q is not a member", id)
+		}
+	}
+	return ""
+}
+
+// checkRepeats reports repeat blocks ExpandRows cannot use.
+func (fa *FormAnnotation) checkRepeats() []ValidationIssue {
+	var issues []ValidationIssue
+	for i := range fa.FieldGroups {
+		g := &fa.FieldGroups[i]
+		if g.Repeat == nil {
+			continue
+		}
+		if problem := fa.checkRepeat(g); problem != "" {
+			issues = append(issues, ValidationIssue{Code: InvalidRepeat, Severity: SeverityError, GroupID: g.GroupID, Message: problem})
+		}
+	}
+	return issues
+}
@@ -0,0 +1,99 @@
+//go:build s3
+
+// Package s3store is an example annotation.Store backed by an Amazon S3
+// bucket. It is built only with the s3 build tag so that the core package
+// keeps no dependency on the AWS SDK.
+package s3store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	annotation "github.com/amoghkashyap86/form-annotation"
+)
+
+// Store keeps annotations as objects under Prefix in Bucket.
+type Store struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
+
+var _ annotation.HashStore = (*Store)(nil)
+
+// hashKey is the user metadata entry holding the annotation.ContentHash of an object.
+const hashKey = "content-hash"
+
+func (s *Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + key),
+	})
+	if err != nil {
+		var missing *types.NoSuchKey
+		if errors.As(err, &missing) {
+			return nil, fmt.Errorf("get %s: %w", key, fs.ErrNotExist)
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// Put buffers the object to record its content hash in the object metadata.
+func (s *Store) Put(ctx context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(s.Prefix + key),
+		Body:     strings.NewReader(string(data)),
+		Metadata: map[string]string{hashKey: annotation.ContentHash(data)},
+	})
+	return err
+}
+
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	pages := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.Prefix + prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, strings.TrimPrefix(aws.ToString(obj.Key), s.Prefix))
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Hash reads the content hash from the object metadata with a HEAD request.
+func (s *Store) Hash(ctx context.Context, key string) (string, error) {
+	out, err := s.Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Prefix + key),
+	})
+	if err != nil {
+		return "", err
+	}
+	hash, ok := out.Metadata[hashKey]
+	if !ok {
+		return "", fmt.Errorf("%s has no content hash", key)
+	}
+	return hash, nil
+}
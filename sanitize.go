@@ -0,0 +1,194 @@
+package annotation
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SanitizeOptions controls SanitizeValue and SetFieldValueWithOptions.
+type SanitizeOptions struct {
+	// Truncate cuts a value longer than the field's MaxLength to fit
+	// instead of refusing it.
+	Truncate bool
+	Fill     FillOptions
+}
+
+// SetFieldValue sanitizes value as SanitizeValue does, refusing values
+// longer than the field's MaxLength, and places it in the field with ID id.
+func (fa *FormAnnotation) SetFieldValue(id, value string) error {
+	return fa.SetFieldValueWithOptions(id, value, SanitizeOptions{})
+}
+
+// SetFieldValueWithOptions is SetFieldValue with options. The value is
+// placed as SetValues places it, through field set hooks and placement
+// checks, and the first error the fill reports is returned.
+func (fa *FormAnnotation) SetFieldValueWithOptions(id, value string, opts SanitizeOptions) error {
+	field, page := fa.fieldAndPage(id)
+	if field == nil {
+		return fmt.Errorf("no field with ID %q", id)
+	}
+	clean, err := field.SanitizeValue(value, opts)
+	if err != nil {
+		return err
+	}
+	report := &FillReport{}
+	fa.place(field, page, clean, opts.Fill, report)
+	for _, issue := range report.Issues {
+		if issue.Severity == SeverityError {
+			return fmt.Errorf("field %q: %s", field.FieldID, issue.Message)
+		}
+	}
+	return nil
+}
+
+// SanitizeValue cleans up value for the field, in order: Unicode is
+// normalized as NormalizeText does; text fields get their TextTransform;
+// characters the field's Pattern cannot match anywhere are removed; and a
+// value longer than MaxLength is refused, or cut under opts.Truncate.
+// Checkbox values are returned as they are.
+func (f *Field) SanitizeValue(value string, opts SanitizeOptions) (string, error) {
+	if f.FieldType == FieldTypeCheckbox || f.DataType == DataTypeBoolean {
+		return value, nil
+	}
+	s := NormalizeText(value)
+	if fm := f.Formatting; fm != nil && f.textual() {
+		var err error
+		if s, err = transformText(s, fm.TextTransform); err != nil {
+			return "", fmt.Errorf("field %q: %w", f.FieldID, err)
+		}
+	}
+	v := f.Validation
+	if v == nil {
+		return s, nil
+	}
+	if v.Pattern != "" {
+		allowed, err := patternRunes(v.Pattern)
+		if err != nil {
+			return "", fmt.Errorf("field %q: pattern: %w", f.FieldID, err)
+		}
+		if allowed != nil {
+			s = strings.Map(func(r rune) rune {
+				if allowed(r) {
+					return r
+				}
+				return -1
+			}, s)
+		}
+	}
+	if v.MaxLength > 0 && utf8.RuneCountInString(s) > v.MaxLength {
+		if !opts.Truncate {
+			return "", fmt.Errorf("field %q: value is longer than %d characters", f.FieldID, v.MaxLength)
+		}
+		s = string([]rune(s)[:v.MaxLength])
+	}
+	return s, nil
+}
+
+// textual reports whether the field holds text rather than a number or
+// date.
+func (f *Field) textual() bool {
+	switch {
+	case f.DataType == DataTypeDecimal || f.DataType == DataTypeInteger || f.DataType == DataTypeDate:
+		return false
+	case f.FieldType == FieldTypeCurrency || f.FieldType == FieldTypeNumeric || f.FieldType == FieldTypeDate:
+		return false
+	}
+	return true
+}
+
+// patternRunes returns a test for the characters pattern can match at
+// some position, or nil when it can match any character.
+func patternRunes(pattern string) (func(rune) bool, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []rune
+	folded, anyChar := false, false
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+			anyChar = true
+		case syntax.OpLiteral:
+			for _, r := range re.Rune {
+				ranges = append(ranges, r, r)
+			}
+			folded = folded || re.Flags&syntax.FoldCase != 0
+		case syntax.OpCharClass:
+			ranges = append(ranges, re.Rune...)
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	if anyChar {
+		return nil, nil
+	}
+	in := func(r rune) bool {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= r && r <= ranges[i+1] {
+				return true
+			}
+		}
+		return false
+	}
+	return func(r rune) bool {
+		return in(r) || folded && (in(unicode.ToUpper(r)) || in(unicode.ToLower(r)))
+	}, nil
+}
+
+// typography maps the punctuation word processors and phones substitute
+// to the ASCII a form field expects.
+var typography = strings.NewReplacer(
+	"‘", "'", "’", "'", "‚", "'", "‛", "'", "′", "'",
+	"“", `"`, "”", `"`, "„", `"`, "‟", `"`, "″", `"`,
+	"‐", "-", "‑", "-", "‒", "-", "–", "-", "—", "-", "−", "-",
+	"…", "...", "\u00a0", " ", "\u2007", " ", "\u202f", " ",
+	"\u200b", "", "\ufeff", "",
+)
+
+// combining lists, for each combining accent, the letters it composes
+// with followed by the composed letter.
+var combining = map[rune]string{
+	'\u0300': "AÀEÈIÌOÒUÙaàeèiìoòuù",
+	'\u0301': "AÁEÉIÍOÓUÚYÝaáeéiíoóuúyýCĆcćNŃnńSŚsśZŹzź",
+	'\u0302': "AÂEÊIÎOÔUÛaâeêiîoôuû",
+	'\u0303': "AÃNÑOÕaãnñoõ",
+	'\u0308': "AÄEËIÏOÖUÜaäeëiïoöuüyÿ",
+	'\u030a': "AÅUŮaåuů",
+	'\u030c': "CČcčSŠsšZŽzžRŘrřEĚeěNŇnň",
+	'\u0327': "CÇcçSŞsş",
+}
+
+// NormalizeText composes Latin letters written with a separate combining
+// accent, as some keyboards and file systems produce them, into the single
+// characters of Unicode NFC, and replaces curly quotes, typographic dashes,
+// ellipses and non-breaking spaces with ASCII. Other text is unchanged;
+// this covers the letters of names and addresses, not all of NFC.
+func NormalizeText(s string) string {
+	s = typography.Replace(s)
+	if !strings.ContainsFunc(s, func(r rune) bool { return unicode.Is(unicode.Mn, r) }) {
+		return s
+	}
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if pairs, ok := combining[r]; ok && len(out) > 0 {
+			p := []rune(pairs)
+			for i := 0; i+1 < len(p); i += 2 {
+				if p[i] == out[len(out)-1] {
+					out[len(out)-1], r = p[i+1], -1
+					break
+				}
+			}
+		}
+		if r >= 0 {
+			out = append(out, r)
+		}
+	}
+	return string(out)
+}
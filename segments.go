@@ -0,0 +1,58 @@
+package annotation
+
+import "fmt"
+
+// AutoSegment lays out a comb of boxCount one-character segments from the
+// field's position, each boxWidth wide and gap apart, as on EIN and
+// routing number lines. The field becomes segmented and its width is set
+// to span the boxes. For segments of several characters, such as the
+// 3-2-4 groups of an SSN, use BuildSegments or SplitSegments.
+func (fa *FormAnnotation) AutoSegment(fieldID string, boxCount int, boxWidth, gap float64) error {
+	field := fa.GetFieldByID(fieldID)
+	if field == nil {
+		return fmt.Errorf("auto segment: no field with ID %q", fieldID)
+	}
+	switch {
+	case boxCount < 1:
+		return fmt.Errorf("auto segment %q: box count %d is less than one", fieldID, boxCount)
+	case boxWidth <= 0:
+		return fmt.Errorf("auto segment %q: box width %g is not positive", fieldID, boxWidth)
+	case gap < 0:
+		return fmt.Errorf("auto segment %q: gap %g is negative", fieldID, gap)
+	}
+	lengths := make([]int, boxCount)
+	for i := range lengths {
+		lengths[i] = 1
+	}
+	field.FieldType = FieldTypeSegmented
+	field.Segments = BuildSegments(field.Position, boxWidth, gap, lengths)
+	field.Position.Width = roundUnit(boxWidth*float64(boxCount) + gap*float64(boxCount-1))
+	return nil
+}
+
+// SplitSegments divides the field's existing position into segments of
+// the given lengths, giving every character the same share of the width
+// and leaving no gaps. SplitSegments("ssn", 3, 2, 4) turns a box drawn
+// around an SSN into its three groups. The field becomes segmented.
+func (fa *FormAnnotation) SplitSegments(fieldID string, lengths ...int) error {
+	field := fa.GetFieldByID(fieldID)
+	if field == nil {
+		return fmt.Errorf("split segments: no field with ID %q", fieldID)
+	}
+	if len(lengths) == 0 {
+		return fmt.Errorf("split segments %q: no segment lengths", fieldID)
+	}
+	cells := 0
+	for _, n := range lengths {
+		if n < 1 {
+			return fmt.Errorf("split segments %q: segment length %d is less than one", fieldID, n)
+		}
+		cells += n
+	}
+	if field.Position.Width <= 0 {
+		return fmt.Errorf("split segments %q: position has no width to split", fieldID)
+	}
+	field.FieldType = FieldTypeSegmented
+	field.Segments = BuildSegments(field.Position, field.Position.Width/float64(cells), 0, lengths)
+	return nil
+}
@@ -0,0 +1,225 @@
+package annotation
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Signer roles.
+const (
+	SignerTaxpayer = "taxpayer"
+	SignerSpouse   = "spouse"
+	SignerPreparer = "preparer"
+	SignerDesignee = "third_party_designee"
+	SignerOfficer  = "officer"
+)
+
+// SignatureSpec describes who signs a signature field and where the
+// signing date goes.
+type SignatureSpec struct {
+	// Role is one of the Signer roles.
+	Role string `json:"signer_role"`
+	// DateField is the ID of the date field dated when the field is signed.
+	DateField string `json:"date_field,omitempty"`
+}
+
+// Signature issue codes.
+const (
+	InvalidSignature = "invalid_signature"
+	// ValueUndated is a signed signature whose date field is empty, and
+	// ValueUnsigned a filled signature date whose signature is not.
+	ValueUndated  = "undated"
+	ValueUnsigned = "unsigned"
+)
+
+// checkSignatures reports signature settings on other field types, unknown
+// roles, and date fields that do not exist or do not hold dates.
+func (fa *FormAnnotation) checkSignatures() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			f := &page.Fields[i]
+			if f.Signature == nil {
+				continue
+			}
+			at := ValidationIssue{Code: InvalidSignature, Severity: SeverityError, FieldID: f.FieldID, Page: page.PageNumber}
+			switch {
+			case f.FieldType != FieldTypeSignature:
+				at.Message = fmt.Sprintf("signature settings on a %s field", f.FieldType)
+			case !knownSignerRole(f.Signature.Role):
+				at.Message = fmt.Sprintf("signer role %q is not taxpayer, spouse, preparer, third_party_designee or officer", f.Signature.Role)
+			case f.Signature.DateField != "":
+				date := fa.GetFieldByID(f.Signature.DateField)
+				switch {
+				case date == nil:
+					at.Message = fmt.Sprintf("signature date field %q does not exist", f.Signature.DateField)
+				case date.FieldType != FieldTypeDate && date.DataType != DataTypeDate:
+					at.Message = fmt.Sprintf("signature date field %q is a %s field, not a date", date.FieldID, date.FieldType)
+				}
+			}
+			if at.Message != "" {
+				issues = append(issues, at)
+			}
+		}
+	}
+	return issues
+}
+
+func knownSignerRole(role string) bool {
+	switch role {
+	case SignerTaxpayer, SignerSpouse, SignerPreparer, SignerDesignee, SignerOfficer:
+		return true
+	}
+	return false
+}
+
+// PendingSignature is a required signature not yet signed.
+type PendingSignature struct {
+	FieldID string `json:"field_id"`
+	Page    int    `json:"page"`
+	Role    string `json:"signer_role,omitempty"`
+}
+
+// PendingSignatures lists the signature fields required at the hard level
+// under the current values, including through requirement conditions, that
+// have no value, in page order.
+func (fa *FormAnnotation) PendingSignatures() ([]PendingSignature, error) {
+	var pending []PendingSignature
+	lookup := fa.valueLookup()
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			f := &page.Fields[i]
+			if f.FieldType != FieldTypeSignature || f.Validation == nil || !isEmptyValue(f.FieldType, f.DataType, f.Value) {
+				continue
+			}
+			level, err := requirementOf(f.Validation.Required, f.Validation.Level, f.Validation.RequiredIf, fa.exprLimits(), lookup)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", f.FieldID, err)
+			}
+			if level != RequirementHard {
+				continue
+			}
+			p := PendingSignature{FieldID: f.FieldID, Page: page.PageNumber}
+			if f.Signature != nil {
+				p.Role = f.Signature.Role
+			}
+			pending = append(pending, p)
+		}
+	}
+	return pending, nil
+}
+
+// ESignOptions controls ExportESignTabs.
+type ESignOptions struct {
+	// DocumentID identifies the form's PDF in the envelope; "1" when empty.
+	DocumentID string
+	// Anchors places tabs by anchor strings instead of coordinates: each
+	// tab's anchor is its field ID between backslashes, such as
+	// \taxpayer_signature\, which the caller prints, typically in white,
+	// in the field before sending the PDF.
+	Anchors bool
+}
+
+// ESignEnvelope is the recipients part of an e-signature envelope, in the
+// shape DocuSign-style APIs accept: one signer per role with sign-here and
+// date-signed tabs.
+type ESignEnvelope struct {
+	Signers []ESignSigner `json:"signers"`
+}
+
+// ESignSigner is one role's recipient. Recipients sign in the order of
+// their roles' first signature in the form.
+type ESignSigner struct {
+	RoleName     string    `json:"roleName"`
+	RecipientID  string    `json:"recipientId"`
+	RoutingOrder string    `json:"routingOrder"`
+	Tabs         ESignTabs `json:"tabs"`
+}
+
+// ESignTabs are the tabs a signer fills in.
+type ESignTabs struct {
+	SignHere   []ESignTab `json:"signHereTabs,omitempty"`
+	DateSigned []ESignTab `json:"dateSignedTabs,omitempty"`
+}
+
+// ESignTab places one tab, by page and position in whole points from the
+// page's top-left corner, or by anchor string. Numbers are strings, as the
+// APIs take them.
+type ESignTab struct {
+	TabLabel      string `json:"tabLabel"`
+	DocumentID    string `json:"documentId"`
+	PageNumber    string `json:"pageNumber,omitempty"`
+	XPosition     string `json:"xPosition,omitempty"`
+	YPosition     string `json:"yPosition,omitempty"`
+	Width         string `json:"width,omitempty"`
+	Height        string `json:"height,omitempty"`
+	AnchorString  string `json:"anchorString,omitempty"`
+	AnchorUnits   string `json:"anchorUnits,omitempty"`
+	AnchorXOffset string `json:"anchorXOffset,omitempty"`
+	AnchorYOffset string `json:"anchorYOffset,omitempty"`
+}
+
+// ExportESignTabs builds the envelope recipients for the form's signature
+// fields: a sign-here tab for each signature, grouped by signer role, and a
+// date-signed tab for its date field. Signatures without a role are
+// skipped. Positions are the media box positions rendering uses.
+func (fa *FormAnnotation) ExportESignTabs(opts ESignOptions) (*ESignEnvelope, error) {
+	if opts.DocumentID == "" {
+		opts.DocumentID = "1"
+	}
+	env := &ESignEnvelope{Signers: []ESignSigner{}}
+	signers := map[string]int{}
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			f := &page.Fields[i]
+			if f.FieldType != FieldTypeSignature || f.Signature == nil || f.Signature.Role == "" {
+				continue
+			}
+			n, ok := signers[f.Signature.Role]
+			if !ok {
+				n = len(env.Signers)
+				signers[f.Signature.Role] = n
+				id := strconv.Itoa(n + 1)
+				env.Signers = append(env.Signers, ESignSigner{RoleName: f.Signature.Role, RecipientID: id, RoutingOrder: id})
+			}
+			tab, err := fa.esignTab(f, page.PageNumber, opts)
+			if err != nil {
+				return nil, err
+			}
+			tabs := &env.Signers[n].Tabs
+			tabs.SignHere = append(tabs.SignHere, tab)
+			if f.Signature.DateField == "" {
+				continue
+			}
+			date, datePage := fa.fieldAndPage(f.Signature.DateField)
+			if date == nil {
+				return nil, fmt.Errorf("e-sign: field %q: signature date field %q does not exist", f.FieldID, f.Signature.DateField)
+			}
+			tab, err = fa.esignTab(date, datePage, opts)
+			if err != nil {
+				return nil, err
+			}
+			tabs.DateSigned = append(tabs.DateSigned, tab)
+		}
+	}
+	return env, nil
+}
+
+func (fa *FormAnnotation) esignTab(f *Field, pageNum int, opts ESignOptions) (ESignTab, error) {
+	tab := ESignTab{TabLabel: f.FieldID, DocumentID: opts.DocumentID}
+	if opts.Anchors {
+		tab.AnchorString = `\` + f.FieldID + `\`
+		tab.AnchorUnits, tab.AnchorXOffset, tab.AnchorYOffset = "pixels", "0", "0"
+		return tab, nil
+	}
+	p, ok := positionInPoints(fa.absoluteField(f, pageNum).Position, fa.FormMetadata.PageSize.Unit)
+	if !ok {
+		return ESignTab{}, fmt.Errorf("e-sign: field %q: unknown position unit %q", f.FieldID, f.Position.Unit)
+	}
+	whole := func(v float64) string { return strconv.Itoa(int(math.Round(v))) }
+	tab.PageNumber = strconv.Itoa(pageNum)
+	tab.XPosition, tab.YPosition = whole(p.X), whole(p.Y)
+	tab.Width, tab.Height = whole(p.Width), whole(p.Height)
+	return tab, nil
+}
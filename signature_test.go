@@ -0,0 +1,52 @@
+package annotation
+
+import "testing"
+
+func signedForm() *FormAnnotation {
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages: []Page{{PageNumber: 1, Fields: []Field{
+			{FieldID: "sig", FieldType: FieldTypeSignature, DataType: DataTypeString,
+				Position:  Position{X: 36, Y: 700, Width: 200, Height: 24, Unit: "pt"},
+				Signature: &SignatureSpec{Role: SignerTaxpayer, DateField: "sig_date"}},
+			{FieldID: "sig_date", FieldType: FieldTypeDate, DataType: DataTypeDate,
+				Position: Position{X: 300, Y: 700, Width: 100, Height: 24, Unit: "pt"}},
+		}}},
+	}
+}
+
+func signatureIssues(fa *FormAnnotation) []ValidationIssue {
+	var issues []ValidationIssue
+	for _, issue := range fa.Validate() {
+		if issue.Code == InvalidSignature {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func TestRenameFieldUpdatesSignatureDate(t *testing.T) {
+	fa := signedForm()
+	if err := fa.RenameField("sig_date", "date_signed"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fa.GetFieldByID("sig").Signature.DateField; got != "date_signed" {
+		t.Errorf("date field = %q, want date_signed", got)
+	}
+	if issues := signatureIssues(fa); len(issues) != 0 {
+		t.Errorf("issues after rename: %v", issues)
+	}
+}
+
+func TestRemoveFieldClearsSignatureDate(t *testing.T) {
+	fa := signedForm()
+	if err := fa.RemoveField("sig_date"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fa.GetFieldByID("sig").Signature.DateField; got != "" {
+		t.Errorf("date field = %q, want it cleared", got)
+	}
+	if issues := signatureIssues(fa); len(issues) != 0 {
+		t.Errorf("issues after removal: %v", issues)
+	}
+}
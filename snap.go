@@ -0,0 +1,199 @@
+package annotation
+
+import (
+	"math"
+	"sort"
+)
+
+// Snap target sources, in priority order.
+const (
+	SnapFieldEdge = "field_edge"
+	SnapColumn    = "column"
+	SnapGrid      = "grid"
+)
+
+// Axes of snap targets and guide lines. A target on the x axis is a
+// vertical line at X = Coordinate.
+const (
+	AxisX = "x"
+	AxisY = "y"
+)
+
+// snapGridPoints is the editor grid pitch: an eighth of an inch.
+const snapGridPoints = 9.0
+
+// alignTolerance is how close, in points, edges must be to share a guide line.
+const alignTolerance = 0.5
+
+// minGuideSupport is how many fields must share an edge line for it to be a guide.
+const minGuideSupport = 2
+
+// SnapTarget is a coordinate a dragged point may snap to.
+type SnapTarget struct {
+	Axis       string  `json:"axis"`
+	Coordinate float64 `json:"coordinate"`
+	Source     string  `json:"source"`
+	FieldID    string  `json:"field_id,omitempty"`
+	Distance   float64 `json:"distance"`
+}
+
+// GuideLine is an edge line shared by several fields, such as the left edge
+// of an amount column.
+type GuideLine struct {
+	Axis       string   `json:"axis"`
+	Coordinate float64  `json:"coordinate"`
+	FieldIDs   []string `json:"field_ids"`
+}
+
+// PageIndex is a read-only spatial index of one page's field edges, in the
+// page's unit. Build it once when a drag starts and query it per frame.
+type PageIndex struct {
+	factor float64 // points per page unit
+	xs, ys []snapEdge
+	guides []GuideLine
+}
+
+type snapEdge struct {
+	coord   float64
+	fieldID string
+}
+
+// PageIndex indexes the rendered fields on pageNum, or returns nil when the
+// page does not exist or its unit is unknown. Fields with unknown units are
+// left out.
+func (fa *FormAnnotation) PageIndex(pageNum int) *PageIndex {
+	unit := fa.FormMetadata.PageSize.Unit
+	factor, ok := toPoints(1, unit)
+	if !ok {
+		return nil
+	}
+	for _, page := range fa.Pages {
+		if page.PageNumber != pageNum {
+			continue
+		}
+		idx := &PageIndex{factor: factor}
+		for _, f := range renderedFields(page.Fields) {
+			p, ok := positionInPoints(f.Position, unit)
+			if !ok {
+				continue
+			}
+			x0, y0 := p.X/factor, p.Y/factor
+			x1, y1 := (p.X+p.Width)/factor, (p.Y+p.Height)/factor
+			idx.xs = append(idx.xs, snapEdge{x0, f.FieldID}, snapEdge{x1, f.FieldID})
+			idx.ys = append(idx.ys, snapEdge{y0, f.FieldID}, snapEdge{y1, f.FieldID})
+		}
+		for _, edges := range [][]snapEdge{idx.xs, idx.ys} {
+			sort.Slice(edges, func(i, j int) bool { return edges[i].coord < edges[j].coord })
+		}
+		idx.guides = append(alignmentLines(AxisX, idx.xs, alignTolerance/factor),
+			alignmentLines(AxisY, idx.ys, alignTolerance/factor)...)
+		return idx
+	}
+	return nil
+}
+
+// alignmentLines clusters sorted edges that lie within tolerance of the
+// cluster's first edge and keeps clusters shared by enough distinct fields.
+func alignmentLines(axis string, edges []snapEdge, tolerance float64) []GuideLine {
+	var lines []GuideLine
+	for start := 0; start < len(edges); {
+		end := start + 1
+		for end < len(edges) && edges[end].coord-edges[start].coord <= tolerance {
+			end++
+		}
+		seen := map[string]bool{}
+		var ids []string
+		sum := 0.0
+		for _, e := range edges[start:end] {
+			sum += e.coord
+			if !seen[e.fieldID] {
+				seen[e.fieldID] = true
+				ids = append(ids, e.fieldID)
+			}
+		}
+		if len(ids) >= minGuideSupport {
+			sort.Strings(ids)
+			lines = append(lines, GuideLine{Axis: axis, Coordinate: sum / float64(end-start), FieldIDs: ids})
+		}
+		start = end
+	}
+	return lines
+}
+
+// GuideLines returns the page's alignment lines, vertical lines first, each
+// axis ordered by how many fields share the line and then by coordinate.
+func (idx *PageIndex) GuideLines() []GuideLine {
+	out := append([]GuideLine{}, idx.guides...)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Axis != out[j].Axis {
+			return out[i].Axis == AxisX
+		}
+		if len(out[i].FieldIDs) != len(out[j].FieldIDs) {
+			return len(out[i].FieldIDs) > len(out[j].FieldIDs)
+		}
+		return out[i].Coordinate < out[j].Coordinate
+	})
+	return out
+}
+
+// SnapTargets returns the field edges, alignment lines and grid lines within
+// radius of (x, y) on either axis, ordered by source priority and then by
+// distance. Edge lookups are binary searches over the sorted edges.
+func (idx *PageIndex) SnapTargets(x, y, radius float64) []SnapTarget {
+	var targets []SnapTarget
+	near := func(axis string, edges []snapEdge, v float64) {
+		i := sort.Search(len(edges), func(i int) bool { return edges[i].coord >= v-radius })
+		for ; i < len(edges) && edges[i].coord <= v+radius; i++ {
+			targets = append(targets, SnapTarget{Axis: axis, Coordinate: edges[i].coord, Source: SnapFieldEdge,
+				FieldID: edges[i].fieldID, Distance: math.Abs(edges[i].coord - v)})
+		}
+	}
+	near(AxisX, idx.xs, x)
+	near(AxisY, idx.ys, y)
+	for _, g := range idx.guides {
+		v := x
+		if g.Axis == AxisY {
+			v = y
+		}
+		if d := math.Abs(g.Coordinate - v); d <= radius {
+			targets = append(targets, SnapTarget{Axis: g.Axis, Coordinate: g.Coordinate, Source: SnapColumn, Distance: d})
+		}
+	}
+	pitch := snapGridPoints / idx.factor
+	for _, axis := range []string{AxisX, AxisY} {
+		v := x
+		if axis == AxisY {
+			v = y
+		}
+		for g := math.Ceil((v-radius)/pitch) * pitch; g <= v+radius; g += pitch {
+			targets = append(targets, SnapTarget{Axis: axis, Coordinate: g, Source: SnapGrid, Distance: math.Abs(g - v)})
+		}
+	}
+	priority := map[string]int{SnapFieldEdge: 0, SnapColumn: 1, SnapGrid: 2}
+	sort.SliceStable(targets, func(i, j int) bool {
+		if a, b := priority[targets[i].Source], priority[targets[j].Source]; a != b {
+			return a < b
+		}
+		return targets[i].Distance < targets[j].Distance
+	})
+	return targets
+}
+
+// SnapTargets is PageIndex(pageNum).SnapTargets for a single query.
+// Coordinates and radius are in the page's unit.
+func (fa *FormAnnotation) SnapTargets(pageNum int, x, y, radius float64) []SnapTarget {
+	idx := fa.PageIndex(pageNum)
+	if idx == nil {
+		return nil
+	}
+	return idx.SnapTargets(x, y, radius)
+}
+
+// GuideLines returns the alignment lines of pageNum, in the page's unit.
+func (fa *FormAnnotation) GuideLines(pageNum int) []GuideLine {
+	idx := fa.PageIndex(pageNum)
+	if idx == nil {
+		return nil
+	}
+	return idx.GuideLines()
+}
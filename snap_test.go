@@ -0,0 +1,76 @@
+package annotation
+
+import (
+	"fmt"
+	"testing"
+)
+
+// denseForm has one page with a grid of cols × rows small fields, the
+// shape of a large schedule.
+func denseForm(cols, rows int) *FormAnnotation {
+	page := Page{PageNumber: 1}
+	for r := range rows {
+		for c := range cols {
+			page.Fields = append(page.Fields, Field{FieldID: fmt.Sprintf("r%d_c%d", r, c), FieldType: FieldTypeText,
+				DataType: DataTypeString, Position: Position{X: 10 + float64(c)*30, Y: 10 + float64(r)*15, Width: 25, Height: 12, Unit: "pt"}})
+		}
+	}
+	return &FormAnnotation{
+		FormMetadata: FormMetadata{FormID: "test", PageCount: 1, PageSize: PageSize{Width: 612, Height: 792, Unit: "pt"}},
+		Pages:        []Page{page},
+	}
+}
+
+func TestSnapTargetsDensePage(t *testing.T) {
+	fa := denseForm(20, 50)
+	targets := fa.SnapTargets(1, 41, 26, 2)
+	var edges int
+	for _, target := range targets {
+		if target.Distance > 2 {
+			t.Errorf("%v is outside the radius", target)
+		}
+		if target.Source == SnapFieldEdge {
+			edges++
+		}
+	}
+	if edges == 0 {
+		t.Fatalf("no field edges among %v", targets)
+	}
+	if targets[0].Source != SnapFieldEdge {
+		t.Errorf("first target %v is not a field edge", targets[0])
+	}
+	if len(fa.GuideLines(1)) == 0 {
+		t.Error("no guide lines on a grid of fields")
+	}
+}
+
+// BenchmarkSnapTargets measures one query against a prebuilt index of a
+// 1,000-field page, as an editor makes on every frame of a drag.
+func BenchmarkSnapTargets(b *testing.B) {
+	idx := denseForm(20, 50).PageIndex(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.SnapTargets(float64(i%600), float64(i%780), 8)
+	}
+}
+
+// BenchmarkFormSnapTargets measures the single-query form, which builds
+// the index each time.
+func BenchmarkFormSnapTargets(b *testing.B) {
+	fa := denseForm(20, 50)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fa.SnapTargets(1, float64(i%600), float64(i%780), 8)
+	}
+}
+
+func BenchmarkGuideLines(b *testing.B) {
+	idx := denseForm(20, 50).PageIndex(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idx.GuideLines()
+	}
+}
@@ -0,0 +1,220 @@
+package annotation
+
+import (
+	"math"
+	"slices"
+)
+
+// spatialCell is the side of a spatial index grid cell, in points.
+const spatialCell = 36.0
+
+// SpatialIndex answers point and rectangle queries against the fields'
+// boxes, page by page, from a grid of half-inch cells. Queries take
+// coordinates in the document's page unit and coordinate origin, as field
+// positions are stored. Like FieldIndex, it points to the annotation's own
+// fields and needs rebuilding after fields move or are added or removed.
+type SpatialIndex struct {
+	factor float64
+	pages  map[int]*spatialPage
+}
+
+type spatialPage struct {
+	boxes []spatialBox
+	cells map[[2]int][]int
+	// lo and hi bound the cells in use.
+	lo, hi [2]int
+}
+
+// spatialBox is one rectangle of a field, in points; a segmented field
+// has one per segment.
+type spatialBox struct {
+	field *Field
+	order int
+	rect  Position
+}
+
+// BuildSpatialIndex indexes the boxes of every non-virtual field with a
+// convertible, non-empty position. Segmented fields are indexed by their
+// segments.
+func (fa *FormAnnotation) BuildSpatialIndex() *SpatialIndex {
+	unit := fa.FormMetadata.PageSize.Unit
+	factor, ok := toPoints(1, unit)
+	if !ok {
+		factor = 1
+	}
+	ix := &SpatialIndex{factor: factor, pages: map[int]*spatialPage{}}
+	for i := range fa.Pages {
+		sp := &spatialPage{cells: map[[2]int][]int{}}
+		for j := range fa.Pages[i].Fields {
+			field := &fa.Pages[i].Fields[j]
+			if field.IsVirtual() {
+				continue
+			}
+			positions := []Position{field.Position}
+			if field.FieldType == FieldTypeSegmented && len(field.Segments) > 0 {
+				positions = segmentPositions(field)
+			}
+			for _, p := range positions {
+				r, ok := positionInPoints(p, unit)
+				if !ok || r.Width <= 0 || r.Height <= 0 {
+					continue
+				}
+				sp.add(spatialBox{field: field, order: j, rect: r})
+			}
+		}
+		ix.pages[fa.Pages[i].PageNumber] = sp
+	}
+	return ix
+}
+
+func (sp *spatialPage) add(b spatialBox) {
+	n := len(sp.boxes)
+	sp.boxes = append(sp.boxes, b)
+	lo, hi := cellOf(b.rect.X, b.rect.Y), cellOf(b.rect.X+b.rect.Width, b.rect.Y+b.rect.Height)
+	if n == 0 {
+		sp.lo, sp.hi = lo, hi
+	}
+	sp.lo = [2]int{min(sp.lo[0], lo[0]), min(sp.lo[1], lo[1])}
+	sp.hi = [2]int{max(sp.hi[0], hi[0]), max(sp.hi[1], hi[1])}
+	for cx := lo[0]; cx <= hi[0]; cx++ {
+		for cy := lo[1]; cy <= hi[1]; cy++ {
+			sp.cells[[2]int{cx, cy}] = append(sp.cells[[2]int{cx, cy}], n)
+		}
+	}
+}
+
+func cellOf(x, y float64) [2]int {
+	return [2]int{int(math.Floor(x / spatialCell)), int(math.Floor(y / spatialCell))}
+}
+
+// FieldAt returns the field whose box contains the point, or nil. Where
+// boxes overlap the smallest wins, as the more specific target, and then
+// the field later on the page.
+func (ix *SpatialIndex) FieldAt(page int, x, y float64) *Field {
+	sp := ix.pages[page]
+	if sp == nil {
+		return nil
+	}
+	x, y = x*ix.factor, y*ix.factor
+	var best *spatialBox
+	for _, n := range sp.cells[cellOf(x, y)] {
+		b := &sp.boxes[n]
+		r := b.rect
+		if x < r.X || x > r.X+r.Width || y < r.Y || y > r.Y+r.Height {
+			continue
+		}
+		if best == nil || r.Width*r.Height < best.rect.Width*best.rect.Height ||
+			r.Width*r.Height == best.rect.Width*best.rect.Height && b.order > best.order {
+			best = b
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.field
+}
+
+// FieldsInRect returns the fields whose boxes intersect rect, in page
+// order. The rectangle is in the page unit, whatever its own Unit says,
+// and one of zero size selects what it touches.
+func (ix *SpatialIndex) FieldsInRect(page int, rect Position) []*Field {
+	sp := ix.pages[page]
+	if sp == nil {
+		return nil
+	}
+	r := Position{X: rect.X * ix.factor, Y: rect.Y * ix.factor, Width: rect.Width * ix.factor, Height: rect.Height * ix.factor}
+	if r.Width < 0 {
+		r.X, r.Width = r.X+r.Width, -r.Width
+	}
+	if r.Height < 0 {
+		r.Y, r.Height = r.Y+r.Height, -r.Height
+	}
+	lo, hi := cellOf(r.X, r.Y), cellOf(r.X+r.Width, r.Y+r.Height)
+	lo = [2]int{max(lo[0], sp.lo[0]), max(lo[1], sp.lo[1])}
+	hi = [2]int{min(hi[0], sp.hi[0]), min(hi[1], sp.hi[1])}
+	seen := map[int]*Field{}
+	for cx := lo[0]; cx <= hi[0]; cx++ {
+		for cy := lo[1]; cy <= hi[1]; cy++ {
+			for _, n := range sp.cells[[2]int{cx, cy}] {
+				b := sp.boxes[n]
+				if seen[b.order] != nil {
+					continue
+				}
+				if b.rect.X <= r.X+r.Width && r.X <= b.rect.X+b.rect.Width &&
+					b.rect.Y <= r.Y+r.Height && r.Y <= b.rect.Y+b.rect.Height {
+					seen[b.order] = b.field
+				}
+			}
+		}
+	}
+	orders := make([]int, 0, len(seen))
+	for order := range seen {
+		orders = append(orders, order)
+	}
+	slices.Sort(orders)
+	fields := make([]*Field, len(orders))
+	for i, order := range orders {
+		fields[i] = seen[order]
+	}
+	return fields
+}
+
+// NearestField returns the field whose box is closest to the point and the
+// distance to its edge in the page unit, zero when the point is inside. It
+// returns nil when the page has no indexed fields.
+func (ix *SpatialIndex) NearestField(page int, x, y float64) (*Field, float64) {
+	sp := ix.pages[page]
+	if sp == nil || len(sp.boxes) == 0 {
+		return nil, 0
+	}
+	x, y = x*ix.factor, y*ix.factor
+	c := cellOf(x, y)
+	var best *spatialBox
+	bestDist := math.Inf(1)
+	// Cells in ring r are at least (r-1) cells from the point, so once the
+	// best box is closer than that no further ring can beat it.
+	rings := max(absInt(c[0]-sp.lo[0]), absInt(c[0]-sp.hi[0]), absInt(c[1]-sp.lo[1]), absInt(c[1]-sp.hi[1]))
+	for r := 0; r <= rings && bestDist > float64(r-1)*spatialCell; r++ {
+		for cx := max(c[0]-r, sp.lo[0]); cx <= min(c[0]+r, sp.hi[0]); cx++ {
+			for cy := max(c[1]-r, sp.lo[1]); cy <= min(c[1]+r, sp.hi[1]); cy++ {
+				if absInt(cx-c[0]) != r && absInt(cy-c[1]) != r {
+					continue
+				}
+				for _, n := range sp.cells[[2]int{cx, cy}] {
+					b := &sp.boxes[n]
+					d := distanceToRect(x, y, b.rect)
+					if d < bestDist || d == bestDist && b.order < best.order {
+						best, bestDist = b, d
+					}
+				}
+			}
+		}
+	}
+	return best.field, bestDist / ix.factor
+}
+
+func distanceToRect(x, y float64, r Position) float64 {
+	dx := max(r.X-x, 0, x-(r.X+r.Width))
+	dy := max(r.Y-y, 0, y-(r.Y+r.Height))
+	return math.Hypot(dx, dy)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// FieldAt returns the field whose box contains the point on page, in the
+// page unit. It builds a SpatialIndex for the one query; callers asking
+// repeatedly, such as on every mouse move, should build one and keep it.
+func (fa *FormAnnotation) FieldAt(page int, x, y float64) *Field {
+	return fa.BuildSpatialIndex().FieldAt(page, x, y)
+}
+
+// FieldsInRect returns the fields on page whose boxes intersect rect, in
+// page order, building a SpatialIndex for the one query.
+func (fa *FormAnnotation) FieldsInRect(page int, rect Position) []*Field {
+	return fa.BuildSpatialIndex().FieldsInRect(page, rect)
+}
@@ -0,0 +1,202 @@
+package annotation
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// StampPlan lists everything a stamper needs to draw the filled values.
+type StampPlan struct {
+	FormID string      `json:"form_id"`
+	Items  []StampItem `json:"items"`
+}
+
+// StampItem is a single value placed on a page. Segmented fields carry one
+// cell per segment instead of a single text run.
+type StampItem struct {
+	FieldID   string      `json:"field_id"`
+	Page      int         `json:"page"`
+	FieldType FieldType   `json:"field_type"`
+	Position  Position    `json:"position"`
+	Text      string      `json:"text,omitempty"`
+	Style     *TextStyle  `json:"style,omitempty"`
+	Check     *CheckStyle `json:"check_style,omitempty"`
+	Cells     []StampCell `json:"cells,omitempty"`
+	// Barcode and Image hold what a barcode or image field draws.
+	Barcode *StampBarcode `json:"barcode,omitempty"`
+	Image   *StampImage   `json:"image,omitempty"`
+}
+
+// StampBarcode is an encoded barcode, to be drawn over the item's position.
+type StampBarcode struct {
+	Symbology string `json:"symbology"`
+	Data      string `json:"data"`
+	// Modules has a row per line of the symbol, written as 1 for a dark
+	// module and 0 for a light one; a linear barcode has a single row,
+	// drawn over the full height.
+	Modules []string `json:"modules"`
+}
+
+// StampImage is a PNG or JPEG image, to be placed in the item's position
+// by its fit mode.
+type StampImage struct {
+	Fit       string `json:"fit"`
+	MediaType string `json:"media_type"`
+	Data      []byte `json:"data"`
+}
+
+// StampCell is the portion of a segmented value drawn into one segment.
+type StampCell struct {
+	Position Position `json:"position"`
+	Text     string   `json:"text"`
+}
+
+// BuildStampPlan produces the stamp plan for the currently filled values.
+// Fields that cannot be drawn are left out of the plan and reported, or kept
+// with a warning, according to opts.GeometryPolicy. Fields are placed as they
+// appear on opts.Target, in media-box coordinates. Barcode fields are
+// encoded, from their template when they have one, and image values
+// decoded; one that cannot be is reported as an unsupported value. Values
+// are masked first under opts.Redact.
+func (fa *FormAnnotation) BuildStampPlan(opts FillOptions) (*StampPlan, *FillReport) {
+	if opts.Redact != nil {
+		fa = fa.Redact(*opts.Redact)
+	}
+	plan := &StampPlan{FormID: fa.FormMetadata.FormID}
+	report := &FillReport{}
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			field := &page.Fields[i]
+			templated := field.Barcode != nil && field.Barcode.Template != ""
+			if (field.Value == "" && !templated) || field.IsVirtual() {
+				continue
+			}
+			eff, visible := fa.EffectiveField(field, opts.Target)
+			if !visible {
+				continue
+			}
+			field = &eff
+			if field.FieldType == FieldTypeCheckbox && !isChecked(field.Value) {
+				continue
+			}
+			text := field.displayText()
+			if field.FieldType == FieldTypeBarcode {
+				data, err := fa.barcodeData(field)
+				if err == nil && data == "" {
+					continue
+				}
+				text = data
+			}
+			if problem := fa.checkPlacement(field, page.PageNumber, text); problem != "" {
+				issue, proceed := placementIssue(field, page.PageNumber, problem, opts)
+				report.add(issue)
+				if !proceed {
+					continue
+				}
+			}
+			item := stampItem(fa.absoluteField(field, page.PageNumber), page.PageNumber)
+			if err := fa.stampMedia(field, &item); err != nil {
+				report.add(FillIssue{
+					FieldID:  field.FieldID,
+					Page:     page.PageNumber,
+					Code:     FillUnsupportedValue,
+					Severity: SeverityError,
+					Message:  err.Error(),
+				})
+				continue
+			}
+			plan.Items = append(plan.Items, item)
+			report.Filled = append(report.Filled, field.FieldID)
+		}
+	}
+	return plan, report
+}
+
+func stampItem(field *Field, page int) StampItem {
+	item := StampItem{
+		FieldID:   field.FieldID,
+		Page:      page,
+		FieldType: field.FieldType,
+		Position:  field.Position,
+		Style:     field.Style,
+	}
+	switch field.FieldType {
+	case FieldTypeCheckbox:
+		item.Check = field.CheckStyle
+		item.Text = "X"
+	case FieldTypeSegmented:
+		chunks := field.segmentText(field.displayText())
+		for i, seg := range field.Segments {
+			item.Cells = append(item.Cells, StampCell{Position: seg.Position, Text: chunks[i]})
+		}
+	default:
+		item.Text = field.displayText()
+	}
+	return item
+}
+
+// stampMedia encodes a barcode field's data or decodes an image field's
+// value into item, replacing its text.
+func (fa *FormAnnotation) stampMedia(field *Field, item *StampItem) error {
+	switch field.FieldType {
+	case FieldTypeBarcode:
+		if field.Barcode == nil {
+			return fmt.Errorf("barcode field %q has no symbology", field.FieldID)
+		}
+		data, err := fa.barcodeData(field)
+		if err != nil {
+			return err
+		}
+		rows, err := EncodeBarcode(field.Barcode.Symbology, data)
+		if err != nil {
+			return err
+		}
+		b := &StampBarcode{Symbology: field.Barcode.Symbology, Data: data}
+		for _, row := range rows {
+			line := make([]byte, len(row))
+			for i, dark := range row {
+				line[i] = '0'
+				if dark {
+					line[i] = '1'
+				}
+			}
+			b.Modules = append(b.Modules, string(line))
+		}
+		item.Text, item.Style, item.Barcode = "", nil, b
+	case FieldTypeImage:
+		mediaType, data, err := ParseImageData(field.Value)
+		if err != nil {
+			return err
+		}
+		fit := ImageFitContain
+		if field.Image != nil && field.Image.Fit != "" {
+			fit = field.Image.Fit
+		}
+		item.Text, item.Style, item.Image = "", nil, &StampImage{Fit: fit, MediaType: mediaType, Data: data}
+	}
+	return nil
+}
+
+// splitSegments distributes value across segments by their lengths. When the
+// raw value does not fit exactly, separator characters such as the dashes in
+// an SSN are dropped first.
+func splitSegments(value string, segments []Segment) []string {
+	total := segmentCells(segments)
+	runes := []rune(value)
+	if len(runes) != total {
+		var kept []rune
+		for _, r := range runes {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				kept = append(kept, r)
+			}
+		}
+		runes = kept
+	}
+	chunks := make([]string, len(segments))
+	for i, seg := range segments {
+		n := min(seg.Length, len(runes))
+		chunks[i] = string(runes[:n])
+		runes = runes[n:]
+	}
+	return chunks
+}
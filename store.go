@@ -0,0 +1,252 @@
+package annotation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Store is a key-value backend holding annotation documents, such as a
+// directory, an embedded file system or an object storage bucket. Keys are
+// slash-separated. Get reports a missing key with an error wrapping
+// fs.ErrNotExist.
+type Store interface {
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Put(ctx context.Context, key string, r io.Reader) error
+	// List returns the keys starting with prefix in sorted order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// HashStore is a Store that can report the content hash of a stored object
+// without reading it. SaveToStore uses it to skip writing unchanged content.
+type HashStore interface {
+	Store
+	// Hash returns the ContentHash of the object at key.
+	Hash(ctx context.Context, key string) (string, error)
+}
+
+// ContentHash returns the SHA-256 digest of data in the form used by StructuralHash.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// LoadFromStore reads the annotation stored at key, decoding it as
+// LoadFile does.
+func LoadFromStore(ctx context.Context, store Store, key string, opts LoadOptions) (*FormAnnotation, error) {
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	annotation, err := parseAnnotationFile(key, data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return annotation, nil
+}
+
+// SaveToStore writes the annotation to key in the same format as SaveToFile,
+// and like it refuses experimental capabilities. When the store is a
+// HashStore and already holds identical content, nothing is written.
+func SaveToStore(ctx context.Context, store Store, key string, fa *FormAnnotation) error {
+	fa, err := fa.forSave()
+	if err != nil {
+		return err
+	}
+	if err := FeatureGates(nil).checkEmit(fa); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(fa.withReaderRequirements(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if hs, ok := store.(HashStore); ok {
+		if hash, err := hs.Hash(ctx, key); err == nil && hash == ContentHash(data) {
+			return nil
+		}
+	}
+	return store.Put(ctx, key, bytes.NewReader(data))
+}
+
+// StoreEntry is one annotation found by ListForms.
+type StoreEntry struct {
+	Key      string
+	Metadata FormMetadata
+}
+
+// ListForms returns the metadata of every .json annotation under prefix,
+// skipping .template.json page templates. Only the form_metadata object of
+// each document is decoded; the pages are not parsed.
+func ListForms(ctx context.Context, store Store, prefix string) ([]StoreEntry, error) {
+	keys, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var entries []StoreEntry
+	for _, key := range keys {
+		if path.Ext(key) != ".json" || strings.HasSuffix(key, templateSuffix) {
+			continue
+		}
+		md, err := readMetadata(ctx, store, key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		entries = append(entries, StoreEntry{Key: key, Metadata: md})
+	}
+	return entries, nil
+}
+
+// readMetadata streams the top-level keys of the document at key and stops
+// as soon as form_metadata has been decoded.
+func readMetadata(ctx context.Context, store Store, key string) (FormMetadata, error) {
+	var md FormMetadata
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		return md, err
+	}
+	defer rc.Close()
+	br := bufio.NewReader(rc)
+	if head, _ := br.Peek(len(utf8BOM)); bytes.Equal(head, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	dec := json.NewDecoder(br)
+	if tok, err := dec.Token(); err != nil {
+		return md, err
+	} else if tok != json.Delim('{') {
+		return md, fmt.Errorf("document is not a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return md, err
+		}
+		if tok == "form_metadata" {
+			return md, dec.Decode(&md)
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return md, err
+		}
+	}
+	return md, fmt.Errorf("document has no form_metadata")
+}
+
+// DirStore is a Store backed by a local directory.
+type DirStore struct {
+	Dir string
+}
+
+func (s DirStore) path(key string) (string, error) {
+	if !fs.ValidPath(key) {
+		return "", fmt.Errorf("invalid store key %q", key)
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
+
+func (s DirStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(name)
+}
+
+// Put writes through a temporary file so readers never see a partial object.
+func (s DirStore) Put(ctx context.Context, key string, r io.Reader) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(name, r)
+}
+
+// writeFileAtomic replaces name with the contents of r by renaming a
+// temporary file over it.
+func writeFileAtomic(name string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".put-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s DirStore) List(ctx context.Context, prefix string) ([]string, error) {
+	return FSStore{FS: os.DirFS(s.Dir)}.List(ctx, prefix)
+}
+
+func (s DirStore) Hash(ctx context.Context, key string) (string, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return ContentHash(data), nil
+}
+
+// FSStore is a read-only Store backed by an fs.FS, such as an embed.FS.
+type FSStore struct {
+	FS fs.FS
+}
+
+func (s FSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.FS.Open(key)
+}
+
+func (s FSStore) Put(ctx context.Context, key string, r io.Reader) error {
+	return fmt.Errorf("put %s: %w", key, errors.ErrUnsupported)
+}
+
+func (s FSStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := fs.WalkDir(s.FS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasPrefix(name, prefix) {
+			keys = append(keys, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
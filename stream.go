@@ -0,0 +1,422 @@
+package annotation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSONError locates malformed JSON, or a value of the wrong type, in an
+// annotation file. Path is the JSON path of the value, such as
+// pages[0].fields[12].value, and Page and FieldID the page number and
+// field ID of the page and field it is in, when they could be read. Line,
+// Column and Offset are zero for an error in a migrated document, whose
+// offsets do not match the input.
+type JSONError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Offset  int64  `json:"offset"`
+	Path    string `json:"path,omitempty"`
+	Page    int    `json:"page,omitempty"`
+	FieldID string `json:"field_id,omitempty"`
+	Err     error  `json:"-"`
+}
+
+func (e *JSONError) Error() string {
+	var at []string
+	if e.Line > 0 {
+		at = append(at, fmt.Sprintf("line %d, column %d (offset %d)", e.Line, e.Column, e.Offset))
+	}
+	if e.Path != "" {
+		at = append(at, "at "+e.Path)
+	}
+	switch {
+	case e.FieldID != "":
+		at = append(at, fmt.Sprintf("in field %q on page %d", e.FieldID, e.Page))
+	case e.Page != 0:
+		at = append(at, fmt.Sprintf("on page %d", e.Page))
+	}
+	if len(at) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", strings.Join(at, " "), e.Err)
+}
+
+func (e *JSONError) Unwrap() error { return e.Err }
+
+// jsonErrorOffset returns where a decoding error occurred, relative to
+// the start of the decoded value.
+func jsonErrorOffset(err error) (int64, bool) {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		return syntax.Offset, true
+	case errors.As(err, &typ):
+		return typ.Offset, true
+	}
+	return 0, false
+}
+
+// locateJSONError adds the line, column and JSON path to a decoding error
+// in data. Errors already located are returned as they are.
+func locateJSONError(err error, data []byte) error {
+	if _, ok := err.(*JSONError); ok {
+		return err
+	}
+	offset, ok := jsonErrorOffset(err)
+	if !ok {
+		return err
+	}
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	loc := locateJSONPath(data, offset)
+	return &JSONError{Line: line, Column: column, Offset: offset, Path: loc.path, Page: loc.page, FieldID: loc.fieldID, Err: err}
+}
+
+// Load reads an annotation from r as LoadFile reads a file. The input is
+// read into memory whole; StreamPages, StreamFields and LoadPage read very
+// large documents a page at a time.
+func Load(r io.Reader, opts LoadOptions) (*FormAnnotation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseAnnotation(data, opts)
+}
+
+// Save writes the annotation to w as SaveToFile writes a file.
+func (fa *FormAnnotation) Save(w io.Writer) error {
+	data, err := fa.encode()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// SaveCanonical writes the annotation to w like Save, normalized with opts
+// as NormalizeWithOptions describes, without changing the annotation
+// itself. For the same content and options the output is byte for byte the
+// same, so that it diffs cleanly under version control.
+func (fa *FormAnnotation) SaveCanonical(w io.Writer, opts NormalizeOptions) error {
+	data, err := fa.canonicalJSON(opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// StreamPages decodes an annotation from r one page at a time, calling fn
+// with each page in document order and never holding more than one page.
+// It returns the rest of the document, metadata and field groups, with no
+// pages. An error from fn stops the stream and is returned.
+//
+// The reader version is checked when the metadata is read, before any page
+// when the metadata comes first as this package writes it. Gates are
+// applied to each page and, at the end, to the metadata and groups.
+// Pages of an older schema version are migrated one at a time, so
+// migrations needing the whole document do not apply. Malformed JSON is
+// reported as a *JSONError. Input must be UTF-8;
+// SourceEncoding is not supported, since transcoding is decided on the
+// whole file, and neither is Strict.
+func StreamPages(r io.Reader, opts LoadOptions, fn func(Page) error) (*FormAnnotation, error) {
+	if opts.SourceEncoding != EncodingUTF8 {
+		return nil, fmt.Errorf("stream pages: source encoding %q is not supported", opts.SourceEncoding)
+	}
+	if opts.Strict {
+		return nil, fmt.Errorf("stream pages: strict loading is not supported")
+	}
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(bom, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	in := &trackedReader{r: br, lastNewline: -1}
+	dec := json.NewDecoder(in)
+	fa := &FormAnnotation{}
+	fail := func(err error, base int64) error {
+		var notUTF8 *NotUTF8Error
+		if errors.As(err, &notUTF8) {
+			return err
+		}
+		offset, ok := jsonErrorOffset(err)
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			err, offset = io.ErrUnexpectedEOF, in.offset
+		case !ok:
+			return fmt.Errorf("stream pages: %w", err)
+		default:
+			var syntax *json.SyntaxError
+			if !errors.As(err, &syntax) {
+				offset += base
+			}
+		}
+		line, column := in.locate(offset)
+		return &JSONError{Line: line, Column: column, Offset: offset, Err: err}
+	}
+	expect := func(want json.Delim) error {
+		tok, err := dec.Token()
+		if err != nil {
+			return fail(err, 0)
+		}
+		if tok != want {
+			offset := dec.InputOffset()
+			line, column := in.locate(offset)
+			return &JSONError{Line: line, Column: column, Offset: offset, Err: fmt.Errorf("expected %q, found %v", want, tok)}
+		}
+		return nil
+	}
+	decode := func(v any) error {
+		base := dec.InputOffset()
+		if err := dec.Decode(v); err != nil {
+			return fail(err, base)
+		}
+		return nil
+	}
+	if err := expect('{'); err != nil {
+		return nil, err
+	}
+	version := SchemaV1
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fail(err, 0)
+		}
+		switch tok {
+		case "form_metadata":
+			if err := decode(&fa.FormMetadata); err != nil {
+				return nil, err
+			}
+			version = max(fa.FormMetadata.SchemaVersion, SchemaV1)
+			if version > CurrentSchemaVersion && !opts.IgnoreReaderVersion {
+				return nil, &UnsupportedDocumentError{SchemaVersion: version}
+			}
+			if !opts.IgnoreReaderVersion {
+				if err := fa.FormMetadata.checkReader(); err != nil {
+					return nil, err
+				}
+			}
+		case "pages":
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, fail(err, 0)
+			}
+			if tok == nil {
+				continue
+			}
+			if tok != json.Delim('[') {
+				offset := dec.InputOffset()
+				line, column := in.locate(offset)
+				return nil, &JSONError{Line: line, Column: column, Offset: offset, Err: fmt.Errorf("pages is not an array")}
+			}
+			for dec.More() {
+				var page Page
+				if version < CurrentSchemaVersion {
+					base := dec.InputOffset()
+					var raw json.RawMessage
+					if err := decode(&raw); err != nil {
+						return nil, err
+					}
+					migrated, changed, err := migratePage(raw, version)
+					if err != nil {
+						return nil, fmt.Errorf("stream pages: %w", err)
+					}
+					if err := json.Unmarshal(migrated, &page); err != nil {
+						if changed {
+							return nil, fmt.Errorf("stream pages: schema version %d page, migrated: %v", version, err)
+						}
+						return nil, fail(err, base)
+					}
+				} else if err := decode(&page); err != nil {
+					return nil, err
+				}
+				doc := &FormAnnotation{Pages: []Page{page}}
+				if err := applyGates(doc, opts); err != nil {
+					return nil, err
+				}
+				if err := fn(doc.Pages[0]); err != nil {
+					return nil, err
+				}
+				in.forget(dec.InputOffset())
+			}
+			if err := expect(']'); err != nil {
+				return nil, err
+			}
+		case "field_groups":
+			if err := decode(&fa.FieldGroups); err != nil {
+				return nil, err
+			}
+		default:
+			var skip json.RawMessage
+			if err := decode(&skip); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := expect('}'); err != nil {
+		return nil, err
+	}
+	if version < CurrentSchemaVersion {
+		fa.FormMetadata.SchemaVersion = CurrentSchemaVersion
+	}
+	if err := applyGates(fa, opts); err != nil {
+		return nil, err
+	}
+	return fa, nil
+}
+
+// LoadPage reads one page of an annotation from r, streaming the document
+// as StreamPages does so that only that page is held in memory. The result
+// has the document's metadata, that page, and the field groups with a
+// member on it, their members trimmed to the page's fields. The metadata
+// still describes the whole form, page_count included. A missing page is
+// an error.
+func LoadPage(r io.Reader, pageNum int, opts LoadOptions) (*FormAnnotation, error) {
+	var found *Page
+	fa, err := StreamPages(r, opts, func(p Page) error {
+		if p.PageNumber == pageNum && found == nil {
+			found = &p
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("load page: no page %d", pageNum)
+	}
+	fa.Pages = []Page{*found}
+	groups := fa.FieldGroups[:0]
+	for _, g := range fa.FieldGroups {
+		g.FieldIDs = slices.DeleteFunc(g.FieldIDs, func(id string) bool { return pageField(found, id, fa.sameID) == nil })
+		if len(g.FieldIDs) > 0 {
+			groups = append(groups, g)
+		}
+	}
+	fa.FieldGroups = groups
+	return runLoadHooks(fa)
+}
+
+// StreamFields returns an iterator over the fields of the annotation in r,
+// with their page numbers, in document order, reading the document as
+// StreamPages does so that only the current page is held in memory. The
+// iterator reads r and can be ranged over once; stopping early stops the
+// read. The returned function reports the error that ended the iteration,
+// if any, once it is done.
+func StreamFields(r io.Reader, opts LoadOptions) (iter.Seq2[int, Field], func() error) {
+	var err error
+	used := false
+	stop := errors.New("stop")
+	seq := func(yield func(int, Field) bool) {
+		if used {
+			err = errors.New("stream fields: the stream has already been read")
+			return
+		}
+		used = true
+		_, e := StreamPages(r, opts, func(p Page) error {
+			for _, f := range p.Fields {
+				if !yield(p.PageNumber, f) {
+					return stop
+				}
+			}
+			return nil
+		})
+		if e != nil && !errors.Is(e, stop) {
+			err = e
+		}
+	}
+	return seq, func() error { return err }
+}
+
+func applyGates(fa *FormAnnotation, opts LoadOptions) error {
+	report, err := opts.Gates.apply(fa)
+	if err != nil {
+		return err
+	}
+	if len(report.Changes) > 0 && opts.OnStripped != nil {
+		opts.OnStripped(report)
+	}
+	return nil
+}
+
+// trackedReader checks that its input is UTF-8 and remembers where lines
+// start, so decoding errors can be located. Newlines before the point the
+// decoder has consumed are forgotten, keeping only a count.
+type trackedReader struct {
+	r           io.Reader
+	offset      int64
+	newlines    []int64
+	forgotten   int
+	lastNewline int64
+	carry       []byte
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	chunk := p[:n]
+	for i, c := range chunk {
+		if c == '\n' {
+			t.newlines = append(t.newlines, t.offset+int64(i))
+		}
+	}
+	if verr := t.checkUTF8(chunk, err == io.EOF); verr != nil {
+		return 0, verr
+	}
+	t.offset += int64(n)
+	return n, err
+}
+
+// checkUTF8 validates chunk, carrying a rune split across reads over to
+// the next one.
+func (t *trackedReader) checkUTF8(chunk []byte, last bool) error {
+	start := t.offset - int64(len(t.carry))
+	data := append(t.carry, chunk...)
+	i := 0
+	for i < len(data) {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) && !last {
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return &NotUTF8Error{Offset: int(start) + i, Byte: data[i]}
+		}
+		i += size
+	}
+	t.carry = append([]byte(nil), data[i:]...)
+	return nil
+}
+
+// forget drops the newlines before offset.
+func (t *trackedReader) forget(offset int64) {
+	n := sort.Search(len(t.newlines), func(i int) bool { return t.newlines[i] >= offset })
+	if n > 0 {
+		t.lastNewline = t.newlines[n-1]
+		t.forgotten += n
+		t.newlines = append(t.newlines[:0], t.newlines[n:]...)
+	}
+}
+
+// locate returns the line and column of offset.
+func (t *trackedReader) locate(offset int64) (line, column int) {
+	n := sort.Search(len(t.newlines), func(i int) bool { return t.newlines[i] >= offset })
+	last := t.lastNewline
+	if n > 0 {
+		last = t.newlines[n-1]
+	}
+	return t.forgotten + n + 1, int(offset - last)
+}
@@ -0,0 +1,223 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// StrictProblem is one violation strict loading found, at a JSON path
+// such as pages[0].fields[12].field_type.
+type StrictProblem struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (p StrictProblem) String() string { return p.Path + ": " + p.Message }
+
+// StrictError lists every violation LoadOptions.Strict found, with the
+// keys of each object visited in sorted order.
+type StrictError struct {
+	Problems []StrictProblem
+}
+
+func (e *StrictError) Error() string {
+	lines := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		lines[i] = p.String()
+	}
+	return fmt.Sprintf("strict load: %d problems:\n%s", len(e.Problems), strings.Join(lines, "\n"))
+}
+
+// checkStrict walks a document against the schema types, reporting keys no
+// type declares, values of the wrong JSON type, and enumerated strings
+// outside their known values. It returns a *StrictError, or nil.
+func checkStrict(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+	var problems []StrictProblem
+	strictWalk("", doc, reflect.TypeOf(FormAnnotation{}), func(path, format string, args ...any) {
+		problems = append(problems, StrictProblem{Path: path, Message: fmt.Sprintf(format, args...)})
+	})
+	if len(problems) > 0 {
+		return &StrictError{Problems: problems}
+	}
+	return nil
+}
+
+func strictWalk(path string, v any, t reflect.Type, add func(path, format string, args ...any)) {
+	if v == nil {
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	at := path
+	if at == "" {
+		at = "(document)"
+	}
+	if selfDecoding(t) {
+		data, _ := json.Marshal(v)
+		if err := json.Unmarshal(data, reflect.New(t).Interface()); err != nil {
+			add(at, "invalid value: %v", err)
+		}
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			add(at, "expected an object, found %s", jsonKind(v))
+			return
+		}
+		fields := jsonFields(t)
+		for _, key := range sortedKeys(obj) {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			f, ok := fields[key]
+			if !ok {
+				if near := nearestKey(key, sortedKeys(fields)); near != "" {
+					add(child, "unknown key (did you mean %q?)", near)
+				} else {
+					add(child, "unknown key")
+				}
+				continue
+			}
+			strictWalk(child, obj[key], f.Type, add)
+			known, isEnum := formatEnums[t.Name()+"."+f.Name]
+			if s, ok := obj[key].(string); ok && isEnum && s != "" && !knownValue(known, s, f.Name == "Unit") {
+				add(child, "unknown value %q", s)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		list, ok := v.([]any)
+		if !ok {
+			add(at, "expected an array, found %s", jsonKind(v))
+			return
+		}
+		for i, item := range list {
+			strictWalk(fmt.Sprintf("%s[%d]", path, i), item, t.Elem(), add)
+		}
+	case reflect.Map:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			add(at, "expected an object, found %s", jsonKind(v))
+			return
+		}
+		for _, key := range sortedKeys(obj) {
+			strictWalk(path+"."+key, obj[key], t.Elem(), add)
+		}
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			add(at, "expected a string, found %s", jsonKind(v))
+		}
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			add(at, "expected a boolean, found %s", jsonKind(v))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.(json.Number)
+		if !ok {
+			add(at, "expected an integer, found %s", jsonKind(v))
+		} else if _, err := strconv.ParseInt(string(n), 10, 64); err != nil {
+			add(at, "expected an integer, found %s", n)
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := v.(json.Number); !ok {
+			add(at, "expected a number, found %s", jsonKind(v))
+		}
+	}
+}
+
+// jsonFields returns t's fields by JSON key, including those of embedded
+// structs.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func knownValue(known []string, s string, foldCase bool) bool {
+	if foldCase {
+		s = strings.ToLower(s)
+	}
+	return slices.Contains(known, s)
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "a number"
+	}
+	return "null"
+}
+
+// nearestKey returns the key within two edits of key, or "" when there is
+// none. Ties go to the first in keys.
+func nearestKey(key string, keys []string) string {
+	best, bestDist := "", 3
+	for _, k := range keys {
+		if d := editDistance(key, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Damerau-Levenshtein distance with adjacent
+// transpositions, so that "feild" is one edit from "field".
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,5 @@
+//go:build annotation_strict
+
+package annotation
+
+const strictAPIDefault = true
@@ -0,0 +1,225 @@
+package annotation
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// PositionedText is a run of text from a PDF's text layer, such as a text
+// extractor reports it: the page, the text, and its bounding box in PDF
+// user space, in points from the bottom-left corner.
+type PositionedText struct {
+	Page   int     `json:"page"`
+	Text   string  `json:"text"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Position suggestion signals besides SignalLabel.
+const (
+	SignalLineNumber      = "line_number"
+	SignalLabelLineNumber = "label_and_line_number"
+)
+
+// PositionSuggestOptions controls SuggestPositions.
+type PositionSuggestOptions struct {
+	// MinConfidence drops weaker matches; 0.5 when zero.
+	MinConfidence float64
+	// FieldWidth is the width, in points, of a suggested text box; 144 when
+	// zero. Boxes are clipped to the page.
+	FieldWidth float64
+	// Gap is the space, in points, left between the matched text and the
+	// suggested box; 4 when zero.
+	Gap float64
+}
+
+// PositionSuggestion proposes a position for an unpositioned field, in the
+// document's page unit and coordinate origin.
+type PositionSuggestion struct {
+	FieldID    string   `json:"field_id"`
+	Page       int      `json:"page"`
+	Position   Position `json:"position"`
+	Confidence float64  `json:"confidence"`
+	Signal     string   `json:"signal"`
+	// Text is the PDF text the field was matched to.
+	Text string `json:"text"`
+}
+
+// SuggestPositions matches the labels and IRS line references of fa's
+// unpositioned fields, the description of the reference standing in for a
+// missing label, against the text of the form's PDF and proposes a
+// position next to each match: a box of FieldWidth after the text for most
+// fields, and a square before it for checkboxes. A label that equals the
+// text scores 1, one contained in it less, and one sharing some of its
+// words less again; a line number leading the text, as "1a" leads "1a Total
+// amount from Form(s) W-2", scores 0.6 alone and raises a label match.
+// Each text is given to at most one field, the best-scoring first, and
+// suggestions are returned in page order. fa is not changed. Documents
+// with coordinate frames are refused.
+func SuggestPositions(fa *FormAnnotation, pdfText []PositionedText, opts PositionSuggestOptions) ([]PositionSuggestion, error) {
+	ps := fa.FormMetadata.PageSize
+	factor, ok := toPoints(1, ps.Unit)
+	if !ok {
+		return nil, fmt.Errorf("suggest positions: unknown page unit %q", ps.Unit)
+	}
+	if fa.HasCoordinateFrames() {
+		return nil, fmt.Errorf("suggest positions: document declares coordinate frames; normalize it to the media box first")
+	}
+	if opts.MinConfidence == 0 {
+		opts.MinConfidence = 0.5
+	}
+	if opts.FieldWidth == 0 {
+		opts.FieldWidth = 144
+	}
+	if opts.Gap == 0 {
+		opts.Gap = 4
+	}
+	type match struct {
+		field, text, page int
+		score             float64
+		signal            string
+	}
+	var fields []*Field
+	var matches []match
+	for _, page := range fa.Pages {
+		for i := range page.Fields {
+			f := &page.Fields[i]
+			if f.IsVirtual() || f.Position.Width > 0 && f.Position.Height > 0 || len(f.Segments) > 0 {
+				continue
+			}
+			label := suggestWords(f.Label)
+			lineToken := ""
+			if ref, err := ParseIRSLineRef(f.IRSLineRef); err == nil {
+				if ref.Line > 0 {
+					lineToken = strconv.Itoa(ref.Line) + ref.Sub
+				}
+				if len(label) == 0 {
+					label = suggestWords(ref.Description)
+				}
+			}
+			for t, text := range pdfText {
+				if text.Page != page.PageNumber {
+					continue
+				}
+				words := suggestWords(text.Text)
+				score, signal := labelScore(label, words), SignalLabel
+				if lineToken != "" && len(words) > 0 && words[0] == lineToken {
+					if score > 0 {
+						score, signal = min(1, math.Round((score+0.15)*100)/100), SignalLabelLineNumber
+					} else {
+						score, signal = 0.6, SignalLineNumber
+					}
+				}
+				if score >= opts.MinConfidence {
+					matches = append(matches, match{field: len(fields), text: t, page: page.PageNumber, score: score, signal: signal})
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return cmp.Compare(b.score, a.score) })
+	var chosen []match
+	fieldDone, textDone := map[int]bool{}, map[int]bool{}
+	for _, m := range matches {
+		if fieldDone[m.field] || textDone[m.text] {
+			continue
+		}
+		fieldDone[m.field], textDone[m.text] = true, true
+		chosen = append(chosen, m)
+	}
+	slices.SortFunc(chosen, func(a, b match) int { return cmp.Compare(a.field, b.field) })
+
+	out := make([]PositionSuggestion, 0, len(chosen))
+	for _, m := range chosen {
+		f, text := fields[m.field], pdfText[m.text]
+		size := fa.PageSizeOf(m.page)
+		pageWidth, pageHeight := size.Width*factor, size.Height*factor
+		box := Position{X: text.X + text.Width + opts.Gap, Y: text.Y, Width: opts.FieldWidth, Height: text.Height}
+		if f.FieldType == FieldTypeCheckbox {
+			box = Position{X: text.X - opts.Gap - text.Height, Y: text.Y, Width: text.Height, Height: text.Height}
+		}
+		if box.X+box.Width > pageWidth {
+			box.Width = max(pageWidth-box.X, 0)
+		}
+		if fa.origin() == OriginTopLeft {
+			box.Y = pageHeight - box.Y - box.Height
+		}
+		out = append(out, PositionSuggestion{
+			FieldID: f.FieldID,
+			Page:    m.page,
+			Position: Position{
+				X: roundUnit(box.X / factor), Y: roundUnit(box.Y / factor),
+				Width: roundUnit(box.Width / factor), Height: roundUnit(box.Height / factor),
+				Unit: ps.Unit,
+			},
+			Confidence: m.score,
+			Signal:     m.signal,
+			Text:       text.Text,
+		})
+	}
+	return out, nil
+}
+
+// ApplyPositionSuggestions writes every suggestion at or above
+// minConfidence to a field that is still unpositioned and returns the ones
+// applied.
+func (fa *FormAnnotation) ApplyPositionSuggestions(suggestions []PositionSuggestion, minConfidence float64) []PositionSuggestion {
+	var applied []PositionSuggestion
+	for _, s := range suggestions {
+		if s.Confidence < minConfidence {
+			continue
+		}
+		field := fa.GetFieldByID(s.FieldID)
+		if field == nil || field.Position.Width > 0 && field.Position.Height > 0 || len(field.Segments) > 0 {
+			continue
+		}
+		field.Position = s.Position
+		applied = append(applied, s)
+	}
+	return applied
+}
+
+// suggestWords lower-cases s and splits it into words of letters and
+// digits.
+func suggestWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// labelScore rates how well a field label matches a text, both as words:
+// 1 when they are equal, 0.8 when the text contains the label or the label
+// the text, and otherwise by the share of words they have in common.
+func labelScore(label, text []string) float64 {
+	if len(label) == 0 || len(text) == 0 {
+		return 0
+	}
+	if slices.Equal(label, text) {
+		return 1
+	}
+	joinedLabel, joinedText := " "+strings.Join(label, " ")+" ", " "+strings.Join(text, " ")+" "
+	if strings.Contains(joinedText, joinedLabel) || len(text) > 1 && strings.Contains(joinedLabel, joinedText) {
+		return 0.8
+	}
+	common := 0
+	inText := map[string]bool{}
+	for _, w := range text {
+		inText[w] = true
+	}
+	union := len(inText)
+	for _, w := range slices.Compact(slices.Sorted(slices.Values(label))) {
+		if inText[w] {
+			common++
+		} else {
+			union++
+		}
+	}
+	return math.Round(70*float64(common)/float64(union)) / 100
+}
@@ -0,0 +1,174 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SVGOptions controls RenderPageSVG.
+type SVGOptions struct {
+	// Background is the href of an image, such as a scan of the form or a
+	// data: URI, stretched under the fields to cover the page.
+	Background string
+	// LabelSize is the font size of the field ID labels in points; the
+	// default is 6. A negative size leaves the labels out.
+	LabelSize float64
+	// StrokeWidth is the width of the field outlines in points; the
+	// default is 0.75.
+	StrokeWidth float64
+	// Types, when set, draws only fields of these types.
+	Types []FieldType
+	// Groups, when set, draws only members of these groups.
+	Groups []string
+	// GroupOutlines outlines the bounding box of each field group drawn.
+	GroupOutlines bool
+	// Target selects the render overrides applied to the drawn fields.
+	Target RenderTarget
+}
+
+// RenderPageSVG draws the field layout of a page as a standalone SVG
+// document for checking positions against the form. The viewBox is the
+// page size in the document's unit, so coordinates in the SVG read the
+// same as in the annotation. Each field is a rectangle color-coded by type,
+// as in RenderPNG, with a box for each segment and its ID as a label.
+// Virtual fields are not drawn.
+func (fa *FormAnnotation) RenderPageSVG(pageNum int, opts SVGOptions) ([]byte, error) {
+	var page *Page
+	for i := range fa.Pages {
+		if fa.Pages[i].PageNumber == pageNum {
+			page = &fa.Pages[i]
+		}
+	}
+	if page == nil {
+		return nil, fmt.Errorf("no page %d", pageNum)
+	}
+	ps := fa.PageSizeOf(pageNum)
+	unit := strings.ToLower(ps.Unit)
+	if unit == "" {
+		unit = "pt"
+	}
+	perUnit, ok := toPoints(1, unit)
+	if !ok {
+		return nil, fmt.Errorf("unknown page unit %q", ps.Unit)
+	}
+	// Output units are page units: 72/perUnit of them per inch.
+	geom, err := newPageGeometry(ps, 72/perUnit)
+	if err != nil {
+		return nil, err
+	}
+	labelSize := opts.LabelSize
+	if labelSize == 0 {
+		labelSize = 6
+	}
+	strokeWidth := opts.StrokeWidth
+	if strokeWidth <= 0 {
+		strokeWidth = 0.75
+	}
+	labelSize, strokeWidth = labelSize/perUnit, strokeWidth/perUnit
+
+	var b bytes.Buffer
+	num := func(v float64) string { return strconv.FormatFloat(roundUnit(v), 'f', -1, 64) }
+	attr := func(s string) string {
+		var sb strings.Builder
+		xml.EscapeText(&sb, []byte(s))
+		return sb.String()
+	}
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" width="%s%s" height="%s%s" viewBox="0 0 %s %s">`+"\n",
+		num(geom.width), unit, num(geom.height), unit, num(geom.width), num(geom.height))
+	fmt.Fprintf(&b, "  <title>%s page %d</title>\n", attr(fa.FormMetadata.FormID), pageNum)
+	if opts.Background != "" {
+		fmt.Fprintf(&b, `  <image x="0" y="0" width="%s" height="%s" preserveAspectRatio="none" xlink:href="%s"/>`+"\n",
+			num(geom.width), num(geom.height), attr(opts.Background))
+	}
+	fmt.Fprintf(&b, `  <rect class="page" x="0" y="0" width="%s" height="%s" fill="none" stroke="#000000" stroke-width="%s"/>`+"\n",
+		num(geom.width), num(geom.height), num(strokeWidth))
+
+	var drawn []*Field
+	for _, field := range fa.targetFields(page.Fields, opts.Target) {
+		if len(opts.Types) > 0 && !slices.Contains(opts.Types, field.FieldType) {
+			continue
+		}
+		if len(opts.Groups) > 0 && !fa.inAnyGroup(field, opts.Groups) {
+			continue
+		}
+		field = fa.absoluteField(field, pageNum)
+		drawn = append(drawn, field)
+		c, ok := fieldColors[field.FieldType]
+		if !ok {
+			c = defaultFieldColor
+		}
+		fmt.Fprintf(&b, `  <g class="field" data-field-id="%s" data-field-type="%s">`+"\n", attr(field.FieldID), attr(string(field.FieldType)))
+		label, hasLabel := geom.box(field.Position)
+		hasLabel = hasLabel && label.W > 0 && label.H > 0
+		if hasLabel {
+			fmt.Fprintf(&b, `    <rect x="%s" y="%s" width="%s" height="%s" fill="%s" fill-opacity="0.19" stroke="%s" stroke-width="%s"/>`+"\n",
+				num(label.X), num(label.Y), num(label.W), num(label.H), svgColor(c), svgColor(c), num(strokeWidth))
+		}
+		for i, seg := range field.Segments {
+			r, ok := geom.box(seg.Position)
+			if !ok {
+				continue
+			}
+			fmt.Fprintf(&b, `    <rect class="segment" x="%s" y="%s" width="%s" height="%s" fill="none" stroke="%s" stroke-width="%s"/>`+"\n",
+				num(r.X), num(r.Y), num(r.W), num(r.H), svgColor(segmentColor), num(strokeWidth))
+			if i == 0 && !hasLabel {
+				label, hasLabel = r, true
+			}
+		}
+		if hasLabel && labelSize > 0 {
+			fmt.Fprintf(&b, `    <text x="%s" y="%s" font-family="sans-serif" font-size="%s" fill="%s">%s</text>`+"\n",
+				num(label.X), num(label.Y-labelSize/4), num(labelSize), svgColor(labelColor), attr(field.FieldID))
+		}
+		b.WriteString("  </g>\n")
+	}
+	if opts.GroupOutlines {
+		for _, group := range fa.FieldGroups {
+			var bounds renderBox
+			found := false
+			for _, field := range drawn {
+				if !fa.inAnyGroup(field, []string{group.GroupID}) {
+					continue
+				}
+				for _, r := range geom.fieldBoxes(field) {
+					if !found {
+						bounds, found = r, true
+					} else {
+						bounds = bounds.union(r)
+					}
+				}
+			}
+			if found {
+				pad := 3 / perUnit
+				fmt.Fprintf(&b, `  <rect class="group" data-group-id="%s" x="%s" y="%s" width="%s" height="%s" fill="none" stroke="%s" stroke-width="%s" stroke-dasharray="%s"/>`+"\n",
+					attr(group.GroupID), num(bounds.X-pad), num(bounds.Y-pad), num(bounds.W+2*pad), num(bounds.H+2*pad),
+					svgColor(groupColor), num(strokeWidth), num(4*strokeWidth))
+			}
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.Bytes(), nil
+}
+
+// inAnyGroup reports whether field belongs to one of groupIDs, by its own
+// group_id or a group's member list.
+func (fa *FormAnnotation) inAnyGroup(field *Field, groupIDs []string) bool {
+	if slices.Contains(groupIDs, field.GroupID) {
+		return true
+	}
+	for _, id := range groupIDs {
+		if g := fa.GetGroup(id); g != nil && slices.ContainsFunc(g.FieldIDs, func(m string) bool { return fa.sameID(m, field.FieldID) }) {
+			return true
+		}
+	}
+	return false
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
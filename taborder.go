@@ -0,0 +1,271 @@
+package annotation
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// defaultRowOverlap is the share of the shorter field's height two fields
+// must both cover to count as one row in tab order.
+const defaultRowOverlap = 0.5
+
+// TabOrderOptions controls ComputeTabOrder.
+type TabOrderOptions struct {
+	// RowOverlap is the share of the shorter field's height that a field's
+	// vertical extent must share with a row's to join it; the default is
+	// 0.5. Fields without a height join a row whose top is within the
+	// default row tolerance of theirs.
+	RowOverlap float64
+	// Columns, above 1, orders the page column by column: the page width
+	// is divided into this many equal columns, each field belongs to the
+	// column holding its left edge, and each column is ordered in rows.
+	Columns int
+	// PinnedGroups lists groups whose members on the page are kept
+	// together in the order of the group's field_ids, where the first of
+	// them falls. Radio and yes/no groups are always kept together, in
+	// reading order.
+	PinnedGroups []string
+	// InferColumns orders the page as ComputeReadingOrder does, finding
+	// its columns from the layout; Columns is then ignored.
+	InferColumns bool
+}
+
+// ComputeTabOrder returns the IDs of a page's fields in tab order: in rows
+// from the top of the page and, within a row, from the left. Virtual fields
+// have no place in tab order and are left out.
+func (fa *FormAnnotation) ComputeTabOrder(pageNum int, opts TabOrderOptions) ([]string, error) {
+	idx := slices.IndexFunc(fa.Pages, func(p Page) bool { return p.PageNumber == pageNum })
+	if idx < 0 {
+		return nil, fmt.Errorf("no page %d", pageNum)
+	}
+	if opts.Columns < 0 {
+		return nil, fmt.Errorf("tab order: %d columns", opts.Columns)
+	}
+	overlap := opts.RowOverlap
+	if overlap <= 0 {
+		overlap = defaultRowOverlap
+	}
+	boxes := fa.tabBoxes(fa.Pages[idx].Fields)
+	if opts.InferColumns {
+		boxes = xyCut(boxes, defaultMinGutter, overlap)
+	} else {
+		if opts.Columns > 1 {
+			if ps, ok := pageInPoints(fa.PageSizeOf(fa.Pages[idx].PageNumber)); ok && ps.Width > 0 {
+				width := ps.Width / float64(opts.Columns)
+				for _, b := range boxes {
+					b.column = min(max(int(math.Floor(b.left/width)), 0), opts.Columns-1)
+				}
+			}
+		}
+		boxes = inRows(boxes, overlap)
+	}
+	ordered := make([]*Field, len(boxes))
+	for i, b := range boxes {
+		ordered[i] = b.f
+	}
+
+	// Gather each kept-together group at its first member.
+	var ids []string
+	done := map[*Field]bool{}
+	for _, f := range ordered {
+		if done[f] {
+			continue
+		}
+		members := []*Field{f}
+		if g := fa.GetGroup(f.GroupID); g != nil {
+			switch {
+			case slices.Contains(opts.PinnedGroups, g.GroupID):
+				members = members[:0]
+				for _, id := range g.FieldIDs {
+					if m := fa.findOnPage(ordered, id); m != nil && !done[m] {
+						members = append(members, m)
+					}
+				}
+			case g.GroupType == GroupTypeRadio || g.GroupType == GroupTypeYesNo:
+				for _, m := range ordered {
+					if m != f && m.GroupID == g.GroupID && !done[m] {
+						members = append(members, m)
+					}
+				}
+			}
+		}
+		if !slices.Contains(members, f) {
+			members = append(members, f)
+		}
+		for _, m := range members {
+			done[m] = true
+			ids = append(ids, m.FieldID)
+		}
+	}
+	return ids, nil
+}
+
+// tabBox is a field's extent in points, with top and bottom measured down
+// the page whatever the origin. A segmented field is placed by its first
+// segment and ends at the right of its last.
+type tabBox struct {
+	f                        *Field
+	top, bottom, left, right float64
+	column                   int
+}
+
+// tabBoxes returns the boxes of the rendered fields, in document order.
+func (fa *FormAnnotation) tabBoxes(fields []Field) []*tabBox {
+	unit := fa.FormMetadata.PageSize.Unit
+	inPoints := func(p Position) Position {
+		if pt, ok := positionInPoints(p, unit); ok {
+			return pt
+		}
+		return p
+	}
+	var boxes []*tabBox
+	for _, f := range renderedFields(fields) {
+		p := inPoints(readingPosition(f))
+		top := p.Y
+		if fa.origin() == OriginBottomLeft {
+			top = -(p.Y + p.Height)
+		}
+		b := &tabBox{f: f, top: top, bottom: top + p.Height, left: p.X, right: p.X + p.Width}
+		for _, s := range f.Segments {
+			sp := inPoints(s.Position)
+			b.right = max(b.right, sp.X+sp.Width)
+		}
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
+
+// inRows orders boxes column by column and, within a column, in rows from
+// the top and then from the left. A box joins a row when it shares more
+// than overlap of the shorter height with the row's first box.
+func inRows(boxes []*tabBox, overlap float64) []*tabBox {
+	boxes = slices.Clone(boxes)
+	slices.SortStableFunc(boxes, func(a, b *tabBox) int {
+		return cmp.Or(cmp.Compare(a.column, b.column), cmp.Compare(a.top, b.top))
+	})
+	for start := 0; start < len(boxes); {
+		end := start + 1
+		for end < len(boxes) && sameRow(boxes[start], boxes[end], overlap) {
+			end++
+		}
+		slices.SortStableFunc(boxes[start:end], func(a, b *tabBox) int { return cmp.Compare(a.left, b.left) })
+		start = end
+	}
+	return boxes
+}
+
+// sameRow reports whether b belongs to the row starting with first.
+func sameRow(first, b *tabBox, overlap float64) bool {
+	if first.column != b.column {
+		return false
+	}
+	h := min(first.bottom-first.top, b.bottom-b.top)
+	if h <= 0 {
+		return b.top-first.top <= defaultRowTolerance
+	}
+	shared := min(first.bottom, b.bottom) - max(first.top, b.top)
+	return shared > overlap*h
+}
+
+// findOnPage returns the field among fields with the given ID, or nil.
+func (fa *FormAnnotation) findOnPage(fields []*Field, fieldID string) *Field {
+	for _, f := range fields {
+		if fa.sameID(f.FieldID, fieldID) {
+			return f
+		}
+	}
+	return nil
+}
+
+// ApplyTabOrder computes every page's tab order and records it in the
+// fields' TabIndex, numbering each page from 1. Virtual fields are left
+// at zero.
+func (fa *FormAnnotation) ApplyTabOrder(opts TabOrderOptions) error {
+	for i := range fa.Pages {
+		page := &fa.Pages[i]
+		ids, err := fa.ComputeTabOrder(page.PageNumber, opts)
+		if err != nil {
+			return err
+		}
+		index := map[string]int{}
+		for n, id := range ids {
+			index[id] = n + 1
+		}
+		for j := range page.Fields {
+			page.Fields[j].TabIndex = index[page.Fields[j].FieldID]
+		}
+	}
+	return nil
+}
+
+// InvalidTabOrder is the validation issue code for a page whose tab
+// indexes are not 1 through the number of its fields.
+const InvalidTabOrder = "invalid_tab_order"
+
+// checkTabOrder reports, on each page giving any field a tab index, the
+// rendered fields without one or with a negative one, indexes used twice,
+// and numbers missing from 1 to the page's field count. Virtual fields
+// must have none.
+func (fa *FormAnnotation) checkTabOrder() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, page := range fa.Pages {
+		at := func(fieldID, msg string, args ...any) {
+			issues = append(issues, ValidationIssue{Code: InvalidTabOrder, Severity: SeverityError, FieldID: fieldID, Page: page.PageNumber, Message: fmt.Sprintf(msg, args...)})
+		}
+		rendered := renderedFields(page.Fields)
+		if !slices.ContainsFunc(rendered, func(f *Field) bool { return f.TabIndex != 0 }) {
+			continue
+		}
+		for _, f := range page.Fields {
+			if f.IsVirtual() && f.TabIndex != 0 {
+				at(f.FieldID, "virtual field has tab index %d", f.TabIndex)
+			}
+		}
+		by := map[int]string{}
+		for _, f := range rendered {
+			switch other, taken := by[f.TabIndex]; {
+			case f.TabIndex == 0:
+				at(f.FieldID, "field has no tab index while others on page %d do", page.PageNumber)
+			case f.TabIndex < 0:
+				at(f.FieldID, "tab index %d is negative", f.TabIndex)
+			case f.TabIndex > len(rendered):
+				at(f.FieldID, "tab index %d is past the page's %d fields", f.TabIndex, len(rendered))
+			case taken:
+				at(f.FieldID, "tab index %d is also field %q's", f.TabIndex, other)
+			default:
+				by[f.TabIndex] = f.FieldID
+			}
+		}
+		var missing []string
+		for n := 1; n <= len(rendered); n++ {
+			if _, ok := by[n]; !ok {
+				missing = append(missing, strconv.Itoa(n))
+			}
+		}
+		if len(missing) > 0 {
+			at("", "tab order skips %s", strings.Join(missing, ", "))
+		}
+	}
+	return issues
+}
+
+// tabSequence returns the indexes of fields in focus order: by tab index,
+// then the fields without one in document order.
+func tabSequence(fields []*Field) []int {
+	seq := make([]int, len(fields))
+	for i := range seq {
+		seq[i] = i
+	}
+	key := func(i int) int {
+		if fields[i].TabIndex <= 0 {
+			return math.MaxInt
+		}
+		return fields[i].TabIndex
+	}
+	slices.SortStableFunc(seq, func(a, b int) int { return cmp.Compare(key(a), key(b)) })
+	return seq
+}
@@ -0,0 +1,116 @@
+package annotation
+
+import "fmt"
+
+// GroupTypeTable marks a group whose members form a grid, such as the
+// dependents table; its rows follow reading order.
+const GroupTypeTable = "table"
+
+// Structure roles used in a TagTree. They are the standard PDF structure
+// types an adapter emits in the StructTree.
+const (
+	TagDocument = "Document"
+	TagPart     = "Part"
+	TagDiv      = "Div"
+	TagTable    = "Table"
+	TagRow      = "TR"
+	TagCell     = "TD"
+	TagForm     = "Form"
+)
+
+// TagTree is the logical structure of a filled form, independent of any PDF
+// library. An adapter translates each node into a structure element and the
+// marked content of the field it stamps.
+type TagTree struct {
+	Root *TagNode `json:"root"`
+	// Unlabeled lists the rendered fields that have no label, and therefore
+	// no accessible name beyond their ID.
+	Unlabeled []string `json:"unlabeled,omitempty"`
+}
+
+// TagNode is one structure element. Field nodes carry the field they tag.
+type TagNode struct {
+	Role     string     `json:"role"`
+	Page     int        `json:"page,omitempty"`
+	GroupID  string     `json:"group_id,omitempty"`
+	FieldID  string     `json:"field_id,omitempty"`
+	Label    string     `json:"label,omitempty"`
+	AltText  string     `json:"alt_text,omitempty"`
+	Children []*TagNode `json:"children,omitempty"`
+}
+
+// ExportTagHints derives the structure tree for the annotation: one Part per
+// page holding its fields in reading order, each associated with its label.
+// A group appears where its first member is read; table groups become
+// tables whose rows are the reading-order rows of their members, and other
+// groups a Div. Field IDs must be unique so that every field can be tagged.
+func (fa *FormAnnotation) ExportTagHints() (*TagTree, error) {
+	for _, issue := range fa.Validate() {
+		if issue.Code == EmptyFieldID || issue.Code == DuplicateFieldID {
+			return nil, fmt.Errorf("page %d: %s", issue.Page, issue.Message)
+		}
+	}
+	groups := map[string]*FieldGroup{}
+	for i := range fa.FieldGroups {
+		groups[fa.FieldGroups[i].GroupID] = &fa.FieldGroups[i]
+	}
+
+	tree := &TagTree{Root: &TagNode{Role: TagDocument}}
+	for _, page := range fa.Pages {
+		part := &TagNode{Role: TagPart, Page: page.PageNumber}
+		placed := map[string]bool{}
+		for _, field := range readingOrder(page.Fields, defaultRowTolerance, fa.origin()) {
+			if field.Label == "" {
+				tree.Unlabeled = append(tree.Unlabeled, field.FieldID)
+			}
+			group, ok := groups[field.GroupID]
+			if !ok {
+				part.Children = append(part.Children, fieldTag(field))
+				continue
+			}
+			if placed[group.GroupID] {
+				continue
+			}
+			placed[group.GroupID] = true
+			part.Children = append(part.Children, fa.groupTag(group, page))
+		}
+		tree.Root.Children = append(tree.Root.Children, part)
+	}
+	return tree, nil
+}
+
+// groupTag builds the node for the members of group on page.
+func (fa *FormAnnotation) groupTag(group *FieldGroup, page Page) *TagNode {
+	var members []Field
+	for _, field := range page.Fields {
+		if field.GroupID == group.GroupID {
+			members = append(members, field)
+		}
+	}
+	if group.GroupType != GroupTypeTable {
+		node := &TagNode{Role: TagDiv, GroupID: group.GroupID}
+		for _, field := range readingOrder(members, defaultRowTolerance, fa.origin()) {
+			node.Children = append(node.Children, fieldTag(field))
+		}
+		return node
+	}
+	table := &TagNode{Role: TagTable, GroupID: group.GroupID}
+	for _, row := range readingRows(members, defaultRowTolerance, fa.origin()) {
+		tr := &TagNode{Role: TagRow}
+		for _, field := range row {
+			tr.Children = append(tr.Children, &TagNode{Role: TagCell, Children: []*TagNode{fieldTag(field)}})
+		}
+		table.Children = append(table.Children, tr)
+	}
+	return table
+}
+
+// fieldTag tags a single field. Its alternate text is the label, or the
+// line reference text when the field has no label.
+func fieldTag(field *Field) *TagNode {
+	alt := field.Label
+	if alt == "" {
+		alt = field.IRSLineRef
+	}
+	return &TagNode{Role: TagForm, FieldID: field.FieldID, Label: field.Label, AltText: alt}
+}
@@ -0,0 +1,187 @@
+package annotation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// templateSuffix marks page template documents in a library.
+const templateSuffix = ".template.json"
+
+// PageTemplate is a named partial page shared by several forms, such as a
+// common header block. Field positions are relative to the point the
+// template is included at.
+type PageTemplate struct {
+	Name string `json:"template_name"`
+	// Unit applies to positions that declare none; the including page's
+	// unit when empty.
+	Unit   string  `json:"unit,omitempty"`
+	Fields []Field `json:"fields"`
+}
+
+// PageInclude places a template's fields on a page. Offsets are in the
+// page unit, and Prefix is prepended to every included field ID.
+type PageInclude struct {
+	Template string  `json:"template"`
+	OffsetX  float64 `json:"offset_x,omitempty"`
+	OffsetY  float64 `json:"offset_y,omitempty"`
+	Prefix   string  `json:"field_id_prefix,omitempty"`
+}
+
+// SetTemplate adds t to the library, replacing any template of the same
+// name. Forms pick up the change the next time their includes are resolved.
+func (l *Library) SetTemplate(t *PageTemplate) {
+	if l.templates == nil {
+		l.templates = map[string]*PageTemplate{}
+	}
+	l.templates[t.Name] = t
+}
+
+// Template returns the template called name, or nil.
+func (l *Library) Template(name string) *PageTemplate {
+	if l == nil {
+		return nil
+	}
+	return l.templates[name]
+}
+
+// Templates returns the library's template names in sorted order.
+func (l *Library) Templates() []string {
+	names := make([]string, 0, len(l.templates))
+	for name := range l.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasIncludes reports whether any page includes a template.
+func (fa *FormAnnotation) HasIncludes() bool {
+	for _, page := range fa.Pages {
+		if len(page.Includes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveIncludes returns a copy of the annotation with every page include
+// replaced by the template's fields from lib, offset and prefixed. The
+// annotation itself keeps its includes, so resolving again after a template
+// changes picks up the change. An included field whose ID is already used
+// is an error.
+func (fa *FormAnnotation) ResolveIncludes(lib *Library) (*FormAnnotation, error) {
+	out := fa.Clone()
+	if !fa.HasIncludes() {
+		return out, nil
+	}
+	ix := out.BuildIndex()
+	taken := map[string]bool{}
+	for key := range ix.byID {
+		taken[key] = true
+	}
+	unit := fa.FormMetadata.PageSize.Unit
+	for i := range out.Pages {
+		page := &out.Pages[i]
+		for _, inc := range page.Includes {
+			t := lib.Template(inc.Template)
+			if t == nil {
+				return nil, fmt.Errorf("page %d includes unknown template %q", page.PageNumber, inc.Template)
+			}
+			fields, err := t.instantiate(inc, unit)
+			if err != nil {
+				return nil, fmt.Errorf("page %d: template %q: %w", page.PageNumber, inc.Template, err)
+			}
+			for _, field := range fields {
+				if taken[ix.key(field.FieldID)] {
+					return nil, fmt.Errorf("page %d: field %q included from template %q is already defined",
+						page.PageNumber, field.FieldID, inc.Template)
+				}
+				taken[ix.key(field.FieldID)] = true
+			}
+			page.Fields = append(page.Fields, fields...)
+		}
+		page.Includes = nil
+	}
+	return out, nil
+}
+
+// instantiate copies the template's fields for one include: IDs and the
+// references between them gain the prefix, and positions are offset and
+// given an explicit unit.
+func (t *PageTemplate) instantiate(inc PageInclude, pageUnit string) ([]Field, error) {
+	offX, okX := toPoints(inc.OffsetX, pageUnit)
+	offY, okY := toPoints(inc.OffsetY, pageUnit)
+	if !okX || !okY {
+		return nil, fmt.Errorf("unknown page unit %q", pageUnit)
+	}
+	tmp := &FormAnnotation{Pages: []Page{{Fields: make([]Field, len(t.Fields))}}}
+	for i := range t.Fields {
+		tmp.Pages[0].Fields[i] = t.Fields[i].Clone()
+	}
+	fields := tmp.Pages[0].Fields
+	if inc.Prefix != "" {
+		for i := range fields {
+			old := fields[i].FieldID
+			tmp.renameField(&fields[i], inc.Prefix+old, func(ref string) bool { return ref == old })
+		}
+	}
+	fallback := t.Unit
+	if fallback == "" {
+		fallback = pageUnit
+	}
+	shift := func(p Position) (Position, error) {
+		if p.Unit == "" {
+			p.Unit = fallback
+		}
+		factor, ok := toPoints(1, p.Unit)
+		if !ok {
+			return p, fmt.Errorf("unknown position unit %q", p.Unit)
+		}
+		p.X += offX / factor
+		p.Y += offY / factor
+		return p, nil
+	}
+	for i := range fields {
+		if fields[i].IsVirtual() {
+			continue
+		}
+		var err error
+		if fields[i].Position, err = shift(fields[i].Position); err != nil {
+			return nil, fmt.Errorf("field %q: %w", fields[i].FieldID, err)
+		}
+		for j := range fields[i].Segments {
+			if fields[i].Segments[j].Position, err = shift(fields[i].Segments[j].Position); err != nil {
+				return nil, fmt.Errorf("field %q: %w", fields[i].FieldID, err)
+			}
+		}
+	}
+	return fields, nil
+}
+
+// LoadTemplateFromStore reads the page template stored at key.
+func LoadTemplateFromStore(ctx context.Context, store Store, key string, opts LoadOptions) (*PageTemplate, error) {
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = decodeInput(data, opts); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	var t PageTemplate
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	if t.Name == "" {
+		return nil, fmt.Errorf("%s: template has no template_name", key)
+	}
+	return &t, nil
+}
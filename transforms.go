@@ -0,0 +1,200 @@
+package annotation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ValueTransform rewrites an upstream value before it is placed in a field.
+type ValueTransform interface {
+	Apply(value string) (string, error)
+}
+
+// InvertibleTransform is a ValueTransform that can restore the upstream
+// value on extraction.
+type InvertibleTransform interface {
+	ValueTransform
+	Invert(value string) (string, error)
+}
+
+// TransformFunc adapts a function to ValueTransform.
+type TransformFunc func(value string) (string, error)
+
+func (fn TransformFunc) Apply(value string) (string, error) { return fn(value) }
+
+var (
+	transformsMu sync.RWMutex
+	transforms   = map[string]ValueTransform{}
+)
+
+// RegisterTransform makes t available to fields under name. It panics if
+// name is empty or already registered, so that a typo cannot silently
+// replace a standard transform.
+func RegisterTransform(name string, t ValueTransform) {
+	transformsMu.Lock()
+	defer transformsMu.Unlock()
+	if name == "" || t == nil {
+		panic("annotation: RegisterTransform needs a name and a transform")
+	}
+	if _, dup := transforms[name]; dup {
+		panic("annotation: transform " + name + " is already registered")
+	}
+	transforms[name] = t
+}
+
+// Transforms returns the names of the registered transforms in sorted order.
+func Transforms() []string {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	names := make([]string, 0, len(transforms))
+	for name := range transforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupTransform(name string) (ValueTransform, bool) {
+	transformsMu.RLock()
+	defer transformsMu.RUnlock()
+	t, ok := transforms[name]
+	return t, ok
+}
+
+// TransformError reports a transform that failed on a field's value. On a
+// sensitive field its message names only the field and the transform, since
+// a registered transform's error may quote the value; Err still has it.
+type TransformError struct {
+	FieldID   string
+	Transform string
+	Err       error
+
+	sensitive bool
+}
+
+func (e *TransformError) Error() string {
+	if e.sensitive {
+		return fmt.Sprintf("field %q: transform %q failed", e.FieldID, e.Transform)
+	}
+	return fmt.Sprintf("field %q: transform %q: %v", e.FieldID, e.Transform, e.Err)
+}
+
+func (e *TransformError) Unwrap() error { return e.Err }
+
+// applyTransforms runs the field's transforms over value in order.
+func applyTransforms(f *Field, value string) (string, error) {
+	for _, name := range f.Transforms {
+		t, ok := lookupTransform(name)
+		if !ok {
+			return "", &TransformError{FieldID: f.FieldID, Transform: name, Err: fmt.Errorf("not registered")}
+		}
+		out, err := t.Apply(value)
+		if err != nil {
+			return "", &TransformError{FieldID: f.FieldID, Transform: name, Err: err, sensitive: f.IsSensitive()}
+		}
+		value = out
+	}
+	return value, nil
+}
+
+// invertTransforms undoes the field's transforms in reverse order, stopping
+// at the first one that cannot be inverted: the value is then returned as
+// that transform produced it.
+func invertTransforms(f *Field, value string) (string, error) {
+	for i := len(f.Transforms) - 1; i >= 0; i-- {
+		t, _ := lookupTransform(f.Transforms[i])
+		inv, ok := t.(InvertibleTransform)
+		if !ok {
+			break
+		}
+		out, err := inv.Invert(value)
+		if err != nil {
+			return "", &TransformError{FieldID: f.FieldID, Transform: f.Transforms[i], Err: err, sensitive: f.IsSensitive()}
+		}
+		value = out
+	}
+	return value, nil
+}
+
+func init() {
+	simple := func(fn func(string) string) TransformFunc {
+		return func(v string) (string, error) { return fn(v), nil }
+	}
+	RegisterTransform("trim", simple(strings.TrimSpace))
+	RegisterTransform("upper", simple(strings.ToUpper))
+	RegisterTransform("lower", simple(strings.ToLower))
+	RegisterTransform("title", simple(titleCase))
+	RegisterTransform("strip_non_digits", simple(func(v string) string {
+		return strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, v)
+	}))
+	RegisterTransform("usps_state", uspsState{})
+}
+
+// titleCase capitalizes the first letter of every word and lowercases the rest.
+func titleCase(v string) string {
+	runes := []rune(strings.ToLower(v))
+	start := true
+	for i, r := range runes {
+		if start && unicode.IsLetter(r) {
+			runes[i] = unicode.ToUpper(r)
+		}
+		start = unicode.IsSpace(r) || r == '-'
+	}
+	return string(runes)
+}
+
+// uspsState maps state names, or abbreviations in any case, to the USPS
+// abbreviation, and inverts to the state name.
+type uspsState struct{}
+
+func (uspsState) Apply(v string) (string, error) {
+	key := strings.ToUpper(strings.Join(strings.Fields(v), " "))
+	if key == "" {
+		return "", nil
+	}
+	if _, ok := uspsStateNames[key]; ok {
+		return key, nil
+	}
+	for abbr, name := range uspsStateNames {
+		if strings.ToUpper(name) == key {
+			return abbr, nil
+		}
+	}
+	return "", fmt.Errorf("not a US state or territory")
+}
+
+func (uspsState) Invert(v string) (string, error) {
+	if v == "" {
+		return "", nil
+	}
+	name, ok := uspsStateNames[strings.ToUpper(v)]
+	if !ok {
+		return "", fmt.Errorf("not a USPS state abbreviation")
+	}
+	return name, nil
+}
+
+var uspsStateNames = map[string]string{
+	"AL": "Alabama", "AK": "Alaska", "AZ": "Arizona", "AR": "Arkansas", "CA": "California",
+	"CO": "Colorado", "CT": "Connecticut", "DE": "Delaware", "DC": "District of Columbia",
+	"FL": "Florida", "GA": "Georgia", "HI": "Hawaii", "ID": "Idaho", "IL": "Illinois",
+	"IN": "Indiana", "IA": "Iowa", "KS": "Kansas", "KY": "Kentucky", "LA": "Louisiana",
+	"ME": "Maine", "MD": "Maryland", "MA": "Massachusetts", "MI": "Michigan", "MN": "Minnesota",
+	"MS": "Mississippi", "MO": "Missouri", "MT": "Montana", "NE": "Nebraska", "NV": "Nevada",
+	"NH": "New Hampshire", "NJ": "New Jersey", "NM": "New Mexico", "NY": "New York",
+	"NC": "North Carolina", "ND": "North Dakota", "OH": "Ohio", "OK": "Oklahoma", "OR": "Oregon",
+	"PA": "Pennsylvania", "RI": "Rhode Island", "SC": "South Carolina", "SD": "South Dakota",
+	"TN": "Tennessee", "TX": "Texas", "UT": "Utah", "VT": "Vermont", "VA": "Virginia",
+	"WA": "Washington", "WV": "West Virginia", "WI": "Wisconsin", "WY": "Wyoming",
+	"AS": "American Samoa", "GU": "Guam", "MP": "Northern Mariana Islands", "PR": "Puerto Rico",
+	"VI": "U.S. Virgin Islands", "AA": "Armed Forces Americas", "AE": "Armed Forces Europe",
+	"AP": "Armed Forces Pacific",
+}
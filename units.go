@@ -0,0 +1,192 @@
+package annotation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// pointsPerUnit maps a unit name to the number of PDF points it spans.
+// Pixels assume the 72 DPI the annotation tooling exports at.
+var pointsPerUnit = map[string]float64{
+	"pt": 1,
+	"in": 72,
+	"mm": 72 / 25.4,
+	"cm": 72 / 2.54,
+	"px": 1,
+}
+
+// toPoints converts v from unit to points. An empty unit is treated as points.
+func toPoints(v float64, unit string) (float64, bool) {
+	if unit == "" {
+		return v, true
+	}
+	factor, ok := pointsPerUnit[strings.ToLower(unit)]
+	if !ok {
+		return 0, false
+	}
+	return v * factor, true
+}
+
+// positionInPoints returns p expressed in points, falling back to the page
+// unit when the position does not declare its own.
+func positionInPoints(p Position, fallbackUnit string) (Position, bool) {
+	unit := p.Unit
+	if unit == "" {
+		unit = fallbackUnit
+	}
+	factor, ok := toPoints(1, unit)
+	if !ok {
+		return Position{}, false
+	}
+	return Position{
+		X:      p.X * factor,
+		Y:      p.Y * factor,
+		Width:  p.Width * factor,
+		Height: p.Height * factor,
+		Unit:   "pt",
+	}, true
+}
+
+// pageInPoints returns the page size expressed in points.
+func pageInPoints(ps PageSize) (PageSize, bool) {
+	factor, ok := toPoints(1, ps.Unit)
+	if !ok {
+		return PageSize{}, false
+	}
+	return PageSize{Width: ps.Width * factor, Height: ps.Height * factor, Unit: "pt"}, true
+}
+
+// UnitOptions controls unit conversion.
+type UnitOptions struct {
+	// DPI is the resolution pixels are measured at. Zero uses 72, making a
+	// pixel a point.
+	DPI float64
+}
+
+// unitFactor returns the number of points one unit spans.
+func (o UnitOptions) unitFactor(unit string) (float64, bool) {
+	if strings.ToLower(unit) == "px" && o.DPI > 0 {
+		return 72 / o.DPI, true
+	}
+	return toPoints(1, unit)
+}
+
+// scale returns the factor converting from unit to target.
+func (o UnitOptions) scale(unit, target string) (float64, bool) {
+	from, ok := o.unitFactor(unit)
+	if !ok {
+		return 0, false
+	}
+	to, _ := o.unitFactor(target)
+	return from / to, true
+}
+
+// ConvertPosition returns p in the target unit, with pixels at 72 DPI. A
+// position without a unit is taken to be in points.
+func ConvertPosition(p Position, target string) (Position, error) {
+	return UnitOptions{}.ConvertPosition(p, target)
+}
+
+// ConvertPosition returns p in the target unit. A position without a unit
+// is taken to be in points.
+func (o UnitOptions) ConvertPosition(p Position, target string) (Position, error) {
+	target = strings.ToLower(target)
+	if _, ok := pointsPerUnit[strings.ToLower(target)]; !ok {
+		return Position{}, fmt.Errorf("unknown unit %q", target)
+	}
+	return o.convertPosition(p, "pt", target)
+}
+
+func (o UnitOptions) convertPosition(p Position, fallbackUnit, target string) (Position, error) {
+	unit := p.Unit
+	if unit == "" {
+		unit = fallbackUnit
+	}
+	k, ok := o.scale(unit, target)
+	if !ok {
+		return Position{}, fmt.Errorf("unknown unit %q", unit)
+	}
+	return Position{
+		X:      roundUnit(p.X * k),
+		Y:      roundUnit(p.Y * k),
+		Width:  roundUnit(p.Width * k),
+		Height: roundUnit(p.Height * k),
+		Unit:   target,
+	}, nil
+}
+
+// ConvertUnits rewrites the page size and every field and segment position
+// in the target unit: "pt", "in", "mm", "cm" or "px", with pixels at 72
+// DPI. Margins and template offsets, which are in the page unit, move with
+// it. On error the annotation is left unchanged.
+func (fa *FormAnnotation) ConvertUnits(target string) error {
+	return fa.ConvertUnitsWithOptions(target, UnitOptions{})
+}
+
+// ConvertUnitsWithOptions is ConvertUnits with pixels measured at opts.DPI.
+func (fa *FormAnnotation) ConvertUnitsWithOptions(target string, opts UnitOptions) error {
+	target = strings.ToLower(target)
+	if _, ok := pointsPerUnit[target]; !ok {
+		return fmt.Errorf("unknown unit %q", target)
+	}
+	out := fa.Clone()
+	md := &out.FormMetadata
+	pageUnit := md.PageSize.Unit
+	if pageUnit == "" {
+		pageUnit = "pt"
+	}
+	k, ok := opts.scale(pageUnit, target)
+	if !ok {
+		return fmt.Errorf("page size: unknown unit %q", md.PageSize.Unit)
+	}
+	md.PageSize.Width = roundUnit(md.PageSize.Width * k)
+	md.PageSize.Height = roundUnit(md.PageSize.Height * k)
+	md.PageSize.Unit = target
+	md.PageSize.Frame.scale(k)
+	for i := range out.Pages {
+		page := &out.Pages[i]
+		page.Frame.scale(k)
+		if ps := page.PageSize; ps != nil {
+			ps.Width, ps.Height = roundUnit(ps.Width*k), roundUnit(ps.Height*k)
+			if ps.Unit != "" {
+				ps.Unit = target
+			}
+		}
+		for j := range page.Includes {
+			page.Includes[j].OffsetX = roundUnit(page.Includes[j].OffsetX * k)
+			page.Includes[j].OffsetY = roundUnit(page.Includes[j].OffsetY * k)
+		}
+		for j := range page.Fields {
+			field := &page.Fields[j]
+			var err error
+			if field.Position, err = opts.convertPosition(field.Position, pageUnit, target); err != nil {
+				return fmt.Errorf("field %q: %w", field.FieldID, err)
+			}
+			for s := range field.Segments {
+				if field.Segments[s].Position, err = opts.convertPosition(field.Segments[s].Position, pageUnit, target); err != nil {
+					return fmt.Errorf("field %q segment %d: %w", field.FieldID, s+1, err)
+				}
+			}
+		}
+	}
+	*fa = *out
+	return nil
+}
+
+// scale multiplies the frame's margins by k.
+func (f *CoordinateFrame) scale(k float64) {
+	if f == nil {
+		return
+	}
+	f.MarginTop = roundUnit(f.MarginTop * k)
+	f.MarginLeft = roundUnit(f.MarginLeft * k)
+	f.MarginBottom = roundUnit(f.MarginBottom * k)
+	f.MarginRight = roundUnit(f.MarginRight * k)
+}
+
+// roundUnit trims the noise a chain of conversions leaves, so 1in converted
+// to points and back reads 1 rather than 0.9999999999999999.
+func roundUnit(v float64) float64 {
+	return math.Round(v*1e9) / 1e9
+}
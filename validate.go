@@ -0,0 +1,363 @@
+package annotation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Structural validation issue codes.
+const (
+	EmptyFieldID         = "empty_field_id"
+	DuplicateFieldID     = "duplicate_field_id"
+	CaseDuplicateFieldID = "case_duplicate_field_id"
+	MissingGroupRef      = "missing_group_ref"
+	UnknownGroupMember   = "unknown_group_member"
+	UnknownReference     = "unknown_reference"
+	InvalidFontSize      = "invalid_font_size"
+	SmallFontSize        = "small_font_size"
+	InvalidMarkSize      = "invalid_mark_size"
+	UnknownHelpReference = "unknown_help_reference"
+	UnknownRenderTarget  = "unknown_render_target"
+	OverrideOffPage      = "override_off_page"
+	InvalidYearRange     = "invalid_year_range"
+	FieldInactiveForYear = "field_inactive_for_year"
+	UnknownTransform     = "unknown_transform"
+	PageCountMismatch    = "page_count_differs"
+	InvalidPosition      = "invalid_position"
+	SegmentsMissing      = "segments_missing"
+	IncompatibleDataType = "incompatible_data_type"
+	MalformedLineRef     = "malformed_line_ref"
+	UnknownFieldType     = "unknown_field_type"
+	UnknownDataType      = "unknown_data_type"
+	PositionOffPage      = "position_off_page"
+)
+
+// fieldDataTypes lists the data types each field type can sensibly hold.
+// Field types not listed, such as virtual fields, accept any data type.
+var fieldDataTypes = map[FieldType][]DataType{
+	FieldTypeText:      {DataTypeString, DataTypeDecimal, DataTypeInteger, DataTypeDate},
+	FieldTypeCurrency:  {DataTypeDecimal, DataTypeInteger},
+	FieldTypeNumeric:   {DataTypeDecimal, DataTypeInteger},
+	FieldTypeCheckbox:  {DataTypeBoolean},
+	FieldTypeDate:      {DataTypeDate, DataTypeString},
+	FieldTypeSegmented: {DataTypeString, DataTypeInteger, DataTypeDate},
+	FieldTypeSignature: {DataTypeString},
+	FieldTypeChoice:    {DataTypeString, DataTypeInteger},
+	FieldTypeBarcode:   {DataTypeString},
+	FieldTypeImage:     {DataTypeString},
+}
+
+// minReadableFontSize is the point size below which text is flagged as
+// unlikely to be legible once printed.
+const minReadableFontSize = 4
+
+// Validate checks the annotation's structural integrity and returns every
+// problem found. A font size of zero means the renderer's default and is
+// not reported. Field IDs that differ only by case are always reported:
+// as errors under CaseInsensitiveIDs, where they collide, and as warnings
+// otherwise.
+func (fa *FormAnnotation) Validate() []ValidationIssue {
+	var issues []ValidationIssue
+	add := func(issue ValidationIssue, format string, args ...any) {
+		if issue.Severity == "" {
+			issue.Severity = SeverityError
+		}
+		issue.Message = fmt.Sprintf(format, args...)
+		issues = append(issues, issue)
+	}
+
+	if fa.FormMetadata.PageCount != len(fa.Pages) {
+		add(ValidationIssue{Code: PageCountMismatch}, "page_count is %d but the form has %d pages", fa.FormMetadata.PageCount, len(fa.Pages))
+	}
+
+	exact := map[string]bool{}
+	folded := map[string]string{}
+	for _, page := range fa.Pages {
+		for _, field := range page.Fields {
+			at := ValidationIssue{FieldID: field.FieldID, Page: page.PageNumber}
+			if field.FieldID == "" {
+				at.Code = EmptyFieldID
+				add(at, "field has no ID")
+				continue
+			}
+			if exact[field.FieldID] {
+				at.Code = DuplicateFieldID
+				add(at, "field ID is used more than once")
+				continue
+			}
+			exact[field.FieldID] = true
+			key := foldID(field.FieldID)
+			if prev, ok := folded[key]; ok {
+				at.Code = CaseDuplicateFieldID
+				if !fa.CaseInsensitiveIDs {
+					at.Severity = SeverityWarning
+				}
+				add(at, "field ID differs from %q only by case", prev)
+				continue
+			}
+			folded[key] = field.FieldID
+		}
+	}
+
+	groups := map[string]bool{}
+	for _, group := range fa.FieldGroups {
+		groups[group.GroupID] = true
+		for _, id := range group.FieldIDs {
+			if fa.GetFieldByID(id) == nil {
+				add(ValidationIssue{Code: UnknownGroupMember, GroupID: group.GroupID}, "member %q is not a field", id)
+			}
+		}
+	}
+	for _, page := range fa.Pages {
+		size := fa.PageSizeOf(page.PageNumber)
+		pageSize, pageOK := pageInPoints(size)
+		for _, field := range page.Fields {
+			at := ValidationIssue{FieldID: field.FieldID, Page: page.PageNumber}
+			if field.GroupID != "" && !groups[field.GroupID] {
+				at.Code = MissingGroupRef
+				add(at, "group %q is not defined", field.GroupID)
+			}
+			if pos := field.Position; pos.Width < 0 || pos.Height < 0 {
+				at.Code = InvalidPosition
+				add(at, "position has negative size %gx%g", pos.Width, pos.Height)
+			}
+			for _, pos := range append([]Position{field.Position}, segmentPositions(&field)...) {
+				if _, ok := toPoints(1, pos.Unit); !ok {
+					at.Code = InvalidPosition
+					add(at, "position unit %q is unknown", pos.Unit)
+					break
+				}
+			}
+			if pageOK && !field.IsVirtual() && fa.offPage(&field, page.PageNumber, pageSize) {
+				at.Code = PositionOffPage
+				add(at, "field extends beyond the %gx%g page", size.Width, size.Height)
+			}
+			if field.FieldType == FieldTypeSegmented && len(field.Segments) == 0 {
+				at.Code = SegmentsMissing
+				add(at, "segmented field has no segments")
+			}
+			if field.FieldType != "" && !slices.Contains(formatEnums["Field.FieldType"], string(field.FieldType)) {
+				at.Code = UnknownFieldType
+				add(at, "field type %q is unknown", field.FieldType)
+			}
+			if field.DataType != "" && !slices.Contains(formatEnums["Field.DataType"], string(field.DataType)) {
+				at.Code = UnknownDataType
+				add(at, "data type %q is unknown", field.DataType)
+			} else if allowed, ok := fieldDataTypes[field.FieldType]; ok && field.DataType != "" && !slices.Contains(allowed, field.DataType) {
+				at.Code = IncompatibleDataType
+				add(at, "a %s field cannot hold %s data", field.FieldType, field.DataType)
+			}
+			if style := field.Style; style != nil {
+				switch {
+				case style.FontSize < 0:
+					at.Code = InvalidFontSize
+					add(at, "font size %g is not positive", style.FontSize)
+				case style.FontSize > 0 && style.FontSize < minReadableFontSize:
+					warn := at
+					warn.Code, warn.Severity = SmallFontSize, SeverityWarning
+					add(warn, "font size %gpt is below %gpt", style.FontSize, float64(minReadableFontSize))
+				}
+			}
+			if cs := field.CheckStyle; cs != nil && cs.MarkSize <= 0 {
+				at.Code = InvalidMarkSize
+				add(at, "mark size %g is not positive", cs.MarkSize)
+			}
+			if field.Help != nil {
+				for _, id := range field.Help.RelatedFieldIDs {
+					if fa.GetFieldByID(id) == nil {
+						warn := at
+						warn.Code, warn.Severity = UnknownHelpReference, SeverityWarning
+						add(warn, "help refers to unknown field %q", id)
+					}
+				}
+			}
+			issues = append(issues, fa.checkOverrides(&field, page.PageNumber)...)
+			issues = append(issues, fa.checkYearRange(&field, page.PageNumber)...)
+			issues = append(issues, checkSensitivity(&field, page.PageNumber)...)
+			if malformedLineRef(field.IRSLineRef) {
+				warn := at
+				warn.Code, warn.Severity = MalformedLineRef, SeverityWarning
+				add(warn, "line reference %q names a line, box, part or schedule it does not parse as", field.IRSLineRef)
+			}
+			for _, name := range field.Transforms {
+				if _, ok := lookupTransform(name); !ok {
+					at.Code = UnknownTransform
+					add(at, "transform %q is not registered", name)
+				}
+			}
+			if v := field.Validation; v != nil {
+				for _, b := range []struct{ key, bound string }{{"min_date", v.MinDate}, {"max_date", v.MaxDate}} {
+					if _, _, err := splitDateBound(b.bound); err != nil {
+						at.Code = RuleInvalid
+						add(at, "%s: %v", b.key, err)
+					}
+				}
+			}
+			for _, rule := range field.rules() {
+				expr, err := ParseExprWithLimits(rule.src, fa.exprLimits())
+				if err != nil {
+					at.Code = RuleInvalid
+					add(at, "%s: %v", rule.key, forField(err, field.FieldID))
+					continue
+				}
+				for _, ref := range expr.Refs() {
+					if fa.GetFieldByID(ref) == nil {
+						at.Code = UnknownReference
+						add(at, "%s references unknown field %q", rule.key, ref)
+					}
+				}
+			}
+		}
+	}
+	issues = append(issues, fa.checkCalculations()...)
+	issues = append(issues, fa.checkRepeats()...)
+	issues = append(issues, fa.checkChoices()...)
+	issues = append(issues, fa.checkRelativePositions()...)
+	issues = append(issues, fa.checkLocalizations()...)
+	issues = append(issues, fa.checkMediaFields()...)
+	issues = append(issues, fa.checkSignatures()...)
+	issues = append(issues, fa.checkTabOrder()...)
+	issues = append(issues, fa.checkYesNoGroups()...)
+	issues = append(issues, fa.checkCoordinateFrames()...)
+	issues = append(issues, fa.checkCoordinateOrigin()...)
+	issues = append(issues, fa.checkPageLayout()...)
+	issues = append(issues, fa.checkRounding()...)
+	issues = append(issues, fa.checkGroupOptions()...)
+	issues = append(issues, fa.checkGroupValidations()...)
+	fa.locateIssues(issues)
+	return issues
+}
+
+// locateIssues sets the JSON path of issues about a field, group or page
+// that has none: the first field with the ID on the issue's page, or
+// anywhere when it names no page.
+func (fa *FormAnnotation) locateIssues(issues []ValidationIssue) {
+	for k := range issues {
+		issue := &issues[k]
+		if issue.Path != "" {
+			continue
+		}
+		switch {
+		case issue.FieldID != "":
+			for i, page := range fa.Pages {
+				if issue.Page != 0 && page.PageNumber != issue.Page {
+					continue
+				}
+				if j := slices.IndexFunc(page.Fields, func(f Field) bool { return fa.sameID(f.FieldID, issue.FieldID) }); j >= 0 {
+					issue.Path = fmt.Sprintf("pages[%d].fields[%d]", i, j)
+					break
+				}
+			}
+		case issue.GroupID != "":
+			if i := slices.IndexFunc(fa.FieldGroups, func(g FieldGroup) bool { return g.GroupID == issue.GroupID }); i >= 0 {
+				issue.Path = fmt.Sprintf("field_groups[%d]", i)
+			}
+		case issue.Page != 0:
+			if i := slices.IndexFunc(fa.Pages, func(p Page) bool { return p.PageNumber == issue.Page }); i >= 0 {
+				issue.Path = fmt.Sprintf("pages[%d]", i)
+			}
+		}
+	}
+}
+
+// offPage reports whether any of the field's rectangles, in points from the
+// top left, extends beyond a page of size ps.
+func (fa *FormAnnotation) offPage(field *Field, pageNum int, ps PageSize) bool {
+	abs := fa.absoluteField(field, pageNum)
+	rects := []Position{abs.Position}
+	if len(abs.Segments) > 0 {
+		rects = segmentPositions(abs)
+	}
+	for _, r := range rects {
+		p, ok := positionInPoints(r, fa.FormMetadata.PageSize.Unit)
+		if ok && (p.X < 0 || p.Y < 0 || p.X+p.Width > ps.Width || p.Y+p.Height > ps.Height) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateOptions controls ValidateAll.
+type ValidateOptions struct {
+	// Metadata, when set, also checks the form metadata under these rules.
+	Metadata *MetadataRules
+	Progress ProgressFunc
+}
+
+// ValidateAll runs every validator over the annotation: structural checks,
+// optional metadata checks, and the value rules applied to the filled values
+// page by page. Progress is reported per phase and, for values, per page.
+func (fa *FormAnnotation) ValidateAll(opts ValidateOptions) *ValidationReport {
+	report, _ := fa.ValidateAllContext(context.Background(), opts)
+	return report
+}
+
+// ValidateAllContext is ValidateAll under ctx. The context is checked before
+// each phase and each page of value rules, so every reported issue comes from
+// a check that ran to completion. When ctx ends first, the report holds the
+// completed work, is marked Incomplete, and the error is a
+// *PartialResultError naming what was skipped.
+func (fa *FormAnnotation) ValidateAllContext(ctx context.Context, opts ValidateOptions) (*ValidationReport, error) {
+	report := &ValidationReport{}
+	pages := func(from int) []int {
+		var out []int
+		for _, page := range fa.Pages[from:] {
+			out = append(out, page.PageNumber)
+		}
+		return out
+	}
+	stop := func(stages []string, from int) (*ValidationReport, error) {
+		report.Incomplete = true
+		return report, &PartialResultError{Stages: stages, Pages: pages(from), Err: ctx.Err()}
+	}
+	if ctx.Err() != nil {
+		return stop([]string{StageStructure, StageMetadata, StageValues}, 0)
+	}
+	opts.Progress.report(StageStructure, 0, 1)
+	report.Issues = append(report.Issues, fa.Validate()...)
+	opts.Progress.report(StageStructure, 1, 1)
+
+	if ctx.Err() != nil {
+		return stop([]string{StageMetadata, StageValues}, 0)
+	}
+	if opts.Metadata != nil {
+		opts.Progress.report(StageMetadata, 0, 1)
+		report.Issues = append(report.Issues, fa.ValidateMetadata(*opts.Metadata).Issues...)
+		opts.Progress.report(StageMetadata, 1, 1)
+	}
+
+	total := len(fa.Pages)
+	opts.Progress.report(StageValues, 0, total)
+	bundle, err := fa.validationBundle()
+	if err != nil {
+		report.add(ValidationIssue{Code: RuleInvalid, Severity: SeverityError, Message: err.Error()})
+		opts.Progress.report(StageValues, total, total)
+		return report, nil
+	}
+	values := map[string]string{}
+	for _, field := range fa.Fields() {
+		values[field.FieldID] = field.Value
+	}
+	if ctx.Err() != nil {
+		return stop([]string{StageValues}, 0)
+	}
+	bundle.validateGroups(values, report)
+	for i, page := range fa.Pages {
+		if ctx.Err() != nil {
+			return stop(nil, i)
+		}
+		var ids []string
+		for _, field := range page.Fields {
+			ids = append(ids, field.FieldID)
+		}
+		pageReport := &ValidationReport{}
+		bundle.validateFields(ids, values, pageReport)
+		for _, issue := range pageReport.Issues {
+			issue.Page = page.PageNumber
+			report.add(issue)
+		}
+		opts.Progress.report(StageValues, i+1, total)
+	}
+	return report, nil
+}
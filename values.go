@@ -0,0 +1,282 @@
+package annotation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateValue parses the field's value as a calendar date, accepting the
+// canonical YYYY-MM-DD form or the field's Formatting.DateFormat.
+func (f *Field) DateValue() (Date, error) {
+	if f.DataType != DataTypeDate {
+		return Date{}, fmt.Errorf("field %q has data type %q, not date", f.FieldID, f.DataType)
+	}
+	d, problem := parseDate(f.Value, f.dateFormat())
+	if problem != "" {
+		return Date{}, fmt.Errorf("field %q: date %s %s", f.FieldID, quoteValue(f, f.Value), problem)
+	}
+	return d, nil
+}
+
+// DecimalValue parses the field's value as an exact decimal. Values in the
+// field's display format, such as "$1,234.00" or "(50)", are accepted.
+func (f *Field) DecimalValue() (*big.Rat, error) {
+	if f.DataType != DataTypeDecimal && f.DataType != DataTypeInteger {
+		return nil, fmt.Errorf("field %q has data type %q, not decimal or integer", f.FieldID, f.DataType)
+	}
+	if f.Value == "" {
+		return nil, fmt.Errorf("field %q has no value", f.FieldID)
+	}
+	n, ok := parseDecimal(f.Value)
+	if !ok {
+		if s, err := f.ParseFormattedValue(f.Value); err == nil {
+			n, ok = parseDecimal(s)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("field %q: %s is not a number", f.FieldID, quoteValue(f, f.Value))
+	}
+	return n, nil
+}
+
+// BoolValue parses the field's value as a boolean. An empty value is
+// false, as an unmarked checkbox is.
+func (f *Field) BoolValue() (bool, error) {
+	if f.DataType != DataTypeBoolean && f.FieldType != FieldTypeCheckbox {
+		return false, fmt.Errorf("field %q has data type %q, not boolean", f.FieldID, f.DataType)
+	}
+	if f.Value == "" {
+		return false, nil
+	}
+	b, ok := parseBoolValue(f.Value)
+	if !ok {
+		return false, fmt.Errorf("field %q: %s is not a boolean", f.FieldID, quoteValue(f, f.Value))
+	}
+	return b, nil
+}
+
+// TypedValue returns the field's value as its data type: a Date for dates,
+// a bool for booleans, an int64 for integers, a *big.Rat for decimals, and
+// the string itself otherwise. It returns nil for an empty value, except
+// that booleans are false.
+func (f *Field) TypedValue() (any, error) {
+	if f.Value == "" && f.DataType != DataTypeBoolean {
+		return nil, nil
+	}
+	switch f.DataType {
+	case DataTypeDate:
+		d, err := f.DateValue()
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	case DataTypeBoolean:
+		b, err := f.BoolValue()
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	case DataTypeInteger:
+		n, err := f.DecimalValue()
+		if err != nil {
+			return nil, err
+		}
+		if !n.IsInt() || !n.Num().IsInt64() {
+			return nil, fmt.Errorf("field %q: %s is not a whole number in range", f.FieldID, quoteValue(f, f.Value))
+		}
+		return n.Num().Int64(), nil
+	case DataTypeDecimal:
+		n, err := f.DecimalValue()
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return f.Value, nil
+}
+
+// SetTypedValue stores v as the field's value in the canonical string form
+// for its data type. Dates accept Date or time.Time; a time.Time contributes
+// its calendar date in its own location and is never converted to UTC first.
+func (f *Field) SetTypedValue(v any) error {
+	s, err := canonicalValue(f, v)
+	if err != nil {
+		return fmt.Errorf("field %q: %w", f.FieldID, err)
+	}
+	f.Value = s
+	return nil
+}
+
+func canonicalValue(f *Field, v any) (string, error) {
+	switch f.DataType {
+	case DataTypeDate:
+		switch v := v.(type) {
+		case Date:
+			if !v.Valid() {
+				return "", fmt.Errorf("%s is not a real calendar date", displayValue(f, v.String()))
+			}
+			return v.String(), nil
+		case time.Time:
+			return DateOf(v).String(), nil
+		case string:
+			d, problem := parseDate(v, f.dateFormat())
+			if problem != "" {
+				return "", fmt.Errorf("date %s %s", quoteValue(f, v), problem)
+			}
+			return d.String(), nil
+		}
+	case DataTypeBoolean:
+		switch v := v.(type) {
+		case bool:
+			return strconv.FormatBool(v), nil
+		case string:
+			b, ok := parseBoolValue(v)
+			if !ok {
+				return "", fmt.Errorf("%s is not a boolean", quoteValue(f, v))
+			}
+			return strconv.FormatBool(b), nil
+		}
+	case DataTypeDecimal, DataTypeInteger:
+		var n *big.Rat
+		switch v := v.(type) {
+		case int:
+			n = big.NewRat(int64(v), 1)
+		case int64:
+			n = big.NewRat(v, 1)
+		case float64:
+			n = new(big.Rat).SetFloat64(v)
+		case *big.Rat:
+			n = v
+		case string:
+			var ok bool
+			if n, ok = parseDecimal(v); !ok {
+				return "", fmt.Errorf("%s is not a number", quoteValue(f, v))
+			}
+		}
+		if n != nil {
+			if f.DataType == DataTypeInteger {
+				if !n.IsInt() {
+					return "", fmt.Errorf("%s is not a whole number", displayValue(f, n.FloatString(2)))
+				}
+				return n.Num().String(), nil
+			}
+			return decimalString(n), nil
+		}
+	default:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		if s, err := scalarString(v); err == nil {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("cannot store %T in a %s field", v, f.DataType)
+}
+
+// decimalString renders n without exponent or trailing zeros.
+func decimalString(n *big.Rat) string {
+	if n.IsInt() {
+		return n.Num().String()
+	}
+	s := n.FloatString(10)
+	return strings.TrimRight(strings.TrimRight(s, "0"), ".")
+}
+
+func (f *Field) dateFormat() string {
+	if f.Formatting != nil {
+		return f.Formatting.DateFormat
+	}
+	return ""
+}
+
+// ExtractOptions controls ExtractValues.
+type ExtractOptions struct {
+	// DateFormat renders date values; the default is YYYY-MM-DD.
+	DateFormat string
+}
+
+// ExtractValues returns the filled values as a nested document keyed by each
+// field's FieldValue path, the inverse of FillFromData. Field transforms are
+// inverted where they can be. Values are typed by
+// data type: booleans as bool, numbers as json.Number, and dates as strings
+// in opts.DateFormat. Fields without a value or path are omitted. A radio
+// group with expected options yields the option code of its checked member
+// at the group's path instead of one value per member.
+func (fa *FormAnnotation) ExtractValues(opts ExtractOptions) (map[string]any, error) {
+	out := map[string]any{}
+	members, err := fa.extractOptionGroups(out)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fa.Fields() {
+		if field.FieldValue == "" || field.Value == "" || members[field.FieldID] {
+			continue
+		}
+		v, err := extractedValue(field, opts)
+		if err != nil {
+			return nil, err
+		}
+		if err := setPath(out, field.FieldValue, v); err != nil {
+			return nil, fmt.Errorf("field %q: %w", field.FieldID, err)
+		}
+	}
+	return out, nil
+}
+
+func extractedValue(f *Field, opts ExtractOptions) (any, error) {
+	if len(f.Transforms) > 0 {
+		value, err := invertTransforms(f, f.Value)
+		if err != nil {
+			return nil, err
+		}
+		inverted := *f
+		inverted.Value = value
+		f = &inverted
+	}
+	switch {
+	case f.DataType == DataTypeDate:
+		d, err := f.DateValue()
+		if err != nil {
+			return nil, err
+		}
+		return d.Format(dateFormatOrISO(opts.DateFormat)), nil
+	case f.DataType == DataTypeBoolean || f.FieldType == FieldTypeCheckbox:
+		return isChecked(f.Value), nil
+	case f.DataType == DataTypeDecimal || f.DataType == DataTypeInteger:
+		n, ok := parseDecimal(f.Value)
+		if !ok {
+			return nil, fmt.Errorf("field %q: %s is not a number", f.FieldID, quoteValue(f, f.Value))
+		}
+		return json.Number(decimalString(n)), nil
+	}
+	return f.Value, nil
+}
+
+// setPath stores v at a dotted path, creating intermediate maps.
+func setPath(doc map[string]any, path string, v any) error {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := doc[key]
+		if !ok {
+			m := map[string]any{}
+			doc[key] = m
+			doc = m
+			continue
+		}
+		m, ok := next.(map[string]any)
+		if !ok {
+			return fmt.Errorf("path %q conflicts with a value at %q", path, key)
+		}
+		doc = m
+	}
+	last := keys[len(keys)-1]
+	if _, ok := doc[last].(map[string]any); ok {
+		return fmt.Errorf("path %q conflicts with nested values", path)
+	}
+	doc[last] = v
+	return nil
+}
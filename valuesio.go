@@ -0,0 +1,217 @@
+package annotation
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ValueFormat names a flat file layout for ExportValues and ImportValues.
+type ValueFormat string
+
+const (
+	// ValuesCSV is a CSV sheet with a field_id and a value column.
+	ValuesCSV ValueFormat = "csv"
+	// ValuesKeyValue is one field_id=value line per field. Blank lines and
+	// lines starting with # are skipped, and a value is Go-quoted when it
+	// has surrounding spaces, a line break or a leading quote.
+	ValuesKeyValue ValueFormat = "kv"
+)
+
+// valueEntry is one field_id and value read from a values file, with its
+// line number.
+type valueEntry struct {
+	line      int
+	id, value string
+}
+
+// ExportValues writes every field's ID and value, in page order, as a
+// values file in format. Unfilled fields are written with an empty value,
+// so the output doubles as a blank sheet to fill in.
+func (fa *FormAnnotation) ExportValues(w io.Writer, format ValueFormat) error {
+	switch format {
+	case ValuesCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"field_id", "value"}); err != nil {
+			return err
+		}
+		for _, f := range fa.Fields() {
+			if err := cw.Write([]string{f.FieldID, f.Value}); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case ValuesKeyValue:
+		bw := bufio.NewWriter(w)
+		for _, f := range fa.Fields() {
+			if f.FieldID == "" || strings.ContainsAny(f.FieldID, "=\r\n") || strings.HasPrefix(strings.TrimSpace(f.FieldID), "#") {
+				return fmt.Errorf("export values: field ID %q cannot be written as a key", f.FieldID)
+			}
+			value := f.Value
+			if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\r\n") || strings.HasPrefix(value, `"`) {
+				value = strconv.Quote(value)
+			}
+			fmt.Fprintf(bw, "%s=%s\n", f.FieldID, value)
+		}
+		return bw.Flush()
+	}
+	return fmt.Errorf("export values: unknown format %q", format)
+}
+
+// ImportValues fills fields from a values file in format, matching rows to
+// fields by ID. Each value is checked against the field's data type as
+// FillFromData checks upstream strings under opts.Coercion, with true and
+// false accepted for boolean fields, then transformed and placed as
+// FillFromData places it. Values are trimmed of surrounding spaces, other
+// than inside a quoted ValuesKeyValue value, and an empty one leaves the
+// field as it is. Unknown fields and values of the wrong type are reported,
+// naming the line, and skipped. Malformed input, a field listed twice or an
+// unknown format fails the import before any value is placed.
+func (fa *FormAnnotation) ImportValues(r io.Reader, format ValueFormat, opts FillOptions) (*FillReport, error) {
+	var entries []valueEntry
+	var err error
+	switch format {
+	case ValuesCSV:
+		entries, err = readValuesCSV(r)
+	case ValuesKeyValue:
+		entries, err = readKeyValues(r)
+	default:
+		err = fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("import values: %w", err)
+	}
+	index := fa.BuildIndex()
+	seen := map[*Field]int{}
+	for _, e := range entries {
+		if field, _ := index.lookup(e.id); field != nil {
+			if first, ok := seen[field]; ok {
+				return nil, fmt.Errorf("import values: line %d: field %q is already set on line %d", e.line, e.id, first)
+			}
+			seen[field] = e.line
+		}
+	}
+
+	report := &FillReport{}
+	for _, e := range entries {
+		field, page := index.lookup(e.id)
+		if field == nil {
+			report.add(FillIssue{
+				FieldID:  e.id,
+				Code:     FillUnknownField,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("line %d: no field with ID %q", e.line, e.id),
+			})
+			continue
+		}
+		if e.value == "" {
+			continue
+		}
+		var raw any = e.value
+		if field.DataType == DataTypeBoolean && (e.value == "true" || e.value == "false") {
+			raw = e.value == "true"
+		}
+		value, rule, err := opts.Coercion.coerce(field, raw)
+		if err != nil {
+			report.add(FillIssue{
+				FieldID:  field.FieldID,
+				Page:     page,
+				Code:     FillTypeMismatch,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("line %d: %v", e.line, err),
+			})
+			continue
+		}
+		if rule != "" {
+			report.Coercions = append(report.Coercions, coercion(field, page, rule, raw, value))
+		}
+		fa.placeTransformed(field, page, value, opts, report)
+	}
+	return report, nil
+}
+
+// readValuesCSV reads a sheet with field_id and value columns; other
+// columns are ignored.
+func readValuesCSV(r io.Reader) ([]valueEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	idCol, valueCol := -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")) {
+		case "field_id":
+			idCol = i
+		case "value":
+			valueCol = i
+		}
+	}
+	if idCol < 0 || valueCol < 0 {
+		return nil, errors.New("the sheet needs field_id and value columns")
+	}
+	var entries []valueEntry
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		e := valueEntry{line: line}
+		if idCol < len(record) {
+			e.id = strings.TrimSpace(record[idCol])
+		}
+		if valueCol < len(record) {
+			e.value = strings.TrimSpace(record[valueCol])
+		}
+		if e.id == "" {
+			if e.value == "" {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: empty field_id", line)
+		}
+		entries = append(entries, e)
+	}
+}
+
+// readKeyValues reads field_id=value lines in the ValuesKeyValue layout.
+func readKeyValues(r io.Reader) ([]valueEntry, error) {
+	var entries []valueEntry
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		id, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: not a field_id=value pair", line)
+		}
+		e := valueEntry{line: line, id: strings.TrimSpace(id), value: strings.TrimSpace(value)}
+		if e.id == "" {
+			return nil, fmt.Errorf("line %d: empty field_id", line)
+		}
+		if strings.HasPrefix(e.value, `"`) {
+			unquoted, err := strconv.Unquote(e.value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: field %q: malformed quoted value", line, e.id)
+			}
+			e.value = unquoted
+		}
+		entries = append(entries, e)
+	}
+	return entries, sc.Err()
+}
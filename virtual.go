@@ -0,0 +1,19 @@
+package annotation
+
+// IsVirtual reports whether the field is bound to data but never rendered.
+// Virtual fields take part in value binding, rules and extraction, but have
+// no position and are excluded from geometry checks, renderers and stamp plans.
+func (f *Field) IsVirtual() bool {
+	return f.FieldType == FieldTypeVirtual
+}
+
+// renderedFields returns pointers to the fields of a page that have geometry.
+func renderedFields(fields []Field) []*Field {
+	out := make([]*Field, 0, len(fields))
+	for i := range fields {
+		if !fields[i].IsVirtual() {
+			out = append(out, &fields[i])
+		}
+	}
+	return out
+}
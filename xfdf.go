@@ -0,0 +1,311 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// FormDataOptions controls the FDF and XFDF exports and imports.
+type FormDataOptions struct {
+	// Names maps field IDs to PDF field names, taking precedence over each
+	// field's pdf_name and the metadata name mapping.
+	Names map[string]string
+}
+
+// Checkbox states in FDF and XFDF.
+const (
+	pdfChecked   = "Yes"
+	pdfUnchecked = "Off"
+)
+
+// pdfValue is one field's value as written for a PDF form.
+type pdfValue struct {
+	name  string
+	value string
+	check bool // value is a checkbox state, written as a name in FDF
+	field *Field
+}
+
+// pdfValues resolves values, keyed by field ID, to PDF names and the text
+// written for them. A nil map takes the fields' filled values. Image fields
+// are left out.
+func (fa *FormAnnotation) pdfValues(values map[string]string, opts FormDataOptions) ([]pdfValue, error) {
+	index := fa.BuildIndex()
+	if values == nil {
+		values = map[string]string{}
+		for _, f := range fa.Fields() {
+			if f.Value != "" && !f.IsVirtual() {
+				values[f.FieldID] = f.Value
+			}
+		}
+	}
+	var out []pdfValue
+	for _, id := range sortedKeys(values) {
+		field := index.ByID(id)
+		if field == nil {
+			return nil, fmt.Errorf("no field with ID %q", id)
+		}
+		v := pdfValue{name: fa.pdfNameWith(field, opts), field: field}
+		switch field.FieldType {
+		case FieldTypeCheckbox:
+			v.value, v.check = pdfUnchecked, true
+			if isChecked(values[id]) {
+				v.value = pdfChecked
+				if fa.optionMember(field, field.OptionCode) == field {
+					// Radio buttons share one PDF field, whose value names
+					// the checked button.
+					v.value = field.OptionCode
+				}
+			}
+		case FieldTypeSegmented:
+			v.value = values[id]
+		case FieldTypeChoice:
+			v.value = field.exportValue(values[id])
+		case FieldTypeImage:
+			// Form data has no place for a picture; it is stamped instead.
+			continue
+		default:
+			text, err := field.FormatValue(values[id])
+			if err != nil {
+				return nil, err
+			}
+			v.value = text
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// optionMember returns the member of field's option group whose option code
+// is code, or nil when field is in no option group or no member has it.
+func (fa *FormAnnotation) optionMember(field *Field, code string) *Field {
+	g := fa.GetGroup(field.GroupID)
+	if code == "" || g == nil || !g.optionGroup() {
+		return nil
+	}
+	for _, id := range g.FieldIDs {
+		if m := fa.GetFieldByID(id); m != nil && m.OptionCode == code {
+			return m
+		}
+	}
+	return nil
+}
+
+func (fa *FormAnnotation) pdfNameWith(field *Field, opts FormDataOptions) string {
+	if name, ok := opts.Names[field.FieldID]; ok {
+		return name
+	}
+	return fa.PDFNameFor(field)
+}
+
+// pdfNode is a level of the PDF field name hierarchy, in which
+// "topmostSubform[0].Page1[0].f1_01[0]" is three nested fields.
+type pdfNode struct {
+	name     string
+	value    *pdfValue
+	children []*pdfNode
+}
+
+func pdfTree(values []pdfValue) *pdfNode {
+	root := &pdfNode{}
+	for i := range values {
+		node := root
+		for _, part := range strings.Split(values[i].name, ".") {
+			var next *pdfNode
+			for _, c := range node.children {
+				if c.name == part {
+					next = c
+					break
+				}
+			}
+			if next == nil {
+				next = &pdfNode{name: part}
+				node.children = append(node.children, next)
+			}
+			node = next
+		}
+		if node.value != nil && values[i].check && values[i].value == pdfUnchecked {
+			continue // an unchecked radio button leaves its group's value
+		}
+		node.value = &values[i]
+	}
+	return root
+}
+
+type xfdfDoc struct {
+	XMLName xml.Name    `xml:"http://ns.adobe.com/xfdf/ xfdf"`
+	Space   string      `xml:"xml:space,attr,omitempty"`
+	Fields  []xfdfField `xml:"fields>field"`
+}
+
+type xfdfField struct {
+	Name   string      `xml:"name,attr"`
+	Value  *string     `xml:"value"`
+	Fields []xfdfField `xml:"field"`
+}
+
+func xfdfFields(nodes []*pdfNode) []xfdfField {
+	var out []xfdfField
+	for _, n := range nodes {
+		f := xfdfField{Name: n.name, Fields: xfdfFields(n.children)}
+		if n.value != nil {
+			f.Value = &n.value.value
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// ToXFDF writes values, keyed by field ID, as an Adobe XFDF document for
+// filling the PDF form; a nil map writes the fields' filled values. Fields
+// are named by PDFNameFor unless opts.Names renames them, and dotted names
+// are nested as the form's field hierarchy. Checkboxes are written "Yes"
+// or "Off", and a radio group, whose buttons share one PDF field, as the
+// option code of its checked member. Segmented values are written as
+// given, and every other value as FormatValue renders it.
+func (fa *FormAnnotation) ToXFDF(values map[string]string, opts FormDataOptions) ([]byte, error) {
+	pv, err := fa.pdfValues(values, opts)
+	if err != nil {
+		return nil, fmt.Errorf("xfdf: %w", err)
+	}
+	doc := xfdfDoc{Space: "preserve", Fields: xfdfFields(pdfTree(pv).children)}
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("xfdf: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// ExportXFDF writes the fields' filled values as an XFDF document, as
+// ToXFDF does with nil values and no renaming.
+func (fa *FormAnnotation) ExportXFDF() ([]byte, error) {
+	return fa.ToXFDF(nil, FormDataOptions{})
+}
+
+// FromXFDF reads the values of an XFDF document, such as one exported from
+// Acrobat, keyed by field ID. Names are resolved through opts.Names and then
+// FieldByPDFName. Checkbox states become "true" or "false", a radio
+// group's option code checks the member it names, and other values are
+// read back to their canonical form with ParseFormattedValue. A name no
+// field has is an error.
+func (fa *FormAnnotation) FromXFDF(data []byte, opts FormDataOptions) (map[string]string, error) {
+	var doc xfdfDoc
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("xfdf: %w", err)
+	}
+	byName := map[string]*Field{}
+	for id, name := range opts.Names {
+		if f := fa.GetFieldByID(id); f != nil {
+			byName[name] = f
+		}
+	}
+	out := map[string]string{}
+	var walk func(prefix string, fields []xfdfField) error
+	walk = func(prefix string, fields []xfdfField) error {
+		for _, f := range fields {
+			name := f.Name
+			if prefix != "" {
+				name = prefix + "." + f.Name
+			}
+			if f.Value != nil {
+				field := byName[name]
+				if field == nil {
+					field = fa.FieldByPDFName(name)
+				}
+				if field == nil {
+					return fmt.Errorf("xfdf: no field is named %q", name)
+				}
+				if m := fa.optionMember(field, *f.Value); m != nil {
+					field = m
+				}
+				value, err := pdfFieldValue(field, *f.Value)
+				if err != nil {
+					return fmt.Errorf("xfdf: %w", err)
+				}
+				out[field.FieldID] = value
+			}
+			if err := walk(name, f.Fields); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("", doc.Fields); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// pdfFieldValue reads a value written to a PDF form back to canonical form.
+func pdfFieldValue(field *Field, value string) (string, error) {
+	if field.FieldType == FieldTypeCheckbox {
+		if value == pdfUnchecked || value == "" {
+			return "false", nil
+		}
+		return "true", nil
+	}
+	if value == "" {
+		return "", nil
+	}
+	return field.ParseFormattedValue(value)
+}
+
+// ToFDF writes values like ToXFDF, as an FDF document. Dotted names are
+// nested with /Kids, checkbox states are written as the names /Yes and
+// /Off, and text outside ASCII is written as UTF-16.
+func (fa *FormAnnotation) ToFDF(values map[string]string, opts FormDataOptions) ([]byte, error) {
+	pv, err := fa.pdfValues(values, opts)
+	if err != nil {
+		return nil, fmt.Errorf("fdf: %w", err)
+	}
+	var b bytes.Buffer
+	b.WriteString("%FDF-1.2\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<< /FDF << /Fields [\n")
+	writeFDFFields(&b, pdfTree(pv).children, 1)
+	b.WriteString("] >> >>\nendobj\ntrailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return b.Bytes(), nil
+}
+
+func writeFDFFields(b *bytes.Buffer, nodes []*pdfNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for _, n := range nodes {
+		fmt.Fprintf(b, "%s<< /T %s", indent, fdfString(n.name))
+		if v := n.value; v != nil {
+			if v.check {
+				fmt.Fprintf(b, " /V /%s", v.value)
+			} else {
+				fmt.Fprintf(b, " /V %s", fdfString(v.value))
+			}
+		}
+		if len(n.children) > 0 {
+			b.WriteString(" /Kids [\n")
+			writeFDFFields(b, n.children, depth+1)
+			b.WriteString(indent + "]")
+		}
+		b.WriteString(" >>\n")
+	}
+}
+
+// fdfString writes s as a PDF string: literal when it is printable ASCII,
+// UTF-16BE with a byte order mark otherwise.
+func fdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+	}
+	var sb strings.Builder
+	sb.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&sb, "%04X", u)
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
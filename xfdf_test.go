@@ -0,0 +1,34 @@
+package annotation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExportXFDF(t *testing.T) {
+	fa := filledAcroForm(t)
+	got, err := fa.ExportXFDF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "acroform/filled.xfdf", got)
+
+	values, err := fa.FromXFDF(got, FormDataOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{}
+	for _, f := range fa.Fields() {
+		if f.Value != "" {
+			want[f.FieldID] = f.Value
+		}
+	}
+	for id, v := range values {
+		if v == "false" && want[id] == "" {
+			delete(values, id)
+		}
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("FromXFDF read back %v, want %v", values, want)
+	}
+}
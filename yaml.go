@@ -0,0 +1,681 @@
+package annotation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The YAML support covers what people write by hand in annotation files:
+// block mappings and sequences, comments, plain, quoted and block (| and >)
+// scalars, and single-line flow collections. Anchors, aliases, tags and
+// multi-document streams are not supported. A YAML document is read by
+// converting it to JSON, so the JSON field names and rules apply unchanged.
+
+// FromYAML parses a YAML document into a FormAnnotation.
+func FromYAML(yamlStr string) (*FormAnnotation, error) {
+	return parseYAMLAnnotation([]byte(yamlStr), LoadOptions{})
+}
+
+// ToYAML converts the FormAnnotation to a YAML document with the same
+// field names and content as ToJSON.
+func (fa *FormAnnotation) ToYAML() (string, error) {
+	data, err := json.Marshal(fa)
+	if err != nil {
+		return "", err
+	}
+	return jsonToYAML(data)
+}
+
+// SaveToYAMLFile writes the FormAnnotation to a YAML file, with the same
+// reader requirements and gate checks as SaveToFile.
+func (fa *FormAnnotation) SaveToYAMLFile(filepath string) error {
+	data, err := fa.encodeYAML()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath, []byte(data), 0644)
+}
+
+// LoadYAML reads a YAML annotation from r as LoadFile reads a .yaml file.
+func LoadYAML(r io.Reader, opts LoadOptions) (*FormAnnotation, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseYAMLAnnotation(data, opts)
+}
+
+// SaveYAML writes the annotation to w as SaveToYAMLFile writes a file.
+func (fa *FormAnnotation) SaveYAML(w io.Writer) error {
+	data, err := fa.encodeYAML()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, data)
+	return err
+}
+
+func (fa *FormAnnotation) encodeYAML() (string, error) {
+	fa, err := fa.forSave()
+	if err != nil {
+		return "", err
+	}
+	if err := FeatureGates(nil).checkEmit(fa); err != nil {
+		return "", err
+	}
+	return fa.withReaderRequirements().ToYAML()
+}
+
+// isYAMLName reports whether a file or key name has a YAML extension.
+func isYAMLName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".yaml") || strings.HasSuffix(lower, ".yml")
+}
+
+func parseYAMLAnnotation(data []byte, opts LoadOptions) (*FormAnnotation, error) {
+	data, err := decodeInput(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = yamlToJSON(data); err != nil {
+		return nil, err
+	}
+	return decodeAnnotation(data, opts)
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string // without indentation and trailing comment
+	raw    string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+// yamlToJSON converts a YAML document to the equivalent JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for n, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		body := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(body, "\t") {
+			return nil, fmt.Errorf("yaml: line %d: tabs cannot be used for indentation", n+1)
+		}
+		text := stripYAMLComment(body)
+		if text == "---" || text == "..." {
+			text = ""
+		}
+		p.lines = append(p.lines, yamlLine{num: n + 1, indent: len(raw) - len(body), text: text, raw: raw})
+	}
+	var buf bytes.Buffer
+	line, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("yaml: document is empty")
+	}
+	if err := p.block(&buf, line.indent); err != nil {
+		return nil, err
+	}
+	if line, ok := p.next(); ok {
+		return nil, fmt.Errorf("yaml: line %d: unexpected content", line.num)
+	}
+	return buf.Bytes(), nil
+}
+
+// next returns the next line with content, skipping blank and comment lines.
+func (p *yamlParser) next() (yamlLine, bool) {
+	for p.i < len(p.lines) && p.lines[p.i].text == "" {
+		p.i++
+	}
+	if p.i == len(p.lines) {
+		return yamlLine{}, false
+	}
+	return p.lines[p.i], true
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// block parses the node starting at the current line, whose indentation
+// is indent.
+func (p *yamlParser) block(buf *bytes.Buffer, indent int) error {
+	line, _ := p.next()
+	if isSeqItem(line.text) {
+		return p.sequence(buf, indent)
+	}
+	if _, _, ok, err := splitYAMLKey(line.text); err != nil {
+		return fmt.Errorf("yaml: line %d: %v", line.num, err)
+	} else if ok {
+		return p.mapping(buf, indent)
+	}
+	p.i++
+	return p.inline(buf, line, line.text, indent)
+}
+
+func (p *yamlParser) mapping(buf *bytes.Buffer, indent int) error {
+	buf.WriteByte('{')
+	seen := map[string]bool{}
+	for {
+		line, ok := p.next()
+		if !ok || line.indent < indent || (line.indent == indent && isSeqItem(line.text)) {
+			break
+		}
+		if line.indent > indent {
+			return fmt.Errorf("yaml: line %d: unexpected indentation", line.num)
+		}
+		key, rest, ok, err := splitYAMLKey(line.text)
+		if err == nil && !ok {
+			err = fmt.Errorf("expected a key: value entry")
+		}
+		if err != nil {
+			return fmt.Errorf("yaml: line %d: %v", line.num, err)
+		}
+		if seen[key] {
+			return fmt.Errorf("yaml: line %d: duplicate key %q", line.num, key)
+		}
+		if len(seen) > 0 {
+			buf.WriteByte(',')
+		}
+		seen[key] = true
+		writeJSONString(buf, key)
+		buf.WriteByte(':')
+		p.i++
+		if rest == "" {
+			// A sequence may sit at its key's own indentation.
+			if next, ok := p.next(); ok && next.indent == indent && isSeqItem(next.text) {
+				if err := p.sequence(buf, indent); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if err := p.inline(buf, line, rest, indent); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+func (p *yamlParser) sequence(buf *bytes.Buffer, indent int) error {
+	buf.WriteByte('[')
+	for n := 0; ; n++ {
+		line, ok := p.next()
+		if !ok || line.indent != indent || !isSeqItem(line.text) {
+			if ok && line.indent > indent {
+				return fmt.Errorf("yaml: line %d: unexpected indentation", line.num)
+			}
+			break
+		}
+		if n > 0 {
+			buf.WriteByte(',')
+		}
+		rest := strings.TrimLeft(line.text[1:], " ")
+		_, _, isKey, _ := splitYAMLKey(rest)
+		if isKey || isSeqItem(rest) {
+			// "- key: value" starts a nested node at the column of its content.
+			p.lines[p.i].indent += len(line.text) - len(rest)
+			p.lines[p.i].text = rest
+			if err := p.block(buf, p.lines[p.i].indent); err != nil {
+				return err
+			}
+			continue
+		}
+		p.i++
+		if err := p.inline(buf, line, rest, indent); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
+
+// inline writes the value written after a key or dash on line: a scalar, a
+// flow collection, a block scalar, or, when empty, the more deeply
+// indented node on the following lines.
+func (p *yamlParser) inline(buf *bytes.Buffer, line yamlLine, rest string, indent int) error {
+	switch {
+	case rest == "":
+		if next, ok := p.next(); ok && next.indent > indent {
+			return p.block(buf, next.indent)
+		}
+		buf.WriteString("null")
+		return nil
+	case rest[0] == '|' || rest[0] == '>':
+		return p.blockScalar(buf, line, rest, indent)
+	case rest[0] == '[' || rest[0] == '{':
+		f := &yamlFlow{s: rest}
+		if err := f.value(buf); err != nil {
+			return fmt.Errorf("yaml: line %d: %v", line.num, err)
+		}
+		if f.skipSpace(); f.pos != len(f.s) {
+			return fmt.Errorf("yaml: line %d: unexpected %q after flow collection", line.num, f.s[f.pos:])
+		}
+		return nil
+	}
+	if err := writeYAMLScalar(buf, rest); err != nil {
+		return fmt.Errorf("yaml: line %d: %v", line.num, err)
+	}
+	return nil
+}
+
+// blockScalar reads a literal (|) or folded (>) scalar, with an optional
+// chomping indicator, from the lines indented beyond indent.
+func (p *yamlParser) blockScalar(buf *bytes.Buffer, line yamlLine, header string, indent int) error {
+	style, chomp := header[0], strings.TrimSpace(header[1:])
+	if chomp != "" && chomp != "-" && chomp != "+" {
+		return fmt.Errorf("yaml: line %d: unsupported block scalar header %q", line.num, header)
+	}
+	var content []string
+	contentIndent := -1
+	for ; p.i < len(p.lines); p.i++ {
+		l := p.lines[p.i]
+		if strings.TrimSpace(l.raw) == "" {
+			content = append(content, "")
+			continue
+		}
+		if l.indent <= indent {
+			break
+		}
+		if contentIndent < 0 {
+			contentIndent = l.indent
+		}
+		if l.indent < contentIndent {
+			return fmt.Errorf("yaml: line %d: block scalar line is indented less than the first", l.num)
+		}
+		content = append(content, l.raw[contentIndent:])
+	}
+	trailing := 0
+	for len(content) > 0 && content[len(content)-1] == "" {
+		content = content[:len(content)-1]
+		trailing++
+	}
+	var body string
+	if style == '|' {
+		body = strings.Join(content, "\n")
+	} else {
+		var sb strings.Builder
+		for i, c := range content {
+			switch {
+			case i == 0:
+			case c == "" || content[i-1] == "":
+				sb.WriteByte('\n')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(c)
+		}
+		body = sb.String()
+	}
+	switch {
+	case chomp == "-" || len(content) == 0:
+	case chomp == "+":
+		body += strings.Repeat("\n", trailing+1)
+	default:
+		body += "\n"
+	}
+	writeJSONString(buf, body)
+	return nil
+}
+
+// stripYAMLComment removes a trailing comment: a # at the start of the
+// text or after whitespace, outside quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || strings.IndexByte(" [{,:", text[i-1]) >= 0 {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+	return strings.TrimRight(text, " ")
+}
+
+// splitYAMLKey splits a "key: value" entry. ok is false when text is not an
+// entry.
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	if text == "" || isSeqItem(text) {
+		return "", "", false, nil
+	}
+	if text[0] == '"' || text[0] == '\'' {
+		end := quotedEnd(text)
+		if end < 0 {
+			return "", "", false, fmt.Errorf("unterminated quoted string")
+		}
+		after := text[end:]
+		if after != ":" && !strings.HasPrefix(after, ": ") {
+			return "", "", false, nil
+		}
+		key, err := unquoteYAML(text[:end])
+		return key, strings.TrimSpace(after[1:]), err == nil, err
+	}
+	if text[0] == '[' || text[0] == '{' {
+		return "", "", false, nil
+	}
+	i := strings.Index(text, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(text, ":") {
+			return "", "", false, nil
+		}
+		i = len(text) - 1
+	}
+	return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true, nil
+}
+
+// quotedEnd returns the index just past the quoted string at the start of
+// s, or -1 when it is not terminated.
+func quotedEnd(s string) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func unquoteYAML(s string) (string, error) {
+	if s[0] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	var out string
+	if json.Unmarshal([]byte(s), &out) == nil {
+		return out, nil
+	}
+	out, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted string %s", s)
+	}
+	return out, nil
+}
+
+var yamlNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// writeYAMLScalar writes a complete scalar as JSON. Plain scalars are null,
+// booleans and JSON-style numbers by the YAML core schema; anything else is
+// a string.
+func writeYAMLScalar(buf *bytes.Buffer, s string) error {
+	if s[0] == '"' || s[0] == '\'' {
+		if quotedEnd(s) != len(s) {
+			return fmt.Errorf("unexpected text after quoted string %s", s)
+		}
+		v, err := unquoteYAML(s)
+		if err != nil {
+			return err
+		}
+		writeJSONString(buf, v)
+		return nil
+	}
+	switch s {
+	case "null", "Null", "NULL", "~":
+		buf.WriteString("null")
+	case "true", "True", "TRUE":
+		buf.WriteString("true")
+	case "false", "False", "FALSE":
+		buf.WriteString("false")
+	default:
+		if yamlNumber.MatchString(s) {
+			buf.WriteString(s)
+		} else {
+			writeJSONString(buf, s)
+		}
+	}
+	return nil
+}
+
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	buf.Truncate(buf.Len() - 1) // Encode's newline
+}
+
+// yamlFlow parses a single-line flow collection such as [a, "b", {c: 1}].
+type yamlFlow struct {
+	s   string
+	pos int
+}
+
+func (f *yamlFlow) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value(buf *bytes.Buffer) error {
+	f.skipSpace()
+	if f.pos == len(f.s) {
+		return fmt.Errorf("unexpected end of flow collection")
+	}
+	switch f.s[f.pos] {
+	case '[':
+		return f.collection(buf, ']', func() error { return f.value(buf) })
+	case '{':
+		return f.collection(buf, '}', func() error {
+			if err := f.scalar(buf, true); err != nil {
+				return err
+			}
+			if f.skipSpace(); f.pos == len(f.s) || f.s[f.pos] != ':' {
+				return fmt.Errorf("expected ':' in flow mapping")
+			}
+			f.pos++
+			buf.WriteByte(':')
+			return f.value(buf)
+		})
+	}
+	return f.scalar(buf, false)
+}
+
+func (f *yamlFlow) collection(buf *bytes.Buffer, end byte, item func() error) error {
+	buf.WriteByte(f.s[f.pos])
+	f.pos++
+	for n := 0; ; n++ {
+		if f.skipSpace(); f.pos < len(f.s) && f.s[f.pos] == end {
+			f.pos++
+			buf.WriteByte(end)
+			return nil
+		}
+		if n > 0 {
+			if f.pos == len(f.s) || f.s[f.pos] != ',' {
+				return fmt.Errorf("expected ',' or %q in flow collection", end)
+			}
+			f.pos++
+			buf.WriteByte(',')
+		}
+		if err := item(); err != nil {
+			return err
+		}
+	}
+}
+
+// scalar reads a flow scalar; keys are always strings.
+func (f *yamlFlow) scalar(buf *bytes.Buffer, key bool) error {
+	f.skipSpace()
+	start := f.pos
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		end := quotedEnd(f.s[f.pos:])
+		if end < 0 {
+			return fmt.Errorf("unterminated quoted string")
+		}
+		f.pos += end
+	} else {
+		stop := ",]}"
+		if key {
+			stop = ",]}:"
+		}
+		for f.pos < len(f.s) && strings.IndexByte(stop, f.s[f.pos]) < 0 {
+			f.pos++
+		}
+	}
+	text := strings.TrimSpace(f.s[start:f.pos])
+	if text == "" {
+		return fmt.Errorf("empty value in flow collection")
+	}
+	if key && text[0] != '"' && text[0] != '\'' {
+		writeJSONString(buf, text)
+		return nil
+	}
+	if key {
+		v, err := unquoteYAML(text)
+		if err != nil {
+			return err
+		}
+		writeJSONString(buf, v)
+		return nil
+	}
+	return writeYAMLScalar(buf, text)
+}
+
+// yamlNode is a JSON value with its object keys in document order.
+type yamlNode struct {
+	scalar string // YAML text of a scalar
+	keys   []string
+	values []*yamlNode
+	isMap  bool
+	isSeq  bool
+}
+
+// jsonToYAML renders a JSON document as block-style YAML.
+func jsonToYAML(data []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := readYAMLNode(dec)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if len(root.values) == 0 {
+		sb.WriteString(root.inline() + "\n")
+	} else {
+		root.write(&sb, 0, false)
+	}
+	return sb.String(), nil
+}
+
+func readYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n := &yamlNode{isMap: t == '{', isSeq: t == '['}
+		for dec.More() {
+			if n.isMap {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, key.(string))
+			}
+			child, err := readYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, child)
+		}
+		if _, err := dec.Token(); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return n, nil
+	case string:
+		return &yamlNode{scalar: yamlString(t)}, nil
+	case json.Number:
+		return &yamlNode{scalar: t.String()}, nil
+	case bool:
+		return &yamlNode{scalar: strconv.FormatBool(t)}, nil
+	}
+	return &yamlNode{scalar: "null"}, nil
+}
+
+// inline renders a scalar or an empty collection.
+func (n *yamlNode) inline() string {
+	switch {
+	case n.isMap:
+		return "{}"
+	case n.isSeq:
+		return "[]"
+	}
+	return n.scalar
+}
+
+// write renders a non-empty collection at indent. When continued is set,
+// the first line's indentation has already been written, after a dash.
+func (n *yamlNode) write(sb *strings.Builder, indent int, continued bool) {
+	pad := strings.Repeat(" ", indent)
+	for i, child := range n.values {
+		if i > 0 || !continued {
+			sb.WriteString(pad)
+		}
+		nested := len(child.values) > 0
+		if n.isMap {
+			sb.WriteString(yamlString(n.keys[i]) + ":")
+			if !nested {
+				sb.WriteString(" " + child.inline() + "\n")
+				continue
+			}
+			sb.WriteString("\n")
+			child.write(sb, indent+2, false)
+			continue
+		}
+		sb.WriteString("- ")
+		if !nested {
+			sb.WriteString(child.inline() + "\n")
+			continue
+		}
+		child.write(sb, indent+2, true)
+	}
+}
+
+var yamlReserved = map[string]bool{
+	"null": true, "Null": true, "NULL": true, "~": true,
+	"true": true, "True": true, "TRUE": true, "false": true, "False": true, "FALSE": true,
+	"yes": true, "Yes": true, "YES": true, "no": true, "No": true, "NO": true,
+	"on": true, "On": true, "ON": true, "off": true, "Off": true, "OFF": true,
+	"y": true, "Y": true, "n": true, "N": true,
+}
+
+// yamlString renders s plain when every YAML reader would read it back as
+// the same string, and double-quoted otherwise. Strings older YAML readers
+// take as booleans, such as "yes", are quoted too.
+func yamlString(s string) string {
+	plain := s != "" && !yamlReserved[s] &&
+		strings.IndexByte("-?:,[]{}#&*!|>'\"%@`0123456789.+ ", s[0]) < 0 &&
+		!strings.HasSuffix(s, " ") && !strings.HasSuffix(s, ":") &&
+		!strings.Contains(s, ": ") && !strings.Contains(s, " #")
+	for _, r := range s {
+		if r < ' ' || r == 0x7f || r == '\u2028' || r == '\u2029' || r == '\ufeff' {
+			plain = false
+		}
+	}
+	if plain {
+		return s
+	}
+	var buf bytes.Buffer
+	writeJSONString(&buf, s)
+	return buf.String()
+}
@@ -0,0 +1,142 @@
+package annotation
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Plausible bounds of a field's year range: the first federal income tax
+// return was filed for 1913.
+const (
+	minPlausibleYear = 1913
+	maxPlausibleYear = 2100
+)
+
+// ActiveIn reports whether the field appears on the form for year. A field
+// without IntroducedYear has always been on the form; one without
+// RetiredYear still is. RetiredYear is the last year the field appears.
+func (f *Field) ActiveIn(year int) bool {
+	if f.IntroducedYear != nil && year < *f.IntroducedYear {
+		return false
+	}
+	if f.RetiredYear != nil && year > *f.RetiredYear {
+		return false
+	}
+	return true
+}
+
+// ForYear returns a copy of the annotation for one tax year: the form year
+// is set, fields not active in that year are dropped, groups lose the
+// dropped members and their option codes, and groups left empty are
+// removed. Rules that referenced a dropped field are kept and reported by
+// Validate on the result.
+func (fa *FormAnnotation) ForYear(year int) *FormAnnotation {
+	out := fa.Clone()
+	out.FormMetadata.Year = year
+	dropped := map[string]bool{}
+	droppedCodes := map[string]bool{}
+	for i := range out.Pages {
+		kept := out.Pages[i].Fields[:0]
+		for _, field := range out.Pages[i].Fields {
+			if field.ActiveIn(year) {
+				kept = append(kept, field)
+				continue
+			}
+			dropped[field.FieldID] = true
+			if field.OptionCode != "" {
+				droppedCodes[field.OptionCode] = true
+			}
+		}
+		out.Pages[i].Fields = kept
+	}
+	if len(dropped) == 0 {
+		return out
+	}
+	groups := out.FieldGroups[:0]
+	for _, g := range out.FieldGroups {
+		g.FieldIDs = slices.DeleteFunc(g.FieldIDs, func(id string) bool {
+			return out.GetFieldByID(id) == nil && dropped[id]
+		})
+		if len(g.ExpectedOptions) > 0 {
+			remaining := map[string]bool{}
+			for _, id := range g.FieldIDs {
+				if field := out.GetFieldByID(id); field != nil {
+					remaining[field.OptionCode] = true
+				}
+			}
+			g.ExpectedOptions = slices.DeleteFunc(g.ExpectedOptions, func(code string) bool {
+				return droppedCodes[code] && !remaining[code]
+			})
+		}
+		if len(g.FieldIDs) > 0 || out.hasGroupMember(g.GroupID) {
+			groups = append(groups, g)
+		}
+	}
+	out.FieldGroups = groups
+	return out
+}
+
+// hasGroupMember reports whether any field names groupID as its group.
+func (fa *FormAnnotation) hasGroupMember(groupID string) bool {
+	for _, page := range fa.Pages {
+		for _, field := range page.Fields {
+			if field.GroupID == groupID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// YearChange lists the fields that appear or disappear between two years.
+type YearChange struct {
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// YearChanges compares the annotation materialized for two years. Only the
+// year ranges differ between the two, so the added and removed fields are
+// the whole difference.
+func (fa *FormAnnotation) YearChanges(from, to int) YearChange {
+	change := YearChange{From: from, To: to}
+	for _, page := range fa.Pages {
+		for _, field := range page.Fields {
+			was, is := field.ActiveIn(from), field.ActiveIn(to)
+			switch {
+			case is && !was:
+				change.Added = append(change.Added, field.FieldID)
+			case was && !is:
+				change.Removed = append(change.Removed, field.FieldID)
+			}
+		}
+	}
+	return change
+}
+
+// checkYearRange reports an implausible or inverted year range, and warns
+// when the field is not active in the form's own year.
+func (fa *FormAnnotation) checkYearRange(field *Field, pageNum int) []ValidationIssue {
+	if field.IntroducedYear == nil && field.RetiredYear == nil {
+		return nil
+	}
+	at := ValidationIssue{Code: InvalidYearRange, Severity: SeverityError, FieldID: field.FieldID, Page: pageNum}
+	var issues []ValidationIssue
+	for _, y := range []*int{field.IntroducedYear, field.RetiredYear} {
+		if y != nil && (*y < minPlausibleYear || *y > maxPlausibleYear) {
+			at.Message = fmt.Sprintf("year %d is outside %d-%d", *y, minPlausibleYear, maxPlausibleYear)
+			issues = append(issues, at)
+		}
+	}
+	if field.IntroducedYear != nil && field.RetiredYear != nil && *field.IntroducedYear > *field.RetiredYear {
+		at.Message = fmt.Sprintf("introduced in %d after being retired in %d", *field.IntroducedYear, *field.RetiredYear)
+		issues = append(issues, at)
+	}
+	if year := fa.FormMetadata.Year; year != 0 && len(issues) == 0 && !field.ActiveIn(year) {
+		at.Code, at.Severity = FieldInactiveForYear, SeverityWarning
+		at.Message = fmt.Sprintf("field is not on the %d form", year)
+		issues = append(issues, at)
+	}
+	return issues
+}
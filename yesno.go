@@ -0,0 +1,145 @@
+package annotation
+
+import "fmt"
+
+// GroupTypeYesNo marks a Yes box and a No box answering one question, where
+// leaving both blank is a third state. FieldIDs holds the Yes member first
+// and the No member second.
+const GroupTypeYesNo = "yes_no"
+
+// GroupRuleYesNo allows at most one of the two members to be checked and,
+// for required groups, at least one.
+const GroupRuleYesNo = "yes_no"
+
+// YesNoMemberCount is reported for a yes/no group without exactly two members.
+const YesNoMemberCount = "yes_no_member_count"
+
+// YesNoState is the answer held by a yes/no group.
+type YesNoState int
+
+const (
+	YesNoUnanswered YesNoState = iota
+	YesNoYes
+	YesNoNo
+)
+
+func (s YesNoState) String() string {
+	switch s {
+	case YesNoYes:
+		return "yes"
+	case YesNoNo:
+		return "no"
+	}
+	return "unanswered"
+}
+
+// yesNoMembers returns the Yes and No fields of a yes/no group.
+func (fa *FormAnnotation) yesNoMembers(groupID string) (yes, no *Field, err error) {
+	g := fa.GetGroup(groupID)
+	switch {
+	case g == nil:
+		return nil, nil, fmt.Errorf("no group with ID %q", groupID)
+	case g.GroupType != GroupTypeYesNo:
+		return nil, nil, fmt.Errorf("group %q has type %q, not %q", groupID, g.GroupType, GroupTypeYesNo)
+	case len(g.FieldIDs) != 2:
+		return nil, nil, fmt.Errorf("group %q has %d members, not 2", groupID, len(g.FieldIDs))
+	}
+	if yes = fa.GetFieldByID(g.FieldIDs[0]); yes == nil {
+		return nil, nil, fmt.Errorf("group %q: member %q is not a field", groupID, g.FieldIDs[0])
+	}
+	if no = fa.GetFieldByID(g.FieldIDs[1]); no == nil {
+		return nil, nil, fmt.Errorf("group %q: member %q is not a field", groupID, g.FieldIDs[1])
+	}
+	return yes, no, nil
+}
+
+// GetYesNo returns the answer of a yes/no group. Both boxes checked is an
+// error rather than either answer.
+func (fa *FormAnnotation) GetYesNo(groupID string) (YesNoState, error) {
+	yes, no, err := fa.yesNoMembers(groupID)
+	if err != nil {
+		return YesNoUnanswered, err
+	}
+	switch y, n := isChecked(yes.Value), isChecked(no.Value); {
+	case y && n:
+		return YesNoUnanswered, fmt.Errorf("group %q has both Yes and No checked", groupID)
+	case y:
+		return YesNoYes, nil
+	case n:
+		return YesNoNo, nil
+	}
+	return YesNoUnanswered, nil
+}
+
+// SetYesNo records an answer by checking one member and clearing the other;
+// YesNoUnanswered clears both. Each member is placed like any other fill.
+func (fa *FormAnnotation) SetYesNo(groupID string, state YesNoState, opts FillOptions) (*FillReport, error) {
+	report := &FillReport{}
+	if err := fa.setYesNo(groupID, state, opts, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (fa *FormAnnotation) setYesNo(groupID string, state YesNoState, opts FillOptions, report *FillReport) error {
+	yes, no, err := fa.yesNoMembers(groupID)
+	if err != nil {
+		return err
+	}
+	for _, m := range []struct {
+		field *Field
+		value string
+	}{
+		{yes, checkValue(state == YesNoYes)},
+		{no, checkValue(state == YesNoNo)},
+	} {
+		_, page := fa.fieldAndPage(m.field.FieldID)
+		fa.place(m.field, page, m.value, opts, report)
+	}
+	return nil
+}
+
+func checkValue(checked bool) string {
+	if checked {
+		return "true"
+	}
+	return "false"
+}
+
+// yesNoFromData reads a yes/no answer from fill data: a boolean, a yes/no
+// spelling, or null and the empty string for unanswered.
+func yesNoFromData(raw any) (YesNoState, bool) {
+	switch v := raw.(type) {
+	case nil:
+		return YesNoUnanswered, true
+	case bool:
+		if v {
+			return YesNoYes, true
+		}
+		return YesNoNo, true
+	case string:
+		if v == "" {
+			return YesNoUnanswered, true
+		}
+		if b, ok := parseBoolValue(v); ok {
+			return yesNoFromData(b)
+		}
+	}
+	return YesNoUnanswered, false
+}
+
+// checkYesNoGroups reports yes/no groups without exactly two members.
+func (fa *FormAnnotation) checkYesNoGroups() []ValidationIssue {
+	var issues []ValidationIssue
+	for _, g := range fa.FieldGroups {
+		if g.GroupType == GroupTypeYesNo && len(g.FieldIDs) != 2 {
+			issues = append(issues, ValidationIssue{
+				Code:     YesNoMemberCount,
+				Severity: SeverityError,
+				GroupID:  g.GroupID,
+				Message:  fmt.Sprintf("yes/no group has %d members, not a Yes and a No", len(g.FieldIDs)),
+			})
+		}
+	}
+	return issues
+}